@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// ackResult é o que a rota informa ao cliente sobre o pagamento recebido.
+type ackResult int
+
+const (
+	ackReceived ackResult = iota
+	ackQueued
+	ackProcessed
+	ackQueueFull
+	ackFailed
+)
+
+// acceptPayment entrega o pagamento conforme o modo configurado. forwarded indica
+// um repasse de outra instância, que não pode ser repassado de novo.
+func (gw *gateway) acceptPayment(ctx context.Context, req PaymentRequest, forwarded bool) ackResult {
+	if gw.queueSaturated(1) {
+		return ackQueueFull
+	}
+	gw.receivePayment(&req)
+
+	switch gw.currentConfig().AckMode {
+	case config.AckEnqueued:
+		if gw.paymentQueue.TryEnqueue(req) {
+			return ackQueued
+		}
+		return ackQueueFull
+	case config.AckSync:
+		// O pagamento segue mesmo se o cliente desistir da resposta
+		switch gw.processPaymentRecovered(context.WithoutCancel(ctx), req) {
+		case sendSucceeded:
+			return ackProcessed
+		case sendShed, sendUnknown, sendDeferred:
+			return ackQueued
+		default:
+			return ackFailed
+		}
+	}
+
+	if forwarded {
+		gw.paymentQueue.Enqueue(req)
+	} else {
+		gw.submitPayment(req)
+	}
+	return ackReceived
+}
+
+// receivePayment registra a chegada de um pagamento válido, definindo o
+// requestedAt no modo "ingestion".
+func (gw *gateway) receivePayment(req *PaymentRequest) {
+	if gw.currentConfig().RequestedAt == config.RequestedAtIngestion && req.RequestedAt.IsZero() {
+		req.RequestedAt = gw.newRequestedAt()
+	}
+	gw.publishEvent(PaymentReceived, *req, "")
+}
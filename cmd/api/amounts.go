@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"rinha-backend-2025/internal/storage"
+)
+
+// Distribuição dos valores (GET /admin/stats/amounts): o histograma de cada
+// processor nos limites de STORAGE_AMOUNT_BOUNDS e os STORAGE_TOP_AMOUNTS maiores
+// pagamentos, com requestedAt no período. No backend redis, os dois são mantidos
+// na gravação; nos demais, calculados da exportação do período.
+
+// AmountStatsResponse é a resposta de GET /admin/stats/amounts.
+type AmountStatsResponse struct {
+	From       *time.Time                         `json:"from,omitempty"`
+	To         time.Time                          `json:"to"`
+	Processors map[string]AmountHistogramResponse `json:"processors"`
+	// Do maior para o menor
+	Largest []LargestPayment `json:"largest"`
+}
+
+// AmountHistogramResponse é o histograma de um processor, não cumulativo.
+type AmountHistogramResponse struct {
+	TotalRequests int            `json:"totalRequests"`
+	TotalAmount   float64        `json:"totalAmount"`
+	Buckets       []AmountBucket `json:"buckets"`
+}
+
+// AmountBucket conta os pagamentos com valor até Le e acima do limite anterior.
+type AmountBucket struct {
+	// Limite superior, ou "+Inf" no último
+	Le          string  `json:"le"`
+	Requests    int     `json:"requests"`
+	TotalAmount float64 `json:"totalAmount"`
+}
+
+type LargestPayment struct {
+	CorrelationID string    `json:"correlationId"`
+	Amount        float64   `json:"amount"`
+	Processor     string    `json:"processor"`
+	RequestedAt   time.Time `json:"requestedAt"`
+}
+
+// amountStats consulta o storage; top limita a lista dos maiores, até
+// STORAGE_TOP_AMOUNTS.
+func (gw *gateway) amountStats(ctx context.Context, from, to time.Time, top int) (AmountStatsResponse, error) {
+	cfg := gw.currentConfig().Storage
+	opts := storage.AmountOptions{Bounds: cfg.AmountBounds, Top: min(top, cfg.TopAmounts)}
+	stats, err := storage.QueryAmounts(ctx, gw.store, from, to, opts)
+	if err != nil {
+		return AmountStatsResponse{}, err
+	}
+
+	response := AmountStatsResponse{
+		To:         to,
+		Processors: make(map[string]AmountHistogramResponse, len(gw.processorNames)),
+		Largest:    make([]LargestPayment, len(stats.Largest)),
+	}
+	if !from.IsZero() {
+		response.From = &from
+	}
+	for _, name := range gw.processorNames {
+		histogram := stats.Processors[name]
+		buckets := make([]AmountBucket, len(opts.Bounds)+1)
+		var requests int
+		var amount float64
+		for i := range buckets {
+			buckets[i].Le = "+Inf"
+			if i < len(opts.Bounds) {
+				buckets[i].Le = strconv.FormatFloat(opts.Bounds[i], 'f', -1, 64)
+			}
+			if i < len(histogram.Counts) {
+				buckets[i].Requests = histogram.Counts[i]
+				buckets[i].TotalAmount = math.Round(histogram.Amounts[i]*100) / 100
+				requests += histogram.Counts[i]
+				amount += histogram.Amounts[i]
+			}
+		}
+		response.Processors[name] = AmountHistogramResponse{
+			TotalRequests: requests,
+			TotalAmount:   math.Round(amount*100) / 100,
+			Buckets:       buckets,
+		}
+	}
+	for i, record := range stats.Largest {
+		response.Largest[i] = LargestPayment{
+			CorrelationID: record.CorrelationID,
+			Amount:        record.Amount,
+			Processor:     record.Processor,
+			RequestedAt:   record.RequestedAt,
+		}
+	}
+	return response, nil
+}
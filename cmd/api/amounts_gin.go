@@ -0,0 +1,47 @@
+//go:build !minimal
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"rinha-backend-2025/internal/storage"
+)
+
+func (gw *gateway) handleAdminAmountStats(c *gin.Context) {
+	from, to, err := parseSummaryRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, errMessage(err))
+		return
+	}
+	if to.IsZero() {
+		to = gw.appClock.Now().UTC()
+	}
+	top := gw.currentConfig().Storage.TopAmounts
+	if v := c.Query("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidTop))
+			return
+		}
+		top = n
+	}
+
+	// Descarregar os pagamentos pendentes desta instância antes de ler
+	gw.flushCounters()
+	response, err := gw.amountStats(c.Request.Context(), from, to, top)
+	if errors.Is(err, storage.ErrNoAmountStats) {
+		respondError(c, http.StatusNotImplemented, errCodeNotImplemented, msg(msgNoAmountStats))
+		return
+	}
+	if err != nil {
+		gw.logf(c.Request.Context(), "Erro ao consultar os valores por período: %v", err)
+		respondError(c, http.StatusInternalServerError, errCodeInternal, msg(msgAmountStatsFailed))
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/config"
+)
+
+const auditStreamKey = "payments:audit"
+
+// Entradas lidas por chamada ao percorrer o stream na consulta
+const auditScanPage = 1000
+
+// Eventos do histórico de um pagamento
+const (
+	auditReceived   = "received"
+	auditProcessing = "processing"
+	auditAttempt    = "attempt"
+	auditShed       = "requeued"
+	auditSucceeded  = "succeeded"
+	auditDLQ        = "dlq"
+	auditReconciled = "reconciled"
+	auditVerified   = "verified"
+	auditUnknown    = "unknown"
+	auditCancelled  = "cancelled"
+)
+
+// AuditEntry é uma transição de estado de um pagamento.
+type AuditEntry struct {
+	ID            string    `json:"id,omitempty"`
+	CorrelationID string    `json:"correlationId"`
+	Event         string    `json:"event"`
+	Attempt       int       `json:"attempt,omitempty"`
+	Processor     string    `json:"processor,omitempty"`
+	Status        int       `json:"status,omitempty"`
+	Amount        float64   `json:"amount,omitempty"`
+	At            time.Time `json:"at"`
+	// requestedAt enviado na tentativa, em RFC 3339
+	RequestedAt string `json:"requestedAt,omitempty"`
+	// Instância que gravou a entrada (INSTANCE_ID)
+	Instance string `json:"instance,omitempty"`
+}
+
+func (gw *gateway) registerAudit() {
+	gw.onPaymentEvent("audit", func(e BusEvent) {
+		if !gw.auditEnabled {
+			return
+		}
+		entry := AuditEntry{CorrelationID: e.Payment.CorrelationID, Processor: e.Processor, At: e.At}
+		switch e.Kind {
+		case PaymentReceived:
+			entry.Event, entry.Amount = auditReceived, e.Payment.Amount
+		case PaymentSettled:
+			entry.Event = auditSucceeded
+		case PaymentFailed:
+			entry.Event = auditDLQ
+		default:
+			// As tentativas são gravadas uma a uma em sendToProcessor
+			return
+		}
+		gw.recordAudit(entry)
+	})
+}
+
+// auditState é o estado da auditoria
+type auditState struct {
+	auditEnabled bool
+	auditMaxLen  int64
+	// AUDIT_TTL: o stream expira sem entradas novas; 0 não expira
+	auditTTL time.Duration
+
+	pendingAudit    []AuditEntry
+	pendingAuditMux sync.Mutex
+	auditDropped    atomic.Int64
+}
+
+// startAuditLog grava as transições em lotes no stream do Redis, limitado a
+// cerca de MaxLen entradas. É best-effort: sem Redis as entradas são descartadas.
+func (gw *gateway) startAuditLog(cfg config.AuditConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	gw.auditEnabled = true
+	gw.auditMaxLen = cfg.MaxLen
+	gw.auditTTL = cfg.TTL.Std()
+
+	gw.registerMetric(metric{
+		Name: "audit_dropped_total",
+		Help: "Entradas de auditoria descartadas (Redis indisponível ou acúmulo acima do limite).",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.auditDropped.Load())}}
+		},
+	})
+
+	go func() {
+		ticker := time.NewTicker(cfg.FlushInterval.Std())
+		defer ticker.Stop()
+
+		for range ticker.C {
+			gw.flushAudit()
+		}
+	}()
+	log.Printf("Auditoria de pagamentos ativa em %s (até ~%d entradas)", gw.keys.Key(auditStreamKey), cfg.MaxLen)
+}
+
+func (gw *gateway) recordAudit(entry AuditEntry) {
+	if !gw.auditEnabled || gw.loadShed(shedAudit) {
+		return
+	}
+	// Entradas vindas do barramento trazem o instante da publicação
+	if entry.At.IsZero() {
+		entry.At = gw.appClock.Now().UTC()
+	}
+	entry.Instance = gw.instanceID
+
+	gw.pendingAuditMux.Lock()
+	defer gw.pendingAuditMux.Unlock()
+	// O stream não guarda mais que isso; acumular além só atrasaria o descarte
+	if int64(len(gw.pendingAudit)) >= gw.auditMaxLen {
+		gw.auditDropped.Add(1)
+		return
+	}
+	gw.pendingAudit = append(gw.pendingAudit, entry)
+}
+
+func (gw *gateway) flushAudit() {
+	gw.pendingAuditMux.Lock()
+	entries := gw.pendingAudit
+	gw.pendingAudit = nil
+	gw.pendingAuditMux.Unlock()
+
+	if len(entries) == 0 {
+		return
+	}
+	client := gw.currentRedis()
+	if client == nil {
+		gw.auditDropped.Add(int64(len(entries)))
+		return
+	}
+
+	ctx := context.Background()
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, entry := range entries {
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: gw.keys.Key(auditStreamKey),
+				MaxLen: gw.auditMaxLen,
+				Approx: true,
+				Values: auditValues(entry),
+			})
+		}
+		if gw.auditTTL > 0 {
+			pipe.Expire(ctx, gw.keys.Key(auditStreamKey), gw.auditTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		gw.auditDropped.Add(int64(len(entries)))
+		log.Printf("Erro ao gravar auditoria: %v", err)
+	}
+}
+
+func auditValues(entry AuditEntry) []interface{} {
+	values := []interface{}{
+		"correlationId", entry.CorrelationID,
+		"event", entry.Event,
+		"at", entry.At.Format(time.RFC3339Nano),
+	}
+	if entry.Attempt > 0 {
+		values = append(values, "attempt", entry.Attempt)
+	}
+	if entry.Processor != "" {
+		values = append(values, "processor", entry.Processor)
+	}
+	if entry.Status != 0 {
+		values = append(values, "status", entry.Status)
+	}
+	if entry.Amount != 0 {
+		values = append(values, "amount", strconv.FormatFloat(entry.Amount, 'f', -1, 64))
+	}
+	if entry.RequestedAt != "" {
+		values = append(values, "requestedAt", entry.RequestedAt)
+	}
+	if entry.Instance != "" {
+		values = append(values, "instance", entry.Instance)
+	}
+	return values
+}
+
+func parseAuditMessage(msg redis.XMessage) AuditEntry {
+	str := func(key string) string {
+		s, _ := msg.Values[key].(string)
+		return s
+	}
+	entry := AuditEntry{
+		ID:            msg.ID,
+		CorrelationID: str("correlationId"),
+		Event:         str("event"),
+		Processor:     str("processor"),
+		RequestedAt:   str("requestedAt"),
+		Instance:      str("instance"),
+	}
+	entry.Attempt, _ = strconv.Atoi(str("attempt"))
+	entry.Status, _ = strconv.Atoi(str("status"))
+	entry.Amount, _ = strconv.ParseFloat(str("amount"), 64)
+	entry.At, _ = time.Parse(time.RFC3339Nano, str("at"))
+	return entry
+}
+
+// auditHistory percorre o stream inteiro em páginas: a consulta é rara (depuração
+// depois do teste), então não vale manter um índice por correlationId.
+func (gw *gateway) auditHistory(ctx context.Context, client redis.UniversalClient, correlationID string) ([]AuditEntry, error) {
+	history := []AuditEntry{}
+	start := "-"
+	for {
+		msgs, err := client.XRangeN(ctx, gw.keys.Key(auditStreamKey), start, "+", auditScanPage).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			if id, _ := msg.Values["correlationId"].(string); id == correlationID {
+				history = append(history, parseAuditMessage(msg))
+			}
+		}
+		if len(msgs) < auditScanPage {
+			// A ordem do stream é a de gravação: as entradas do barramento podem
+			// chegar depois das tentativas gravadas direto
+			sort.SliceStable(history, func(i, j int) bool { return history[i].At.Before(history[j].At) })
+			return history, nil
+		}
+		// Intervalo exclusivo a partir da última entrada lida
+		start = "(" + msgs[len(msgs)-1].ID
+	}
+}
+
+// purgeAudit apaga o histórico junto com os pagamentos.
+func (gw *gateway) purgeAudit(ctx context.Context) error {
+	gw.pendingAuditMux.Lock()
+	gw.pendingAudit = nil
+	gw.pendingAuditMux.Unlock()
+
+	if client := gw.currentRedis(); client != nil {
+		return client.Del(ctx, gw.keys.Key(auditStreamKey)).Err()
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (gw *gateway) handleAdminAudit(c *gin.Context) {
+	if !gw.auditEnabled {
+		respondError(c, http.StatusNotFound, errCodeNotFound, msg(msgAuditDisabled))
+		return
+	}
+	client := gw.currentRedis()
+	if client == nil {
+		respondError(c, http.StatusServiceUnavailable, errCodeUnavailable, msg(msgRedisUnavailable))
+		return
+	}
+
+	correlationID := c.Param("correlationId")
+	// Incluir o que ainda não foi gravado
+	gw.flushAudit()
+	history, err := gw.auditHistory(c.Request.Context(), client, correlationID)
+	if err != nil {
+		gw.logf(c.Request.Context(), "Erro ao consultar a auditoria de %s: %v", correlationID, err)
+		respondError(c, http.StatusInternalServerError, errCodeInternal, msg(msgAuditFailed))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"correlationId": correlationID, "events": history})
+}
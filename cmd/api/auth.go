@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync/atomic"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// authState é o estado da autenticação
+type authState struct {
+	// Recusas por falta de chave ou origem fora da allowlist
+	authRejected atomic.Int64
+}
+
+func (gw *gateway) registerAuth() {
+	gw.registerMetric(metric{
+		Name: "auth_rejected_total",
+		Help: "Requisições às rotas administrativas recusadas pela autenticação.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.authRejected.Load())}}
+		},
+	})
+}
+
+// routeGuard é a autenticação de um grupo de rotas: chave de API e/ou allowlist.
+type routeGuard struct {
+	group  string
+	header string
+	// SHA-256 da chave, para comparar em tempo constante sem vazar o tamanho
+	keyDigest []byte
+	allow     []netip.Prefix
+
+	gw *gateway
+}
+
+// newRouteGuard retorna nil quando o grupo não exige chave nem origem.
+func (gw *gateway) newRouteGuard(group, header string, cfg config.AuthGroupConfig) *routeGuard {
+	if cfg.APIKey == "" && len(cfg.AllowIPs) == 0 {
+		log.Printf("Aviso: rotas %s sem autenticação (defina a chave de API ou a allowlist)", group)
+		return nil
+	}
+
+	// Já validada em config.Validate
+	allow, _ := config.ParseAllowIPs(cfg.AllowIPs)
+	guard := &routeGuard{group: group, header: header, allow: allow, gw: gw}
+	if cfg.APIKey != "" {
+		digest := sha256.Sum256([]byte(cfg.APIKey))
+		guard.keyDigest = digest[:]
+	}
+	return guard
+}
+
+// check retorna o status da recusa ou 0 quando a requisição pode seguir. A origem
+// é o endereço da conexão, nunca X-Forwarded-For, que o cliente controla.
+func (g *routeGuard) check(r *http.Request) int {
+	if len(g.allow) > 0 && !g.allowed(r.RemoteAddr) {
+		return http.StatusForbidden
+	}
+	if g.keyDigest != nil {
+		digest := sha256.Sum256([]byte(r.Header.Get(g.header)))
+		if subtle.ConstantTimeCompare(digest[:], g.keyDigest) != 1 {
+			return http.StatusUnauthorized
+		}
+	}
+	return 0
+}
+
+func (g *routeGuard) allowed(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	// Conexões pelo socket unix não têm IP e só passam sem allowlist
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range g.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// reject registra a recusa e retorna o código e a mensagem do erro.
+func (g *routeGuard) reject(status int, r *http.Request) (string, localizedMessage) {
+	g.gw.authRejected.Add(1)
+	g.gw.logf(r.Context(), "Acesso negado às rotas %s: %s %s de %s", g.group, r.Method, r.URL.Path, r.RemoteAddr)
+	if status == http.StatusForbidden {
+		return errCodeForbidden, msg(msgOriginNotAllowed)
+	}
+	return errCodeUnauthorized, msg(msgInvalidAPIKey)
+}
+
+// wrap protege um http.Handler fora do router, como a porta de diagnóstico.
+func (g *routeGuard) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status := g.check(r); status != 0 {
+			code, message := g.reject(status, r)
+			writeHTTPError(w, r, status, code, message)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
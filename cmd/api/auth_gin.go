@@ -0,0 +1,25 @@
+//go:build !minimal
+
+package main
+
+import "github.com/gin-gonic/gin"
+
+// middleware protege um grupo do router principal.
+func (g *routeGuard) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if status := g.check(c.Request); status != 0 {
+			code, message := g.reject(status, c.Request)
+			respondError(c, status, code, message)
+			return
+		}
+		c.Next()
+	}
+}
+
+// authMiddleware retorna os handlers de autenticação do grupo, vazio quando aberto.
+func authMiddleware(guard *routeGuard) []gin.HandlerFunc {
+	if guard == nil {
+		return nil
+	}
+	return []gin.HandlerFunc{guard.middleware()}
+}
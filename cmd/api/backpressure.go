@@ -0,0 +1,149 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Sinal de backpressure nas respostas de POST /payments e em /healthz:
+// X-Queue-Depth é o que a instância tem em memória para enviar, e
+// X-Estimated-Delay-Ms quanto um pagamento recebido agora deve esperar, pela
+// vazão recente dos workers. Clientes e o load balancer podem espaçar os envios
+// antes de a fila chegar a MAX_QUEUE_DEPTH. Sem vazão medida e com a fila não
+// vazia, a estimativa é omitida.
+const (
+	queueDepthHeader     = "X-Queue-Depth"
+	estimatedDelayHeader = "X-Estimated-Delay-Ms"
+)
+
+// Intervalo e peso da amostra na média móvel da vazão dos workers
+const (
+	throughputSampleInterval = time.Second
+	throughputSmoothing      = 0.3
+)
+
+// backpressureState é o estado de backpressure
+type backpressureState struct {
+	// Pagamentos recusados por MAX_QUEUE_DEPTH
+	queueShed atomic.Int64
+	// Pagamentos retirados da fila por segundo, em média móvel; bits do float64
+	queueThroughput atomic.Uint64
+}
+
+// BackpressureStatus são os números dos headers de backpressure, em /healthz.
+type BackpressureStatus struct {
+	QueueDepth int `json:"queueDepth"`
+	// Pagamentos retirados da fila por segundo, em média móvel
+	Throughput float64 `json:"throughput"`
+	// Ausente sem vazão medida com a fila não vazia
+	EstimatedDelayMs *int64 `json:"estimatedDelayMs,omitempty"`
+}
+
+func (gw *gateway) registerBackpressure() {
+	gw.registerMetric(metric{
+		Name: "queue_overflow",
+		Help: "Pagamentos processados fora do pool porque a fila estava cheia.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			if gw.paymentQueue == nil {
+				return nil
+			}
+			return []metricSample{{Value: float64(gw.paymentQueue.Status().Overflow)}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "queue_depth_high_watermark",
+		Help: "Maior quantidade de pagamentos em memória (fila e fora do pool) desde a subida.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			if gw.paymentQueue == nil {
+				return nil
+			}
+			return []metricSample{{Value: float64(gw.paymentQueue.Status().HighWatermark)}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "queue_shed_total",
+		Help: "Pagamentos recusados com 503 por MAX_QUEUE_DEPTH.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.queueShed.Load())}}
+		},
+	})
+}
+
+// queueSaturated indica que aceitar mais n pagamentos passaria de MAX_QUEUE_DEPTH
+// ou que a memória está acima da marca alta (ver memory.go). A recusa é
+// antecipada: o cliente recebe 503 em vez de a instância acumular goroutines
+// fora do pool até estourar a memória.
+func (gw *gateway) queueSaturated(n int) bool {
+	if gw.memoryShedding(n) {
+		return true
+	}
+	limit := gw.currentConfig().Workers.MaxQueueDepth
+	if limit == 0 || gw.paymentQueue.Depth()+n <= limit {
+		return false
+	}
+	gw.queueShed.Add(int64(n))
+	return true
+}
+
+// startThroughputSampler mede a vazão dos workers a cada segundo, pelos
+// pagamentos retirados das faixas.
+func (gw *gateway) startThroughputSampler() {
+	go func() {
+		ticker := time.NewTicker(throughputSampleInterval)
+		defer ticker.Stop()
+
+		last, lastAt := gw.queueTaken(), gw.appClock.Now()
+		for range ticker.C {
+			taken, now := gw.queueTaken(), gw.appClock.Now()
+			elapsed := now.Sub(lastAt).Seconds()
+			if elapsed <= 0 {
+				continue
+			}
+			sample := float64(taken-last) / elapsed
+			rate := math.Float64frombits(gw.queueThroughput.Load())
+			gw.queueThroughput.Store(math.Float64bits(rate + throughputSmoothing*(sample-rate)))
+			last, lastAt = taken, now
+		}
+	}()
+}
+
+func (gw *gateway) queueTaken() int64 {
+	var taken int64
+	for _, l := range gw.paymentQueue.Status().Lanes {
+		taken += l.Taken
+	}
+	return taken
+}
+
+// backpressureStatus calcula a profundidade e a espera estimada de um
+// pagamento novo.
+func (gw *gateway) backpressureStatus() BackpressureStatus {
+	status := BackpressureStatus{
+		QueueDepth: gw.paymentQueue.Depth(),
+		Throughput: math.Float64frombits(gw.queueThroughput.Load()),
+	}
+	// Abaixo disso a média só está decaindo depois de a fila esvaziar
+	if status.QueueDepth == 0 || status.Throughput >= 0.01 {
+		var delayMs int64
+		if status.QueueDepth > 0 {
+			delayMs = int64(math.Ceil(float64(status.QueueDepth) / status.Throughput * 1000))
+		}
+		status.EstimatedDelayMs = &delayMs
+	}
+	return status
+}
+
+// setBackpressureHeaders escreve X-Queue-Depth e X-Estimated-Delay-Ms.
+func (gw *gateway) setBackpressureHeaders(header http.Header) {
+	status := gw.backpressureStatus()
+	header[queueDepthHeader] = []string{strconv.Itoa(status.QueueDepth)}
+	if status.EstimatedDelayMs != nil {
+		header[estimatedDelayHeader] = []string{strconv.FormatInt(*status.EstimatedDelayMs, 10)}
+	}
+}
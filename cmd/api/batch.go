@@ -0,0 +1,27 @@
+package main
+
+// Desfechos de cada item de POST /payments/batch
+const (
+	batchAccepted = "accepted"
+	batchRejected = "rejected"
+)
+
+// BatchItemResult é o resultado de um item, na mesma posição do lote enviado.
+type BatchItemResult struct {
+	Index         int          `json:"index"`
+	CorrelationID string       `json:"correlationId,omitempty"`
+	Status        string       `json:"status"`
+	Code          string       `json:"code,omitempty"`
+	Error         string       `json:"error,omitempty"`
+	Details       []FieldError `json:"details,omitempty"`
+	// Valor com que o correlationId foi recebido antes, no code amount_conflict
+	OriginalAmount float64 `json:"originalAmount,omitempty"`
+	// Error no idioma da requisição (ver localizeBatch)
+	message localizedMessage
+}
+
+type BatchResponse struct {
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Results  []BatchItemResult `json:"results"`
+}
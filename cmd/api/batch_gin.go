@@ -0,0 +1,113 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	json "github.com/goccy/go-json"
+)
+
+// handlePaymentsBatch recebe uma lista de pagamentos, valida cada um como em
+// POST /payments e enfileira os válidos de uma vez: ou todos entram na fila ou
+// o lote inteiro é recusado com 503, e o cliente pode reenviá-lo sem duplicar.
+func (gw *gateway) handlePaymentsBatch(c *gin.Context) {
+	if !isJSONContentType(c.GetHeader("Content-Type")) {
+		respondError(c, http.StatusUnsupportedMediaType, errCodeUnsupportedMediaType, msg(msgJSONContentType))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, gw.currentConfig().Validation.MaxBatchBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(c, http.StatusRequestEntityTooLarge, errCodeBodyTooLarge, msg(msgBodyTooLarge, tooLarge.Limit))
+			return
+		}
+		respondError(c, http.StatusBadRequest, errCodeInvalidBody, msg(msgReadBody))
+		return
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		respondError(c, http.StatusBadRequest, errCodeInvalidBody, msg(msgBatchNotList))
+		return
+	}
+	maxItems := gw.currentConfig().Validation.MaxBatchItems
+	if len(items) == 0 || len(items) > maxItems {
+		respondError(c, http.StatusUnprocessableEntity, errCodeInvalidPayload, msg(msgBatchSize, maxItems))
+		return
+	}
+
+	response := BatchResponse{Results: make([]BatchItemResult, len(items))}
+	accepted := make([]PaymentRequest, 0, len(items))
+	seen := make(map[string]bool, len(items))
+
+	for i, item := range items {
+		result := &response.Results[i]
+		result.Index = i
+
+		req, err := gw.decodePaymentRequest(bytes.NewReader(item), gw.currentConfig().Validation.MaxAmount)
+		result.CorrelationID = req.CorrelationID
+		var validationErr *ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			result.Code, result.message = errCodeInvalidPayload, msg(msgInvalidPayload)
+			result.Details = validationErr.Fields
+		case err != nil:
+			result.Code, result.message = errCodeInvalidBody, errMessage(err)
+		case req.CallbackURL != "" && !gw.callbackAllowed(req.CallbackURL):
+			result.Code, result.message = errCodeCallbackNotAllowed, msg(msgCallbackNotAllowed)
+		case seen[req.CorrelationID]:
+			result.Code, result.message = errCodeDuplicate, msg(msgBatchDuplicate)
+		default:
+			if conflict, ok := gw.findAmountConflict(c.Request.Context(), req); ok {
+				result.Code, result.message = errCodeAmountConflict, msg(msgAmountConflict)
+				result.OriginalAmount = conflict.OriginalAmount
+			}
+		}
+		if result.Code != "" {
+			result.Error = result.message.text(localePtBR)
+			result.Status = batchRejected
+			response.Rejected++
+			continue
+		}
+
+		req.RequestID = c.GetString(requestIDKey)
+		seen[req.CorrelationID] = true
+		result.Status = batchAccepted
+		response.Accepted++
+		accepted = append(accepted, req)
+	}
+
+	if len(accepted) == 0 {
+		// Os motivos de cada item seguem nos detalhes
+		respondErrorDetails(c, http.StatusUnprocessableEntity, errCodeBatchRejected, msg(msgBatchRejected), response)
+		return
+	}
+
+	if gw.queueSaturated(len(accepted)) {
+		gw.setBackpressureHeaders(c.Writer.Header())
+		c.Header("Retry-After", "1")
+		respondError(c, http.StatusServiceUnavailable, errCodeQueueFull, msg(msgQueueFull))
+		return
+	}
+	for i := range accepted {
+		gw.receivePayment(&accepted[i])
+	}
+	enqueued := gw.paymentQueue.TryEnqueueAll(accepted)
+	gw.setBackpressureHeaders(c.Writer.Header())
+	if !enqueued {
+		c.Header("Retry-After", "1")
+		respondError(c, http.StatusServiceUnavailable, errCodeQueueFull, msg(msgQueueFull))
+		return
+	}
+
+	locale := requestLocale(c.Request)
+	setContentLanguage(c.Writer.Header(), locale)
+	c.JSON(http.StatusAccepted, localizeBatch(locale, response))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"rinha-backend-2025/internal/queue"
+)
+
+func (gw *gateway) registerCancel() {
+	gw.registerMetric(metric{
+		Name: "payments_cancelled_total",
+		Help: "Pagamentos cancelados (DELETE /payments/:correlationId) antes do envio.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			if gw.paymentQueue == nil {
+				return nil
+			}
+			return []metricSample{{Value: float64(gw.paymentQueue.Status().Cancelled)}}
+		},
+	})
+}
+
+// cancelOnPeers repassa o cancelamento às outras instâncias e retorna o primeiro
+// desfecho diferente de NotFound.
+func (gw *gateway) cancelOnPeers(ctx context.Context, correlationID string) queue.CancelResult {
+	for _, peer := range gw.peerURLs {
+		req, err := http.NewRequestWithContext(ctx, http.MethodDelete, peer+"/payments/"+correlationID, nil)
+		if err != nil {
+			continue
+		}
+		req.Header.Set(peerForwardedHeader, "1")
+		if id := requestIDFrom(ctx); id != "" {
+			req.Header.Set(requestIDHeader, id)
+		}
+
+		resp, err := gw.peerClient.Do(req)
+		if err != nil {
+			gw.logf(ctx, "Erro ao repassar cancelamento de %s para %s: %v", correlationID, peer, err)
+			continue
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return queue.Cancelled
+		case http.StatusConflict:
+			return queue.Dispatched
+		}
+	}
+	return queue.NotFound
+}
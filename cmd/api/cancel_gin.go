@@ -0,0 +1,41 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rinha-backend-2025/internal/ids"
+	"rinha-backend-2025/internal/queue"
+)
+
+// handleCancelPayment retira da fila um pagamento que ainda não foi enviado. Sem
+// o pagamento na fila local, as outras instâncias (PEER_URLS) são consultadas,
+// já que o balanceador pode ter mandado o POST para qualquer uma delas.
+func (gw *gateway) handleCancelPayment(c *gin.Context) {
+	correlationID := c.Param("correlationId")
+	if !ids.Valid(correlationID) {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidCorrelationID))
+		return
+	}
+
+	result := gw.paymentQueue.Cancel(correlationID)
+	if result == queue.NotFound && c.GetHeader(peerForwardedHeader) == "" {
+		result = gw.cancelOnPeers(c.Request.Context(), correlationID)
+	}
+
+	switch result {
+	case queue.Cancelled:
+		gw.recordAudit(AuditEntry{CorrelationID: correlationID, Event: auditCancelled})
+		gw.indexPaymentStatus(correlationID, "", time.Time{})
+		gw.publishPaymentEvent(eventCancelled, correlationID, 0, "")
+		writeStatic(c.Writer, http.StatusOK, paymentCancelledResponse)
+	case queue.Dispatched:
+		respondError(c, http.StatusConflict, errCodeAlreadyDispatched, msg(msgAlreadyDispatched))
+	default:
+		respondError(c, http.StatusNotFound, errCodeNotFound, msg(msgNotInQueue))
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/config"
+	pp "rinha-backend-2025/internal/processor"
+)
+
+// Erro das falhas injetadas no Redis
+var errChaos = errors.New("chaos: falha injetada")
+
+// chaosState é o estado da injeção de falhas; nil quando desativada
+type chaosState struct {
+	processorChaos *chaosInjector
+	redisChaos     *chaosInjector
+}
+
+// chaosInjector sorteia, a cada chamada, um atraso extra e no máximo uma falha
+// (erro imediato ou espera até o timeout), conforme as probabilidades configuradas.
+type chaosInjector struct {
+	name    string
+	faults  config.ChaosFaults
+	timeout time.Duration
+	fail    error
+	clock   clock.Clock
+
+	injected atomic.Int64
+}
+
+func (gw *gateway) newChaosInjector(name string, faults config.ChaosFaults, timeout time.Duration, fail error) *chaosInjector {
+	if faults.DelayRate == 0 && faults.FailRate == 0 && faults.TimeoutRate == 0 {
+		return nil
+	}
+	log.Printf("CHAOS: %s com atraso %.0f%% (%v), falha %.0f%%, timeout %.0f%%", name,
+		faults.DelayRate*100, faults.Delay.Std(), faults.FailRate*100, faults.TimeoutRate*100)
+	return &chaosInjector{name: name, faults: faults, timeout: timeout, fail: fail, clock: gw.appClock}
+}
+
+func (gw *gateway) initChaos(cfg config.ChaosConfig, attemptTimeout time.Duration, redisCfg config.RedisConfig) {
+	gw.processorChaos = gw.newChaosInjector("processors", cfg.Processor, attemptTimeout,
+		&pp.StatusError{Op: "chaos", Code: 500})
+	gw.redisChaos = gw.newChaosInjector("redis", cfg.Redis, redisCfg.ReadTimeout.Std(), errChaos)
+
+	if gw.processorChaos == nil && gw.redisChaos == nil {
+		return
+	}
+	gw.registerMetric(metric{
+		Name: "chaos_injected_total",
+		Help: "Falhas e atrasos injetados pelo CHAOS_*.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			var samples []metricSample
+			for _, c := range []*chaosInjector{gw.processorChaos, gw.redisChaos} {
+				if c != nil {
+					samples = append(samples, metricSample{
+						Labels: map[string]string{"target": c.name},
+						Value:  float64(c.injected.Load()),
+					})
+				}
+			}
+			return samples
+		},
+	})
+}
+
+// inject aplica o sorteio antes da chamada real; um erro significa que ela não
+// deve acontecer.
+func (c *chaosInjector) inject(ctx context.Context) error {
+	if rand.Float64() < c.faults.DelayRate {
+		c.injected.Add(1)
+		if err := sleepContext(ctx, c.clock, c.faults.Delay.Std()); err != nil {
+			return err
+		}
+	}
+
+	roll := rand.Float64()
+	switch {
+	case roll < c.faults.FailRate:
+		c.injected.Add(1)
+		return c.fail
+	case roll < c.faults.FailRate+c.faults.TimeoutRate:
+		c.injected.Add(1)
+		if err := sleepContext(ctx, c.clock, c.timeout); err != nil {
+			return err
+		}
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+func sleepContext(ctx context.Context, clk clock.Clock, d time.Duration) error {
+	timer := clk.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// chaosClient injeta falhas antes de cada chamada ao processor.
+type chaosClient struct {
+	next  pp.ProcessorClient
+	chaos *chaosInjector
+}
+
+func (c chaosClient) SubmitPayment(ctx context.Context, payment pp.Payment) error {
+	if err := c.chaos.inject(ctx); err != nil {
+		return err
+	}
+	return c.next.SubmitPayment(ctx, payment)
+}
+
+func (c chaosClient) ServiceHealth(ctx context.Context) (pp.Health, error) {
+	if err := c.chaos.inject(ctx); err != nil {
+		return pp.Health{}, err
+	}
+	return c.next.ServiceHealth(ctx)
+}
+
+func (c chaosClient) GetPayment(ctx context.Context, correlationID string) (pp.Payment, error) {
+	if err := c.chaos.inject(ctx); err != nil {
+		return pp.Payment{}, err
+	}
+	return c.next.GetPayment(ctx, correlationID)
+}
+
+func (c chaosClient) PaymentsSummary(ctx context.Context, from, to time.Time) (pp.AdminSummary, error) {
+	if err := c.chaos.inject(ctx); err != nil {
+		return pp.AdminSummary{}, err
+	}
+	return c.next.PaymentsSummary(ctx, from, to)
+}
+
+// wrapProcessorChaos envolve os clientes já criados quando CHAOS_PROCESSOR_* está ativo.
+func (gw *gateway) wrapProcessorChaos() {
+	if gw.processorChaos == nil {
+		return
+	}
+	for name, client := range gw.processorClients {
+		gw.processorClients[name] = chaosClient{next: client, chaos: gw.processorChaos}
+	}
+}
+
+// Os hooks do go-redis abortam o comando quando retornam erro antes de next
+func (c *chaosInjector) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (c *chaosInjector) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := c.inject(ctx); err != nil {
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (c *chaosInjector) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := c.inject(ctx); err != nil {
+			return err
+		}
+		return next(ctx, cmds)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Serialização manual das respostas do caminho quente. O resumo tem formato fixo,
+// então montá-lo com append evita a reflexão do encoding/json. O payload enviado
+// aos processors é montado da mesma forma no pacote processor.
+
+const jsonContentType = "application/json; charset=utf-8"
+
+// Valor pronto do header, compartilhado entre as respostas; nunca é alterado
+var jsonContentTypeHeader = []string{jsonContentType}
+
+// staticResponse é um corpo fixo serializado uma única vez, com o Content-Length
+// já formatado.
+type staticResponse struct {
+	body          []byte
+	contentLength []string
+}
+
+func newStaticResponse(body string) staticResponse {
+	return staticResponse{body: []byte(body), contentLength: []string{strconv.Itoa(len(body))}}
+}
+
+// Respostas de sucesso mais comuns, escritas sem gin.H nem serialização
+var (
+	paymentReceivedResponse  = newStaticResponse(`{"message":"payment received"}`)
+	paymentQueuedResponse    = newStaticResponse(`{"message":"payment queued"}`)
+	paymentProcessedResponse = newStaticResponse(`{"message":"payment processed"}`)
+	paymentsPurgedResponse   = newStaticResponse(`{"message":"payments purged"}`)
+	paymentCancelledResponse = newStaticResponse(`{"message":"payment cancelled"}`)
+)
+
+// writeJSON escreve um corpo já serializado sem as alocações do c.Data para o header.
+func writeJSON(w http.ResponseWriter, status int, body []byte) {
+	header := w.Header()
+	header["Content-Type"] = jsonContentTypeHeader
+	header["Content-Length"] = []string{strconv.Itoa(len(body))}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// writeStatic é o writeJSON das respostas fixas: nenhum dos headers aloca.
+func writeStatic(w http.ResponseWriter, status int, r staticResponse) {
+	header := w.Header()
+	header["Content-Type"] = jsonContentTypeHeader
+	header["Content-Length"] = r.contentLength
+	w.WriteHeader(status)
+	w.Write(r.body)
+}
+
+// Buffers reaproveitados entre requisições; os que cresceram demais são descartados
+// para o pool não segurar memória por causa de um corpo atípico.
+const maxPooledBuffer = 4 << 10
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 512)
+		return &buf
+	},
+}
+
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(buf *[]byte) {
+	if cap(*buf) > maxPooledBuffer {
+		return
+	}
+	*buf = (*buf)[:0]
+	bufferPool.Put(buf)
+}
+
+// readInto lê r até o fim acrescentando a buf, como io.ReadAll, mas sobre um
+// buffer do pool.
+func readInto(buf []byte, r io.Reader) ([]byte, error) {
+	for {
+		if len(buf) == cap(buf) {
+			buf = append(buf, 0)[:len(buf)]
+		}
+		n, err := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err == io.EOF {
+			return buf, nil
+		}
+		if err != nil {
+			return buf, err
+		}
+	}
+}
+
+// appendJSON segue o formato do encoding/json para OutboxEntry, que é gravada a
+// cada pagamento.
+func (e OutboxEntry) appendJSON(buf []byte) []byte {
+	buf = append(buf, `{"correlationId":`...)
+	buf = appendJSONString(buf, e.CorrelationID)
+	buf = append(buf, `,"amount":`...)
+	buf = strconv.AppendFloat(buf, e.Amount, 'f', -1, 64)
+	if e.CallbackURL != "" {
+		buf = append(buf, `,"callbackUrl":`...)
+		buf = appendJSONString(buf, e.CallbackURL)
+	}
+	if e.Currency != "" {
+		buf = append(buf, `,"currency":`...)
+		buf = appendJSONString(buf, e.Currency)
+	}
+	buf = append(buf, `,"requestedAt":"`...)
+	buf = e.RequestedAt.AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, `","createdAt":"`...)
+	buf = e.CreatedAt.AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, '"')
+	if e.Unknown {
+		buf = append(buf, `,"unknown":true`...)
+	}
+	if len(e.Metadata) > 0 {
+		buf = append(buf, `,"metadata":`...)
+		buf = append(buf, e.Metadata...)
+	}
+	return append(buf, '}')
+}
+
+// appendJSON escreve os processors na ordem de orderedNames; com halfUp, meio
+// centavo exato arredonda para cima em vez de para o par (AMOUNT_ROUNDING).
+func (s PaymentSummaryResponse) appendJSON(buf []byte, halfUp bool) []byte {
+	buf = append(buf, '{')
+	for i, name := range s.orderedNames() {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendJSONString(buf, name)
+		buf = append(buf, ':')
+		buf = s[name].appendJSON(buf, halfUp)
+	}
+	return append(buf, '}')
+}
+
+// orderedNames retorna default e fallback primeiro e os demais processors em
+// ordem alfabética.
+func (s PaymentSummaryResponse) orderedNames() []string {
+	names := make([]string, 2, max(len(s), 2))
+	names[0], names[1] = "default", "fallback"
+	for name := range s {
+		if name != "default" && name != "fallback" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[2:])
+	return names
+}
+
+func (s ProcessorSummary) appendJSON(buf []byte, halfUp bool) []byte {
+	buf = append(buf, `{"totalRequests":`...)
+	buf = strconv.AppendInt(buf, int64(s.TotalRequests), 10)
+	buf = append(buf, `,"totalAmount":`...)
+	buf = appendAmount(buf, s.TotalAmount, halfUp)
+	return append(buf, '}')
+}
+
+// MarshalJSON mantém o mesmo formato quando o resumo passa pelo encoding/json,
+// com o arredondamento padrão (half-even).
+func (s ProcessorSummary) MarshalJSON() ([]byte, error) {
+	return s.appendJSON(nil, false), nil
+}
+
+// appendAmount escreve o valor sempre com 2 casas decimais. Somas de float64
+// acumulam erros como 19.899999999999999, que viram 19.90 aqui.
+func appendAmount(buf []byte, v float64, halfUp bool) []byte {
+	// Fora do alcance dos centavos em int64
+	if math.IsNaN(v) || math.IsInf(v, 0) || math.Abs(v) >= 1e15 {
+		return strconv.AppendFloat(buf, v, 'f', 2, 64)
+	}
+
+	cents := roundToCents(v, halfUp)
+	if cents < 0 {
+		buf = append(buf, '-')
+		cents = -cents
+	}
+	buf = strconv.AppendInt(buf, cents/100, 10)
+	buf = append(buf, '.', byte('0'+cents%100/10), byte('0'+cents%10))
+	return buf
+}
+
+// roundToCents arredonda a partir da menor representação decimal do float, e
+// não de v*100, para que 1.005 seja tratado como meio centavo exato.
+func roundToCents(v float64, halfUp bool) int64 {
+	var scratch [32]byte
+	digits := string(strconv.AppendFloat(scratch[:0], math.Abs(v), 'f', -1, 64))
+	intPart, frac, _ := strings.Cut(digits, ".")
+	frac += "000"
+
+	cents, _ := strconv.ParseInt(intPart+frac[:2], 10, 64)
+	rest := frac[2:]
+	tail := strings.TrimRight(rest[1:], "0") != ""
+	switch {
+	case rest[0] > '5', rest[0] == '5' && tail:
+		cents++
+	case rest[0] == '5':
+		// Meio centavo exato
+		if halfUp || cents%2 == 1 {
+			cents++
+		}
+	}
+
+	if v < 0 {
+		return -cents
+	}
+	return cents
+}
+
+// appendJSONString escreve s como string JSON; nomes de processors raramente
+// precisam de escape, então o caminho comum não aloca.
+func appendJSONString(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c == '"' || c == '\\' || c >= 0x80 {
+			quoted, _ := json.Marshal(s)
+			return append(buf, quoted...)
+		}
+	}
+	buf = append(buf, '"')
+	buf = append(buf, s...)
+	return append(buf, '"')
+}
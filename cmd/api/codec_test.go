@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOutboxEntryAppendJSONRoundTrip(t *testing.T) {
+	requestedAt := time.Date(2025, 7, 15, 12, 34, 56, 789000000, time.UTC)
+	entries := map[string]OutboxEntry{
+		"mínima": {
+			CorrelationID: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3",
+			Amount:        19.9,
+			RequestedAt:   requestedAt,
+			CreatedAt:     requestedAt.Add(time.Millisecond),
+		},
+		"completa": {
+			CorrelationID: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3",
+			Amount:        1234.56,
+			CallbackURL:   "http://hooks.local/pagamentos?x=\"1\"",
+			Currency:      "BRL",
+			RequestedAt:   requestedAt,
+			CreatedAt:     requestedAt.Add(time.Second),
+			Unknown:       true,
+			Metadata:      json.RawMessage(`{"pedido":"42","itens":[1,2],"cliente":{"vip":true}}`),
+		},
+		"só metadata": {
+			CorrelationID: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3",
+			Amount:        0.01,
+			RequestedAt:   requestedAt,
+			CreatedAt:     requestedAt,
+			Metadata:      json.RawMessage(`{"origem":"lote"}`),
+		},
+	}
+
+	for name, entry := range entries {
+		t.Run(name, func(t *testing.T) {
+			got := entry.appendJSON(nil)
+
+			want, err := json.Marshal(entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("appendJSON difere do encoding/json:\n got %s\nwant %s", got, want)
+			}
+
+			var decoded OutboxEntry
+			if err := json.Unmarshal(got, &decoded); err != nil {
+				t.Fatalf("appendJSON gerou JSON inválido %s: %v", got, err)
+			}
+			if !reflect.DeepEqual(decoded, entry) {
+				t.Errorf("ida e volta perdeu campos:\n got %+v\nwant %+v", decoded, entry)
+			}
+		})
+	}
+}
+
+func TestAppendAmountRounding(t *testing.T) {
+	tests := []struct {
+		value    float64
+		halfEven string
+		halfUp   string
+	}{
+		// Erro acumulado de soma: o texto mais curto já é 19.9
+		{19.899999999999999, "19.90", "19.90"},
+		{0.1 + 0.2, "0.30", "0.30"},
+		// O float64 de 1.005 fica abaixo do meio, mas o texto mais curto é 1.005
+		{1.005, "1.00", "1.01"},
+		{2.675, "2.68", "2.68"},
+		{0.125, "0.12", "0.13"},
+		{0.135, "0.14", "0.14"},
+		{0.005, "0.00", "0.01"},
+		{0.015, "0.02", "0.02"},
+		{0.1250001, "0.13", "0.13"},
+		{0.0049999, "0.00", "0.00"},
+		{1e-7, "0.00", "0.00"},
+		{0, "0.00", "0.00"},
+		{19.9, "19.90", "19.90"},
+		{1234.5, "1234.50", "1234.50"},
+		{-0.125, "-0.12", "-0.13"},
+		{-2.675, "-2.68", "-2.68"},
+		{-19.899999999999999, "-19.90", "-19.90"},
+		{-0.001, "0.00", "0.00"},
+		{999999999999999.9, "999999999999999.90", "999999999999999.90"},
+		// A partir de 1e15 os centavos não cabem com folga em int64
+		{1e15, "1000000000000000.00", "1000000000000000.00"},
+		{-1e15, "-1000000000000000.00", "-1000000000000000.00"},
+		{1e20, "100000000000000000000.00", "100000000000000000000.00"},
+		{math.NaN(), "NaN", "NaN"},
+		{math.Inf(1), "+Inf", "+Inf"},
+	}
+
+	for _, mode := range []struct {
+		name   string
+		halfUp bool
+	}{{"half-even", false}, {"half-up", true}} {
+		for _, tt := range tests {
+			want := tt.halfEven
+			if mode.halfUp {
+				want = tt.halfUp
+			}
+			if got := string(appendAmount(nil, tt.value, mode.halfUp)); got != want {
+				t.Errorf("%s: appendAmount(%v) = %s, esperado %s", mode.name, tt.value, got, want)
+			}
+		}
+	}
+}
+
+func TestRoundToCents(t *testing.T) {
+	tests := []struct {
+		value    float64
+		halfEven int64
+		halfUp   int64
+	}{
+		{19.899999999999999, 1990, 1990},
+		{1.005, 100, 101},
+		{2.675, 268, 268},
+		{0.125, 12, 13},
+		{-0.125, -12, -13},
+		{-1.005, -100, -101},
+		{123456789012.125, 12345678901212, 12345678901213},
+	}
+
+	for _, tt := range tests {
+		if got := roundToCents(tt.value, false); got != tt.halfEven {
+			t.Errorf("half-even: roundToCents(%v) = %d, esperado %d", tt.value, got, tt.halfEven)
+		}
+		if got := roundToCents(tt.value, true); got != tt.halfUp {
+			t.Errorf("half-up: roundToCents(%v) = %d, esperado %d", tt.value, got, tt.halfUp)
+		}
+	}
+}
+
+func benchmarkSummary() PaymentSummaryResponse {
+	return PaymentSummaryResponse{
+		"default":  {TotalRequests: 16742, TotalAmount: 333165.8},
+		"fallback": {TotalRequests: 1024, TotalAmount: 20377.6},
+	}
+}
+
+func TestPaymentSummaryAppendJSON(t *testing.T) {
+	got := string(benchmarkSummary().appendJSON(nil, false))
+	want := `{"default":{"totalRequests":16742,"totalAmount":333165.80},"fallback":{"totalRequests":1024,"totalAmount":20377.60}}`
+	if got != want {
+		t.Errorf("appendJSON = %s, esperado %s", got, want)
+	}
+}
+
+func BenchmarkPaymentSummaryAppendJSON(b *testing.B) {
+	summary := benchmarkSummary()
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for b.Loop() {
+		buf = summary.appendJSON(buf[:0], false)
+	}
+}
+
+// BenchmarkPaymentSummaryEncodingJSON é a referência: o mesmo resumo pelo
+// encoding/json, com reflexão e o float sem arredondar.
+func BenchmarkPaymentSummaryEncodingJSON(b *testing.B) {
+	type plainSummary struct {
+		TotalRequests int     `json:"totalRequests"`
+		TotalAmount   float64 `json:"totalAmount"`
+	}
+	summary := make(map[string]plainSummary)
+	for name, s := range benchmarkSummary() {
+		summary[name] = plainSummary{TotalRequests: s.TotalRequests, TotalAmount: s.TotalAmount}
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := json.Marshal(summary); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendAmount(b *testing.B) {
+	buf := make([]byte, 0, 32)
+	b.ReportAllocs()
+	for b.Loop() {
+		buf = appendAmount(buf[:0], 333165.799999999, false)
+	}
+}
+
+func BenchmarkOutboxEntryAppendJSON(b *testing.B) {
+	now := time.Date(2025, 7, 15, 12, 34, 56, 789000000, time.UTC)
+	entry := OutboxEntry{
+		CorrelationID: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3",
+		Amount:        19.9,
+		RequestedAt:   now,
+		CreatedAt:     now,
+	}
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for b.Loop() {
+		buf = entry.appendJSON(buf[:0])
+	}
+}
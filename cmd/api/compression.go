@@ -0,0 +1,269 @@
+//go:build !minimal
+
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Compressão negociada por Accept-Encoding nas respostas do resumo e da
+// exportação. O POST /payments fica de fora: a resposta fixa de poucos bytes só
+// ficaria maior. "deflate" segue o HTTP, ou seja, o formato zlib.
+
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+var (
+	gzipWriters sync.Pool
+	zlibWriters sync.Pool
+
+	compressionEncodings = [2]string{encodingGzip, encodingDeflate}
+)
+
+// compressionState é o estado da compressão
+type compressionState struct {
+	compressionCfg config.CompressionConfig
+
+	compressedResponses  [2]atomic.Int64
+	decompressedRequests atomic.Int64
+}
+
+func (gw *gateway) registerCompression() {
+	gw.registerMetric(metric{
+		Name: "http_compressed_responses_total",
+		Help: "Respostas enviadas comprimidas, por Content-Encoding.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, len(compressionEncodings))
+			for i, encoding := range compressionEncodings {
+				samples[i] = metricSample{
+					Labels: map[string]string{"encoding": encoding},
+					Value:  float64(gw.compressedResponses[i].Load()),
+				}
+			}
+			return samples
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "http_decompressed_requests_total",
+		Help: "Corpos de requisição recebidos com Content-Encoding gzip ou deflate.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.decompressedRequests.Load())}}
+		},
+	})
+}
+
+// compressionMiddleware retorna os handlers que comprimem a resposta da rota,
+// vazio com COMPRESSION=false.
+func (gw *gateway) compressionMiddleware(cfg config.CompressionConfig) []gin.HandlerFunc {
+	if !cfg.Enabled {
+		return nil
+	}
+	gw.compressionCfg = cfg
+	return []gin.HandlerFunc{func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding < 0 {
+			c.Next()
+			return
+		}
+		w := &compressWriter{ResponseWriter: c.Writer, gw: gw, encoding: encoding}
+		c.Writer = w
+		defer w.close()
+		c.Next()
+	}}
+}
+
+// negotiateEncoding escolhe gzip ou deflate conforme Accept-Encoding, preferindo
+// gzip no empate; -1 quando nenhum é aceito.
+func negotiateEncoding(header string) int {
+	if header == "" {
+		return -1
+	}
+	best, bestQ := -1, 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		index := -1
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case encodingGzip, "*":
+			index = 0
+		case encodingDeflate:
+			index = 1
+		}
+		if index >= 0 && q > 0 && (q > bestQ || (q == bestQ && index < best)) {
+			best, bestQ = index, q
+		}
+	}
+	return best
+}
+
+// compressWriter decide no WriteHeader: corpos fixos menores que MinBytes, sem
+// corpo ou já codificados seguem como estão.
+type compressWriter struct {
+	gin.ResponseWriter
+	gw       *gateway
+	encoding int
+
+	decided    bool
+	compressor io.WriteCloser
+}
+
+type flushWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func (w *compressWriter) decide(code int) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	header := w.Header()
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified ||
+		header.Get("Content-Encoding") != "" {
+		return
+	}
+	if length := header.Get("Content-Length"); length != "" {
+		if n, err := strconv.Atoi(length); err == nil && n < w.gw.compressionCfg.MinBytes {
+			return
+		}
+	}
+
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", compressionEncodings[w.encoding])
+	w.compressor = w.gw.getCompressor(w.encoding, w.ResponseWriter)
+	w.gw.compressedResponses[w.encoding].Add(1)
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	w.decide(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	w.decide(w.Status())
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	w.decide(w.Status())
+	if w.compressor == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.compressor.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush esvazia o compressor antes da conexão, para a exportação em streaming.
+func (w *compressWriter) Flush() {
+	if f, ok := w.compressor.(flushWriter); ok {
+		f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) close() {
+	if w.compressor == nil {
+		return
+	}
+	w.compressor.Close()
+	putCompressor(w.encoding, w.compressor)
+	w.compressor = nil
+}
+
+func (gw *gateway) getCompressor(encoding int, dst io.Writer) io.WriteCloser {
+	if encoding == 0 {
+		if zw, ok := gzipWriters.Get().(*gzip.Writer); ok {
+			zw.Reset(dst)
+			return zw
+		}
+		zw, _ := gzip.NewWriterLevel(dst, gw.compressionCfg.Level)
+		return zw
+	}
+	if zw, ok := zlibWriters.Get().(*zlib.Writer); ok {
+		zw.Reset(dst)
+		return zw
+	}
+	zw, _ := zlib.NewWriterLevel(dst, gw.compressionCfg.Level)
+	return zw
+}
+
+func putCompressor(encoding int, w io.WriteCloser) {
+	if encoding == 0 {
+		gzipWriters.Put(w)
+	} else {
+		zlibWriters.Put(w)
+	}
+}
+
+// decompressionMiddleware aceita corpos com Content-Encoding gzip ou deflate. O
+// limite de tamanho do handler vale para o corpo já descomprimido.
+func (gw *gateway) decompressionMiddleware(cfg config.CompressionConfig) []gin.HandlerFunc {
+	if !cfg.Requests {
+		return nil
+	}
+	return []gin.HandlerFunc{func(c *gin.Context) {
+		var body io.ReadCloser
+		var err error
+		switch strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding"))) {
+		case "", "identity":
+			c.Next()
+			return
+		case encodingGzip:
+			body, err = gzip.NewReader(c.Request.Body)
+		case encodingDeflate:
+			body, err = zlib.NewReader(c.Request.Body)
+		default:
+			respondError(c, http.StatusUnsupportedMediaType, errCodeUnsupportedMediaType, msg(msgContentEncoding))
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errCodeInvalidBody, msg(msgInvalidCompressedBody))
+			return
+		}
+		gw.decompressedRequests.Add(1)
+
+		c.Request.Body = decompressedBody{ReadCloser: body, raw: c.Request.Body}
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Del("Content-Length")
+		c.Request.ContentLength = -1
+		c.Next()
+	}}
+}
+
+// decompressedBody fecha o descompressor e o corpo original.
+type decompressedBody struct {
+	io.ReadCloser
+	raw io.Closer
+}
+
+func (b decompressedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.raw.Close()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/storage"
+)
+
+// Conflitos de valor (AMOUNT_CONFLICT_CHECK): um correlationId repetido com o
+// mesmo valor é uma retentativa do cliente e segue como antes, contado uma vez
+// só; com outro valor, aceitá-lo deixaria os contadores com um valor que o
+// processor nunca recebeu, ou o pagamento original sem registro. A fila já
+// indexa os pagamentos pelo correlationId para o DELETE, e o padrão, "queue",
+// confere só ali, sem custo por requisição. "storage" consulta também os
+// registros, para repetições fora da janela da fila, mas põe uma leitura do
+// storage (um round-trip ao Redis) na resposta de cada pagamento novo.
+
+// conflictsState é o estado da conferência de valor
+type conflictsState struct {
+	amountConflicts atomic.Int64
+}
+
+func (gw *gateway) registerConflicts() {
+	gw.registerMetric(metric{
+		Name: "payment_amount_conflicts_total",
+		Help: "Pagamentos recusados por repetir um correlationId com outro valor.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.amountConflicts.Load())}}
+		},
+	})
+}
+
+// AmountConflict são os detalhes da recusa de um correlationId repetido com
+// outro valor.
+type AmountConflict struct {
+	CorrelationID  string  `json:"correlationId"`
+	Amount         float64 `json:"amount"`
+	OriginalAmount float64 `json:"originalAmount"`
+}
+
+// findAmountConflict procura o correlationId entre os pagamentos já recebidos;
+// ok quando ele chegou antes com outro valor. Uma falha na consulta ao storage
+// deixa o pagamento seguir: a recusa é uma proteção, não uma dependência.
+func (gw *gateway) findAmountConflict(ctx context.Context, req PaymentRequest) (conflict AmountConflict, ok bool) {
+	mode := gw.currentConfig().Validation.AmountConflictCheck
+	if mode == config.ConflictCheckOff {
+		return AmountConflict{}, false
+	}
+
+	original, found := gw.paymentQueue.Find(req.CorrelationID)
+	amount := original.Amount
+	if !found && mode == config.ConflictCheckStorage {
+		if finder, isFinder := gw.store.(storage.Finder); isFinder {
+			record, recorded, err := finder.FindPayment(ctx, req.CorrelationID)
+			if err != nil {
+				gw.logf(ctx, "Erro ao consultar pagamento %s: %v", req.CorrelationID, err)
+			}
+			found, amount = recorded, record.Amount
+		}
+	}
+	if !found || sameCents(amount, req.Amount) {
+		return AmountConflict{}, false
+	}
+
+	gw.amountConflicts.Add(1)
+	gw.logf(ctx, "Pagamento %s recusado: recebido antes com %.2f, agora com %.2f", req.CorrelationID, amount, req.Amount)
+	return AmountConflict{CorrelationID: req.CorrelationID, Amount: req.Amount, OriginalAmount: amount}, true
+}
+
+// sameCents compara os valores em centavos, a precisão aceita na validação.
+func sameCents(a, b float64) bool {
+	return math.Round(a*100) == math.Round(b*100)
+}
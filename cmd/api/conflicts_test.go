@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/queue"
+	"rinha-backend-2025/internal/storage"
+)
+
+// TestFindAmountConflictChecksQueueByDefault confere que o padrão não consulta o
+// storage: um pagamento que já saiu da fila passa sem ser conferido.
+func TestFindAmountConflictChecksQueueByDefault(t *testing.T) {
+	gw := newTestGateway(t, clock.Real())
+	if mode := gw.currentConfig().Validation.AmountConflictCheck; mode != config.ConflictCheckQueue {
+		t.Fatalf("AMOUNT_CONFLICT_CHECK padrão %q, esperado %q", mode, config.ConflictCheckQueue)
+	}
+	pool, err := queue.New(queue.Options[PaymentRequest]{
+		Size:  1,
+		Label: func(req PaymentRequest) string { return req.CorrelationID },
+		Key:   func(req PaymentRequest) string { return req.CorrelationID },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw.paymentQueue = pool
+	memory := storage.NewMemory()
+	gw.store = memory
+
+	const id = "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+	ctx := context.Background()
+	if err := memory.RecordPayment(ctx, storage.Record{CorrelationID: id, Amount: 19.90, Processor: "default", RequestedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := gw.findAmountConflict(ctx, PaymentRequest{CorrelationID: id, Amount: 29.90}); ok {
+		t.Error("conflito achado no storage sem AMOUNT_CONFLICT_CHECK=storage")
+	}
+
+	if !pool.TryEnqueue(PaymentRequest{CorrelationID: id, Amount: 19.90}) {
+		t.Fatal("fila cheia")
+	}
+	if _, ok := gw.findAmountConflict(ctx, PaymentRequest{CorrelationID: id, Amount: 29.90}); !ok {
+		t.Error("conflito com o pagamento na fila não achado")
+	}
+}
+
+// TestFindAmountConflictChecksStorage confere que, com AMOUNT_CONFLICT_CHECK=storage,
+// um pagamento que já saiu da fila ainda é conferido pelo registro no storage.
+func TestFindAmountConflictChecksStorage(t *testing.T) {
+	gw := newTestGateway(t, clock.Real())
+	cfg := gw.currentConfig().Config
+	cfg.Validation.AmountConflictCheck = config.ConflictCheckStorage
+	if err := gw.applyConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	pool, err := queue.New(queue.Options[PaymentRequest]{
+		Size:  1,
+		Label: func(req PaymentRequest) string { return req.CorrelationID },
+		Key:   func(req PaymentRequest) string { return req.CorrelationID },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw.paymentQueue = pool
+	memory := storage.NewMemory()
+	gw.store = memory
+
+	const id = "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+	ctx := context.Background()
+	if err := memory.RecordPayment(ctx, storage.Record{CorrelationID: id, Amount: 19.90, Processor: "default", RequestedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		req      PaymentRequest
+		conflict bool
+	}{
+		{PaymentRequest{CorrelationID: id, Amount: 19.90}, false},
+		{PaymentRequest{CorrelationID: id, Amount: 19.9000001}, false},
+		{PaymentRequest{CorrelationID: id, Amount: 29.90}, true},
+		{PaymentRequest{CorrelationID: "9b1f5c1e-3a8d-4f0e-8d6a-2c4b7e9f1a3d", Amount: 29.90}, false},
+	}
+	for _, tt := range tests {
+		conflict, ok := gw.findAmountConflict(ctx, tt.req)
+		if ok != tt.conflict {
+			t.Errorf("%s com %.2f: conflito %v, esperado %v", tt.req.CorrelationID, tt.req.Amount, ok, tt.conflict)
+			continue
+		}
+		if ok && conflict.OriginalAmount != 19.90 {
+			t.Errorf("valor original %.2f, esperado 19.90", conflict.OriginalAmount)
+		}
+	}
+}
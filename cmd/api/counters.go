@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/storage"
+)
+
+// countersState é o estado dos contadores
+type countersState struct {
+	// Flushes seguram o lado de leitura; a leitura consistente do resumo segura
+	// o lado de escrita, pausando brevemente os flushes.
+	counterFlushGate sync.RWMutex
+
+	// Deltas ainda não enviados ao storage, por processor
+	pendingCounters    map[string]*storage.Delta
+	pendingRecords     []storage.Record
+	pendingCountersMux sync.Mutex
+	// Serializa os flushes para que deltas devolvidos após erro não se percam
+	flushMux sync.Mutex
+
+	counterFlushBatch int
+	counterFlushNow   chan struct{}
+
+	// Contagem exatamente uma vez (COUNTER_EXACTLY_ONCE); nil com o storage sem suporte
+	onceCounter storage.OnceCounter
+	countedTTL  time.Duration
+	// Já contados, aguardando só o registro individual depois de um erro
+	pendingCounted    []storage.Record
+	duplicatePayments atomic.Int64
+}
+
+func (gw *gateway) registerCounters() {
+	gw.registerMetric(metric{
+		Name: "counter_duplicates_total",
+		Help: "Pagamentos confirmados de novo cujo correlationId já estava contado, ignorados no resumo.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.duplicatePayments.Load())}}
+		},
+	})
+}
+
+// recordSuccessfulPayment acumula o pagamento para o próximo flush: os contadores
+// atendem o resumo sem filtro e o registro individual atende as consultas por período.
+func (gw *gateway) recordSuccessfulPayment(payment storage.Record) {
+	gw.pendingCountersMux.Lock()
+	if payment.Epoch != gw.paymentEpoch.Load() {
+		// Envio começado antes de um purge: o que ele somaria já foi apagado
+		gw.pendingCountersMux.Unlock()
+		gw.stalePayments.Add(1)
+		return
+	}
+	gw.addPendingPaymentLocked(payment)
+	if gw.counterWAL != nil {
+		gw.counterWAL.append(payment)
+	}
+	full := gw.counterFlushBatch > 0 && len(gw.pendingRecords) >= gw.counterFlushBatch
+	gw.pendingCountersMux.Unlock()
+
+	if full {
+		select {
+		case gw.counterFlushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// addPendingPayment acumula um pagamento já gravado no WAL (replay na subida).
+func (gw *gateway) addPendingPayment(payment storage.Record) {
+	gw.pendingCountersMux.Lock()
+	defer gw.pendingCountersMux.Unlock()
+
+	gw.addPendingPaymentLocked(payment)
+}
+
+func (gw *gateway) addPendingPaymentLocked(payment storage.Record) {
+	delta := gw.pendingCounters[payment.Processor]
+	if delta == nil {
+		delta = &storage.Delta{}
+		gw.pendingCounters[payment.Processor] = delta
+	}
+	delta.Requests++
+	delta.Amount += payment.Amount
+	gw.pendingRecords = append(gw.pendingRecords, payment)
+}
+
+// startCounterFlusher descarrega os deltas a cada intervalo ou quando o lote enche.
+func (gw *gateway) startCounterFlusher(cfg config.CountersConfig) error {
+	gw.counterFlushBatch = cfg.FlushBatchSize
+	if cfg.ExactlyOnce {
+		if counter, ok := gw.store.(storage.OnceCounter); ok {
+			gw.onceCounter, gw.countedTTL = counter, cfg.CountedTTL.Std()
+		} else {
+			log.Printf("Aviso: o storage %s não conta uma única vez por correlationId; retries confirmados duas vezes contam em dobro", gw.currentConfig().Storage.Backend)
+		}
+	}
+	gw.loadEpoch(context.Background())
+	if err := gw.openCounterWAL(cfg.WALDir); err != nil {
+		return err
+	}
+	gw.startEpochSync()
+
+	go func() {
+		ticker := time.NewTicker(cfg.FlushInterval.Std())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-gw.counterFlushNow:
+			}
+			gw.flushCounters()
+		}
+	}()
+	return nil
+}
+
+func (gw *gateway) flushCounters() {
+	gw.counterFlushGate.RLock()
+	defer gw.counterFlushGate.RUnlock()
+
+	gw.flushCountersLocked(context.Background())
+}
+
+// shutdownCounters faz o último flush dentro do prazo de encerramento. O que o
+// storage não confirmar a tempo fica no WAL para a próxima subida.
+func (gw *gateway) shutdownCounters(ctx context.Context) {
+	gw.counterFlushGate.RLock()
+	defer gw.counterFlushGate.RUnlock()
+
+	gw.flushCountersLocked(ctx)
+
+	gw.flushMux.Lock()
+	defer gw.flushMux.Unlock()
+	gw.pendingCountersMux.Lock()
+	defer gw.pendingCountersMux.Unlock()
+
+	pending := len(gw.pendingRecords) + len(gw.pendingCounted)
+	if gw.counterWAL == nil {
+		if pending > 0 {
+			log.Printf("Aviso: %d pagamentos não enviados ao storage foram perdidos (defina COUNTER_WAL_DIR)", pending)
+		}
+		return
+	}
+
+	keep := pending > 0 || len(gw.counterWAL.carried) > 0
+	gw.counterWAL.close(keep)
+	if keep {
+		log.Printf("WAL: pagamentos não confirmados no storage preservados em %s para a próxima subida", gw.counterWAL.dir)
+	}
+}
+
+// counterStoreDurable indica se o que o storage aceita sobrevive a esta instância:
+// em modo degradado, o backend "redis" grava só em memória até reconectar.
+func (gw *gateway) counterStoreDurable() bool {
+	_, degradable := gw.store.(*storage.Degradable)
+	return !degradable || gw.currentRedis() != nil
+}
+
+// flushCountersLocked deve ser chamada com counterFlushGate já adquirido.
+func (gw *gateway) flushCountersLocked(ctx context.Context) {
+	gw.flushMux.Lock()
+	defer gw.flushMux.Unlock()
+
+	// Segmentos de uma queda do Redis já reenviados pelo Promote: liberar mesmo sem pendentes
+	walBacklog := gw.counterWAL != nil && len(gw.counterWAL.carried) > 0 && gw.counterStoreDurable()
+
+	gw.pendingCountersMux.Lock()
+	if len(gw.pendingCounters) == 0 && len(gw.pendingRecords) == 0 && len(gw.pendingCounted) == 0 && !walBacklog {
+		gw.pendingCountersMux.Unlock()
+		return
+	}
+	deltas := gw.pendingCounters
+	records := gw.pendingRecords
+	counted := gw.pendingCounted
+	// Se o storage passar desta época durante o envio, o lote não aparece nas leituras
+	ctx = storage.WithEpoch(ctx, gw.paymentEpoch.Load())
+	gw.pendingCounters = make(map[string]*storage.Delta)
+	gw.pendingRecords = nil
+	gw.pendingCounted = nil
+	var segments []string
+	if gw.counterWAL != nil {
+		segments = gw.counterWAL.rotate()
+	}
+	gw.pendingCountersMux.Unlock()
+
+	// Devolver o que falhar para a próxima tentativa
+	failed := false
+	if gw.onceCounter != nil {
+		// Os contadores saem dos registros, conferidos pelo correlationId no storage;
+		// repetir o lote depois de um erro é seguro
+		if len(records) > 0 {
+			fresh, err := gw.onceCounter.CountOnce(ctx, records, gw.countedTTL)
+			if err != nil {
+				log.Printf("Erro ao atualizar contadores no storage: %v", err)
+				gw.pendingCountersMux.Lock()
+				gw.pendingRecords = append(records, gw.pendingRecords...)
+				gw.pendingCountersMux.Unlock()
+				failed = true
+			} else {
+				gw.duplicatePayments.Add(int64(len(records) - len(fresh)))
+				counted = append(counted, fresh...)
+			}
+		}
+		records = nil
+	} else if len(deltas) > 0 {
+		if err := gw.store.IncrementSummary(ctx, deltas); err != nil {
+			log.Printf("Erro ao atualizar contadores no storage: %v", err)
+			mergeCounters(gw.pendingCounters, &gw.pendingCountersMux, deltas)
+			failed = true
+		}
+	}
+
+	if len(records) > 0 {
+		if err := gw.recordPayments(ctx, records); err != nil {
+			log.Printf("Erro ao registrar pagamentos no storage: %v", err)
+			gw.pendingCountersMux.Lock()
+			gw.pendingRecords = append(records, gw.pendingRecords...)
+			gw.pendingCountersMux.Unlock()
+			failed = true
+		}
+	}
+	if len(counted) > 0 {
+		// Já contados: uma nova passagem por CountOnce os descartaria como repetidos
+		if err := gw.recordPayments(ctx, counted); err != nil {
+			log.Printf("Erro ao registrar pagamentos no storage: %v", err)
+			gw.pendingCountersMux.Lock()
+			gw.pendingCounted = append(counted, gw.pendingCounted...)
+			gw.pendingCountersMux.Unlock()
+			failed = true
+		}
+	}
+
+	// Com falha parcial, o replay repete também a parte já enviada: pelo menos uma
+	// vez. Conferido depois do envio: com o Redis de volta, a memória já foi reenviada
+	if gw.counterWAL != nil {
+		if failed || !gw.counterStoreDurable() {
+			gw.counterWAL.carry(segments)
+		} else {
+			gw.counterWAL.release(segments)
+		}
+	}
+}
+
+func (gw *gateway) recordPayments(ctx context.Context, records []storage.Record) error {
+	if recorder, ok := gw.store.(storage.BatchRecorder); ok {
+		return recorder.RecordPayments(gw.withLoadShed(ctx, len(records)), records)
+	}
+
+	for _, record := range records {
+		if err := gw.store.RecordPayment(ctx, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discardPendingCounters descarta os deltas ainda não enviados e passa os
+// próximos para a época epoch (usado pelo purge).
+func (gw *gateway) discardPendingCounters(epoch int64) {
+	gw.flushMux.Lock()
+	defer gw.flushMux.Unlock()
+
+	gw.pendingCountersMux.Lock()
+	gw.paymentEpoch.Store(epoch)
+	gw.pendingCounters = make(map[string]*storage.Delta)
+	gw.pendingRecords = nil
+	gw.pendingCounted = nil
+	if gw.counterWAL != nil {
+		gw.counterWAL.reset()
+	}
+	gw.pendingCountersMux.Unlock()
+}
+
+func mergeCounters(dst map[string]*storage.Delta, mux *sync.Mutex, src map[string]*storage.Delta) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	for processor, delta := range src {
+		current := dst[processor]
+		if current == nil {
+			current = &storage.Delta{}
+			dst[processor] = current
+		}
+		current.Requests += delta.Requests
+		current.Amount += delta.Amount
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import "time"
+
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
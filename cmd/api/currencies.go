@@ -0,0 +1,91 @@
+//go:build !minimal
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rinha-backend-2025/internal/storage"
+)
+
+// CurrencySummaryResponse é o resumo de ?byCurrency=true: o formato legado com
+// os contadores de cada moeda em "currencies" dentro de cada processor.
+type CurrencySummaryResponse struct {
+	Totals     PaymentSummaryResponse
+	Currencies map[string]map[string]storage.Summary
+}
+
+// handleCurrencySummary atende GET /payments-summary?byCurrency=true. Os
+// contadores por moeda não têm filtro por período nem taxas: sem from, to e
+// detailed.
+func (gw *gateway) handleCurrencySummary(c *gin.Context) {
+	if c.Query("from") != "" || c.Query("to") != "" {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgByCurrencyRange))
+		return
+	}
+	if c.Query("detailed") == "true" {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgByCurrencyDetail))
+		return
+	}
+
+	summary := gw.loadPaymentsSummary(time.Time{}, time.Time{}, summaryOptions{
+		Consistent: c.Query("consistent") == "true",
+		NoCache:    true,
+	})
+	currencies, err := storage.CurrencySummary(c.Request.Context(), gw.store)
+	if errors.Is(err, storage.ErrNoCurrencies) {
+		respondError(c, http.StatusNotImplemented, errCodeNotImplemented, msg(msgNoCurrencies))
+		return
+	}
+	if err != nil {
+		gw.logf(c.Request.Context(), "Erro ao consultar o resumo por moeda: %v", err)
+		respondError(c, http.StatusInternalServerError, errCodeInternal, msg(msgCurrenciesFailed))
+		return
+	}
+
+	buf := getBuffer()
+	*buf = CurrencySummaryResponse{Totals: summary, Currencies: currencies}.appendJSON(*buf, gw.currentConfig().amountHalfUp)
+	writeJSON(c.Writer, http.StatusOK, *buf)
+	putBuffer(buf)
+}
+
+// appendJSON escreve os processors na ordem do resumo e as moedas em ordem
+// alfabética.
+func (r CurrencySummaryResponse) appendJSON(buf []byte, halfUp bool) []byte {
+	buf = append(buf, '{')
+	for i, name := range r.Totals.orderedNames() {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendJSONString(buf, name)
+		totals := r.Totals[name]
+		buf = append(buf, `:{"totalRequests":`...)
+		buf = strconv.AppendInt(buf, int64(totals.TotalRequests), 10)
+		buf = append(buf, `,"totalAmount":`...)
+		buf = appendAmount(buf, totals.TotalAmount, halfUp)
+		buf = append(buf, `,"currencies":{`...)
+
+		byCurrency := r.Currencies[name]
+		codes := make([]string, 0, len(byCurrency))
+		for code := range byCurrency {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for j, code := range codes {
+			if j > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, code)
+			buf = append(buf, ':')
+			buf = ProcessorSummary(byCurrency[code]).appendJSON(buf, halfUp)
+		}
+		buf = append(buf, "}}"...)
+	}
+	return append(buf, '}')
+}
@@ -0,0 +1,109 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// RuntimeStats resume o estado do runtime para acompanhar testes de carga.
+type RuntimeStats struct {
+	Goroutines    int       `json:"goroutines"`
+	HeapAlloc     uint64    `json:"heapAllocBytes"`
+	HeapInuse     uint64    `json:"heapInuseBytes"`
+	HeapObjects   uint64    `json:"heapObjects"`
+	NumGC         uint32    `json:"numGC"`
+	PauseTotalMs  float64   `json:"gcPauseTotalMs"`
+	LastPauseMs   float64   `json:"gcLastPauseMs"`
+	LastGC        time.Time `json:"lastGC"`
+	ActiveWorkers int64     `json:"activeWorkers"`
+	QueueLength   int       `json:"queueLength"`
+}
+
+func (gw *gateway) runtimeStats() RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	stats := RuntimeStats{
+		Goroutines:    runtime.NumGoroutine(),
+		HeapAlloc:     m.HeapAlloc,
+		HeapInuse:     m.HeapInuse,
+		HeapObjects:   m.HeapObjects,
+		NumGC:         m.NumGC,
+		PauseTotalMs:  float64(m.PauseTotalNs) / 1e6,
+		ActiveWorkers: int64(gw.paymentQueue.Status().Active),
+		QueueLength:   gw.paymentQueue.Len(),
+	}
+	if m.NumGC > 0 {
+		stats.LastPauseMs = float64(m.PauseNs[(m.NumGC+255)%256]) / 1e6
+		stats.LastGC = time.Unix(0, int64(m.LastGC)).UTC()
+	}
+	return stats
+}
+
+// newDebugHandler monta pprof, expvar, as estatísticas de runtime e a Swagger UI
+// sob /debug.
+// Não usa o http.DefaultServeMux para não expor nada sem DEBUG_ENDPOINTS.
+func (gw *gateway) newDebugHandler() http.Handler {
+	// Publicado em /debug/vars junto com cmdline e memstats do expvar, que é um
+	// só por processo
+	if expvar.Get("runtime") == nil {
+		expvar.Publish("runtime", expvar.Func(func() interface{} { return gw.runtimeStats() }))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	// Swagger UI, com o documento ao lado também na porta de diagnóstico
+	mux.HandleFunc("/debug/swagger", serveSwaggerUI)
+	mux.HandleFunc("/debug/openapi.json", gw.serveOpenAPI)
+	mux.HandleFunc("/debug/runtime", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(gw.runtimeStats()); err != nil {
+			log.Printf("Erro ao serializar estatísticas de runtime: %v", err)
+		}
+	})
+	return mux
+}
+
+// startDebugEndpoints expõe /debug na porta de diagnóstico ou, sem ela, no router
+// principal, nos dois casos atrás da autenticação do grupo. Retorna o servidor
+// separado (ou nil) para o encerramento.
+func (gw *gateway) startDebugEndpoints(cfg config.DebugConfig, auth config.AuthConfig, r *gin.Engine) *http.Server {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	handler := gw.newDebugHandler()
+	if guard := gw.newRouteGuard("/debug", auth.Header, auth.Debug); guard != nil {
+		handler = guard.wrap(handler)
+	}
+	if cfg.Port == "" {
+		r.Any("/debug/*path", gin.WrapH(handler))
+		log.Printf("Endpoints de diagnóstico disponíveis em /debug")
+		return nil
+	}
+
+	srv := &http.Server{Addr: "0.0.0.0:" + cfg.Port, Handler: handler}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Erro no servidor de diagnóstico: %v", err)
+		}
+	}()
+	log.Printf("Endpoints de diagnóstico disponíveis na porta %s", cfg.Port)
+	return srv
+}
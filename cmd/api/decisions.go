@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/selector"
+)
+
+// Registro das decisões de roteamento (SELECTOR_DECISION_LOG): cada pagamento
+// despachado grava no stream routing:decisions os candidatos que a estratégia
+// recebeu, a ordem tentada, o processor final, o desfecho e a latência. O
+// stream é limitado a cerca de SELECTOR_DECISION_LOG_MAXLEN entradas e vai por
+// GET /admin/routing/decisions, em NDJSON, para o cmd/replay, que compara outras
+// estratégias sobre as mesmas decisões. Best-effort como a auditoria: sem
+// Redis, as decisões são descartadas.
+
+const decisionStreamKey = "routing:decisions"
+
+// Intervalo de gravação do stream
+const decisionFlushInterval = time.Second
+
+// decisionsState é o estado do registro de decisões
+type decisionsState struct {
+	decisionLogEnabled bool
+	decisionLogMaxLen  int64
+
+	pendingDecisions    []selector.Decision
+	pendingDecisionsMux sync.Mutex
+	decisionsRecorded   atomic.Int64
+	decisionsDropped    atomic.Int64
+}
+
+func (gw *gateway) initDecisionLog(cfg config.SelectorConfig) {
+	if !cfg.DecisionLog {
+		return
+	}
+	gw.decisionLogEnabled = true
+	gw.decisionLogMaxLen = cfg.DecisionLogMaxLen
+
+	gw.registerMetric(metric{
+		Name: "routing_decisions_recorded_total",
+		Help: "Decisões de roteamento gravadas no stream do Redis.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.decisionsRecorded.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "routing_decisions_dropped_total",
+		Help: "Decisões de roteamento descartadas (Redis indisponível ou acúmulo acima do limite).",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.decisionsDropped.Load())}}
+		},
+	})
+
+	go func() {
+		ticker := time.NewTicker(decisionFlushInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			gw.flushDecisions()
+		}
+	}()
+	log.Printf("Decisões de roteamento gravadas em %s (até ~%d entradas)", gw.keys.Key(decisionStreamKey), cfg.DecisionLogMaxLen)
+}
+
+// decisionOutcome traduz o desfecho do envio para o registro.
+func decisionOutcome(result sendResult) string {
+	switch result {
+	case sendSucceeded:
+		return selector.OutcomeSucceeded
+	case sendUnknown:
+		return selector.OutcomeUnknown
+	case sendRejected:
+		return selector.OutcomeRejected
+	}
+	return selector.OutcomeFailed
+}
+
+// recordDecision guarda a decisão de dispatchPayment para o próximo flush.
+func (gw *gateway) recordDecision(candidates []selector.Candidate, ranking []string, processor string, result sendResult, amount float64, latency time.Duration) {
+	// Os que voltam para a fila sem desfecho (limitador, TPS, orçamento do
+	// fallback) são decididos de novo na próxima vez
+	if !gw.decisionLogEnabled || result == sendShed || result == sendDeferred || gw.loadShed(shedDecisions) {
+		return
+	}
+	decision := selector.Decision{
+		At:         gw.appClock.Now().UTC(),
+		Amount:     amount,
+		Strategy:   gw.currentConfig().Selector.Strategy,
+		Candidates: candidates,
+		Ranking:    ranking,
+		Processor:  processor,
+		Outcome:    decisionOutcome(result),
+		Latency:    latency,
+		Instance:   gw.instanceID,
+	}
+
+	gw.pendingDecisionsMux.Lock()
+	defer gw.pendingDecisionsMux.Unlock()
+	// O stream não guarda mais que isso; acumular além só atrasaria o descarte
+	if int64(len(gw.pendingDecisions)) >= gw.decisionLogMaxLen {
+		gw.decisionsDropped.Add(1)
+		return
+	}
+	gw.pendingDecisions = append(gw.pendingDecisions, decision)
+}
+
+func (gw *gateway) flushDecisions() {
+	gw.pendingDecisionsMux.Lock()
+	decisions := gw.pendingDecisions
+	gw.pendingDecisions = nil
+	gw.pendingDecisionsMux.Unlock()
+
+	if len(decisions) == 0 {
+		return
+	}
+	client := gw.currentRedis()
+	if client == nil {
+		gw.decisionsDropped.Add(int64(len(decisions)))
+		return
+	}
+
+	ctx := context.Background()
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, decision := range decisions {
+			data, err := json.Marshal(decision)
+			if err != nil {
+				return err
+			}
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: gw.keys.Key(decisionStreamKey),
+				MaxLen: gw.decisionLogMaxLen,
+				Approx: true,
+				Values: []interface{}{"decision", data},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		gw.decisionsDropped.Add(int64(len(decisions)))
+		log.Printf("Erro ao gravar decisões de roteamento: %v", err)
+		return
+	}
+	gw.decisionsRecorded.Add(int64(len(decisions)))
+}
+
+// scanDecisions percorre o stream da decisão mais antiga para a mais recente,
+// a partir de after (exclusivo; vazio desde o início), até limit decisões.
+func (gw *gateway) scanDecisions(ctx context.Context, client redis.UniversalClient, after string, limit int, fn func(id string, decision selector.Decision) error) error {
+	start := "-"
+	if after != "" {
+		start = "(" + after
+	}
+	for sent := 0; sent < limit; {
+		page := int64(min(auditScanPage, limit-sent))
+		msgs, err := client.XRangeN(ctx, gw.keys.Key(decisionStreamKey), start, "+", page).Result()
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			data, _ := msg.Values["decision"].(string)
+			var decision selector.Decision
+			if err := json.Unmarshal([]byte(data), &decision); err != nil {
+				continue
+			}
+			if err := fn(msg.ID, decision); err != nil {
+				return err
+			}
+			sent++
+		}
+		if int64(len(msgs)) < page {
+			return nil
+		}
+		// Intervalo exclusivo depois da última entrada lida
+		start = "(" + msgs[len(msgs)-1].ID
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"rinha-backend-2025/internal/selector"
+)
+
+// Decisões por resposta, sem ?limit
+const defaultDecisionsLimit = 100_000
+
+// handleAdminRoutingDecisions exporta o stream de decisões em NDJSON, da mais
+// antiga para a mais recente, cada linha com o ID da entrada no stream;
+// ?after=<id> continua depois da última linha de uma exportação anterior.
+func (gw *gateway) handleAdminRoutingDecisions(c *gin.Context) {
+	if !gw.decisionLogEnabled {
+		respondError(c, http.StatusNotFound, errCodeNotFound, msg(msgDecisionLogDisabled))
+		return
+	}
+	limit := defaultDecisionsLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidLimit))
+			return
+		}
+		limit = n
+	}
+	client := gw.currentRedis()
+	if client == nil {
+		respondError(c, http.StatusServiceUnavailable, errCodeUnavailable, msg(msgRedisUnavailable))
+		return
+	}
+
+	// Incluir o que ainda não foi gravado
+	gw.flushDecisions()
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	w := bufio.NewWriter(c.Writer)
+	rows := 0
+	ctx := c.Request.Context()
+	err := gw.scanDecisions(ctx, client, c.Query("after"), limit, func(id string, decision selector.Decision) error {
+		line, err := json.Marshal(struct {
+			ID string `json:"id"`
+			selector.Decision
+		}{id, decision})
+		if err != nil {
+			return err
+		}
+		w.Write(line)
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushEvery == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		// O status já foi enviado: só resta interromper a resposta
+		gw.logf(ctx, "Exportação de decisões interrompida após %d linhas: %v", rows, err)
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Pools de envio por processor (PROCESSOR_<NOME>_WORKERS): sem eles, um fallback
+// travado prende os workers que o default precisaria. Com eles, os workers da
+// fila principal só roteiam: o pagamento vai para a fila do pool do primeiro
+// processor da ordem, e os workers do pool o processam com a mesma pipeline,
+// sem seguir para os processors de outro pool. Da falha ali, o pagamento passa
+// para o pool do próximo processor antes da DLQ. Os processors sem pool seguem
+// sendo tentados por quem estiver processando. Os pagamentos na fila de um pool
+// não são vistos por DELETE /payments/:correlationId.
+
+// dispatchPool é o pool de envio de um processor.
+type dispatchPool struct {
+	gw        *gateway
+	processor string
+	jobs      chan PaymentRequest
+	workers   int
+	wg        sync.WaitGroup
+	// closed impede envios à fila depois de stopDispatchPools
+	mu     sync.RWMutex
+	closed bool
+
+	busy       atomic.Int64
+	taken      atomic.Int64
+	full       atomic.Int64
+	rebalanced atomic.Int64
+}
+
+// dispatchPoolsState é o estado dos pools de envio
+type dispatchPoolsState struct {
+	// Pools por nome de processor; nil sem nenhum configurado
+	dispatchPools map[string]*dispatchPool
+}
+
+type dispatchPoolKey struct{}
+
+// initDispatchPools inicia os pools configurados e o rebalanceamento.
+func (gw *gateway) initDispatchPools(cfg config.WorkersConfig) {
+	if len(cfg.Pools) == 0 {
+		return
+	}
+	gw.dispatchPools = make(map[string]*dispatchPool, len(cfg.Pools))
+	for _, name := range gw.processorNames {
+		poolCfg, ok := cfg.Pools[name]
+		if !ok {
+			continue
+		}
+		pool := &dispatchPool{
+			gw:        gw,
+			processor: name,
+			jobs:      make(chan PaymentRequest, poolCfg.QueueSize),
+			workers:   poolCfg.Workers,
+		}
+		for i := 0; i < poolCfg.Workers; i++ {
+			pool.wg.Add(1)
+			go pool.work()
+		}
+		gw.dispatchPools[name] = pool
+		log.Printf("Pool de envio do %s: %d workers, fila de %d", name, poolCfg.Workers, poolCfg.QueueSize)
+	}
+
+	gw.registerMetric(metric{
+		Name: "dispatch_pool_depth",
+		Help: "Pagamentos aguardando um worker no pool de envio de cada processor.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			return gw.collectDispatchPools(func(p *dispatchPool) float64 { return float64(len(p.jobs)) })
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "dispatch_pool_busy",
+		Help: "Workers ocupados no pool de envio de cada processor.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			return gw.collectDispatchPools(func(p *dispatchPool) float64 { return float64(p.busy.Load()) })
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "dispatch_pool_taken_total",
+		Help: "Pagamentos processados pelo pool de envio de cada processor.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return gw.collectDispatchPools(func(p *dispatchPool) float64 { return float64(p.taken.Load()) })
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "dispatch_pool_full_total",
+		Help: "Pagamentos que encontraram cheia a fila do pool de envio do processor.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return gw.collectDispatchPools(func(p *dispatchPool) float64 { return float64(p.full.Load()) })
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "dispatch_pool_rebalanced_total",
+		Help: "Pagamentos retirados da fila do pool de envio de um processor que passou a falhar.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return gw.collectDispatchPools(func(p *dispatchPool) float64 { return float64(p.rebalanced.Load()) })
+		},
+	})
+
+	go func() {
+		ticker := time.NewTicker(cfg.RebalanceInterval.Std())
+		defer ticker.Stop()
+
+		for range ticker.C {
+			gw.rebalanceDispatchPools()
+		}
+	}()
+}
+
+func (gw *gateway) collectDispatchPools(value func(*dispatchPool) float64) []metricSample {
+	samples := make([]metricSample, 0, len(gw.dispatchPools))
+	for _, name := range gw.processorNames {
+		if p := gw.dispatchPools[name]; p != nil {
+			samples = append(samples, metricSample{Labels: map[string]string{"processor": name}, Value: value(p)})
+		}
+	}
+	return samples
+}
+
+func (p *dispatchPool) work() {
+	defer p.wg.Done()
+	for req := range p.jobs {
+		ctx := withRequestID(context.Background(), req.RequestID)
+		// O processor passou a falhar enquanto o pagamento esperava: outro pool
+		// pode atendê-lo
+		if p.gw.processorFailing(p.processor, p.gw.healthMonitor.Get(ctx, p.processor)) && p.gw.routeToOtherPool(ctx, req, p.processor) {
+			p.rebalanced.Add(1)
+			continue
+		}
+		p.busy.Add(1)
+		p.taken.Add(1)
+		p.gw.processPaymentRecovered(context.WithValue(ctx, dispatchPoolKey{}, p.processor), req)
+		p.busy.Add(-1)
+	}
+}
+
+// offer coloca o pagamento na fila do pool; false se ela está cheia ou fechada.
+func (p *dispatchPool) offer(req PaymentRequest) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+	select {
+	case p.jobs <- req:
+		return true
+	default:
+		p.full.Add(1)
+		return false
+	}
+}
+
+// take retira um pagamento da fila sem esperar.
+func (p *dispatchPool) take() (PaymentRequest, bool) {
+	select {
+	case req, ok := <-p.jobs:
+		return req, ok
+	default:
+		return PaymentRequest{}, false
+	}
+}
+
+func (p *dispatchPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+}
+
+// routePayment é o processamento dos workers da fila principal com pools: o
+// pagamento vai para o pool do primeiro processor da ordem com vaga, ou é
+// processado ali mesmo se esse processor não tem pool. Com todos os pools
+// cheios, volta para a fila principal.
+func (gw *gateway) routePayment(ctx context.Context, req PaymentRequest) {
+	for _, name := range gw.filterByAmount(gw.rankProcessors(ctx), req.Amount) {
+		pool := gw.dispatchPools[name]
+		if pool == nil {
+			gw.processPaymentRecovered(context.WithValue(ctx, dispatchPoolKey{}, ""), req)
+			return
+		}
+		if pool.offer(req) {
+			return
+		}
+	}
+	gw.paymentQueue.Requeue(req, gw.currentConfig().Limiter.RequeueDelay.Std())
+}
+
+// routeToOtherPool passa o pagamento ao pool do próximo processor da ordem que
+// ainda não o recebeu, depois de from. false se não há nenhum com vaga.
+func (gw *gateway) routeToOtherPool(ctx context.Context, req PaymentRequest, from string) bool {
+	if !slices.Contains(req.PoolsTried, from) {
+		req.PoolsTried = append(slices.Clip(req.PoolsTried), from)
+	}
+	for _, name := range gw.filterByAmount(gw.rankProcessors(ctx), req.Amount) {
+		pool := gw.dispatchPools[name]
+		if pool == nil || slices.Contains(req.PoolsTried, name) {
+			continue
+		}
+		if pool.offer(req) {
+			gw.logf(ctx, "Pagamento %s passado do %s para o pool do %s", req.CorrelationID, poolLabel(from), name)
+			return true
+		}
+	}
+	return false
+}
+
+// poolOf retorna o processor do pool que processa o pagamento em ctx ("" nos
+// workers da fila principal), e false fora do roteamento por pools (DLQ, ACK
+// síncrono).
+func poolOf(ctx context.Context) (string, bool) {
+	processor, ok := ctx.Value(dispatchPoolKey{}).(string)
+	return processor, ok
+}
+
+func poolLabel(processor string) string {
+	if processor == "" {
+		return "roteamento"
+	}
+	return processor
+}
+
+// poolRanking restringe a ordem de envio de quem processa com pools: o processor
+// do próprio pool primeiro e depois os sem pool. Os outros pools recebem o
+// pagamento por handOffPayment, sem prender o worker deste.
+func (gw *gateway) poolRanking(ctx context.Context, ranking []string) []string {
+	own, ok := poolOf(ctx)
+	if !ok || gw.dispatchPools == nil {
+		return ranking
+	}
+	restricted := make([]string, 0, len(ranking))
+	if own != "" && slices.Contains(ranking, own) {
+		restricted = append(restricted, own)
+	}
+	for _, name := range ranking {
+		if gw.dispatchPools[name] == nil {
+			restricted = append(restricted, name)
+		}
+	}
+	return restricted
+}
+
+// handOffPayment passa um pagamento que falhou para o pool de outro processor,
+// em vez da DLQ; false se não há outro pool que ainda não o tentou.
+func (gw *gateway) handOffPayment(ctx context.Context, req PaymentRequest) bool {
+	own, ok := poolOf(ctx)
+	if !ok || gw.dispatchPools == nil {
+		return false
+	}
+	return gw.routeToOtherPool(ctx, req, own)
+}
+
+// rebalanceDispatchPools devolve ao roteamento a fila dos pools cujo processor
+// passou a falhar, para não esperar os workers dele.
+func (gw *gateway) rebalanceDispatchPools() {
+	ctx := context.Background()
+	for _, name := range gw.processorNames {
+		pool := gw.dispatchPools[name]
+		if pool == nil || len(pool.jobs) == 0 || !gw.processorFailing(name, gw.healthMonitor.Get(ctx, name)) {
+			continue
+		}
+		moved := 0
+		for n := len(pool.jobs); n > 0; n-- {
+			req, ok := pool.take()
+			if !ok {
+				break
+			}
+			if !gw.routeToOtherPool(ctx, req, name) {
+				// Sem vaga nos outros pools: o roteamento tenta de novo depois
+				gw.paymentQueue.Requeue(req, gw.currentConfig().Limiter.RequeueDelay.Std())
+				break
+			}
+			moved++
+		}
+		if moved > 0 {
+			pool.rebalanced.Add(int64(moved))
+			log.Printf("Rebalanceamento: %d pagamentos saíram do pool do %s", moved, name)
+		}
+	}
+}
+
+// stopDispatchPools fecha as filas dos pools e aguarda os workers terminarem o
+// que já foi enfileirado. Roda depois de paymentQueue.Stop, que para o roteamento.
+func (gw *gateway) stopDispatchPools(ctx context.Context) {
+	if gw.dispatchPools == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		for _, pool := range gw.dispatchPools {
+			pool.close()
+		}
+		for _, pool := range gw.dispatchPools {
+			pool.wg.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Aviso: pools de envio não terminaram antes do prazo de encerramento")
+	}
+}
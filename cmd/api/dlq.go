@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const dlqKey = "payments:dlq"
+
+// DeadLetter é um pagamento que esgotou as tentativas em todos os processors.
+type DeadLetter struct {
+	CorrelationID string    `json:"correlationId"`
+	Amount        float64   `json:"amount"`
+	CallbackURL   string    `json:"callbackUrl,omitempty"`
+	Currency      string    `json:"currency,omitempty"`
+	RequestedAt   time.Time `json:"requestedAt"`
+	FailedAt      time.Time `json:"failedAt"`
+	Redrives      int       `json:"redrives"`
+	// Metadata do pedido original, que segue no redrive
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// newDeadLetter estaciona um pagamento que esgotou as tentativas.
+func (gw *gateway) newDeadLetter(req PaymentRequest) DeadLetter {
+	return DeadLetter{
+		CorrelationID: req.CorrelationID,
+		Amount:        req.Amount,
+		CallbackURL:   req.CallbackURL,
+		Currency:      req.Currency,
+		RequestedAt:   req.RequestedAt,
+		FailedAt:      gw.appClock.Now().UTC(),
+		Metadata:      req.Metadata,
+	}
+}
+
+// dlqState é o estado da DLQ
+type dlqState struct {
+	// Usada apenas quando o Redis não está disponível
+	memoryDLQ    []DeadLetter
+	memoryDLQMux sync.Mutex
+}
+
+func (gw *gateway) pushToDLQ(entry DeadLetter) {
+	if client := gw.currentRedis(); client != nil {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			log.Printf("Erro ao serializar entrada da DLQ: %v", err)
+			return
+		}
+		if err := client.RPush(context.Background(), gw.keys.Key(dlqKey), data).Err(); err != nil {
+			log.Printf("Erro ao enviar %s para a DLQ: %v", entry.CorrelationID, err)
+		}
+		return
+	}
+
+	gw.memoryDLQMux.Lock()
+	// No teto, a mais antiga sai para a nova entrar (MEMORY_MAX_DLQ_ENTRIES)
+	if len(gw.memoryDLQ) >= gw.currentConfig().Memory.MaxDLQEntries {
+		gw.memoryEvicted[evictedDLQ].Add(1)
+		log.Printf("DLQ em memória cheia: %s descartado", gw.memoryDLQ[0].CorrelationID)
+		gw.memoryDLQ = gw.memoryDLQ[1:]
+	}
+	gw.memoryDLQ = append(gw.memoryDLQ, entry)
+	gw.memoryDLQMux.Unlock()
+}
+
+// replayMemoryDLQ move para o Redis as entradas acumuladas em memória durante
+// uma queda; as que não puderem ser enviadas continuam em memória.
+func (gw *gateway) replayMemoryDLQ(ctx context.Context, client redis.UniversalClient) error {
+	gw.memoryDLQMux.Lock()
+	defer gw.memoryDLQMux.Unlock()
+
+	for len(gw.memoryDLQ) > 0 {
+		data, err := json.Marshal(gw.memoryDLQ[0])
+		if err != nil {
+			log.Printf("Entrada inválida descartada da DLQ: %v", err)
+		} else if err := client.RPush(ctx, gw.keys.Key(dlqKey), data).Err(); err != nil {
+			return err
+		}
+		gw.memoryDLQ = gw.memoryDLQ[1:]
+	}
+	return nil
+}
+
+func (gw *gateway) popFromDLQ() (DeadLetter, bool) {
+	var entry DeadLetter
+
+	if client := gw.currentRedis(); client != nil {
+		data, err := client.LPop(context.Background(), gw.keys.Key(dlqKey)).Bytes()
+		if err != nil {
+			return entry, false
+		}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			log.Printf("Entrada inválida descartada da DLQ: %v", err)
+			return entry, false
+		}
+		return entry, true
+	}
+
+	gw.memoryDLQMux.Lock()
+	defer gw.memoryDLQMux.Unlock()
+	if len(gw.memoryDLQ) == 0 {
+		return entry, false
+	}
+	entry = gw.memoryDLQ[0]
+	gw.memoryDLQ = gw.memoryDLQ[1:]
+	return entry, true
+}
+
+func (gw *gateway) dlqLength() int {
+	if client := gw.currentRedis(); client != nil {
+		n, err := client.LLen(context.Background(), gw.keys.Key(dlqKey)).Result()
+		if err != nil {
+			log.Printf("Erro ao obter tamanho da DLQ: %v", err)
+			return 0
+		}
+		return int(n)
+	}
+
+	gw.memoryDLQMux.Lock()
+	defer gw.memoryDLQMux.Unlock()
+	return len(gw.memoryDLQ)
+}
+
+func (gw *gateway) listDLQ(limit int) []DeadLetter {
+	entries := []DeadLetter{}
+
+	if client := gw.currentRedis(); client != nil {
+		items, err := client.LRange(context.Background(), gw.keys.Key(dlqKey), 0, int64(limit-1)).Result()
+		if err != nil {
+			log.Printf("Erro ao listar DLQ: %v", err)
+			return entries
+		}
+		for _, item := range items {
+			var entry DeadLetter
+			if err := json.Unmarshal([]byte(item), &entry); err == nil {
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	}
+
+	gw.memoryDLQMux.Lock()
+	defer gw.memoryDLQMux.Unlock()
+	if limit > len(gw.memoryDLQ) {
+		limit = len(gw.memoryDLQ)
+	}
+	return append(entries, gw.memoryDLQ[:limit]...)
+}
+
+// eachDLQ percorre a DLQ em páginas, sem retirar as entradas.
+func (gw *gateway) eachDLQ(ctx context.Context, fn func(DeadLetter) error) error {
+	const page = 500
+
+	if client := gw.currentRedis(); client != nil {
+		for start := int64(0); ; start += page {
+			items, err := client.LRange(ctx, gw.keys.Key(dlqKey), start, start+page-1).Result()
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				var entry DeadLetter
+				if err := json.Unmarshal([]byte(item), &entry); err != nil {
+					continue
+				}
+				if err := fn(entry); err != nil {
+					return err
+				}
+			}
+			if len(items) < page {
+				return nil
+			}
+		}
+	}
+
+	gw.memoryDLQMux.Lock()
+	entries := append([]DeadLetter(nil), gw.memoryDLQ...)
+	gw.memoryDLQMux.Unlock()
+	for _, entry := range entries {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startDLQRedrive reprocessa periodicamente a DLQ quando algum processor está saudável.
+func (gw *gateway) startDLQRedrive(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			gw.redriveDLQ()
+		}
+	}()
+}
+
+func (gw *gateway) redriveDLQ() {
+	ctx := context.Background()
+	if gw.allProcessorsFailing(ctx) {
+		return
+	}
+
+	// Limitar ao tamanho atual para não reprocessar as entradas devolvidas neste ciclo
+	pending := gw.dlqLength()
+	for i := 0; i < pending; i++ {
+		entry, ok := gw.popFromDLQ()
+		if !ok {
+			return
+		}
+
+		// O envio original pode ter sido aceito apesar do erro: não reenviar
+		if gw.outboxEnabled {
+			epoch := gw.currentEpoch()
+			if record, found, err := gw.lookupAcceptedPayment(ctx, entry.CorrelationID); err == nil && found {
+				if gw.outboxClaim(entry.CorrelationID) {
+					record.Currency, record.Epoch = entry.Currency, epoch
+					gw.outboxReconciled.Add(1)
+					gw.recordSuccessfulPayment(record)
+					gw.recordAudit(AuditEntry{CorrelationID: entry.CorrelationID, Event: auditReconciled, Processor: record.Processor})
+					gw.indexPaymentStatus(entry.CorrelationID, paymentSucceeded, record.RequestedAt)
+					gw.notifyPayment(PaymentRequest{CorrelationID: entry.CorrelationID, CallbackURL: entry.CallbackURL},
+						webhookProcessed, record.Processor)
+				}
+				log.Printf("Pagamento %s já aceito pelo %s, removido da DLQ", entry.CorrelationID, record.Processor)
+				continue
+			}
+		}
+
+		// Entradas gravadas antes do requestedAt fazer parte da DLQ
+		if entry.RequestedAt.IsZero() {
+			entry.RequestedAt = gw.newRequestedAt()
+		}
+		req := PaymentRequest{
+			CorrelationID: entry.CorrelationID,
+			Amount:        entry.Amount,
+			CallbackURL:   entry.CallbackURL,
+			Currency:      currencyOrDefault(entry.Currency),
+			RequestedAt:   entry.RequestedAt,
+			Metadata:      entry.Metadata,
+		}
+		switch gw.redrivePayment(ctx, req) {
+		case sendSucceeded:
+			log.Printf("Pagamento %s reprocessado da DLQ", entry.CorrelationID)
+			continue
+		case sendUnknown:
+			// Volta pela reconciliação, se nenhum processor o conhecer
+			continue
+		}
+
+		entry.Redrives++
+		entry.FailedAt = gw.appClock.Now().UTC()
+		gw.pushToDLQ(entry)
+	}
+}
+
+// redrivePayment reenvia uma entrada da DLQ com um novo orçamento de tempo. Um
+// pânico conta como falha: a entrada volta para a DLQ com mais um redrive.
+func (gw *gateway) redrivePayment(ctx context.Context, req PaymentRequest) (result sendResult) {
+	ctx, cancel := context.WithTimeout(ctx, gw.currentConfig().Retry.PaymentBudget.Std())
+	defer cancel()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			gw.logPaymentPanic(ctx, req, recovered)
+			gw.paymentPanics[panicRedriveFailed].Add(1)
+			result = sendFailed
+		}
+	}()
+
+	return gw.dispatchPayment(ctx, req)
+}
+
+// RequeueResponse é a resposta de POST /admin/requeue.
+type RequeueResponse struct {
+	// Entradas devolvidas à fila principal
+	Moved int `json:"moved"`
+	// Retiradas sem reenvio: o pagamento já estava registrado ou na fila
+	AlreadyProcessed int `json:"alreadyProcessed"`
+	// Tamanho da DLQ ao final
+	Remaining int `json:"remaining"`
+}
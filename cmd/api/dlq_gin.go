@@ -0,0 +1,99 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"rinha-backend-2025/internal/storage"
+)
+
+func (gw *gateway) handleAdminDLQ(c *gin.Context) {
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidLimit))
+			return
+		}
+		limit = n
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"size":    gw.dlqLength(),
+		"entries": gw.listDLQ(limit),
+	})
+}
+
+// handleAdminRequeue devolve até limit entradas da DLQ à fila principal, para o
+// operador reenviá-las sem esperar o redrive periódico. Os correlationIds já
+// registrados no storage ou ainda na fila saem da DLQ sem voltar aos
+// processors, o que torna repetir a chamada seguro. Com a fila cheia, a entrada
+// volta para a DLQ e a chamada termina.
+func (gw *gateway) handleAdminRequeue(c *gin.Context) {
+	if source := c.DefaultQuery("source", "dlq"); source != "dlq" {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidDLQSource))
+		return
+	}
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidLimit))
+			return
+		}
+		limit = n
+	}
+
+	ctx := c.Request.Context()
+	// Os registros aceitos há pouco ainda podem estar nos deltas pendentes
+	gw.flushCounters()
+	finder, _ := gw.store.(storage.Finder)
+
+	var response RequeueResponse
+	for i := 0; i < limit; i++ {
+		entry, ok := gw.popFromDLQ()
+		if !ok {
+			break
+		}
+
+		if _, queued := gw.paymentQueue.Find(entry.CorrelationID); queued {
+			response.AlreadyProcessed++
+			continue
+		}
+		if finder != nil {
+			if _, found, err := finder.FindPayment(ctx, entry.CorrelationID); err == nil && found {
+				gw.logf(ctx, "Pagamento %s já registrado, removido da DLQ", entry.CorrelationID)
+				response.AlreadyProcessed++
+				continue
+			}
+		}
+
+		// Entradas gravadas antes do requestedAt fazer parte da DLQ
+		if entry.RequestedAt.IsZero() {
+			entry.RequestedAt = gw.newRequestedAt()
+		}
+		req := PaymentRequest{
+			CorrelationID: entry.CorrelationID,
+			Amount:        entry.Amount,
+			CallbackURL:   entry.CallbackURL,
+			Currency:      currencyOrDefault(entry.Currency),
+			RequestedAt:   entry.RequestedAt,
+			Metadata:      entry.Metadata,
+			RequestID:     c.GetString(requestIDKey),
+		}
+		if !gw.paymentQueue.TryEnqueue(req) {
+			gw.pushToDLQ(entry)
+			break
+		}
+		gw.indexPaymentStatus(req.CorrelationID, paymentPending, req.RequestedAt)
+		response.Moved++
+	}
+	response.Remaining = gw.dlqLength()
+
+	gw.logf(ctx, "Requeue da DLQ: %d movidos, %d já processados, %d restantes", response.Moved, response.AlreadyProcessed, response.Remaining)
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/config"
+)
+
+// Resolução dos hosts dos processors (PROCESSOR_DNS_REFRESH): o dialer padrão
+// resolve a cada conexão e fica com o primeiro endereço que aceitar, o que põe
+// todas as conexões no mesmo IP quando o host tem vários registros, e o pool
+// keep-alive continua nos IPs antigos depois de uma mudança no DNS. Aqui cada
+// host é resolvido a cada intervalo, as conexões novas alternam entre os
+// endereços e um endereço que recusa a conexão sai do rodízio por
+// PROCESSOR_DNS_FAILURE_COOLDOWN. Quando os endereços mudam, as conexões ociosas
+// são fechadas para o pool se redistribuir.
+
+// dnsState é o estado da resolução dos processors
+type dnsState struct {
+	// Nil com PROCESSOR_DNS_REFRESH=0
+	processorDNS *dnsDialer
+}
+
+type dnsDialer struct {
+	dialer   *net.Dialer
+	resolver *net.Resolver
+	clock    clock.Clock
+	cooldown time.Duration
+	// Chamada quando os endereços de um host mudam
+	onChange func()
+
+	mu    sync.Mutex
+	hosts map[string]*dnsHost
+}
+
+type dnsHost struct {
+	// Trocado inteiro na resolução, com mu
+	addrs []*dnsAddr
+	next  atomic.Uint64
+}
+
+// dnsAddr é um endereço de um host, com a saúde das conexões a ele.
+type dnsAddr struct {
+	ip string
+	// Fim do cooldown, em ns Unix; 0 quando disponível
+	downUntil atomic.Int64
+	dials     atomic.Int64
+	failures  atomic.Int64
+}
+
+func (gw *gateway) registerDns() {
+	gw.registerMetric(metric{
+		Name: "processor_dns_addresses",
+		Help: "Endereços resolvidos por host de processor, pelo estado (up ou cooldown).",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			var samples []metricSample
+			gw.processorDNS.each(func(host string, a *dnsAddr) {
+				state, up := "up", 1.0
+				if a.downUntil.Load() > gw.appClock.Now().UnixNano() {
+					state, up = "cooldown", 0
+				}
+				samples = append(samples, metricSample{Labels: map[string]string{"host": host, "ip": a.ip, "state": state}, Value: up})
+			})
+			return samples
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "processor_dns_dials_total",
+		Help: "Conexões abertas com cada endereço dos processors, pelo resultado.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			var samples []metricSample
+			gw.processorDNS.each(func(host string, a *dnsAddr) {
+				failures := a.failures.Load()
+				samples = append(samples,
+					metricSample{Labels: map[string]string{"host": host, "ip": a.ip, "result": "ok"}, Value: float64(a.dials.Load() - failures)},
+					metricSample{Labels: map[string]string{"host": host, "ip": a.ip, "result": "error"}, Value: float64(failures)},
+				)
+			})
+			return samples
+		},
+	})
+}
+
+// initProcessorDNS cria o dialer dos processors; nil com DNSRefresh zero.
+func (gw *gateway) initProcessorDNS(cfg config.HTTPClientConfig, onChange func()) *dnsDialer {
+	if cfg.DNSRefresh == 0 {
+		return nil
+	}
+	d := &dnsDialer{
+		// Os mesmos limites do dialer do http.DefaultTransport
+		dialer:   &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		resolver: net.DefaultResolver,
+		clock:    gw.appClock,
+		cooldown: cfg.DNSFailureCooldown.Std(),
+		onChange: onChange,
+		hosts:    make(map[string]*dnsHost),
+	}
+	go func() {
+		ticker := time.NewTicker(cfg.DNSRefresh.Std())
+		defer ticker.Stop()
+
+		for range ticker.C {
+			d.refreshAll()
+		}
+	}()
+	gw.processorDNS = d
+	log.Printf("Processors reresolvidos a cada %s, com rodízio entre os endereços", cfg.DNSRefresh.Std())
+	return d
+}
+
+// DialContext conecta ao próximo endereço do rodízio, passando aos seguintes
+// quando um recusa; os em cooldown só são tentados se nenhum outro aceitar.
+func (d *dnsDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+	h, addrs, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	now := d.clock.Now()
+	start := int(h.next.Add(1) % uint64(len(addrs)))
+	order := make([]*dnsAddr, 0, len(addrs))
+	var cooling []*dnsAddr
+	for i := range addrs {
+		a := addrs[(start+i)%len(addrs)]
+		if a.downUntil.Load() > now.UnixNano() {
+			cooling = append(cooling, a)
+		} else {
+			order = append(order, a)
+		}
+	}
+	order = append(order, cooling...)
+
+	var firstErr error
+	for _, a := range order {
+		a.dials.Add(1)
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(a.ip, port))
+		if err == nil {
+			a.downUntil.Store(0)
+			return conn, nil
+		}
+		a.failures.Add(1)
+		a.downUntil.Store(d.clock.Now().Add(d.cooldown).UnixNano())
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// lookup retorna os endereços do host, resolvendo na primeira conexão.
+func (d *dnsDialer) lookup(ctx context.Context, host string) (*dnsHost, []*dnsAddr, error) {
+	d.mu.Lock()
+	h := d.hosts[host]
+	if h != nil {
+		addrs := h.addrs
+		d.mu.Unlock()
+		return h, addrs, nil
+	}
+	d.mu.Unlock()
+
+	if err := d.resolve(ctx, host); err != nil {
+		return nil, nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h = d.hosts[host]
+	return h, h.addrs, nil
+}
+
+// resolve atualiza os endereços do host, mantendo a saúde dos que continuam.
+func (d *dnsDialer) resolve(ctx context.Context, host string) error {
+	ips, err := d.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return err
+	}
+	slices.Sort(ips)
+
+	d.mu.Lock()
+	h := d.hosts[host]
+	first := h == nil
+	if first {
+		h = &dnsHost{}
+		d.hosts[host] = h
+	}
+	previous := make([]string, len(h.addrs))
+	known := make(map[string]*dnsAddr, len(h.addrs))
+	for i, a := range h.addrs {
+		previous[i] = a.ip
+		known[a.ip] = a
+	}
+	changed := !slices.Equal(previous, ips)
+	if changed {
+		addrs := make([]*dnsAddr, len(ips))
+		for i, ip := range ips {
+			if addrs[i] = known[ip]; addrs[i] == nil {
+				addrs[i] = &dnsAddr{ip: ip}
+			}
+		}
+		h.addrs = addrs
+	}
+	d.mu.Unlock()
+
+	if changed && !first {
+		log.Printf("Endereços de %s mudaram: %v -> %v", host, previous, ips)
+		if d.onChange != nil {
+			d.onChange()
+		}
+	}
+	return nil
+}
+
+// refreshAll reresolve os hosts já usados; numa falha, os endereços anteriores
+// continuam valendo.
+func (d *dnsDialer) refreshAll() {
+	d.mu.Lock()
+	hosts := make([]string, 0, len(d.hosts))
+	for host := range d.hosts {
+		hosts = append(hosts, host)
+	}
+	d.mu.Unlock()
+
+	for _, host := range hosts {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := d.resolve(ctx, host); err != nil {
+			log.Printf("Erro ao reresolver %s: %v", host, err)
+		}
+		cancel()
+	}
+}
+
+// each percorre os endereços de todos os hosts; nada sem o dialer.
+func (d *dnsDialer) each(fn func(host string, a *dnsAddr)) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for host, h := range d.hosts {
+		for _, a := range h.addrs {
+			fn(host, a)
+		}
+	}
+}
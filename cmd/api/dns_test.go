@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+)
+
+// TestDNSCooldownFollowsAppClock confere que o endereço que recusou a conexão
+// fica no fim do rodízio até o relógio da aplicação passar do cooldown.
+func TestDNSCooldownFollowsAppClock(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	fake := clock.NewFake(time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC))
+	// 127.0.0.2 não tem ninguém escutando na porta: recusa na hora
+	up, down := &dnsAddr{ip: "127.0.0.1"}, &dnsAddr{ip: "127.0.0.2"}
+	d := &dnsDialer{
+		dialer:   &net.Dialer{Timeout: time.Second},
+		clock:    fake,
+		cooldown: 5 * time.Second,
+		hosts:    map[string]*dnsHost{"processor": {addrs: []*dnsAddr{up, down}}},
+	}
+	dial := func() {
+		t.Helper()
+		conn, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("processor", port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+
+	// O rodízio começa pelo segundo endereço, que recusa e entra em cooldown
+	dial()
+	if down.dials.Load() != 1 || down.failures.Load() != 1 {
+		t.Fatalf("%d tentativas e %d falhas no endereço fora do ar, esperado 1 e 1", down.dials.Load(), down.failures.Load())
+	}
+	// Na vez dele de novo, ainda no cooldown, vai para o fim e não é tentado
+	dial()
+	dial()
+	if got := down.dials.Load(); got != 1 {
+		t.Fatalf("%d tentativas durante o cooldown, esperado 1", got)
+	}
+
+	fake.Advance(5 * time.Second)
+	dial()
+	dial()
+	if got := down.dials.Load(); got != 2 {
+		t.Errorf("%d tentativas depois do cooldown, esperado 2", got)
+	}
+}
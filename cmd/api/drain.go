@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Encerramento sem 502 no nginx: no sinal, a readiness falha e as respostas
+// passam a levar Connection: close, para o nginx não reaproveitar a conexão com
+// a instância que está saindo. Depois de SERVER_PRE_STOP_DELAY, tempo de o
+// balanceador notar, o servidor drena o que estiver em andamento.
+
+// drainState é o estado do encerramento
+type drainState struct {
+	// Requisições HTTP em andamento nas portas TCP e no socket
+	httpInFlight atomic.Int64
+}
+
+func (gw *gateway) registerDrain() {
+	gw.registerMetric(metric{
+		Name: "http_requests_in_flight",
+		Help: "Requisições HTTP em andamento, streams abertos incluídos; zera ao fim da drenagem.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.httpInFlight.Load())}}
+		},
+	})
+}
+
+// drainHandler conta as requisições em andamento e, no encerramento, pede ao
+// cliente que feche a conexão depois da resposta.
+func (gw *gateway) drainHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gw.httpInFlight.Add(1)
+		defer gw.httpInFlight.Add(-1)
+		if gw.appShuttingDown.Load() {
+			w.Header().Set("Connection", "close")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// waitPreStop aguarda o pre-stop com a readiness já falhando, antes da drenagem.
+func (gw *gateway) waitPreStop(delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	log.Printf("Readiness em falha; drenando em %s (%d requisições em andamento)", delay, gw.httpInFlight.Load())
+	time.Sleep(delay)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pp "rinha-backend-2025/internal/processor"
+)
+
+// Correlation IDs repetidos (RETRY_DUPLICATE_STATUS): uma tentativa sem resposta
+// pode ter sido aceita, e o retry recebe do processor um 409/422 por repetir o
+// correlationId. Tratado como falha, o pagamento seguiria para o próximo
+// processor e seria cobrado duas vezes. O processor que recusou é conferido com
+// GET /payments/{id}: se ele tem o pagamento, este conta como aceito por ele,
+// com o valor e o requestedAt que ele registrou, e o veredito fica guardado para
+// as próximas aparições do mesmo correlationId (requeue, redrive, repetição do
+// cliente), que já não são reenviadas.
+
+// Tempo que um veredito fica guardado, maior que a janela de repetições do cliente
+const duplicateVerdictTTL = 10 * time.Minute
+
+// Resultados da conferência de um status de repetição, na ordem da métrica
+const (
+	duplicateFound = iota
+	duplicateNotFound
+	duplicateError
+	duplicateCached
+)
+
+var (
+	duplicateResultNames = [...]string{
+		duplicateFound:    "found",
+		duplicateNotFound: "not_found",
+		duplicateError:    "error",
+		duplicateCached:   "cached",
+	}
+)
+
+// duplicatesState é o estado dos vereditos, por correlationId
+type duplicatesState struct {
+	duplicateResults     [len(duplicateResultNames)]atomic.Int64
+	duplicateVerdicts    map[string]duplicateVerdict
+	duplicateVerdictsMux sync.Mutex
+}
+
+// duplicateVerdict é o pagamento como o processor dono o registrou.
+type duplicateVerdict struct {
+	Processor   string
+	Amount      float64
+	RequestedAt time.Time
+	epoch       int64
+	expires     time.Time
+}
+
+func (gw *gateway) registerDuplicates() {
+	gw.registerMetric(metric{
+		Name: "payment_duplicate_responses_total",
+		Help: "Respostas de correlationId repetido dos processors, por resultado da conferência (cached: veredito já guardado).",
+		Type: "counter",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, len(duplicateResultNames))
+			for i, name := range duplicateResultNames {
+				samples[i] = metricSample{
+					Labels: map[string]string{"result": name},
+					Value:  float64(gw.duplicateResults[i].Load()),
+				}
+			}
+			return samples
+		},
+	})
+}
+
+// cachedDuplicate retorna o veredito guardado do correlationId, se houver um da
+// época atual.
+func (gw *gateway) cachedDuplicate(correlationID string) (duplicateVerdict, bool) {
+	gw.duplicateVerdictsMux.Lock()
+	defer gw.duplicateVerdictsMux.Unlock()
+
+	verdict, ok := gw.duplicateVerdicts[correlationID]
+	if !ok {
+		return duplicateVerdict{}, false
+	}
+	// Depois de um purge, o mesmo correlationId é um pagamento novo
+	if verdict.epoch != gw.currentEpoch() || !gw.appClock.Now().Before(verdict.expires) {
+		delete(gw.duplicateVerdicts, correlationID)
+		return duplicateVerdict{}, false
+	}
+	return verdict, true
+}
+
+func (gw *gateway) storeDuplicate(correlationID string, verdict duplicateVerdict) {
+	gw.duplicateVerdictsMux.Lock()
+	defer gw.duplicateVerdictsMux.Unlock()
+
+	now := gw.appClock.Now()
+	if _, ok := gw.duplicateVerdicts[correlationID]; !ok && len(gw.duplicateVerdicts) >= gw.currentConfig().Memory.MaxDuplicateEntries {
+		gw.evictDuplicatesLocked(now)
+	}
+	verdict.epoch, verdict.expires = gw.currentEpoch(), now.Add(duplicateVerdictTTL)
+	gw.duplicateVerdicts[correlationID] = verdict
+}
+
+// evictDuplicatesLocked abre espaço no teto (MEMORY_MAX_DUPLICATE_ENTRIES):
+// saem os vencidos e, sem nenhum, um veredito qualquer, que volta a ser
+// conferido no processor se o correlationId reaparecer.
+func (gw *gateway) evictDuplicatesLocked(now time.Time) {
+	removed := false
+	for correlationID, verdict := range gw.duplicateVerdicts {
+		if !now.Before(verdict.expires) {
+			delete(gw.duplicateVerdicts, correlationID)
+			removed = true
+		}
+	}
+	if removed {
+		return
+	}
+	for correlationID := range gw.duplicateVerdicts {
+		delete(gw.duplicateVerdicts, correlationID)
+		gw.memoryEvicted[evictedDuplicates].Add(1)
+		return
+	}
+}
+
+// resolveDuplicate confere no processor o pagamento que ele recusou por repetido.
+// sendSucceeded com o registro dele; sendFailed se ele não o tem (o status era
+// outra recusa); sendUnknown, com o outbox, se a consulta falhar. Sem outbox, a
+// falha na consulta deixa a tentativa como ambígua, para a conferência antes da
+// falha.
+func (gw *gateway) resolveDuplicate(ctx context.Context, correlationID, processor string) (duplicateVerdict, sendResult) {
+	if verdict, ok := gw.cachedDuplicate(correlationID); ok {
+		gw.duplicateResults[duplicateCached].Add(1)
+		return verdict, sendSucceeded
+	}
+
+	// Roda mesmo com o orçamento do pagamento esgotado, como a conferência antes da falha
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), gw.currentConfig().HTTP.AttemptTimeout.Std())
+	defer cancel()
+	payment, err := gw.processorClients[processor].GetPayment(ctx, correlationID)
+	switch {
+	case err == nil:
+		gw.duplicateResults[duplicateFound].Add(1)
+		verdict := duplicateVerdict{Processor: processor, Amount: payment.Amount, RequestedAt: payment.RequestedAt.UTC()}
+		gw.storeDuplicate(correlationID, verdict)
+		gw.recordAudit(AuditEntry{CorrelationID: correlationID, Event: auditVerified, Processor: processor})
+		gw.logf(ctx, "Pagamento %s já estava no %s, contado como aceito por ele", correlationID, processor)
+		return verdict, sendSucceeded
+	case errors.Is(err, pp.ErrNotFound):
+		gw.duplicateResults[duplicateNotFound].Add(1)
+		gw.logf(ctx, "Pagamento %s recusado pelo %s e ausente nele", correlationID, processor)
+		return duplicateVerdict{}, sendFailed
+	default:
+		gw.duplicateResults[duplicateError].Add(1)
+		gw.logf(ctx, "Erro ao conferir o repetido %s no %s: %v", correlationID, processor, err)
+		if gw.outboxEnabled {
+			return duplicateVerdict{Processor: processor}, sendUnknown
+		}
+		markAmbiguous(ctx, processor)
+		return duplicateVerdict{}, sendFailed
+	}
+}
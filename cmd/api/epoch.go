@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/storage"
+)
+
+// Época dos contadores: cada purge começa uma, e um pagamento confirmado só conta
+// na época em que o envio começou. Sem isso, um worker no meio do envio durante o
+// purge somaria depois dele um pagamento que o purge já apagou. Com o storage
+// versionado (o Redis), a época é a do storage, e os purges das outras instâncias
+// chegam pelo epochSyncInterval; nos demais, ela é local.
+
+// Intervalo entre as leituras da época do storage
+const epochSyncInterval = time.Second
+
+// epochState é o estado da época
+type epochState struct {
+	// Época dos pendentes: alterada só com flushMux e pendingCountersMux
+	paymentEpoch atomic.Int64
+	// Confirmados depois de um purge, em um envio começado antes dele
+	stalePayments atomic.Int64
+}
+
+func (gw *gateway) registerEpoch() {
+	gw.registerMetric(metric{
+		Name: "counter_stale_epoch_total",
+		Help: "Pagamentos confirmados depois de um purge, em envios começados antes dele, ignorados no resumo.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.stalePayments.Load())}}
+		},
+	})
+}
+
+// currentEpoch é a época a gravar no storage.Record de um envio que começa agora.
+func (gw *gateway) currentEpoch() int64 {
+	return gw.paymentEpoch.Load()
+}
+
+// purgedEpoch é a época depois do purge do storage.
+func (gw *gateway) purgedEpoch() int64 {
+	if versioned, ok := gw.store.(storage.Versioned); ok {
+		return versioned.Epoch()
+	}
+	return gw.paymentEpoch.Load() + 1
+}
+
+// loadEpoch lê a época do storage na subida, antes do replay do WAL.
+func (gw *gateway) loadEpoch(ctx context.Context) {
+	versioned, ok := gw.store.(storage.Versioned)
+	if !ok {
+		return
+	}
+	epoch, err := versioned.SyncEpoch(ctx)
+	if err != nil {
+		log.Printf("Aviso: erro ao ler a época do storage: %v", err)
+		epoch = versioned.Epoch()
+	}
+	gw.paymentEpoch.Store(epoch)
+}
+
+// startEpochSync acompanha os purges das outras instâncias.
+func (gw *gateway) startEpochSync() {
+	versioned, ok := gw.store.(storage.Versioned)
+	if !ok {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(epochSyncInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			gw.syncEpoch(versioned)
+		}
+	}()
+}
+
+func (gw *gateway) syncEpoch(versioned storage.Versioned) {
+	// Com o lado de leitura, um purge desta instância não corre em paralelo
+	gw.counterFlushGate.RLock()
+	defer gw.counterFlushGate.RUnlock()
+
+	epoch, err := versioned.SyncEpoch(context.Background())
+	if err != nil {
+		log.Printf("Erro ao ler a época do storage: %v", err)
+		return
+	}
+	if previous := gw.paymentEpoch.Load(); epoch != previous {
+		gw.discardPendingCounters(epoch)
+		gw.paymentsSummaryCache.invalidate()
+		log.Printf("Storage na época %d (era %d): contadores pendentes da anterior descartados", epoch, previous)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/events"
+)
+
+// Barramento de eventos do pagamento: o caminho crítico publica as etapas e
+// segue, sem conhecer quem as consome. Webhooks, auditoria, o stream SSE e as
+// métricas se inscrevem no init() do próprio arquivo com onPaymentEvent, e um
+// recurso novo só precisa fazer o mesmo.
+
+// PaymentEventKind é a etapa do pagamento publicada no barramento.
+type PaymentEventKind int
+
+const (
+	// Pagamento válido aceito pela API
+	PaymentReceived PaymentEventKind = iota
+	// Enviado a um processor (uma vez por processor tentado)
+	PaymentRouted
+	// Confirmado por um processor e contabilizado
+	PaymentSettled
+	// Recusado por todos os processors e estacionado na DLQ
+	PaymentFailed
+)
+
+var paymentEventNames = [...]string{
+	PaymentReceived: "received",
+	PaymentRouted:   "routed",
+	PaymentSettled:  "settled",
+	PaymentFailed:   "failed",
+}
+
+func (k PaymentEventKind) String() string {
+	return paymentEventNames[k]
+}
+
+// BusEvent é uma etapa de um pagamento. Processor fica vazio em Received e Failed.
+type BusEvent struct {
+	Kind      PaymentEventKind
+	Payment   PaymentRequest
+	Processor string
+	At        time.Time
+}
+
+type paymentEventHandler struct {
+	name string
+	fn   func(BusEvent)
+}
+
+// eventsState é o estado do barramento
+type eventsState struct {
+	paymentEventHandlers []paymentEventHandler
+	paymentBus           *events.Bus[BusEvent]
+
+	paymentEventCounts [len(paymentEventNames)]atomic.Int64
+}
+
+// onPaymentEvent inscreve fn no barramento; chamado no init() de cada recurso.
+// fn roda na goroutine do barramento e não deve bloquear.
+func (gw *gateway) onPaymentEvent(name string, fn func(BusEvent)) {
+	gw.paymentEventHandlers = append(gw.paymentEventHandlers, paymentEventHandler{name: name, fn: fn})
+}
+
+func (gw *gateway) registerEvents() {
+	gw.onPaymentEvent("metrics", func(e BusEvent) {
+		gw.paymentEventCounts[e.Kind].Add(1)
+	})
+
+	gw.registerMetric(metric{
+		Name: "payment_events_total",
+		Help: "Etapas de pagamento entregues pelo barramento de eventos, por tipo.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, len(paymentEventNames))
+			for kind, name := range paymentEventNames {
+				samples[kind] = metricSample{
+					Labels: map[string]string{"event": name},
+					Value:  float64(gw.paymentEventCounts[kind].Load()),
+				}
+			}
+			return samples
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "event_bus_queue_depth",
+		Help: "Eventos aguardando entrega no barramento.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			if gw.paymentBus == nil {
+				return nil
+			}
+			return []metricSample{{Value: float64(gw.paymentBus.Depth())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "event_bus_dropped_total",
+		Help: "Eventos descartados com o buffer do barramento cheio; os inscritos não os recebem.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			if gw.paymentBus == nil {
+				return nil
+			}
+			return []metricSample{{Value: float64(gw.paymentBus.Dropped())}}
+		},
+	})
+}
+
+func (gw *gateway) startEventBus(cfg config.EventsConfig) {
+	gw.paymentBus = events.New[BusEvent](cfg.BufferSize)
+	for _, h := range gw.paymentEventHandlers {
+		gw.paymentBus.Subscribe(h.name, h.fn)
+	}
+	gw.paymentBus.Start()
+	log.Printf("Barramento de eventos: %d inscritos, buffer de %d eventos", len(gw.paymentEventHandlers), cfg.BufferSize)
+}
+
+// stopEventBus entrega os eventos pendentes antes dos webhooks e da auditoria
+// encerrarem.
+func (gw *gateway) stopEventBus(ctx context.Context) {
+	gw.paymentBus.Close(ctx)
+}
+
+// publishEvent publica a etapa do pagamento sem bloquear.
+func (gw *gateway) publishEvent(kind PaymentEventKind, req PaymentRequest, processor string) {
+	gw.paymentBus.Publish(BusEvent{Kind: kind, Payment: req, Processor: processor, At: gw.appClock.Now().UTC()})
+}
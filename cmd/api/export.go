@@ -0,0 +1,166 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rinha-backend-2025/internal/storage"
+)
+
+// Situação de cada linha da exportação
+const (
+	exportProcessed = "processed"
+	exportFailed    = "failed"
+)
+
+// Linhas escritas entre um flush e outro da resposta
+const exportFlushEvery = 500
+
+// exportRow é uma linha de GET /admin/payments/export.
+type exportRow struct {
+	CorrelationID string
+	Amount        float64
+	Processor     string
+	RequestedAt   time.Time
+	Status        string
+}
+
+// exportWriter escreve as linhas em um dos formatos aceitos.
+type exportWriter interface {
+	Write(row exportRow) error
+	Flush() error
+}
+
+// handleAdminPaymentsExport transmite os pagamentos registrados (e os que estão
+// na DLQ, como failed) com requestedAt em [from, to], em CSV ou NDJSON. A
+// resposta vai em chunks conforme o storage é percorrido, sem montar a lista em
+// memória.
+func (gw *gateway) handleAdminPaymentsExport(c *gin.Context) {
+	from, to, err := parseSummaryRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, errMessage(err))
+		return
+	}
+	// Limites ausentes cobrem todo o histórico
+	if to.IsZero() {
+		to = gw.appClock.Now().Add(time.Hour)
+	}
+
+	exporter, ok := gw.store.(storage.Exporter)
+	if !ok {
+		respondError(c, http.StatusNotImplemented, errCodeNotImplemented, msg(msgNoExport))
+		return
+	}
+
+	var writer exportWriter
+	switch format := c.DefaultQuery("format", "csv"); format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="payments.csv"`)
+		writer = newCSVExportWriter(c.Writer)
+	case "ndjson":
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="payments.ndjson"`)
+		writer = &ndjsonExportWriter{w: c.Writer}
+	default:
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidFormat))
+		return
+	}
+
+	// Incluir os pagamentos ainda pendentes nesta instância
+	gw.flushCounters()
+
+	c.Status(http.StatusOK)
+	rows := 0
+	emit := func(row exportRow) error {
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushEvery == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	}
+
+	ctx := c.Request.Context()
+	err = exporter.ExportByRange(ctx, from, to, func(r storage.Record) error {
+		return emit(exportRow{r.CorrelationID, r.Amount, r.Processor, r.RequestedAt, exportProcessed})
+	})
+	if err == nil {
+		err = gw.eachDLQ(ctx, func(d DeadLetter) error {
+			if d.RequestedAt.Before(from) || d.RequestedAt.After(to) {
+				return nil
+			}
+			return emit(exportRow{d.CorrelationID, d.Amount, "", d.RequestedAt, exportFailed})
+		})
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		// O status já foi enviado: só resta interromper a resposta
+		gw.logf(ctx, "Exportação de pagamentos interrompida após %d linhas: %v", rows, err)
+	}
+}
+
+type csvExportWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVExportWriter(w http.ResponseWriter) *csvExportWriter {
+	writer := &csvExportWriter{w: csv.NewWriter(w), record: make([]string, 5)}
+	writer.w.Write([]string{"correlationId", "amount", "processor", "requestedAt", "status"})
+	return writer
+}
+
+func (e *csvExportWriter) Write(row exportRow) error {
+	e.record[0] = row.CorrelationID
+	e.record[1] = strconv.FormatFloat(row.Amount, 'f', -1, 64)
+	e.record[2] = row.Processor
+	e.record[3] = row.RequestedAt.UTC().Format(time.RFC3339Nano)
+	e.record[4] = row.Status
+	return e.w.Write(e.record)
+}
+
+func (e *csvExportWriter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonExportWriter struct {
+	w   http.ResponseWriter
+	buf []byte
+}
+
+func (e *ndjsonExportWriter) Write(row exportRow) error {
+	buf := append(e.buf[:0], `{"correlationId":`...)
+	buf = appendJSONString(buf, row.CorrelationID)
+	buf = append(buf, `,"amount":`...)
+	buf = strconv.AppendFloat(buf, row.Amount, 'f', -1, 64)
+	buf = append(buf, `,"processor":`...)
+	buf = appendJSONString(buf, row.Processor)
+	buf = append(buf, `,"requestedAt":"`...)
+	buf = row.RequestedAt.UTC().AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, `","status":`...)
+	buf = appendJSONString(buf, row.Status)
+	buf = append(buf, "}\n"...)
+	e.buf = buf
+
+	_, err := e.w.Write(buf)
+	return err
+}
+
+func (e *ndjsonExportWriter) Flush() error {
+	return nil
+}
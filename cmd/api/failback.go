@@ -0,0 +1,131 @@
+package main
+
+import (
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Failback: enquanto o tráfego está longe do processor de maior prioridade, uma
+// fração dos pagamentos (FAILBACK_PROBE_RATE) o tenta primeiro, com os demais da
+// ordem como rede de segurança. Depois de FAILBACK_SUCCESSES sucessos seguidos
+// dele, o failing do último health-check deixa de valer e o tráfego volta sem
+// esperar a próxima consulta. Um health-check posterior continua valendo.
+
+// failbackState é o estado do failback
+type failbackState struct {
+	failbackProbeRate float64
+	failbackSuccesses int
+
+	failbackMux sync.Mutex
+	// O preferido não está em primeiro na ordem
+	failbackDiverted bool
+	failbackStreak   int
+	// Quando o preferido foi considerado recuperado
+	failbackRecoveredAt time.Time
+
+	failbackProbes     atomic.Int64
+	failbackRecoveries atomic.Int64
+}
+
+func (gw *gateway) registerFailback() {
+	gw.registerMetric(metric{
+		Name: "failback_probes_total",
+		Help: "Pagamentos enviados primeiro ao processor preferido enquanto o tráfego estava em outro.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.failbackProbes.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "failback_recoveries_total",
+		Help: "Vezes em que o processor preferido voltou a receber o tráfego pelo failback.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.failbackRecoveries.Load())}}
+		},
+	})
+}
+
+func (gw *gateway) initFailback(cfg config.FailbackConfig) {
+	if len(gw.processorNames) < 2 {
+		return
+	}
+	gw.failbackProbeRate = cfg.ProbeRate
+	gw.failbackSuccesses = cfg.Successes
+}
+
+// preferredProcessor é o de maior prioridade, para onde o failback devolve o tráfego.
+func (gw *gateway) preferredProcessor() string {
+	return gw.processorNames[0]
+}
+
+// failbackRecovered indica que o preferido se recuperou depois do health-check
+// feito em checkedAt.
+func (gw *gateway) failbackRecovered(checkedAt time.Time) bool {
+	gw.failbackMux.Lock()
+	defer gw.failbackMux.Unlock()
+	return gw.failbackRecoveredAt.After(checkedAt)
+}
+
+// applyFailback recebe a ordem do selector e, de vez em quando, coloca o
+// preferido na frente enquanto o tráfego está em outro processor.
+func (gw *gateway) applyFailback(ranking []string) []string {
+	if gw.failbackProbeRate == 0 {
+		return ranking
+	}
+	preferred := gw.preferredProcessor()
+	diverted := ranking[0] != preferred
+
+	gw.failbackMux.Lock()
+	if diverted != gw.failbackDiverted {
+		gw.failbackDiverted = diverted
+		gw.failbackStreak = 0
+	}
+	gw.failbackMux.Unlock()
+
+	if !diverted || rand.Float64() >= gw.failbackProbeRate {
+		return ranking
+	}
+
+	gw.failbackProbes.Add(1)
+	probe := make([]string, 0, len(ranking))
+	probe = append(probe, preferred)
+	for _, name := range ranking {
+		if name != preferred {
+			probe = append(probe, name)
+		}
+	}
+	return probe
+}
+
+// observeFailback conta as tentativas no preferido feitas com o tráfego desviado.
+func (gw *gateway) observeFailback(processor string, ok bool) {
+	if gw.failbackProbeRate == 0 || processor != gw.preferredProcessor() {
+		return
+	}
+
+	gw.failbackMux.Lock()
+	defer gw.failbackMux.Unlock()
+	if !gw.failbackDiverted {
+		return
+	}
+	if !ok {
+		gw.failbackStreak = 0
+		return
+	}
+	gw.failbackStreak++
+	if gw.failbackStreak < gw.failbackSuccesses {
+		return
+	}
+
+	gw.failbackRecoveredAt = gw.appClock.Now()
+	gw.failbackDiverted = false
+	gw.failbackStreak = 0
+	gw.failbackRecoveries.Add(1)
+	log.Printf("Processor %s respondeu %d vezes seguidas, voltando a receber o tráfego", processor, gw.failbackSuccesses)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Orçamento do fallback (FALLBACK_BUDGET_*): a taxa maior dos processors fora do
+// preferido come o lucro, então o valor enviado a eles numa janela deslizante tem
+// teto, absoluto e/ou em fração do volume aceito. O pagamento que estouraria o
+// teto não é enviado: volta para a fila e espera o preferido se recuperar ou a
+// janela andar. O valor é reservado antes do envio e devolvido se o fallback não
+// aceitar; um resultado desconhecido mantém a reserva.
+
+// fallbackBudgetState é o estado do orçamento do fallback
+type fallbackBudgetState struct {
+	fallbackBudgetMux sync.Mutex
+	// Baldes de um segundo da janela, indexados pelo segundo Unix
+	fallbackBudgetBuckets []fallbackBudgetBucket
+
+	fallbackDeferred atomic.Int64
+}
+
+type fallbackBudgetBucket struct {
+	second int64
+	// Valor reservado para o fallback e valor aceito por qualquer processor
+	fallback float64
+	total    float64
+}
+
+// fallbackReservation é o valor reservado para um envio ao fallback; o zero não
+// reserva nada.
+type fallbackReservation struct {
+	second int64
+	amount float64
+}
+
+func (gw *gateway) registerFallbackBudget() {
+	gw.registerMetric(metric{
+		Name: "fallback_budget_amount",
+		Help: "Valor na janela do orçamento do fallback: reservado para o fallback e aceito por todos os processors.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			fallback, total := gw.fallbackBudgetUsage(gw.currentConfig().FallbackBudget, gw.appClock.Now())
+			return []metricSample{
+				{Labels: map[string]string{"kind": "fallback"}, Value: fallback},
+				{Labels: map[string]string{"kind": "total"}, Value: total},
+			}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "fallback_budget_share",
+		Help: "Fração do volume da janela no fallback.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			fallback, total := gw.fallbackBudgetUsage(gw.currentConfig().FallbackBudget, gw.appClock.Now())
+			share := 0.0
+			if total > 0 {
+				share = min(fallback/total, 1)
+			}
+			return []metricSample{{Value: share}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "fallback_budget_limit",
+		Help: "Tetos configurados do orçamento do fallback (0 sem teto ou desativado).",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			cfg := gw.currentConfig().FallbackBudget
+			if !cfg.Enabled {
+				cfg.MaxAmount, cfg.MaxShare = 0, 0
+			}
+			return []metricSample{
+				{Labels: map[string]string{"limit": "amount"}, Value: cfg.MaxAmount},
+				{Labels: map[string]string{"limit": "share"}, Value: cfg.MaxShare},
+			}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "fallback_budget_deferred_total",
+		Help: "Envios ao fallback adiados por estourarem o orçamento.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.fallbackDeferred.Load())}}
+		},
+	})
+}
+
+// reserveFallback reserva amount para o envio ao processor; false quando o
+// orçamento não comporta o pagamento. O preferido nunca consome orçamento.
+func (gw *gateway) reserveFallback(processor string, amount float64) (fallbackReservation, bool) {
+	cfg := gw.currentConfig().FallbackBudget
+	if !cfg.Enabled || processor == gw.preferredProcessor() {
+		return fallbackReservation{}, true
+	}
+
+	now := gw.appClock.Now()
+	gw.fallbackBudgetMux.Lock()
+	defer gw.fallbackBudgetMux.Unlock()
+	fallback, total := gw.fallbackBudgetUsageLocked(cfg, now)
+	if cfg.MaxAmount > 0 && fallback+amount > cfg.MaxAmount {
+		gw.fallbackDeferred.Add(1)
+		return fallbackReservation{}, false
+	}
+	// O próprio pagamento entra nos dois lados
+	if cfg.MaxShare > 0 && fallback+amount > cfg.MaxShare*(total+amount) {
+		gw.fallbackDeferred.Add(1)
+		return fallbackReservation{}, false
+	}
+
+	bucket := gw.fallbackBudgetBucketLocked(cfg, now)
+	bucket.fallback += amount
+	return fallbackReservation{second: bucket.second, amount: amount}, true
+}
+
+// settleFallback devolve a reserva quando o fallback não ficou com o pagamento.
+func (gw *gateway) settleFallback(r fallbackReservation, result sendResult) {
+	if r.amount == 0 || result == sendSucceeded || result == sendUnknown || result == sendDuplicate {
+		return
+	}
+	gw.fallbackBudgetMux.Lock()
+	defer gw.fallbackBudgetMux.Unlock()
+	for i := range gw.fallbackBudgetBuckets {
+		if b := &gw.fallbackBudgetBuckets[i]; b.second == r.second {
+			b.fallback = max(b.fallback-r.amount, 0)
+			return
+		}
+	}
+}
+
+// recordFallbackBudgetVolume soma um pagamento aceito ao volume da janela, base
+// do teto em fração.
+func (gw *gateway) recordFallbackBudgetVolume(amount float64) {
+	cfg := gw.currentConfig().FallbackBudget
+	if !cfg.Enabled {
+		return
+	}
+	gw.fallbackBudgetMux.Lock()
+	gw.fallbackBudgetBucketLocked(cfg, gw.appClock.Now()).total += amount
+	gw.fallbackBudgetMux.Unlock()
+}
+
+// fallbackBudgetBucketLocked retorna o balde do segundo atual, reaproveitando o
+// que saiu da janela. Uma janela recarregada com outro tamanho recomeça vazia.
+func (gw *gateway) fallbackBudgetBucketLocked(cfg config.FallbackBudgetConfig, now time.Time) *fallbackBudgetBucket {
+	n := fallbackBudgetWindow(cfg)
+	if len(gw.fallbackBudgetBuckets) != n {
+		gw.fallbackBudgetBuckets = make([]fallbackBudgetBucket, n)
+	}
+	second := now.Unix()
+	bucket := &gw.fallbackBudgetBuckets[second%int64(n)]
+	if bucket.second != second {
+		*bucket = fallbackBudgetBucket{second: second}
+	}
+	return bucket
+}
+
+func (gw *gateway) fallbackBudgetUsage(cfg config.FallbackBudgetConfig, now time.Time) (fallback, total float64) {
+	gw.fallbackBudgetMux.Lock()
+	defer gw.fallbackBudgetMux.Unlock()
+	return gw.fallbackBudgetUsageLocked(cfg, now)
+}
+
+func (gw *gateway) fallbackBudgetUsageLocked(cfg config.FallbackBudgetConfig, now time.Time) (fallback, total float64) {
+	oldest := now.Unix() - int64(fallbackBudgetWindow(cfg)) + 1
+	for _, b := range gw.fallbackBudgetBuckets {
+		if b.second >= oldest {
+			fallback += b.fallback
+			total += b.total
+		}
+	}
+	return fallback, total
+}
+
+// fallbackBudgetWindow é o número de baldes de um segundo da janela.
+func fallbackBudgetWindow(cfg config.FallbackBudgetConfig) int {
+	return max(int(cfg.Window.Std()/time.Second), 1)
+}
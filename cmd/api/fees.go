@@ -0,0 +1,75 @@
+package main
+
+import "strconv"
+
+// DetailedSummaryResponse é o resumo de GET /payments-summary?detailed=true: o
+// formato da Rinha acrescido das taxas, para acompanhar o lucro durante o teste.
+// As taxas são o totalAmount vezes a taxa configurada de cada processor, então
+// valem igualmente para os contadores e para as consultas por período.
+type DetailedSummaryResponse struct {
+	Processors map[string]DetailedProcessorSummary
+	Order      []string
+	Total      DetailedProcessorSummary
+}
+
+type DetailedProcessorSummary struct {
+	TotalRequests int
+	TotalAmount   float64
+	FeeRate       float64
+	TotalFees     float64
+}
+
+func (gw *gateway) newDetailedSummary(summary PaymentSummaryResponse) DetailedSummaryResponse {
+	detailed := DetailedSummaryResponse{
+		Processors: make(map[string]DetailedProcessorSummary, len(summary)),
+		Order:      summary.orderedNames(),
+	}
+	for name, s := range summary {
+		// Processors fora da configuração (default ou fallback vazios) não têm taxa
+		rate := gw.processorDefs[name].Fee
+		current := DetailedProcessorSummary{
+			TotalRequests: s.TotalRequests,
+			TotalAmount:   s.TotalAmount,
+			FeeRate:       rate,
+			TotalFees:     s.TotalAmount * rate,
+		}
+		detailed.Processors[name] = current
+
+		detailed.Total.TotalRequests += current.TotalRequests
+		detailed.Total.TotalAmount += current.TotalAmount
+		detailed.Total.TotalFees += current.TotalFees
+	}
+	return detailed
+}
+
+// appendJSON segue a ordem do resumo simples; total não tem feeRate.
+func (d DetailedSummaryResponse) appendJSON(buf []byte, halfUp bool) []byte {
+	buf = append(buf, `{"processors":{`...)
+	for i, name := range d.Order {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendJSONString(buf, name)
+		buf = append(buf, ':')
+		buf = d.Processors[name].appendJSON(buf, true, halfUp)
+	}
+	buf = append(buf, `},"total":`...)
+	buf = d.Total.appendJSON(buf, false, halfUp)
+	return append(buf, '}')
+}
+
+func (s DetailedProcessorSummary) appendJSON(buf []byte, withRate, halfUp bool) []byte {
+	buf = append(buf, `{"totalRequests":`...)
+	buf = strconv.AppendInt(buf, int64(s.TotalRequests), 10)
+	buf = append(buf, `,"totalAmount":`...)
+	buf = appendAmount(buf, s.TotalAmount, halfUp)
+	if withRate {
+		buf = append(buf, `,"feeRate":`...)
+		buf = strconv.AppendFloat(buf, s.FeeRate, 'f', -1, 64)
+	}
+	buf = append(buf, `,"totalFees":`...)
+	buf = appendAmount(buf, s.TotalFees, halfUp)
+	buf = append(buf, `,"netAmount":`...)
+	buf = appendAmount(buf, s.TotalAmount-s.TotalFees, halfUp)
+	return append(buf, '}')
+}
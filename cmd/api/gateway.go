@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/keyspace"
+	pp "rinha-backend-2025/internal/processor"
+	"rinha-backend-2025/internal/queue"
+	"rinha-backend-2025/internal/retry"
+	"rinha-backend-2025/internal/storage"
+)
+
+// gateway reúne as dependências e o estado de uma instância da API: o cliente
+// dos processors, o storage, a fila, o relógio e, embutido, o estado de cada
+// recurso, declarado no arquivo dele. main monta um só e o passa às rotas; os
+// testes montam quantos precisarem, sem nada compartilhado entre eles.
+type gateway struct {
+	httpClient *http.Client
+	store      storage.Storage
+	// Fila de pagamentos aguardando processamento pelos workers
+	paymentQueue *queue.Pool[PaymentRequest]
+	// Relógio do requestedAt, das esperas entre tentativas, das janelas e dos
+	// cooldowns; um clock.Fake nos testes
+	appClock clock.Clock
+	// Namespace das chaves do Redis (RUN_ID), definido em initRunID antes do
+	// primeiro comando
+	keys keyspace.Space
+
+	auditState
+	authState
+	backpressureState
+	chaosState
+	conflictsState
+	countersState
+	decisionsState
+	dispatchPoolsState
+	dlqState
+	dnsState
+	drainState
+	duplicatesState
+	epochState
+	eventsState
+	failbackState
+	fallbackBudgetState
+	healthHistoryState
+	hedgeState
+	inferenceState
+	instanceState
+	latencyState
+	limiterState
+	loadShedState
+	memoryState
+	metricsState
+	outboxState
+	outcomesState
+	pacingState
+	panicsState
+	peerState
+	pipelineState
+	preforkState
+	processorLimitsState
+	processorsState
+	ratelimitState
+	redisState
+	redisCallsState
+	redisWriterState
+	reloadState
+	requestCodecsState
+	requestedAtState
+	sloState
+	snapshotState
+	statusState
+	statusIndexState
+	streamState
+	summaryCacheState
+	summaryCheckState
+	routerState
+	verifyState
+	verifySampleState
+	walState
+	webhookState
+}
+
+// newGateway cria os mapas e canais de cada recurso e registra as métricas e os
+// inscritos do barramento; httpClient, store, paymentQueue e a configuração vêm
+// depois, na inicialização do main.
+func newGateway(clk clock.Clock) *gateway {
+	gw := &gateway{appClock: clk}
+	gw.preforkIndex = -1
+	gw.pendingCounters = make(map[string]*storage.Delta)
+	gw.counterFlushNow = make(chan struct{}, 1)
+	gw.duplicateVerdicts = make(map[string]duplicateVerdict)
+	gw.healthHistory = make(map[string]*healthHistoryRing)
+	gw.inferenceTrackers = make(map[string]*inferenceTracker)
+	gw.instanceID = hostname()
+	gw.appStartedAt = clk.Now()
+	gw.processorLatency = make(map[string]*latencyStats)
+	gw.processorLimiters = make(map[string]*concurrencyLimiter)
+	gw.memoryOutbox = make(map[string]OutboxEntry)
+	gw.processorOutcomes = make(map[string]*outcomeStats)
+	gw.processorFailureClasses = make(map[string]*[len(retry.ClassNames)]atomic.Int64)
+	gw.processorPacers = make(map[string]*dispatchPacer)
+	gw.paymentPipeline = gw.processPayment
+	gw.processorLimits = make(map[string]*processorLimit)
+	gw.processorDefs = make(map[string]config.ProcessorDef)
+	gw.processorClients = make(map[string]pp.ProcessorClient)
+	gw.localBuckets = make(map[string]*tokenBucket)
+	gw.sloLatency = make(map[string]*latencyStats)
+	gw.streamSubs = make(map[*streamSubscriber]struct{})
+	gw.streamDone = make(chan struct{})
+	gw.paymentsSummaryCache = &summaryCache{clock: gw.appClock}
+	gw.summaryDiscrepancies = make(map[string]summaryDiscrepancy)
+
+	gw.registerAudit()
+	gw.registerAuth()
+	gw.registerBackpressure()
+	gw.registerCancel()
+	gw.registerConflicts()
+	gw.registerCounters()
+	gw.registerDns()
+	gw.registerDrain()
+	gw.registerDuplicates()
+	gw.registerEpoch()
+	gw.registerEvents()
+	gw.registerFailback()
+	gw.registerFallbackBudget()
+	gw.registerHedge()
+	gw.registerLanes()
+	gw.registerLoadShed()
+	gw.registerLogwriter()
+	gw.registerMemory()
+	gw.registerOutbox()
+	gw.registerPanics()
+	gw.registerPeer()
+	gw.registerProcessorLimits()
+	gw.registerRatelimit()
+	gw.registerRedisCalls()
+	gw.registerRedisWriter()
+	gw.registerReload()
+	gw.registerRequestCodecs()
+	gw.registerRequestedAt()
+	gw.registerRuntime()
+	gw.registerSnapshot()
+	gw.registerStatusIndex()
+	gw.registerStream()
+	gw.registerSummaryCheck()
+	gw.registerRouter()
+	gw.registerVerify()
+	gw.registerWebhook()
+	return gw
+}
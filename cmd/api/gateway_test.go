@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/config"
+)
+
+// newTestGateway monta um gateway com a configuração padrão, sem variáveis de
+// ambiente.
+func newTestGateway(t *testing.T, clk clock.Clock) *gateway {
+	t.Helper()
+	gw := newGateway(clk)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.applyConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return gw
+}
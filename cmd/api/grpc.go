@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/paymentspb"
+)
+
+// paymentGRPCServer expõe a mesma fila de processamento e o mesmo storage das
+// rotas HTTP.
+type paymentGRPCServer struct {
+	paymentspb.UnimplementedPaymentServiceServer
+	gw *gateway
+}
+
+func (s paymentGRPCServer) SubmitPayment(ctx context.Context, in *paymentspb.SubmitPaymentRequest) (*paymentspb.SubmitPaymentResponse, error) {
+	locale := grpcLocale(ctx)
+	req := newProtoPaymentRequest(in)
+	if err := s.gw.validatePaymentRequest(req, s.gw.currentConfig().Validation.MaxAmount); err != nil {
+		return nil, status.Error(codes.InvalidArgument, localizedError(locale, err))
+	}
+	if req.CallbackURL != "" && !s.gw.callbackAllowed(req.CallbackURL) {
+		return nil, status.Error(codes.InvalidArgument, msg(msgCallbackNotAllowed).text(locale))
+	}
+	if conflict, ok := s.gw.findAmountConflict(ctx, req); ok {
+		return nil, status.Errorf(codes.AlreadyExists, "%s (%.2f)", msg(msgAmountConflict).text(locale), conflict.OriginalAmount)
+	}
+
+	switch s.gw.acceptPayment(ctx, req, false) {
+	case ackQueued:
+		return &paymentspb.SubmitPaymentResponse{Message: "payment queued"}, nil
+	case ackProcessed:
+		return &paymentspb.SubmitPaymentResponse{Message: "payment processed"}, nil
+	case ackQueueFull:
+		return nil, status.Error(codes.ResourceExhausted, msg(msgQueueFull).text(locale))
+	case ackFailed:
+		return nil, status.Error(codes.Unavailable, msg(msgProcessorsFailed).text(locale))
+	}
+	return &paymentspb.SubmitPaymentResponse{Message: "payment received"}, nil
+}
+
+func (s paymentGRPCServer) GetSummary(ctx context.Context, in *paymentspb.GetSummaryRequest) (*paymentspb.GetSummaryResponse, error) {
+	from, to, err := parseSummaryRange(in.GetFrom(), in.GetTo())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	summary := s.gw.loadPaymentsSummary(from, to, summaryOptions{
+		Consistent: in.GetConsistent(),
+		NoCache:    in.GetNocache(),
+	})
+	response := &paymentspb.GetSummaryResponse{
+		Default:    toProtoSummary(summary["default"]),
+		Fallback:   toProtoSummary(summary["fallback"]),
+		Processors: make(map[string]*paymentspb.ProcessorSummary, len(summary)),
+	}
+	for name, s := range summary {
+		response.Processors[name] = toProtoSummary(s)
+	}
+	return response, nil
+}
+
+// grpcLocale escolhe o idioma das mensagens de erro pelo metadata
+// accept-language, como o Accept-Language nas rotas HTTP.
+func grpcLocale(ctx context.Context) int {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return matchLocale(strings.Join(md.Get("accept-language"), ", "))
+}
+
+// localizedError é o texto de um erro da validação no idioma escolhido.
+func localizedError(locale int, err error) string {
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		return (&ValidationError{Fields: localizeFields(locale, invalid.Fields)}).Error()
+	}
+	return errMessage(err).text(locale)
+}
+
+func toProtoSummary(s ProcessorSummary) *paymentspb.ProcessorSummary {
+	return &paymentspb.ProcessorSummary{
+		TotalRequests: int64(s.TotalRequests),
+		TotalAmount:   s.TotalAmount,
+	}
+}
+
+// startGRPCServer escuta na porta gRPC configurada, com o mesmo certificado da
+// porta HTTP quando há TLS; sem porta, retorna nil.
+func (gw *gateway) startGRPCServer(cfg config.GRPCConfig, tlsConfig *tls.Config) (*grpc.Server, error) {
+	if cfg.Port == "" {
+		return nil, nil
+	}
+
+	ln, err := net.Listen("tcp", "0.0.0.0:"+cfg.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig.Clone())))
+	}
+	srv := grpc.NewServer(opts...)
+	paymentspb.RegisterPaymentServiceServer(srv, paymentGRPCServer{gw: gw})
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Printf("Erro no servidor gRPC: %v", err)
+		}
+	}()
+	log.Printf("Servidor gRPC iniciando na porta %s", cfg.Port)
+	return srv, nil
+}
+
+// stopGRPCServer aguarda as chamadas em andamento até o fim do prazo e então
+// encerra as restantes.
+func stopGRPCServer(ctx context.Context, srv *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Prazo de encerramento do gRPC esgotado, interrompendo chamadas")
+		srv.Stop()
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/paymentspb"
+)
+
+// TestSubmitPaymentFollowsAcceptLanguage confere que os erros do gRPC saem do
+// catálogo no idioma do metadata accept-language, como nas rotas HTTP.
+func TestSubmitPaymentFollowsAcceptLanguage(t *testing.T) {
+	gw := newTestGateway(t, clock.Real())
+	gw.webhookConfig = config.WebhookConfig{AllowedHosts: []string{"hooks.local"}}
+	server := paymentGRPCServer{gw: gw}
+
+	const id = "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+	invalidAmount := &paymentspb.SubmitPaymentRequest{CorrelationId: id, Amount: -1}
+	callbackOutside := &paymentspb.SubmitPaymentRequest{CorrelationId: id, Amount: 19.9, CallbackUrl: "http://127.0.0.1:6379/"}
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		in             *paymentspb.SubmitPaymentRequest
+		wantMessage    string
+	}{
+		{"validação sem metadata", "", invalidAmount, "amount: deve ser maior que zero"},
+		{"validação em inglês", "en-US,en;q=0.9", invalidAmount, "amount: must be greater than zero"},
+		{"validação em idioma não suportado", "fr", invalidAmount, "amount: deve ser maior que zero"},
+		{"callback em inglês", "en", callbackOutside, "callbackUrl host not allowed"},
+		{"callback em português", "pt-BR", callbackOutside, "host do callbackUrl não permitido"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.acceptLanguage != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("accept-language", tt.acceptLanguage))
+			}
+			_, err := server.SubmitPayment(ctx, tt.in)
+			st, _ := status.FromError(err)
+			if st.Code() != codes.InvalidArgument || st.Message() != tt.wantMessage {
+				t.Errorf("%s %q, esperado %s %q", st.Code(), st.Message(), codes.InvalidArgument, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// h2cTransport fala HTTP/2 sem TLS com os processors: uma conexão multiplexa
+// todas as requisições, sem disputa pelo pool. Um host que nunca respondeu em
+// HTTP/2 e falha depois de conectar passa a usar HTTP/1.1 de vez.
+type h2cTransport struct {
+	h2 *http.Transport
+	h1 *http.Transport
+
+	// host -> true quando já respondeu em HTTP/2, false quando voltou ao HTTP/1.1
+	hosts sync.Map
+}
+
+func newH2CTransport(h1 *http.Transport) *h2cTransport {
+	h2 := h1.Clone()
+	h2.Protocols = new(http.Protocols)
+	h2.Protocols.SetUnencryptedHTTP2(true)
+
+	log.Printf("Usando h2c com os processors (fallback para HTTP/1.1)")
+	return &h2cTransport{h2: h2, h1: h1}
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Com TLS o protocolo é negociado via ALPN
+	if req.URL.Scheme == "https" {
+		return t.h1.RoundTrip(req)
+	}
+
+	host := req.URL.Host
+	state, known := t.hosts.Load(host)
+	if known && !state.(bool) {
+		return t.h1.RoundTrip(req)
+	}
+
+	resp, err := t.h2.RoundTrip(req)
+	if err == nil {
+		if !known {
+			t.hosts.Store(host, true)
+		}
+		return resp, nil
+	}
+
+	// Sem conexão ou requisição cancelada não diz nada sobre o protocolo. A
+	// requisição que falhou não é reenviada aqui: o retry de quem chama a refaz.
+	if !known && req.Context().Err() == nil && !isDialError(err) {
+		if _, loaded := t.hosts.LoadOrStore(host, false); !loaded {
+			log.Printf("h2c recusado por %s (%v), usando HTTP/1.1", host, err)
+		}
+	}
+	return nil, err
+}
+
+// CloseIdleConnections fecha as ociosas dos dois transports.
+func (t *h2cTransport) CloseIdleConnections() {
+	t.h2.CloseIdleConnections()
+	t.h1.CloseIdleConnections()
+}
+
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/health"
+)
+
+// Histórico de saúde (HEALTH_HISTORY_*): um anel por processor com os últimos
+// resultados de health-check e as mudanças do veredito inferido do tráfego,
+// exposto em GET /admin/health-history para cruzar, depois do teste, as decisões
+// de roteamento com o comportamento dos processors. Com HEALTH_HISTORY_STREAM, as
+// consultas feitas por esta instância e os vereditos também vão para um stream
+// do Redis, com as entradas de todas as instâncias.
+
+const healthHistoryStreamKey = "health:history"
+
+// Intervalo de gravação do stream; são poucas entradas por segundo
+const healthHistoryFlushInterval = time.Second
+
+// Eventos do histórico
+const (
+	healthEventCheck    = "check"
+	healthEventInferred = "inferred"
+)
+
+// HealthHistoryEntry é um resultado de health-check ou uma mudança do veredito
+// inferido de um processor.
+type HealthHistoryEntry struct {
+	ID        string    `json:"id,omitempty"`
+	At        time.Time `json:"at"`
+	Processor string    `json:"processor"`
+	Event     string    `json:"event"`
+	Failing   bool      `json:"failing"`
+	// O failing mudou em relação à entrada anterior do mesmo evento
+	Changed bool `json:"changed"`
+	// check: latência informada e se foi esta instância que consultou
+	MinResponseTime int  `json:"minResponseTime,omitempty"`
+	Probed          bool `json:"probed,omitempty"`
+	// inferred: tentativas e fração recusada na janela
+	Attempts  int64   `json:"attempts,omitempty"`
+	ErrorRate float64 `json:"errorRate,omitempty"`
+	// Só nas entradas lidas do stream
+	Instance string `json:"instance,omitempty"`
+}
+
+type healthHistoryRing struct {
+	entries []HealthHistoryEntry
+	next    int
+	// Último failing de cada evento, para marcar as mudanças
+	last map[string]bool
+}
+
+// healthHistoryState é o estado do histórico de saúde; healthHistory vazio com
+// HEALTH_HISTORY_SIZE=0
+type healthHistoryState struct {
+	healthHistory    map[string]*healthHistoryRing
+	healthHistoryMux sync.Mutex
+
+	healthHistoryStream       bool
+	healthHistoryStreamMaxLen int64
+	pendingHealthHistory      []HealthHistoryEntry
+}
+
+func (gw *gateway) initHealthHistory(cfg config.HealthHistoryConfig) {
+	if cfg.Size == 0 {
+		return
+	}
+	for _, name := range gw.processorNames {
+		gw.healthHistory[name] = &healthHistoryRing{entries: make([]HealthHistoryEntry, 0, cfg.Size), last: make(map[string]bool)}
+	}
+	if !cfg.Stream {
+		return
+	}
+	gw.healthHistoryStream = true
+	gw.healthHistoryStreamMaxLen = cfg.StreamMaxLen
+	go func() {
+		ticker := time.NewTicker(healthHistoryFlushInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			gw.flushHealthHistory()
+		}
+	}()
+	log.Printf("Histórico de saúde também em %s (até ~%d entradas)", gw.keys.Key(healthHistoryStreamKey), cfg.StreamMaxLen)
+}
+
+// recordHealthCheck registra um resultado novo de health-check.
+func (gw *gateway) recordHealthCheck(processor string, status health.Status, probed bool) {
+	gw.recordHealthHistory(HealthHistoryEntry{
+		At:              status.LastCheckedAt.UTC(),
+		Processor:       processor,
+		Event:           healthEventCheck,
+		Failing:         status.Failing,
+		MinResponseTime: status.MinResponseTime,
+		Probed:          probed,
+	})
+}
+
+// recordInferredVerdict registra uma mudança do veredito inferido.
+func (gw *gateway) recordInferredVerdict(processor string, failing bool, attempts int64, errorRate float64) {
+	gw.recordHealthHistory(HealthHistoryEntry{
+		At:        gw.appClock.Now().UTC(),
+		Processor: processor,
+		Event:     healthEventInferred,
+		Failing:   failing,
+		Attempts:  attempts,
+		ErrorRate: errorRate,
+	})
+}
+
+func (gw *gateway) recordHealthHistory(entry HealthHistoryEntry) {
+	ring := gw.healthHistory[entry.Processor]
+	if ring == nil {
+		return
+	}
+
+	gw.healthHistoryMux.Lock()
+	defer gw.healthHistoryMux.Unlock()
+	last, seen := ring.last[entry.Event]
+	entry.Changed = !seen || last != entry.Failing
+	ring.last[entry.Event] = entry.Failing
+
+	if len(ring.entries) < cap(ring.entries) {
+		ring.entries = append(ring.entries, entry)
+	} else {
+		ring.entries[ring.next] = entry
+	}
+	ring.next = (ring.next + 1) % cap(ring.entries)
+
+	// Os resultados lidos do Redis já foram gravados por quem consultou
+	if gw.healthHistoryStream && (entry.Event != healthEventCheck || entry.Probed) {
+		gw.pendingHealthHistory = append(gw.pendingHealthHistory, entry)
+	}
+}
+
+// healthHistorySnapshot copia as últimas limit entradas do processor, da mais
+// antiga para a mais recente.
+func (gw *gateway) healthHistorySnapshot(processor string, limit int) []HealthHistoryEntry {
+	ring := gw.healthHistory[processor]
+	gw.healthHistoryMux.Lock()
+	defer gw.healthHistoryMux.Unlock()
+
+	entries := make([]HealthHistoryEntry, 0, len(ring.entries))
+	if len(ring.entries) == cap(ring.entries) {
+		entries = append(entries, ring.entries[ring.next:]...)
+		entries = append(entries, ring.entries[:ring.next]...)
+	} else {
+		entries = append(entries, ring.entries...)
+	}
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries
+}
+
+func (gw *gateway) flushHealthHistory() {
+	gw.healthHistoryMux.Lock()
+	entries := gw.pendingHealthHistory
+	gw.pendingHealthHistory = nil
+	gw.healthHistoryMux.Unlock()
+
+	client := gw.currentRedis()
+	if len(entries) == 0 || client == nil {
+		return
+	}
+
+	instance := gw.instanceName()
+	if gw.preforkIndex >= 0 {
+		instance += "/" + strconv.Itoa(gw.preforkIndex)
+	}
+	ctx := context.Background()
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, entry := range entries {
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: gw.keys.Key(healthHistoryStreamKey),
+				MaxLen: gw.healthHistoryStreamMaxLen,
+				Approx: true,
+				Values: healthHistoryValues(entry, instance),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Erro ao gravar histórico de saúde: %v", err)
+	}
+}
+
+func healthHistoryValues(entry HealthHistoryEntry, instance string) []interface{} {
+	values := []interface{}{
+		"processor", entry.Processor,
+		"event", entry.Event,
+		"failing", strconv.FormatBool(entry.Failing),
+		"changed", strconv.FormatBool(entry.Changed),
+		"at", entry.At.Format(time.RFC3339Nano),
+		"instance", instance,
+	}
+	switch entry.Event {
+	case healthEventCheck:
+		values = append(values, "minResponseTime", entry.MinResponseTime)
+	case healthEventInferred:
+		values = append(values, "attempts", entry.Attempts, "errorRate", strconv.FormatFloat(entry.ErrorRate, 'f', -1, 64))
+	}
+	return values
+}
+
+func parseHealthHistoryMessage(msg redis.XMessage) HealthHistoryEntry {
+	str := func(key string) string {
+		s, _ := msg.Values[key].(string)
+		return s
+	}
+	entry := HealthHistoryEntry{
+		ID:        msg.ID,
+		Processor: str("processor"),
+		Event:     str("event"),
+		Failing:   str("failing") == "true",
+		Changed:   str("changed") == "true",
+		Instance:  str("instance"),
+	}
+	entry.At, _ = time.Parse(time.RFC3339Nano, str("at"))
+	entry.MinResponseTime, _ = strconv.Atoi(str("minResponseTime"))
+	entry.Attempts, _ = strconv.ParseInt(str("attempts"), 10, 64)
+	entry.ErrorRate, _ = strconv.ParseFloat(str("errorRate"), 64)
+	entry.Probed = entry.Event == healthEventCheck
+	return entry
+}
+
+// streamHealthHistory lê as últimas limit entradas do stream, filtradas pelo
+// processor quando informado, da mais antiga para a mais recente.
+func (gw *gateway) streamHealthHistory(ctx context.Context, client redis.UniversalClient, processor string, limit int) ([]HealthHistoryEntry, error) {
+	entries := []HealthHistoryEntry{}
+	end := "+"
+	for len(entries) < limit {
+		msgs, err := client.XRevRangeN(ctx, gw.keys.Key(healthHistoryStreamKey), end, "-", auditScanPage).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			if p, _ := msg.Values["processor"].(string); processor == "" || p == processor {
+				entries = append(entries, parseHealthHistoryMessage(msg))
+				if len(entries) == limit {
+					break
+				}
+			}
+		}
+		if len(msgs) < auditScanPage {
+			break
+		}
+		// Intervalo exclusivo antes da última entrada lida
+		end = "(" + msgs[len(msgs)-1].ID
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
@@ -0,0 +1,65 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleAdminHealthHistory responde o histórico desta instância por processor
+// ou, com source=stream, o do stream do Redis, com todas as instâncias.
+func (gw *gateway) handleAdminHealthHistory(c *gin.Context) {
+	if len(gw.healthHistory) == 0 {
+		respondError(c, http.StatusNotFound, errCodeNotFound, msg(msgHealthHistoryOff))
+		return
+	}
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidLimit))
+			return
+		}
+		limit = n
+	}
+	processor := c.Query("processor")
+	if processor != "" && gw.healthHistory[processor] == nil {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgUnknownProcessor, processor))
+		return
+	}
+
+	switch c.DefaultQuery("source", "local") {
+	case "local":
+		processors := make(map[string][]HealthHistoryEntry, len(gw.processorNames))
+		for _, name := range gw.processorNames {
+			if processor == "" || name == processor {
+				processors[name] = gw.healthHistorySnapshot(name, limit)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"processors": processors})
+	case "stream":
+		if !gw.healthHistoryStream {
+			respondError(c, http.StatusNotFound, errCodeNotFound, msg(msgHealthStreamDisabled))
+			return
+		}
+		client := gw.currentRedis()
+		if client == nil {
+			respondError(c, http.StatusServiceUnavailable, errCodeUnavailable, msg(msgRedisUnavailable))
+			return
+		}
+		// Incluir o que ainda não foi gravado
+		gw.flushHealthHistory()
+		entries, err := gw.streamHealthHistory(c.Request.Context(), client, processor, limit)
+		if err != nil {
+			gw.logf(c.Request.Context(), "Erro ao consultar o stream do histórico de saúde: %v", err)
+			respondError(c, http.StatusInternalServerError, errCodeInternal, msg(msgHealthStreamFailed))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"entries": entries})
+	default:
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidSource))
+	}
+}
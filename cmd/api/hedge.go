@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	pp "rinha-backend-2025/internal/processor"
+)
+
+// hedgeState é o estado do hedge
+type hedgeState struct {
+	// Pagamentos em que o perdedor do hedge também foi aceito pelo processor
+	hedgeDuplicates atomic.Int64
+}
+
+func (gw *gateway) registerHedge() {
+	gw.registerMetric(metric{
+		Name: "hedge_duplicates_total",
+		Help: "Pagamentos aceitos pelos dois processors durante um hedge.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.hedgeDuplicates.Load())}}
+		},
+	})
+}
+
+type hedgeOutcome struct {
+	processor string
+	result    sendResult
+	// Pânico no envio, repassado à goroutine do worker
+	panicked *goroutinePanic
+}
+
+// hedgedSend envia ao primary e, se ele não responder dentro do hedge delay, dispara
+// o mesmo pagamento no secondary. Vence a primeira confirmação; a outra chamada é
+// cancelada e apenas o vencedor é contabilizado.
+func (gw *gateway) hedgedSend(ctx context.Context, payment pp.Payment, primary, secondary string) (string, sendResult) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outcomes := make(chan hedgeOutcome, 2)
+	launch := func(processor string) {
+		go func() {
+			outcome := hedgeOutcome{processor: processor, result: sendFailed}
+			defer func() {
+				outcome.panicked = capturePanic(recover())
+				outcomes <- outcome
+			}()
+			outcome.result = gw.sendToProcessor(ctx, processor, payment)
+		}()
+	}
+
+	launch(primary)
+	running := 1
+	hedged := false
+
+	timer := gw.appClock.NewTimer(gw.currentConfig().Hedging.Delay.Std())
+	defer timer.Stop()
+
+	var winner, unknown, duplicate hedgeOutcome
+	lastResult := sendFailed
+	deferred := false
+	for running > 0 {
+		select {
+		case <-timer.C():
+			if !hedged {
+				gw.logf(ctx, "Processor %s lento para %s, disparando hedge no %s", primary, payment.CorrelationID, secondary)
+				launch(secondary)
+				hedged = true
+				running++
+			}
+			continue
+		case outcome := <-outcomes:
+			running--
+			if outcome.panicked != nil {
+				cancel()
+				panic(*outcome.panicked)
+			}
+			if outcome.result == sendSucceeded {
+				if winner.result == sendSucceeded {
+					// O cancelamento chegou tarde: o outro processor também aceitou
+					gw.hedgeDuplicates.Add(1)
+					gw.logf(ctx, "Pagamento %s aceito por %s e %s durante hedge", payment.CorrelationID, winner.processor, outcome.processor)
+					continue
+				}
+				winner = outcome
+				cancel()
+				continue
+			}
+			lastResult = outcome.result
+			switch outcome.result {
+			case sendUnknown:
+				unknown = outcome
+			case sendDuplicate:
+				duplicate = outcome
+			}
+			deferred = deferred || outcome.result == sendDeferred
+
+			// Primary falhou ou foi adiado antes do hedge: seguir direto para o secondary
+			if outcome.processor == primary && !hedged && (outcome.result == sendFailed || outcome.result == sendDeferred) && ctx.Err() == nil {
+				launch(secondary)
+				hedged = true
+				running++
+			}
+		}
+	}
+
+	if winner.result == sendSucceeded {
+		return winner.processor, sendSucceeded
+	}
+	// Um dos dois já tinha o pagamento: dispatchPayment confere com ele
+	if duplicate.result == sendDuplicate {
+		return duplicate.processor, sendDuplicate
+	}
+	// Um dos dois pode ter aceitado: a reconciliação decide
+	if unknown.result == sendUnknown {
+		return unknown.processor, sendUnknown
+	}
+	if lastResult == sendFailed && deferred {
+		return "", sendDeferred
+	}
+	return "", lastResult
+}
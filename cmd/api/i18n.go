@@ -0,0 +1,381 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/text/language"
+)
+
+// Mensagens de erro em pt-BR (padrão) ou en, pelo Accept-Language (no gRPC, o
+// metadata accept-language). Os clientes e testes devem decidir pelos códigos
+// (o code do envelope e o de cada campo em details, o status no gRPC), que não
+// mudam com o idioma; o texto é só para pessoas. Os handlers passam o ID da
+// mensagem e os argumentos, e o texto sai no idioma escolhido.
+
+// Idiomas suportados, na ordem das colunas das tabelas; o primeiro é o padrão
+const (
+	localePtBR = iota
+	localeEN
+	localeCount
+)
+
+// localizedText é um texto em cada idioma suportado.
+type localizedText [localeCount]string
+
+var localeMatcher = language.NewMatcher([]language.Tag{
+	localePtBR: language.BrazilianPortuguese,
+	localeEN:   language.English,
+})
+
+var localeTags = [localeCount]string{localePtBR: "pt-BR", localeEN: "en"}
+
+// Códigos dos problemas de validação por campo (details[].code)
+const (
+	fieldRequired            = "required"
+	fieldUnknown             = "unknown_field"
+	fieldInvalidUUID         = "invalid_uuid"
+	fieldNotString           = "not_string"
+	fieldNumberAsString      = "number_as_string"
+	fieldInvalidNumber       = "invalid_number"
+	fieldNotPositive         = "not_positive"
+	fieldAboveMaximum        = "above_maximum"
+	fieldAboveProcessorLimit = "above_processor_limit"
+	fieldTooManyDecimals     = "too_many_decimals"
+	fieldInvalidURL          = "invalid_url"
+	fieldInvalidCurrency     = "invalid_currency_format"
+	fieldUnknownCurrency     = "unknown_currency"
+	fieldNotObject           = "not_object"
+	fieldInvalidJSON         = "invalid_json"
+	fieldNotAccepted         = "not_accepted"
+	fieldTooLarge            = "too_large"
+)
+
+// fieldMessages tem o texto de cada código de campo; os verbos recebem os
+// argumentos do fieldIssue.
+var fieldMessages = map[string]localizedText{
+	fieldRequired:            {"campo obrigatório", "is required"},
+	fieldUnknown:             {"campo desconhecido", "unknown field"},
+	fieldInvalidUUID:         {"deve ser um UUID válido", "must be a valid UUID"},
+	fieldNotString:           {"deve ser uma string", "must be a string"},
+	fieldNumberAsString:      {"deve ser um número, não uma string", "must be a number, not a string"},
+	fieldInvalidNumber:       {"deve ser um número válido", "must be a valid number"},
+	fieldNotPositive:         {"deve ser maior que zero", "must be greater than zero"},
+	fieldAboveMaximum:        {"deve ser no máximo %.2f", "must be at most %.2f"},
+	fieldAboveProcessorLimit: {"acima do maior valor aceito pelos processors (%.2f)", "above the highest amount accepted by the processors (%.2f)"},
+	fieldTooManyDecimals:     {"deve ter no máximo 2 casas decimais", "must have at most 2 decimal places"},
+	fieldInvalidURL:          {"deve ser uma URL http(s) válida", "must be a valid http(s) URL"},
+	fieldInvalidCurrency:     {"deve ser um código ISO 4217 de 3 letras maiúsculas", "must be a 3-letter uppercase ISO 4217 code"},
+	fieldUnknownCurrency:     {"moeda ISO 4217 desconhecida", "unknown ISO 4217 currency"},
+	fieldNotObject:           {"deve ser um objeto", "must be an object"},
+	fieldInvalidJSON:         {"deve ser um objeto JSON válido", "must be a valid JSON object"},
+	fieldNotAccepted:         {"não aceito nesta instância (MAX_METADATA_BYTES=0)", "not accepted by this instance (MAX_METADATA_BYTES=0)"},
+	fieldTooLarge:            {"deve ter no máximo %d bytes", "must be at most %d bytes"},
+}
+
+// IDs das mensagens do envelope (error.message e results[].error dos lotes)
+const (
+	msgInvalidPayload        = "invalid_payload"
+	msgReadBody              = "read_body"
+	msgBodyTooLarge          = "body_too_large"
+	msgInvalidCompressedBody = "invalid_compressed_body"
+	msgJSONContentType       = "json_content_type"
+	msgPaymentContentType    = "payment_content_type"
+	msgContentEncoding       = "content_encoding"
+	msgInvalidJSON           = "invalid_json"
+	msgJSONTrailingData      = "json_trailing_data"
+	msgInvalidProtobuf       = "invalid_protobuf"
+	msgMsgpackNotMap         = "msgpack_not_map"
+	msgMsgpackTrailingData   = "msgpack_trailing_data"
+	msgMsgpackTruncated      = "msgpack_truncated"
+	msgMsgpackKeyNotString   = "msgpack_key_not_string"
+	msgMsgpackUnknownType    = "msgpack_unknown_type"
+	msgBatchNotList          = "batch_not_list"
+	msgBatchSize             = "batch_size"
+	msgBatchRejected         = "batch_rejected"
+	msgBatchDuplicate        = "batch_duplicate"
+	msgAmountConflict        = "amount_conflict"
+	msgCallbackNotAllowed    = "callback_not_allowed"
+	msgQueueFull             = "queue_full"
+	msgProcessorsFailed      = "processors_failed"
+	msgAlreadyDispatched     = "already_dispatched"
+	msgPaymentNotFound       = "payment_not_found"
+	msgNotInQueue            = "not_in_queue"
+	msgInvalidCorrelationID  = "invalid_correlation_id"
+	msgPaymentLookupFailed   = "payment_lookup_failed"
+	msgNoPaymentLookup       = "no_payment_lookup"
+	msgDeletePaymentsFailed  = "delete_payments_failed"
+
+	msgInvalidFrom      = "invalid_from"
+	msgInvalidTo        = "invalid_to"
+	msgRangeOrder       = "range_order"
+	msgInvalidLimit     = "invalid_limit"
+	msgInvalidPageLimit = "invalid_page_limit"
+	msgInvalidOffset    = "invalid_offset"
+	msgInvalidTop       = "invalid_top"
+	msgInvalidBucket    = "invalid_bucket"
+	msgTooManyBuckets   = "too_many_buckets"
+	msgInvalidFormat    = "invalid_format"
+	msgInvalidStatus    = "invalid_status"
+	msgInvalidDLQSource = "invalid_dlq_source"
+	msgInvalidSource    = "invalid_source"
+	msgUnknownProcessor = "unknown_processor"
+	msgByCurrencyRange  = "by_currency_range"
+	msgByCurrencyDetail = "by_currency_detailed"
+
+	msgNoAmountStats      = "no_amount_stats"
+	msgNoCurrencies       = "no_currencies"
+	msgNoTimeSeries       = "no_time_series"
+	msgNoIntegrityCheck   = "no_integrity_check"
+	msgNoExport           = "no_export"
+	msgAmountStatsFailed  = "amount_stats_failed"
+	msgCurrenciesFailed   = "currencies_failed"
+	msgTimeSeriesFailed   = "time_series_failed"
+	msgIntegrityFailed    = "integrity_failed"
+	msgStatusIndexFailed  = "status_index_failed"
+	msgAuditFailed        = "audit_failed"
+	msgHealthStreamFailed = "health_stream_failed"
+	msgSummaryDeltaFailed = "summary_delta_failed"
+
+	msgAuditDisabled        = "audit_disabled"
+	msgDecisionLogDisabled  = "decision_log_disabled"
+	msgHealthHistoryOff     = "health_history_disabled"
+	msgHealthStreamDisabled = "health_stream_disabled"
+	msgStatusIndexDisabled  = "status_index_disabled"
+
+	msgInvalidConfig  = "invalid_config"
+	msgConfigRejected = "config_rejected"
+
+	msgRateLimited      = "rate_limited"
+	msgInvalidAPIKey    = "invalid_api_key"
+	msgOriginNotAllowed = "origin_not_allowed"
+	msgRouteNotFound    = "route_not_found"
+	msgRedisUnavailable = "redis_unavailable"
+	msgInternal         = "internal_error"
+)
+
+// messageCatalog tem o texto de cada mensagem do envelope; os verbos recebem os
+// argumentos do msg.
+var messageCatalog = map[string]localizedText{
+	msgInvalidPayload:        {"payload inválido", "invalid payload"},
+	msgReadBody:              {"erro ao ler o corpo da requisição", "error reading the request body"},
+	msgBodyTooLarge:          {"corpo maior que %d bytes", "body larger than %d bytes"},
+	msgInvalidCompressedBody: {"corpo comprimido inválido", "invalid compressed body"},
+	msgJSONContentType:       {"Content-Type deve ser application/json", "Content-Type must be application/json"},
+	msgPaymentContentType:    {"Content-Type deve ser application/json, application/msgpack ou application/x-protobuf", "Content-Type must be application/json, application/msgpack or application/x-protobuf"},
+	msgContentEncoding:       {"Content-Encoding deve ser gzip ou deflate", "Content-Encoding must be gzip or deflate"},
+	msgInvalidJSON:           {"JSON inválido: %v", "invalid JSON: %v"},
+	msgJSONTrailingData:      {"JSON inválido: conteúdo após o objeto", "invalid JSON: content after the object"},
+	msgInvalidProtobuf:       {"protobuf inválido: %v", "invalid protobuf: %v"},
+	msgMsgpackNotMap:         {"MessagePack inválido: o corpo deve ser um mapa", "invalid MessagePack: the body must be a map"},
+	msgMsgpackTrailingData:   {"MessagePack inválido: conteúdo após o mapa", "invalid MessagePack: content after the map"},
+	msgMsgpackTruncated:      {"MessagePack inválido: corpo truncado", "invalid MessagePack: truncated body"},
+	msgMsgpackKeyNotString:   {"MessagePack inválido: chave do mapa deve ser uma string", "invalid MessagePack: map keys must be strings"},
+	msgMsgpackUnknownType:    {"MessagePack inválido: tipo 0x%02x desconhecido", "invalid MessagePack: unknown type 0x%02x"},
+	msgBatchNotList:          {"JSON inválido: o corpo deve ser uma lista de pagamentos", "invalid JSON: the body must be a list of payments"},
+	msgBatchSize:             {"o lote deve ter entre 1 e %d pagamentos", "the batch must have between 1 and %d payments"},
+	msgBatchRejected:         {"nenhum pagamento do lote é válido", "no payment in the batch is valid"},
+	msgBatchDuplicate:        {"correlationId repetido no lote", "correlationId repeated in the batch"},
+	msgAmountConflict:        {"correlationId já recebido com outro valor", "correlationId already received with a different amount"},
+	msgCallbackNotAllowed:    {"host do callbackUrl não permitido", "callbackUrl host not allowed"},
+	msgQueueFull:             {"fila de pagamentos cheia", "payment queue is full"},
+	msgProcessorsFailed:      {"nenhum processor aceitou o pagamento", "no processor accepted the payment"},
+	msgAlreadyDispatched:     {"pagamento já enviado a um processor", "payment already sent to a processor"},
+	msgPaymentNotFound:       {"pagamento não encontrado", "payment not found"},
+	msgNotInQueue:            {"pagamento não encontrado na fila", "payment not found in the queue"},
+	msgInvalidCorrelationID:  {"correlationId deve ser um UUID válido", "correlationId must be a valid UUID"},
+	msgPaymentLookupFailed:   {"erro ao consultar pagamento", "error looking up the payment"},
+	msgNoPaymentLookup:       {"storage sem consulta por correlationId", "the storage cannot look up payments by correlationId"},
+	msgDeletePaymentsFailed:  {"erro ao apagar pagamentos", "error deleting payments"},
+
+	msgInvalidFrom:      {"from deve ser uma data ISO 8601 válida", "from must be a valid ISO 8601 date"},
+	msgInvalidTo:        {"to deve ser uma data ISO 8601 válida", "to must be a valid ISO 8601 date"},
+	msgRangeOrder:       {"to deve ser posterior a from", "to must be after from"},
+	msgInvalidLimit:     {"limit deve ser um inteiro positivo", "limit must be a positive integer"},
+	msgInvalidPageLimit: {"limit deve ser um inteiro entre 1 e %d", "limit must be an integer between 1 and %d"},
+	msgInvalidOffset:    {"offset deve ser um inteiro não negativo", "offset must be a non-negative integer"},
+	msgInvalidTop:       {"top deve ser um inteiro não negativo", "top must be a non-negative integer"},
+	msgInvalidBucket:    {"bucket deve ser 1s, 10s ou 1m", "bucket must be 1s, 10s or 1m"},
+	msgTooManyBuckets:   {"período maior que %d buckets de %s", "range longer than %d buckets of %s"},
+	msgInvalidFormat:    {"format deve ser csv ou ndjson", "format must be csv or ndjson"},
+	msgInvalidStatus:    {"status deve ser pending, succeeded ou failed", "status must be pending, succeeded or failed"},
+	msgInvalidDLQSource: {"source deve ser dlq", "source must be dlq"},
+	msgInvalidSource:    {"source deve ser local ou stream", "source must be local or stream"},
+	msgUnknownProcessor: {"processor desconhecido: %s", "unknown processor: %s"},
+	msgByCurrencyRange:  {"byCurrency não aceita from nem to", "byCurrency does not accept from or to"},
+	msgByCurrencyDetail: {"byCurrency não aceita detailed", "byCurrency does not accept detailed"},
+
+	msgNoAmountStats:      {"storage não permite consultar os valores por período", "the storage cannot query amounts by range"},
+	msgNoCurrencies:       {"storage não mantém os contadores por moeda", "the storage does not keep per-currency counters"},
+	msgNoTimeSeries:       {"storage não permite consultar a série por período", "the storage cannot query the time series by range"},
+	msgNoIntegrityCheck:   {"storage não permite recontar os pagamentos registrados", "the storage cannot recount the recorded payments"},
+	msgNoExport:           {"o storage configurado não permite exportação", "the configured storage does not support export"},
+	msgAmountStatsFailed:  {"erro ao consultar os valores por período", "error querying amounts by range"},
+	msgCurrenciesFailed:   {"erro ao consultar o resumo por moeda", "error querying the per-currency summary"},
+	msgTimeSeriesFailed:   {"erro ao consultar a série do resumo", "error querying the summary time series"},
+	msgIntegrityFailed:    {"erro ao conferir os contadores", "error checking the counters"},
+	msgStatusIndexFailed:  {"erro ao consultar o índice por status", "error querying the status index"},
+	msgAuditFailed:        {"erro ao consultar a auditoria", "error querying the audit log"},
+	msgHealthStreamFailed: {"erro ao consultar o stream do histórico de saúde", "error querying the health history stream"},
+	msgSummaryDeltaFailed: {"erro ao calcular o resumo desta instância", "error computing this instance's summary"},
+
+	msgAuditDisabled:        {"auditoria desativada (AUDIT_LOG)", "audit log disabled (AUDIT_LOG)"},
+	msgDecisionLogDisabled:  {"registro de decisões desativado (SELECTOR_DECISION_LOG)", "decision log disabled (SELECTOR_DECISION_LOG)"},
+	msgHealthHistoryOff:     {"histórico de saúde desativado (HEALTH_HISTORY_SIZE)", "health history disabled (HEALTH_HISTORY_SIZE)"},
+	msgHealthStreamDisabled: {"stream do histórico de saúde desativado (HEALTH_HISTORY_STREAM)", "health history stream disabled (HEALTH_HISTORY_STREAM)"},
+	msgStatusIndexDisabled:  {"índices por status desativados (STATUS_INDEX)", "status indexes disabled (STATUS_INDEX)"},
+
+	msgInvalidConfig:  {"configuração inválida: %v", "invalid configuration: %v"},
+	msgConfigRejected: {"configuração recusada: %v", "configuration rejected: %v"},
+
+	msgRateLimited:      {"limite de requisições excedido", "rate limit exceeded"},
+	msgInvalidAPIKey:    {"chave de API inválida", "invalid API key"},
+	msgOriginNotAllowed: {"origem não autorizada", "origin not allowed"},
+	msgRouteNotFound:    {"rota não encontrada", "route not found"},
+	msgRedisUnavailable: {"Redis indisponível", "Redis unavailable"},
+	msgInternal:         {"erro interno", "internal error"},
+}
+
+// localizedMessage é uma mensagem do catálogo com os argumentos dos verbos, ou
+// o texto de um erro sem ID, que sai como veio em qualquer idioma.
+type localizedMessage struct {
+	id   string
+	args []any
+	raw  string
+}
+
+func msg(id string, args ...any) localizedMessage {
+	return localizedMessage{id: id, args: args}
+}
+
+func (m localizedMessage) text(locale int) string {
+	if m.id == "" {
+		return m.raw
+	}
+	format := messageCatalog[m.id][locale]
+	if format == "" {
+		format = messageCatalog[m.id][localePtBR]
+	}
+	if len(m.args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, m.args...)
+}
+
+// messageError é um erro com a mensagem do catálogo, para as funções cujo erro
+// vai direto para o envelope; Error() é o texto em pt-BR.
+type messageError struct {
+	localizedMessage
+}
+
+func newMessageError(id string, args ...any) error {
+	return &messageError{msg(id, args...)}
+}
+
+func (e *messageError) Error() string {
+	return e.text(localePtBR)
+}
+
+// Unwrap expõe o erro de origem passado como argumento da mensagem.
+func (e *messageError) Unwrap() error {
+	for _, arg := range e.args {
+		if err, ok := arg.(error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// errMessage é a mensagem de um erro que vai para o envelope: a do catálogo, se
+// ele a tiver, ou o texto do erro.
+func errMessage(err error) localizedMessage {
+	var me *messageError
+	if errors.As(err, &me) {
+		return me.localizedMessage
+	}
+	return localizedMessage{raw: err.Error()}
+}
+
+// requestLocale escolhe o idioma pelo Accept-Language; pt-BR sem o cabeçalho ou
+// sem nenhum idioma suportado.
+func requestLocale(r *http.Request) int {
+	return matchLocale(r.Header.Get("Accept-Language"))
+}
+
+// matchLocale escolhe o idioma pelo valor de um Accept-Language, o do HTTP ou o
+// do metadata accept-language do gRPC.
+func matchLocale(header string) int {
+	if header == "" {
+		return localePtBR
+	}
+	_, index := language.MatchStrings(localeMatcher, header)
+	return index
+}
+
+// setContentLanguage informa o idioma escolhido na resposta.
+func setContentLanguage(header http.Header, locale int) {
+	header.Set("Content-Language", localeTags[locale])
+	header.Add("Vary", "Accept-Language")
+}
+
+// fieldIssue é um problema de validação ainda sem o campo; o zero é "sem problema".
+type fieldIssue struct {
+	code string
+	args []any
+}
+
+func issue(code string, args ...any) fieldIssue {
+	return fieldIssue{code: code, args: args}
+}
+
+// at cria o FieldError do campo, com a mensagem em pt-BR.
+func (i fieldIssue) at(field string) FieldError {
+	return FieldError{Field: field, Code: i.code, Message: i.message(localePtBR), args: i.args}
+}
+
+func (i fieldIssue) message(locale int) string {
+	format := fieldMessages[i.code][locale]
+	if len(i.args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, i.args...)
+}
+
+// localizeFields reescreve as mensagens dos campos no idioma da requisição.
+func localizeFields(locale int, fields []FieldError) []FieldError {
+	if locale == localePtBR {
+		return fields
+	}
+	localized := make([]FieldError, len(fields))
+	for i, f := range fields {
+		f.Message = fieldIssue{code: f.Code, args: f.args}.message(locale)
+		localized[i] = f
+	}
+	return localized
+}
+
+// localizeDetails traduz os detalhes conhecidos do envelope.
+func localizeDetails(locale int, details any) any {
+	switch d := details.(type) {
+	case []FieldError:
+		return localizeFields(locale, d)
+	case BatchResponse:
+		return localizeBatch(locale, d)
+	}
+	return details
+}
+
+func localizeBatch(locale int, response BatchResponse) BatchResponse {
+	if locale == localePtBR {
+		return response
+	}
+	results := make([]BatchItemResult, len(response.Results))
+	for i, r := range response.Results {
+		r.Error = r.message.text(locale)
+		r.Details = localizeFields(locale, r.Details)
+		results[i] = r
+	}
+	response.Results = results
+	return response
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var formatVerb = regexp.MustCompile(`%[-+# 0]*[0-9.]*[a-zA-Z]`)
+
+// TestCatalogsHaveEveryLocale confere que cada código declarado em i18n.go tem
+// texto em todos os idiomas, com os mesmos verbos.
+func TestCatalogsHaveEveryLocale(t *testing.T) {
+	constants := parseCatalogConstants(t)
+	catalogs := map[string]map[string]localizedText{
+		"msg":   messageCatalog,
+		"field": fieldMessages,
+	}
+
+	for prefix, catalog := range catalogs {
+		declared := 0
+		for name, id := range constants {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			declared++
+			texts, ok := catalog[id]
+			if !ok {
+				t.Errorf("%s (%q) sem entrada no catálogo", name, id)
+				continue
+			}
+			for locale, text := range texts {
+				if text == "" {
+					t.Errorf("%s sem texto em %s", name, localeTags[locale])
+				}
+			}
+			pt := formatVerb.FindAllString(texts[localePtBR], -1)
+			for locale := localePtBR + 1; locale < localeCount; locale++ {
+				if got := formatVerb.FindAllString(texts[locale], -1); !slices.Equal(got, pt) {
+					t.Errorf("%s: verbos %v em %s, %v em pt-BR", name, got, localeTags[locale], pt)
+				}
+			}
+		}
+		if declared != len(catalog) {
+			t.Errorf("catálogo %s tem %d entradas para %d códigos declarados", prefix, len(catalog), declared)
+		}
+	}
+}
+
+// TestMessageCallsMatchCatalog confere, em todo o pacote, que msg e
+// newMessageError recebem um ID declarado e um argumento por verbo.
+func TestMessageCallsMatchCatalog(t *testing.T) {
+	constants := parseCatalogConstants(t)
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	calls := 0
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			fn, ok := call.Fun.(*ast.Ident)
+			if !ok || (fn.Name != "msg" && fn.Name != "newMessageError") || len(call.Args) == 0 {
+				return true
+			}
+			pos := fset.Position(call.Pos())
+			ident, ok := call.Args[0].(*ast.Ident)
+			if !ok {
+				// Repasses como newMessageError(id, args...) dentro do próprio i18n.go
+				if name != "i18n.go" {
+					t.Errorf("%s: ID da mensagem deve ser uma constante msg*", pos)
+				}
+				return true
+			}
+			id, declared := constants[ident.Name]
+			if !declared {
+				if name != "i18n.go" {
+					t.Errorf("%s: %s não é um ID declarado", pos, ident.Name)
+				}
+				return true
+			}
+			calls++
+			verbs := len(formatVerb.FindAllString(messageCatalog[id][localePtBR], -1))
+			if args := len(call.Args) - 1; args != verbs && call.Ellipsis == token.NoPos {
+				t.Errorf("%s: %s recebe %d argumentos para %d verbos", pos, ident.Name, args, verbs)
+			}
+			return true
+		})
+	}
+	if calls == 0 {
+		t.Fatal("nenhuma chamada a msg encontrada")
+	}
+}
+
+func TestLocalizedMessageText(t *testing.T) {
+	tests := []struct {
+		message localizedMessage
+		locale  int
+		want    string
+	}{
+		{msg(msgQueueFull), localePtBR, "fila de pagamentos cheia"},
+		{msg(msgQueueFull), localeEN, "payment queue is full"},
+		{msg(msgBodyTooLarge, int64(1024)), localeEN, "body larger than 1024 bytes"},
+		{msg(msgTooManyBuckets, 3600, "1s"), localePtBR, "período maior que 3600 buckets de 1s"},
+		{errMessage(fmt.Errorf("resumo: %w", newMessageError(msgRangeOrder))), localeEN, "to must be after from"},
+		{errMessage(errors.New("falha sem ID")), localeEN, "falha sem ID"},
+	}
+	for _, tt := range tests {
+		if got := tt.message.text(tt.locale); got != tt.want {
+			t.Errorf("text(%s) = %q, esperado %q", localeTags[tt.locale], got, tt.want)
+		}
+	}
+
+	cause := errors.New("EOF")
+	if err := newMessageError(msgInvalidJSON, cause); !errors.Is(err, cause) {
+		t.Errorf("newMessageError não expõe o erro de origem")
+	}
+}
+
+func TestWriteHTTPErrorFollowsAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		wantLanguage   string
+		wantMessage    string
+	}{
+		{"", "pt-BR", "limit deve ser um inteiro entre 1 e 1000"},
+		{"en-US,en;q=0.9", "en", "limit must be an integer between 1 and 1000"},
+		{"fr", "pt-BR", "limit deve ser um inteiro entre 1 e 1000"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/admin/payments?limit=0", nil)
+		if tt.acceptLanguage != "" {
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		writeHTTPError(w, r, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidPageLimit, 1000))
+
+		var body ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Error.Code != errCodeInvalidParameter || body.Error.Message != tt.wantMessage {
+			t.Errorf("Accept-Language %q: %s %q, esperado %s %q", tt.acceptLanguage, body.Error.Code, body.Error.Message, errCodeInvalidParameter, tt.wantMessage)
+		}
+		if got := w.Header().Get("Content-Language"); got != tt.wantLanguage {
+			t.Errorf("Accept-Language %q: Content-Language %q, esperado %q", tt.acceptLanguage, got, tt.wantLanguage)
+		}
+	}
+}
+
+// parseCatalogConstants lê as constantes msg* e field* de i18n.go, para que um
+// código novo sem texto no catálogo quebre o teste.
+func parseCatalogConstants(t *testing.T) map[string]string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "i18n.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	constants := make(map[string]string)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			for i, name := range value.Names {
+				if !strings.HasPrefix(name.Name, "msg") && !strings.HasPrefix(name.Name, "field") {
+					continue
+				}
+				lit, ok := value.Values[i].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+				id, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				constants[name.Name] = id
+			}
+		}
+	}
+	return constants
+}
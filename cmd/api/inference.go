@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/health"
+)
+
+// Saúde inferida do próprio tráfego: o /service-health aceita uma consulta a
+// cada 5s, então o "failing" de cada processor vem das tentativas da janela
+// recente, atualizado a cada tentativa. O health-check passa a ser consultado
+// com menos frequência e só decide quando a janela não tem tentativas
+// suficientes, como na subida ou com o tráfego desviado do processor.
+
+// Vereditos da inferência
+const (
+	verdictUnknown int32 = iota
+	verdictHealthy
+	verdictFailing
+)
+
+// inferenceTracker soma as tentativas de um processor em buckets de 1s, em um
+// anel do tamanho da janela.
+type inferenceTracker struct {
+	gw   *gateway
+	name string
+	cfg  config.InferenceConfig
+
+	mu      sync.Mutex
+	buckets []inferenceBucket
+
+	verdict atomic.Int32
+	// Segundo da última tentativa: sem tentativas por uma janela, o veredito vence
+	lastSecond atomic.Int64
+	// Fração recusada na janela, para a métrica
+	errorRate atomic.Uint64
+}
+
+type inferenceBucket struct {
+	second    int64
+	attempts  int64
+	failures  int64
+	latencyNs int64
+}
+
+// inferenceState é o estado da inferência; vazio com HEALTH_INFERENCE=false
+type inferenceState struct {
+	inferenceTrackers map[string]*inferenceTracker
+}
+
+func (gw *gateway) initHealthInference(cfg config.InferenceConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	size := int(cfg.Window.Std() / time.Second)
+	for _, name := range gw.processorNames {
+		gw.inferenceTrackers[name] = &inferenceTracker{gw: gw, name: name, cfg: cfg, buckets: make([]inferenceBucket, size)}
+	}
+	log.Printf("Saúde inferida do tráfego: janela %v, ao menos %d tentativas, falhando a partir de %.0f%% recusadas; health-check a cada %v",
+		cfg.Window.Std(), cfg.MinSamples, cfg.ErrorRate*100, cfg.CheckInterval.Std())
+
+	gw.registerMetric(metric{
+		Name: "processor_inferred_failing",
+		Help: "Veredito inferido do tráfego por processor (1 falhando, 0 saudável); ausente sem tentativas suficientes.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, 0, len(gw.processorNames))
+			for _, name := range gw.processorNames {
+				value := 0.0
+				switch gw.inferenceTrackers[name].Verdict() {
+				case verdictUnknown:
+					continue
+				case verdictFailing:
+					value = 1
+				}
+				samples = append(samples, metricSample{Labels: map[string]string{"processor": name}, Value: value})
+			}
+			return samples
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "processor_inferred_error_rate",
+		Help: "Fração das tentativas recusadas por processor na janela da inferência.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, 0, len(gw.processorNames))
+			for _, name := range gw.processorNames {
+				samples = append(samples, metricSample{
+					Labels: map[string]string{"processor": name},
+					Value:  gw.inferenceTrackers[name].ErrorRate(),
+				})
+			}
+			return samples
+		},
+	})
+}
+
+// observeInference registra uma tentativa no tracker do processor.
+func (gw *gateway) observeInference(processor string, ok bool, latency time.Duration) {
+	if t := gw.inferenceTrackers[processor]; t != nil {
+		t.Record(ok, latency)
+	}
+}
+
+func (t *inferenceTracker) Record(ok bool, latency time.Duration) {
+	now := t.gw.appClock.Now().Unix()
+
+	t.mu.Lock()
+	b := &t.buckets[now%int64(len(t.buckets))]
+	if b.second != now {
+		*b = inferenceBucket{second: now}
+	}
+	b.attempts++
+	b.latencyNs += int64(latency)
+	if !ok {
+		b.failures++
+	}
+
+	var attempts, failures, latencyNs int64
+	for _, bucket := range t.buckets {
+		if now-bucket.second < int64(len(t.buckets)) {
+			attempts += bucket.attempts
+			failures += bucket.failures
+			latencyNs += bucket.latencyNs
+		}
+	}
+	t.mu.Unlock()
+
+	t.lastSecond.Store(now)
+	rate := float64(failures) / float64(attempts)
+	t.errorRate.Store(uint64(rate * 1e6))
+
+	verdict := verdictUnknown
+	if attempts >= int64(t.cfg.MinSamples) {
+		verdict = verdictHealthy
+		slow := t.cfg.LatencyMs > 0 && time.Duration(latencyNs/attempts) >= time.Duration(t.cfg.LatencyMs)*time.Millisecond
+		if rate >= t.cfg.ErrorRate || slow {
+			verdict = verdictFailing
+		}
+	}
+	if previous := t.verdict.Swap(verdict); previous != verdict && verdict != verdictUnknown {
+		log.Printf("Saúde inferida do %s: failing=%v (%d tentativas em %ds, %.0f%% recusadas, média %v)",
+			t.name, verdict == verdictFailing, attempts, len(t.buckets), rate*100,
+			time.Duration(latencyNs/attempts).Round(time.Millisecond))
+		t.gw.recordInferredVerdict(t.name, verdict == verdictFailing, attempts, rate)
+	}
+}
+
+// Verdict é o veredito da janela; Unknown sem tentativas na última janela.
+func (t *inferenceTracker) Verdict() int32 {
+	if t.gw.appClock.Now().Unix()-t.lastSecond.Load() >= int64(len(t.buckets)) {
+		return verdictUnknown
+	}
+	return t.verdict.Load()
+}
+
+func (t *inferenceTracker) ErrorRate() float64 {
+	if t.Verdict() == verdictUnknown {
+		return 0
+	}
+	return float64(t.errorRate.Load()) / 1e6
+}
+
+// healthCheckInterval é o intervalo do /service-health: o mínimo do processor
+// sem inferência, o de corroboração com ela.
+func healthCheckInterval(cfg config.HealthConfig) time.Duration {
+	if cfg.Inference.Enabled {
+		return cfg.Inference.CheckInterval.Std()
+	}
+	return 0
+}
+
+// processorFailing é o "failing" usado no roteamento: o inferido quando a janela
+// tem tentativas suficientes, senão o do último health-check.
+func (gw *gateway) processorFailing(name string, status *health.Status) bool {
+	if t := gw.inferenceTrackers[name]; t != nil {
+		switch t.Verdict() {
+		case verdictHealthy:
+			return false
+		case verdictFailing:
+			return true
+		}
+	}
+	return status.Failing
+}
+
+// allProcessorsFailing indica que nenhum processor está aceitando pagamentos.
+func (gw *gateway) allProcessorsFailing(ctx context.Context) bool {
+	for _, name := range gw.processorNames {
+		if !gw.processorFailing(name, gw.healthMonitor.Get(ctx, name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// inferredVerdict descreve o veredito para o /healthz; vazio sem inferência.
+func (gw *gateway) inferredVerdict(name string) string {
+	t := gw.inferenceTrackers[name]
+	if t == nil {
+		return ""
+	}
+	switch t.Verdict() {
+	case verdictHealthy:
+		return "healthy"
+	case verdictFailing:
+		return "failing"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Identidade da instância (INSTANCE_ID, ou o hostname), para atribuir o que se vê
+// atrás do nginx: prefixa as linhas de log, vai no label instance_id das métricas
+// e nas entradas de auditoria e, com INSTANCE_HEADER, no header X-Instance das
+// respostas. GET /admin/whoami a reporta com a versão e o uptime.
+
+const (
+	instanceHeader = "X-Instance"
+	instanceLabel  = "instance_id"
+)
+
+// Versão do build, por -ldflags "-X main.version=..." (ARG VERSION no Dockerfile);
+// vazia usa a versão do módulo
+var version string
+
+// instanceState é o estado da identificação da instância
+type instanceState struct {
+	// Hostname até initInstance
+	instanceID   string
+	appStartedAt time.Time
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return name
+}
+
+// instanceName identifica esta instância nas travas e nos registros em Redis.
+func (gw *gateway) instanceName() string {
+	return gw.instanceID
+}
+
+// initInstance define o ID e o prefixa aos logs; no prefork, o índice do filho
+// segue no prefixo dele.
+func (gw *gateway) initInstance(cfg config.InstanceConfig) {
+	if cfg.ID != "" {
+		gw.instanceID = cfg.ID
+	}
+	log.SetPrefix("[" + gw.instanceID + "] " + log.Prefix())
+	log.SetFlags(log.Flags() | log.Lmsgprefix)
+
+	gw.registerMetric(metric{
+		Name: "instance_info",
+		Help: "Identidade e versão da instância; sempre 1.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			return []metricSample{{
+				Labels: map[string]string{"version": appVersion(), "go_version": runtime.Version()},
+				Value:  1,
+			}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "instance_uptime_seconds",
+		Help: "Tempo desde a subida do processo.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: gw.appClock.Since(gw.appStartedAt).Seconds()}}
+		},
+	})
+}
+
+// instanceHandler devolve o ID no header X-Instance (INSTANCE_HEADER).
+func (gw *gateway) instanceHandler(next http.Handler, cfg config.InstanceConfig) http.Handler {
+	if !cfg.Header {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(instanceHeader, gw.instanceID)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WhoAmI é a resposta de GET /admin/whoami.
+type WhoAmI struct {
+	Instance string `json:"instance"`
+	Hostname string `json:"hostname"`
+	PID      int    `json:"pid"`
+	// Índice do filho no RUN_MODE=prefork; ausente fora dele
+	PreforkChild *int `json:"preforkChild,omitempty"`
+	// Namespace das chaves no Redis (RUN_ID)
+	RunID         string    `json:"runId,omitempty"`
+	Version       string    `json:"version"`
+	Build         BuildInfo `json:"build"`
+	StartedAt     time.Time `json:"startedAt"`
+	UptimeSeconds float64   `json:"uptimeSeconds"`
+}
+
+// BuildInfo resume o que o go build gravou no binário.
+type BuildInfo struct {
+	GoVersion string `json:"goVersion"`
+	// Commit e data do commit; ausentes num build sem o .git
+	Revision     string `json:"revision,omitempty"`
+	RevisionTime string `json:"revisionTime,omitempty"`
+	// Árvore com mudanças não commitadas no build
+	Modified bool `json:"modified,omitempty"`
+	// Build tags, como "minimal"
+	Tags string `json:"tags,omitempty"`
+}
+
+func (gw *gateway) whoAmI() WhoAmI {
+	who := WhoAmI{
+		Instance:      gw.instanceID,
+		Hostname:      hostname(),
+		PID:           os.Getpid(),
+		RunID:         gw.keys.RunID(),
+		Version:       appVersion(),
+		Build:         buildInfo(),
+		StartedAt:     gw.appStartedAt.UTC(),
+		UptimeSeconds: gw.appClock.Since(gw.appStartedAt).Seconds(),
+	}
+	if gw.preforkIndex >= 0 {
+		index := gw.preforkIndex
+		who.PreforkChild = &index
+	}
+	return who
+}
+
+func appVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+func buildInfo() BuildInfo {
+	build := BuildInfo{GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return build
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.time":
+			build.RevisionTime = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		case "-tags":
+			build.Tags = setting.Value
+		}
+	}
+	return build
+}
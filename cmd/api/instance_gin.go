@@ -0,0 +1,13 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (gw *gateway) handleAdminWhoAmI(c *gin.Context) {
+	c.JSON(http.StatusOK, gw.whoAmI())
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+)
+
+// TestWhoAmIUptimeFollowsAppClock confere que o uptime é medido no mesmo relógio
+// que marcou a subida.
+func TestWhoAmIUptimeFollowsAppClock(t *testing.T) {
+	start := time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	gw := newGateway(fake)
+	fake.Advance(90 * time.Second)
+
+	who := gw.whoAmI()
+	if !who.StartedAt.Equal(start) || who.UptimeSeconds != 90 {
+		t.Errorf("subida em %s com %.0fs de uptime, esperado %s e 90s", who.StartedAt, who.UptimeSeconds, start)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"math"
+
+	"rinha-backend-2025/internal/storage"
+)
+
+// Conferência de integridade (GET /admin/verify): os contadores do resumo, que
+// o GET /payments-summary lê sem filtro, são comparados com a recontagem dos
+// pagamentos registrados. No backend redis entra também a soma de conferência,
+// atualizada no mesmo script dos contadores: se só ela bate com os pagamentos, o
+// totalAmount acumulou arredondamento ou foi alterado por fora. Diferenças
+// esperadas: pagamentos ainda pendentes em outras instâncias, registros
+// expirados (STORAGE_RECORD_TTL) e as correções de SUMMARY_CHECK_CORRECT.
+
+// IntegrityResponse é a resposta de GET /admin/verify.
+type IntegrityResponse struct {
+	// Sem diferença em nenhum processor
+	Consistent bool                          `json:"consistent"`
+	Processors map[string]ProcessorIntegrity `json:"processors"`
+}
+
+// ProcessorIntegrity compara os totais de um processor. As diferenças são o
+// lado conferido menos a recontagem.
+type ProcessorIntegrity struct {
+	Consistent bool            `json:"consistent"`
+	Counters   IntegrityTotals `json:"counters"`
+	// Ausente nos backends sem ela
+	Checksum *IntegrityTotals `json:"checksum,omitempty"`
+	Recorded IntegrityTotals  `json:"recorded"`
+	Drift    IntegrityTotals  `json:"drift"`
+	// Ausente sem a soma de conferência
+	ChecksumDrift *IntegrityTotals `json:"checksumDrift,omitempty"`
+}
+
+type IntegrityTotals struct {
+	TotalRequests int64   `json:"totalRequests"`
+	TotalAmount   float64 `json:"totalAmount"`
+}
+
+func (gw *gateway) checkIntegrity(ctx context.Context) (IntegrityResponse, error) {
+	integrity, err := storage.CheckIntegrity(ctx, gw.store)
+	if err != nil {
+		return IntegrityResponse{}, err
+	}
+
+	response := IntegrityResponse{Consistent: true, Processors: make(map[string]ProcessorIntegrity, len(gw.processorNames))}
+	for _, name := range gw.processorNames {
+		entry := integrity[name]
+		recorded := tallyTotals(entry.Recorded)
+		counters := IntegrityTotals{
+			TotalRequests: int64(entry.Counters.TotalRequests),
+			TotalAmount:   roundCents(entry.Counters.TotalAmount),
+		}
+		result := ProcessorIntegrity{
+			Counters: counters,
+			Recorded: recorded,
+			Drift:    totalsDrift(counters, recorded),
+		}
+		result.Consistent = result.Drift == IntegrityTotals{}
+		if entry.Checksum != nil {
+			checksum := tallyTotals(*entry.Checksum)
+			drift := totalsDrift(checksum, recorded)
+			result.Checksum, result.ChecksumDrift = &checksum, &drift
+			result.Consistent = result.Consistent && drift == IntegrityTotals{}
+		}
+		response.Consistent = response.Consistent && result.Consistent
+		response.Processors[name] = result
+	}
+	return response, nil
+}
+
+func tallyTotals(t storage.Tally) IntegrityTotals {
+	return IntegrityTotals{TotalRequests: t.Requests, TotalAmount: float64(t.Cents) / 100}
+}
+
+func totalsDrift(got, recorded IntegrityTotals) IntegrityTotals {
+	return IntegrityTotals{
+		TotalRequests: got.TotalRequests - recorded.TotalRequests,
+		TotalAmount:   roundCents(got.TotalAmount - recorded.TotalAmount),
+	}
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
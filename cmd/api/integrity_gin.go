@@ -0,0 +1,28 @@
+//go:build !minimal
+
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"rinha-backend-2025/internal/storage"
+)
+
+func (gw *gateway) handleAdminVerify(c *gin.Context) {
+	// Descarregar os pagamentos pendentes desta instância antes de recontar
+	gw.flushCounters()
+	response, err := gw.checkIntegrity(c.Request.Context())
+	if errors.Is(err, storage.ErrNoIntegrityCheck) {
+		respondError(c, http.StatusNotImplemented, errCodeNotImplemented, msg(msgNoIntegrityCheck))
+		return
+	}
+	if err != nil {
+		gw.logf(c.Request.Context(), "Erro ao conferir os contadores: %v", err)
+		respondError(c, http.StatusInternalServerError, errCodeInternal, msg(msgIntegrityFailed))
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
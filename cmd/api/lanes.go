@@ -0,0 +1,55 @@
+package main
+
+import "rinha-backend-2025/internal/queue"
+
+func (gw *gateway) registerLanes() {
+	gw.registerMetric(metric{
+		Name: "queue_depth",
+		Help: "Pagamentos aguardando um worker, por faixa.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			return gw.collectLanes(func(l queue.LaneStatus) float64 { return float64(l.Depth) })
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "queue_taken_total",
+		Help: "Pagamentos retirados da fila pelos workers, por faixa.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return gw.collectLanes(func(l queue.LaneStatus) float64 { return float64(l.Taken) })
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "queue_wait_seconds_total",
+		Help: "Soma do tempo de espera na fila dos pagamentos retirados, por faixa.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return gw.collectLanes(func(l queue.LaneStatus) float64 { return l.WaitSeconds })
+		},
+	})
+}
+
+func (gw *gateway) collectLanes(value func(queue.LaneStatus) float64) []metricSample {
+	if gw.paymentQueue == nil {
+		return nil
+	}
+	lanes := gw.paymentQueue.Status().Lanes
+	samples := make([]metricSample, 0, len(lanes))
+	for _, name := range []string{queue.LaneHigh, queue.LaneNormal} {
+		if l, ok := lanes[name]; ok {
+			samples = append(samples, metricSample{Labels: map[string]string{"lane": name}, Value: value(l)})
+		}
+	}
+	return samples
+}
+
+// priorityLane manda para a faixa prioritária os pagamentos a partir de
+// threshold, que valem mais no resultado; 0 desativa a faixa.
+func priorityLane(threshold float64) func(PaymentRequest) bool {
+	if threshold <= 0 {
+		return nil
+	}
+	return func(req PaymentRequest) bool {
+		return req.Amount >= threshold
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+)
+
+// Buckets exponenciais de 100µs a ~2min, com erro relativo de até 5% por bucket:
+// o suficiente para p50/p95/p99 sem guardar cada amostra.
+const (
+	latencyMin     = 100 * time.Microsecond
+	latencyGrowth  = 1.1
+	latencyBuckets = 150
+)
+
+var latencyLogGrowth = math.Log(latencyGrowth)
+
+type latencyHistogram struct {
+	counts [latencyBuckets]int64
+	total  int64
+}
+
+func latencyBucket(d time.Duration) int {
+	if d <= latencyMin {
+		return 0
+	}
+	i := int(math.Log(float64(d)/float64(latencyMin))/latencyLogGrowth) + 1
+	return min(i, latencyBuckets-1)
+}
+
+// Limite superior do bucket, usado como valor do quantil
+func latencyBucketBound(i int) time.Duration {
+	return time.Duration(float64(latencyMin) * math.Pow(latencyGrowth, float64(i)))
+}
+
+// latencyStats guarda as latências observadas de um processor em duas janelas:
+// os quantis cobrem a janela atual e a anterior, para refletirem o comportamento
+// recente sem zerar de uma vez a cada rotação.
+type latencyStats struct {
+	mu        sync.Mutex
+	clock     clock.Clock
+	window    time.Duration
+	current   latencyHistogram
+	previous  latencyHistogram
+	rotatedAt time.Time
+}
+
+// LatencySnapshot é o resumo exposto em /admin/stats e usado pelo selector.
+type LatencySnapshot struct {
+	Count int64   `json:"count"`
+	P50Ms float64 `json:"p50Ms"`
+	P95Ms float64 `json:"p95Ms"`
+	P99Ms float64 `json:"p99Ms"`
+}
+
+// latencyState é o estado das latências
+type latencyState struct {
+	// Latências por processor; preenchido em initLatencyStats e só lido depois
+	processorLatency map[string]*latencyStats
+}
+
+func (gw *gateway) initLatencyStats(window time.Duration) {
+	for _, name := range gw.processorNames {
+		gw.processorLatency[name] = &latencyStats{clock: gw.appClock, window: window, rotatedAt: gw.appClock.Now()}
+	}
+
+	gw.registerMetric(metric{
+		Name: "processor_latency_seconds",
+		Help: "Quantis da latência observada nos envios aos processors.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			var samples []metricSample
+			for _, name := range gw.processorNames {
+				for _, q := range []float64{0.5, 0.95, 0.99} {
+					samples = append(samples, metricSample{
+						Labels: map[string]string{"processor": name, "quantile": strconv.FormatFloat(q, 'f', -1, 64)},
+						Value:  gw.processorLatency[name].Quantile(q).Seconds(),
+					})
+				}
+			}
+			return samples
+		},
+	})
+}
+
+func (gw *gateway) recordLatency(processor string, d time.Duration) {
+	if stats := gw.processorLatency[processor]; stats != nil {
+		stats.Record(d)
+	}
+}
+
+func (s *latencyStats) Record(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rotateLocked()
+	s.current.counts[latencyBucket(d)]++
+	s.current.total++
+}
+
+func (s *latencyStats) rotateLocked() {
+	elapsed := s.clock.Since(s.rotatedAt)
+	if elapsed < s.window {
+		return
+	}
+	if elapsed < 2*s.window {
+		s.previous = s.current
+	} else {
+		// Sem amostras na última janela inteira: o histórico antigo não vale mais
+		s.previous = latencyHistogram{}
+	}
+	s.current = latencyHistogram{}
+	s.rotatedAt = s.clock.Now()
+}
+
+// Quantile retorna a latência abaixo da qual está a fração q das amostras; 0 sem
+// amostras.
+func (s *latencyStats) Quantile(q float64) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.quantileLocked(q)
+}
+
+func (s *latencyStats) quantileLocked(q float64) time.Duration {
+	s.rotateLocked()
+
+	total := s.current.total + s.previous.total
+	if total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(total)))
+	var seen int64
+	for i := 0; i < latencyBuckets; i++ {
+		seen += s.current.counts[i] + s.previous.counts[i]
+		if seen >= rank {
+			return latencyBucketBound(i)
+		}
+	}
+	return latencyBucketBound(latencyBuckets - 1)
+}
+
+func (s *latencyStats) quantileAndCount(q float64) (time.Duration, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.quantileLocked(q), s.current.total + s.previous.total
+}
+
+func (s *latencyStats) Snapshot() LatencySnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms := func(d time.Duration) float64 {
+		return float64(d.Microseconds()) / 1000
+	}
+	return LatencySnapshot{
+		Count: s.current.total + s.previous.total,
+		P50Ms: ms(s.quantileLocked(0.5)),
+		P95Ms: ms(s.quantileLocked(0.95)),
+		P99Ms: ms(s.quantileLocked(0.99)),
+	}
+}
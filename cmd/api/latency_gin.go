@@ -0,0 +1,17 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (gw *gateway) handleAdminStats(c *gin.Context) {
+	latency := make(map[string]LatencySnapshot, len(gw.processorLatency))
+	for name, stats := range gw.processorLatency {
+		latency[name] = stats.Snapshot()
+	}
+	c.JSON(http.StatusOK, gin.H{"latency": latency})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// concurrencyLimiter limita as requisições simultâneas a um processor usando AIMD:
+// cada sucesso rápido aumenta o limite em ~1 por janela, cada falha ou resposta
+// lenta o reduz multiplicativamente.
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	shed     int64
+
+	min              float64
+	max              float64
+	backoff          float64
+	latencyThreshold time.Duration
+}
+
+func newConcurrencyLimiter(cfg config.LimiterConfig) *concurrencyLimiter {
+	return &concurrencyLimiter{
+		limit:            float64(cfg.Initial),
+		min:              float64(cfg.Min),
+		max:              float64(cfg.Max),
+		backoff:          cfg.Backoff,
+		latencyThreshold: cfg.LatencyThreshold.Std(),
+	}
+}
+
+// TryAcquire reserva uma vaga; false indica que o processor está no limite.
+func (l *concurrencyLimiter) TryAcquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight >= int(l.limit) {
+		l.shed++
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+// Release libera a vaga e ajusta o limite conforme o resultado da chamada.
+func (l *concurrencyLimiter) Release(success bool, latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+
+	congested := !success || (l.latencyThreshold > 0 && latency > l.latencyThreshold)
+	if congested {
+		l.limit *= l.backoff
+		if l.limit < l.min {
+			l.limit = l.min
+		}
+		return
+	}
+
+	l.limit += 1 / l.limit
+	if l.limit > l.max {
+		l.limit = l.max
+	}
+}
+
+func (l *concurrencyLimiter) snapshot() (limit, inFlight int, shed int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit), l.inFlight, l.shed
+}
+
+// limiterState é o estado dos limitadores de concorrência
+type limiterState struct {
+	// Limitadores por processor; vazio quando desativado
+	processorLimiters map[string]*concurrencyLimiter
+}
+
+func (gw *gateway) initProcessorLimiters(cfg config.LimiterConfig) {
+	if !cfg.Enabled {
+		return
+	}
+
+	for _, processor := range gw.processorNames {
+		gw.processorLimiters[processor] = newConcurrencyLimiter(cfg)
+	}
+
+	collect := func(pick func(limit, inFlight int, shed int64) float64) func() []metricSample {
+		return func() []metricSample {
+			samples := make([]metricSample, 0, len(gw.processorLimiters))
+			for processor, limiter := range gw.processorLimiters {
+				samples = append(samples, metricSample{
+					Labels: map[string]string{"processor": processor},
+					Value:  pick(limiter.snapshot()),
+				})
+			}
+			return samples
+		}
+	}
+
+	gw.registerMetric(metric{
+		Name: "processor_concurrency_limit",
+		Help: "Limite atual de requisições simultâneas por processor.",
+		Type: "gauge",
+		Collect: collect(func(limit, _ int, _ int64) float64 {
+			return float64(limit)
+		}),
+	})
+	gw.registerMetric(metric{
+		Name: "processor_inflight_requests",
+		Help: "Requisições em andamento por processor.",
+		Type: "gauge",
+		Collect: collect(func(_, inFlight int, _ int64) float64 {
+			return float64(inFlight)
+		}),
+	})
+	gw.registerMetric(metric{
+		Name: "processor_shed_total",
+		Help: "Tentativas devolvidas à fila por falta de vaga no limitador.",
+		Type: "counter",
+		Collect: collect(func(_, _ int, shed int64) float64 {
+			return float64(shed)
+		}),
+	})
+}
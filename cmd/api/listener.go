@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// openListeners abre a porta TCP e/ou o socket unix configurados. Com tlsConfig,
+// a porta TCP serve HTTPS (e HTTP/2 via ALPN); o socket segue em texto puro.
+func (gw *gateway) openListeners(cfg config.Config, tlsConfig *tls.Config) ([]net.Listener, error) {
+	var listeners []net.Listener
+
+	if cfg.Socket.Path != "" {
+		ln, err := listenUnixSocket(cfg.Socket)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Servidor escutando no socket %s", cfg.Socket.Path)
+		listeners = append(listeners, ln)
+	}
+
+	if cfg.Socket.Path == "" || !cfg.Socket.Only {
+		// Os filhos do prefork dividem a porta: SO_REUSEPORT mesmo com um listener
+		tcp, err := listenTCP("0.0.0.0:"+cfg.Port, cfg.Listeners, gw.preforkIndex >= 0, cfg.Server)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		for _, ln := range tcp {
+			if tlsConfig != nil {
+				ln = tls.NewListener(ln, tlsConfig)
+			}
+			listeners = append(listeners, ln)
+		}
+
+		suffix := ""
+		if len(tcp) > 1 {
+			suffix = fmt.Sprintf(" (%d listeners com SO_REUSEPORT)", len(tcp))
+		}
+		if tlsConfig != nil {
+			log.Printf("Servidor iniciando na porta %s com TLS%s", cfg.Port, suffix)
+		} else {
+			log.Printf("Servidor iniciando na porta %s%s", cfg.Port, suffix)
+		}
+	}
+
+	return listeners, nil
+}
+
+// listenTCP abre n sockets com SO_REUSEPORT em addr (0 = GOMAXPROCS), cada um
+// servido por um laço de accept próprio. Sem suporte na plataforma, ou se o
+// primeiro falhar, abre um socket comum, a não ser que shared exija a porta
+// compartilhada com outros processos. As conexões aceitas seguem o TCP keep-alive
+// e o TCP_NODELAY de SERVER_*.
+func listenTCP(addr string, n int, shared bool, cfg config.ServerConfig) ([]net.Listener, error) {
+	if n == 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	lc := net.ListenConfig{KeepAlive: cfg.TCPKeepAlive.Std()}
+	if n > 1 || shared {
+		listeners := make([]net.Listener, 0, n)
+		for i := 0; i < n; i++ {
+			ln, err := listenReusePort(lc, addr)
+			if err != nil {
+				closeListeners(listeners)
+				if i > 0 || shared {
+					return nil, err
+				}
+				log.Printf("Aviso: %v; usando um único listener", err)
+				break
+			}
+			listeners = append(listeners, tuneTCPListener(ln, cfg))
+		}
+		if len(listeners) == n {
+			return listeners, nil
+		}
+	}
+
+	ln, err := lc.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return []net.Listener{tuneTCPListener(ln, cfg)}, nil
+}
+
+// tuneTCPListener desliga o TCP_NODELAY das conexões aceitas quando pedido; o Go
+// já o liga em toda conexão TCP.
+func tuneTCPListener(ln net.Listener, cfg config.ServerConfig) net.Listener {
+	if cfg.TCPNoDelay {
+		return ln
+	}
+	return nagleListener{ln}
+}
+
+type nagleListener struct {
+	net.Listener
+}
+
+func (l nagleListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetNoDelay(false)
+	}
+	return conn, err
+}
+
+// newHTTPServer cria o servidor das portas TCP e do socket com os prazos e o
+// limite de headers de SERVER_*, contando as requisições em andamento para a
+// drenagem (ver drain.go).
+func (gw *gateway) newHTTPServer(handler http.Handler, cfg config.ServerConfig) *http.Server {
+	return &http.Server{
+		Handler:           gw.drainHandler(handler),
+		ReadTimeout:       cfg.ReadTimeout.Std(),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout.Std(),
+		WriteTimeout:      cfg.WriteTimeout.Std(),
+		IdleTimeout:       cfg.IdleTimeout.Std(),
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+func listenUnixSocket(cfg config.SocketConfig) (net.Listener, error) {
+	if err := removeStaleSocket(cfg.Path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao escutar no socket %s: %w", cfg.Path, err)
+	}
+
+	mode, _ := strconv.ParseUint(cfg.Mode, 8, 32)
+	if err := os.Chmod(cfg.Path, os.FileMode(mode)); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("erro ao ajustar permissões do socket %s: %w", cfg.Path, err)
+	}
+	return ln, nil
+}
+
+// removeStaleSocket apaga um socket deixado por uma execução anterior. Se ainda
+// houver alguém aceitando conexões nele, retorna erro em vez de roubá-lo.
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s existe e não é um socket", path)
+	}
+
+	if conn, err := net.DialTimeout("unix", path, 100*time.Millisecond); err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %s já está em uso", path)
+	}
+
+	log.Printf("Removendo socket antigo %s", path)
+	return os.Remove(path)
+}
+
+func serveListeners(srv *http.Server, listeners []net.Listener) {
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+				logFatal(err)
+			}
+		}(ln)
+	}
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, ln := range listeners {
+		ln.Close()
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/storage"
+)
+
+// Modo de carga extrema (LOAD_SHED_*): com a CPU tomada pela validação e pela
+// decodificação dos pagamentos, ou com a fila crescendo, o trabalho acessório
+// disputa a mesma CPU com os envios. Acima da marca alta ele é desligado até a
+// carga baixar da marca baixa; o que fica de fora vai para load_shed_skipped_total.
+// Os histogramas de latência continuam, porque o selector e a guarda de SLO
+// dependem deles.
+
+// Trabalho desligado na carga extrema, em load_shed_skipped_total
+const (
+	shedAudit = iota
+	shedLogs
+	shedDecisions
+	shedVerifySample
+	shedAmountHistogram
+)
+
+var shedWorkNames = [...]string{
+	shedAudit:           "audit",
+	shedLogs:            "logs",
+	shedDecisions:       "decisions",
+	shedVerifySample:    "verify_sample",
+	shedAmountHistogram: "amount_histogram",
+}
+
+// loadShedState é o estado do modo de carga extrema
+type loadShedState struct {
+	// Acima da marca alta: trabalho acessório desligado até baixar da marca baixa
+	loadShedding atomic.Bool
+	// Fração da CPU disponível usada na última leitura; bits do float64, e NaN
+	// sem a CPU medida
+	loadShedCPU atomic.Uint64
+
+	loadShedActivations atomic.Int64
+	loadShedSkipped     [len(shedWorkNames)]atomic.Int64
+}
+
+// LoadShedStatus é o estado do modo de carga extrema, em /healthz.
+type LoadShedStatus struct {
+	Active bool `json:"active"`
+	// Fração da CPU disponível usada pelo processo; ausente sem a CPU medida
+	CPURatio *float64 `json:"cpuRatio,omitempty"`
+}
+
+func (gw *gateway) registerLoadShed() {
+	gw.loadShedCPU.Store(math.Float64bits(math.NaN()))
+
+	gw.registerMetric(metric{
+		Name: "load_shedding",
+		Help: "1 enquanto a carga extrema mantém o trabalho acessório desligado.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			if gw.loadShedding.Load() {
+				return []metricSample{{Value: 1}}
+			}
+			return []metricSample{{Value: 0}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "load_shed_cpu_ratio",
+		Help: "Fração da CPU disponível usada pelo processo na última leitura do modo de carga extrema.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			if ratio := math.Float64frombits(gw.loadShedCPU.Load()); !math.IsNaN(ratio) {
+				return []metricSample{{Value: ratio}}
+			}
+			return nil
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "load_shed_activations_total",
+		Help: "Vezes em que a carga extrema desligou o trabalho acessório.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.loadShedActivations.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "load_shed_skipped_total",
+		Help: "Trabalho acessório deixado de lado na carga extrema, por tipo (audit, logs, decisions, verify_sample, amount_histogram).",
+		Type: "counter",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, len(gw.loadShedSkipped))
+			for i := range gw.loadShedSkipped {
+				samples[i] = metricSample{
+					Labels: map[string]string{"work": shedWorkNames[i]},
+					Value:  float64(gw.loadShedSkipped[i].Load()),
+				}
+			}
+			return samples
+		},
+	})
+}
+
+// startLoadShedMonitor inicia o monitor; roda depois de abrir a fila, que ele lê.
+func (gw *gateway) startLoadShedMonitor(cfg config.LoadShedConfig) {
+	if cfg.CheckInterval == 0 {
+		return
+	}
+	// Com prefork, cada processo mede só a sua parte da cota
+	capacity := float64(runtime.NumCPU())
+	if quota, err := cgroupCPUQuota(); err == nil && quota > 0 {
+		capacity = quota
+	}
+	measureCPU := cfg.CPUHigh > 0
+	if _, ok := processCPUTime(); measureCPU && !ok {
+		log.Printf("Aviso: tempo de CPU do processo indisponível nesta plataforma, LOAD_SHED_CPU_HIGH ignorado")
+		measureCPU = false
+	}
+	if !measureCPU && cfg.QueueHigh == 0 {
+		return
+	}
+	log.Printf("Modo de carga extrema: CPU %.0f%%/%.0f%% de %.2f CPU, fila %d/%d (0 ignora)",
+		cfg.CPUHigh*100, cfg.CPULow*100, capacity, cfg.QueueHigh, cfg.QueueLow)
+
+	go func() {
+		ticker := time.NewTicker(cfg.CheckInterval.Std())
+		defer ticker.Stop()
+
+		lastCPU, _ := processCPUTime()
+		lastAt := time.Now()
+		for range ticker.C {
+			// Sinais ignorados não seguram a entrada nem a saída
+			high, low := false, true
+			ratio := math.NaN()
+			if measureCPU {
+				used, _ := processCPUTime()
+				now := time.Now()
+				if elapsed := now.Sub(lastAt); elapsed > 0 {
+					ratio = float64(used-lastCPU) / (float64(elapsed) * capacity)
+				}
+				lastCPU, lastAt = used, now
+				gw.loadShedCPU.Store(math.Float64bits(ratio))
+				high = high || ratio >= cfg.CPUHigh
+				low = low && ratio < cfg.CPULow
+			}
+			depth := gw.paymentQueue.Depth()
+			if cfg.QueueHigh > 0 {
+				high = high || depth >= cfg.QueueHigh
+				low = low && depth < cfg.QueueLow
+			}
+
+			switch {
+			case high:
+				if !gw.loadShedding.Swap(true) {
+					gw.loadShedActivations.Add(1)
+					log.Printf("Aviso: carga extrema (%s), desligando auditoria, logs das requisições e estatísticas acessórias", describeLoad(ratio, depth))
+				}
+			case low:
+				if gw.loadShedding.Swap(false) {
+					log.Printf("Carga normal (%s), religando o trabalho acessório", describeLoad(ratio, depth))
+				}
+			}
+		}
+	}()
+}
+
+func describeLoad(cpuRatio float64, depth int) string {
+	if math.IsNaN(cpuRatio) {
+		return fmt.Sprintf("%d pagamentos em memória", depth)
+	}
+	return fmt.Sprintf("CPU em %.0f%%, %d pagamentos em memória", cpuRatio*100, depth)
+}
+
+// loadShed indica que o trabalho deve ser deixado de lado pela carga extrema.
+func (gw *gateway) loadShed(work int) bool {
+	if !gw.loadShedding.Load() {
+		return false
+	}
+	gw.loadShedSkipped[work].Add(1)
+	return true
+}
+
+// withLoadShed marca a gravação dos records para pular o histograma de valores
+// na carga extrema; os maiores pagamentos continuam sendo guardados.
+func (gw *gateway) withLoadShed(ctx context.Context, records int) context.Context {
+	if !gw.loadShedding.Load() {
+		return ctx
+	}
+	gw.loadShedSkipped[shedAmountHistogram].Add(int64(records))
+	return storage.WithoutAmountHistogram(ctx)
+}
+
+func (gw *gateway) loadShedStatus() LoadShedStatus {
+	status := LoadShedStatus{Active: gw.loadShedding.Load()}
+	if ratio := math.Float64frombits(gw.loadShedCPU.Load()); !math.IsNaN(ratio) {
+		status.CPURatio = &ratio
+	}
+	return status
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Saída dos logs (LOG_BUFFER): o log.Printf e o access log do Gin escrevem em
+// stdout/stderr com a requisição parada; quando o driver de log do Docker
+// atrasa a leitura do pipe, o atraso vai para a latência dos pagamentos. Com o
+// buffer, cada linha é copiada para uma fila e escrita por uma goroutine, que
+// junta as que estiverem esperando numa escrita só; com a fila cheia a linha é
+// descartada e contada. Até startAsyncLogs, na inicialização, e depois de
+// syncLogs, no encerramento, a escrita é direta: o log.Fatal sai do processo
+// logo depois de escrever e perderia a linha na fila.
+
+// Tamanho máximo de uma escrita de linhas acumuladas
+const logBatchBytes = 64 << 10
+
+var (
+	stderrLog = &asyncLog{out: os.Stderr}
+	stdoutLog = &asyncLog{out: os.Stdout}
+)
+
+func (gw *gateway) registerLogwriter() {
+	streams := map[string]*asyncLog{"stderr": stderrLog, "stdout": stdoutLog}
+	gw.registerMetric(metric{
+		Name: "log_lines_dropped_total",
+		Help: "Linhas de log descartadas com o buffer de escrita cheio, por saída.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, 0, len(streams))
+			for stream, w := range streams {
+				samples = append(samples, metricSample{Labels: map[string]string{"stream": stream}, Value: float64(w.dropped.Load())})
+			}
+			return samples
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "log_buffer_lines",
+		Help: "Linhas de log aguardando a escrita, por saída.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, 0, len(streams))
+			for stream, w := range streams {
+				samples = append(samples, metricSample{Labels: map[string]string{"stream": stream}, Value: float64(w.queued.Load())})
+			}
+			return samples
+		},
+	})
+}
+
+// asyncLog é uma saída de log com a fila de linhas.
+type asyncLog struct {
+	out io.Writer
+	// nil com LOG_BUFFER=0
+	lines   chan []byte
+	async   atomic.Bool
+	queued  atomic.Int64
+	dropped atomic.Int64
+	// Não intercala as escritas diretas com as da goroutine
+	mu sync.Mutex
+}
+
+// setupLogOutput passa o log para as saídas com fila, ainda em escrita direta;
+// nada com size zero. O Gin passa junto ao montar o router.
+func setupLogOutput(size int) {
+	if size == 0 {
+		return
+	}
+	for _, w := range []*asyncLog{stderrLog, stdoutLog} {
+		w.lines = make(chan []byte, size)
+		go w.run()
+	}
+	log.SetOutput(stderrLog)
+}
+
+// startAsyncLogs passa a escrever pela fila.
+func startAsyncLogs() {
+	for _, w := range []*asyncLog{stderrLog, stdoutLog} {
+		w.async.Store(w.lines != nil)
+	}
+}
+
+// syncLogs volta à escrita direta e espera, até timeout, a fila esvaziar.
+func syncLogs(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for _, w := range []*asyncLog{stderrLog, stdoutLog} {
+		w.async.Store(false)
+		for w.queued.Load() > 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+// logFatal é o log.Fatal que não perde a linha na fila.
+func logFatal(v ...any) {
+	syncLogs(time.Second)
+	log.Fatal(v...)
+}
+
+func (w *asyncLog) Write(p []byte) (int, error) {
+	if !w.async.Load() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return w.out.Write(p)
+	}
+
+	// O log reaproveita o buffer da linha
+	line := append([]byte(nil), p...)
+	w.queued.Add(1)
+	select {
+	case w.lines <- line:
+	default:
+		w.queued.Add(-1)
+		w.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// run escreve as linhas da fila até o fim do processo. Um erro de escrita só
+// perde o lote: não há a quem informá-lo.
+func (w *asyncLog) run() {
+	var batch bytes.Buffer
+	var reported int64
+	for line := range w.lines {
+		n := int64(1)
+		batch.Write(line)
+	collect:
+		for batch.Len() < logBatchBytes {
+			select {
+			case line := <-w.lines:
+				batch.Write(line)
+				n++
+			default:
+				break collect
+			}
+		}
+		if dropped := w.dropped.Load(); dropped > reported {
+			fmt.Fprintf(&batch, "%s %s%d linhas de log descartadas: saída lenta\n",
+				time.Now().Format("2006/01/02 15:04:05"), log.Prefix(), dropped-reported)
+			reported = dropped
+		}
+
+		w.mu.Lock()
+		w.out.Write(batch.Bytes())
+		w.mu.Unlock()
+		batch.Reset()
+		w.queued.Add(-n)
+	}
+}
@@ -0,0 +1,951 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/health"
+	pp "rinha-backend-2025/internal/processor"
+	"rinha-backend-2025/internal/processorstub"
+	"rinha-backend-2025/internal/queue"
+	"rinha-backend-2025/internal/retry"
+	"rinha-backend-2025/internal/storage"
+)
+
+// Estruturas de dados
+type PaymentRequest struct {
+	CorrelationID string  `json:"correlationId" binding:"required"`
+	Amount        float64 `json:"amount" binding:"required"`
+	// Opcional: recebe a notificação do fim do processamento se o host estiver em
+	// WEBHOOK_ALLOWED_HOSTS (ver webhook.go)
+	CallbackURL string `json:"callbackUrl,omitempty"`
+	// Código ISO 4217; BRL quando omitido. Os processors não o recebem: só o
+	// resumo por moeda (?byCurrency=true) o usa
+	Currency string `json:"currency,omitempty"`
+	// Enviado aos processors; zero até ser definido conforme REQUESTED_AT
+	RequestedAt time.Time `json:"-"`
+	// Objeto JSON opcional guardado com o registro (ver metadata.go)
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// X-Request-ID da requisição que trouxe o pagamento, para os logs do worker
+	RequestID string `json:"-"`
+	// Pânicos já recuperados no processamento, até WORKER_PANIC_RETRIES (ver panics.go)
+	Panics int `json:"-"`
+	// Pools de envio em que o pagamento já falhou (ver dispatch_pools.go)
+	PoolsTried []string `json:"-"`
+}
+
+type PaymentResponse struct {
+	Message string `json:"message"`
+}
+
+// PaymentSummaryResponse traz uma seção por processor. "default" e "fallback"
+// estão sempre presentes, mesmo zerados, para manter o formato da Rinha.
+type PaymentSummaryResponse map[string]ProcessorSummary
+
+func (gw *gateway) newPaymentSummary(summary map[string]storage.Summary) PaymentSummaryResponse {
+	response := PaymentSummaryResponse{"default": {}, "fallback": {}}
+	for _, name := range gw.processorNames {
+		response[name] = ProcessorSummary(summary[name])
+	}
+	return response
+}
+
+type ProcessorSummary struct {
+	TotalRequests int     `json:"totalRequests"`
+	TotalAmount   float64 `json:"totalAmount"`
+}
+
+func newRetryPolicy(cfg config.RetryConfig) (retry.Policy, error) {
+	retryOn, err := retry.ParseStatusList(cfg.RetryOnStatus)
+	if err != nil {
+		return retry.Policy{}, err
+	}
+	duplicate, err := retry.ParseStatusList(cfg.DuplicateStatus)
+	if err != nil {
+		return retry.Policy{}, err
+	}
+	terminal, err := retry.ParseStatusList(cfg.TerminalStatus)
+	if err != nil {
+		return retry.Policy{}, err
+	}
+	ambiguous, err := retry.ParseStatusList(cfg.AmbiguousStatus)
+	if err != nil {
+		return retry.Policy{}, err
+	}
+
+	return retry.Policy{
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   cfg.BaseDelay.Std(),
+		MaxDelay:    cfg.MaxDelay.Std(),
+		Jitter:      cfg.Jitter,
+		RetryOn:     retryOn,
+		Duplicate:   duplicate,
+		Terminal:    terminal,
+		Ambiguous:   ambiguous,
+	}, nil
+}
+
+func main() {
+	selfTestMode := flag.Bool("selftest", false, "valida o pipeline com pagamentos sintéticos e processors simulados e sai")
+	flag.Parse()
+
+	gw := newGateway(clock.Real())
+
+	// Carregar e validar configuração
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	}
+	// GOMAXPROCS e GOMEMLIMIT pelos limites do cgroup (RUNTIME_*), antes de o
+	// prefork dividi-los entre os filhos
+	tuneRuntime(cfg.Runtime)
+	// RUN_MODE=prefork: o pai só supervisiona os filhos (ver prefork.go)
+	if cfg.RunMode == config.RunPrefork && !*selfTestMode {
+		index, child := preforkChildIndex()
+		if !child {
+			log.Printf("Configuração efetiva: %s", cfg)
+			os.Exit(runPrefork(cfg))
+		}
+		gw.setupPreforkChild(&cfg, index)
+	}
+	// Configuração em vigor, recarregável em parte sem reiniciar (ver reload.go)
+	if err := gw.applyConfig(cfg); err != nil {
+		log.Fatalf("Política de retry inválida: %v", err)
+	}
+	// ID da instância nos logs, nas métricas e na auditoria (INSTANCE_ID)
+	gw.initInstance(cfg.Instance)
+	log.Printf("Configuração efetiva: %s", cfg)
+	// Logs por uma fila, sem segurar as requisições numa saída lenta (LOG_BUFFER)
+	setupLogOutput(cfg.LogBuffer)
+	// Recusar pagamentos perto do limite de memória, por processo (MEMORY_*)
+	gw.startMemoryGuard(cfg.Memory)
+
+	transport := newProcessorTransport(cfg.Warmup)
+	// Rodízio entre os endereços dos processors, reresolvidos periodicamente (PROCESSOR_DNS_*)
+	if dialer := gw.initProcessorDNS(cfg.HTTP, func() { gw.httpClient.CloseIdleConnections() }); dialer != nil {
+		transport.DialContext = dialer.DialContext
+	}
+	// Processors em https (PROCESSOR_CA_FILE, PROCESSOR_CLIENT_*_FILE)
+	transport.TLSClientConfig, err = newProcessorTLSConfig(cfg.HTTP)
+	if err != nil {
+		log.Fatalf("Configuração TLS dos processors inválida: %v", err)
+	}
+	gw.httpClient = &http.Client{Timeout: cfg.HTTP.Timeout.Std(), Transport: transport}
+	if cfg.HTTP.HTTP2 == "h2c" {
+		gw.httpClient.Transport = newH2CTransport(transport)
+	}
+	// Processors simulados, controlados pelo self-test (ver selftest.go)
+	var selfTestStubs map[string]*processorstub.Stub
+	switch {
+	case *selfTestMode:
+		selfTestStubs = gw.initSelfTestProcessors(cfg.ProcessorDefs(), gw.httpClient, cfg.SummaryCheck.AdminToken, cfg.Processors.Auth)
+	case cfg.DryRun.Enabled:
+		gw.initDryRunProcessors(cfg.ProcessorDefs(), cfg.DryRun)
+	default:
+		gw.initProcessors(cfg.ProcessorDefs(), gw.httpClient, cfg.SummaryCheck.AdminToken, cfg.Processors.Auth)
+	}
+	// Teto de valor e de TPS por processor, respeitados pelo roteamento
+	gw.initProcessorLimits(cfg.Processors.Limits)
+
+	// Injeção de falhas para testes de resiliência (CHAOS_*)
+	gw.initChaos(cfg.Chaos, cfg.HTTP.AttemptTimeout.Std(), cfg.Redis)
+	gw.wrapProcessorChaos()
+
+	// Inicializar Redis; fora do ar, a instância começa em modo degradado (memória)
+	ctx := context.Background()
+	redisClient := gw.newRedisClient(cfg.Redis)
+	if err := pingRedis(redisClient, cfg.Redis); err != nil {
+		log.Printf("Aviso: Não foi possível conectar ao Redis: %v. Usando memória até reconectar.", err)
+	} else {
+		gw.setCurrentRedis(redisClient)
+	}
+
+	// Namespace das chaves (RUN_ID), antes de qualquer chave ser usada
+	gw.initRunID(cfg.Redis, gw.currentRedis())
+
+	// Inicializar storage (STORAGE_BACKEND)
+	gw.store, err = storage.New(ctx, cfg.Storage, gw.currentRedis(), gw.processorNames, gw.appClock, gw.keys)
+	if err != nil {
+		log.Fatalf("Erro ao inicializar storage: %v", err)
+	}
+
+	// Rotas HTTP: no build padrão, Gin com CORS, compressão, OpenAPI e pprof; com
+	// -tags minimal, só as da Rinha sobre net/http (ver router_minimal.go)
+	router, debugSrv := gw.newRouter(cfg)
+
+	// Reconectar ao Redis e detectar quedas
+	gw.startRedisSupervisor(redisClient, cfg.Redis)
+	// Pipelines do outbox (REDIS_WRITER_*)
+	gw.startRedisWriter(cfg.Redis.Writer)
+
+	// Últimos health-checks e vereditos por processor (HEALTH_HISTORY_*)
+	gw.initHealthHistory(cfg.Health.History)
+
+	// Inicializar cache de health-check
+	gw.healthMonitor = health.New(health.Options{
+		Names:   gw.processorNames,
+		Clients: gw.processorClients,
+		Clock:   gw.appClock,
+		Redis:   gw.currentRedis,
+		Keys:    gw.keys,
+		Timeout: cfg.HTTP.HealthCheckTimeout.Std(),
+		// As conexões provavelmente caíram junto com o processor
+		OnRecover: func(processor string) {
+			go gw.warmProcessor(processor, cfg.Warmup.Connections)
+		},
+		OnUpdate:    gw.recordHealthCheck,
+		ProbeMargin: cfg.Health.ProbeMargin.Std(),
+		Instances:   cfg.HealthInstances(),
+		Slot:        cfg.Health.Slot,
+		Interval:    healthCheckInterval(cfg.Health),
+	})
+
+	// "failing" inferido das próprias tentativas (HEALTH_INFERENCE_*)
+	gw.initHealthInference(cfg.Health.Inference)
+
+	// Devolver o tráfego ao preferido quando ele se recuperar (FAILBACK_*)
+	gw.initFailback(cfg.Failback)
+
+	// Registrar as decisões de roteamento para o cmd/replay (SELECTOR_DECISION_LOG)
+	gw.initDecisionLog(cfg.Selector)
+
+	// Conferir uma amostra dos pagamentos aceitos nos processors (VERIFY_SAMPLE_*)
+	gw.initVerifySample(cfg.VerifySample)
+
+	// Limitar requisições simultâneas por processor
+	gw.initProcessorLimiters(cfg.Limiter)
+	// Espaçar os envios por processor (DISPATCH_PACING_*)
+	gw.initDispatchPacing(cfg.Pacing)
+	// Desviar tráfego quando o p99 do preferido estourar o orçamento (SLO_GUARD_*)
+	gw.initSLOGuard(cfg.SLO)
+
+	// Latências e desfechos observados, expostos em /admin/stats e usados pelo selector
+	gw.initLatencyStats(cfg.Selector.LatencyWindow.Std())
+	gw.initOutcomeStats(cfg.Selector.LatencyWindow.Std())
+
+	// Abrir conexões com os processors antes do primeiro pagamento
+	gw.warmProcessors(cfg.Warmup)
+	gw.startWarmupLoop(cfg.Warmup)
+
+	// Eventos em tempo real para dashboards (GET /payments/stream)
+	gw.initPaymentStream(cfg.Stream)
+
+	// Etapas do pagamento entregues a webhooks, auditoria, stream e métricas (EVENT_BUS_*)
+	gw.startEventBus(cfg.Events)
+
+	// Validação, dedup, rate limit, métricas e auditoria em volta do
+	// processamento, na ordem configurada (PIPELINE_MIDDLEWARES)
+	gw.initPaymentPipeline(cfg.Pipeline)
+
+	// Pools de envio por processor, com workers e fila próprios (PROCESSOR_<NOME>_WORKERS)
+	gw.initDispatchPools(cfg.Workers)
+
+	// Iniciar workers de processamento, com faixa prioritária por valor (PRIORITY_AMOUNT)
+	// e a fila em memória, no Redis ou no NATS (QUEUE_BACKEND)
+	queueBackend, err := gw.newQueueBackend(cfg.Queue)
+	if err != nil {
+		log.Fatalf("Erro ao configurar a fila de pagamentos: %v", err)
+	}
+	gw.paymentQueue, err = queue.New(queue.Options[PaymentRequest]{
+		Workers: cfg.Workers.Count,
+		Size:    cfg.Workers.QueueSize,
+		Process: func(ctx context.Context, req PaymentRequest) {
+			if gw.dispatchPools != nil {
+				gw.routePayment(withRequestID(ctx, req.RequestID), req)
+				return
+			}
+			gw.processPaymentRecovered(withRequestID(ctx, req.RequestID), req)
+		},
+		Label: func(req PaymentRequest) string {
+			return req.CorrelationID
+		},
+		Priority:      priorityLane(cfg.Workers.PriorityAmount),
+		PriorityBurst: cfg.Workers.PriorityBurst,
+		// Índice para DELETE /payments/:correlationId
+		Key: func(req PaymentRequest) string {
+			return req.CorrelationID
+		},
+		Backend:        queueBackend,
+		Encode:         encodeQueuedPayment,
+		Decode:         decodeQueuedPayment,
+		Prefetch:       cfg.Queue.Prefetch,
+		PublishTimeout: cfg.Queue.PublishTimeout.Std(),
+		Clock:          gw.appClock,
+	})
+	if err != nil {
+		log.Fatalf("Erro ao abrir a fila de pagamentos: %v", err)
+	}
+	// Vazão dos workers para X-Estimated-Delay-Ms (ver backpressure.go)
+	gw.startThroughputSampler()
+	// Desligar o trabalho acessório sob CPU ou fila acima do limite (LOAD_SHED_*)
+	gw.startLoadShedMonitor(cfg.LoadShed)
+
+	// Repassar excesso de fila para as outras instâncias (PEER_URLS)
+	gw.initPeers(cfg.Peers)
+
+	// Notificar o fim do processamento (callbackUrl ou WEBHOOK_URL)
+	if err := gw.startWebhookWorkers(cfg.Webhook); err != nil {
+		log.Fatalf("Configuração de webhooks inválida: %v", err)
+	}
+
+	// Reprocessar pagamentos da DLQ quando os processors se recuperarem
+	gw.startDLQRedrive(cfg.DLQ.RedriveInterval.Std())
+
+	// Enviar contadores ao Redis em lotes
+	if err := gw.startCounterFlusher(cfg.Counters); err != nil {
+		log.Fatalf("Erro ao abrir o WAL dos contadores: %v", err)
+	}
+	gw.paymentsSummaryCache.ttl = cfg.Counters.SummaryCacheTTL.Std()
+
+	// Copiar o resumo para fora do storage e restaurar o que ele perder (COUNTER_SNAPSHOT_*)
+	gw.startSummarySnapshots(cfg.Counters.Snapshot, cfg.Storage.Backend)
+
+	// Histórico por pagamento em Redis Stream (AUDIT_LOG)
+	gw.startAuditLog(cfg.Audit)
+	// Pagamentos por status para GET /admin/payments (STATUS_INDEX)
+	gw.startStatusIndex(cfg.StatusIndex)
+
+	// Conferir nos processors os pagamentos com resultado desconhecido
+	gw.startOutboxReconciler(cfg.Outbox)
+
+	// Comparar os contadores com /admin/payments-summary dos processors (SUMMARY_CHECK)
+	gw.startSummaryCheck(cfg.SummaryCheck, cfg.Storage, cfg.Peers)
+
+	// Iniciar servidor, com TLS nas portas TCP quando configurado (CERT_FILE/KEY_FILE)
+	serverTLS, err := newServerTLSConfig(cfg.TLS)
+	if err != nil {
+		log.Fatalf("Configuração TLS inválida: %v", err)
+	}
+	listeners, err := gw.openListeners(cfg, serverTLS)
+	if err != nil {
+		log.Fatalf("Erro ao abrir listeners: %v", err)
+	}
+	srv := gw.newHTTPServer(gw.instanceHandler(router, cfg.Instance), cfg.Server)
+	srv.RegisterOnShutdown(gw.closePaymentStreams)
+	serveListeners(srv, listeners)
+
+	// Ingestão via gRPC (GRPC_PORT), com a mesma fila e o mesmo storage
+	grpcSrv, err := gw.startGRPCServer(cfg.GRPC, serverTLS)
+	if err != nil {
+		log.Fatalf("Erro ao iniciar servidor gRPC: %v", err)
+	}
+
+	// Recarregar a parte recarregável da configuração no SIGHUP
+	gw.startConfigReload()
+
+	// Inicialização concluída: liberar a readiness
+	startAsyncLogs()
+	gw.appReady.Store(true)
+
+	// No self-test, o processo encerra ao fim das verificações
+	selfTestDone := make(chan int, 1)
+	if *selfTestMode {
+		go func() {
+			selfTestDone <- gw.runSelfTest(router, selfTestStubs, cfg)
+		}()
+	}
+
+	// Encerramento gracioso: parar de aceitar requisições, drenar workers e contadores
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	exitCode := 0
+	select {
+	case <-stop:
+	case exitCode = <-selfTestDone:
+	}
+	log.Printf("Encerrando servidor...")
+	// A readiness falha e as respostas pedem Connection: close antes da drenagem
+	gw.appShuttingDown.Store(true)
+	gw.waitPreStop(cfg.Server.PreStopDelay.Std())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Std())
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Erro ao encerrar servidor HTTP: %v (%d requisições em andamento)", err, gw.httpInFlight.Load())
+	}
+	if grpcSrv != nil {
+		stopGRPCServer(shutdownCtx, grpcSrv)
+	}
+	if debugSrv != nil {
+		debugSrv.Shutdown(shutdownCtx)
+	}
+	gw.paymentQueue.Stop(shutdownCtx)
+	gw.stopDispatchPools(shutdownCtx)
+	gw.stopEventBus(shutdownCtx)
+	gw.stopWebhookWorkers(shutdownCtx)
+	gw.shutdownCounters(shutdownCtx)
+	log.Printf("Servidor encerrado")
+	syncLogs(time.Second)
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// paymentReply é a resposta do POST /payments, escrita pelo router do build: o
+// corpo fixo de sucesso ou, com code, o erro no envelope padrão.
+type paymentReply struct {
+	status  int
+	body    staticResponse
+	code    string
+	message localizedMessage
+	details any
+}
+
+func paymentError(status int, code string, message localizedMessage) paymentReply {
+	return paymentReply{status: status, code: code, message: message}
+}
+
+// servePayment decodifica, confere e aceita o pagamento do POST /payments;
+// grava em w só os headers.
+func (gw *gateway) servePayment(w http.ResponseWriter, r *http.Request) paymentReply {
+	decoder, ok := lookupPaymentDecoder(r.Header.Get("Content-Type"))
+	if !ok {
+		return paymentError(http.StatusUnsupportedMediaType, errCodeUnsupportedMediaType, msg(msgPaymentContentType))
+	}
+
+	// Ler o corpo inteiro antes de decodificar: o decoder não preserva o erro de limite
+	buf := getBuffer()
+	defer putBuffer(buf)
+	body, err := readInto(*buf, http.MaxBytesReader(w, r.Body, gw.currentConfig().Validation.MaxBodyBytes))
+	*buf = body
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return paymentError(http.StatusRequestEntityTooLarge, errCodeBodyTooLarge, msg(msgBodyTooLarge, tooLarge.Limit))
+		}
+		return paymentError(http.StatusBadRequest, errCodeInvalidBody, msg(msgReadBody))
+	}
+
+	req, err := gw.decodePayment(decoder, body, gw.currentConfig().Validation.MaxAmount)
+	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			reply := paymentError(http.StatusUnprocessableEntity, errCodeInvalidPayload, msg(msgInvalidPayload))
+			reply.details = validationErr.Fields
+			return reply
+		}
+		return paymentError(http.StatusBadRequest, errCodeInvalidBody, errMessage(err))
+	}
+	if req.CallbackURL != "" && !gw.callbackAllowed(req.CallbackURL) {
+		return paymentError(http.StatusBadRequest, errCodeCallbackNotAllowed, msg(msgCallbackNotAllowed))
+	}
+
+	forwarded := r.Header.Get(peerForwardedHeader) != ""
+	if forwarded {
+		// Mantém o instante definido pela instância que recebeu o pagamento
+		req.RequestedAt = parsePeerRequestedAt(r.Header.Get(peerRequestedAtHeader))
+	}
+	req.RequestID = requestIDFrom(r.Context())
+	if conflict, ok := gw.findAmountConflict(r.Context(), req); ok {
+		reply := paymentError(http.StatusUnprocessableEntity, errCodeAmountConflict, msg(msgAmountConflict))
+		reply.details = conflict
+		return reply
+	}
+
+	result := gw.acceptPayment(r.Context(), req, forwarded)
+	gw.setBackpressureHeaders(w.Header())
+	switch result {
+	case ackReceived:
+		return paymentReply{status: http.StatusOK, body: paymentReceivedResponse}
+	case ackQueued:
+		return paymentReply{status: http.StatusAccepted, body: paymentQueuedResponse}
+	case ackProcessed:
+		return paymentReply{status: http.StatusOK, body: paymentProcessedResponse}
+	case ackQueueFull:
+		w.Header().Set("Retry-After", "1")
+		return paymentError(http.StatusServiceUnavailable, errCodeQueueFull, msg(msgQueueFull))
+	default:
+		return paymentError(http.StatusBadGateway, errCodeProcessorsFailed, msg(msgProcessorsFailed))
+	}
+}
+
+// sendResult é o desfecho do envio de um pagamento.
+type sendResult int
+
+const (
+	sendFailed sendResult = iota
+	sendSucceeded
+	// O limitador de concorrência não tinha vaga: devolver para a fila
+	sendShed
+	// Sem confirmação nem recusa (ex.: timeout): fica no outbox para a reconciliação
+	sendUnknown
+	// O orçamento do fallback ou o TPS do processor não comportava o pagamento:
+	// devolver para a fila
+	sendDeferred
+	// O processor recusou o correlationId por já tê-lo; dispatchPayment confere
+	// com ele (ver duplicates.go)
+	sendDuplicate
+	// Recusa definitiva (RETRY_TERMINAL_STATUS): os outros processors não são
+	// tentados e o pagamento vai para a DLQ
+	sendRejected
+)
+
+// processPayment envia o pagamento e dá destino aos que não foram aceitos:
+// sem vaga no limitador ou no orçamento do fallback voltam para a fila, falhas
+// vão para a DLQ.
+func (gw *gateway) processPayment(ctx context.Context, req PaymentRequest) sendResult {
+	// Orçamento total do pagamento, somando todas as tentativas e o fallback
+	ctx, cancel := context.WithTimeout(ctx, gw.currentConfig().Retry.PaymentBudget.Std())
+	defer cancel()
+
+	// No modo "send", o primeiro envio define o instante; a fila e a DLQ o preservam
+	if req.RequestedAt.IsZero() {
+		req.RequestedAt = gw.newRequestedAt()
+	}
+
+	result := gw.dispatchPayment(ctx, req)
+	switch result {
+	case sendSucceeded, sendUnknown:
+		return result
+	case sendShed:
+		gw.paymentQueue.Requeue(req, gw.currentConfig().Limiter.RequeueDelay.Std())
+		return result
+	case sendDeferred:
+		gw.paymentQueue.Requeue(req, gw.currentConfig().FallbackBudget.Delay.Std())
+		return result
+	case sendFailed:
+		// Com pools de envio, o pool de outro processor tenta antes da DLQ
+		if gw.handOffPayment(ctx, req) {
+			return sendShed
+		}
+	}
+
+	// Esgotou as tentativas em todos os processors: estacionar na DLQ
+	gw.pushToDLQ(gw.newDeadLetter(req))
+	gw.publishEvent(PaymentFailed, req, "")
+	return result
+}
+
+// dispatchPayment envia o pagamento aos processors na ordem do selector, até um
+// aceitar, e atualiza os contadores em caso de sucesso.
+func (gw *gateway) dispatchPayment(ctx context.Context, req PaymentRequest) sendResult {
+	ctx = gw.withSentRequestedAt(gw.withAmbiguousAttempts(ctx))
+	// Ordem de tentativa definida pela estratégia configurada (SELECTOR_STRATEGY),
+	// sem os processors cujo teto o valor ultrapassa (PROCESSOR_<NOME>_MAX_AMOUNT)
+	candidates := gw.routingCandidates(ctx)
+	ranking := gw.filterByAmount(gw.rankCandidates(candidates), req.Amount)
+	if len(ranking) == 0 {
+		gw.logf(ctx, "Nenhum processor aceita o valor %.2f de %s", req.Amount, req.CorrelationID)
+		return sendFailed
+	}
+	// Nos pools de envio, só o processor do pool e os sem pool (PROCESSOR_<NOME>_WORKERS)
+	if ranking = gw.poolRanking(ctx, ranking); len(ranking) == 0 {
+		return sendFailed
+	}
+	processor := ranking[0]
+	start := gw.appClock.Now()
+	epoch := gw.currentEpoch()
+
+	// Preparar requisição para o PP; cada tentativa envia o requestedAt de
+	// REQUESTED_AT_RETRY (ver requested_at.go)
+	requestedAt := req.RequestedAt
+	payment := pp.Payment{CorrelationID: req.CorrelationID, Amount: req.Amount, RequestedAt: requestedAt}
+	if gw.currentConfig().Processors.ForwardMetadata {
+		payment.Metadata = req.Metadata
+	}
+
+	// Registrar a intenção antes do envio: se o resultado se perder, a reconciliação resolve
+	outboxEntry := OutboxEntry{
+		CorrelationID: req.CorrelationID,
+		Amount:        req.Amount,
+		CallbackURL:   req.CallbackURL,
+		Currency:      req.Currency,
+		RequestedAt:   requestedAt,
+		CreatedAt:     gw.appClock.Now(),
+		Metadata:      req.Metadata,
+	}
+	if gw.outboxEnabled {
+		gw.outboxBegin(outboxEntry)
+	}
+
+	var result sendResult
+	tried := 1
+	if verdict, ok := gw.cachedDuplicate(req.CorrelationID); ok {
+		// Um processor já disse ter o pagamento: contar sem reenviar
+		processor, result = verdict.Processor, sendDuplicate
+	} else if gw.currentConfig().Hedging.Enabled && len(ranking) > 1 && !gw.processorFailing(ranking[1], gw.healthMonitor.Get(ctx, ranking[1])) {
+		// Corrida entre os dois primeiros quando o preferido demora a responder
+		processor, result = gw.hedgedSend(ctx, payment, ranking[0], ranking[1])
+		tried = 2
+	} else {
+		// Tentar processar com o PP selecionado
+		result = gw.sendToProcessor(ctx, processor, payment)
+	}
+
+	// Se falhou ou estourou o orçamento do fallback, seguir para os próximos da ordem
+	deferred := result == sendDeferred
+	for _, next := range ranking[tried:] {
+		if (result != sendFailed && result != sendDeferred) || ctx.Err() != nil {
+			break
+		}
+		if result == sendFailed {
+			gw.logf(ctx, "Falha no processor %s, tentando %s para %s", processor, next, req.CorrelationID)
+		}
+		processor = next
+		result = gw.sendToProcessor(ctx, next, payment)
+		deferred = deferred || result == sendDeferred
+	}
+	// A recusa definitiva segue como falha, sem passar para o pool de outro processor
+	rejected := result == sendRejected
+	if rejected {
+		result = sendFailed
+	}
+	// Contar com o requestedAt que o processor aceitou
+	if result == sendSucceeded {
+		requestedAt = lastRequestedAtSent(ctx, processor, requestedAt)
+	}
+
+	// Recusado por repetido: contar com o registro do processor que já o tinha
+	if result == sendDuplicate {
+		var verdict duplicateVerdict
+		verdict, result = gw.resolveDuplicate(ctx, req.CorrelationID, processor)
+		if result == sendSucceeded {
+			if !sameCents(verdict.Amount, req.Amount) {
+				gw.logf(ctx, "Pagamento %s registrado no %s com %.2f, recebido com %.2f", req.CorrelationID, processor, verdict.Amount, req.Amount)
+			}
+			req.Amount = verdict.Amount
+			if !verdict.RequestedAt.IsZero() {
+				requestedAt = verdict.RequestedAt
+			}
+		}
+	}
+	// Antes de declarar a falha, conferir se alguma tentativa sem resposta foi aceita
+	if result == sendFailed {
+		if verified, verifiedAt, ok := gw.verifyAmbiguousAttempts(ctx, req.CorrelationID); ok {
+			processor, result = verified, sendSucceeded
+			if !verifiedAt.IsZero() {
+				requestedAt = verifiedAt
+			}
+		}
+	}
+	// Um fallback adiado pelo orçamento ainda pode aceitar depois: não é falha
+	if result == sendFailed && deferred {
+		result = sendDeferred
+	}
+	if result == sendFailed && rejected {
+		result = sendRejected
+	}
+
+	// Atualizar contadores se o pagamento foi processado com sucesso
+	switch result {
+	case sendSucceeded:
+		if gw.outboxEnabled {
+			gw.outboxClaim(req.CorrelationID)
+		}
+		gw.recordSLOLatency(processor, gw.appClock.Since(start))
+		gw.recordFallbackBudgetVolume(req.Amount)
+		gw.recordSuccessfulPayment(storage.Record{
+			CorrelationID: req.CorrelationID,
+			Amount:        req.Amount,
+			Processor:     processor,
+			RequestedAt:   requestedAt,
+			Currency:      req.Currency,
+			Metadata:      req.Metadata,
+			Epoch:         epoch,
+		})
+		gw.logf(ctx, "Pagamento %s processado com sucesso pelo %s", req.CorrelationID, processor)
+		gw.publishEvent(PaymentSettled, req, processor)
+		gw.verifySample(ctx, processor, req.CorrelationID, req.Amount)
+	case sendUnknown:
+		// Outro processor poderia cobrar o mesmo pagamento de novo
+		outboxEntry.Unknown = true
+		gw.outboxBegin(outboxEntry)
+		gw.paymentsUnknown.Add(1)
+		gw.recordAudit(AuditEntry{CorrelationID: req.CorrelationID, Event: auditUnknown, Processor: processor})
+		gw.logf(ctx, "Resultado do pagamento %s no %s desconhecido, aguardando reconciliação", req.CorrelationID, processor)
+	case sendFailed:
+		gw.logf(ctx, "Falha ao processar pagamento %s", req.CorrelationID)
+	case sendDeferred:
+		gw.logf(ctx, "Pagamento %s adiado pelo orçamento do fallback", req.CorrelationID)
+	case sendRejected:
+		gw.logf(ctx, "Pagamento %s recusado de forma definitiva", req.CorrelationID)
+	}
+	// Para o replay de estratégias (SELECTOR_DECISION_LOG)
+	gw.recordDecision(candidates, ranking, processor, result, req.Amount, gw.appClock.Since(start))
+
+	return result
+}
+
+// newRequestedAt retorna o instante atual na precisão aceita pelos processors.
+func (gw *gateway) newRequestedAt() time.Time {
+	return gw.appClock.Now().UTC().Truncate(time.Millisecond)
+}
+
+func (gw *gateway) sendToProcessor(ctx context.Context, processor string, payment pp.Payment) (result sendResult) {
+	// Sem vaga no TPS do processor, os próximos da ordem tentam (PROCESSOR_<NOME>_MAX_TPS)
+	if !gw.takeProcessorTPS(processor) {
+		return sendDeferred
+	}
+	// O valor fica reservado no orçamento do fallback só se o processor ficar com ele
+	reservation, ok := gw.reserveFallback(processor, payment.Amount)
+	if !ok {
+		return sendDeferred
+	}
+	defer func() { gw.settleFallback(reservation, result) }()
+
+	limiter := gw.processorLimiters[processor]
+	original := payment.RequestedAt
+	// A mesma política em todas as tentativas, mesmo que a configuração seja recarregada
+	retryPolicy := gw.currentConfig().retry
+	gw.publishEvent(PaymentRouted, PaymentRequest{
+		CorrelationID: payment.CorrelationID,
+		Amount:        payment.Amount,
+		RequestedAt:   payment.RequestedAt,
+	}, processor)
+
+	// Retry conforme a política configurada (RETRY_*), mais curto com o SLO violado
+	attempts := gw.sloMaxAttempts(retryPolicy.MaxAttempts)
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if !gw.waitForRetry(ctx, processor, retryPolicy.Delay(attempt)) {
+				return sendFailed
+			}
+		}
+
+		if err := gw.waitForDispatchSlot(ctx, processor); err != nil {
+			if !errors.Is(err, errDispatchBusy) {
+				return sendFailed
+			}
+			gw.recordAudit(AuditEntry{CorrelationID: payment.CorrelationID, Event: auditShed, Processor: processor})
+			return sendShed
+		}
+		if limiter != nil && !limiter.TryAcquire() {
+			gw.recordAudit(AuditEntry{CorrelationID: payment.CorrelationID, Event: auditShed, Processor: processor})
+			return sendShed
+		}
+		payment.RequestedAt = gw.attemptRequestedAt(original)
+		markRequestedAtSent(ctx, processor, payment.RequestedAt)
+		start := gw.appClock.Now()
+		status, err := gw.postPayment(ctx, processor, payment, attempt)
+		entry := AuditEntry{
+			CorrelationID: payment.CorrelationID,
+			Event:         auditAttempt,
+			Attempt:       attempt + 1,
+			Processor:     processor,
+			Status:        status,
+		}
+		// Formatado só com a auditoria ligada: o caminho do envio é quente
+		if gw.auditEnabled {
+			entry.RequestedAt = payment.RequestedAt.Format(time.RFC3339Nano)
+		}
+		gw.recordAudit(entry)
+		ok := status >= 200 && status < 300
+		latency := gw.appClock.Since(start)
+		if limiter != nil {
+			limiter.Release(ok, latency)
+		}
+		// Chamadas canceladas (hedge perdido, orçamento esgotado) não medem o processor
+		if ctx.Err() == nil {
+			gw.recordLatency(processor, latency)
+			gw.recordOutcome(processor, ok)
+			gw.observeFailback(processor, ok)
+			gw.observeInference(processor, ok, latency)
+		}
+
+		if ok {
+			return sendSucceeded
+		}
+		class := retryPolicy.Classify(status)
+		if class == retry.ClassRetryable && pp.Ambiguous(err) {
+			class = retry.ClassAmbiguous
+		}
+		gw.recordFailureClass(processor, class)
+		switch class {
+		case retry.ClassDuplicate:
+			// Já aceito numa tentativa anterior: repetir ou seguir para o próximo
+			// processor cobraria duas vezes
+			return sendDuplicate
+		case retry.ClassAmbiguous:
+			// O processor pode ter aceitado: repetir arriscaria cobrar duas vezes,
+			// então quem decide é a reconciliação do outbox (sem outbox, segue o
+			// retry e a conferência antes da falha)
+			if gw.outboxEnabled {
+				return sendUnknown
+			}
+			markAmbiguous(ctx, processor)
+		case retry.ClassTerminal:
+			gw.logf(ctx, "Status %d do %s é uma recusa definitiva, desistindo de %s", status, processor, payment.CorrelationID)
+			return sendRejected
+		}
+		if !retryPolicy.ShouldRetry(status) {
+			gw.logf(ctx, "Status %d do %s não é repetível, desistindo", status, processor)
+			return sendFailed
+		}
+	}
+
+	return sendFailed
+}
+
+// postPayment faz uma única tentativa, limitada pelo timeout por tentativa, e
+// retorna o status HTTP (0 em caso de erro de rede, timeout ou 2xx sem
+// confirmação) e o erro.
+func (gw *gateway) postPayment(ctx context.Context, processor string, payment pp.Payment, attempt int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, gw.currentConfig().HTTP.AttemptTimeout.Std())
+	defer cancel()
+
+	err := gw.processorClients[processor].SubmitPayment(ctx, payment)
+	if err != nil {
+		gw.logf(ctx, "Erro na tentativa %d para %s: %v", attempt+1, processor, err)
+	}
+	return pp.StatusCode(err), err
+}
+
+// waitForRetry aguarda o backoff, desistindo se o contexto for cancelado ou se o
+// tempo restante não comportar o backoff mais o minResponseTime do processor.
+func (gw *gateway) waitForRetry(ctx context.Context, processor string, delay time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok {
+		expected := time.Duration(gw.healthMonitor.Get(ctx, processor).MinResponseTime) * time.Millisecond
+		if gw.appClock.Until(deadline) < delay+expected {
+			gw.logf(ctx, "Orçamento insuficiente para nova tentativa no %s, desistindo", processor)
+			return false
+		}
+	}
+
+	timer := gw.appClock.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+type summaryOptions struct {
+	// Aguarda as escritas em andamento e bloqueia novas durante a leitura
+	Consistent bool
+	// Lê direto do storage, sem o cache do resumo sem filtro
+	NoCache bool
+}
+
+// loadPaymentsSummary atende o resumo tanto pelo HTTP quanto pelo gRPC.
+func (gw *gateway) loadPaymentsSummary(from, to time.Time, opts summaryOptions) PaymentSummaryResponse {
+	unfiltered := from.IsZero() && to.IsZero()
+	cluster := gw.currentConfig().ClusterSummary()
+
+	// Leituras consistentes nunca vêm do cache. O resumo do cluster também não:
+	// o cache de cada instância envelheceria em momentos diferentes, e as duas
+	// responderiam totais diferentes conforme a que o nginx escolhesse
+	useCache := unfiltered && !opts.Consistent && !opts.NoCache && !cluster
+	var generation uint64
+	if useCache {
+		cached, gen, ok := gw.paymentsSummaryCache.get()
+		if ok {
+			return cached
+		}
+		generation = gen
+	}
+
+	// As outras instâncias descarregam os pendentes antes da leitura do storage.
+	// Sem segurar counterFlushGate: duas leituras consistentes simultâneas, cada
+	// uma esperando a outra instância, travariam até o timeout
+	var peerDeltas []map[string]ProcessorSummary
+	if cluster {
+		peerDeltas = gw.fetchPeerDeltas(from, to)
+	}
+
+	if opts.Consistent {
+		gw.counterFlushGate.Lock()
+		defer gw.counterFlushGate.Unlock()
+		gw.flushCountersLocked(context.Background())
+	} else {
+		// Descarregar os deltas pendentes desta instância antes de ler
+		gw.flushCounters()
+	}
+
+	// Sem filtro, os contadores respondem direto; com filtro, agregar os registros
+	var summary PaymentSummaryResponse
+	if unfiltered {
+		summary = gw.getPaymentsSummary()
+	} else {
+		summary = gw.getPaymentsSummaryByRange(from, to)
+	}
+
+	addPeerDeltas(summary, peerDeltas)
+	if useCache {
+		gw.paymentsSummaryCache.set(summary, generation)
+	}
+	return summary
+}
+
+func parseSummaryRange(fromStr, toStr string) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+
+	if fromStr != "" {
+		if from, err = time.Parse(time.RFC3339Nano, fromStr); err != nil {
+			return from, to, newMessageError(msgInvalidFrom)
+		}
+	}
+	if toStr != "" {
+		if to, err = time.Parse(time.RFC3339Nano, toStr); err != nil {
+			return from, to, newMessageError(msgInvalidTo)
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return from, to, newMessageError(msgRangeOrder)
+	}
+	return from, to, nil
+}
+
+func (gw *gateway) getPaymentsSummary() PaymentSummaryResponse {
+	summary, err := gw.store.GetSummary(context.Background())
+	if err != nil {
+		log.Printf("Erro ao obter resumo do storage: %v", err)
+		return gw.newPaymentSummary(nil)
+	}
+	return gw.newPaymentSummary(summary)
+}
+
+func (gw *gateway) getPaymentsSummaryByRange(from, to time.Time) PaymentSummaryResponse {
+	// Limites ausentes cobrem todo o histórico
+	if to.IsZero() {
+		to = gw.appClock.Now().Add(time.Hour)
+	}
+
+	summary, err := gw.store.QueryByRange(context.Background(), from, to)
+	if err != nil {
+		log.Printf("Erro ao consultar resumo por período: %v", err)
+		return gw.newPaymentSummary(nil)
+	}
+	return gw.newPaymentSummary(summary)
+}
+
+// purgePayments apaga contadores e pagamentos registrados.
+func (gw *gateway) purgePayments(ctx context.Context) error {
+	gw.counterFlushGate.Lock()
+	defer gw.counterFlushGate.Unlock()
+
+	if err := gw.purgeOutbox(ctx); err != nil {
+		gw.logf(ctx, "Erro ao apagar outbox: %v", err)
+	}
+	if err := gw.purgeAudit(ctx); err != nil {
+		gw.logf(ctx, "Erro ao apagar auditoria: %v", err)
+	}
+	if err := gw.purgeStatusIndex(ctx); err != nil {
+		gw.logf(ctx, "Erro ao apagar índices por status: %v", err)
+	}
+	err := gw.store.Purge(ctx)
+	// Os envios em andamento ficam na época anterior
+	gw.discardPendingCounters(gw.purgedEpoch())
+	gw.paymentsSummaryCache.invalidate()
+	if err != nil {
+		gw.logf(ctx, "Erro ao apagar pagamentos: %v", err)
+		return err
+	}
+	gw.resetSummaryCheck(ctx)
+	gw.markSnapshotPurge(ctx)
+	return nil
+}
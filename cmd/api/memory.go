@@ -0,0 +1,195 @@
+package main
+
+import (
+	"log"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Guarda de memória (MEMORY_*): com o limite de 350MB do container, o OOM kill
+// perde a fila e os contadores ainda não enviados. Os contadores pendentes e o
+// storage em memória não têm teto, porque descartá-los perderia pagamentos já
+// aceitos pelos processors: quem os limita é a recusa de novos pagamentos, que
+// também segura a fila e as goroutines fora do pool.
+
+// Intervalo mínimo entre os GCs forçados enquanto a memória segue acima da marca
+const forcedGCInterval = 5 * time.Second
+
+// Estruturas em memória com teto, em memory_evicted_total
+const (
+	evictedDLQ = iota
+	evictedOutbox
+	evictedDedup
+	evictedDuplicates
+)
+
+var evictedStoreNames = [...]string{evictedDLQ: "dlq", evictedOutbox: "outbox", evictedDedup: "dedup", evictedDuplicates: "duplicates"}
+
+// memoryState é o estado da guarda de memória
+type memoryState struct {
+	// Orçamento e memória obtida do SO menos a devolvida, na última leitura
+	memoryBudget atomic.Int64
+	memoryUsed   atomic.Int64
+	// Acima da marca alta: pagamentos recusados até baixar da marca baixa
+	memoryPressure atomic.Bool
+
+	memoryShed     atomic.Int64
+	memoryForcedGC atomic.Int64
+	memoryEvicted  [len(evictedStoreNames)]atomic.Int64
+}
+
+// MemoryStatus é o estado da guarda de memória, em /healthz.
+type MemoryStatus struct {
+	UsedBytes int64 `json:"usedBytes"`
+	// Ausente sem orçamento (MEMORY_BUDGET_BYTES nem GOMEMLIMIT)
+	BudgetBytes int64 `json:"budgetBytes,omitempty"`
+	Pressure    bool  `json:"pressure"`
+}
+
+func (gw *gateway) registerMemory() {
+	gw.registerMetric(metric{
+		Name: "memory_used_bytes",
+		Help: "Memória obtida do SO menos a devolvida, na última leitura da guarda de memória.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.memoryUsed.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "memory_budget_bytes",
+		Help: "Orçamento da guarda de memória; ausente sem orçamento.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			if budget := gw.memoryBudget.Load(); budget > 0 {
+				return []metricSample{{Value: float64(budget)}}
+			}
+			return nil
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "memory_pressure",
+		Help: "1 enquanto a memória passa da marca alta e os pagamentos são recusados.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			if gw.memoryPressure.Load() {
+				return []metricSample{{Value: 1}}
+			}
+			return []metricSample{{Value: 0}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "memory_shed_total",
+		Help: "Pagamentos recusados com 503 pela guarda de memória.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.memoryShed.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "memory_forced_gc_total",
+		Help: "GCs forçados pela guarda de memória.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.memoryForcedGC.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "memory_evicted_total",
+		Help: "Entradas descartadas por estrutura em memória (dlq, outbox, dedup, duplicates) ao atingir o teto.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, len(gw.memoryEvicted))
+			for i := range gw.memoryEvicted {
+				samples[i] = metricSample{
+					Labels: map[string]string{"store": evictedStoreNames[i]},
+					Value:  float64(gw.memoryEvicted[i].Load()),
+				}
+			}
+			return samples
+		},
+	})
+}
+
+// startMemoryGuard inicia o monitor; roda depois de tuneRuntime, para usar o
+// GOMEMLIMIT já definido.
+func (gw *gateway) startMemoryGuard(cfg config.MemoryConfig) {
+	budget := cfg.BudgetBytes
+	if budget == 0 {
+		if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+			budget = limit
+		}
+	}
+	gw.memoryBudget.Store(budget)
+	if cfg.CheckInterval == 0 {
+		return
+	}
+	if budget == 0 {
+		log.Printf("Guarda de memória sem orçamento (MEMORY_BUDGET_BYTES ou GOMEMLIMIT): só medindo")
+	} else {
+		log.Printf("Guarda de memória: recusa acima de %s, volta abaixo de %s",
+			formatBytes(uint64(float64(budget)*cfg.HighWatermark)), formatBytes(uint64(float64(budget)*cfg.LowWatermark)))
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.CheckInterval.Std())
+		defer ticker.Stop()
+
+		var lastGC time.Time
+		for range ticker.C {
+			used := gw.readMemoryUsed()
+			if budget == 0 {
+				continue
+			}
+			ratio := float64(used) / float64(budget)
+			switch {
+			case ratio >= cfg.HighWatermark:
+				if !gw.memoryPressure.Swap(true) {
+					log.Printf("Aviso: memória em %s de %s, recusando pagamentos", formatBytes(uint64(used)), formatBytes(uint64(budget)))
+				}
+				// Depois do GC, a próxima leitura já mostra o que foi devolvido ao SO
+				if now := time.Now(); now.Sub(lastGC) >= forcedGCInterval {
+					lastGC = now
+					gw.memoryForcedGC.Add(1)
+					debug.FreeOSMemory()
+				}
+			case ratio < cfg.LowWatermark:
+				if gw.memoryPressure.Swap(false) {
+					log.Printf("Memória em %s de %s, aceitando pagamentos de novo", formatBytes(uint64(used)), formatBytes(uint64(budget)))
+				}
+			}
+		}
+	}()
+}
+
+// readMemoryUsed lê a memória que conta para o limite do container, a mesma
+// que o GOMEMLIMIT acompanha.
+func (gw *gateway) readMemoryUsed() int64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	used := int64(m.Sys - m.HeapReleased)
+	gw.memoryUsed.Store(used)
+	return used
+}
+
+// memoryShedding indica que n pagamentos devem ser recusados pela pressão de
+// memória.
+func (gw *gateway) memoryShedding(n int) bool {
+	if !gw.memoryPressure.Load() {
+		return false
+	}
+	gw.memoryShed.Add(int64(n))
+	return true
+}
+
+func (gw *gateway) memoryStatus() MemoryStatus {
+	return MemoryStatus{
+		UsedBytes:   gw.memoryUsed.Load(),
+		BudgetBytes: gw.memoryBudget.Load(),
+		Pressure:    gw.memoryPressure.Load(),
+	}
+}
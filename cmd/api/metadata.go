@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"time"
+
+	json "github.com/goccy/go-json"
+)
+
+// Metadata dos pagamentos: um objeto JSON opcional em POST /payments, de até
+// MAX_METADATA_BYTES compactado, que segue com o pagamento pela fila, DLQ e
+// outbox, é guardado com o registro e volta em GET /payments/:correlationId.
+// Serve para marcar a origem do tráfego em experimentos; os processors só o
+// recebem com PROCESSOR_FORWARD_METADATA.
+
+// PaymentRecordResponse é a resposta de GET /payments/:correlationId.
+type PaymentRecordResponse struct {
+	CorrelationID string          `json:"correlationId"`
+	Amount        float64         `json:"amount"`
+	Processor     string          `json:"processor"`
+	RequestedAt   time.Time       `json:"requestedAt"`
+	Currency      string          `json:"currency"`
+	Metadata      json.RawMessage `json:"metadata,omitempty"`
+}
+
+// validateMetadata aceita um objeto JSON (ou null) e o retorna compactado.
+func (gw *gateway) validateMetadata(raw json.RawMessage) (json.RawMessage, fieldIssue) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, fieldIssue{}
+	}
+	if raw[0] != '{' {
+		return nil, issue(fieldNotObject)
+	}
+	limit := gw.currentConfig().Validation.MaxMetadataBytes
+	if limit == 0 {
+		return nil, issue(fieldNotAccepted)
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return nil, issue(fieldInvalidJSON)
+	}
+	if compact.Len() > limit {
+		return nil, issue(fieldTooLarge, limit)
+	}
+	return compact.Bytes(), fieldIssue{}
+}
@@ -0,0 +1,48 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"rinha-backend-2025/internal/ids"
+	"rinha-backend-2025/internal/storage"
+)
+
+// handleGetPayment responde o registro de um pagamento já processado. Com os
+// backends memory e bolt, só esta instância conhece os próprios registros.
+func (gw *gateway) handleGetPayment(c *gin.Context) {
+	correlationID := c.Param("correlationId")
+	if !ids.Valid(correlationID) {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidCorrelationID))
+		return
+	}
+	finder, ok := gw.store.(storage.Finder)
+	if !ok {
+		respondError(c, http.StatusNotImplemented, errCodeNotImplemented, msg(msgNoPaymentLookup))
+		return
+	}
+
+	// Os registros aceitos há pouco ainda podem estar nos deltas pendentes
+	gw.flushCounters()
+	record, found, err := finder.FindPayment(c.Request.Context(), correlationID)
+	if err != nil {
+		gw.logf(c.Request.Context(), "Erro ao consultar pagamento %s: %v", correlationID, err)
+		respondError(c, http.StatusInternalServerError, errCodeInternal, msg(msgPaymentLookupFailed))
+		return
+	}
+	if !found {
+		respondError(c, http.StatusNotFound, errCodeNotFound, msg(msgPaymentNotFound))
+		return
+	}
+	c.JSON(http.StatusOK, PaymentRecordResponse{
+		CorrelationID: record.CorrelationID,
+		Amount:        record.Amount,
+		Processor:     record.Processor,
+		RequestedAt:   record.RequestedAt,
+		Currency:      currencyOrDefault(record.Currency),
+		Metadata:      record.Metadata,
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// metricSample é um valor de uma métrica com seus labels.
+type metricSample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// metric é coletada sob demanda no formato texto do Prometheus.
+type metric struct {
+	Name    string
+	Help    string
+	Type    string // "gauge" ou "counter"
+	Collect func() []metricSample
+}
+
+// metricsState é o estado de métricas
+type metricsState struct {
+	registeredMetrics    []metric
+	registeredMetricsMux sync.Mutex
+}
+
+func (gw *gateway) registerMetric(m metric) {
+	gw.registeredMetricsMux.Lock()
+	gw.registeredMetrics = append(gw.registeredMetrics, m)
+	gw.registeredMetricsMux.Unlock()
+}
+
+// writeLabels escreve os labels da amostra e o instance_id (INSTANCE_ID), que
+// separa as instâncias atrás do nginx mesmo sem o label instance do scrape.
+func (gw *gateway) writeLabels(b *strings.Builder, labels map[string]string) {
+	names := make([]string, 0, len(labels)+1)
+	for name := range labels {
+		names = append(names, name)
+	}
+	if _, ok := labels[instanceLabel]; !ok {
+		names = append(names, instanceLabel)
+	}
+	sort.Strings(names)
+
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		value, ok := labels[name]
+		if !ok {
+			value = gw.instanceID
+		}
+		fmt.Fprintf(b, "%s=%q", name, value)
+	}
+	b.WriteByte('}')
+}
@@ -0,0 +1,30 @@
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (gw *gateway) handleMetrics(c *gin.Context) {
+	gw.registeredMetricsMux.Lock()
+	metrics := append([]metric(nil), gw.registeredMetrics...)
+	gw.registeredMetricsMux.Unlock()
+
+	var b strings.Builder
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n", m.Name, m.Help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", m.Name, m.Type)
+		for _, sample := range m.Collect() {
+			b.WriteString(m.Name)
+			gw.writeLabels(&b, sample.Labels)
+			fmt.Fprintf(&b, " %g\n", sample.Value)
+		}
+	}
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4", []byte(b.String()))
+}
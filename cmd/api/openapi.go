@@ -0,0 +1,769 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/selector"
+)
+
+// Especificação OpenAPI 3 (GET /openapi.json), para gerar os clientes e o
+// harness de teste. As rotas, parâmetros e desfechos são descritos à mão, a
+// partir dos handlers; os schemas dos tipos serializados pelo encoding/json vêm
+// das tags json por reflexão, para não divergirem das respostas. Os corpos
+// montados com append (resumo, taxas, série, moedas e exportação) têm o schema
+// escrito aqui, no formato de codec.go. As rotas /internal, só entre
+// instâncias, ficam de fora. Com DEBUG_ENDPOINTS, a Swagger UI fica em
+// /debug/swagger.
+
+// jsonObject é um nó do documento.
+type jsonObject = map[string]any
+
+var (
+	openAPIOnce sync.Once
+	openAPIBody []byte
+)
+
+// serveOpenAPI responde o documento, montado na primeira chamada.
+func (gw *gateway) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		body, err := json.Marshal(buildOpenAPI(gw.currentConfig().Auth.Header))
+		if err != nil {
+			log.Printf("Erro ao serializar a especificação OpenAPI: %v", err)
+		}
+		openAPIBody = body
+	})
+	w.Header().Set("Content-Type", jsonContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(openAPIBody)))
+	w.Write(openAPIBody)
+}
+
+// buildOpenAPI monta o documento; authHeader é o header da chave das rotas
+// administrativas.
+func buildOpenAPI(authHeader string) jsonObject {
+	s := newOpenAPISchemas()
+	errorRef := s.ref(reflect.TypeOf(ErrorResponse{}))
+	errorResponses := func(statuses map[int]string) jsonObject {
+		responses := jsonObject{}
+		for status, description := range statuses {
+			responses[strconv.Itoa(status)] = jsonResponse(description, errorRef)
+		}
+		return responses
+	}
+	admin := func(op jsonObject) jsonObject {
+		op["tags"] = []string{"admin"}
+		op["security"] = []jsonObject{{"adminKey": []string{}}}
+		responses := op["responses"].(jsonObject)
+		responses["401"] = jsonResponse("Chave de API ausente ou inválida", errorRef)
+		responses["403"] = jsonResponse("Origem fora de ADMIN_ALLOW_IPS", errorRef)
+		return op
+	}
+
+	payment := s.ref(reflect.TypeOf(PaymentRequest{}))
+	// A validação exige um UUID, o que a tag json não diz
+	s.defs["PaymentRequest"].(jsonObject)["properties"].(jsonObject)["correlationId"] = uuidSchema()
+	message := s.ref(reflect.TypeOf(PaymentResponse{}))
+	processorSummary := s.ref(reflect.TypeOf(ProcessorSummary{}))
+	summary := s.define("PaymentSummaryResponse", jsonObject{
+		"type":                 "object",
+		"description":          "Um resumo por processor; default e fallback sempre presentes.",
+		"required":             []string{"default", "fallback"},
+		"additionalProperties": processorSummary,
+	})
+	detailedProcessor := s.define("DetailedProcessorSummary", jsonObject{
+		"type":        "object",
+		"description": "feeRate ausente no total.",
+		"required":    []string{"totalRequests", "totalAmount", "totalFees", "netAmount"},
+		"properties": jsonObject{
+			"totalRequests": schemaType("integer"),
+			"totalAmount":   schemaType("number"),
+			"feeRate":       schemaType("number"),
+			"totalFees":     schemaType("number"),
+			"netAmount":     schemaType("number"),
+		},
+	})
+	detailed := s.define("DetailedSummaryResponse", jsonObject{
+		"type":     "object",
+		"required": []string{"processors", "total"},
+		"properties": jsonObject{
+			"processors": mapOf(detailedProcessor),
+			"total":      detailedProcessor,
+		},
+	})
+	byCurrency := s.define("CurrencySummaryResponse", jsonObject{
+		"type": "object",
+		"additionalProperties": jsonObject{
+			"type":     "object",
+			"required": []string{"totalRequests", "totalAmount", "currencies"},
+			"properties": jsonObject{
+				"totalRequests": schemaType("integer"),
+				"totalAmount":   schemaType("number"),
+				"currencies":    mapOf(processorSummary),
+			},
+		},
+	})
+	timeseries := s.define("TimeseriesResponse", jsonObject{
+		"type":     "object",
+		"required": []string{"bucket", "buckets"},
+		"properties": jsonObject{
+			"bucket": enumSchema("1s", "10s", "1m"),
+			"buckets": arrayOf(jsonObject{
+				"type":                 "object",
+				"description":          "Início do bucket e um resumo por processor.",
+				"required":             []string{"start"},
+				"properties":           jsonObject{"start": dateTimeSchema()},
+				"additionalProperties": processorSummary,
+			}),
+		},
+	})
+	exportRow := s.define("ExportRow", jsonObject{
+		"type":     "object",
+		"required": []string{"correlationId", "amount", "processor", "requestedAt", "status"},
+		"properties": jsonObject{
+			"correlationId": uuidSchema(),
+			"amount":        schemaType("number"),
+			"processor":     schemaType("string"),
+			"requestedAt":   dateTimeSchema(),
+			"status":        enumSchema(exportProcessed, exportFailed),
+		},
+	})
+	status := s.ref(reflect.TypeOf(StatusResponse{}))
+	reloadable := s.ref(reflect.TypeOf(config.Reloadable{}))
+
+	correlationIDParam := jsonObject{"name": "correlationId", "in": "path", "required": true, "schema": uuidSchema()}
+	fromParam := queryParam("from", "Início do período por requestedAt (RFC 3339)", dateTimeSchema())
+	toParam := queryParam("to", "Fim do período por requestedAt (RFC 3339)", dateTimeSchema())
+	limitParam := func(description string) jsonObject {
+		return queryParam("limit", description, jsonObject{"type": "integer", "minimum": 1, "default": 100})
+	}
+	flag := func(name, description string) jsonObject {
+		return queryParam(name, description, jsonObject{"type": "boolean", "default": false})
+	}
+	payloadErrors := map[int]string{
+		http.StatusBadRequest:            "Corpo ilegível ou host do callbackUrl fora de WEBHOOK_ALLOWED_HOSTS",
+		http.StatusRequestEntityTooLarge: "Corpo maior que o limite",
+		http.StatusUnsupportedMediaType:  "Content-Type não aceito",
+		http.StatusUnprocessableEntity:   "Pagamento inválido ou correlationId repetido com outro valor",
+		http.StatusServiceUnavailable:    "Fila de pagamentos cheia",
+	}
+
+	paymentResponses := errorResponses(payloadErrors)
+	paymentResponses["200"] = jsonResponse("Pagamento recebido ou já processado", message)
+	paymentResponses["202"] = jsonResponse("Pagamento na fila", message)
+	paymentResponses["502"] = jsonResponse("Nenhum processor aceitou o pagamento (modo síncrono)", errorRef)
+
+	batchResponse := s.ref(reflect.TypeOf(BatchResponse{}))
+	batchResponses := errorResponses(payloadErrors)
+	batchResponses["202"] = jsonResponse("Itens válidos na fila; o resultado de cada um na posição do lote", batchResponse)
+	batchResponses["422"] = jsonResponse("Nenhum item válido; os resultados vêm em error.details", errorRef)
+
+	// Sinal de backpressure das respostas de recebimento (ver backpressure.go)
+	backpressureHeaders := jsonObject{
+		queueDepthHeader: jsonObject{
+			"description": "Pagamentos em memória na instância, aguardando envio",
+			"schema":      schemaType("integer"),
+		},
+		estimatedDelayHeader: jsonObject{
+			"description": "Espera estimada de um pagamento novo pela vazão recente dos workers; ausente sem vazão medida",
+			"schema":      schemaType("integer"),
+		},
+	}
+	// Os erros de validação saem antes da fila e não os trazem
+	for _, responses := range []jsonObject{paymentResponses, batchResponses} {
+		for _, status := range []string{"200", "202", "502", "503"} {
+			if response, ok := responses[status].(jsonObject); ok {
+				response["headers"] = backpressureHeaders
+			}
+		}
+	}
+
+	getPaymentResponses := errorResponses(map[int]string{
+		http.StatusBadRequest:          "correlationId não é um UUID",
+		http.StatusNotFound:            "Pagamento não encontrado",
+		http.StatusInternalServerError: "Erro ao consultar o storage",
+		http.StatusNotImplemented:      "Storage sem consulta por correlationId",
+	})
+	getPaymentResponses["200"] = jsonResponse("Registro do pagamento", s.ref(reflect.TypeOf(PaymentRecordResponse{})))
+
+	cancelResponses := errorResponses(map[int]string{
+		http.StatusBadRequest: "correlationId não é um UUID",
+		http.StatusNotFound:   "Pagamento fora da fila",
+		http.StatusConflict:   "Pagamento já enviado a um processor",
+	})
+	cancelResponses["200"] = jsonResponse("Pagamento retirado da fila", message)
+
+	summaryResponses := errorResponses(map[int]string{
+		http.StatusBadRequest:     "Parâmetro inválido",
+		http.StatusNotImplemented: "Storage sem resumo por moeda",
+	})
+	summaryResponses["200"] = jsonResponse("Resumo; o formato depende de detailed e byCurrency", jsonObject{
+		"oneOf": []jsonObject{summary, detailed, byCurrency},
+	})
+	summaryResponses["304"] = jsonObject{"description": "Contadores iguais aos do ETag ou sem mudança desde If-Modified-Since"}
+
+	timeseriesResponses := errorResponses(map[int]string{
+		http.StatusBadRequest:          "Parâmetro inválido ou buckets demais",
+		http.StatusInternalServerError: "Erro ao consultar o storage",
+		http.StatusNotImplemented:      "Storage sem série por período",
+	})
+	timeseriesResponses["200"] = jsonResponse("Série do resumo", timeseries)
+
+	amountsResponses := errorResponses(map[int]string{
+		http.StatusBadRequest:          "Parâmetro inválido",
+		http.StatusInternalServerError: "Erro ao consultar o storage",
+		http.StatusNotImplemented:      "Storage sem histograma nem exportação",
+	})
+	amountsResponses["200"] = jsonResponse("Distribuição dos valores", s.ref(reflect.TypeOf(AmountStatsResponse{})))
+
+	verifyResponses := errorResponses(map[int]string{
+		http.StatusInternalServerError: "Erro ao consultar o storage",
+		http.StatusNotImplemented:      "Storage sem exportação",
+	})
+	verifyResponses["200"] = jsonResponse("Contadores comparados com os pagamentos registrados", s.ref(reflect.TypeOf(IntegrityResponse{})))
+
+	decisionsResponses := errorResponses(map[int]string{
+		http.StatusBadRequest:         "limit inválido",
+		http.StatusNotFound:           "Registro de decisões desativado",
+		http.StatusServiceUnavailable: "Redis indisponível",
+	})
+	decisionsResponses["200"] = jsonObject{
+		"description": "Uma decisão por linha, da mais antiga para a mais recente",
+		"content":     jsonObject{"application/x-ndjson": jsonObject{"schema": s.ref(reflect.TypeOf(selector.Decision{}))}},
+	}
+
+	statusResponses := jsonObject{"200": jsonResponse("Estado da instância", status)}
+	readyResponses := jsonObject{
+		"200": jsonResponse("Instância pronta", status),
+		"503": jsonResponse("Iniciando, encerrando ou sem Redis", status),
+	}
+
+	dlqResponses := errorResponses(map[int]string{http.StatusBadRequest: "limit inválido"})
+	dlqResponses["200"] = jsonResponse("Entradas mais antigas da DLQ", jsonObject{
+		"type":     "object",
+		"required": []string{"size", "entries"},
+		"properties": jsonObject{
+			"size":    schemaType("integer"),
+			"entries": arrayOf(s.ref(reflect.TypeOf(DeadLetter{}))),
+		},
+	})
+
+	requeueResponses := errorResponses(map[int]string{http.StatusBadRequest: "source ou limit inválido"})
+	requeueResponses["200"] = jsonResponse("Entradas movidas", s.ref(reflect.TypeOf(RequeueResponse{})))
+
+	statusPageResponses := errorResponses(map[int]string{
+		http.StatusBadRequest:          "Parâmetro inválido",
+		http.StatusNotFound:            "Índices desativados (STATUS_INDEX)",
+		http.StatusInternalServerError: "Erro ao consultar o índice",
+		http.StatusServiceUnavailable:  "Redis indisponível",
+	})
+	statusPageResponses["200"] = jsonResponse("Página dos pagamentos do status, por requestedAt", s.ref(reflect.TypeOf(PaymentStatusPage{})))
+
+	exportResponses := errorResponses(map[int]string{
+		http.StatusBadRequest:     "Parâmetro inválido",
+		http.StatusNotImplemented: "Storage sem exportação",
+	})
+	exportResponses["200"] = jsonObject{
+		"description": "Pagamentos registrados e os que estão na DLQ, como failed",
+		"content": jsonObject{
+			"text/csv":             jsonObject{"schema": schemaType("string")},
+			"application/x-ndjson": jsonObject{"schema": exportRow},
+		},
+	}
+
+	auditResponses := errorResponses(map[int]string{
+		http.StatusNotFound:            "Auditoria desativada (AUDIT_LOG)",
+		http.StatusInternalServerError: "Erro ao ler o histórico",
+		http.StatusServiceUnavailable:  "Redis indisponível",
+	})
+	auditResponses["200"] = jsonResponse("Transições do pagamento", jsonObject{
+		"type":     "object",
+		"required": []string{"correlationId", "events"},
+		"properties": jsonObject{
+			"correlationId": uuidSchema(),
+			"events":        arrayOf(s.ref(reflect.TypeOf(AuditEntry{}))),
+		},
+	})
+
+	healthEntry := s.ref(reflect.TypeOf(HealthHistoryEntry{}))
+	healthResponses := errorResponses(map[int]string{
+		http.StatusBadRequest:          "Parâmetro inválido",
+		http.StatusNotFound:            "Histórico ou stream desativado (HEALTH_HISTORY_*)",
+		http.StatusInternalServerError: "Erro ao ler o stream",
+		http.StatusServiceUnavailable:  "Redis indisponível",
+	})
+	healthResponses["200"] = jsonResponse("Histórico local por processor ou entradas do stream", jsonObject{
+		"oneOf": []jsonObject{
+			{"type": "object", "required": []string{"processors"}, "properties": jsonObject{"processors": mapOf(arrayOf(healthEntry))}},
+			{"type": "object", "required": []string{"entries"}, "properties": jsonObject{"entries": arrayOf(healthEntry)}},
+		},
+	})
+
+	configUpdateResponses := errorResponses(map[int]string{
+		http.StatusBadRequest:            "JSON inválido ou campo fora da parte recarregável",
+		http.StatusRequestEntityTooLarge: "Corpo maior que 64 KiB",
+		http.StatusUnprocessableEntity:   "Configuração recusada na validação",
+	})
+	configUpdateResponses["200"] = jsonResponse("Configuração em vigor", reloadable)
+
+	paths := jsonObject{
+		"/payments": jsonObject{
+			"post": jsonObject{
+				"tags":        []string{"pagamentos"},
+				"summary":     "Recebe um pagamento",
+				"operationId": "createPayment",
+				"requestBody": jsonObject{
+					"required": true,
+					"content": jsonObject{
+						"application/json":       jsonObject{"schema": payment},
+						"application/msgpack":    jsonObject{"schema": payment},
+						"application/x-protobuf": jsonObject{"schema": jsonObject{"type": "string", "format": "binary"}},
+					},
+				},
+				"responses": paymentResponses,
+			},
+		},
+		"/payments/batch": jsonObject{
+			"post": jsonObject{
+				"tags":        []string{"pagamentos"},
+				"summary":     "Recebe um lote de pagamentos; ou todos os válidos entram na fila ou nenhum",
+				"operationId": "createPaymentsBatch",
+				"requestBody": jsonObject{"required": true, "content": jsonContent(arrayOf(payment))},
+				"responses":   batchResponses,
+			},
+		},
+		"/payments/{correlationId}": jsonObject{
+			"parameters": []jsonObject{correlationIDParam},
+			"get": jsonObject{
+				"tags":        []string{"pagamentos"},
+				"summary":     "Registro de um pagamento processado",
+				"operationId": "getPayment",
+				"responses":   getPaymentResponses,
+			},
+			"delete": jsonObject{
+				"tags":        []string{"pagamentos"},
+				"summary":     "Retira da fila um pagamento ainda não enviado",
+				"operationId": "cancelPayment",
+				"responses":   cancelResponses,
+			},
+		},
+		"/payments/stream": jsonObject{
+			"get": jsonObject{
+				"tags":        []string{"pagamentos"},
+				"summary":     "Eventos do pipeline como Server-Sent Events",
+				"operationId": "streamPayments",
+				"responses": jsonObject{"200": jsonObject{
+					"description": "Um evento por passo, nomeado pelo type; \"dropped\" informa os descartados por lentidão",
+					"content":     jsonObject{"text/event-stream": jsonObject{"schema": s.ref(reflect.TypeOf(PaymentEvent{}))}},
+				}},
+			},
+		},
+		"/payments-summary": jsonObject{
+			"get": jsonObject{
+				"tags":        []string{"resumo"},
+				"summary":     "Totais por processor",
+				"operationId": "getPaymentsSummary",
+				"parameters": []jsonObject{
+					fromParam, toParam,
+					flag("detailed", "Inclui as taxas e o total"),
+					flag("byCurrency", "Totais por moeda; sem from, to e detailed"),
+					flag("consistent", "Aplica os contadores pendentes antes de ler"),
+					flag("nocache", "Ignora o cache do resumo"),
+				},
+				"responses": summaryResponses,
+			},
+		},
+		"/payments-summary/timeseries": jsonObject{
+			"get": jsonObject{
+				"tags":        []string{"resumo"},
+				"summary":     "Resumo em buckets de requestedAt",
+				"operationId": "getPaymentsTimeseries",
+				"parameters": []jsonObject{
+					queryParam("bucket", "Largura dos buckets", jsonObject{"type": "string", "enum": []string{"1s", "10s", "1m"}, "default": "1s"}),
+					fromParam, toParam,
+				},
+				"responses": timeseriesResponses,
+			},
+		},
+		"/healthz": jsonObject{
+			"get": jsonObject{"tags": []string{"status"}, "summary": "Liveness", "operationId": "getHealthz", "responses": statusResponses},
+		},
+		"/readyz": jsonObject{
+			"get": jsonObject{"tags": []string{"status"}, "summary": "Readiness", "operationId": "getReadyz", "responses": readyResponses},
+		},
+		"/metrics": jsonObject{
+			"get": jsonObject{
+				"tags":        []string{"status"},
+				"summary":     "Métricas no formato de texto do Prometheus",
+				"operationId": "getMetrics",
+				"responses": jsonObject{"200": jsonObject{
+					"description": "Métricas",
+					"content":     jsonObject{"text/plain": jsonObject{"schema": schemaType("string")}},
+				}},
+			},
+		},
+		"/openapi.json": jsonObject{
+			"get": jsonObject{
+				"tags":        []string{"status"},
+				"summary":     "Este documento",
+				"operationId": "getOpenAPI",
+				"responses":   jsonObject{"200": jsonResponse("Especificação OpenAPI 3", schemaType("object"))},
+			},
+		},
+		"/purge-payments": jsonObject{
+			"post": admin(jsonObject{
+				"summary":     "Apaga contadores, pagamentos registrados, outbox e auditoria",
+				"operationId": "purgePayments",
+				"responses": jsonObject{
+					"200": jsonResponse("Pagamentos apagados", message),
+					"500": jsonResponse("Erro ao apagar pagamentos", errorRef),
+				},
+			}),
+		},
+		"/admin/dlq": jsonObject{
+			"get": admin(jsonObject{
+				"summary":     "Pagamentos que esgotaram as tentativas",
+				"operationId": "listDLQ",
+				"parameters":  []jsonObject{limitParam("Máximo de entradas")},
+				"responses":   dlqResponses,
+			}),
+		},
+		"/admin/requeue": jsonObject{
+			"post": admin(jsonObject{
+				"summary":     "Devolve entradas da DLQ à fila principal",
+				"operationId": "requeueDLQ",
+				"parameters": []jsonObject{
+					queryParam("source", "Origem das entradas", enumSchema("dlq")),
+					limitParam("Máximo de entradas movidas"),
+				},
+				"responses": requeueResponses,
+			}),
+		},
+		"/admin/payments": jsonObject{
+			"get": admin(jsonObject{
+				"summary":     "Pagamentos pendentes, processados ou na DLQ do período",
+				"operationId": "listPaymentsByStatus",
+				"parameters": []jsonObject{
+					{"name": "status", "in": "query", "required": true, "schema": enumSchema(paymentStatuses...)},
+					fromParam, toParam,
+					queryParam("limit", "Tamanho da página", jsonObject{"type": "integer", "minimum": 1, "maximum": maxStatusPage, "default": 100}),
+					queryParam("offset", "Posição da página; o nextOffset da anterior", jsonObject{"type": "integer", "minimum": 0, "default": 0}),
+				},
+				"responses": statusPageResponses,
+			}),
+		},
+		"/admin/payments/export": jsonObject{
+			"get": admin(jsonObject{
+				"summary":     "Exporta os pagamentos do período",
+				"operationId": "exportPayments",
+				"parameters": []jsonObject{
+					fromParam, toParam,
+					queryParam("format", "Formato da exportação", jsonObject{"type": "string", "enum": []string{"csv", "ndjson"}, "default": "csv"}),
+				},
+				"responses": exportResponses,
+			}),
+		},
+		"/admin/stats": jsonObject{
+			"get": admin(jsonObject{
+				"summary":     "Latência recente por processor",
+				"operationId": "getStats",
+				"responses": jsonObject{"200": jsonResponse("Latências", jsonObject{
+					"type":       "object",
+					"required":   []string{"latency"},
+					"properties": jsonObject{"latency": mapOf(s.ref(reflect.TypeOf(LatencySnapshot{})))},
+				})},
+			}),
+		},
+		"/admin/stats/amounts": jsonObject{
+			"get": admin(jsonObject{
+				"summary":     "Histograma dos valores por processor e maiores pagamentos do período",
+				"operationId": "getAmountStats",
+				"parameters": []jsonObject{
+					fromParam, toParam,
+					queryParam("top", "Maiores pagamentos na resposta, até STORAGE_TOP_AMOUNTS", jsonObject{"type": "integer", "minimum": 0}),
+				},
+				"responses": amountsResponses,
+			}),
+		},
+		"/admin/verify": jsonObject{
+			"get": admin(jsonObject{
+				"summary":     "Reconfere os contadores do resumo com os pagamentos registrados",
+				"operationId": "verifyCounters",
+				"responses":   verifyResponses,
+			}),
+		},
+		"/admin/audit/{correlationId}": jsonObject{
+			"parameters": []jsonObject{correlationIDParam},
+			"get": admin(jsonObject{
+				"summary":     "Histórico de estados de um pagamento",
+				"operationId": "getAudit",
+				"responses":   auditResponses,
+			}),
+		},
+		"/admin/health-history": jsonObject{
+			"get": admin(jsonObject{
+				"summary":     "Últimos health-checks e vereditos por processor",
+				"operationId": "getHealthHistory",
+				"parameters": []jsonObject{
+					limitParam("Máximo de entradas por processor"),
+					queryParam("processor", "Só este processor", schemaType("string")),
+					queryParam("source", "Esta instância ou o stream do Redis, com todas", jsonObject{"type": "string", "enum": []string{"local", "stream"}, "default": "local"}),
+				},
+				"responses": healthResponses,
+			}),
+		},
+		"/admin/routing": jsonObject{
+			"get": admin(jsonObject{
+				"summary":     "Decisão atual do selector e as entradas que a produziram",
+				"operationId": "getRouting",
+				"responses": jsonObject{"200": jsonResponse("Explicação do roteamento",
+					s.ref(reflect.TypeOf(RoutingExplanation{})))},
+			}),
+		},
+		"/admin/routing/decisions": jsonObject{
+			"get": admin(jsonObject{
+				"summary":     "Exporta as decisões de roteamento registradas, para o cmd/replay",
+				"operationId": "exportRoutingDecisions",
+				"parameters": []jsonObject{
+					queryParam("after", "ID da última decisão já exportada", schemaType("string")),
+					queryParam("limit", "Máximo de decisões", jsonObject{"type": "integer", "minimum": 1, "default": defaultDecisionsLimit}),
+				},
+				"responses": decisionsResponses,
+			}),
+		},
+		"/admin/whoami": jsonObject{
+			"get": admin(jsonObject{
+				"summary":     "Identidade, versão e uptime da instância que respondeu",
+				"operationId": "getWhoAmI",
+				"responses": jsonObject{"200": jsonResponse("Instância",
+					s.ref(reflect.TypeOf(WhoAmI{})))},
+			}),
+		},
+		"/admin/config": jsonObject{
+			"get": admin(jsonObject{
+				"summary":     "Parte recarregável da configuração em vigor",
+				"operationId": "getConfig",
+				"responses":   jsonObject{"200": jsonResponse("Configuração em vigor", reloadable)},
+			}),
+			"put": admin(jsonObject{
+				"summary":     "Aplica um JSON parcial sobre a parte recarregável",
+				"operationId": "updateConfig",
+				"requestBody": jsonObject{"required": true, "content": jsonContent(jsonObject{
+					"type":        "object",
+					"description": "Campos de Reloadable; os omitidos mantêm o valor em vigor",
+				})},
+				"responses": configUpdateResponses,
+			}),
+		},
+	}
+
+	return jsonObject{
+		"openapi": "3.0.3",
+		"info": jsonObject{
+			"title":       "Rinha de Backend 2025",
+			"version":     "1.0.0",
+			"description": "Intermediador de pagamentos entre os Payment Processors default e fallback.",
+		},
+		"paths": paths,
+		"components": jsonObject{
+			"schemas": s.defs,
+			"securitySchemes": jsonObject{
+				"adminKey": jsonObject{"type": "apiKey", "in": "header", "name": authHeader},
+			},
+		},
+	}
+}
+
+// openAPISchemas acumula em components.schemas os tipos referenciados.
+type openAPISchemas struct {
+	defs  jsonObject
+	names map[reflect.Type]string
+}
+
+func newOpenAPISchemas() *openAPISchemas {
+	return &openAPISchemas{defs: jsonObject{}, names: make(map[reflect.Type]string)}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+	marshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// of retorna o schema de t como o encoding/json o serializa; structs nomeadas
+// viram uma referência.
+func (s *openAPISchemas) of(t reflect.Type) jsonObject {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return dateTimeSchema()
+	case t == rawMessageType:
+		// Só a metadata, sempre um objeto
+		return schemaType("object")
+	case t.Implements(marshalerType):
+		// config.Duration, como "5s"
+		return schemaType("string")
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return schemaType("boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schemaType("integer")
+	case reflect.Float32, reflect.Float64:
+		return schemaType("number")
+	case reflect.String:
+		return schemaType("string")
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return jsonObject{"type": "string", "format": "byte"}
+		}
+		return arrayOf(s.of(t.Elem()))
+	case reflect.Map:
+		return mapOf(s.of(t.Elem()))
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.ref(t)
+	}
+	// any: qualquer valor
+	return jsonObject{}
+}
+
+// ref registra a struct t e retorna a referência a ela. Nomes repetidos entre
+// pacotes ganham o do pacote como prefixo.
+func (s *openAPISchemas) ref(t reflect.Type) jsonObject {
+	name, ok := s.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := s.defs[name]; taken {
+			name = path.Base(t.PkgPath()) + "." + name
+		}
+		s.names[t] = name
+		// Reservado antes dos campos, para os tipos recursivos
+		s.defs[name] = nil
+		s.defs[name] = s.object(t)
+	}
+	return jsonObject{"$ref": "#/components/schemas/" + name}
+}
+
+// define registra um schema escrito à mão e retorna a referência a ele.
+func (s *openAPISchemas) define(name string, schema jsonObject) jsonObject {
+	s.defs[name] = schema
+	return jsonObject{"$ref": "#/components/schemas/" + name}
+}
+
+func (s *openAPISchemas) object(t reflect.Type) jsonObject {
+	properties := jsonObject{}
+	var required []string
+	s.fields(t, properties, &required)
+	schema := jsonObject{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fields segue as regras do encoding/json: campos sem tag usam o nome Go, os de
+// structs embutidas sem tag sobem para o objeto, e os sem omitempty são
+// obrigatórios.
+func (s *openAPISchemas) fields(t reflect.Type, properties jsonObject, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			s.fields(f.Type, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		properties[name] = s.of(f.Type)
+		optional := false
+		for _, opt := range strings.Split(opts, ",") {
+			optional = optional || opt == "omitempty" || opt == "omitzero"
+		}
+		if !optional {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func schemaType(t string) jsonObject {
+	return jsonObject{"type": t}
+}
+
+func dateTimeSchema() jsonObject {
+	return jsonObject{"type": "string", "format": "date-time"}
+}
+
+func uuidSchema() jsonObject {
+	return jsonObject{"type": "string", "format": "uuid"}
+}
+
+func enumSchema(values ...string) jsonObject {
+	return jsonObject{"type": "string", "enum": values}
+}
+
+func arrayOf(items jsonObject) jsonObject {
+	return jsonObject{"type": "array", "items": items}
+}
+
+func mapOf(values jsonObject) jsonObject {
+	return jsonObject{"type": "object", "additionalProperties": values}
+}
+
+func queryParam(name, description string, schema jsonObject) jsonObject {
+	return jsonObject{"name": name, "in": "query", "description": description, "schema": schema}
+}
+
+func jsonContent(schema jsonObject) jsonObject {
+	return jsonObject{"application/json": jsonObject{"schema": schema}}
+}
+
+func jsonResponse(description string, schema jsonObject) jsonObject {
+	return jsonObject{"description": description, "content": jsonContent(schema)}
+}
+
+// Swagger UI de /debug/swagger; os assets vêm do CDN, para não embutir alguns
+// MiB no binário por uma página de diagnóstico.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<title>Rinha de Backend 2025 - API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+func serveSwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIPage))
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/config"
+	pp "rinha-backend-2025/internal/processor"
+	"rinha-backend-2025/internal/storage"
+)
+
+const outboxKey = "payments:outbox"
+
+// OutboxEntry registra a intenção de enviar um pagamento. A entrada só sai do
+// outbox quando o resultado é conhecido: sucesso contabilizado ou reconciliação
+// com os processors.
+type OutboxEntry struct {
+	CorrelationID string    `json:"correlationId"`
+	Amount        float64   `json:"amount"`
+	CallbackURL   string    `json:"callbackUrl,omitempty"`
+	Currency      string    `json:"currency,omitempty"`
+	RequestedAt   time.Time `json:"requestedAt"`
+	CreatedAt     time.Time `json:"createdAt"`
+	// O envio terminou sem confirmação nem recusa: se nenhum processor conhecer o
+	// pagamento, a reconciliação o manda para a DLQ
+	Unknown bool `json:"unknown,omitempty"`
+	// Metadata do pedido, para o registro reconciliado
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// outboxState é o estado do outbox
+type outboxState struct {
+	outboxEnabled bool
+
+	// Usado apenas quando o Redis não está disponível
+	memoryOutbox    map[string]OutboxEntry
+	memoryOutboxMux sync.Mutex
+
+	// Pagamentos aceitos pelos processors que só foram contabilizados na reconciliação
+	outboxReconciled atomic.Int64
+	// Envios que terminaram sem saber se o processor aceitou
+	paymentsUnknown atomic.Int64
+}
+
+func (gw *gateway) registerOutbox() {
+	gw.registerMetric(metric{
+		Name: "outbox_reconciled_total",
+		Help: "Pagamentos aceitos pelos processors e contabilizados pela reconciliação do outbox.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.outboxReconciled.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "payments_unknown_total",
+		Help: "Envios sem confirmação nem recusa do processor, deixados para a reconciliação.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.paymentsUnknown.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "outbox_pending",
+		Help: "Pagamentos no outbox aguardando resultado ou reconciliação.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.outboxSize())}}
+		},
+	})
+}
+
+// outboxBegin grava a intenção antes do envio; um novo envio do mesmo pagamento
+// (requeue ou redrive) sobrescreve a entrada e reinicia o prazo de reconciliação.
+func (gw *gateway) outboxBegin(entry OutboxEntry) {
+	if client := gw.currentRedis(); client != nil {
+		// O pipeline já foi enviado quando redisDo retorna; só então o buffer volta
+		buf := getBuffer()
+		*buf = entry.appendJSON(*buf)
+		_, err := gw.redisDo(context.Background(), client, func(c redis.Cmdable) redis.Cmder {
+			return c.HSet(context.Background(), gw.keys.Key(outboxKey), entry.CorrelationID, *buf)
+		})
+		if err != nil {
+			log.Printf("Erro ao gravar %s no outbox: %v", entry.CorrelationID, err)
+		}
+		putBuffer(buf)
+		return
+	}
+
+	gw.memoryOutboxMux.Lock()
+	if _, ok := gw.memoryOutbox[entry.CorrelationID]; !ok && len(gw.memoryOutbox) >= gw.currentConfig().Memory.MaxOutboxEntries {
+		gw.evictOldestOutboxLocked()
+	}
+	gw.memoryOutbox[entry.CorrelationID] = entry
+	gw.memoryOutboxMux.Unlock()
+}
+
+// evictOldestOutboxLocked descarta a entrada mais antiga do outbox em memória,
+// no teto de MEMORY_MAX_OUTBOX_ENTRIES. Só roda com o Redis fora e o outbox
+// cheio, então a busca linear não pesa.
+func (gw *gateway) evictOldestOutboxLocked() {
+	var oldest OutboxEntry
+	for _, entry := range gw.memoryOutbox {
+		if oldest.CorrelationID == "" || entry.CreatedAt.Before(oldest.CreatedAt) {
+			oldest = entry
+		}
+	}
+	delete(gw.memoryOutbox, oldest.CorrelationID)
+	gw.memoryEvicted[evictedOutbox].Add(1)
+	log.Printf("Outbox em memória cheio: %s descartado sem reconciliação", oldest.CorrelationID)
+}
+
+// replayMemoryOutbox move para o Redis as entradas gravadas em memória durante
+// uma queda.
+func (gw *gateway) replayMemoryOutbox(ctx context.Context, client redis.UniversalClient) error {
+	gw.memoryOutboxMux.Lock()
+	defer gw.memoryOutboxMux.Unlock()
+
+	for id, entry := range gw.memoryOutbox {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			log.Printf("Erro ao serializar entrada do outbox: %v", err)
+		} else if err := client.HSet(ctx, gw.keys.Key(outboxKey), id, data).Err(); err != nil {
+			return err
+		}
+		delete(gw.memoryOutbox, id)
+	}
+	return nil
+}
+
+// outboxClaim remove a entrada e retorna true apenas para quem de fato a removeu,
+// o que impede que duas instâncias contabilizem o mesmo pagamento.
+func (gw *gateway) outboxClaim(correlationID string) bool {
+	if client := gw.currentRedis(); client != nil {
+		cmd, err := gw.redisDo(context.Background(), client, func(c redis.Cmdable) redis.Cmder {
+			return c.HDel(context.Background(), gw.keys.Key(outboxKey), correlationID)
+		})
+		if err != nil {
+			log.Printf("Erro ao remover %s do outbox: %v", correlationID, err)
+			return false
+		}
+		return cmd.(*redis.IntCmd).Val() == 1
+	}
+
+	gw.memoryOutboxMux.Lock()
+	defer gw.memoryOutboxMux.Unlock()
+	if _, ok := gw.memoryOutbox[correlationID]; !ok {
+		return false
+	}
+	delete(gw.memoryOutbox, correlationID)
+	return true
+}
+
+func (gw *gateway) outboxSize() int {
+	if client := gw.currentRedis(); client != nil {
+		n, err := client.HLen(context.Background(), gw.keys.Key(outboxKey)).Result()
+		if err != nil {
+			log.Printf("Erro ao obter tamanho do outbox: %v", err)
+			return 0
+		}
+		return int(n)
+	}
+
+	gw.memoryOutboxMux.Lock()
+	defer gw.memoryOutboxMux.Unlock()
+	return len(gw.memoryOutbox)
+}
+
+// staleOutboxEntries retorna as entradas gravadas antes de cutoff, cujo envio já
+// terminou com certeza (o orçamento do pagamento é menor que o prazo).
+func (gw *gateway) staleOutboxEntries(cutoff time.Time) []OutboxEntry {
+	var stale []OutboxEntry
+
+	if client := gw.currentRedis(); client != nil {
+		ctx := context.Background()
+		iter := client.HScan(ctx, gw.keys.Key(outboxKey), 0, "", 500).Iterator()
+		for iter.Next(ctx) {
+			// HSCAN alterna campo e valor
+			if !iter.Next(ctx) {
+				break
+			}
+			var entry OutboxEntry
+			if err := json.Unmarshal([]byte(iter.Val()), &entry); err != nil {
+				continue
+			}
+			if entry.CreatedAt.Before(cutoff) {
+				stale = append(stale, entry)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			log.Printf("Erro ao percorrer outbox: %v", err)
+		}
+		return stale
+	}
+
+	gw.memoryOutboxMux.Lock()
+	defer gw.memoryOutboxMux.Unlock()
+	for _, entry := range gw.memoryOutbox {
+		if entry.CreatedAt.Before(cutoff) {
+			stale = append(stale, entry)
+		}
+	}
+	return stale
+}
+
+func (gw *gateway) purgeOutbox(ctx context.Context) error {
+	if client := gw.currentRedis(); client != nil {
+		return client.Del(ctx, gw.keys.Key(outboxKey)).Err()
+	}
+
+	gw.memoryOutboxMux.Lock()
+	gw.memoryOutbox = make(map[string]OutboxEntry)
+	gw.memoryOutboxMux.Unlock()
+	return nil
+}
+
+// startOutboxReconciler confere periodicamente as entradas antigas do outbox.
+func (gw *gateway) startOutboxReconciler(cfg config.OutboxConfig) {
+	gw.outboxEnabled = cfg.Enabled
+	if !cfg.Enabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.ReconcileInterval.Std())
+		defer ticker.Stop()
+
+		for range ticker.C {
+			gw.reconcileOutbox(cfg.ReconcileAfter.Std())
+		}
+	}()
+}
+
+// reconcileOutbox pergunta aos processors pelos pagamentos sem resultado conhecido:
+// os que foram aceitos (ex.: erro de rede depois do processamento) são contabilizados;
+// os demais saem do outbox e ficam a cargo da DLQ, para onde vão os de desfecho
+// desconhecido, que não passaram por ela.
+func (gw *gateway) reconcileOutbox(after time.Duration) {
+	ctx := context.Background()
+
+	for _, entry := range gw.staleOutboxEntries(gw.appClock.Now().Add(-after)) {
+		epoch := gw.currentEpoch()
+		record, found, err := gw.lookupAcceptedPayment(ctx, entry.CorrelationID)
+		if err != nil {
+			// Sem resposta conclusiva, tentar de novo no próximo ciclo
+			log.Printf("Erro ao reconciliar %s: %v", entry.CorrelationID, err)
+			continue
+		}
+		if !gw.outboxClaim(entry.CorrelationID) {
+			continue
+		}
+		if found {
+			// Os processors não conhecem a moeda: vale a do pedido original, como o metadata
+			record.Currency, record.Metadata = entry.Currency, entry.Metadata
+			record.Epoch = epoch
+			gw.outboxReconciled.Add(1)
+			gw.recordSuccessfulPayment(record)
+			gw.recordAudit(AuditEntry{CorrelationID: entry.CorrelationID, Event: auditReconciled, Processor: record.Processor})
+			gw.indexPaymentStatus(entry.CorrelationID, paymentSucceeded, record.RequestedAt)
+			if entry.Unknown {
+				gw.notifyPayment(PaymentRequest{CorrelationID: entry.CorrelationID, CallbackURL: entry.CallbackURL},
+					webhookProcessed, record.Processor)
+			}
+			log.Printf("Pagamento %s reconciliado: aceito pelo %s", entry.CorrelationID, record.Processor)
+			continue
+		}
+		if entry.Unknown {
+			gw.pushToDLQ(DeadLetter{
+				CorrelationID: entry.CorrelationID,
+				Amount:        entry.Amount,
+				CallbackURL:   entry.CallbackURL,
+				Currency:      entry.Currency,
+				RequestedAt:   entry.RequestedAt,
+				FailedAt:      gw.appClock.Now().UTC(),
+				Metadata:      entry.Metadata,
+			})
+			gw.recordAudit(AuditEntry{CorrelationID: entry.CorrelationID, Event: auditDLQ})
+			gw.indexPaymentStatus(entry.CorrelationID, paymentFailed, entry.RequestedAt)
+			log.Printf("Pagamento %s não encontrado nos processors, enviado para a DLQ", entry.CorrelationID)
+		}
+	}
+}
+
+// lookupAcceptedPayment procura o pagamento em todos os processors. Retorna erro se
+// algum deles não responder de forma conclusiva.
+func (gw *gateway) lookupAcceptedPayment(ctx context.Context, correlationID string) (storage.Record, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, gw.currentConfig().HTTP.AttemptTimeout.Std())
+	defer cancel()
+
+	for _, processor := range gw.processorNames {
+		payment, err := gw.processorClients[processor].GetPayment(ctx, correlationID)
+		if errors.Is(err, pp.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return storage.Record{}, false, fmt.Errorf("%s: %w", processor, err)
+		}
+		return storage.Record{
+			CorrelationID: correlationID,
+			Amount:        payment.Amount,
+			Processor:     processor,
+			RequestedAt:   payment.RequestedAt,
+		}, true, nil
+	}
+	return storage.Record{}, false, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/retry"
+)
+
+// outcomeStats conta as tentativas aceitas e recusadas de um processor nas mesmas
+// duas janelas das latências, para a estimativa de sucesso da estratégia "profit".
+type outcomeStats struct {
+	mu        sync.Mutex
+	clock     clock.Clock
+	window    time.Duration
+	current   outcomeCounts
+	previous  outcomeCounts
+	rotatedAt time.Time
+}
+
+type outcomeCounts struct {
+	successes int64
+	failures  int64
+}
+
+// outcomesState é o estado dos desfechos
+type outcomesState struct {
+	// Desfechos por processor; preenchido em initOutcomeStats e só lido depois
+	processorOutcomes map[string]*outcomeStats
+
+	// Tentativas recusadas por processor e classe do status (retry.Class)
+	processorFailureClasses map[string]*[len(retry.ClassNames)]atomic.Int64
+}
+
+func (gw *gateway) initOutcomeStats(window time.Duration) {
+	for _, name := range gw.processorNames {
+		gw.processorOutcomes[name] = &outcomeStats{clock: gw.appClock, window: window, rotatedAt: gw.appClock.Now()}
+		gw.processorFailureClasses[name] = new([len(retry.ClassNames)]atomic.Int64)
+	}
+
+	gw.registerMetric(metric{
+		Name: "processor_success_ratio",
+		Help: "Fração das tentativas aceitas por processor na janela recente.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, 0, len(gw.processorNames))
+			for _, name := range gw.processorNames {
+				successes, failures := gw.processorOutcomes[name].Counts()
+				ratio := 0.0
+				if total := successes + failures; total > 0 {
+					ratio = float64(successes) / float64(total)
+				}
+				samples = append(samples, metricSample{
+					Labels: map[string]string{"processor": name},
+					Value:  ratio,
+				})
+			}
+			return samples
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "processor_failures_total",
+		Help: "Tentativas recusadas por processor e classe do status (retryable, terminal, ambiguous, duplicate, other).",
+		Type: "counter",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, 0, len(gw.processorNames)*len(retry.ClassNames))
+			for _, name := range gw.processorNames {
+				counts := gw.processorFailureClasses[name]
+				for class, className := range retry.ClassNames {
+					samples = append(samples, metricSample{
+						Labels: map[string]string{"processor": name, "class": className},
+						Value:  float64(counts[class].Load()),
+					})
+				}
+			}
+			return samples
+		},
+	})
+}
+
+func (gw *gateway) recordFailureClass(processor string, class retry.Class) {
+	if counts := gw.processorFailureClasses[processor]; counts != nil {
+		counts[class].Add(1)
+	}
+}
+
+func (gw *gateway) recordOutcome(processor string, ok bool) {
+	if stats := gw.processorOutcomes[processor]; stats != nil {
+		stats.Record(ok)
+	}
+}
+
+func (s *outcomeStats) Record(ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rotateLocked()
+	if ok {
+		s.current.successes++
+	} else {
+		s.current.failures++
+	}
+}
+
+// Counts soma a janela atual e a anterior.
+func (s *outcomeStats) Counts() (successes, failures int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rotateLocked()
+	return s.current.successes + s.previous.successes, s.current.failures + s.previous.failures
+}
+
+func (s *outcomeStats) rotateLocked() {
+	elapsed := s.clock.Since(s.rotatedAt)
+	if elapsed < s.window {
+		return
+	}
+	if elapsed < 2*s.window {
+		s.previous = s.current
+	} else {
+		s.previous = outcomeCounts{}
+	}
+	s.current = outcomeCounts{}
+	s.rotatedAt = s.clock.Now()
+}
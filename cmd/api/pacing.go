@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Espaçamento dos envios por processor (leaky bucket, na forma do GCRA): cada
+// envio reserva a próxima vez livre, e as vezes ficam a 1/rps umas das outras.
+// Quando o default volta de uma queda, o acúmulo da fila sai no ritmo do teto em
+// vez de chegar de uma vez e derrubá-lo de novo.
+
+// Intervalo de recálculo do teto automático a partir do minResponseTime
+const pacingAdjustInterval = time.Second
+
+// errDispatchBusy indica que a vez de envio passaria de MaxWait.
+var errDispatchBusy = errors.New("vez de envio além da espera máxima")
+
+type dispatchPacer struct {
+	mu        sync.Mutex
+	rps       float64
+	interval  time.Duration
+	tolerance time.Duration
+	// Vez teórica do próximo envio
+	next time.Time
+
+	// Teto fixo da configuração; 0 sem teto fixo
+	fixed float64
+	burst int
+
+	waited atomic.Int64
+	shed   atomic.Int64
+}
+
+// setRate troca o teto; 0 libera os envios sem espaçamento.
+func (p *dispatchPacer) setRate(rps float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rps = rps
+	if rps <= 0 {
+		p.interval, p.tolerance = 0, 0
+		return
+	}
+	p.interval = time.Duration(float64(time.Second) / rps)
+	p.tolerance = time.Duration(p.burst-1) * p.interval
+}
+
+// reserve retorna quanto esperar pela vez do envio, ou false se passaria de maxWait.
+func (p *dispatchPacer) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.interval == 0 {
+		return 0, true
+	}
+	tat := p.next
+	if tat.Before(now) {
+		tat = now
+	}
+	wait := max(tat.Sub(now)-p.tolerance, 0)
+	if wait > maxWait {
+		return 0, false
+	}
+	p.next = tat.Add(p.interval)
+	return wait, true
+}
+
+func (p *dispatchPacer) rate() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rps
+}
+
+// pacingState é o estado do espaçamento; vazio com DISPATCH_PACING=false
+type pacingState struct {
+	processorPacers map[string]*dispatchPacer
+	pacingMaxWait   time.Duration
+}
+
+func (gw *gateway) initDispatchPacing(cfg config.PacingConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	gw.pacingMaxWait = cfg.MaxWait.Std()
+	for _, name := range gw.processorNames {
+		fixed, ok := cfg.RPS[name]
+		if !ok {
+			fixed = cfg.DefaultRPS
+		}
+		p := &dispatchPacer{fixed: fixed, burst: cfg.Burst}
+		p.setRate(fixed)
+		gw.processorPacers[name] = p
+	}
+
+	if cfg.Concurrency > 0 {
+		gw.adjustDispatchRates(cfg.Concurrency)
+		go func() {
+			ticker := time.NewTicker(pacingAdjustInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				gw.adjustDispatchRates(cfg.Concurrency)
+			}
+		}()
+	}
+	log.Printf("Espaçamento de envios ativo: até %d envios seguidos, espera máxima %v, teto automático de %d envios por minResponseTime",
+		cfg.Burst, gw.pacingMaxWait, cfg.Concurrency)
+
+	collect := func(pick func(p *dispatchPacer) float64) func() []metricSample {
+		return func() []metricSample {
+			samples := make([]metricSample, 0, len(gw.processorPacers))
+			for _, name := range gw.processorNames {
+				samples = append(samples, metricSample{
+					Labels: map[string]string{"processor": name},
+					Value:  pick(gw.processorPacers[name]),
+				})
+			}
+			return samples
+		}
+	}
+	gw.registerMetric(metric{
+		Name: "processor_dispatch_rate_limit",
+		Help: "Teto atual de envios por segundo a cada processor (0 sem teto).",
+		Type: "gauge",
+		Collect: collect(func(p *dispatchPacer) float64 {
+			return p.rate()
+		}),
+	})
+	gw.registerMetric(metric{
+		Name: "processor_paced_wait_seconds_total",
+		Help: "Tempo somado que os envios esperaram pela vez em cada processor.",
+		Type: "counter",
+		Collect: collect(func(p *dispatchPacer) float64 {
+			return time.Duration(p.waited.Load()).Seconds()
+		}),
+	})
+	gw.registerMetric(metric{
+		Name: "processor_paced_shed_total",
+		Help: "Envios devolvidos à fila porque a vez passaria da espera máxima.",
+		Type: "counter",
+		Collect: collect(func(p *dispatchPacer) float64 {
+			return float64(p.shed.Load())
+		}),
+	})
+}
+
+// adjustDispatchRates aplica o menor entre o teto fixo e concurrency envios a
+// cada minResponseTime do último health-check.
+func (gw *gateway) adjustDispatchRates(concurrency int) {
+	for name, p := range gw.processorPacers {
+		rps := p.fixed
+		if minResponse := gw.healthMonitor.Get(context.Background(), name).MinResponseTime; minResponse > 0 {
+			auto := float64(concurrency) * 1000 / float64(minResponse)
+			if rps == 0 || auto < rps {
+				rps = auto
+			}
+		}
+		if math.Abs(rps-p.rate()) > 1e-9 {
+			p.setRate(rps)
+		}
+	}
+}
+
+// waitForDispatchSlot espera a vez de enviar ao processor. Retorna errDispatchBusy
+// quando a vez passaria de DISPATCH_PACING_MAX_WAIT, ou o erro de ctx.
+func (gw *gateway) waitForDispatchSlot(ctx context.Context, processor string) error {
+	p := gw.processorPacers[processor]
+	if p == nil {
+		return nil
+	}
+	wait, ok := p.reserve(gw.appClock.Now(), gw.pacingMaxWait)
+	if !ok {
+		p.shed.Add(1)
+		return errDispatchBusy
+	}
+	if wait == 0 {
+		return nil
+	}
+	p.waited.Add(int64(wait))
+
+	timer := gw.appClock.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// Recuperação de pânicos no processamento: o recovery do Gin só cobre as
+// requisições, e um pânico num worker derrubaria o processo inteiro com todos
+// os pagamentos em memória. O pagamento que causou o pânico volta para a fila
+// até WORKER_PANIC_RETRIES vezes e depois vai para a DLQ, nunca é descartado.
+
+// Desfechos dos pagamentos que entraram em pânico, na ordem da métrica
+const (
+	panicRequeued = iota
+	panicDeadLettered
+	panicRedriveFailed
+)
+
+var panicOutcomeNames = [...]string{panicRequeued: "requeued", panicDeadLettered: "dlq", panicRedriveFailed: "redrive_failed"}
+
+// panicsState é o estado da recuperação de pânicos
+type panicsState struct {
+	paymentPanics [len(panicOutcomeNames)]atomic.Int64
+}
+
+func (gw *gateway) registerPanics() {
+	gw.registerMetric(metric{
+		Name: "payment_panics_total",
+		Help: "Pânicos recuperados no processamento de pagamentos, pelo destino do pagamento.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, len(panicOutcomeNames))
+			for i, name := range panicOutcomeNames {
+				samples[i] = metricSample{
+					Labels: map[string]string{"outcome": name},
+					Value:  float64(gw.paymentPanics[i].Load()),
+				}
+			}
+			return samples
+		},
+	})
+}
+
+// goroutinePanic leva um pânico de uma goroutine auxiliar (como a do hedge) para
+// a goroutine do worker, com a pilha de onde ele aconteceu.
+type goroutinePanic struct {
+	value any
+	stack []byte
+}
+
+func (p goroutinePanic) String() string {
+	return fmt.Sprint(p.value)
+}
+
+// capturePanic converte o recover() de uma goroutine auxiliar em goroutinePanic;
+// nil sem pânico.
+func capturePanic(recovered any) *goroutinePanic {
+	if recovered == nil {
+		return nil
+	}
+	if p, ok := recovered.(goroutinePanic); ok {
+		return &p
+	}
+	return &goroutinePanic{value: recovered, stack: debug.Stack()}
+}
+
+// logPaymentPanic registra o pânico com o correlationId e a pilha.
+func (gw *gateway) logPaymentPanic(ctx context.Context, req PaymentRequest, recovered any) {
+	stack := debug.Stack()
+	if p, ok := recovered.(goroutinePanic); ok {
+		recovered, stack = p.value, p.stack
+	}
+	gw.logf(ctx, "Pânico ao processar %s: %v\n%s", req.CorrelationID, recovered, stack)
+}
+
+// processPaymentRecovered roda paymentPipeline nos workers e no ACK_MODE=sync.
+// Depois de um pânico, o pagamento volta para a fila com o atraso base do retry
+// (sendShed) ou, esgotado WORKER_PANIC_RETRIES, vai para a DLQ (sendFailed).
+func (gw *gateway) processPaymentRecovered(ctx context.Context, req PaymentRequest) (result sendResult) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		gw.logPaymentPanic(ctx, req, recovered)
+
+		cfg := gw.currentConfig()
+		if req.Panics < cfg.Workers.PanicRetries {
+			req.Panics++
+			gw.paymentPanics[panicRequeued].Add(1)
+			gw.logf(ctx, "Pagamento %s de volta à fila após o pânico (%d de %d)", req.CorrelationID, req.Panics, cfg.Workers.PanicRetries)
+			gw.paymentQueue.Requeue(req, cfg.Retry.BaseDelay.Std())
+			result = sendShed
+			return
+		}
+
+		gw.paymentPanics[panicDeadLettered].Add(1)
+		gw.logf(ctx, "Pagamento %s enviado à DLQ após %d pânicos", req.CorrelationID, req.Panics+1)
+		gw.pushToDLQ(gw.newDeadLetter(req))
+		gw.publishEvent(PaymentFailed, req, "")
+		result = sendFailed
+	}()
+	return gw.paymentPipeline(ctx, req)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/processorstub"
+	"rinha-backend-2025/internal/storage"
+)
+
+// TestPaymentsRejectsMalformedBodies passa pelas rotas do build em teste
+// (go test e go test -tags minimal): os corpos recusados antes da fila.
+func TestPaymentsRejectsMalformedBodies(t *testing.T) {
+	gw := newTestGateway(t, clock.Real())
+	cfg := gw.currentConfig()
+	handler, _ := gw.newRouter(cfg.Config)
+
+	const id = "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+	valid := `{"correlationId":"` + id + `","amount":19.9}`
+	tests := []struct {
+		name        string
+		contentType string
+		body        io.Reader
+		wantStatus  int
+		wantCode    string
+	}{
+		{"truncado", "application/json", strings.NewReader(valid[:len(valid)-6]), http.StatusBadRequest, errCodeInvalidBody},
+		{"vazio", "application/json", strings.NewReader(""), http.StatusBadRequest, errCodeInvalidBody},
+		{"conexão cortada", "application/json", io.MultiReader(strings.NewReader(valid[:20]), errReader{io.ErrUnexpectedEOF}), http.StatusBadRequest, errCodeInvalidBody},
+		{"dados após o objeto", "application/json", strings.NewReader(valid + `{}`), http.StatusBadRequest, errCodeInvalidBody},
+		{"não é objeto", "application/json", strings.NewReader(`[1,2]`), http.StatusBadRequest, errCodeInvalidBody},
+		{"grande demais", "application/json", strings.NewReader(`{"correlationId":"` + id + `","amount":19.9,"metadata":{"x":"` + strings.Repeat("a", int(cfg.Validation.MaxBodyBytes)) + `"}}`), http.StatusRequestEntityTooLarge, errCodeBodyTooLarge},
+		{"text/plain", "text/plain", strings.NewReader(valid), http.StatusUnsupportedMediaType, errCodeUnsupportedMediaType},
+		{"sem Content-Type", "", strings.NewReader(valid), http.StatusUnsupportedMediaType, errCodeUnsupportedMediaType},
+		{"Content-Type inválido", "application/json; charset", strings.NewReader(valid), http.StatusUnsupportedMediaType, errCodeUnsupportedMediaType},
+		{"campo desconhecido", "application/json", strings.NewReader(`{"correlationId":"` + id + `","amount":19.9,"valor":1}`), http.StatusUnprocessableEntity, errCodeInvalidPayload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/payments", tt.body)
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, esperado %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			var body ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("resposta fora do envelope: %v: %s", err, w.Body)
+			}
+			if body.Error.Code != tt.wantCode {
+				t.Errorf("código %q, esperado %q", body.Error.Code, tt.wantCode)
+			}
+			if body.Error.Message == "" {
+				t.Errorf("envelope sem mensagem")
+			}
+		})
+	}
+}
+
+// errReader simula a conexão fechada no meio do corpo.
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func BenchmarkDecodePayment(b *testing.B) {
+	gw := newGateway(clock.Real())
+	body := []byte(`{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":19.9}`)
+	decoder, ok := lookupPaymentDecoder("application/json")
+	if !ok {
+		b.Fatal("sem decoder para application/json")
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := gw.decodePayment(decoder, body, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkServePayments mede o POST /payments dentro do processo: o router do
+// build em teste, com os processors no processorstub e um storage que descarta
+// tudo. No modo sync a conta inclui a escolha do processor e o envio; sem a
+// rede e o accept da API, é a base para comparar com BenchmarkPostPayments e
+// separar o custo dos listeners (LISTENERS, SO_REUSEPORT) do custo da rota.
+func BenchmarkServePayments(b *testing.B) {
+	cfg, err := config.Load()
+	if err != nil {
+		b.Fatal(err)
+	}
+	cfg.AckMode = config.AckSync
+	// Sem o log de acesso, que escreveria uma linha por iteração
+	cfg.LogLevel = config.LogWarn
+	defs := cfg.ProcessorDefs()
+	for i := range defs {
+		srv := httptest.NewServer(processorstub.New(processorstub.Options{}))
+		b.Cleanup(srv.Close)
+		defs[i].URL = srv.URL
+	}
+	handler := newPeerTestInstance(b, cfg, defs, discardStore{})
+
+	b.ReportAllocs()
+	for b.Loop() {
+		body := `{"correlationId":"` + uuid.NewString() + `","amount":19.9}`
+		r := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+		r.Header.Set("Content-Type", jsonContentType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			b.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+	}
+}
+
+// discardStore aceita tudo e não guarda nada, para o benchmark não medir o storage.
+type discardStore struct{}
+
+func (discardStore) IncrementSummary(context.Context, map[string]*storage.Delta) error { return nil }
+func (discardStore) GetSummary(context.Context) (map[string]storage.Summary, error) {
+	return map[string]storage.Summary{}, nil
+}
+func (discardStore) RecordPayment(context.Context, storage.Record) error { return nil }
+func (discardStore) QueryByRange(context.Context, time.Time, time.Time) (map[string]storage.Summary, error) {
+	return map[string]storage.Summary{}, nil
+}
+func (discardStore) Purge(context.Context) error { return nil }
+
+// BenchmarkPostPayments mede o POST /payments contra a API em BENCH_API_URL
+// (ex.: http://localhost:9999), com a rede e o accept de verdade. A variante
+// newconn abre uma conexão por requisição, para comparar LISTENERS=1 com
+// LISTENERS=0.
+func BenchmarkPostPayments(b *testing.B) {
+	target := strings.TrimRight(os.Getenv("BENCH_API_URL"), "/")
+	if target == "" {
+		b.Skip("BENCH_API_URL não definida")
+	}
+	if resp, err := http.Get(target + "/payments-summary"); err != nil {
+		b.Skipf("API em %s não responde: %v", target, err)
+	} else {
+		resp.Body.Close()
+	}
+
+	for _, variant := range []struct {
+		name      string
+		keepAlive bool
+	}{{"keepalive", true}, {"newconn", false}} {
+		b.Run(variant.name, func(b *testing.B) {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.DisableKeepAlives = !variant.keepAlive
+			client := &http.Client{Timeout: 5 * time.Second, Transport: transport}
+
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					body := `{"correlationId":"` + uuid.NewString() + `","amount":19.9}`
+					resp, err := client.Post(target+"/payments", "application/json", bytes.NewBufferString(body))
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+					if resp.StatusCode >= 300 {
+						b.Errorf("status %d", resp.StatusCode)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/storage"
+)
+
+// Marca os pagamentos repassados por outra instância; eles nunca são repassados
+// de novo, para não ficarem circulando entre instâncias cheias.
+const peerForwardedHeader = "X-Peer-Forwarded"
+
+// Leva o requestedAt já definido, que não faz parte do corpo de POST /payments
+const peerRequestedAtHeader = "X-Peer-Requested-At"
+
+// peerState é o estado do repasse entre instâncias
+type peerState struct {
+	peerURLs       []string
+	peerClient     *http.Client
+	peerThreshold  int
+	peerNext       atomic.Uint64
+	peerForwarded  atomic.Int64
+	peerForwardErr atomic.Int64
+}
+
+func (gw *gateway) registerPeer() {
+	gw.registerMetric(metric{
+		Name: "peer_forwarded_total",
+		Help: "Pagamentos repassados para outra instância por excesso de fila.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.peerForwarded.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "peer_forward_failures_total",
+		Help: "Repasses recusados pela outra instância e processados localmente.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.peerForwardErr.Load())}}
+		},
+	})
+}
+
+func (gw *gateway) initPeers(cfg config.PeersConfig) {
+	if len(cfg.URLs) == 0 {
+		return
+	}
+
+	for _, u := range cfg.URLs {
+		gw.peerURLs = append(gw.peerURLs, strings.TrimRight(u, "/"))
+	}
+	gw.peerClient = &http.Client{Timeout: cfg.Timeout.Std()}
+	gw.peerThreshold = cfg.QueueThreshold
+	log.Printf("Repasse para %d instância(s) com fila acima de %d", len(gw.peerURLs), gw.peerThreshold)
+}
+
+// submitPayment é a entrada dos pagamentos recebidos de clientes: com a fila
+// local acima do limite, o pagamento vai para outra instância.
+func (gw *gateway) submitPayment(req PaymentRequest) {
+	if len(gw.peerURLs) > 0 && gw.paymentQueue.Len() >= gw.peerThreshold {
+		go gw.forwardToPeer(req)
+		return
+	}
+	gw.paymentQueue.Enqueue(req)
+}
+
+// forwardToPeer repassa o pagamento em rodízio entre as instâncias; se a outra
+// instância não aceitar, ele volta para a fila local.
+func (gw *gateway) forwardToPeer(req PaymentRequest) {
+	peer := gw.peerURLs[gw.peerNext.Add(1)%uint64(len(gw.peerURLs))]
+
+	if err := gw.postToPeer(peer, req); err != nil {
+		gw.peerForwardErr.Add(1)
+		gw.logf(withRequestID(context.Background(), req.RequestID), "Erro ao repassar %s para %s: %v", req.CorrelationID, peer, err)
+		gw.paymentQueue.Enqueue(req)
+		return
+	}
+	gw.peerForwarded.Add(1)
+}
+
+func (gw *gateway) postToPeer(peer string, req PaymentRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, peer+"/payments", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", jsonContentType)
+	httpReq.Header.Set(peerForwardedHeader, "1")
+	if req.RequestID != "" {
+		httpReq.Header.Set(requestIDHeader, req.RequestID)
+	}
+	if !req.RequestedAt.IsZero() {
+		httpReq.Header.Set(peerRequestedAtHeader, req.RequestedAt.Format(time.RFC3339Nano))
+	}
+
+	resp, err := gw.peerClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// parsePeerRequestedAt lê o header de repasse; ausente ou inválido retorna zero e o
+// instante é definido aqui.
+func parsePeerRequestedAt(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	requestedAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		log.Printf("Header %s inválido: %q", peerRequestedAtHeader, value)
+		return time.Time{}
+	}
+	return requestedAt.UTC()
+}
+
+// internalSummaryDelta expõe às outras instâncias o que do resumo só esta
+// instância enxerga. Os contadores pendentes são descarregados antes, como na
+// leitura consistente, e a resposta leva só os pagamentos guardados localmente:
+// quem pediu lê o storage compartilhado depois, e assim cada pagamento entra uma
+// única vez na soma.
+func (gw *gateway) internalSummaryDelta(ctx context.Context, from, to time.Time) ([]byte, error) {
+	gw.counterFlushGate.Lock()
+	gw.flushCountersLocked(ctx)
+	gw.counterFlushGate.Unlock()
+
+	summary, err := gw.localSummary(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return gw.newPaymentSummary(summary).appendJSON(nil, gw.currentConfig().amountHalfUp), nil
+}
+
+func (gw *gateway) localSummary(ctx context.Context, from, to time.Time) (map[string]storage.Summary, error) {
+	local, ok := gw.store.(storage.LocalSummarizer)
+	if !ok {
+		return nil, nil
+	}
+	if !from.IsZero() && to.IsZero() {
+		to = gw.appClock.Now().Add(time.Hour)
+	}
+	return local.LocalSummary(ctx, from, to)
+}
+
+// fetchPeerDeltas pede a cada instância a parte do resumo que só ela guarda. Deve
+// vir antes da leitura do storage, que então já inclui o que elas descarregaram.
+// Uma instância que não responde fica de fora, com aviso no log.
+func (gw *gateway) fetchPeerDeltas(from, to time.Time) []map[string]ProcessorSummary {
+	query := url.Values{}
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.RFC3339Nano))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.RFC3339Nano))
+	}
+
+	results := make([]map[string]ProcessorSummary, len(gw.peerURLs))
+	var wg sync.WaitGroup
+	for i, peer := range gw.peerURLs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			peerSummary, err := gw.fetchPeerSummary(peer, query)
+			if err != nil {
+				log.Printf("Erro ao obter resumo de %s: %v", peer, err)
+				return
+			}
+			results[i] = peerSummary
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// addPeerDeltas soma ao resumo as partes obtidas por fetchPeerDeltas.
+func addPeerDeltas(summary PaymentSummaryResponse, deltas []map[string]ProcessorSummary) {
+	for _, peerSummary := range deltas {
+		for processor, s := range peerSummary {
+			current := summary[processor]
+			current.TotalRequests += s.TotalRequests
+			current.TotalAmount += s.TotalAmount
+			summary[processor] = current
+		}
+	}
+}
+
+func (gw *gateway) fetchPeerSummary(peer string, query url.Values) (map[string]ProcessorSummary, error) {
+	resp, err := gw.peerClient.Get(peer + "/internal/summary-delta?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var summary map[string]ProcessorSummary
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
@@ -0,0 +1,24 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (gw *gateway) handleInternalSummaryDelta(c *gin.Context) {
+	from, to, err := parseSummaryRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, errMessage(err))
+		return
+	}
+	body, err := gw.internalSummaryDelta(c.Request.Context(), from, to)
+	if err != nil {
+		gw.logf(c.Request.Context(), "Erro ao calcular o resumo desta instância: %v", err)
+		respondError(c, http.StatusInternalServerError, errCodeInternal, msg(msgSummaryDeltaFailed))
+		return
+	}
+	c.Data(http.StatusOK, jsonContentType, body)
+}
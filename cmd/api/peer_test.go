@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/health"
+	"rinha-backend-2025/internal/keyspace"
+	"rinha-backend-2025/internal/processorstub"
+	"rinha-backend-2025/internal/queue"
+	"rinha-backend-2025/internal/storage"
+)
+
+// TestPeersAnswerTheSameSummary sobe duas instâncias sobre o mesmo storage, cada
+// uma com a outra em PEER_URLS. Os contadores pendentes só chegam ao storage no
+// flush, e a instância que recebeu o pagamento é a única que os enxerga; ainda
+// assim, as duas respondem o mesmo /payments-summary, com todos os pagamentos.
+func TestPeersAnswerTheSameSummary(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	// As URLs existem antes dos routers, que precisam delas em PEER_URLS
+	var handlers [2]http.Handler
+	var urls [2]string
+	for i := range handlers {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers[i].ServeHTTP(w, r)
+		}))
+		t.Cleanup(srv.Close)
+		urls[i] = srv.URL
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Resposta depois do envio ao processor, sem esperar a fila
+	cfg.AckMode = config.AckSync
+	defs := cfg.ProcessorDefs()
+	names := make([]string, len(defs))
+	for i := range defs {
+		srv := httptest.NewServer(processorstub.New(processorstub.Options{}))
+		t.Cleanup(srv.Close)
+		defs[i].URL = srv.URL
+		names[i] = defs[i].Name
+	}
+	store := storage.NewDegradable(client, names, 0, storage.AmountOptions{}, clock.Real(), keyspace.Space{})
+
+	for i := range handlers {
+		peerCfg := cfg
+		peerCfg.Peers = config.PeersConfig{
+			URLs:             []string{urls[1-i]},
+			QueueThreshold:   cfg.Workers.QueueSize,
+			Timeout:          config.Duration(time.Second),
+			AggregateSummary: true,
+		}
+		handlers[i] = newPeerTestInstance(t, peerCfg, defs, store)
+	}
+
+	for n := 0; n < 10; n++ {
+		body := `{"correlationId":"` + uuid.NewString() + `","amount":19.9}`
+		resp, err := http.Post(urls[n%2]+"/payments", jsonContentType, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /payments na instância %d: status %d", n%2, resp.StatusCode)
+		}
+	}
+
+	var summaries [2][]byte
+	for i, u := range urls {
+		resp, err := http.Get(u + "/payments-summary")
+		if err != nil {
+			t.Fatal(err)
+		}
+		summaries[i], err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /payments-summary na instância %d: status %d: %s", i, resp.StatusCode, summaries[i])
+		}
+	}
+	if !bytes.Equal(summaries[0], summaries[1]) {
+		t.Fatalf("resumos diferentes:\n%s\n%s", summaries[0], summaries[1])
+	}
+	want := `"default":{"totalRequests":10,"totalAmount":199.00}`
+	if !bytes.Contains(summaries[0], []byte(want)) {
+		t.Errorf("resumo %s, esperado %s", summaries[0], want)
+	}
+}
+
+// newPeerTestInstance monta uma instância como o main, só com o necessário para
+// processar e resumir pagamentos, e retorna o router dela.
+func newPeerTestInstance(t testing.TB, cfg config.Config, defs []config.ProcessorDef, store storage.Storage) http.Handler {
+	t.Helper()
+	gw := newGateway(clock.Real())
+	if err := gw.applyConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	gw.httpClient = &http.Client{Timeout: cfg.HTTP.Timeout.Std()}
+	gw.initProcessors(defs, gw.httpClient, "", nil)
+	gw.store = store
+
+	gw.healthMonitor = health.New(health.Options{
+		Names:    gw.processorNames,
+		Clients:  gw.processorClients,
+		Clock:    gw.appClock,
+		Redis:    gw.currentRedis,
+		Timeout:  cfg.HTTP.HealthCheckTimeout.Std(),
+		Interval: healthCheckInterval(cfg.Health),
+	})
+	gw.initHealthInference(cfg.Health.Inference)
+	gw.initFailback(cfg.Failback)
+	gw.initProcessorLimits(cfg.Processors.Limits)
+	gw.initProcessorLimiters(cfg.Limiter)
+	gw.initDispatchPacing(cfg.Pacing)
+	gw.initSLOGuard(cfg.SLO)
+	gw.initLatencyStats(cfg.Selector.LatencyWindow.Std())
+	gw.initOutcomeStats(cfg.Selector.LatencyWindow.Std())
+	gw.startEventBus(cfg.Events)
+	t.Cleanup(func() { gw.stopEventBus(context.Background()) })
+	gw.initPaymentPipeline(cfg.Pipeline)
+
+	// Só para a conferência de conflitos: no modo sync nada passa pela fila
+	var err error
+	gw.paymentQueue, err = queue.New(queue.Options[PaymentRequest]{
+		Size:  cfg.Workers.QueueSize,
+		Label: func(req PaymentRequest) string { return req.CorrelationID },
+		Key:   func(req PaymentRequest) string { return req.CorrelationID },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw.initPeers(cfg.Peers)
+
+	handler, _ := gw.newRouter(cfg)
+	return handler
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// PaymentHandler processa um pagamento tirado da fila.
+type PaymentHandler func(ctx context.Context, req PaymentRequest) sendResult
+
+// PaymentMiddleware envolve o processamento com uma responsabilidade transversal.
+// Pode encerrar o pagamento sem chamar next, dando a ele o seu destino.
+type PaymentMiddleware func(next PaymentHandler) PaymentHandler
+
+// Construtores dos middlewares por nome (PIPELINE_MIDDLEWARES)
+var paymentMiddlewares = map[string]func(*gateway, config.PipelineConfig) PaymentMiddleware{
+	config.MiddlewareValidation: (*gateway).validationMiddleware,
+	config.MiddlewareDedup:      (*gateway).dedupMiddleware,
+	config.MiddlewareRateLimit:  (*gateway).dispatchRateMiddleware,
+	config.MiddlewareMetrics:    (*gateway).metricsMiddleware,
+	config.MiddlewareAudit:      (*gateway).auditMiddleware,
+}
+
+// pipelineState é o estado dos middlewares
+type pipelineState struct {
+	// Processamento dos workers, com os middlewares configurados em volta de
+	// processPayment
+	paymentPipeline    PaymentHandler
+	pipelineRejected   atomic.Int64
+	pipelineDuplicates atomic.Int64
+	pipelineThrottled  atomic.Int64
+
+	// Por sendResult
+	pipelineResults [len(sendResultNames)]atomic.Int64
+	pipelineMicros  [len(sendResultNames)]atomic.Int64
+}
+
+// initPaymentPipeline monta a cadeia na ordem configurada: o primeiro da lista
+// é o mais externo.
+func (gw *gateway) initPaymentPipeline(cfg config.PipelineConfig) {
+	handler := PaymentHandler(gw.processPayment)
+	for i := len(cfg.Middlewares) - 1; i >= 0; i-- {
+		handler = paymentMiddlewares[cfg.Middlewares[i]](gw, cfg)(handler)
+	}
+	gw.paymentPipeline = handler
+	if len(cfg.Middlewares) > 0 {
+		log.Printf("Middlewares do processamento: %s", strings.Join(cfg.Middlewares, ", "))
+	}
+}
+
+// Nomes dos sendResult nas métricas
+var sendResultNames = [...]string{
+	sendFailed:    "failed",
+	sendSucceeded: "succeeded",
+	sendShed:      "shed",
+	sendUnknown:   "unknown",
+	sendDeferred:  "deferred",
+	sendDuplicate: "duplicate",
+	sendRejected:  "rejected",
+}
+
+// validationMiddleware aplica as regras do POST /payments aos pagamentos que
+// chegam à fila por outros caminhos (backend externo, repasse das instâncias).
+// Os inválidos são descartados: repetir não os tornaria válidos.
+func (gw *gateway) validationMiddleware(config.PipelineConfig) PaymentMiddleware {
+	gw.registerMetric(metric{
+		Name: "pipeline_rejected_total",
+		Help: "Pagamentos descartados pelo middleware validation.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.pipelineRejected.Load())}}
+		},
+	})
+	return func(next PaymentHandler) PaymentHandler {
+		return func(ctx context.Context, req PaymentRequest) sendResult {
+			if err := gw.validatePaymentRequest(req, gw.currentConfig().Validation.MaxAmount); err != nil {
+				gw.pipelineRejected.Add(1)
+				gw.logf(ctx, "Pagamento %s descartado: %v", req.CorrelationID, err)
+				return sendFailed
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// paymentDedup lembra os correlationIds em andamento e os aceitos há menos de
+// ttl, na época em que foram vistos, até max entradas.
+type paymentDedup struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	max  int
+	seen map[string]dedupEntry
+	// Contador das entradas que saíram pelo teto
+	evicted *atomic.Int64
+}
+
+type dedupEntry struct {
+	settled bool
+	epoch   int64
+	expires time.Time
+}
+
+// begin registra o início do processamento; false se o correlationId já está em
+// andamento ou foi aceito, e settled diz qual dos dois.
+func (d *paymentDedup) begin(correlationID string, epoch int64, now time.Time) (settled, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, found := d.seen[correlationID]; found && e.epoch == epoch && now.Before(e.expires) {
+		return e.settled, false
+	}
+	if _, found := d.seen[correlationID]; !found && len(d.seen) >= d.max {
+		d.evictLocked(now)
+	}
+	d.seen[correlationID] = dedupEntry{epoch: epoch, expires: now.Add(d.ttl)}
+	return false, true
+}
+
+// evictLocked abre espaço no teto (MEMORY_MAX_DEDUP_ENTRIES): primeiro saem os
+// vencidos e, sem nenhum, uma entrada qualquer. Um repetido que escape chega ao
+// processor, que recusa o correlationId já aceito.
+func (d *paymentDedup) evictLocked(now time.Time) {
+	if d.sweepLocked(now) > 0 {
+		return
+	}
+	for correlationID := range d.seen {
+		delete(d.seen, correlationID)
+		d.evicted.Add(1)
+		return
+	}
+}
+
+// finish guarda os aceitos e os de resultado desconhecido, que o outbox resolve;
+// os demais podem voltar (fila, DLQ) e são esquecidos.
+func (d *paymentDedup) finish(correlationID string, result sendResult, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if result != sendSucceeded && result != sendUnknown {
+		delete(d.seen, correlationID)
+		return
+	}
+	// Ausente se saiu pelo teto durante o envio
+	if e, ok := d.seen[correlationID]; ok {
+		e.settled, e.expires = true, now.Add(d.ttl)
+		d.seen[correlationID] = e
+	}
+}
+
+func (d *paymentDedup) sweep(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sweepLocked(now)
+}
+
+// sweepLocked remove as entradas vencidas e retorna quantas saíram.
+func (d *paymentDedup) sweepLocked(now time.Time) int {
+	removed := 0
+	for correlationID, e := range d.seen {
+		if !now.Before(e.expires) {
+			delete(d.seen, correlationID)
+			removed++
+		}
+	}
+	return removed
+}
+
+// dedupMiddleware não processa de novo um correlationId aceito nesta instância
+// há menos de PIPELINE_DEDUP_TTL; um repetido durante o envio do original volta
+// para a fila. O purge muda a época e libera os correlationIds.
+func (gw *gateway) dedupMiddleware(cfg config.PipelineConfig) PaymentMiddleware {
+	dedup := &paymentDedup{
+		ttl:  cfg.DedupTTL.Std(),
+		max:  gw.currentConfig().Memory.MaxDedupEntries,
+		seen: make(map[string]dedupEntry),
+
+		evicted: &gw.memoryEvicted[evictedDedup],
+	}
+	go func() {
+		ticker := time.NewTicker(dedup.ttl)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			dedup.sweep(gw.appClock.Now())
+		}
+	}()
+	gw.registerMetric(metric{
+		Name: "pipeline_duplicates_total",
+		Help: "Pagamentos repetidos barrados pelo middleware dedup.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.pipelineDuplicates.Load())}}
+		},
+	})
+	return func(next PaymentHandler) PaymentHandler {
+		return func(ctx context.Context, req PaymentRequest) sendResult {
+			settled, ok := dedup.begin(req.CorrelationID, gw.currentEpoch(), gw.appClock.Now())
+			if !ok {
+				gw.pipelineDuplicates.Add(1)
+				if settled {
+					gw.logf(ctx, "Pagamento %s já aceito, ignorando a repetição", req.CorrelationID)
+					return sendSucceeded
+				}
+				gw.paymentQueue.Requeue(req, gw.currentConfig().Limiter.RequeueDelay.Std())
+				return sendShed
+			}
+			result := next(ctx, req)
+			dedup.finish(req.CorrelationID, result, gw.appClock.Now())
+			return result
+		}
+	}
+}
+
+// dispatchRateMiddleware limita os pagamentos processados por segundo com o
+// token bucket do rate limiting, compartilhado entre as instâncias pelo Redis.
+// Sem token, o pagamento volta para a fila pelo tempo até o próximo.
+func (gw *gateway) dispatchRateMiddleware(cfg config.PipelineConfig) PaymentMiddleware {
+	limits := []bucketLimit{{gw.keys.Key("ratelimit:{rinha}:dispatch"), cfg.Rate, float64(cfg.Burst)}}
+	gw.registerMetric(metric{
+		Name: "pipeline_throttled_total",
+		Help: "Pagamentos devolvidos à fila pelo middleware ratelimit.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.pipelineThrottled.Load())}}
+		},
+	})
+	return func(next PaymentHandler) PaymentHandler {
+		return func(ctx context.Context, req PaymentRequest) sendResult {
+			if wait := gw.takeToken(ctx, limits); wait > 0 {
+				gw.pipelineThrottled.Add(1)
+				gw.paymentQueue.Requeue(req, wait)
+				return sendShed
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// metricsMiddleware conta os desfechos e o tempo gasto em cada um.
+func (gw *gateway) metricsMiddleware(config.PipelineConfig) PaymentMiddleware {
+	gw.registerMetric(metric{
+		Name: "pipeline_results_total",
+		Help: "Pagamentos processados por desfecho (failed, succeeded, shed, unknown, deferred, duplicate, rejected).",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return collectPipelineResults(gw.pipelineResults[:], 1)
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "pipeline_seconds_total",
+		Help: "Tempo de processamento somado por desfecho.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return collectPipelineResults(gw.pipelineMicros[:], 1e-6)
+		},
+	})
+	return func(next PaymentHandler) PaymentHandler {
+		return func(ctx context.Context, req PaymentRequest) sendResult {
+			start := gw.appClock.Now()
+			result := next(ctx, req)
+			gw.pipelineResults[result].Add(1)
+			gw.pipelineMicros[result].Add(gw.appClock.Since(start).Microseconds())
+			return result
+		}
+	}
+}
+
+func collectPipelineResults(values []atomic.Int64, scale float64) []metricSample {
+	samples := make([]metricSample, len(values))
+	for i := range values {
+		samples[i] = metricSample{
+			Labels: map[string]string{"result": sendResultNames[i]},
+			Value:  float64(values[i].Load()) * scale,
+		}
+	}
+	return samples
+}
+
+// auditMiddleware registra no histórico o início de cada processamento e os
+// pagamentos adiados pelo orçamento do fallback ou pelo TPS dos processors, que
+// voltam para a fila sem passar pelo limitador.
+func (gw *gateway) auditMiddleware(config.PipelineConfig) PaymentMiddleware {
+	return func(next PaymentHandler) PaymentHandler {
+		return func(ctx context.Context, req PaymentRequest) sendResult {
+			gw.recordAudit(AuditEntry{CorrelationID: req.CorrelationID, Event: auditProcessing, Amount: req.Amount})
+			result := next(ctx, req)
+			if result == sendDeferred {
+				gw.recordAudit(AuditEntry{CorrelationID: req.CorrelationID, Event: auditShed})
+			}
+			return result
+		}
+	}
+}
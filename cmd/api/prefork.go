@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"syscall"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// RUN_MODE=prefork: o processo pai abre PREFORK_CHILDREN cópias do próprio
+// binário e só as supervisiona. Cada filho escuta na mesma porta com
+// SO_REUSEPORT, com o kernel distribuindo as conexões, e tem o próprio GC e um
+// heap menor, o que encurta as pausas sob o limite de memória do container. O
+// pai reinicia o filho que terminar, repassa o SIGHUP e, no SIGINT/SIGTERM,
+// encerra todos dentro do SHUTDOWN_TIMEOUT.
+
+// Índice do filho, definido pelo pai; ausente no pai e no modo single
+const preforkChildEnv = "PREFORK_CHILD_INDEX"
+
+// Folga além do SHUTDOWN_TIMEOUT dos filhos antes de matá-los
+const preforkKillGrace = 5 * time.Second
+
+// preforkState é o estado do filho no prefork
+type preforkState struct {
+	// Índice deste processo entre os filhos; -1 fora do prefork
+	preforkIndex int
+}
+
+type preforkChild struct {
+	index     int
+	cmd       *exec.Cmd
+	startedAt time.Time
+	// Espera antes do próximo restart
+	delay time.Duration
+}
+
+type preforkExit struct {
+	index int
+	err   error
+}
+
+// preforkChildIndex lê o índice definido pelo pai; false no próprio pai.
+func preforkChildIndex() (int, bool) {
+	v := os.Getenv(preforkChildEnv)
+	if v == "" {
+		return 0, false
+	}
+	index, err := strconv.Atoi(v)
+	if err != nil || index < 0 {
+		log.Fatalf("%s inválido: %q", preforkChildEnv, v)
+	}
+	return index, true
+}
+
+func preforkChildren(cfg config.PreforkConfig) int {
+	if cfg.Children > 0 {
+		return cfg.Children
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// setupPreforkChild ajusta a configuração do filho index: só o primeiro abre a
+// porta gRPC e a de diagnóstico, os health-checks dividem o ciclo com os irmãos
+// e cada um grava o próprio WAL.
+func (gw *gateway) setupPreforkChild(cfg *config.Config, index int) {
+	n := preforkChildren(cfg.Prefork)
+	gw.preforkIndex = index
+	log.SetPrefix(fmt.Sprintf("[filho %d] ", index))
+	log.SetFlags(log.Flags() | log.Lmsgprefix)
+	// O pai repassa o SIGHUP a todos; até startConfigReload, o padrão mataria o filho
+	signal.Ignore(syscall.SIGHUP)
+
+	if index > 0 {
+		cfg.GRPC.Port = ""
+		if cfg.Debug.Port != "" {
+			cfg.Debug.Enabled = false
+		}
+	}
+	instances := cfg.HealthInstances()
+	if cfg.Health.Slot >= 0 {
+		cfg.Health.Slot = cfg.Health.Slot*n + index
+	}
+	cfg.Health.Instances = instances * n
+	if cfg.Counters.WALDir != "" {
+		cfg.Counters.WALDir = filepath.Join(cfg.Counters.WALDir, fmt.Sprintf("child-%d", index))
+	}
+}
+
+// runPrefork supervisiona os filhos até o SIGINT/SIGTERM e retorna o código de
+// saída do pai.
+func runPrefork(cfg config.Config) int {
+	n := preforkChildren(cfg.Prefork)
+	exe, err := os.Executable()
+	if err != nil {
+		log.Printf("Erro ao localizar o executável: %v", err)
+		return 1
+	}
+	env := preforkChildEnvironment(n)
+
+	exits := make(chan preforkExit, n)
+	restarts := make(chan int, n)
+	children := make([]*preforkChild, n)
+	running := 0
+	start := func(child *preforkChild) error {
+		cmd := exec.Command(exe, os.Args[1:]...)
+		cmd.Env = append(env[:len(env):len(env)], fmt.Sprintf("%s=%d", preforkChildEnv, child.index))
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		child.cmd, child.startedAt = cmd, time.Now()
+		running++
+		go func() {
+			exits <- preforkExit{index: child.index, err: cmd.Wait()}
+		}()
+		return nil
+	}
+
+	for i := range children {
+		children[i] = &preforkChild{index: i, delay: cfg.Prefork.RestartDelay.Std()}
+		if err := start(children[i]); err != nil {
+			log.Printf("Erro ao iniciar o filho %d: %v", i, err)
+			stopPreforkChildren(children, exits, running, cfg.ShutdownTimeout.Std())
+			return 1
+		}
+	}
+	log.Printf("Prefork: %d filhos na porta %s (pid do pai %d)", n, cfg.Port, os.Getpid())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				for _, child := range children {
+					if child.cmd != nil {
+						child.cmd.Process.Signal(syscall.SIGHUP)
+					}
+				}
+				continue
+			}
+			log.Printf("Encerrando %d filhos...", running)
+			return stopPreforkChildren(children, exits, running, cfg.ShutdownTimeout.Std())
+
+		case exit := <-exits:
+			running--
+			child := children[exit.index]
+			uptime := time.Since(child.startedAt)
+			child.cmd = nil
+			// Saídas seguidas logo depois da subida espaçam os restarts
+			if uptime >= cfg.Prefork.MaxRestartDelay.Std() {
+				child.delay = cfg.Prefork.RestartDelay.Std()
+			}
+			log.Printf("Filho %d terminou após %v (%v); reiniciando em %v",
+				exit.index, uptime.Round(time.Millisecond), exitDescription(exit.err), child.delay)
+			time.AfterFunc(child.delay, func() { restarts <- exit.index })
+			child.delay = min(child.delay*2, cfg.Prefork.MaxRestartDelay.Std())
+
+		case index := <-restarts:
+			if err := start(children[index]); err != nil {
+				log.Printf("Erro ao reiniciar o filho %d: %v", index, err)
+				time.AfterFunc(children[index].delay, func() { restarts <- index })
+			}
+		}
+	}
+}
+
+// stopPreforkChildren manda SIGTERM aos filhos e espera o encerramento gracioso
+// de cada um; quem passar do prazo é morto.
+func stopPreforkChildren(children []*preforkChild, exits <-chan preforkExit, running int, timeout time.Duration) int {
+	for _, child := range children {
+		if child != nil && child.cmd != nil {
+			child.cmd.Process.Signal(syscall.SIGTERM)
+		}
+	}
+
+	code := 0
+	deadline := time.After(timeout + preforkKillGrace)
+	for running > 0 {
+		select {
+		case exit := <-exits:
+			running--
+			children[exit.index].cmd = nil
+			if exit.err != nil {
+				log.Printf("Filho %d encerrado com erro: %v", exit.index, exitDescription(exit.err))
+				code = 1
+			}
+		case <-deadline:
+			for _, child := range children {
+				if child != nil && child.cmd != nil {
+					log.Printf("Filho %d não encerrou em %v; matando", child.index, timeout+preforkKillGrace)
+					child.cmd.Process.Kill()
+				}
+			}
+			return 1
+		}
+	}
+	log.Printf("Filhos encerrados")
+	return code
+}
+
+// preforkChildEnvironment divide entre os filhos os Ps e o GOMEMLIMIT do pai,
+// para que a soma continue dentro do container. Com chaves repetidas, o
+// os/exec usa o último valor.
+func preforkChildEnvironment(n int) []string {
+	env := append(os.Environ(),
+		fmt.Sprintf("PREFORK_CHILDREN=%d", n),
+		fmt.Sprintf("GOMAXPROCS=%d", max(1, runtime.GOMAXPROCS(0)/n)))
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		env = append(env, fmt.Sprintf("GOMEMLIMIT=%d", limit/int64(n)))
+	}
+	return env
+}
+
+func exitDescription(err error) string {
+	if err == nil {
+		return "código 0"
+	}
+	return err.Error()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Restrições por processor (PROCESSOR_<NOME>_MAX_AMOUNT e _MAX_TPS): um processor
+// que recusa valores acima de um teto ou limita as transações por segundo sai da
+// ordem do selector para os pagamentos que não cabem nele, em vez de gastar
+// tentativas em recusas. Um valor acima do teto de todos os processors é
+// recusado já na validação; sem vaga de TPS em nenhum, o pagamento volta para a
+// fila como no orçamento do fallback.
+
+// processorLimitsState é o estado das restrições dos processors
+type processorLimitsState struct {
+	processorLimits map[string]*processorLimit
+	// Maior valor aceito por algum processor; 0 quando algum não tem teto
+	processorsMaxAmount float64
+}
+
+// processorLimit são as restrições de um processor em vigor.
+type processorLimit struct {
+	maxAmount float64
+	// nil sem MaxTPS
+	tps *tpsBucket
+
+	amountSkips atomic.Int64
+	tpsSkips    atomic.Int64
+}
+
+// tpsBucket é um token bucket local, com capacidade de um segundo de envios.
+type tpsBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func (gw *gateway) registerProcessorLimits() {
+	gw.registerMetric(metric{
+		Name: "processor_limit_skips_total",
+		Help: "Pagamentos desviados de um processor por uma restrição dele (amount ou tps).",
+		Type: "counter",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, 0, 2*len(gw.processorLimits))
+			for name, l := range gw.processorLimits {
+				samples = append(samples,
+					metricSample{Labels: map[string]string{"processor": name, "limit": "amount"}, Value: float64(l.amountSkips.Load())},
+					metricSample{Labels: map[string]string{"processor": name, "limit": "tps"}, Value: float64(l.tpsSkips.Load())},
+				)
+			}
+			return samples
+		},
+	})
+}
+
+// initProcessorLimits aplica as restrições, depois de registrados os processors.
+func (gw *gateway) initProcessorLimits(limits map[string]config.ProcessorLimits) {
+	unlimited := false
+	for _, name := range gw.processorNames {
+		cfg := limits[name]
+		if cfg.MaxAmount == 0 {
+			unlimited = true
+		}
+		gw.processorsMaxAmount = max(gw.processorsMaxAmount, cfg.MaxAmount)
+		if cfg == (config.ProcessorLimits{}) {
+			continue
+		}
+
+		l := &processorLimit{maxAmount: cfg.MaxAmount}
+		if cfg.MaxTPS > 0 {
+			l.tps = &tpsBucket{rate: cfg.MaxTPS, tokens: max(1, cfg.MaxTPS), last: gw.appClock.Now()}
+		}
+		gw.processorLimits[name] = l
+		log.Printf("Processor %s limitado: valor máximo %.2f, %.0f TPS (0 sem limite)", name, cfg.MaxAmount, cfg.MaxTPS)
+	}
+	if unlimited {
+		gw.processorsMaxAmount = 0
+	}
+}
+
+// processorsAmountLimit retorna o maior valor aceito pelos processors; false
+// quando algum não tem teto.
+func (gw *gateway) processorsAmountLimit() (float64, bool) {
+	return gw.processorsMaxAmount, gw.processorsMaxAmount > 0
+}
+
+// filterByAmount tira da ordem os processors cujo teto o valor ultrapassa.
+func (gw *gateway) filterByAmount(ranking []string, amount float64) []string {
+	if len(gw.processorLimits) == 0 {
+		return ranking
+	}
+	allowed := ranking[:0:0]
+	for _, name := range ranking {
+		if l := gw.processorLimits[name]; l != nil && l.maxAmount > 0 && amount > l.maxAmount {
+			l.amountSkips.Add(1)
+			continue
+		}
+		allowed = append(allowed, name)
+	}
+	return allowed
+}
+
+// takeProcessorTPS consome uma transação do processor; false sem vaga no
+// segundo corrente.
+func (gw *gateway) takeProcessorTPS(processor string) bool {
+	l := gw.processorLimits[processor]
+	if l == nil || l.tps == nil {
+		return true
+	}
+	if l.tps.take(gw.appClock.Now()) {
+		return true
+	}
+	l.tpsSkips.Add(1)
+	return false
+}
+
+func (b *tpsBucket) take(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(max(1, b.rate), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/health"
+	pp "rinha-backend-2025/internal/processor"
+	"rinha-backend-2025/internal/selector"
+)
+
+// processorsState é o estado dos processors configurados
+type processorsState struct {
+	// Nomes em ordem de prioridade
+	processorNames []string
+	processorDefs  map[string]config.ProcessorDef
+	// Clientes dos Payment Processors, por nome
+	processorClients map[string]pp.ProcessorClient
+	healthMonitor    *health.Monitor
+}
+
+func (gw *gateway) initProcessors(defs []config.ProcessorDef, client *http.Client, adminToken string, auth map[string]config.ProcessorAuth) {
+	for _, def := range defs {
+		processorClient := pp.NewHTTPClient(def.URL, client)
+		processorClient.SetAdminToken(adminToken)
+		if a, ok := auth[def.Name]; ok {
+			processorClient.SetAuth(pp.Auth{
+				BearerToken:     a.BearerToken,
+				HMACSecret:      a.HMACSecret,
+				SignatureHeader: a.SignatureHeader,
+				Headers:         a.Headers,
+			})
+		}
+
+		gw.processorNames = append(gw.processorNames, def.Name)
+		gw.processorDefs[def.Name] = def
+		gw.processorClients[def.Name] = processorClient
+	}
+}
+
+// initDryRunProcessors troca os processors configurados por simulações em
+// memória, com a latência e a taxa de falhas do DRY_RUN.
+func (gw *gateway) initDryRunProcessors(defs []config.ProcessorDef, cfg config.DryRunConfig) {
+	for _, def := range defs {
+		fake := pp.NewFake()
+		fake.SetDelay(cfg.Latency.Std())
+		fake.SetFailureRate(cfg.FailureRate)
+		fake.SetHealth(pp.Health{MinResponseTime: int(cfg.Latency.Std().Milliseconds())})
+
+		gw.processorNames = append(gw.processorNames, def.Name)
+		gw.processorDefs[def.Name] = def
+		gw.processorClients[def.Name] = fake
+	}
+	log.Printf("DRY_RUN: %d processors simulados em memória (latência %v, falhas %.0f%%)",
+		len(defs), cfg.Latency.Std(), cfg.FailureRate*100)
+}
+
+// rankProcessors pede ao selector a ordem de tentativa do próximo pagamento.
+func (gw *gateway) rankProcessors(ctx context.Context) []string {
+	return gw.rankCandidates(gw.routingCandidates(ctx))
+}
+
+// rankCandidates ordena candidatos já coletados, com o failback e a guarda de SLO.
+func (gw *gateway) rankCandidates(candidates []selector.Candidate) []string {
+	return gw.applySLOGuard(gw.applyFailback(gw.currentConfig().selector.Rank(candidates)))
+}
+
+// routingCandidates é o que o selector recebe de cada processor, em ordem de
+// prioridade.
+func (gw *gateway) routingCandidates(ctx context.Context) []selector.Candidate {
+	candidates := make([]selector.Candidate, len(gw.processorNames))
+	for i, name := range gw.processorNames {
+		def := gw.processorDefs[name]
+		status := gw.healthMonitor.Get(ctx, name)
+		failing := gw.processorFailing(name, status)
+		// Recuperado pelo failback depois do health-check que o deu como falhando
+		if failing && name == gw.preferredProcessor() && gw.failbackRecovered(status.LastCheckedAt) {
+			failing = false
+		}
+		candidates[i] = selector.Candidate{
+			Name:            name,
+			Fee:             def.Fee,
+			Priority:        def.Priority,
+			Failing:         failing,
+			MinResponseTime: time.Duration(status.MinResponseTime) * time.Millisecond,
+		}
+		if stats := gw.processorLatency[name]; stats != nil {
+			candidates[i].Observed, candidates[i].Samples = stats.quantileAndCount(gw.currentConfig().Selector.LatencyQuantile)
+		}
+		if stats := gw.processorOutcomes[name]; stats != nil {
+			candidates[i].Successes, candidates[i].Failures = stats.Counts()
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/queue"
+)
+
+// Fila fora do processo (QUEUE_BACKEND=redis ou nats): os pagamentos aceitos
+// ficam gravados até um worker terminá-los, e sobrevivem a um restart da
+// instância em troca de uma ida ao backend por pagamento. Cada instância (e
+// cada filho do prefork) consome as próprias filas, pelo QUEUE_CONSUMER.
+
+// queuedPayment é o PaymentRequest gravado no backend, com os campos que não
+// saem no JSON da API.
+type queuedPayment struct {
+	PaymentRequest
+	RequestedAt time.Time `json:"requestedAt,omitzero"`
+	RequestID   string    `json:"requestId,omitempty"`
+	Panics      int       `json:"panics,omitempty"`
+}
+
+func encodeQueuedPayment(req PaymentRequest) ([]byte, error) {
+	return json.Marshal(queuedPayment{
+		PaymentRequest: req,
+		RequestedAt:    req.RequestedAt,
+		RequestID:      req.RequestID,
+		Panics:         req.Panics,
+	})
+}
+
+func decodeQueuedPayment(data []byte) (PaymentRequest, error) {
+	var q queuedPayment
+	if err := json.Unmarshal(data, &q); err != nil {
+		return PaymentRequest{}, err
+	}
+	req := q.PaymentRequest
+	req.RequestedAt = q.RequestedAt
+	req.RequestID = q.RequestID
+	req.Panics = q.Panics
+	return req, nil
+}
+
+// newQueueBackend cria o backend da fila; nil com QUEUE_BACKEND=memory.
+func (gw *gateway) newQueueBackend(cfg config.QueueConfig) (queue.Backend, error) {
+	consumer := cfg.Consumer
+	if consumer == "" {
+		consumer = gw.instanceName()
+	}
+	if gw.preforkIndex >= 0 {
+		consumer += "/" + strconv.Itoa(gw.preforkIndex)
+	}
+
+	switch cfg.Backend {
+	case "redis":
+		name := gw.keys.Key(cfg.Name)
+		log.Printf("Fila de pagamentos no Redis (%s:%s:*)", name, consumer)
+		return queue.NewRedisStreams(gw.currentRedis, name, consumer), nil
+	case "nats":
+		log.Printf("Fila de pagamentos no NATS JetStream (stream %s, consumer %s)", cfg.Name, consumer)
+		return queue.NewJetStream(cfg.NATSURL, cfg.Name, consumer, cfg.AckWait.Std())
+	case "memory":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("backend desconhecido: %q", cfg.Backend)
+	}
+}
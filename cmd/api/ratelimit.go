@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Token buckets compartilhados entre as instâncias. KEYS[i] tem taxa ARGV[2i]
+// (tokens/s) e capacidade ARGV[2i+1]; ARGV[1] é o instante atual em ms. O token só
+// é consumido se todos os buckets permitirem. Retorna {permitido, espera em ms}.
+// As chaves compartilham a hash tag {rinha}, para o script rodar no Redis Cluster.
+var rateLimitScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local tokens = {}
+local wait = 0
+for i, key in ipairs(KEYS) do
+	local rate = tonumber(ARGV[2 * i])
+	local burst = tonumber(ARGV[2 * i + 1])
+	local state = redis.call("HMGET", key, "tokens", "ts")
+	local available = tonumber(state[1]) or burst
+	local ts = tonumber(state[2]) or now
+	available = math.min(burst, available + math.max(0, now - ts) * rate / 1000)
+	if available < 1 then
+		wait = math.max(wait, math.ceil((1 - available) * 1000 / rate))
+	end
+	tokens[i] = available
+end
+for i, key in ipairs(KEYS) do
+	local rate = tonumber(ARGV[2 * i])
+	local burst = tonumber(ARGV[2 * i + 1])
+	if wait == 0 then
+		tokens[i] = tokens[i] - 1
+	end
+	redis.call("HSET", key, "tokens", tostring(tokens[i]), "ts", now)
+	redis.call("PEXPIRE", key, math.ceil(burst * 1000 / rate) + 1000)
+end
+if wait == 0 then
+	return {1, 0}
+end
+return {0, wait}
+`)
+
+// Acima disso, os buckets locais cheios são descartados
+const maxLocalBuckets = 10000
+
+// ratelimitState é o estado do rate limiting
+type ratelimitState struct {
+	// Usados apenas quando o Redis não está disponível
+	localBuckets    map[string]*tokenBucket
+	localBucketsMux sync.Mutex
+
+	rateLimited atomic.Int64
+}
+
+func (gw *gateway) registerRatelimit() {
+	gw.registerMetric(metric{
+		Name: "rate_limited_total",
+		Help: "Requisições a POST /payments recusadas pelo rate limiting.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.rateLimited.Load())}}
+		},
+	})
+}
+
+// tokenBucket é a versão em memória do bucket do script.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	rate   float64
+	burst  float64
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// full indica que o bucket já teria sido reabastecido por completo.
+func (b *tokenBucket) full(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// bucketLimit é um bucket a consultar: chave e parâmetros.
+type bucketLimit struct {
+	key   string
+	rate  float64
+	burst float64
+}
+
+// takeToken retorna 0 se a requisição pode seguir ou quanto esperar até o próximo token.
+func (gw *gateway) takeToken(ctx context.Context, limits []bucketLimit) time.Duration {
+	if len(limits) == 0 {
+		return 0
+	}
+
+	if client := gw.currentRedis(); client != nil {
+		keys := make([]string, 0, len(limits))
+		args := make([]interface{}, 0, 1+2*len(limits))
+		args = append(args, gw.appClock.Now().UnixMilli())
+		for _, l := range limits {
+			keys = append(keys, l.key)
+			args = append(args, l.rate, l.burst)
+		}
+
+		result, err := rateLimitScript.Run(ctx, client, keys, args...).Int64Slice()
+		if err == nil && len(result) == 2 {
+			return time.Duration(result[1]) * time.Millisecond
+		}
+		log.Printf("Erro no rate limiting compartilhado: %v. Usando limite local.", err)
+	}
+
+	return gw.takeLocalToken(limits)
+}
+
+func (gw *gateway) takeLocalToken(limits []bucketLimit) time.Duration {
+	gw.localBucketsMux.Lock()
+	defer gw.localBucketsMux.Unlock()
+
+	now := gw.appClock.Now()
+	if len(gw.localBuckets) > maxLocalBuckets {
+		// Bucket cheio equivale a bucket inexistente
+		for key, b := range gw.localBuckets {
+			if b.full(now) {
+				delete(gw.localBuckets, key)
+			}
+		}
+	}
+
+	var wait time.Duration
+	buckets := make([]*tokenBucket, len(limits))
+	for i, l := range limits {
+		b := gw.localBuckets[l.key]
+		if b == nil {
+			b = &tokenBucket{tokens: l.burst, last: now, rate: l.rate, burst: l.burst}
+			gw.localBuckets[l.key] = b
+		}
+		// Limites recarregados valem também para os buckets existentes
+		b.rate, b.burst = l.rate, l.burst
+		b.refill(now)
+		if b.tokens < 1 {
+			wait = max(wait, time.Duration((1-b.tokens)/l.rate*float64(time.Second)))
+		}
+		buckets[i] = b
+	}
+
+	if wait > 0 {
+		return wait
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return 0
+}
@@ -0,0 +1,36 @@
+//go:build !minimal
+
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitMiddleware aplica os limites global e por IP do cliente; taxa 0
+// desativa o respectivo bucket. Os limites seguem a configuração em vigor.
+func (gw *gateway) rateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := gw.currentConfig().RateLimit
+		var limits []bucketLimit
+		if cfg.GlobalRate > 0 {
+			limits = append(limits, bucketLimit{gw.keys.Key("ratelimit:{rinha}:global"), cfg.GlobalRate, float64(cfg.GlobalBurst)})
+		}
+		if cfg.ClientRate > 0 {
+			limits = append(limits, bucketLimit{gw.keys.Key("ratelimit:{rinha}:client:" + c.ClientIP()), cfg.ClientRate, float64(cfg.ClientBurst)})
+		}
+
+		wait := gw.takeToken(c.Request.Context(), limits)
+		if wait <= 0 {
+			c.Next()
+			return
+		}
+
+		gw.rateLimited.Add(1)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondError(c, http.StatusTooManyRequests, errCodeRateLimited, msg(msgRateLimited))
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+)
+
+func TestTakeLocalTokenRefillsOnAppClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC))
+	gw := newGateway(fake)
+
+	limits := []bucketLimit{{key: "teste:" + t.Name(), rate: 2, burst: 2}}
+	for i := range 2 {
+		if wait := gw.takeLocalToken(limits); wait != 0 {
+			t.Fatalf("requisição %d dentro do burst esperou %v", i+1, wait)
+		}
+	}
+	if wait := gw.takeLocalToken(limits); wait != 500*time.Millisecond {
+		t.Fatalf("bucket vazio: espera %v, esperado 500ms", wait)
+	}
+
+	fake.Advance(499 * time.Millisecond)
+	if wait := gw.takeLocalToken(limits); wait <= 0 {
+		t.Fatalf("token liberado antes do tempo")
+	}
+	fake.Advance(time.Millisecond)
+	if wait := gw.takeLocalToken(limits); wait != 0 {
+		t.Errorf("depois de 500ms no relógio da aplicação: espera %v, esperado 0", wait)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/storage"
+)
+
+// redisState é o estado da conexão com o Redis
+type redisState struct {
+	// Cliente Redis em uso; nil enquanto a instância está em modo degradado (memória).
+	redisConn atomic.Pointer[redis.UniversalClient]
+}
+
+func (gw *gateway) currentRedis() redis.UniversalClient {
+	if client := gw.redisConn.Load(); client != nil {
+		return *client
+	}
+	return nil
+}
+
+func (gw *gateway) setCurrentRedis(client redis.UniversalClient) {
+	gw.redisConn.Store(&client)
+}
+
+// newRedisClient cria o cliente do REDIS_MODE: um nó, Sentinel ou Cluster. As
+// chaves usadas juntas em scripts e DELs compartilham hash tag, para caírem no
+// mesmo slot do Cluster. Todo comando respeita o prazo do contexto, limitado a
+// REDIS_OP_TIMEOUT.
+func (gw *gateway) newRedisClient(cfg config.RedisConfig) redis.UniversalClient {
+	var client redis.UniversalClient
+	switch cfg.Mode {
+	case config.RedisSentinel:
+		client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.RedisAddrs(),
+			SentinelPassword: cfg.SentinelPassword,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+			PoolSize:         cfg.PoolSize,
+			DialTimeout:      cfg.DialTimeout.Std(),
+			ReadTimeout:      cfg.ReadTimeout.Std(),
+			WriteTimeout:     cfg.WriteTimeout.Std(),
+
+			ContextTimeoutEnabled: true,
+		})
+	case config.RedisCluster:
+		client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        cfg.RedisAddrs(),
+			Password:     cfg.Password,
+			PoolSize:     cfg.PoolSize,
+			DialTimeout:  cfg.DialTimeout.Std(),
+			ReadTimeout:  cfg.ReadTimeout.Std(),
+			WriteTimeout: cfg.WriteTimeout.Std(),
+
+			ContextTimeoutEnabled: true,
+		})
+	default:
+		client = redis.NewClient(&redis.Options{
+			Addr:         cfg.Addr,
+			Password:     cfg.Password,
+			DB:           cfg.DB,
+			PoolSize:     cfg.PoolSize,
+			DialTimeout:  cfg.DialTimeout.Std(),
+			ReadTimeout:  cfg.ReadTimeout.Std(),
+			WriteTimeout: cfg.WriteTimeout.Std(),
+
+			ContextTimeoutEnabled: true,
+		})
+	}
+	// O primeiro hook envolve os demais: o prazo vale também para o atraso do chaos
+	gw.redisCalls = newRedisCallHook(cfg.OpTimeout.Std(), cfg.SlowCallThreshold.Std())
+	client.AddHook(gw.redisCalls)
+	if gw.redisChaos != nil {
+		client.AddHook(gw.redisChaos)
+	}
+	return client
+}
+
+func pingRedis(client redis.UniversalClient, cfg config.RedisConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout.Std())
+	defer cancel()
+
+	return client.Ping(ctx).Err()
+}
+
+// startRedisSupervisor mantém a conexão com o Redis: fora do ar, tenta reconectar
+// com backoff exponencial e, ao conseguir, reenvia o que foi acumulado em memória;
+// conectado, faz pings periódicos e volta ao modo degradado se a conexão cair.
+func (gw *gateway) startRedisSupervisor(client redis.UniversalClient, cfg config.RedisConfig) {
+	go func() {
+		backoff := cfg.ReconnectMinBackoff.Std()
+
+		for {
+			if gw.currentRedis() != nil {
+				time.Sleep(cfg.PingInterval.Std())
+				if err := pingRedis(client, cfg); err != nil {
+					log.Printf("Conexão com o Redis perdida: %v. Entrando em modo degradado.", err)
+					gw.demoteRedis()
+				}
+				continue
+			}
+
+			time.Sleep(backoff)
+			err := pingRedis(client, cfg)
+			if err == nil {
+				err = gw.promoteRedis(client)
+			}
+			if err != nil {
+				backoff = min(2*backoff, cfg.ReconnectMaxBackoff.Std())
+				continue
+			}
+
+			backoff = cfg.ReconnectMinBackoff.Std()
+			log.Printf("Redis reconectado: dados acumulados em memória reenviados")
+		}
+	}()
+}
+
+// promoteRedis passa a usar o Redis. O storage é reenviado antes de o cliente ser
+// publicado; DLQ e outbox depois, para que nada gravado no meio fique só em memória.
+func (gw *gateway) promoteRedis(client redis.UniversalClient) error {
+	ctx := context.Background()
+
+	if ds, ok := gw.store.(*storage.Degradable); ok {
+		if err := ds.Promote(ctx, client); err != nil {
+			return fmt.Errorf("erro ao reenviar contadores: %w", err)
+		}
+	}
+
+	gw.setCurrentRedis(client)
+
+	if err := gw.replayMemoryDLQ(ctx, client); err != nil {
+		gw.demoteRedis()
+		return fmt.Errorf("erro ao reenviar DLQ: %w", err)
+	}
+	if err := gw.replayMemoryOutbox(ctx, client); err != nil {
+		gw.demoteRedis()
+		return fmt.Errorf("erro ao reenviar outbox: %w", err)
+	}
+	return nil
+}
+
+func (gw *gateway) demoteRedis() {
+	gw.redisConn.Store(nil)
+	if ds, ok := gw.store.(*storage.Degradable); ok {
+		ds.Demote()
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Nome usado nas métricas para os pipelines, que misturam comandos
+const redisPipelineName = "pipeline"
+
+// redisCallHook limita cada comando e pipeline a REDIS_OP_TIMEOUT, para que um
+// Redis travado não prenda os workers, e conta as chamadas lentas e estouradas.
+type redisCallHook struct {
+	timeout time.Duration
+	slow    time.Duration
+
+	mu       sync.Mutex
+	slowBy   map[string]int64
+	timedOut map[string]int64
+}
+
+// redisCallsState é o estado da medição das chamadas ao Redis
+type redisCallsState struct {
+	// Em uso pelo cliente Redis; nil até newRedisClient
+	redisCalls *redisCallHook
+}
+
+func (gw *gateway) registerRedisCalls() {
+	gw.registerMetric(metric{
+		Name: "redis_slow_calls_total",
+		Help: "Chamadas ao Redis que levaram mais que REDIS_SLOW_CALL_THRESHOLD, por comando.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return gw.collectRedisCalls(func(h *redisCallHook) map[string]int64 { return h.slowBy })
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "redis_call_timeouts_total",
+		Help: "Chamadas ao Redis interrompidas pelo prazo (REDIS_OP_TIMEOUT ou do chamador), por comando.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return gw.collectRedisCalls(func(h *redisCallHook) map[string]int64 { return h.timedOut })
+		},
+	})
+}
+
+func newRedisCallHook(timeout, slow time.Duration) *redisCallHook {
+	return &redisCallHook{
+		timeout:  timeout,
+		slow:     slow,
+		slowBy:   make(map[string]int64),
+		timedOut: make(map[string]int64),
+	}
+}
+
+func (h *redisCallHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *redisCallHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		// Uma leitura bloqueante espera de propósito (a fila no Redis): fica fora
+		// do prazo e das métricas
+		if isBlockingRead(cmd) {
+			return next(ctx, cmd)
+		}
+		ctx, cancel := context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.observe(cmd.Name(), time.Since(start), err)
+		return err
+	}
+}
+
+func (h *redisCallHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, cancel := context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.observe(redisPipelineName, time.Since(start), err)
+		return err
+	}
+}
+
+func (h *redisCallHook) observe(command string, elapsed time.Duration, err error) {
+	slow := h.slow > 0 && elapsed >= h.slow
+	timedOut := isTimeout(err)
+	if !slow && !timedOut {
+		return
+	}
+
+	// O go-redis já entrega o nome em minúsculas; EVALSHA e EVAL contam juntos
+	command = strings.TrimSuffix(command, "sha")
+	h.mu.Lock()
+	if slow {
+		h.slowBy[command]++
+	}
+	if timedOut {
+		h.timedOut[command]++
+	}
+	h.mu.Unlock()
+}
+
+// isBlockingRead reconhece XREAD e XREADGROUP com BLOCK.
+func isBlockingRead(cmd redis.Cmder) bool {
+	if name := cmd.Name(); name != "xread" && name != "xreadgroup" {
+		return false
+	}
+	for _, arg := range cmd.Args() {
+		if s, ok := arg.(string); ok && strings.EqualFold(s, "block") {
+			return true
+		}
+	}
+	return false
+}
+
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (gw *gateway) collectRedisCalls(counts func(*redisCallHook) map[string]int64) []metricSample {
+	h := gw.redisCalls
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	byCommand := counts(h)
+	commands := make([]string, 0, len(byCommand))
+	for command := range byCommand {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+
+	samples := make([]metricSample, 0, len(commands))
+	for _, command := range commands {
+		samples = append(samples, metricSample{
+			Labels: map[string]string{"command": command},
+			Value:  float64(byCommand[command]),
+		})
+	}
+	return samples
+}
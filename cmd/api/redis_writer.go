@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Writer do Redis: os comandos do caminho quente (outbox) entram em um canal e
+// uma goroutine dedicada os junta em pipelines, limitados por tamanho e por
+// tempo de espera. Com centenas de workers, cada pipeline leva os comandos de
+// muitos pagamentos em um único RTT.
+
+var errRedisUnavailable = errors.New("Redis indisponível")
+
+// redisWrite é um comando aguardando o writer. queue o acrescenta ao pipeline e
+// retorna o Cmder com o resultado, lido por quem enviou depois de done.
+type redisWrite struct {
+	queue func(redis.Cmdable) redis.Cmder
+	cmd   redis.Cmder
+	err   error
+	done  chan struct{}
+}
+
+// redisWriterState é o estado do writer; redisWriterQueue nil com REDIS_WRITER=false
+type redisWriterState struct {
+	redisWriterQueue chan *redisWrite
+	redisWriterCfg   config.RedisWriterConfig
+
+	redisWriterPipelines    atomic.Int64
+	redisWriterCommands     atomic.Int64
+	redisWriterBackpressure atomic.Int64
+	redisWriterErrors       atomic.Int64
+}
+
+func (gw *gateway) registerRedisWriter() {
+	gw.registerMetric(metric{
+		Name: "redis_writer_pipelines_total",
+		Help: "Pipelines enviados pelo writer do Redis.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.redisWriterPipelines.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "redis_writer_commands_total",
+		Help: "Comandos enviados pelo writer do Redis; dividido pelos pipelines, o tamanho médio do lote.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.redisWriterCommands.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "redis_writer_queue_depth",
+		Help: "Comandos aguardando o writer do Redis.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(len(gw.redisWriterQueue))}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "redis_writer_backpressure_total",
+		Help: "Comandos que encontraram a fila do writer cheia e esperaram vaga.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.redisWriterBackpressure.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "redis_writer_errors_total",
+		Help: "Pipelines do writer que falharam por inteiro (conexão, prazo ou Redis indisponível).",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.redisWriterErrors.Load())}}
+		},
+	})
+}
+
+func (gw *gateway) startRedisWriter(cfg config.RedisWriterConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	gw.redisWriterCfg = cfg
+	gw.redisWriterQueue = make(chan *redisWrite, cfg.QueueSize)
+	go gw.runRedisWriter()
+	log.Printf("Writer do Redis: pipelines de até %d comandos, espera de até %v", cfg.BatchSize, cfg.Linger.Std())
+}
+
+// redisDo executa o comando montado por queue pelo writer, ou direto no client
+// sem writer. Com a fila cheia, espera vaga até o prazo de ctx.
+func (gw *gateway) redisDo(ctx context.Context, client redis.UniversalClient, queue func(redis.Cmdable) redis.Cmder) (redis.Cmder, error) {
+	if gw.redisWriterQueue == nil {
+		cmd := queue(client)
+		return cmd, cmd.Err()
+	}
+
+	w := &redisWrite{queue: queue, done: make(chan struct{})}
+	select {
+	case gw.redisWriterQueue <- w:
+	default:
+		gw.redisWriterBackpressure.Add(1)
+		select {
+		case gw.redisWriterQueue <- w:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	select {
+	case <-w.done:
+		return w.cmd, w.err
+	case <-ctx.Done():
+		// O comando ainda segue no pipeline; só quem esperava desiste
+		return nil, ctx.Err()
+	}
+}
+
+func (gw *gateway) runRedisWriter() {
+	batch := make([]*redisWrite, 0, gw.redisWriterCfg.BatchSize)
+	linger := gw.redisWriterCfg.Linger.Std()
+	timer := time.NewTimer(0)
+	<-timer.C
+
+	for first := range gw.redisWriterQueue {
+		batch = append(batch[:0], first)
+
+		// O que já chegou entra sem esperar; depois, até Linger por mais comandos
+		drained := false
+		for !drained && len(batch) < cap(batch) {
+			select {
+			case w := <-gw.redisWriterQueue:
+				batch = append(batch, w)
+			default:
+				drained = true
+			}
+		}
+		if linger > 0 && len(batch) < cap(batch) {
+			timer.Reset(linger)
+		wait:
+			for len(batch) < cap(batch) {
+				select {
+				case w := <-gw.redisWriterQueue:
+					batch = append(batch, w)
+				case <-timer.C:
+					break wait
+				}
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+
+		gw.execRedisBatch(batch)
+	}
+}
+
+// execRedisBatch envia o lote em um pipeline e libera quem espera cada comando.
+func (gw *gateway) execRedisBatch(batch []*redisWrite) {
+	defer func() {
+		for _, w := range batch {
+			close(w.done)
+		}
+	}()
+
+	client := gw.currentRedis()
+	if client == nil {
+		gw.redisWriterErrors.Add(1)
+		for _, w := range batch {
+			w.err = errRedisUnavailable
+		}
+		return
+	}
+
+	pipe := client.Pipeline()
+	for _, w := range batch {
+		w.cmd = w.queue(pipe)
+	}
+	_, err := pipe.Exec(context.Background())
+	gw.redisWriterPipelines.Add(1)
+	gw.redisWriterCommands.Add(int64(len(batch)))
+
+	// Exec retorna o primeiro erro entre os comandos; cada um tem o seu
+	if err != nil && !errors.Is(err, redis.Nil) && allFailed(batch) {
+		gw.redisWriterErrors.Add(1)
+	}
+	for _, w := range batch {
+		w.err = w.cmd.Err()
+	}
+}
+
+func allFailed(batch []*redisWrite) bool {
+	for _, w := range batch {
+		if w.cmd.Err() == nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/retry"
+	"rinha-backend-2025/internal/selector"
+)
+
+// Recarga da configuração sem reiniciar, pelo SIGHUP (relê CONFIG_FILE e o
+// ambiente) ou por PUT /admin/config. Só config.Reloadable muda: política de
+// retry, selector, limites do rate limiting e nível de log. O restante continua
+// com o valor da inicialização.
+
+// Tamanho máximo do corpo de PUT /admin/config
+const maxConfigBodyBytes = 64 << 10
+
+// runtimeConfig é a configuração em vigor com os objetos montados a partir dela,
+// trocados juntos a cada recarga.
+type runtimeConfig struct {
+	config.Config
+	retry    retry.Policy
+	selector selector.Selector
+	// AMOUNT_ROUNDING=half-up: meio centavo exato arredonda para cima
+	amountHalfUp bool
+}
+
+// reloadState é o estado da configuração em vigor
+type reloadState struct {
+	activeConfig atomic.Pointer[runtimeConfig]
+	// Serializa as recargas: duas ao mesmo tempo perderiam uma das mudanças
+	reloadMu sync.Mutex
+	// LOG_LEVEL=warn: sem logs por requisição e sem access log
+	requestLogsOff atomic.Bool
+
+	configReloads       atomic.Int64
+	configReloadsFailed atomic.Int64
+}
+
+func (gw *gateway) registerReload() {
+	gw.registerMetric(metric{
+		Name: "config_reloads_total",
+		Help: "Recargas da configuração por SIGHUP ou PUT /admin/config, por resultado.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{
+				{Labels: map[string]string{"result": "ok"}, Value: float64(gw.configReloads.Load())},
+				{Labels: map[string]string{"result": "error"}, Value: float64(gw.configReloadsFailed.Load())},
+			}
+		},
+	})
+}
+
+// currentConfig retorna a configuração em vigor. Quem precisa de valores
+// coerentes entre si guarda o retorno em vez de chamar de novo.
+func (gw *gateway) currentConfig() *runtimeConfig {
+	return gw.activeConfig.Load()
+}
+
+// applyConfig monta a política de retry e o selector de cfg e troca a
+// configuração em vigor de uma vez.
+func (gw *gateway) applyConfig(cfg config.Config) error {
+	policy, err := newRetryPolicy(cfg.Retry)
+	if err != nil {
+		return err
+	}
+	gw.activeConfig.Store(&runtimeConfig{Config: cfg, retry: policy, selector: selector.New(cfg.Selector), amountHalfUp: cfg.Counters.AmountRounding == config.RoundHalfUp})
+	gw.requestLogsOff.Store(cfg.LogLevel == config.LogWarn)
+	return nil
+}
+
+// reloadConfig aplica r sobre a configuração em vigor; inválida, nada muda.
+func (gw *gateway) reloadConfig(r config.Reloadable, source string) error {
+	gw.reloadMu.Lock()
+	defer gw.reloadMu.Unlock()
+
+	next, err := gw.currentConfig().WithReloadable(r)
+	if err == nil {
+		err = gw.applyConfig(next)
+	}
+	if err != nil {
+		gw.configReloadsFailed.Add(1)
+		log.Printf("Recarga da configuração (%s) recusada: %v", source, err)
+		return err
+	}
+	gw.configReloads.Add(1)
+	log.Printf("Configuração recarregada (%s): retry %d tentativas, selector %s, rate limit %.0f/%.0f rps, log %s",
+		source, next.Retry.MaxAttempts, next.Selector.Strategy, next.RateLimit.GlobalRate, next.RateLimit.ClientRate, next.LogLevel)
+	return nil
+}
+
+// startConfigReload recarrega a configuração a cada SIGHUP.
+func (gw *gateway) startConfigReload() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			cfg, err := config.Load()
+			if err != nil {
+				gw.configReloadsFailed.Add(1)
+				log.Printf("Recarga da configuração (SIGHUP) recusada: %v", err)
+				continue
+			}
+			gw.reloadConfig(cfg.Reloadable(), "SIGHUP")
+		}
+	}()
+}
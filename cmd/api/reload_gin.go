@@ -0,0 +1,39 @@
+//go:build !minimal
+
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	json "github.com/goccy/go-json"
+)
+
+// handleAdminConfig retorna a parte recarregável da configuração em vigor.
+func (gw *gateway) handleAdminConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gw.currentConfig().Reloadable())
+}
+
+// handleAdminConfigUpdate aplica um JSON parcial sobre a parte recarregável:
+// campos omitidos mantêm o valor em vigor, e campos fora dela são recusados.
+func (gw *gateway) handleAdminConfigUpdate(c *gin.Context) {
+	r := gw.currentConfig().Reloadable()
+	dec := json.NewDecoder(http.MaxBytesReader(c.Writer, c.Request.Body, maxConfigBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(c, http.StatusRequestEntityTooLarge, errCodeBodyTooLarge, msg(msgBodyTooLarge, tooLarge.Limit))
+			return
+		}
+		respondError(c, http.StatusBadRequest, errCodeInvalidBody, msg(msgInvalidConfig, err))
+		return
+	}
+
+	if err := gw.reloadConfig(r, "PUT /admin/config"); err != nil {
+		respondError(c, http.StatusUnprocessableEntity, errCodeInvalidPayload, msg(msgConfigRejected, err))
+		return
+	}
+	c.JSON(http.StatusOK, gw.currentConfig().Reloadable())
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"mime"
+	"strconv"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/proto"
+
+	"rinha-backend-2025/internal/storage"
+	"rinha-backend-2025/paymentspb"
+)
+
+// Codificações aceitas no corpo de POST /payments, escolhidas pelo Content-Type.
+// JSON continua o padrão; MessagePack e protobuf (SubmitPaymentRequest de
+// paymentspb/payments.proto) poupam o parse a produtores internos de alto volume.
+// Todas passam pelas mesmas regras de validação, e a resposta segue em JSON.
+
+const (
+	codecJSON = iota
+	codecMsgpack
+	codecProtobuf
+)
+
+// paymentDecoder decodifica e valida o corpo: erro comum vira 400 e
+// *ValidationError vira 422, como no JSON.
+type paymentDecoder struct {
+	codec  int
+	decode func(gw *gateway, body []byte, maxAmount float64) (PaymentRequest, error)
+}
+
+var (
+	paymentDecoders = map[string]paymentDecoder{
+		"application/json":       {codecJSON, (*gateway).decodeJSONPayment},
+		"application/msgpack":    {codecMsgpack, (*gateway).decodeMsgpackPayment},
+		"application/x-msgpack":  {codecMsgpack, (*gateway).decodeMsgpackPayment},
+		"application/x-protobuf": {codecProtobuf, (*gateway).decodeProtobufPayment},
+		"application/protobuf":   {codecProtobuf, (*gateway).decodeProtobufPayment},
+	}
+
+	codecNames     = [...]string{codecJSON: "json", codecMsgpack: "msgpack", codecProtobuf: "protobuf"}
+	errNotMsgpack  = newMessageError(msgMsgpackNotMap)
+	errMsgpackTail = newMessageError(msgMsgpackTrailingData)
+	errMsgpackEOF  = newMessageError(msgMsgpackTruncated)
+)
+
+// requestCodecsState é o estado das codificações aceitas
+type requestCodecsState struct {
+	codecRequests [len(codecNames)]atomic.Int64
+}
+
+func (gw *gateway) registerRequestCodecs() {
+	gw.registerMetric(metric{
+		Name: "payment_requests_by_encoding_total",
+		Help: "Corpos de POST /payments decodificados, por codificação do Content-Type.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, len(codecNames))
+			for i, name := range codecNames {
+				samples[i] = metricSample{
+					Labels: map[string]string{"encoding": name},
+					Value:  float64(gw.codecRequests[i].Load()),
+				}
+			}
+			return samples
+		},
+	})
+}
+
+// lookupPaymentDecoder escolhe o decoder pelo Content-Type, ignorando parâmetros
+// como charset.
+func lookupPaymentDecoder(contentType string) (paymentDecoder, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return paymentDecoder{}, false
+	}
+	d, ok := paymentDecoders[mediaType]
+	return d, ok
+}
+
+// decodePayment decodifica o corpo com d, contando a codificação.
+func (gw *gateway) decodePayment(d paymentDecoder, body []byte, maxAmount float64) (PaymentRequest, error) {
+	gw.codecRequests[d.codec].Add(1)
+	return d.decode(gw, body, maxAmount)
+}
+
+func (gw *gateway) decodeJSONPayment(body []byte, maxAmount float64) (PaymentRequest, error) {
+	return gw.decodePaymentRequest(bytes.NewReader(body), maxAmount)
+}
+
+func (gw *gateway) decodeProtobufPayment(body []byte, maxAmount float64) (PaymentRequest, error) {
+	var in paymentspb.SubmitPaymentRequest
+	if err := proto.Unmarshal(body, &in); err != nil {
+		return PaymentRequest{}, newMessageError(msgInvalidProtobuf, err)
+	}
+	req := newProtoPaymentRequest(&in)
+	return req, gw.validatePaymentRequest(req, maxAmount)
+}
+
+// newProtoPaymentRequest converte a mensagem do gRPC e do corpo protobuf; a moeda
+// vazia vale BRL.
+func newProtoPaymentRequest(in *paymentspb.SubmitPaymentRequest) PaymentRequest {
+	req := PaymentRequest{
+		CorrelationID: in.GetCorrelationId(),
+		Amount:        in.GetAmount(),
+		CallbackURL:   in.GetCallbackUrl(),
+		Currency:      in.GetCurrency(),
+	}
+	if req.Currency == "" {
+		req.Currency = storage.DefaultCurrency
+	}
+	return req
+}
+
+// decodeMsgpackPayment lê um mapa com as mesmas chaves do JSON. Como no JSON,
+// chaves desconhecidas e tipos errados são problemas de validação (422), e o
+// amount deve ser numérico: float32 é lido pelo valor decimal mais curto, para
+// 19.9 não virar 19.899999618530273 e falhar nas casas decimais.
+func (gw *gateway) decodeMsgpackPayment(body []byte, maxAmount float64) (PaymentRequest, error) {
+	r := msgpackReader{data: body}
+	n, ok, err := r.mapHeader()
+	if err != nil {
+		return PaymentRequest{}, err
+	}
+	if !ok {
+		return PaymentRequest{}, errNotMsgpack
+	}
+
+	req := PaymentRequest{Currency: storage.DefaultCurrency}
+	var fields []FieldError
+	hasAmount := false
+	for range n {
+		key, err := r.value()
+		if err != nil {
+			return PaymentRequest{}, err
+		}
+		if key.kind != msgpackString {
+			return PaymentRequest{}, newMessageError(msgMsgpackKeyNotString)
+		}
+		v, err := r.value()
+		if err != nil {
+			return PaymentRequest{}, err
+		}
+
+		switch key.str {
+		case "correlationId", "callbackUrl", "currency":
+			if v.kind == msgpackNil {
+				continue
+			}
+			if v.kind != msgpackString {
+				fields = append(fields, issue(fieldNotString).at(key.str))
+				continue
+			}
+			switch key.str {
+			case "correlationId":
+				req.CorrelationID = v.str
+			case "callbackUrl":
+				req.CallbackURL = v.str
+			default:
+				req.Currency = v.str
+			}
+		case "amount":
+			switch v.kind {
+			case msgpackNumber:
+				req.Amount, hasAmount = v.num, true
+			case msgpackNil:
+			case msgpackString:
+				fields = append(fields, issue(fieldNumberAsString).at("amount"))
+				hasAmount = true
+			default:
+				fields = append(fields, issue(fieldInvalidNumber).at("amount"))
+				hasAmount = true
+			}
+		default:
+			return PaymentRequest{}, &ValidationError{Fields: []FieldError{issue(fieldUnknown).at(key.str)}}
+		}
+	}
+	if r.pos != len(r.data) {
+		return PaymentRequest{}, errMsgpackTail
+	}
+
+	if !hasAmount {
+		fields = append(fields, issue(fieldRequired).at("amount"))
+	}
+	if len(fields) > 0 {
+		return req, &ValidationError{Fields: fields}
+	}
+	return req, gw.validatePaymentRequest(req, maxAmount)
+}
+
+// Tipos de valor que o decoder distingue; o resto vira msgpackOther.
+const (
+	msgpackNil = iota
+	msgpackString
+	msgpackNumber
+	msgpackOther
+)
+
+type msgpackValue struct {
+	kind int
+	str  string
+	num  float64
+}
+
+// msgpackReader lê só o necessário do formato: valores escalares e, para
+// rejeitá-los, arrays, mapas, binários e extensões, sempre dentro do corpo.
+type msgpackReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *msgpackReader) take(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errMsgpackEOF
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// uint lê um inteiro sem sinal big-endian de size bytes.
+func (r *msgpackReader) uint(size int) (uint64, error) {
+	b, err := r.take(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// mapHeader lê o tamanho do mapa; false quando o próximo valor não é um mapa.
+func (r *msgpackReader) mapHeader() (uint64, bool, error) {
+	if r.pos >= len(r.data) {
+		return 0, false, errMsgpackEOF
+	}
+	b := r.data[r.pos]
+	switch {
+	case b&0xf0 == 0x80:
+		r.pos++
+		return uint64(b & 0x0f), true, nil
+	case b == 0xde:
+		r.pos++
+		n, err := r.uint(2)
+		return n, true, err
+	case b == 0xdf:
+		r.pos++
+		n, err := r.uint(4)
+		return n, true, err
+	}
+	return 0, false, nil
+}
+
+func (r *msgpackReader) value() (msgpackValue, error) {
+	b, err := r.take(1)
+	if err != nil {
+		return msgpackValue{}, err
+	}
+	t := b[0]
+	switch {
+	case t <= 0x7f:
+		return numberValue(float64(t)), nil
+	case t >= 0xe0:
+		return numberValue(float64(int8(t))), nil
+	case t&0xe0 == 0xa0:
+		return r.str(uint64(t & 0x1f))
+	case t&0xf0 == 0x80:
+		return msgpackValue{kind: msgpackOther}, r.skip(2 * uint64(t&0x0f))
+	case t&0xf0 == 0x90:
+		return msgpackValue{kind: msgpackOther}, r.skip(uint64(t & 0x0f))
+	}
+
+	switch t {
+	case 0xc0:
+		return msgpackValue{kind: msgpackNil}, nil
+	case 0xc2, 0xc3:
+		return msgpackValue{kind: msgpackOther}, nil
+	case 0xca:
+		bits, err := r.uint(4)
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		f := math.Float32frombits(uint32(bits))
+		num, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'f', -1, 32), 64)
+		return numberValue(num), nil
+	case 0xcb:
+		bits, err := r.uint(8)
+		return numberValue(math.Float64frombits(bits)), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := r.uint(1 << (t - 0xcc))
+		return numberValue(float64(n)), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (t - 0xd0)
+		n, err := r.uint(size)
+		// Estender o sinal do inteiro de size bytes
+		shift := 64 - 8*size
+		return numberValue(float64(int64(n<<shift) >> shift)), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.uint(1 << (t - 0xd9))
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		return r.str(n)
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.uint(1 << (t - 0xc4))
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		return msgpackValue{kind: msgpackOther}, r.skipBytes(n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := r.uint(1 << (t - 0xc7))
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		return msgpackValue{kind: msgpackOther}, r.skipBytes(n + 1)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return msgpackValue{kind: msgpackOther}, r.skipBytes(1 + 1<<(t-0xd4))
+	case 0xdc, 0xdd:
+		n, err := r.uint(2 << (t - 0xdc))
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		return msgpackValue{kind: msgpackOther}, r.skip(n)
+	case 0xde, 0xdf:
+		n, err := r.uint(2 << (t - 0xde))
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		return msgpackValue{kind: msgpackOther}, r.skip(2 * n)
+	}
+	return msgpackValue{}, newMessageError(msgMsgpackUnknownType, t)
+}
+
+func numberValue(num float64) msgpackValue {
+	return msgpackValue{kind: msgpackNumber, num: num}
+}
+
+func (r *msgpackReader) str(n uint64) (msgpackValue, error) {
+	b, err := r.take(n)
+	if err != nil {
+		return msgpackValue{}, err
+	}
+	return msgpackValue{kind: msgpackString, str: string(b)}, nil
+}
+
+func (r *msgpackReader) skipBytes(n uint64) error {
+	_, err := r.take(n)
+	return err
+}
+
+// skip descarta n valores, como os itens de um array ou as chaves e valores de
+// um mapa aninhado.
+func (r *msgpackReader) skip(n uint64) error {
+	for range n {
+		if _, err := r.value(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	json "github.com/goccy/go-json"
+)
+
+// Identificação das requisições: cada requisição recebe um X-Request-ID (ou
+// mantém o do cliente), devolvido no header da resposta, no corpo dos erros e
+// nas linhas de log da requisição e do processamento do pagamento.
+
+const requestIDHeader = "X-Request-ID"
+
+// IDs recebidos maiores que isso são trocados por um gerado aqui
+const maxRequestIDLen = 128
+
+// Prefixo aleatório por processo seguido de um contador: único entre as
+// instâncias sem sortear bytes a cada requisição.
+var (
+	requestIDPrefix  = newRequestIDPrefix()
+	requestIDCounter atomic.Uint64
+)
+
+type requestIDContextKey struct{}
+
+func newRequestIDPrefix() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+func newRequestID() string {
+	return requestIDPrefix + "-" + strconv.FormatUint(requestIDCounter.Add(1), 36)
+}
+
+// validRequestID aceita só ASCII visível, para o ID não quebrar logs nem headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// incomingRequestID retorna o ID enviado pelo cliente ou um novo.
+func incomingRequestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); validRequestID(id) {
+		return id
+	}
+	return newRequestID()
+}
+
+func withRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// requestIDFrom retorna o ID da requisição que originou ctx, ou vazio.
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// logf é o log.Printf das requisições: prefixa a linha com o ID quando ctx tem um.
+// Omitido com LOG_LEVEL=warn e na carga extrema (ver load_shed.go).
+func (gw *gateway) logf(ctx context.Context, format string, args ...any) {
+	if gw.requestLogsOff.Load() || gw.loadShed(shedLogs) {
+		return
+	}
+	if id := requestIDFrom(ctx); id != "" {
+		log.Printf("[%s] "+format, append([]any{id}, args...)...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// Códigos de erro das respostas, estáveis para os clientes
+const (
+	errCodeUnsupportedMediaType = "unsupported_media_type"
+	errCodeBodyTooLarge         = "body_too_large"
+	errCodeInvalidBody          = "invalid_body"
+	errCodeInvalidPayload       = "invalid_payload"
+	errCodeInvalidParameter     = "invalid_parameter"
+	errCodeBatchRejected        = "batch_rejected"
+	errCodeDuplicate            = "duplicate_correlation_id"
+	errCodeAmountConflict       = "amount_conflict"
+	errCodeCallbackNotAllowed   = "callback_not_allowed"
+	errCodeQueueFull            = "queue_full"
+	errCodeAlreadyDispatched    = "already_dispatched"
+	errCodeProcessorsFailed     = "processors_failed"
+	errCodeRateLimited          = "rate_limited"
+	errCodeUnauthorized         = "unauthorized"
+	errCodeForbidden            = "forbidden"
+	errCodeNotFound             = "not_found"
+	errCodeNotImplemented       = "not_implemented"
+	errCodeUnavailable          = "unavailable"
+	errCodeInternal             = "internal_error"
+)
+
+// ErrorResponse é o corpo de todas as respostas de erro.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId"`
+	// Detalhes do erro, como os campos inválidos de um pagamento
+	Details any `json:"details,omitempty"`
+}
+
+// writeHTTPError é o respondError dos handlers fora do Gin, como os da porta de
+// diagnóstico e os do build minimal.
+func writeHTTPError(w http.ResponseWriter, r *http.Request, status int, code string, message localizedMessage) {
+	writeHTTPErrorDetails(w, r, status, code, message, nil)
+}
+
+func writeHTTPErrorDetails(w http.ResponseWriter, r *http.Request, status int, code string, message localizedMessage, details any) {
+	id := incomingRequestID(r)
+	locale := requestLocale(r)
+	text := message.text(locale)
+	body, err := json.Marshal(ErrorResponse{Error: ErrorBody{
+		Code:      code,
+		Message:   text,
+		RequestID: id,
+		Details:   localizeDetails(locale, details),
+	}})
+	if err != nil {
+		http.Error(w, text, status)
+		return
+	}
+	w.Header().Set("Content-Type", jsonContentType)
+	w.Header().Set(requestIDHeader, id)
+	setContentLanguage(w.Header(), locale)
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,71 @@
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Chave do ID no gin.Context, lida também pelo formatter do log de acesso
+const requestIDKey = "requestId"
+
+// requestIDMiddleware deve ser o primeiro do router: os demais middlewares já
+// respondem erros com o ID.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := incomingRequestID(c.Request)
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Request = c.Request.WithContext(withRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// accessLogFormatter mantém o formato do log padrão do Gin, com o ID da requisição.
+func (gw *gateway) accessLogFormatter(p gin.LogFormatterParams) string {
+	if gw.requestLogsOff.Load() || gw.loadShed(shedLogs) {
+		return ""
+	}
+	id, _ := p.Keys[requestIDKey].(string)
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v | %s\n%s",
+		p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		p.StatusCode,
+		p.Latency,
+		p.ClientIP,
+		p.Method,
+		p.Path,
+		id,
+		p.ErrorMessage,
+	)
+}
+
+// respondError responde o erro no envelope padrão e interrompe os próximos handlers.
+func respondError(c *gin.Context, status int, code string, message localizedMessage) {
+	respondErrorDetails(c, status, code, message, nil)
+}
+
+// A mensagem e os detalhes seguem o Accept-Language (ver i18n.go).
+func respondErrorDetails(c *gin.Context, status int, code string, message localizedMessage, details any) {
+	locale := requestLocale(c.Request)
+	setContentLanguage(c.Writer.Header(), locale)
+	c.AbortWithStatusJSON(status, ErrorResponse{Error: ErrorBody{
+		Code:      code,
+		Message:   message.text(locale),
+		RequestID: c.GetString(requestIDKey),
+		Details:   localizeDetails(locale, details),
+	}})
+}
+
+// handleNoRoute troca o 404 em texto do Gin pelo envelope.
+func handleNoRoute(c *gin.Context) {
+	respondError(c, http.StatusNotFound, errCodeNotFound, msg(msgRouteNotFound))
+}
+
+// handlePanic responde 500 no envelope; o stack trace vai para o log do Recovery.
+func (gw *gateway) handlePanic(c *gin.Context, recovered any) {
+	gw.logf(c.Request.Context(), "Panic em %s %s: %v", c.Request.Method, c.Request.URL.Path, recovered)
+	respondError(c, http.StatusInternalServerError, errCodeInternal, msg(msgInternal))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// requestedAt das retentativas (REQUESTED_AT_RETRY): um pagamento repetido
+// minutos depois com o requestedAt original pode ser recusado ou cair fora da
+// janela do resumo do processor. Com "refresh" ou "max-skew", cada tentativa
+// pode levar outro instante; o que o processor aceitou é o que vai para o
+// storage, e cada valor enviado fica na auditoria da tentativa.
+
+// requestedAtState é o estado do requestedAt
+type requestedAtState struct {
+	// Tentativas enviadas com um requestedAt diferente do original
+	requestedAtRefreshed atomic.Int64
+}
+
+func (gw *gateway) registerRequestedAt() {
+	gw.registerMetric(metric{
+		Name: "requested_at_refreshed_total",
+		Help: "Tentativas enviadas com requestedAt renovado (REQUESTED_AT_RETRY refresh ou max-skew).",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.requestedAtRefreshed.Load())}}
+		},
+	})
+}
+
+// attemptRequestedAt retorna o requestedAt de uma tentativa do pagamento
+// recebido com original.
+func (gw *gateway) attemptRequestedAt(original time.Time) time.Time {
+	cfg := gw.currentConfig()
+	switch cfg.RequestedAtRetry {
+	case config.RequestedAtRefresh:
+	case config.RequestedAtMaxSkew:
+		if gw.appClock.Since(original) <= cfg.RequestedAtMaxSkew.Std() {
+			return original
+		}
+	default:
+		return original
+	}
+	now := gw.newRequestedAt()
+	if !now.Equal(original) {
+		gw.requestedAtRefreshed.Add(1)
+	}
+	return now
+}
+
+// sentRequestedAt guarda o último requestedAt enviado a cada processor no envio
+// do pagamento.
+type sentRequestedAt struct {
+	mu          sync.Mutex
+	byProcessor map[string]time.Time
+}
+
+type sentRequestedAtKey struct{}
+
+// withSentRequestedAt prepara ctx para registrar o requestedAt de cada tentativa;
+// com "preserve", todas enviam o original e não há o que registrar.
+func (gw *gateway) withSentRequestedAt(ctx context.Context) context.Context {
+	if gw.currentConfig().RequestedAtRetry == config.RequestedAtPreserve {
+		return ctx
+	}
+	return context.WithValue(ctx, sentRequestedAtKey{}, &sentRequestedAt{byProcessor: make(map[string]time.Time)})
+}
+
+// markRequestedAtSent registra o requestedAt da tentativa no processor.
+func markRequestedAtSent(ctx context.Context, processor string, requestedAt time.Time) {
+	s, _ := ctx.Value(sentRequestedAtKey{}).(*sentRequestedAt)
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.byProcessor[processor] = requestedAt
+	s.mu.Unlock()
+}
+
+// lastRequestedAtSent retorna o requestedAt da última tentativa no processor, ou
+// original se nada foi registrado.
+func lastRequestedAtSent(ctx context.Context, processor string, original time.Time) time.Time {
+	s, _ := ctx.Value(sentRequestedAtKey{}).(*sentRequestedAt)
+	if s == nil {
+		return original
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if requestedAt, ok := s.byProcessor[processor]; ok {
+		return requestedAt
+	}
+	return original
+}
@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+func listenReusePort(lc net.ListenConfig, addr string) (net.Listener, error) {
+	return nil, errors.New("SO_REUSEPORT não suportado nesta plataforma")
+}
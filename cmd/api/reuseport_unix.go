@@ -0,0 +1,28 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"context"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// listenReusePort abre a porta com SO_REUSEPORT: vários sockets na mesma porta,
+// com o kernel distribuindo as conexões novas entre eles. O resto de lc vale
+// para as conexões aceitas.
+func listenReusePort(lc net.ListenConfig, addr string) (net.Listener, error) {
+	lc.Control = func(network, address string, c syscall.RawConn) error {
+		var sockErr error
+		err := c.Control(func(fd uintptr) {
+			sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+		})
+		if err != nil {
+			return err
+		}
+		return sockErr
+	}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
@@ -0,0 +1,169 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// routerState é o estado que só as rotas do Gin usam.
+type routerState struct {
+	compressionState
+	summaryETagState
+}
+
+// registerRouter registra as métricas da compressão e do GET condicional.
+func (gw *gateway) registerRouter() {
+	gw.registerCompression()
+	gw.registerSummaryETag()
+}
+
+// newRouter monta as rotas do build padrão sobre o Gin e retorna também a porta
+// de diagnóstico, se houver.
+func (gw *gateway) newRouter(cfg config.Config) (http.Handler, *http.Server) {
+	// Log de acesso do Gin pelas mesmas saídas com fila do log (LOG_BUFFER)
+	if cfg.LogBuffer > 0 {
+		gin.DefaultWriter = stdoutLog
+		gin.DefaultErrorWriter = stderrLog
+	}
+
+	// Configurar Gin; o ID da requisição vem antes de tudo para constar no log e nos erros
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(requestIDMiddleware(), gin.LoggerWithFormatter(gw.accessLogFormatter), gin.CustomRecovery(gw.handlePanic))
+	r.NoRoute(handleNoRoute)
+
+	// CORS (CORS_*); desativado, nenhum trabalho por requisição
+	if cfg.CORS.Enabled {
+		r.Use(corsMiddleware(cfg.CORS))
+	}
+
+	// gzip/deflate negociados nas respostas maiores e aceitos no lote (COMPRESSION_*)
+	compress := gw.compressionMiddleware(cfg.Compression)
+	decompress := gw.decompressionMiddleware(cfg.Compression)
+
+	// Rotas
+	if cfg.RateLimit.Enabled {
+		// Token buckets global e por cliente (RATE_LIMIT_*)
+		limit := gw.rateLimitMiddleware()
+		r.POST("/payments", limit, gw.handlePayments)
+		r.POST("/payments/batch", append(append([]gin.HandlerFunc{limit}, decompress...), gw.handlePaymentsBatch)...)
+	} else {
+		r.POST("/payments", gw.handlePayments)
+		r.POST("/payments/batch", append(decompress, gw.handlePaymentsBatch)...)
+	}
+	r.DELETE("/payments/:correlationId", gw.handleCancelPayment)
+	r.GET("/payments-summary", append(compress, gw.handlePaymentsSummary)...)
+	r.GET("/payments-summary/timeseries", append(compress, gw.handlePaymentsTimeseries)...)
+	r.GET("/payments/stream", gw.handlePaymentStream)
+	r.GET("/payments/:correlationId", gw.handleGetPayment)
+	r.GET("/healthz", gw.handleHealthz)
+	r.GET("/readyz", gw.handleReadyz)
+	r.GET("/metrics", gw.handleMetrics)
+	// Especificação OpenAPI das rotas (ver openapi.go)
+	r.GET("/openapi.json", gin.WrapF(gw.serveOpenAPI))
+	r.GET("/internal/summary-delta", gw.handleInternalSummaryDelta)
+	// Nome anterior, chamado pelas instâncias ainda na versão antiga durante o deploy
+	r.GET("/internal/summary", gw.handleInternalSummaryDelta)
+
+	// Rotas administrativas: chave de API e/ou allowlist (ADMIN_API_KEY, ADMIN_ALLOW_IPS)
+	admin := r.Group("", authMiddleware(gw.newRouteGuard("/admin", cfg.Auth.Header, cfg.Auth.Admin))...)
+	admin.POST("/purge-payments", gw.handlePurgePayments)
+	admin.GET("/admin/dlq", gw.handleAdminDLQ)
+	admin.POST("/admin/requeue", gw.handleAdminRequeue)
+	admin.GET("/admin/payments", gw.handleAdminPayments)
+	admin.GET("/admin/payments/export", append(compress, gw.handleAdminPaymentsExport)...)
+	admin.GET("/admin/stats", gw.handleAdminStats)
+	admin.GET("/admin/stats/amounts", gw.handleAdminAmountStats)
+	admin.GET("/admin/verify", gw.handleAdminVerify)
+	admin.GET("/admin/audit/:correlationId", gw.handleAdminAudit)
+	admin.GET("/admin/health-history", gw.handleAdminHealthHistory)
+	admin.GET("/admin/routing", gw.handleAdminRouting)
+	admin.GET("/admin/routing/decisions", gw.handleAdminRoutingDecisions)
+	admin.GET("/admin/whoami", gw.handleAdminWhoAmI)
+	admin.GET("/admin/config", gw.handleAdminConfig)
+	admin.PUT("/admin/config", gw.handleAdminConfigUpdate)
+
+	// pprof e estatísticas de runtime (DEBUG_ENDPOINTS)
+	return r, gw.startDebugEndpoints(cfg.Debug, cfg.Auth, r)
+}
+
+func (gw *gateway) handlePayments(c *gin.Context) {
+	reply := gw.servePayment(c.Writer, c.Request)
+	if reply.code != "" {
+		respondErrorDetails(c, reply.status, reply.code, reply.message, reply.details)
+		return
+	}
+	writeStatic(c.Writer, reply.status, reply.body)
+}
+
+func (gw *gateway) handlePaymentsSummary(c *gin.Context) {
+	// ?byCurrency=true acrescenta os contadores de cada moeda (ver currencies.go)
+	if c.Query("byCurrency") == "true" {
+		gw.handleCurrencySummary(c)
+		return
+	}
+
+	// Filtro opcional por período de requestedAt (ISO 8601)
+	from, to, err := parseSummaryRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, errMessage(err))
+		return
+	}
+
+	// ?consistent=true aguarda as escritas em andamento antes de ler;
+	// ?nocache=true ignora o cache do resumo sem filtro;
+	// ?detailed=true acrescenta as taxas e o líquido de cada processor
+	summary := gw.loadPaymentsSummary(from, to, summaryOptions{
+		Consistent: c.Query("consistent") == "true",
+		NoCache:    c.Query("nocache") == "true",
+	})
+	detailed := c.Query("detailed") == "true"
+
+	// 304 quando o cliente já tem esses contadores (ver summary_etag.go)
+	variant := summaryVariant(from, to, detailed)
+	etag := gw.summaryETag(variant, summary, detailed)
+	if gw.notModified(c, etag, gw.summaryModifiedAt(variant, etag)) {
+		return
+	}
+
+	buf := getBuffer()
+	if detailed {
+		*buf = gw.newDetailedSummary(summary).appendJSON(*buf, gw.currentConfig().amountHalfUp)
+	} else {
+		*buf = summary.appendJSON(*buf, gw.currentConfig().amountHalfUp)
+	}
+	writeJSON(c.Writer, http.StatusOK, *buf)
+	putBuffer(buf)
+}
+
+func (gw *gateway) handlePurgePayments(c *gin.Context) {
+	if err := gw.purgePayments(c.Request.Context()); err != nil {
+		respondError(c, http.StatusInternalServerError, errCodeInternal, msg(msgDeletePaymentsFailed))
+		return
+	}
+	writeStatic(c.Writer, http.StatusOK, paymentsPurgedResponse)
+}
+
+// corsMiddleware monta o middleware do gin-contrib/cors a partir de CORS_*.
+func corsMiddleware(cfg config.CORSConfig) gin.HandlerFunc {
+	corsConfig := cors.Config{
+		AllowMethods:     cfg.AllowMethods,
+		AllowHeaders:     cfg.AllowHeaders,
+		ExposeHeaders:    cfg.ExposeHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge.Std(),
+	}
+	if slices.Equal(cfg.AllowOrigins, []string{"*"}) {
+		corsConfig.AllowAllOrigins = true
+	} else {
+		corsConfig.AllowOrigins = cfg.AllowOrigins
+	}
+	return cors.New(corsConfig)
+}
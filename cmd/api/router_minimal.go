@@ -0,0 +1,118 @@
+//go:build minimal
+
+package main
+
+import (
+	"log"
+	"net/http"
+
+	json "github.com/goccy/go-json"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Build minimal (go build -tags minimal), para as rodadas valendo nota: só as
+// rotas que a Rinha e as outras instâncias chamam, direto no net/http, sem Gin,
+// CORS, compressão, OpenAPI, pprof nem log de acesso. Fila, pipeline e storage
+// são os mesmos do build padrão, que segue sendo o de desenvolvimento.
+
+// routerState fica vazio: compressão e GET condicional são só do Gin.
+type routerState struct{}
+
+func (gw *gateway) registerRouter() {}
+
+// newRouter monta as rotas do build minimal; não há porta de diagnóstico.
+func (gw *gateway) newRouter(cfg config.Config) (http.Handler, *http.Server) {
+	if cfg.Debug.Enabled || cfg.RateLimit.Enabled || cfg.Compression.Enabled {
+		log.Printf("Aviso: build minimal ignora DEBUG_ENDPOINTS, RATE_LIMIT_ENABLED e COMPRESSION")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /payments", gw.servePayments)
+	mux.HandleFunc("GET /payments-summary", gw.servePaymentsSummary)
+	mux.HandleFunc("GET /healthz", gw.serveHealthz)
+	mux.HandleFunc("GET /readyz", gw.serveReadyz)
+	mux.HandleFunc("GET /internal/summary-delta", gw.serveInternalSummaryDelta)
+	// Mesma guarda das rotas administrativas do build padrão
+	var purge http.Handler = http.HandlerFunc(gw.servePurgePayments)
+	if guard := gw.newRouteGuard("/admin", cfg.Auth.Header, cfg.Auth.Admin); guard != nil {
+		purge = guard.wrap(purge)
+	}
+	mux.Handle("POST /purge-payments", purge)
+	return mux, nil
+}
+
+func (gw *gateway) servePayments(w http.ResponseWriter, r *http.Request) {
+	reply := gw.servePayment(w, r)
+	if reply.code != "" {
+		writeHTTPErrorDetails(w, r, reply.status, reply.code, reply.message, reply.details)
+		return
+	}
+	writeStatic(w, reply.status, reply.body)
+}
+
+// servePaymentsSummary aceita o período e ?consistent, ?nocache e ?detailed; o
+// resumo por moeda e o ETag ficam no build padrão.
+func (gw *gateway) servePaymentsSummary(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, to, err := parseSummaryRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		writeHTTPError(w, r, http.StatusBadRequest, errCodeInvalidParameter, errMessage(err))
+		return
+	}
+	summary := gw.loadPaymentsSummary(from, to, summaryOptions{
+		Consistent: query.Get("consistent") == "true",
+		NoCache:    query.Get("nocache") == "true",
+	})
+
+	buf := getBuffer()
+	if query.Get("detailed") == "true" {
+		*buf = gw.newDetailedSummary(summary).appendJSON(*buf, gw.currentConfig().amountHalfUp)
+	} else {
+		*buf = summary.appendJSON(*buf, gw.currentConfig().amountHalfUp)
+	}
+	writeJSON(w, http.StatusOK, *buf)
+	putBuffer(buf)
+}
+
+func (gw *gateway) servePurgePayments(w http.ResponseWriter, r *http.Request) {
+	if err := gw.purgePayments(r.Context()); err != nil {
+		writeHTTPError(w, r, http.StatusInternalServerError, errCodeInternal, msg(msgDeletePaymentsFailed))
+		return
+	}
+	writeStatic(w, http.StatusOK, paymentsPurgedResponse)
+}
+
+func (gw *gateway) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, gw.livenessStatus(r.Context()))
+}
+
+func (gw *gateway) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	code, status := gw.readinessStatus(r.Context())
+	writeStatus(w, code, status)
+}
+
+func writeStatus(w http.ResponseWriter, code int, status StatusResponse) {
+	body, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, code, body)
+}
+
+func (gw *gateway) serveInternalSummaryDelta(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, to, err := parseSummaryRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		writeHTTPError(w, r, http.StatusBadRequest, errCodeInvalidParameter, errMessage(err))
+		return
+	}
+	body, err := gw.internalSummaryDelta(r.Context(), from, to)
+	if err != nil {
+		gw.logf(r.Context(), "Erro ao calcular o resumo desta instância: %v", err)
+		writeHTTPError(w, r, http.StatusInternalServerError, errCodeInternal, msg(msgSummaryDeltaFailed))
+		return
+	}
+	writeJSON(w, http.StatusOK, body)
+}
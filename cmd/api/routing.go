@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/health"
+	"rinha-backend-2025/internal/selector"
+)
+
+// GET /admin/routing explica a ordem do selector nesta instância: o que ele
+// recebeu de cada processor, a pontuação da estratégia e os ajustes aplicados
+// depois dele (failback, guard de SLO, orçamento do fallback e restrições por
+// processor). Não há circuit breaker: o limitador de concorrência e o veredito
+// inferido da saúde fazem esse papel, e aparecem por processor.
+
+// RoutingExplanation é a resposta de GET /admin/routing.
+type RoutingExplanation struct {
+	Strategy string `json:"strategy"`
+	// Ordem do selector agora, antes dos desvios sorteados do failback e do SLO
+	Ranking []string `json:"ranking"`
+	// Primeiro da ordem: para onde vai o próximo pagamento sem desvio
+	Decision       string                `json:"decision"`
+	AllFailing     bool                  `json:"allFailing"`
+	Processors     []ProcessorRouting    `json:"processors"`
+	Failback       FailbackRouting       `json:"failback"`
+	SLOGuard       SLOGuardRouting       `json:"sloGuard"`
+	FallbackBudget FallbackBudgetRouting `json:"fallbackBudget"`
+}
+
+// ProcessorRouting são as entradas do selector para um processor.
+type ProcessorRouting struct {
+	Name     string  `json:"name"`
+	Priority int     `json:"priority"`
+	Fee      float64 `json:"fee"`
+	// O failing usado na ordem: o inferido, o do health-check ou o anulado pelo
+	// failback
+	Failing     bool          `json:"failing"`
+	HealthCheck health.Status `json:"healthCheck"`
+	// Veredito da inferência (healthy, failing ou unknown); vazio sem ela
+	Inferred          string  `json:"inferred,omitempty"`
+	InferredErrorRate float64 `json:"inferredErrorRate,omitempty"`
+	// O failback deu o preferido como recuperado depois do último health-check
+	FailbackRecovered bool `json:"failbackRecovered,omitempty"`
+	// Desfechos das tentativas na janela recente
+	Successes int64           `json:"successes"`
+	Failures  int64           `json:"failures"`
+	ErrorRate float64         `json:"errorRate"`
+	Latency   LatencySnapshot `json:"latency"`
+	// Latência no quantil do selector (SELECTOR_LATENCY_QUANTILE)
+	ObservedMs float64 `json:"observedMs"`
+	// Pontuação da estratégia; ausente no failover
+	Score   *RoutingScore           `json:"score,omitempty"`
+	Limits  *ProcessorLimitsRouting `json:"limits,omitempty"`
+	Limiter *LimiterRouting         `json:"limiter,omitempty"`
+}
+
+// RoutingScore é o selector.Score de um processor.
+type RoutingScore struct {
+	LatencyMs float64 `json:"latencyMs,omitempty"`
+	Slow      bool    `json:"slow,omitempty"`
+	Cost      float64 `json:"cost,omitempty"`
+	Value     float64 `json:"value,omitempty"`
+	Chance    float64 `json:"chance,omitempty"`
+}
+
+// ProcessorLimitsRouting são as restrições do processor (PROCESSOR_<NOME>_MAX_*).
+type ProcessorLimitsRouting struct {
+	MaxAmount   float64 `json:"maxAmount"`
+	MaxTPS      float64 `json:"maxTps"`
+	AmountSkips int64   `json:"amountSkips"`
+	TPSSkips    int64   `json:"tpsSkips"`
+}
+
+// LimiterRouting é o estado do limitador de concorrência do processor.
+type LimiterRouting struct {
+	Limit    int   `json:"limit"`
+	InFlight int   `json:"inFlight"`
+	Shed     int64 `json:"shed"`
+}
+
+type FailbackRouting struct {
+	// Fração dos pagamentos que tenta o preferido primeiro durante o desvio; 0 desativado
+	ProbeRate float64 `json:"probeRate"`
+	Preferred string  `json:"preferred"`
+	Diverted  bool    `json:"diverted"`
+	// Sucessos seguidos do preferido e quantos o devolvem ao tráfego
+	Streak    int `json:"streak"`
+	Successes int `json:"successes"`
+}
+
+type SLOGuardRouting struct {
+	Enabled  bool    `json:"enabled"`
+	Breached bool    `json:"breached"`
+	P99Ms    float64 `json:"p99Ms"`
+	BudgetMs float64 `json:"budgetMs"`
+	// Destino do tráfego desviado e a fração desviada durante a violação
+	Target    string  `json:"target,omitempty"`
+	ShiftRate float64 `json:"shiftRate"`
+}
+
+type FallbackBudgetRouting struct {
+	Enabled   bool            `json:"enabled"`
+	Window    config.Duration `json:"window"`
+	MaxAmount float64         `json:"maxAmount"`
+	MaxShare  float64         `json:"maxShare"`
+	// Reservado para o fallback e aceito por todos na janela
+	FallbackAmount float64 `json:"fallbackAmount"`
+	TotalAmount    float64 `json:"totalAmount"`
+	Share          float64 `json:"share"`
+	Deferred       int64   `json:"deferred"`
+}
+
+func (gw *gateway) explainRouting(ctx context.Context) RoutingExplanation {
+	cfg := gw.currentConfig()
+	candidates := gw.routingCandidates(ctx)
+	ranking := cfg.selector.Rank(candidates)
+	var scores map[string]selector.Score
+	if explainer, ok := cfg.selector.(selector.Explainer); ok {
+		scores = explainer.Scores(candidates)
+	}
+
+	explanation := RoutingExplanation{
+		Strategy:       cfg.Selector.Strategy,
+		Ranking:        ranking,
+		Decision:       ranking[0],
+		AllFailing:     gw.allProcessorsFailing(ctx),
+		Processors:     make([]ProcessorRouting, len(candidates)),
+		Failback:       gw.explainFailback(),
+		SLOGuard:       gw.explainSLOGuard(),
+		FallbackBudget: gw.explainFallbackBudget(cfg.FallbackBudget),
+	}
+	for i, candidate := range candidates {
+		explanation.Processors[i] = gw.explainProcessor(ctx, candidate, scores)
+	}
+	return explanation
+}
+
+func (gw *gateway) explainProcessor(ctx context.Context, candidate selector.Candidate, scores map[string]selector.Score) ProcessorRouting {
+	name := candidate.Name
+	status := gw.healthMonitor.Get(ctx, name)
+	p := ProcessorRouting{
+		Name:              name,
+		Priority:          candidate.Priority,
+		Fee:               candidate.Fee,
+		Failing:           candidate.Failing,
+		HealthCheck:       *status,
+		Inferred:          gw.inferredVerdict(name),
+		FailbackRecovered: gw.processorFailing(name, status) && !candidate.Failing,
+		Successes:         candidate.Successes,
+		Failures:          candidate.Failures,
+		ObservedMs:        durationMs(candidate.Observed),
+	}
+	if t := gw.inferenceTrackers[name]; t != nil {
+		p.InferredErrorRate = t.ErrorRate()
+	}
+	if attempts := candidate.Successes + candidate.Failures; attempts > 0 {
+		p.ErrorRate = float64(candidate.Failures) / float64(attempts)
+	}
+	if stats := gw.processorLatency[name]; stats != nil {
+		p.Latency = stats.Snapshot()
+	}
+	if score, ok := scores[name]; ok {
+		p.Score = &RoutingScore{
+			LatencyMs: durationMs(score.Latency),
+			Slow:      score.Slow,
+			Cost:      score.Cost,
+			Value:     score.Value,
+			Chance:    score.Chance,
+		}
+	}
+	if l := gw.processorLimits[name]; l != nil {
+		p.Limits = &ProcessorLimitsRouting{
+			MaxAmount:   l.maxAmount,
+			AmountSkips: l.amountSkips.Load(),
+			TPSSkips:    l.tpsSkips.Load(),
+		}
+		if l.tps != nil {
+			p.Limits.MaxTPS = l.tps.rate
+		}
+	}
+	if limiter := gw.processorLimiters[name]; limiter != nil {
+		limit, inFlight, shed := limiter.snapshot()
+		p.Limiter = &LimiterRouting{Limit: limit, InFlight: inFlight, Shed: shed}
+	}
+	return p
+}
+
+func (gw *gateway) explainFailback() FailbackRouting {
+	gw.failbackMux.Lock()
+	defer gw.failbackMux.Unlock()
+	return FailbackRouting{
+		ProbeRate: gw.failbackProbeRate,
+		Preferred: gw.preferredProcessor(),
+		Diverted:  gw.failbackDiverted,
+		Streak:    gw.failbackStreak,
+		Successes: gw.failbackSuccesses,
+	}
+}
+
+func (gw *gateway) explainSLOGuard() SLOGuardRouting {
+	if !gw.sloCfg.Enabled {
+		return SLOGuardRouting{}
+	}
+	guard := SLOGuardRouting{
+		Enabled:   true,
+		Breached:  gw.sloBreached.Load(),
+		P99Ms:     durationMs(gw.sloLatency[gw.preferredProcessor()].Quantile(0.99)),
+		BudgetMs:  durationMs(gw.sloCfg.P99Budget.Std()),
+		ShiftRate: gw.sloCfg.ShiftRate,
+	}
+	if guard.Breached {
+		gw.sloMux.Lock()
+		guard.Target = gw.sloTarget
+		gw.sloMux.Unlock()
+	}
+	return guard
+}
+
+func (gw *gateway) explainFallbackBudget(cfg config.FallbackBudgetConfig) FallbackBudgetRouting {
+	fallback, total := gw.fallbackBudgetUsage(cfg, gw.appClock.Now())
+	budget := FallbackBudgetRouting{
+		Enabled:        cfg.Enabled,
+		Window:         cfg.Window,
+		MaxAmount:      cfg.MaxAmount,
+		MaxShare:       cfg.MaxShare,
+		FallbackAmount: fallback,
+		TotalAmount:    total,
+		Deferred:       gw.fallbackDeferred.Load(),
+	}
+	if total > 0 {
+		budget.Share = min(fallback/total, 1)
+	}
+	return budget
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
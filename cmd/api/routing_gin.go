@@ -0,0 +1,13 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (gw *gateway) handleAdminRouting(c *gin.Context) {
+	c.JSON(http.StatusOK, gw.explainRouting(c.Request.Context()))
+}
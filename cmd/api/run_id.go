@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/keyspace"
+)
+
+// Chave do RUN_ID sorteado com RUN_ID=auto; é a única fora do namespace
+const runIDKey = "run-id:{rinha}"
+
+// Renova o RUN_ID sorteado: recria a chave se expirou e só estende a deste
+// processo; retorna 0 quando outra execução já a trocou.
+var renewRunIDScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+if current == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// initRunID define o namespace das chaves (RUN_ID) antes do primeiro comando ao
+// Redis. Com "auto", a primeira instância sorteia o ID e as demais o leem do
+// Redis; ele vale enquanto alguma o renovar, então a próxima execução, depois de
+// RUN_ID_TTL parada, começa com chaves novas. Sem Redis na inicialização, as
+// chaves ficam sem prefixo.
+func (gw *gateway) initRunID(cfg config.RedisConfig, client redis.UniversalClient) {
+	switch cfg.RunID {
+	case "":
+		return
+	case config.RunIDAuto:
+	default:
+		gw.keys = keyspace.New(cfg.RunID)
+		log.Printf("Chaves do Redis no namespace %s", cfg.RunID)
+		return
+	}
+
+	if client == nil {
+		log.Printf("Aviso: Redis indisponível, RUN_ID auto não sorteado; chaves sem namespace")
+		return
+	}
+	ttl := cfg.RunIDTTL.Std()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.OpTimeout.Std())
+	id, err := claimRunID(ctx, client, ttl)
+	cancel()
+	if err != nil {
+		log.Printf("Aviso: Erro ao obter o RUN_ID: %v; chaves sem namespace", err)
+		return
+	}
+	gw.keys = keyspace.New(id)
+	log.Printf("Chaves do Redis no namespace %s (auto, renovado a cada %s)", id, ttl/3)
+
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			client := gw.currentRedis()
+			if client == nil {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.OpTimeout.Std())
+			renewed, err := renewRunIDScript.Run(ctx, client, []string{runIDKey}, id, ttl.Milliseconds()).Int()
+			cancel()
+			if err != nil {
+				log.Printf("Erro ao renovar o RUN_ID: %v", err)
+			} else if renewed == 0 {
+				log.Printf("Aviso: RUN_ID %s substituído por outra execução; esta instância segue no namespace antigo", id)
+			}
+		}
+	}()
+}
+
+// claimRunID grava um ID novo se não houver um em vigor e retorna o que valer.
+func claimRunID(ctx context.Context, client redis.UniversalClient, ttl time.Duration) (string, error) {
+	var raw [8]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(raw[:])
+	ok, err := client.SetNX(ctx, runIDKey, id, ttl).Result()
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return id, nil
+	}
+	return client.Get(ctx, runIDKey).Result()
+}
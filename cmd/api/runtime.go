@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Ajuste do runtime aos limites do container (RUNTIME_*): o Go 1.24 usa um P
+// por núcleo da máquina, ignorando a cota de CPU do cgroup, e não tem limite de
+// memória; com 0.6 CPU numa máquina de 8 núcleos, 8 Ps disputam a cota e o
+// container é estrangulado no fim de cada período. Aqui o GOMAXPROCS segue a
+// cota e o GOMEMLIMIT uma fração do limite de memória, como faz o automaxprocs.
+// Só os arquivos do cgroup do próprio processo são lidos (/sys/fs/cgroup do
+// namespace do container), em v2 ou v1.
+
+const (
+	cgroupRoot = "/sys/fs/cgroup"
+	// O v1 reporta "sem limite" como um número enorme, arredondado à página
+	cgroupUnlimited = 1 << 62
+)
+
+func (gw *gateway) registerRuntime() {
+	gw.registerMetric(metric{
+		Name: "runtime_gomaxprocs",
+		Help: "GOMAXPROCS em vigor.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(runtime.GOMAXPROCS(0))}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "runtime_memory_limit_bytes",
+		Help: "GOMEMLIMIT em vigor; ausente sem limite.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			limit := debug.SetMemoryLimit(-1)
+			if limit == math.MaxInt64 {
+				return nil
+			}
+			return []metricSample{{Value: float64(limit)}}
+		},
+	})
+}
+
+// tuneRuntime aplica o GOMAXPROCS, o GOGC e o GOMEMLIMIT e registra a origem de
+// cada um. Roda antes do prefork: os filhos recebem do pai o GOMAXPROCS e o
+// GOMEMLIMIT já divididos e os mantêm, por estarem no ambiente.
+func tuneRuntime(cfg config.RuntimeConfig) {
+	procs := "padrão do Go"
+	switch {
+	case os.Getenv("GOMAXPROCS") != "":
+		procs = "variável GOMAXPROCS"
+	case cfg.AutoMaxProcs:
+		quota, err := cgroupCPUQuota()
+		switch {
+		case err != nil:
+			log.Printf("Aviso: cota de CPU do cgroup não lida: %v", err)
+		case quota > 0:
+			n := min(max(1, int(math.Ceil(quota))), runtime.NumCPU())
+			runtime.GOMAXPROCS(n)
+			procs = fmt.Sprintf("cota do cgroup de %.2f CPU", quota)
+		default:
+			procs = "sem cota no cgroup"
+		}
+	}
+
+	gcPercent, gc := os.Getenv("GOGC"), "variável GOGC"
+	if gcPercent == "" {
+		debug.SetGCPercent(cfg.GCPercent)
+		gcPercent, gc = strconv.Itoa(cfg.GCPercent), "RUNTIME_GOGC"
+		if cfg.GCPercent < 0 {
+			gcPercent = "off"
+		}
+	}
+
+	mem := "sem limite"
+	switch {
+	case os.Getenv("GOMEMLIMIT") != "":
+		mem = "variável GOMEMLIMIT"
+	case cfg.MemoryLimitBytes > 0:
+		debug.SetMemoryLimit(cfg.MemoryLimitBytes)
+		mem = "RUNTIME_MEMORY_LIMIT_BYTES"
+	default:
+		limit, err := cgroupMemoryLimit()
+		switch {
+		case err != nil:
+			log.Printf("Aviso: limite de memória do cgroup não lido: %v", err)
+		case limit > 0:
+			debug.SetMemoryLimit(int64(float64(limit) * cfg.MemoryLimitRatio))
+			mem = fmt.Sprintf("%.0f%% do limite do cgroup de %s", cfg.MemoryLimitRatio*100, formatBytes(limit))
+		}
+	}
+
+	log.Printf("Runtime: GOMAXPROCS=%d (%s), GOGC=%s (%s), GOMEMLIMIT=%s (%s)",
+		runtime.GOMAXPROCS(0), procs, gcPercent, gc, memoryLimitString(), mem)
+}
+
+// cgroupCPUQuota retorna a cota em CPUs; 0 sem cota ou fora de um cgroup.
+func cgroupCPUQuota() (float64, error) {
+	// v2: "max 100000" ou "60000 100000"
+	if data, err := os.ReadFile(cgroupRoot + "/cpu.max"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("cpu.max inesperado: %q", data)
+		}
+		if fields[0] == "max" {
+			return 0, nil
+		}
+		return cpuQuotaRatio(fields[0], fields[1])
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+
+	// v1: cota -1 é sem limite
+	quota, err := os.ReadFile(cgroupRoot + "/cpu/cpu.cfs_quota_us")
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	period, err := os.ReadFile(cgroupRoot + "/cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0, err
+	}
+	q := strings.TrimSpace(string(quota))
+	if q == "-1" {
+		return 0, nil
+	}
+	return cpuQuotaRatio(q, strings.TrimSpace(string(period)))
+}
+
+func cpuQuotaRatio(quota, period string) (float64, error) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cota de CPU inválida: %q", quota)
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, fmt.Errorf("período de CPU inválido: %q", period)
+	}
+	return q / p, nil
+}
+
+// cgroupMemoryLimit retorna o limite de memória em bytes; 0 sem limite ou fora
+// de um cgroup.
+func cgroupMemoryLimit() (uint64, error) {
+	path := cgroupRoot + "/memory.max"
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		path = cgroupRoot + "/memory/memory.limit_in_bytes"
+		data, err = os.ReadFile(path)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	v := strings.TrimSpace(string(data))
+	if v == "max" {
+		return 0, nil
+	}
+	limit, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s inesperado: %q", path, v)
+	}
+	if limit >= cgroupUnlimited {
+		return 0, nil
+	}
+	return limit, nil
+}
+
+func memoryLimitString() string {
+	limit := debug.SetMemoryLimit(-1)
+	if limit == math.MaxInt64 {
+		return "off"
+	}
+	return formatBytes(uint64(limit))
+}
+
+func formatBytes(n uint64) string {
+	return fmt.Sprintf("%.1fMiB", float64(n)/(1<<20))
+}
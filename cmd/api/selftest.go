@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	json "github.com/goccy/go-json"
+	"github.com/google/uuid"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/processorstub"
+)
+
+// Self-test da implantação (--selftest): com os processors trocados por
+// stubs em memória, pagamentos sintéticos percorrem o pipeline de verdade
+// (HTTP, fila, workers, contadores e o storage configurado) e o resultado é
+// conferido nos contadores, no filtro por período, na DLQ e no purge. O
+// processo sai com código 1 na primeira divergência.
+//
+// O teste apaga os pagamentos ao terminar, por isso recusa rodar sobre um
+// storage ou uma DLQ com dados.
+
+const (
+	selfTestPayments = 5
+	selfTestAmount   = 19.90
+	// Espera máxima por cada etapa assíncrona (envio, flush, retries até a DLQ)
+	selfTestTimeout = 30 * time.Second
+)
+
+// initSelfTestProcessors troca os processors configurados por stubs em
+// memória (internal/processorstub), sem latência nem falhas, servidos em
+// loopback: o envio passa pelo cliente HTTP de verdade. O self-test controla as
+// falhas pelos stubs.
+func (gw *gateway) initSelfTestProcessors(defs []config.ProcessorDef, client *http.Client, adminToken string, auth map[string]config.ProcessorAuth) map[string]*processorstub.Stub {
+	stubs := make(map[string]*processorstub.Stub, len(defs))
+	stubbed := make([]config.ProcessorDef, len(defs))
+	for i, def := range defs {
+		stub := processorstub.New(processorstub.Options{Token: adminToken})
+		// Fica no ar até o processo sair, no fim do self-test
+		server := httptest.NewServer(stub)
+		def.URL = server.URL
+		stubbed[i] = def
+		stubs[def.Name] = stub
+	}
+	gw.initProcessors(stubbed, client, adminToken, auth)
+	log.Printf("Self-test: %d processors simulados em memória", len(defs))
+	return stubs
+}
+
+// selfTest faz as requisições pelo router, como um cliente faria.
+type selfTest struct {
+	gw      *gateway
+	handler http.Handler
+	stubs   map[string]*processorstub.Stub
+	// Header e chave das rotas administrativas
+	authHeader string
+	adminKey   string
+}
+
+// runSelfTest roda as etapas em ordem e retorna o código de saída do processo.
+func (gw *gateway) runSelfTest(handler http.Handler, stubs map[string]*processorstub.Stub, cfg config.Config) int {
+	t := &selfTest{gw: gw, handler: handler, stubs: stubs, authHeader: cfg.Auth.Header, adminKey: cfg.Auth.Admin.APIKey}
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"storage vazio", t.checkEmpty},
+		{"contadores", t.checkCounters},
+		{"filtro por período", t.checkRange},
+		{"DLQ", t.checkDLQ},
+		{"purge", t.checkPurge},
+	}
+
+	start := time.Now()
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			log.Printf("Self-test falhou em %s: %v", step.name, err)
+			return 1
+		}
+		log.Printf("Self-test: %s ok", step.name)
+	}
+	log.Printf("Self-test concluído em %v", time.Since(start).Round(time.Millisecond))
+	return 0
+}
+
+// checkEmpty impede que o purge do fim apague pagamentos reais.
+func (t *selfTest) checkEmpty() error {
+	summary, err := t.summary(time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	for name, s := range summary {
+		if s.TotalRequests != 0 {
+			return fmt.Errorf("storage já tem %d pagamentos do %s; rode o self-test num ambiente vazio", s.TotalRequests, name)
+		}
+	}
+	if n := t.gw.dlqLength(); n != 0 {
+		return fmt.Errorf("DLQ já tem %d pagamentos; rode o self-test num ambiente vazio", n)
+	}
+	return nil
+}
+
+// checkCounters envia os pagamentos e confere o resumo de cada processor com o
+// que o stub dele aceitou.
+func (t *selfTest) checkCounters() error {
+	for range selfTestPayments {
+		if err := t.submit(); err != nil {
+			return err
+		}
+	}
+
+	return t.eventually(func() error {
+		summary, err := t.summary(time.Time{}, time.Time{})
+		if err != nil {
+			return err
+		}
+		accepted := 0
+		for _, name := range t.gw.processorNames {
+			payments := t.stubs[name].Payments()
+			accepted += len(payments)
+			var amount float64
+			for _, p := range payments {
+				amount += p.Amount
+			}
+			got := summary[name]
+			if got.TotalRequests != len(payments) || math.Abs(got.TotalAmount-amount) > 0.005 {
+				return fmt.Errorf("%s: resumo com %d pagamentos e %.2f, processor com %d e %.2f",
+					name, got.TotalRequests, got.TotalAmount, len(payments), amount)
+			}
+		}
+		if accepted != selfTestPayments {
+			return fmt.Errorf("processors aceitaram %d de %d pagamentos", accepted, selfTestPayments)
+		}
+		return nil
+	})
+}
+
+// checkRange confere que o período atual contém os pagamentos e um período
+// anterior não contém nenhum.
+func (t *selfTest) checkRange() error {
+	now := time.Now().UTC()
+	current, err := t.summary(now.Add(-time.Hour), now.Add(time.Minute))
+	if err != nil {
+		return err
+	}
+	if got := totalRequests(current); got != selfTestPayments {
+		return fmt.Errorf("última hora com %d pagamentos, esperados %d", got, selfTestPayments)
+	}
+
+	past, err := t.summary(now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	if got := totalRequests(past); got != 0 {
+		return fmt.Errorf("ontem com %d pagamentos, esperado nenhum", got)
+	}
+	return nil
+}
+
+// checkDLQ derruba todos os processors e espera o pagamento esgotar as
+// tentativas e chegar à DLQ. As entradas são removidas antes de restaurar os
+// processors, para o redrive não reenviá-las.
+func (t *selfTest) checkDLQ() error {
+	for _, stub := range t.stubs {
+		stub.SetFailure(true)
+	}
+	defer func() {
+		for _, stub := range t.stubs {
+			stub.SetFailure(false)
+		}
+	}()
+
+	correlationID := uuid.NewString()
+	if err := t.submitID(correlationID); err != nil {
+		return err
+	}
+	err := t.eventually(func() error {
+		for _, entry := range t.gw.listDLQ(t.gw.dlqLength()) {
+			if entry.CorrelationID == correlationID {
+				return nil
+			}
+		}
+		return fmt.Errorf("pagamento %s não chegou à DLQ", correlationID)
+	})
+	for {
+		if _, ok := t.gw.popFromDLQ(); !ok {
+			break
+		}
+	}
+	return err
+}
+
+// checkPurge apaga os pagamentos do teste e confere o resumo zerado.
+func (t *selfTest) checkPurge() error {
+	rec := t.do(http.MethodPost, "/purge-payments", nil, true)
+	if rec.Code != http.StatusOK {
+		return fmt.Errorf("POST /purge-payments respondeu %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, stub := range t.stubs {
+		stub.Purge()
+	}
+
+	summary, err := t.summary(time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	if got := totalRequests(summary); got != 0 {
+		return fmt.Errorf("resumo com %d pagamentos depois do purge", got)
+	}
+	if n := t.gw.dlqLength(); n != 0 {
+		return fmt.Errorf("DLQ com %d pagamentos depois do teste", n)
+	}
+	return nil
+}
+
+func (t *selfTest) submit() error {
+	return t.submitID(uuid.NewString())
+}
+
+func (t *selfTest) submitID(correlationID string) error {
+	body := fmt.Sprintf(`{"correlationId":%q,"amount":%.2f}`, correlationID, selfTestAmount)
+	rec := t.do(http.MethodPost, "/payments", []byte(body), false)
+	if rec.Code < 200 || rec.Code >= 300 {
+		return fmt.Errorf("POST /payments respondeu %d: %s", rec.Code, rec.Body.String())
+	}
+	return nil
+}
+
+// summary lê o resumo consistente, sem cache, com o período opcional.
+func (t *selfTest) summary(from, to time.Time) (PaymentSummaryResponse, error) {
+	query := url.Values{"consistent": {"true"}, "nocache": {"true"}}
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.RFC3339Nano))
+		query.Set("to", to.Format(time.RFC3339Nano))
+	}
+	rec := t.do(http.MethodGet, "/payments-summary?"+query.Encode(), nil, false)
+	if rec.Code != http.StatusOK {
+		return nil, fmt.Errorf("GET /payments-summary respondeu %d: %s", rec.Code, rec.Body.String())
+	}
+	var summary PaymentSummaryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		return nil, fmt.Errorf("resumo inválido: %w", err)
+	}
+	return summary, nil
+}
+
+func (t *selfTest) do(method, target string, body []byte, admin bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req.RemoteAddr = "127.0.0.1:0"
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if admin && t.adminKey != "" {
+		req.Header.Set(t.authHeader, t.adminKey)
+	}
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// eventually repete check até passar ou estourar selfTestTimeout, retornando o
+// último erro.
+func (t *selfTest) eventually(check func() error) error {
+	deadline := time.Now().Add(selfTestTimeout)
+	for {
+		err := check()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func totalRequests(summary PaymentSummaryResponse) int {
+	total := 0
+	for _, s := range summary {
+		total += s.TotalRequests
+	}
+	return total
+}
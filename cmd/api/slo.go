@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Guard do SLO de latência (SLO_GUARD_*): a pontuação cobra o p99, e um processor
+// preferido lento o estoura mesmo sem falhar. Com o p99 de ponta a ponta do
+// preferido acima do orçamento, uma fração dos pagamentos (SLO_SHIFT_RATE) vai
+// primeiro ao processor mais rápido, e os retries caem para SLO_MAX_ATTEMPTS.
+// Tudo volta ao normal quando o p99 fica abaixo de SLO_RECOVER_RATIO do
+// orçamento, o que evita alternar a cada avaliação perto do limite.
+
+// sloState é o estado do guard; vazio com SLO_GUARD_ENABLED=false
+type sloState struct {
+	sloCfg     config.SLOConfig
+	sloLatency map[string]*latencyStats
+
+	sloBreached atomic.Bool
+	sloMux      sync.Mutex
+	// Processor que recebe o tráfego desviado durante a violação
+	sloTarget string
+
+	sloBreaches atomic.Int64
+	sloShifted  atomic.Int64
+}
+
+func (gw *gateway) initSLOGuard(cfg config.SLOConfig) {
+	if !cfg.Enabled || len(gw.processorNames) < 2 {
+		return
+	}
+	gw.sloCfg = cfg
+	for _, name := range gw.processorNames {
+		gw.sloLatency[name] = &latencyStats{clock: gw.appClock, window: cfg.Window.Std(), rotatedAt: gw.appClock.Now()}
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval.Std())
+		defer ticker.Stop()
+		for range ticker.C {
+			gw.evaluateSLO()
+		}
+	}()
+	log.Printf("Guard de SLO ativo: p99 de %s até %v, desviando %.0f%% do tráfego e com até %d tentativas na violação",
+		gw.preferredProcessor(), cfg.P99Budget.Std(), cfg.ShiftRate*100, cfg.MaxAttempts)
+
+	gw.registerMetric(metric{
+		Name: "slo_guard_active",
+		Help: "1 enquanto o p99 do processor preferido está acima do orçamento.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			var v float64
+			if gw.sloBreached.Load() {
+				v = 1
+			}
+			return []metricSample{{Value: v}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "slo_latency_p99_seconds",
+		Help: "p99 da latência de ponta a ponta dos pagamentos confirmados por cada processor.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, 0, len(gw.processorNames))
+			for _, name := range gw.processorNames {
+				samples = append(samples, metricSample{
+					Labels: map[string]string{"processor": name},
+					Value:  gw.sloLatency[name].Quantile(0.99).Seconds(),
+				})
+			}
+			return samples
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "slo_guard_breaches_total",
+		Help: "Vezes em que o p99 do processor preferido passou do orçamento.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.sloBreaches.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "slo_guard_shifted_total",
+		Help: "Pagamentos enviados primeiro ao processor mais rápido durante a violação do SLO.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.sloShifted.Load())}}
+		},
+	})
+}
+
+// recordSLOLatency registra a latência de ponta a ponta de um pagamento
+// confirmado pelo processor.
+func (gw *gateway) recordSLOLatency(processor string, d time.Duration) {
+	if stats := gw.sloLatency[processor]; stats != nil {
+		stats.Record(d)
+	}
+}
+
+// evaluateSLO compara o p99 do preferido com o orçamento e escolhe o destino do
+// tráfego desviado.
+func (gw *gateway) evaluateSLO() {
+	preferred := gw.preferredProcessor()
+	p99, samples := gw.sloLatency[preferred].quantileAndCount(0.99)
+	budget := gw.sloCfg.P99Budget.Std()
+
+	// Sem amostras suficientes, o estado atual se mantém: durante o desvio o
+	// preferido recebe menos tráfego, e pouco tráfego não prova a recuperação.
+	// Sem nenhuma, a violação saiu da janela e o preferido volta a ser medido
+	if samples < gw.sloCfg.MinSamples {
+		if gw.sloBreached.Load() && samples == 0 {
+			gw.setSLOBreached(false, p99)
+		}
+		return
+	}
+	switch {
+	case !gw.sloBreached.Load() && p99 > budget:
+		gw.setSLOBreached(true, p99)
+	case gw.sloBreached.Load() && p99 <= time.Duration(float64(budget)*gw.sloCfg.RecoverRatio):
+		gw.setSLOBreached(false, p99)
+	}
+}
+
+func (gw *gateway) setSLOBreached(breached bool, p99 time.Duration) {
+	if !breached {
+		gw.sloBreached.Store(false)
+		log.Printf("SLO recuperado: p99 de %s em %v, rotas e retries normais", gw.preferredProcessor(), p99)
+		return
+	}
+
+	target := gw.fastestProcessor()
+	gw.sloMux.Lock()
+	gw.sloTarget = target
+	gw.sloMux.Unlock()
+	gw.sloBreached.Store(true)
+	gw.sloBreaches.Add(1)
+	log.Printf("SLO violado: p99 de %s em %v (orçamento %v), desviando tráfego para %s",
+		gw.preferredProcessor(), p99, gw.sloCfg.P99Budget.Std(), target)
+}
+
+// fastestProcessor é o de menor p99 de ponta a ponta entre os demais; sem
+// amostras de nenhum, o segundo em prioridade.
+func (gw *gateway) fastestProcessor() string {
+	best := gw.processorNames[1]
+	var bestP99 time.Duration
+	for _, name := range gw.processorNames[1:] {
+		p99, samples := gw.sloLatency[name].quantileAndCount(0.99)
+		if samples > 0 && (bestP99 == 0 || p99 < bestP99) {
+			best, bestP99 = name, p99
+		}
+	}
+	return best
+}
+
+// applySLOGuard coloca o processor mais rápido na frente de uma fração dos
+// pagamentos enquanto o SLO está violado, se ele não estiver falhando.
+func (gw *gateway) applySLOGuard(ranking []string) []string {
+	if !gw.sloBreached.Load() || ranking[0] != gw.preferredProcessor() || rand.Float64() >= gw.sloCfg.ShiftRate {
+		return ranking
+	}
+	gw.sloMux.Lock()
+	target := gw.sloTarget
+	gw.sloMux.Unlock()
+	if gw.processorFailing(target, gw.healthMonitor.Get(context.Background(), target)) {
+		return ranking
+	}
+
+	gw.sloShifted.Add(1)
+	shifted := make([]string, 0, len(ranking))
+	shifted = append(shifted, target)
+	for _, name := range ranking {
+		if name != target {
+			shifted = append(shifted, name)
+		}
+	}
+	return shifted
+}
+
+// sloMaxAttempts limita as tentativas por processor durante a violação do SLO.
+func (gw *gateway) sloMaxAttempts(attempts int) int {
+	if gw.sloBreached.Load() && gw.sloCfg.MaxAttempts > 0 {
+		return min(attempts, gw.sloCfg.MaxAttempts)
+	}
+	return attempts
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	json "github.com/goccy/go-json"
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/storage"
+)
+
+// Cópia do resumo fora do storage (COUNTER_SNAPSHOT_*): um FLUSHALL acidental no
+// meio do teste zeraria a pontuação. A cada intervalo, o resumo (e, com
+// COUNTER_SNAPSHOT_PAYMENTS, os pagamentos registrados) vai para um arquivo e/ou
+// um Redis secundário. Na subida e antes de cada cópia, se algum processor tem
+// menos pagamentos no storage do que na cópia anterior e não houve purge depois
+// dela, a diferença volta ao storage; o resultado é o maior dos dois.
+//
+// O purge é reconhecido pelo instante gravado nesta instância e no Redis
+// principal. Um purge em outra instância seguido de um FLUSHALL antes da próxima
+// cópia não se distingue de uma perda de dados.
+
+const (
+	snapshotKey       = "summary:snapshot"
+	snapshotPurgedKey = "snapshot:purged-at"
+	snapshotLockKey   = "snapshot:restore:lock"
+)
+
+// Prazo do lock da restauração, que só uma das instâncias faz
+const snapshotLockTTL = 30 * time.Second
+
+// summarySnapshot é o conteúdo de uma cópia.
+type summarySnapshot struct {
+	TakenAt  time.Time                  `json:"takenAt"`
+	Instance string                     `json:"instance"`
+	Summary  map[string]storage.Summary `json:"summary"`
+	Payments []storage.Record           `json:"payments,omitempty"`
+}
+
+// snapshotState é o estado da cópia do resumo; lastSnapshot só é usado pela goroutine
+// das cópias
+type snapshotState struct {
+	snapshotCfg         config.SnapshotConfig
+	snapshotRedis       *redis.Client
+	snapshotRedisTarget string
+	lastSnapshot        *summarySnapshot
+
+	// Último purge feito por esta instância, em ms
+	snapshotPurgedAt atomic.Int64
+
+	snapshotsTaken           atomic.Int64
+	snapshotFailures         atomic.Int64
+	snapshotRestores         atomic.Int64
+	snapshotRestoredRequests atomic.Int64
+}
+
+func (gw *gateway) registerSnapshot() {
+	gw.registerMetric(metric{
+		Name: "counter_snapshots_total",
+		Help: "Cópias do resumo gravadas e com falha.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{
+				{Labels: map[string]string{"result": "ok"}, Value: float64(gw.snapshotsTaken.Load())},
+				{Labels: map[string]string{"result": "error"}, Value: float64(gw.snapshotFailures.Load())},
+			}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "counter_snapshot_restores_total",
+		Help: "Restaurações do storage a partir da cópia do resumo.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.snapshotRestores.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "counter_snapshot_restored_requests_total",
+		Help: "Pagamentos devolvidos aos contadores do storage pela cópia do resumo.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.snapshotRestoredRequests.Load())}}
+		},
+	})
+}
+
+// startSummarySnapshots restaura o que faltar em relação à última cópia e passa
+// a copiar o resumo a cada intervalo. Deve rodar depois do WAL dos contadores.
+func (gw *gateway) startSummarySnapshots(cfg config.SnapshotConfig, backend string) {
+	if !cfg.Enabled() {
+		return
+	}
+	gw.snapshotCfg = cfg
+	if cfg.RedisAddr != "" {
+		gw.snapshotRedis = redis.NewClient(&redis.Options{Addr: cfg.RedisAddr, Password: cfg.RedisPassword})
+		gw.snapshotRedisTarget = gw.keys.Key(snapshotKey)
+		// Cada instância dos backends locais tem o próprio resumo
+		if backend == "memory" || backend == "bolt" {
+			gw.snapshotRedisTarget += ":" + gw.instanceName()
+		}
+	}
+
+	ctx := context.Background()
+	snapshot, err := gw.loadSummarySnapshot(ctx)
+	if err != nil {
+		log.Printf("Aviso: cópia do resumo ilegível, ignorada: %v", err)
+	} else if snapshot != nil {
+		log.Printf("Cópia do resumo de %s carregada (%s)", snapshot.TakenAt.Format(time.RFC3339), snapshot.Instance)
+		gw.lastSnapshot = snapshot
+	}
+	gw.takeSummarySnapshot(ctx)
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval.Std())
+		defer ticker.Stop()
+
+		for range ticker.C {
+			gw.takeSummarySnapshot(ctx)
+		}
+	}()
+}
+
+// takeSummarySnapshot restaura o que faltar em relação à cópia anterior e grava
+// uma nova. Sem conseguir restaurar, mantém a anterior, que ainda tem os valores
+// maiores.
+func (gw *gateway) takeSummarySnapshot(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, max(gw.snapshotCfg.Interval.Std(), 5*time.Second))
+	defer cancel()
+
+	if gw.lastSnapshot != nil {
+		if err := gw.restoreFromSnapshot(ctx, gw.lastSnapshot); err != nil {
+			gw.snapshotFailures.Add(1)
+			log.Printf("Cópia do resumo não atualizada: %v", err)
+			return
+		}
+	}
+
+	// Um purge no meio deixaria a cópia com parte dos valores antigos
+	gw.counterFlushGate.RLock()
+	defer gw.counterFlushGate.RUnlock()
+
+	snapshot, err := gw.captureSummarySnapshot(ctx)
+	if err == nil {
+		err = gw.writeSummarySnapshot(ctx, snapshot)
+	}
+	if err != nil {
+		gw.snapshotFailures.Add(1)
+		log.Printf("Erro ao gravar cópia do resumo: %v", err)
+		return
+	}
+	gw.snapshotsTaken.Add(1)
+	gw.lastSnapshot = snapshot
+}
+
+func (gw *gateway) captureSummarySnapshot(ctx context.Context) (*summarySnapshot, error) {
+	summary, err := gw.store.GetSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &summarySnapshot{TakenAt: gw.appClock.Now().UTC(), Instance: gw.instanceName(), Summary: summary}
+	if !gw.snapshotCfg.Payments {
+		return snapshot, nil
+	}
+	exporter, ok := gw.store.(storage.Exporter)
+	if !ok {
+		return snapshot, nil
+	}
+	err = exporter.ExportByRange(ctx, time.Time{}, gw.snapshotExportEnd(), func(r storage.Record) error {
+		snapshot.Payments = append(snapshot.Payments, r)
+		return nil
+	})
+	return snapshot, err
+}
+
+// Fim do intervalo das exportações de todos os pagamentos
+func (gw *gateway) snapshotExportEnd() time.Time {
+	return gw.appClock.Now().Add(24 * time.Hour)
+}
+
+// restoreFromSnapshot devolve ao storage o que ele perdeu em relação à cópia:
+// os contadores que encolheram e, na cópia com pagamentos, os que sumiram.
+func (gw *gateway) restoreFromSnapshot(ctx context.Context, snapshot *summarySnapshot) error {
+	if gw.snapshotPurgedSince(ctx, snapshot.TakenAt) {
+		return nil
+	}
+	live, err := gw.store.GetSummary(ctx)
+	if err != nil {
+		return err
+	}
+	if len(missingSummary(snapshot.Summary, live)) == 0 {
+		return nil
+	}
+
+	// Uma instância restaura; as outras veem o resultado na próxima cópia
+	if client := gw.currentRedis(); client != nil {
+		acquired, err := client.SetNX(ctx, gw.keys.Key(snapshotLockKey), gw.instanceName(), snapshotLockTTL).Result()
+		if err != nil {
+			return fmt.Errorf("erro ao obter lock da restauração: %w", err)
+		}
+		if !acquired {
+			return errors.New("restauração em andamento em outra instância")
+		}
+		defer client.Del(context.Background(), gw.keys.Key(snapshotLockKey))
+	}
+
+	// Sem flushes nem purges enquanto a diferença é calculada e somada
+	gw.counterFlushGate.Lock()
+	defer gw.counterFlushGate.Unlock()
+	if gw.snapshotPurgedSince(ctx, snapshot.TakenAt) {
+		return nil
+	}
+	live, err = gw.store.GetSummary(ctx)
+	if err != nil {
+		return err
+	}
+	deltas := missingSummary(snapshot.Summary, live)
+	if len(deltas) == 0 {
+		return nil
+	}
+	if err := gw.store.IncrementSummary(ctx, deltas); err != nil {
+		return fmt.Errorf("erro ao restaurar contadores: %w", err)
+	}
+	gw.paymentsSummaryCache.invalidate()
+
+	var requests int64
+	for name, delta := range deltas {
+		requests += delta.Requests
+		log.Printf("Aviso: storage com %d pagamentos a menos do %s que a cópia de %s; restaurados", delta.Requests, name, snapshot.TakenAt.Format(time.RFC3339))
+	}
+	gw.snapshotRestores.Add(1)
+	gw.snapshotRestoredRequests.Add(requests)
+
+	if len(snapshot.Payments) > 0 {
+		restored, err := gw.restoreSnapshotPayments(ctx, snapshot.Payments)
+		if err != nil {
+			return fmt.Errorf("erro ao restaurar pagamentos: %w", err)
+		}
+		log.Printf("%d pagamentos registrados restaurados da cópia do resumo", restored)
+	}
+	return nil
+}
+
+// missingSummary retorna, por processor, quanto falta no storage para chegar à
+// cópia; os que têm ao menos os pagamentos da cópia ficam de fora.
+func missingSummary(snapshot, live map[string]storage.Summary) map[string]*storage.Delta {
+	deltas := make(map[string]*storage.Delta)
+	for name, s := range snapshot {
+		current := live[name]
+		if s.TotalRequests <= current.TotalRequests {
+			continue
+		}
+		deltas[name] = &storage.Delta{
+			Requests: int64(s.TotalRequests - current.TotalRequests),
+			Amount:   s.TotalAmount - current.TotalAmount,
+		}
+	}
+	return deltas
+}
+
+// restoreSnapshotPayments registra os pagamentos da cópia que o storage não tem.
+func (gw *gateway) restoreSnapshotPayments(ctx context.Context, payments []storage.Record) (int, error) {
+	exporter, ok := gw.store.(storage.Exporter)
+	if !ok {
+		return 0, nil
+	}
+	existing := make(map[string]bool)
+	err := exporter.ExportByRange(ctx, time.Time{}, gw.snapshotExportEnd(), func(r storage.Record) error {
+		existing[r.CorrelationID] = true
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var missing []storage.Record
+	for _, payment := range payments {
+		if !existing[payment.CorrelationID] {
+			missing = append(missing, payment)
+		}
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+	return len(missing), gw.recordPayments(ctx, missing)
+}
+
+// snapshotPurgedSince informa se houve purge depois da cópia, nesta instância ou,
+// pelo Redis principal, em qualquer outra.
+func (gw *gateway) snapshotPurgedSince(ctx context.Context, takenAt time.Time) bool {
+	if gw.snapshotPurgedAt.Load() >= takenAt.UnixMilli() {
+		return true
+	}
+	client := gw.currentRedis()
+	if client == nil {
+		return false
+	}
+	value, err := client.Get(ctx, gw.keys.Key(snapshotPurgedKey)).Int64()
+	if err != nil && err != redis.Nil {
+		// Na dúvida, não restaurar: somar a mais é pior que deixar de somar
+		log.Printf("Erro ao consultar o último purge: %v", err)
+		return true
+	}
+	return value >= takenAt.UnixMilli()
+}
+
+// markSnapshotPurge registra o purge e apaga as cópias, chamada com
+// counterFlushGate adquirido.
+func (gw *gateway) markSnapshotPurge(ctx context.Context) {
+	if !gw.snapshotCfg.Enabled() {
+		return
+	}
+	now := gw.appClock.Now().UnixMilli()
+	gw.snapshotPurgedAt.Store(now)
+	if client := gw.currentRedis(); client != nil {
+		if err := client.Set(ctx, gw.keys.Key(snapshotPurgedKey), now, 0).Err(); err != nil {
+			gw.logf(ctx, "Erro ao registrar o purge para a cópia do resumo: %v", err)
+		}
+	}
+	if gw.snapshotCfg.Path != "" {
+		if err := os.Remove(gw.snapshotCfg.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			gw.logf(ctx, "Erro ao apagar a cópia do resumo: %v", err)
+		}
+	}
+	if gw.snapshotRedis != nil {
+		if err := gw.snapshotRedis.Del(ctx, gw.snapshotRedisTarget).Err(); err != nil {
+			gw.logf(ctx, "Erro ao apagar a cópia do resumo no Redis secundário: %v", err)
+		}
+	}
+}
+
+// writeSummarySnapshot grava a cópia em todos os destinos; o arquivo é trocado
+// por rename, sem deixar uma cópia pela metade.
+func (gw *gateway) writeSummarySnapshot(ctx context.Context, snapshot *summarySnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	if path := gw.snapshotCfg.Path; path != "" {
+		tmp := path + ".tmp"
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err == nil {
+			err = os.WriteFile(tmp, data, 0o644)
+		}
+		if err == nil {
+			err = os.Rename(tmp, path)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("arquivo %s: %w", path, err))
+		}
+	}
+	if gw.snapshotRedis != nil {
+		if err := gw.snapshotRedis.Set(ctx, gw.snapshotRedisTarget, data, 0).Err(); err != nil {
+			errs = append(errs, fmt.Errorf("Redis secundário: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadSummarySnapshot lê a cópia mais recente entre o arquivo e o Redis
+// secundário; nil sem nenhuma.
+func (gw *gateway) loadSummarySnapshot(ctx context.Context) (*summarySnapshot, error) {
+	var candidates [][]byte
+	if path := gw.snapshotCfg.Path; path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if data != nil {
+			candidates = append(candidates, data)
+		}
+	}
+	if gw.snapshotRedis != nil {
+		data, err := gw.snapshotRedis.Get(ctx, gw.snapshotRedisTarget).Bytes()
+		if err != nil && err != redis.Nil {
+			log.Printf("Aviso: Não foi possível ler a cópia do resumo no Redis secundário: %v", err)
+		}
+		if data != nil {
+			candidates = append(candidates, data)
+		}
+	}
+
+	var newest *summarySnapshot
+	for _, data := range candidates {
+		var snapshot summarySnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, err
+		}
+		if newest == nil || snapshot.TakenAt.After(newest.TakenAt) {
+			newest = &snapshot
+		}
+	}
+	return newest, nil
+}
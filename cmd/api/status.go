@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/queue"
+)
+
+// statusState é o estado da instância
+type statusState struct {
+	// Vira true quando toda a inicialização terminou
+	appReady atomic.Bool
+	// Vira true quando o encerramento começa
+	appShuttingDown atomic.Bool
+}
+
+type ProcessorStatus struct {
+	Failing         bool      `json:"failing"`
+	MinResponseTime int       `json:"minResponseTime"`
+	LastCheckedAt   time.Time `json:"lastCheckedAt"`
+	// Veredito do tráfego recente: "healthy", "failing" ou "unknown"; ausente
+	// sem HEALTH_INFERENCE
+	Inferred string `json:"inferred,omitempty"`
+}
+
+type StatusResponse struct {
+	Status     string                     `json:"status"`
+	Redis      string                     `json:"redis"`
+	Workers    queue.Status               `json:"workers"`
+	DLQSize    int                        `json:"dlqSize"`
+	Processors map[string]ProcessorStatus `json:"processors"`
+	// Os números dos headers de POST /payments
+	Backpressure BackpressureStatus `json:"backpressure"`
+	Memory       MemoryStatus       `json:"memory"`
+	LoadShed     LoadShedStatus     `json:"loadShed"`
+}
+
+// livenessStatus é o corpo do /healthz: 200 enquanto o processo estiver de pé.
+func (gw *gateway) livenessStatus(ctx context.Context) StatusResponse {
+	status := gw.buildStatus(ctx)
+	status.Status = "alive"
+	return status
+}
+
+// readinessStatus é a resposta do /readyz: 503 até a inicialização terminar,
+// durante o encerramento ou quando o Redis conectado deixa de responder. Em modo
+// degradado (Redis fora, dados em memória) a instância continua pronta.
+func (gw *gateway) readinessStatus(ctx context.Context) (int, StatusResponse) {
+	status := gw.buildStatus(ctx)
+
+	switch {
+	case gw.appShuttingDown.Load():
+		status.Status = "shutting_down"
+	case !gw.appReady.Load():
+		status.Status = "starting"
+	case status.Redis != "ok" && status.Redis != "degraded":
+		status.Status = "redis_unavailable"
+	default:
+		status.Status = "ready"
+		return http.StatusOK, status
+	}
+	return http.StatusServiceUnavailable, status
+}
+
+func (gw *gateway) buildStatus(ctx context.Context) StatusResponse {
+	return StatusResponse{
+		Redis:      gw.redisStatus(ctx),
+		Workers:    gw.paymentQueue.Status(),
+		DLQSize:    gw.dlqLength(),
+		Processors: gw.processorsStatus(),
+
+		Backpressure: gw.backpressureStatus(),
+		Memory:       gw.memoryStatus(),
+		LoadShed:     gw.loadShedStatus(),
+	}
+}
+
+func (gw *gateway) redisStatus(ctx context.Context) string {
+	client := gw.currentRedis()
+	if client == nil {
+		// Sem conexão: a instância segue em memória enquanto o supervisor reconecta
+		return "degraded"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+func (gw *gateway) processorsStatus() map[string]ProcessorStatus {
+	snapshot := gw.healthMonitor.Snapshot()
+	processors := make(map[string]ProcessorStatus, len(snapshot))
+	for name, status := range snapshot {
+		processors[name] = ProcessorStatus{
+			Failing:         status.Failing,
+			MinResponseTime: status.MinResponseTime,
+			LastCheckedAt:   status.LastCheckedAt,
+			Inferred:        gw.inferredVerdict(name),
+		}
+	}
+	return processors
+}
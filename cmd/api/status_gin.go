@@ -0,0 +1,18 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (gw *gateway) handleHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gw.livenessStatus(c.Request.Context()))
+}
+
+func (gw *gateway) handleReadyz(c *gin.Context) {
+	code, status := gw.readinessStatus(c.Request.Context())
+	c.JSON(code, status)
+}
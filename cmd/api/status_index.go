@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/ids"
+)
+
+// Índices por status (STATUS_INDEX): um sorted set no Redis para cada status,
+// com o correlationId (em ids.Encode) como membro e o requestedAt como score, para listar em
+// GET /admin/payments os pagamentos pendentes, processados ou na DLQ de um
+// período, e reenviar ou investigar só esses depois de um teste. Uma transição
+// retira o pagamento dos outros status e o põe no novo, no mesmo pipeline. Como
+// a auditoria, as transições são gravadas em lotes e são best-effort: sem Redis
+// elas são descartadas.
+
+const statusIndexKeyPrefix = "payments:status:"
+
+// Status dos índices
+const (
+	paymentPending   = "pending"
+	paymentSucceeded = "succeeded"
+	paymentFailed    = "failed"
+)
+
+var paymentStatuses = []string{paymentPending, paymentSucceeded, paymentFailed}
+
+// Tamanho máximo de uma página de GET /admin/payments
+const maxStatusPage = 1000
+
+// statusTransition é a mudança de status de um pagamento.
+type statusTransition struct {
+	CorrelationID string
+	// Vazio retira o pagamento de todos os índices
+	Status      string
+	RequestedAt time.Time
+}
+
+// PaymentStatusPage é a resposta de GET /admin/payments.
+type PaymentStatusPage struct {
+	Status string `json:"status"`
+	// Pagamentos do status no período, em todas as páginas
+	Total    int64                `json:"total"`
+	Payments []PaymentStatusEntry `json:"payments"`
+	// offset da próxima página; ausente na última
+	NextOffset int64 `json:"nextOffset,omitempty"`
+}
+
+type PaymentStatusEntry struct {
+	CorrelationID string    `json:"correlationId"`
+	RequestedAt   time.Time `json:"requestedAt"`
+}
+
+func (gw *gateway) registerStatusIndex() {
+	gw.onPaymentEvent("status-index", func(e BusEvent) {
+		if !gw.statusIndexEnabled {
+			return
+		}
+		switch e.Kind {
+		case PaymentReceived:
+			gw.indexPaymentStatus(e.Payment.CorrelationID, paymentPending, e.Payment.RequestedAt)
+		case PaymentSettled:
+			gw.indexPaymentStatus(e.Payment.CorrelationID, paymentSucceeded, e.Payment.RequestedAt)
+		case PaymentFailed:
+			gw.indexPaymentStatus(e.Payment.CorrelationID, paymentFailed, e.Payment.RequestedAt)
+		}
+	})
+}
+
+// statusIndexState é o estado dos índices por status
+type statusIndexState struct {
+	statusIndexEnabled bool
+	statusIndexMaxLen  int64
+
+	pendingTransitions    []statusTransition
+	pendingTransitionsMux sync.Mutex
+	statusIndexDropped    atomic.Int64
+}
+
+// startStatusIndex grava as transições em lotes, mantendo até MaxLen pagamentos
+// em cada status.
+func (gw *gateway) startStatusIndex(cfg config.StatusIndexConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	gw.statusIndexEnabled = true
+	gw.statusIndexMaxLen = cfg.MaxLen
+
+	gw.registerMetric(metric{
+		Name: "status_index_dropped_total",
+		Help: "Transições de status descartadas (Redis indisponível ou acúmulo acima do limite).",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.statusIndexDropped.Load())}}
+		},
+	})
+
+	go func() {
+		ticker := time.NewTicker(cfg.FlushInterval.Std())
+		defer ticker.Stop()
+
+		for range ticker.C {
+			gw.flushStatusIndex()
+		}
+	}()
+	log.Printf("Índices por status ativos em %s* (até %d pagamentos por status)", gw.keys.Key(statusIndexKeyPrefix), cfg.MaxLen)
+}
+
+// indexPaymentStatus registra a transição; sem requestedAt (modo "send", ainda
+// na fila), vale o instante atual.
+func (gw *gateway) indexPaymentStatus(correlationID, status string, requestedAt time.Time) {
+	if !gw.statusIndexEnabled {
+		return
+	}
+	if requestedAt.IsZero() {
+		requestedAt = gw.appClock.Now()
+	}
+
+	gw.pendingTransitionsMux.Lock()
+	defer gw.pendingTransitionsMux.Unlock()
+	// Um lote maior que os índices só atrasaria o descarte
+	if int64(len(gw.pendingTransitions)) >= gw.statusIndexMaxLen*int64(len(paymentStatuses)) {
+		gw.statusIndexDropped.Add(1)
+		return
+	}
+	gw.pendingTransitions = append(gw.pendingTransitions, statusTransition{CorrelationID: correlationID, Status: status, RequestedAt: requestedAt})
+}
+
+func (gw *gateway) flushStatusIndex() {
+	gw.pendingTransitionsMux.Lock()
+	transitions := gw.pendingTransitions
+	gw.pendingTransitions = nil
+	gw.pendingTransitionsMux.Unlock()
+
+	if len(transitions) == 0 {
+		return
+	}
+	client := gw.currentRedis()
+	if client == nil {
+		gw.statusIndexDropped.Add(int64(len(transitions)))
+		return
+	}
+
+	ctx := context.Background()
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, t := range transitions {
+			for _, status := range paymentStatuses {
+				if status != t.Status {
+					pipe.ZRem(ctx, gw.keys.Key(statusIndexKeyPrefix+status), ids.Encode(t.CorrelationID))
+				}
+			}
+			if t.Status != "" {
+				pipe.ZAdd(ctx, gw.keys.Key(statusIndexKeyPrefix+t.Status), redis.Z{
+					Score:  float64(t.RequestedAt.UnixMilli()),
+					Member: ids.Encode(t.CorrelationID),
+				})
+			}
+		}
+		// Mantém os de requestedAt mais recente
+		for _, status := range paymentStatuses {
+			pipe.ZRemRangeByRank(ctx, gw.keys.Key(statusIndexKeyPrefix+status), 0, -gw.statusIndexMaxLen-1)
+		}
+		return nil
+	})
+	if err != nil {
+		gw.statusIndexDropped.Add(int64(len(transitions)))
+		log.Printf("Erro ao gravar índices por status: %v", err)
+	}
+}
+
+// purgeStatusIndex apaga os índices junto com os pagamentos.
+func (gw *gateway) purgeStatusIndex(ctx context.Context) error {
+	gw.pendingTransitionsMux.Lock()
+	gw.pendingTransitions = nil
+	gw.pendingTransitionsMux.Unlock()
+
+	client := gw.currentRedis()
+	if client == nil {
+		return nil
+	}
+	// Uma chave por comando: no Cluster elas podem estar em slots diferentes
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, status := range paymentStatuses {
+			pipe.Del(ctx, gw.keys.Key(statusIndexKeyPrefix+status))
+		}
+		return nil
+	})
+	return err
+}
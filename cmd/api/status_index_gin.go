@@ -0,0 +1,104 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/ids"
+)
+
+// handleAdminPayments lista os pagamentos de um status com requestedAt em
+// [from, to], do mais antigo para o mais recente, em páginas de limit a partir
+// de offset.
+func (gw *gateway) handleAdminPayments(c *gin.Context) {
+	if !gw.statusIndexEnabled {
+		respondError(c, http.StatusNotFound, errCodeNotFound, msg(msgStatusIndexDisabled))
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case paymentPending, paymentSucceeded, paymentFailed:
+	default:
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidStatus))
+		return
+	}
+	from, to, err := parseSummaryRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, errMessage(err))
+		return
+	}
+	limit := int64(100)
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 || n > maxStatusPage {
+			respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidPageLimit, maxStatusPage))
+			return
+		}
+		limit = n
+	}
+	var offset int64
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidOffset))
+			return
+		}
+		offset = n
+	}
+	client := gw.currentRedis()
+	if client == nil {
+		respondError(c, http.StatusServiceUnavailable, errCodeUnavailable, msg(msgRedisUnavailable))
+		return
+	}
+
+	minScore, maxScore := "-inf", "+inf"
+	if !from.IsZero() {
+		minScore = strconv.FormatInt(from.UnixMilli(), 10)
+	}
+	if !to.IsZero() {
+		maxScore = strconv.FormatInt(to.UnixMilli(), 10)
+	}
+
+	// Incluir o que ainda não foi gravado
+	gw.flushStatusIndex()
+	ctx := c.Request.Context()
+	key := gw.keys.Key(statusIndexKeyPrefix + status)
+	var total *redis.IntCmd
+	var members *redis.ZSliceCmd
+	_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		total = pipe.ZCount(ctx, key, minScore, maxScore)
+		members = pipe.ZRangeArgsWithScores(ctx, redis.ZRangeArgs{
+			Key:     key,
+			Start:   minScore,
+			Stop:    maxScore,
+			ByScore: true,
+			Offset:  offset,
+			Count:   limit,
+		})
+		return nil
+	})
+	if err != nil {
+		gw.logf(ctx, "Erro ao consultar índice %s: %v", key, err)
+		respondError(c, http.StatusInternalServerError, errCodeInternal, msg(msgStatusIndexFailed))
+		return
+	}
+
+	page := PaymentStatusPage{Status: status, Total: total.Val(), Payments: make([]PaymentStatusEntry, 0, len(members.Val()))}
+	for _, z := range members.Val() {
+		id, _ := z.Member.(string)
+		page.Payments = append(page.Payments, PaymentStatusEntry{
+			CorrelationID: ids.Decode(id),
+			RequestedAt:   time.UnixMilli(int64(z.Score)).UTC(),
+		})
+	}
+	if next := offset + int64(len(page.Payments)); next < page.Total {
+		page.NextOffset = next
+	}
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Tipos de evento publicados em GET /payments/stream
+const (
+	eventReceived  = "received"
+	eventRouted    = "routed"
+	eventSucceeded = "succeeded"
+	eventFailed    = "failed"
+	eventCancelled = "cancelled"
+)
+
+// Comentário SSE enviado periodicamente para detectar clientes desconectados
+const streamKeepAlive = 15 * time.Second
+
+// PaymentEvent é um passo do pagamento no pipeline.
+type PaymentEvent struct {
+	Type          string    `json:"type"`
+	CorrelationID string    `json:"correlationId"`
+	Amount        float64   `json:"amount"`
+	Processor     string    `json:"processor,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+// streamSubscriber recebe os eventos em um buffer próprio: quando o cliente não
+// acompanha, os eventos mais antigos são descartados, nunca o pipeline espera.
+type streamSubscriber struct {
+	events  chan PaymentEvent
+	dropped atomic.Int64
+}
+
+// push retorna quantos eventos antigos saíram para o novo caber.
+func (s *streamSubscriber) push(event PaymentEvent) (dropped int64) {
+	for {
+		select {
+		case s.events <- event:
+			return dropped
+		default:
+		}
+		// Buffer cheio: abrir espaço descartando o mais antigo
+		select {
+		case <-s.events:
+			s.dropped.Add(1)
+			dropped++
+		default:
+		}
+	}
+}
+
+// streamState é o estado do stream de eventos
+type streamState struct {
+	streamBufferSize int
+	streamSubs       map[*streamSubscriber]struct{}
+	streamSubsMux    sync.RWMutex
+	// Evita montar eventos sem ninguém ouvindo
+	streamSubCount       atomic.Int64
+	paymentStreamDropped atomic.Int64
+
+	// Fechado no encerramento para liberar as conexões abertas
+	streamDone      chan struct{}
+	streamCloseOnce sync.Once
+}
+
+// Tipo no stream de cada etapa do barramento
+var streamEventTypes = [...]string{
+	PaymentReceived: eventReceived,
+	PaymentRouted:   eventRouted,
+	PaymentSettled:  eventSucceeded,
+	PaymentFailed:   eventFailed,
+}
+
+func (gw *gateway) registerStream() {
+	gw.onPaymentEvent("stream", func(e BusEvent) {
+		if gw.streamSubCount.Load() == 0 {
+			return
+		}
+		gw.pushPaymentEvent(PaymentEvent{
+			Type:          streamEventTypes[e.Kind],
+			CorrelationID: e.Payment.CorrelationID,
+			Amount:        e.Payment.Amount,
+			Processor:     e.Processor,
+			At:            e.At,
+		})
+	})
+
+	gw.registerMetric(metric{
+		Name: "payment_stream_subscribers",
+		Help: "Clientes conectados em /payments/stream.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.streamSubCount.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "payment_stream_dropped_total",
+		Help: "Eventos descartados por clientes lentos em /payments/stream.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.paymentStreamDropped.Load())}}
+		},
+	})
+}
+
+func (gw *gateway) initPaymentStream(cfg config.StreamConfig) {
+	gw.streamBufferSize = cfg.BufferSize
+}
+
+// publishPaymentEvent entrega o evento a todos os clientes conectados, sem bloquear.
+func (gw *gateway) publishPaymentEvent(eventType, correlationID string, amount float64, processor string) {
+	if gw.streamSubCount.Load() == 0 {
+		return
+	}
+	gw.pushPaymentEvent(PaymentEvent{
+		Type:          eventType,
+		CorrelationID: correlationID,
+		Amount:        amount,
+		Processor:     processor,
+		At:            gw.appClock.Now().UTC(),
+	})
+}
+
+func (gw *gateway) pushPaymentEvent(event PaymentEvent) {
+	gw.streamSubsMux.RLock()
+	defer gw.streamSubsMux.RUnlock()
+	for sub := range gw.streamSubs {
+		gw.paymentStreamDropped.Add(sub.push(event))
+	}
+}
+
+func (gw *gateway) subscribePaymentEvents() *streamSubscriber {
+	sub := &streamSubscriber{events: make(chan PaymentEvent, gw.streamBufferSize)}
+	gw.streamSubsMux.Lock()
+	gw.streamSubs[sub] = struct{}{}
+	gw.streamSubsMux.Unlock()
+	gw.streamSubCount.Add(1)
+	return sub
+}
+
+func (gw *gateway) unsubscribePaymentEvents(sub *streamSubscriber) {
+	gw.streamSubsMux.Lock()
+	delete(gw.streamSubs, sub)
+	gw.streamSubsMux.Unlock()
+	gw.streamSubCount.Add(-1)
+}
+
+// closePaymentStreams encerra as conexões abertas; registrado no Shutdown do
+// servidor HTTP, que de outra forma esperaria os streams até o timeout.
+func (gw *gateway) closePaymentStreams() {
+	gw.streamCloseOnce.Do(func() { close(gw.streamDone) })
+}
@@ -0,0 +1,68 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handlePaymentStream envia os eventos como Server-Sent Events. Descartes por
+// lentidão são informados em um evento "dropped" com a quantidade perdida.
+func (gw *gateway) handlePaymentStream(c *gin.Context) {
+	sub := gw.subscribePaymentEvents()
+	defer gw.unsubscribePaymentEvents(sub)
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Impede o buffering de proxies como o nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	ctx := c.Request.Context()
+	var reported int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-gw.streamDone:
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event := <-sub.events:
+			if dropped := sub.dropped.Load(); dropped > reported {
+				if err := writeSSE(w, "dropped", gin.H{"count": dropped - reported}); err != nil {
+					return
+				}
+				reported = dropped
+			}
+			if err := writeSSE(w, event.Type, event); err != nil {
+				return
+			}
+		}
+		w.Flush()
+	}
+}
+
+func writeSSE(w gin.ResponseWriter, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Erro ao serializar evento do stream: %v", err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+)
+
+// summaryCache guarda por um instante o resumo sem filtro, que o checker consulta
+// em rajadas. A geração impede que uma leitura iniciada antes de um purge grave
+// o resultado antigo depois dele.
+type summaryCache struct {
+	mu         sync.Mutex
+	clock      clock.Clock
+	ttl        time.Duration
+	value      PaymentSummaryResponse
+	expires    time.Time
+	generation uint64
+}
+
+// summaryCacheState é o estado do cache do resumo
+type summaryCacheState struct {
+	paymentsSummaryCache *summaryCache
+}
+
+func (c *summaryCache) get() (PaymentSummaryResponse, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl > 0 && c.clock.Now().Before(c.expires) {
+		return c.value, c.generation, true
+	}
+	return PaymentSummaryResponse{}, c.generation, false
+}
+
+func (c *summaryCache) set(value PaymentSummaryResponse, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 || generation != c.generation {
+		return
+	}
+	c.value = value
+	c.expires = c.clock.Now().Add(c.ttl)
+}
+
+func (c *summaryCache) invalidate() {
+	c.mu.Lock()
+	c.generation++
+	c.expires = time.Time{}
+	c.mu.Unlock()
+}
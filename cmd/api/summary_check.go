@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/storage"
+)
+
+// Início do histórico conferível, atualizado a cada purge e compartilhado entre as
+// instâncias: janelas anteriores misturariam pagamentos já apagados de um lado só
+const summaryCheckSinceKey = "summary-check:since"
+
+// summaryDiscrepancy é o quanto o processor tem a mais que os nossos contadores
+// (negativo quando temos a mais).
+type summaryDiscrepancy struct {
+	Requests int64
+	Amount   float64
+}
+
+// summaryCheckState é o estado da conferência do resumo
+type summaryCheckState struct {
+	// Início do histórico conhecido por esta instância (unix ms)
+	summaryCheckSince atomic.Int64
+
+	// Divergência da última janela conferida, por processor
+	summaryDiscrepancies    map[string]summaryDiscrepancy
+	summaryDiscrepanciesMux sync.Mutex
+
+	summaryChecks      atomic.Int64
+	summaryCorrections atomic.Int64
+}
+
+func (gw *gateway) registerSummaryCheck() {
+	gw.registerMetric(metric{
+		Name: "summary_discrepancy_requests",
+		Help: "Pagamentos a mais no processor que nos contadores, na última janela conferida.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			return gw.collectSummaryDiscrepancies(func(d summaryDiscrepancy) float64 { return float64(d.Requests) })
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "summary_discrepancy_amount",
+		Help: "Valor a mais no processor que nos contadores, na última janela conferida.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			return gw.collectSummaryDiscrepancies(func(d summaryDiscrepancy) float64 { return d.Amount })
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "summary_checks_total",
+		Help: "Janelas conferidas com o resumo administrativo dos processors.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.summaryChecks.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "summary_corrections_total",
+		Help: "Divergências somadas aos contadores (SUMMARY_CHECK_CORRECT).",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.summaryCorrections.Load())}}
+		},
+	})
+}
+
+func (gw *gateway) collectSummaryDiscrepancies(value func(summaryDiscrepancy) float64) []metricSample {
+	gw.summaryDiscrepanciesMux.Lock()
+	defer gw.summaryDiscrepanciesMux.Unlock()
+
+	samples := make([]metricSample, 0, len(gw.processorNames))
+	for _, name := range gw.processorNames {
+		samples = append(samples, metricSample{
+			Labels: map[string]string{"processor": name},
+			Value:  value(gw.summaryDiscrepancies[name]),
+		})
+	}
+	return samples
+}
+
+// startSummaryCheck confere a cada intervalo a janela que terminou Lag atrás.
+func (gw *gateway) startSummaryCheck(cfg config.SummaryCheckConfig, storageCfg config.StorageConfig, peers config.PeersConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	// Com storage local (memória ou bolt) e várias instâncias, nenhuma vê todos os pagamentos
+	if (storageCfg.Backend == "memory" || storageCfg.Backend == "bolt") && len(peers.URLs) > 0 {
+		log.Printf("Aviso: conferência do resumo desativada com storage %s e peers", storageCfg.Backend)
+		return
+	}
+
+	// Pagamentos de antes desta instância subir podem ter ficado só na memória de outra
+	gw.summaryCheckSince.Store(gw.appClock.Now().UnixMilli())
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval.Std())
+		defer ticker.Stop()
+
+		for range ticker.C {
+			gw.checkSummaries(cfg, len(peers.URLs) > 0)
+		}
+	}()
+}
+
+// checkSummaries compara, por processor, os pagamentos com requestedAt na janela
+// com o que o processor aceitou no mesmo período.
+func (gw *gateway) checkSummaries(cfg config.SummaryCheckConfig, clustered bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Interval.Std())
+	defer cancel()
+
+	interval := cfg.Interval.Std()
+	end := gw.appClock.Now().Add(-cfg.Lag.Std()).Truncate(interval)
+	start := end.Add(-interval)
+	if since := gw.summaryCheckStart(ctx); since.After(start) {
+		if !since.Before(end) {
+			return
+		}
+		start = since
+	}
+
+	// Uma instância por janela, para não corrigir a mesma divergência várias vezes
+	client := gw.currentRedis()
+	if client != nil {
+		acquired, err := client.SetNX(ctx, gw.keys.Key("summary-check:lock:"+strconv.FormatInt(end.UnixMilli(), 10)), gw.instanceName(), 2*interval).Result()
+		if err != nil {
+			log.Printf("Erro ao obter lock da conferência do resumo: %v", err)
+			return
+		}
+		if !acquired {
+			return
+		}
+	} else if clustered {
+		// Em modo degradado esta instância não vê os pagamentos das outras
+		return
+	}
+
+	// Limite inclusivo dos dois lados: parar 1ms antes do início da próxima janela
+	to := end.Add(-time.Millisecond)
+
+	gw.flushCounters()
+	ours, err := gw.store.QueryByRange(ctx, start, to)
+	if err != nil {
+		log.Printf("Erro ao consultar resumo para conferência: %v", err)
+		return
+	}
+	gw.summaryChecks.Add(1)
+
+	for _, name := range gw.processorNames {
+		theirs, err := gw.processorClients[name].PaymentsSummary(ctx, start, to)
+		if err != nil {
+			log.Printf("Erro ao consultar resumo administrativo do %s: %v", name, err)
+			continue
+		}
+
+		diff := summaryDiscrepancy{
+			Requests: int64(theirs.TotalRequests - ours[name].TotalRequests),
+			Amount:   math.Round((theirs.TotalAmount-ours[name].TotalAmount)*100) / 100,
+		}
+		gw.summaryDiscrepanciesMux.Lock()
+		gw.summaryDiscrepancies[name] = diff
+		gw.summaryDiscrepanciesMux.Unlock()
+
+		if diff.Requests == 0 && diff.Amount == 0 {
+			continue
+		}
+		log.Printf("Divergência no resumo do %s entre %s e %s: processor %d/%.2f, contadores %d/%.2f",
+			name, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339),
+			theirs.TotalRequests, theirs.TotalAmount, ours[name].TotalRequests, ours[name].TotalAmount)
+
+		if cfg.Correct {
+			gw.correctSummary(ctx, name, diff)
+		}
+	}
+}
+
+// correctSummary soma a divergência aos contadores. Só o resumo sem filtro é
+// corrigido: os registros individuais da janela continuam como estão.
+func (gw *gateway) correctSummary(ctx context.Context, processor string, diff summaryDiscrepancy) {
+	gw.counterFlushGate.RLock()
+	defer gw.counterFlushGate.RUnlock()
+
+	deltas := map[string]*storage.Delta{processor: {Requests: diff.Requests, Amount: diff.Amount}}
+	if err := gw.store.IncrementSummary(ctx, deltas); err != nil {
+		log.Printf("Erro ao corrigir contadores do %s: %v", processor, err)
+		return
+	}
+	gw.paymentsSummaryCache.invalidate()
+	gw.summaryCorrections.Add(1)
+	log.Printf("Contadores do %s corrigidos em %d/%.2f", processor, diff.Requests, diff.Amount)
+}
+
+// summaryCheckStart retorna o mais recente entre o início local e o último purge.
+func (gw *gateway) summaryCheckStart(ctx context.Context) time.Time {
+	since := gw.summaryCheckSince.Load()
+	if client := gw.currentRedis(); client != nil {
+		shared, err := client.Get(ctx, gw.keys.Key(summaryCheckSinceKey)).Int64()
+		if err != nil && err != redis.Nil {
+			log.Printf("Erro ao ler início da conferência do resumo: %v", err)
+		}
+		if shared > since {
+			since = shared
+		}
+	}
+	return time.UnixMilli(since)
+}
+
+// resetSummaryCheck descarta o histórico anterior ao purge.
+func (gw *gateway) resetSummaryCheck(ctx context.Context) {
+	now := gw.appClock.Now().UnixMilli()
+	gw.summaryCheckSince.Store(now)
+
+	gw.summaryDiscrepanciesMux.Lock()
+	gw.summaryDiscrepancies = make(map[string]summaryDiscrepancy)
+	gw.summaryDiscrepanciesMux.Unlock()
+
+	if client := gw.currentRedis(); client != nil {
+		if err := client.Set(ctx, gw.keys.Key(summaryCheckSinceKey), now, 0).Err(); err != nil {
+			log.Printf("Erro ao registrar início da conferência do resumo: %v", err)
+		}
+	}
+}
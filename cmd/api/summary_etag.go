@@ -0,0 +1,150 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET condicional do resumo: os dashboards consultam o resumo sem parar, então a
+// resposta leva um ETag calculado dos contadores, antes da serialização, e um
+// Last-Modified com o instante em que esta instância viu os valores mudarem.
+// Com If-None-Match (que tem precedência) ou If-Modified-Since atendidos, a
+// resposta é 304 sem corpo. O ETag é fraco porque o corpo pode ir comprimido.
+
+// Variantes acompanhadas antes do mapa recomeçar; cada período filtrado é uma
+const summaryVersionsMax = 256
+
+// summaryVersion é o ETag atual de uma variante do resumo e desde quando ele vale.
+type summaryVersion struct {
+	etag  string
+	since time.Time
+}
+
+// summaryETagState é o estado do GET condicional
+type summaryETagState struct {
+	summaryVersions    map[string]summaryVersion
+	summaryVersionsMux sync.Mutex
+
+	summaryNotModified atomic.Int64
+}
+
+func (gw *gateway) registerSummaryETag() {
+	gw.summaryVersions = make(map[string]summaryVersion)
+
+	gw.registerMetric(metric{
+		Name: "payments_summary_not_modified_total",
+		Help: "Consultas ao resumo respondidas com 304 pelo If-None-Match ou If-Modified-Since.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.summaryNotModified.Load())}}
+		},
+	})
+}
+
+// summaryETag resume os contadores e a variante da resposta (período e detalhe,
+// com as taxas) num ETag fraco.
+func (gw *gateway) summaryETag(variant string, summary PaymentSummaryResponse, detailed bool) string {
+	h := fnv.New64a()
+	h.Write([]byte(variant))
+	var buf [8]byte
+	for _, name := range summary.orderedNames() {
+		s := summary[name]
+		h.Write([]byte(name))
+		binary.LittleEndian.PutUint64(buf[:], uint64(s.TotalRequests))
+		h.Write(buf[:])
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(s.TotalAmount))
+		h.Write(buf[:])
+		if detailed {
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(gw.processorDefs[name].Fee))
+			h.Write(buf[:])
+		}
+	}
+	return `W/"` + strconv.FormatUint(h.Sum64(), 16) + `"`
+}
+
+// summaryModifiedAt retorna desde quando a variante tem esse ETag. Valores que
+// voltam a um ETag anterior, como depois de um purge, contam como mudança.
+func (gw *gateway) summaryModifiedAt(variant, etag string) time.Time {
+	gw.summaryVersionsMux.Lock()
+	defer gw.summaryVersionsMux.Unlock()
+
+	if v, ok := gw.summaryVersions[variant]; ok && v.etag == etag {
+		return v.since
+	}
+	// Recomeçar só adianta o Last-Modified, o que no máximo evita um 304
+	if len(gw.summaryVersions) >= summaryVersionsMax {
+		clear(gw.summaryVersions)
+	}
+	now := gw.appClock.Now()
+	gw.summaryVersions[variant] = summaryVersion{etag: etag, since: now}
+	return now
+}
+
+// summaryVariant identifica a resposta pelos parâmetros que mudam o corpo.
+func summaryVariant(from, to time.Time, detailed bool) string {
+	var b strings.Builder
+	b.WriteString(strconv.FormatInt(timeKey(from), 10))
+	b.WriteByte('|')
+	b.WriteString(strconv.FormatInt(timeKey(to), 10))
+	if detailed {
+		b.WriteString("|detailed")
+	}
+	return b.String()
+}
+
+func timeKey(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// notModified grava o ETag e o Last-Modified e responde 304 quando o cliente já
+// tem essa versão.
+func (gw *gateway) notModified(c *gin.Context, etag string, modifiedAt time.Time) bool {
+	header := c.Writer.Header()
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", modifiedAt.UTC().Format(http.TimeFormat))
+	// Pode guardar, mas deve revalidar a cada consulta
+	header.Set("Cache-Control", "no-cache")
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+	} else {
+		ims, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+		// O Last-Modified tem precisão de segundos
+		if err != nil || modifiedAt.Truncate(time.Second).After(ims) {
+			return false
+		}
+	}
+	gw.summaryNotModified.Add(1)
+	c.Status(http.StatusNotModified)
+	c.Writer.WriteHeaderNow()
+	return true
+}
+
+// etagMatches faz a comparação fraca do If-None-Match, que pode listar vários
+// ETags ou "*".
+func etagMatches(header, etag string) bool {
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+//go:build !minimal
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	pp "rinha-backend-2025/internal/processor"
+	"rinha-backend-2025/internal/storage"
+)
+
+// Granularidades aceitas em ?bucket=
+var timeseriesBuckets = map[string]time.Duration{
+	"1s":  time.Second,
+	"10s": 10 * time.Second,
+	"1m":  time.Minute,
+}
+
+// Buckets por resposta; períodos maiores pedem uma granularidade maior
+const maxTimeseriesBuckets = 3600
+
+// Sem from, a série cobre os últimos buckets até to
+const defaultTimeseriesBuckets = 60
+
+// TimeseriesResponse é a resposta de GET /payments-summary/timeseries.
+type TimeseriesResponse struct {
+	Bucket  string
+	Buckets []TimeseriesBucket
+}
+
+type TimeseriesBucket struct {
+	Start      time.Time
+	Processors PaymentSummaryResponse
+}
+
+// handlePaymentsTimeseries quebra o resumo em buckets de requestedAt, com os
+// totais de cada processor, para acompanhar a vazão ao longo do teste.
+func (gw *gateway) handlePaymentsTimeseries(c *gin.Context) {
+	name := c.DefaultQuery("bucket", "1s")
+	bucket, ok := timeseriesBuckets[name]
+	if !ok {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgInvalidBucket))
+		return
+	}
+	from, to, err := parseSummaryRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, errMessage(err))
+		return
+	}
+	if to.IsZero() {
+		to = gw.appClock.Now().UTC()
+	}
+	if from.IsZero() {
+		from = to.Add(-(defaultTimeseriesBuckets - 1) * bucket)
+	}
+	if to.Before(from) {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgRangeOrder))
+		return
+	}
+	if to.Sub(from.Truncate(bucket))/bucket >= maxTimeseriesBuckets {
+		respondError(c, http.StatusBadRequest, errCodeInvalidParameter, msg(msgTooManyBuckets, maxTimeseriesBuckets, name))
+		return
+	}
+
+	// Descarregar os pagamentos pendentes desta instância antes de ler
+	gw.flushCounters()
+	buckets, err := storage.QueryBuckets(c.Request.Context(), gw.store, from, to, bucket)
+	if errors.Is(err, storage.ErrNoTimeSeries) {
+		respondError(c, http.StatusNotImplemented, errCodeNotImplemented, msg(msgNoTimeSeries))
+		return
+	}
+	if err != nil {
+		gw.logf(c.Request.Context(), "Erro ao consultar a série do resumo: %v", err)
+		respondError(c, http.StatusInternalServerError, errCodeInternal, msg(msgTimeSeriesFailed))
+		return
+	}
+
+	response := TimeseriesResponse{Bucket: name, Buckets: make([]TimeseriesBucket, len(buckets))}
+	for i, b := range buckets {
+		response.Buckets[i] = TimeseriesBucket{Start: b.Start, Processors: gw.newPaymentSummary(b.Processors)}
+	}
+	buf := getBuffer()
+	*buf = response.appendJSON(*buf, gw.currentConfig().amountHalfUp)
+	writeJSON(c.Writer, http.StatusOK, *buf)
+	putBuffer(buf)
+}
+
+// appendJSON escreve {"bucket":..,"buckets":[{"start":..,"default":{..},..}]},
+// com os processors de cada bucket na ordem do resumo.
+func (r TimeseriesResponse) appendJSON(buf []byte, halfUp bool) []byte {
+	buf = append(buf, `{"bucket":`...)
+	buf = appendJSONString(buf, r.Bucket)
+	buf = append(buf, `,"buckets":[`...)
+	for i, b := range r.Buckets {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, `{"start":"`...)
+		buf = b.Start.AppendFormat(buf, pp.RequestedAtLayout)
+		buf = append(buf, '"')
+		for _, name := range b.Processors.orderedNames() {
+			buf = append(buf, ',')
+			buf = appendJSONString(buf, name)
+			buf = append(buf, ':')
+			buf = b.Processors[name].appendJSON(buf, halfUp)
+		}
+		buf = append(buf, '}')
+	}
+	return append(buf, "]}"...)
+}
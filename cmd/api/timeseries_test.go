@@ -0,0 +1,68 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/storage"
+)
+
+// TestTimeseriesWindowFollowsAppClock confere que a janela padrão da série, sem
+// from e to, termina no instante do relógio da aplicação.
+func TestTimeseriesWindowFollowsAppClock(t *testing.T) {
+	start := time.Date(2025, 7, 15, 12, 0, 30, 0, time.UTC)
+	fake := clock.NewFake(start)
+	gw := newTestGateway(t, fake)
+	cfg := gw.currentConfig()
+	// O resumo de cada bucket traz só os processors configurados
+	gw.initProcessors(cfg.ProcessorDefs(), http.DefaultClient, "", nil)
+	memory := storage.NewMemory()
+	gw.store = memory
+	handler, _ := gw.newRouter(cfg.Config)
+
+	ctx := context.Background()
+	for _, at := range []time.Time{start.Add(-time.Second), start.Add(-2 * time.Minute)} {
+		if err := memory.RecordPayment(ctx, storage.Record{CorrelationID: at.String(), Amount: 19.90, Processor: "default", RequestedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A janela cobre os últimos 60s do relógio: só o pagamento de 1s atrás
+	if got := timeseriesRequests(t, handler); got != 1 {
+		t.Fatalf("%d pagamentos na janela, esperado 1", got)
+	}
+	fake.Advance(2 * time.Minute)
+	if got := timeseriesRequests(t, handler); got != 0 {
+		t.Errorf("%d pagamentos na janela 2min depois, esperado 0", got)
+	}
+}
+
+// timeseriesRequests soma os pagamentos do default em todos os buckets.
+func timeseriesRequests(t *testing.T, handler http.Handler) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/payments-summary/timeseries", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Buckets []struct {
+			Default ProcessorSummary `json:"default"`
+		} `json:"buckets"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, b := range body.Buckets {
+		total += b.Default.TotalRequests
+	}
+	return total
+}
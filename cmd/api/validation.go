@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"mime"
+	"strconv"
+	"strings"
+
+	json "github.com/goccy/go-json"
+	"golang.org/x/text/currency"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/ids"
+	"rinha-backend-2025/internal/storage"
+)
+
+// FieldError descreve um problema de validação em um campo do payload. O Code é
+// estável; a Message segue o Accept-Language (ver i18n.go).
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Argumentos da mensagem, para traduzi-la na resposta
+	args []any
+}
+
+// ValidationError agrupa os problemas encontrados; vira uma resposta 422.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// isJSONContentType aceita application/json, com ou sem parâmetros como charset.
+func isJSONContentType(value string) bool {
+	mediaType, _, err := mime.ParseMediaType(value)
+	return err == nil && mediaType == "application/json"
+}
+
+// rawPaymentRequest preserva o texto do amount para validar casas decimais e tipo.
+type rawPaymentRequest struct {
+	CorrelationID *string         `json:"correlationId"`
+	Amount        json.RawMessage `json:"amount"`
+	CallbackURL   *string         `json:"callbackUrl"`
+	Currency      *string         `json:"currency"`
+	Metadata      json.RawMessage `json:"metadata"`
+}
+
+// decodePaymentRequest faz a decodificação estrita do corpo: JSON malformado ou
+// conteúdo após o objeto retornam erro comum (400); campos desconhecidos e
+// problemas de valores retornam *ValidationError (422).
+func (gw *gateway) decodePaymentRequest(body io.Reader, maxAmount float64) (PaymentRequest, error) {
+	var raw rawPaymentRequest
+
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&raw); err != nil {
+		// O codec não tem erro tipado para campos desconhecidos
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return PaymentRequest{}, &ValidationError{Fields: []FieldError{
+				issue(fieldUnknown).at(strings.Trim(field, `"`)),
+			}}
+		}
+		return PaymentRequest{}, newMessageError(msgInvalidJSON, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return PaymentRequest{}, newMessageError(msgJSONTrailingData)
+	}
+
+	var req PaymentRequest
+	var fields []FieldError
+
+	switch {
+	case raw.CorrelationID == nil:
+		fields = append(fields, issue(fieldRequired).at("correlationId"))
+	default:
+		req.CorrelationID = *raw.CorrelationID
+		if !ids.Valid(req.CorrelationID) {
+			fields = append(fields, issue(fieldInvalidUUID).at("correlationId"))
+		}
+	}
+
+	amount, problem := gw.validateAmount(raw.Amount, maxAmount)
+	if problem.code != "" {
+		fields = append(fields, problem.at("amount"))
+	}
+	req.Amount = amount
+
+	if raw.CallbackURL != nil {
+		req.CallbackURL = *raw.CallbackURL
+		if !config.ValidURL(req.CallbackURL) {
+			fields = append(fields, issue(fieldInvalidURL).at("callbackUrl"))
+		}
+	}
+
+	req.Currency = storage.DefaultCurrency
+	if raw.Currency != nil {
+		req.Currency = *raw.Currency
+		if problem := validateCurrency(req.Currency); problem.code != "" {
+			fields = append(fields, problem.at("currency"))
+		}
+	}
+
+	if raw.Metadata != nil {
+		metadata, problem := gw.validateMetadata(raw.Metadata)
+		if problem.code != "" {
+			fields = append(fields, problem.at("metadata"))
+		}
+		req.Metadata = metadata
+	}
+
+	if len(fields) > 0 {
+		return req, &ValidationError{Fields: fields}
+	}
+	return req, nil
+}
+
+// validateAmount aceita apenas números JSON (não strings), positivos, finitos,
+// com no máximo 2 casas decimais, até maxAmount e aceitos por algum processor.
+func (gw *gateway) validateAmount(raw json.RawMessage, maxAmount float64) (float64, fieldIssue) {
+	text := string(bytes.TrimSpace(raw))
+	if text == "" || text == "null" {
+		return 0, issue(fieldRequired)
+	}
+	if text[0] == '"' {
+		return 0, issue(fieldNumberAsString)
+	}
+
+	amount, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0, issue(fieldInvalidNumber)
+	}
+	if amount <= 0 {
+		return amount, issue(fieldNotPositive)
+	}
+	if maxAmount > 0 && amount > maxAmount {
+		return amount, issue(fieldAboveMaximum, maxAmount)
+	}
+	if limit, ok := gw.processorsAmountLimit(); ok && amount > limit {
+		return amount, issue(fieldAboveProcessorLimit, limit)
+	}
+	if !hasAtMostTwoDecimals(text, amount) {
+		return amount, issue(fieldTooManyDecimals)
+	}
+	return amount, fieldIssue{}
+}
+
+// validateCurrency aceita apenas códigos ISO 4217 em maiúsculas, como "BRL" e "USD".
+func validateCurrency(code string) fieldIssue {
+	if len(code) != 3 || strings.ToUpper(code) != code {
+		return issue(fieldInvalidCurrency)
+	}
+	if _, err := currency.ParseISO(code); err != nil {
+		return issue(fieldUnknownCurrency)
+	}
+	return fieldIssue{}
+}
+
+// currencyOrDefault trata como BRL as entradas gravadas antes da moeda existir.
+func currencyOrDefault(code string) string {
+	if code == "" {
+		return storage.DefaultCurrency
+	}
+	return code
+}
+
+func hasAtMostTwoDecimals(text string, amount float64) bool {
+	// Notação científica: conferir pelo valor
+	if strings.ContainsAny(text, "eE") {
+		cents := amount * 100
+		return math.Abs(cents-math.Round(cents)) < 1e-6
+	}
+
+	dot := strings.IndexByte(text, '.')
+	if dot < 0 {
+		return true
+	}
+	decimals := strings.TrimRight(text[dot+1:], "0")
+	return len(decimals) <= 2
+}
+
+// validatePaymentRequest aplica as mesmas regras a pedidos que não chegam como
+// JSON (gRPC, MessagePack e protobuf), onde o amount já é um número.
+func (gw *gateway) validatePaymentRequest(req PaymentRequest, maxAmount float64) error {
+	var fields []FieldError
+
+	switch {
+	case req.CorrelationID == "":
+		fields = append(fields, issue(fieldRequired).at("correlationId"))
+	default:
+		if !ids.Valid(req.CorrelationID) {
+			fields = append(fields, issue(fieldInvalidUUID).at("correlationId"))
+		}
+	}
+
+	text := strconv.FormatFloat(req.Amount, 'f', -1, 64)
+	if _, problem := gw.validateAmount(json.RawMessage(text), maxAmount); problem.code != "" {
+		fields = append(fields, problem.at("amount"))
+	}
+
+	if req.CallbackURL != "" && !config.ValidURL(req.CallbackURL) {
+		fields = append(fields, issue(fieldInvalidURL).at("callbackUrl"))
+	}
+	if problem := validateCurrency(req.Currency); problem.code != "" {
+		fields = append(fields, problem.at("currency"))
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pp "rinha-backend-2025/internal/processor"
+)
+
+// Conferência antes da falha (RETRY_VERIFY_BEFORE_FAIL): sem outbox, uma
+// tentativa sem resposta (timeout, conexão caída) segue o retry, e o processor
+// pode ter aceitado o pagamento mesmo assim. Antes de mandá-lo para a DLQ,
+// GET /payments/{id} em cada processor com tentativa ambígua evita perder um
+// pagamento que ele já cobrou.
+
+// Resultados da conferência, na ordem da métrica
+const (
+	verifyFound = iota
+	verifyNotFound
+	verifyError
+)
+
+var verifyResultNames = [...]string{verifyFound: "found", verifyNotFound: "not_found", verifyError: "error"}
+
+// verifyState é o estado da conferência antes da falha
+type verifyState struct {
+	verifyResults [len(verifyResultNames)]atomic.Int64
+}
+
+func (gw *gateway) registerVerify() {
+	gw.registerMetric(metric{
+		Name: "payment_verifications_total",
+		Help: "Consultas GET /payments/{id} aos processors com tentativa ambígua antes de declarar a falha, por resultado.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, len(verifyResultNames))
+			for i, name := range verifyResultNames {
+				samples[i] = metricSample{
+					Labels: map[string]string{"result": name},
+					Value:  float64(gw.verifyResults[i].Load()),
+				}
+			}
+			return samples
+		},
+	})
+}
+
+// ambiguousAttempts guarda os processors que deixaram alguma tentativa do
+// pagamento sem resposta conclusiva.
+type ambiguousAttempts struct {
+	mu         sync.Mutex
+	processors []string
+}
+
+type ambiguousAttemptsKey struct{}
+
+// withAmbiguousAttempts prepara ctx para registrar as tentativas ambíguas do envio.
+func (gw *gateway) withAmbiguousAttempts(ctx context.Context) context.Context {
+	if !gw.currentConfig().Retry.VerifyBeforeFail {
+		return ctx
+	}
+	return context.WithValue(ctx, ambiguousAttemptsKey{}, &ambiguousAttempts{})
+}
+
+// markAmbiguous registra que uma tentativa no processor pode ter sido aceita.
+func markAmbiguous(ctx context.Context, processor string) {
+	a, _ := ctx.Value(ambiguousAttemptsKey{}).(*ambiguousAttempts)
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	if !slices.Contains(a.processors, processor) {
+		a.processors = append(a.processors, processor)
+	}
+	a.mu.Unlock()
+}
+
+// verifyAmbiguousAttempts procura o pagamento nos processors com tentativa
+// ambígua e retorna o que o aceitou, com o requestedAt registrado por ele. Roda mesmo com o orçamento do pagamento
+// esgotado, limitada ao timeout de uma tentativa.
+func (gw *gateway) verifyAmbiguousAttempts(ctx context.Context, correlationID string) (string, time.Time, bool) {
+	a, _ := ctx.Value(ambiguousAttemptsKey{}).(*ambiguousAttempts)
+	if a == nil {
+		return "", time.Time{}, false
+	}
+	a.mu.Lock()
+	processors := slices.Clone(a.processors)
+	a.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), gw.currentConfig().HTTP.AttemptTimeout.Std())
+	defer cancel()
+	for _, processor := range processors {
+		payment, err := gw.processorClients[processor].GetPayment(ctx, correlationID)
+		switch {
+		case err == nil:
+			gw.verifyResults[verifyFound].Add(1)
+			gw.recordAudit(AuditEntry{CorrelationID: correlationID, Event: auditVerified, Processor: processor})
+			gw.logf(ctx, "Pagamento %s encontrado no %s depois das tentativas sem resposta", correlationID, processor)
+			return processor, payment.RequestedAt.UTC(), true
+		case errors.Is(err, pp.ErrNotFound):
+			gw.verifyResults[verifyNotFound].Add(1)
+		default:
+			gw.verifyResults[verifyError].Add(1)
+			gw.logf(ctx, "Erro ao conferir %s no %s: %v", correlationID, processor, err)
+		}
+	}
+	return "", time.Time{}, false
+}
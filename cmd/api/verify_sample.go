@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+
+	"rinha-backend-2025/internal/config"
+	pp "rinha-backend-2025/internal/processor"
+)
+
+// Amostragem de conferência (VERIFY_SAMPLE_RATE): uma fração dos pagamentos
+// aceitos é procurada com GET /payments/{id} no processor que os aceitou, logo
+// depois do envio e no mesmo worker. A taxa de acerto das últimas
+// VERIFY_SAMPLE_WINDOW conferências de cada processor vai para a métrica
+// payment_verify_sample_correctness: abaixo de 1, o processor respondeu 2xx e
+// perdeu ou alterou pagamentos. As conferências com erro não entram na taxa.
+
+// Resultados da conferência por amostragem, na ordem da métrica
+const (
+	sampleFound = iota
+	sampleNotFound
+	sampleMismatch
+	sampleError
+)
+
+var sampleResultNames = [...]string{
+	sampleFound:    "found",
+	sampleNotFound: "not_found",
+	sampleMismatch: "mismatch",
+	sampleError:    "error",
+}
+
+// sampleWindow guarda as últimas conferências conclusivas de um processor.
+type sampleWindow struct {
+	mu      sync.Mutex
+	correct []bool
+	next    int
+	filled  bool
+	hits    int
+
+	results [len(sampleResultNames)]atomic.Int64
+}
+
+// verifySampleState é o estado da amostragem; sampleWindows é nil com ela desativada
+type verifySampleState struct {
+	verifySampleRate float64
+	sampleWindows    map[string]*sampleWindow
+}
+
+func (gw *gateway) initVerifySample(cfg config.VerifySampleConfig) {
+	if cfg.Rate <= 0 {
+		return
+	}
+	gw.verifySampleRate = cfg.Rate
+	gw.sampleWindows = make(map[string]*sampleWindow, len(gw.processorNames))
+	for _, name := range gw.processorNames {
+		gw.sampleWindows[name] = &sampleWindow{correct: make([]bool, cfg.Window)}
+	}
+
+	gw.registerMetric(metric{
+		Name: "payment_verify_samples_total",
+		Help: "Pagamentos aceitos conferidos por amostragem com GET /payments/{id}, por processor e resultado.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, 0, len(gw.processorNames)*len(sampleResultNames))
+			for _, name := range gw.processorNames {
+				for i, result := range sampleResultNames {
+					samples = append(samples, metricSample{
+						Labels: map[string]string{"processor": name, "result": result},
+						Value:  float64(gw.sampleWindows[name].results[i].Load()),
+					})
+				}
+			}
+			return samples
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "payment_verify_sample_correctness",
+		Help: "Fração das últimas conferências por amostragem em que o processor tinha o pagamento aceito, com o mesmo valor.",
+		Type: "gauge",
+		Collect: func() []metricSample {
+			samples := make([]metricSample, 0, len(gw.processorNames))
+			for _, name := range gw.processorNames {
+				if ratio, ok := gw.sampleWindows[name].correctness(); ok {
+					samples = append(samples, metricSample{Labels: map[string]string{"processor": name}, Value: ratio})
+				}
+			}
+			return samples
+		},
+	})
+}
+
+// add registra uma conferência conclusiva, descartando a mais antiga da janela.
+func (w *sampleWindow) add(correct bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.filled && w.correct[w.next] {
+		w.hits--
+	}
+	w.correct[w.next] = correct
+	if correct {
+		w.hits++
+	}
+	w.next++
+	if w.next == len(w.correct) {
+		w.next, w.filled = 0, true
+	}
+}
+
+// correctness retorna a taxa de acerto da janela; false sem nenhuma conferência.
+func (w *sampleWindow) correctness() (float64, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := w.next
+	if w.filled {
+		n = len(w.correct)
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return float64(w.hits) / float64(n), true
+}
+
+// verifySample confere, se sorteado, o pagamento aceito pelo processor. Roda
+// depois da contagem, limitada ao timeout de uma tentativa.
+func (gw *gateway) verifySample(ctx context.Context, processor string, correlationID string, amount float64) {
+	window := gw.sampleWindows[processor]
+	if window == nil || rand.Float64() >= gw.verifySampleRate || gw.loadShed(shedVerifySample) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), gw.currentConfig().HTTP.AttemptTimeout.Std())
+	defer cancel()
+	payment, err := gw.processorClients[processor].GetPayment(ctx, correlationID)
+	switch {
+	case err == nil && sameCents(payment.Amount, amount):
+		window.results[sampleFound].Add(1)
+		window.add(true)
+	case err == nil:
+		window.results[sampleMismatch].Add(1)
+		window.add(false)
+		gw.logf(ctx, "Aviso: pagamento %s aceito pelo %s com %.2f, registrado com %.2f", correlationID, processor, amount, payment.Amount)
+	case errors.Is(err, pp.ErrNotFound):
+		window.results[sampleNotFound].Add(1)
+		window.add(false)
+		gw.logf(ctx, "Aviso: pagamento %s aceito pelo %s não foi encontrado nele", correlationID, processor)
+	default:
+		window.results[sampleError].Add(1)
+		gw.logf(ctx, "Erro ao conferir por amostragem %s no %s: %v", correlationID, processor, err)
+	}
+}
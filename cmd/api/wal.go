@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	json "github.com/goccy/go-json"
+
+	"rinha-backend-2025/internal/storage"
+)
+
+// Write-ahead log dos pagamentos ainda não enviados ao storage (COUNTER_WAL_DIR).
+// Cada pagamento confirmado vira uma linha JSON no segmento atual; a cada flush o
+// segmento é trocado e, quando o storage confirma, os segmentos do lote são
+// apagados. Na subida, os segmentos que sobraram voltam para o próximo flush:
+// a contagem é pelo menos uma vez, mesmo com o Redis lento no encerramento.
+
+const walSegmentPrefix = "payments-"
+const walSegmentSuffix = ".wal"
+
+// walState é o estado do WAL
+type walState struct {
+	// Nil quando o WAL está desativado
+	counterWAL *paymentWAL
+}
+
+// paymentWAL é usado só pelos contadores: file, seq e buf com pendingCountersMux,
+// carried com flushMux.
+type paymentWAL struct {
+	dir  string
+	file *os.File
+	seq  int
+	buf  []byte
+
+	// Segmentos fechados com pagamentos que o storage ainda não confirmou
+	carried []string
+}
+
+// openCounterWAL abre o WAL em dir e devolve aos contadores pendentes os
+// pagamentos deixados pela execução anterior. dir vazio desativa o WAL.
+func (gw *gateway) openCounterWAL(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("erro ao criar %s: %w", dir, err)
+	}
+
+	segments, lastSeq, err := listWALSegments(dir)
+	if err != nil {
+		return err
+	}
+
+	wal := &paymentWAL{dir: dir, seq: lastSeq, carried: segments}
+	if err := wal.openNext(); err != nil {
+		return err
+	}
+
+	replayed, stale := 0, 0
+	for _, path := range segments {
+		records, err := gw.readWALSegment(path)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			// Gravado antes de um purge feito enquanto a instância estava parada
+			if record.Epoch != gw.currentEpoch() {
+				stale++
+				continue
+			}
+			gw.addPendingPayment(record)
+			replayed++
+		}
+	}
+	if replayed > 0 {
+		log.Printf("WAL: %d pagamentos de %d segmentos reenviados ao storage", replayed, len(segments))
+	}
+	if stale > 0 {
+		gw.stalePayments.Add(int64(stale))
+		log.Printf("WAL: %d pagamentos de uma época anterior ao último purge descartados", stale)
+	}
+
+	gw.counterWAL = wal
+	return nil
+}
+
+// listWALSegments retorna os segmentos em ordem e o maior número de sequência.
+func listWALSegments(dir string) ([]string, int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, fmt.Errorf("erro ao listar %s: %w", dir, err)
+	}
+
+	type segment struct {
+		path string
+		seq  int
+	}
+	var segments []segment
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, walSegmentPrefix) || !strings.HasSuffix(name, walSegmentSuffix) {
+			continue
+		}
+		seq, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, walSegmentPrefix), walSegmentSuffix))
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{filepath.Join(dir, name), seq})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].seq < segments[j].seq })
+
+	paths := make([]string, len(segments))
+	lastSeq := 0
+	for i, s := range segments {
+		paths[i] = s.path
+		lastSeq = s.seq
+	}
+	return paths, lastSeq, nil
+}
+
+// readWALSegment lê os pagamentos de um segmento. Uma linha incompleta (queda
+// no meio da escrita) é ignorada.
+func (gw *gateway) readWALSegment(path string) ([]storage.Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler %s: %w", path, err)
+	}
+
+	var records []storage.Record
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record walRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			log.Printf("WAL: linha inválida ignorada em %s: %v", path, err)
+			continue
+		}
+		// Linhas anteriores à época valem para a atual
+		record.Record.Epoch = gw.currentEpoch()
+		if record.Epoch != nil {
+			record.Record.Epoch = *record.Epoch
+		}
+		records = append(records, record.Record)
+	}
+	return records, scanner.Err()
+}
+
+func (w *paymentWAL) segmentPath(seq int) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%s%08d%s", walSegmentPrefix, seq, walSegmentSuffix))
+}
+
+func (w *paymentWAL) openNext() error {
+	w.seq++
+	file, err := os.OpenFile(w.segmentPath(w.seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir segmento do WAL: %w", err)
+	}
+	w.file = file
+	return nil
+}
+
+// append grava o pagamento no segmento atual. Sem fsync: sobrevive à queda do
+// processo, não à da máquina.
+func (w *paymentWAL) append(record storage.Record) {
+	if w.file == nil {
+		return
+	}
+	w.buf = appendRecordJSON(w.buf[:0], record)
+	w.buf = append(w.buf, '\n')
+	if _, err := w.file.Write(w.buf); err != nil {
+		log.Printf("WAL: erro ao gravar %s: %v", record.CorrelationID, err)
+	}
+}
+
+// rotate fecha o segmento atual e retorna todos os que cobrem os pagamentos
+// pendentes neste momento.
+func (w *paymentWAL) rotate() []string {
+	segments := w.carried
+	w.carried = nil
+	if w.file != nil {
+		w.file.Close()
+		segments = append(segments, w.file.Name())
+		w.file = nil
+	}
+	if err := w.openNext(); err != nil {
+		log.Printf("WAL: %v", err)
+	}
+	return segments
+}
+
+// release apaga os segmentos cujos pagamentos o storage confirmou.
+func (w *paymentWAL) release(segments []string) {
+	for _, path := range segments {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("WAL: erro ao apagar %s: %v", path, err)
+		}
+	}
+}
+
+// carry guarda os segmentos de um flush que falhou: os pagamentos voltaram para
+// os pendentes e seguem no próximo lote.
+func (w *paymentWAL) carry(segments []string) {
+	w.carried = append(segments, w.carried...)
+}
+
+// reset descarta todos os segmentos (purge).
+func (w *paymentWAL) reset() {
+	w.release(w.rotate())
+}
+
+// close encerra o segmento atual. Com keep, grava em disco para o replay na
+// próxima subida; sem keep (nada pendente), apaga o segmento.
+func (w *paymentWAL) close(keep bool) {
+	if w.file == nil {
+		return
+	}
+	file := w.file
+	w.file = nil
+	if !keep {
+		file.Close()
+		w.release([]string{file.Name()})
+		return
+	}
+	if err := file.Sync(); err != nil {
+		log.Printf("WAL: erro ao sincronizar %s: %v", file.Name(), err)
+	}
+	file.Close()
+}
+
+// walRecord é a linha do WAL: o storage.Record e a época, que o registro não
+// leva no JSON.
+type walRecord struct {
+	storage.Record
+	Epoch *int64 `json:"epoch,omitempty"`
+}
+
+// appendRecordJSON segue o formato do encoding/json para walRecord.
+func appendRecordJSON(buf []byte, r storage.Record) []byte {
+	buf = append(buf, `{"correlationId":`...)
+	buf = appendJSONString(buf, r.CorrelationID)
+	buf = append(buf, `,"amount":`...)
+	buf = strconv.AppendFloat(buf, r.Amount, 'f', -1, 64)
+	buf = append(buf, `,"processor":`...)
+	buf = appendJSONString(buf, r.Processor)
+	buf = append(buf, `,"requestedAt":"`...)
+	buf = r.RequestedAt.AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, '"')
+	if r.Currency != "" {
+		buf = append(buf, `,"currency":`...)
+		buf = appendJSONString(buf, r.Currency)
+	}
+	if len(r.Metadata) > 0 {
+		buf = append(buf, `,"metadata":`...)
+		buf = append(buf, r.Metadata...)
+	}
+	buf = append(buf, `,"epoch":`...)
+	buf = strconv.AppendInt(buf, r.Epoch, 10)
+	return append(buf, '}')
+}
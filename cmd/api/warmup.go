@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+	pp "rinha-backend-2025/internal/processor"
+)
+
+// Consultado nos processors só para abrir conexões: não existe, a resposta é 404
+const warmupCorrelationID = "00000000-0000-0000-0000-000000000000"
+
+// newProcessorTransport mantém ociosas pelo menos as conexões aquecidas; o
+// padrão do net/http guarda apenas 2 por host.
+func newProcessorTransport(cfg config.WarmupConfig) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = max(cfg.Connections, http.DefaultMaxIdleConnsPerHost)
+	return transport
+}
+
+// warmProcessors aquece todos os processors em paralelo, no máximo por um
+// attempt timeout, para não atrasar a inicialização com um processor fora do ar.
+func (gw *gateway) warmProcessors(cfg config.WarmupConfig) {
+	if cfg.Connections == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, processor := range gw.processorNames {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gw.warmProcessor(processor, cfg.Connections)
+		}()
+	}
+	wg.Wait()
+}
+
+// warmProcessor faz connections requisições simultâneas ao processor, o que
+// deixa o mesmo número de conexões keep-alive no pool.
+func (gw *gateway) warmProcessor(processor string, connections int) {
+	if connections == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gw.currentConfig().HTTP.AttemptTimeout.Std())
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	var ok atomic.Int64
+	for i := 0; i < connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := gw.processorClients[processor].GetPayment(ctx, warmupCorrelationID)
+			if err == nil || errors.Is(err, pp.ErrNotFound) {
+				ok.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	log.Printf("Conexões com %s aquecidas: %d/%d em %v", processor, ok.Load(), connections, time.Since(start))
+}
+
+// startWarmupLoop refaz o aquecimento periodicamente nos processors saudáveis,
+// antes que o transport feche as conexões ociosas.
+func (gw *gateway) startWarmupLoop(cfg config.WarmupConfig) {
+	if cfg.Connections == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.PingInterval.Std())
+		defer ticker.Stop()
+
+		for range ticker.C {
+			for _, processor := range gw.processorNames {
+				if !gw.processorFailing(processor, gw.healthMonitor.Get(context.Background(), processor)) {
+					gw.warmProcessor(processor, cfg.Connections)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/retry"
+)
+
+// Assinatura HMAC-SHA256 do corpo com WEBHOOK_SECRET, no formato "sha256=<hex>"
+const webhookSignatureHeader = "X-Webhook-Signature"
+
+// Situações informadas na notificação
+const (
+	webhookProcessed = "processed"
+	// Foi para a DLQ; se o reprocessamento der certo, chega uma nova notificação "processed"
+	webhookFailed = "failed"
+)
+
+// PaymentNotification é o corpo enviado ao callbackUrl quando o processamento termina.
+type PaymentNotification struct {
+	CorrelationID string    `json:"correlationId"`
+	Status        string    `json:"status"`
+	Processor     string    `json:"processor,omitempty"`
+	ProcessedAt   time.Time `json:"processedAt"`
+}
+
+func (gw *gateway) registerWebhook() {
+	gw.onPaymentEvent("webhook", func(e BusEvent) {
+		switch e.Kind {
+		case PaymentSettled:
+			gw.notifyPayment(e.Payment, webhookProcessed, e.Processor)
+		case PaymentFailed:
+			gw.notifyPayment(e.Payment, webhookFailed, "")
+		}
+	})
+
+	gw.registerMetric(metric{
+		Name: "webhook_delivered_total",
+		Help: "Notificações de pagamento entregues.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.webhookDelivered.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "webhook_failures_total",
+		Help: "Notificações abandonadas depois de esgotar as tentativas.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.webhookFailures.Load())}}
+		},
+	})
+	gw.registerMetric(metric{
+		Name: "webhook_dropped_total",
+		Help: "Notificações descartadas com a fila de entrega cheia.",
+		Type: "counter",
+		Collect: func() []metricSample {
+			return []metricSample{{Value: float64(gw.webhookDropped.Load())}}
+		},
+	})
+}
+
+type webhookDelivery struct {
+	URL          string
+	Notification PaymentNotification
+}
+
+// webhookState é o estado das notificações
+type webhookState struct {
+	webhookConfig config.WebhookConfig
+	webhookClient *http.Client
+	webhookRetry  retry.Policy
+	webhookQueue  chan webhookDelivery
+	webhookWg     sync.WaitGroup
+	// Cancelado quando o prazo do encerramento acaba, interrompendo as esperas do retry
+	webhookCtx    context.Context
+	webhookCancel context.CancelFunc
+
+	// Protege o envio na fila contra o fechamento no encerramento
+	webhookCloseMux sync.RWMutex
+	webhookClosed   bool
+
+	webhookDelivered atomic.Int64
+	webhookFailures  atomic.Int64
+	webhookDropped   atomic.Int64
+}
+
+func (gw *gateway) startWebhookWorkers(cfg config.WebhookConfig) error {
+	retryOn, err := retry.ParseStatusList(cfg.RetryOnStatus)
+	if err != nil {
+		return err
+	}
+	gw.webhookConfig = cfg
+	gw.webhookRetry = retry.Policy{
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   cfg.BaseDelay.Std(),
+		MaxDelay:    cfg.MaxDelay.Std(),
+		Jitter:      0.2,
+		RetryOn:     retryOn,
+	}
+	gw.webhookClient = &http.Client{
+		Timeout: cfg.Timeout.Std(),
+		// Um redirect levaria a notificação para fora de WEBHOOK_ALLOWED_HOSTS
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	gw.webhookQueue = make(chan webhookDelivery, cfg.QueueSize)
+	gw.webhookCtx, gw.webhookCancel = context.WithCancel(context.Background())
+
+	for i := 0; i < cfg.Workers; i++ {
+		gw.webhookWg.Add(1)
+		go func() {
+			defer gw.webhookWg.Done()
+			for delivery := range gw.webhookQueue {
+				gw.deliverWebhook(gw.webhookCtx, delivery)
+			}
+		}()
+	}
+
+	if cfg.Secret == "" {
+		log.Printf("Aviso: WEBHOOK_SECRET vazio, notificações enviadas sem assinatura")
+	}
+
+	return nil
+}
+
+// callbackAllowed diz se o callbackUrl é http(s) e aponta para um host de
+// WEBHOOK_ALLOWED_HOSTS; sem a lista nenhum é aceito.
+func (gw *gateway) callbackAllowed(target string) bool {
+	u, err := url.Parse(target)
+	if err != nil || u.Hostname() == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	for _, allowed := range gw.webhookConfig.AllowedHosts {
+		allowedHost, allowedPort, err := net.SplitHostPort(allowed)
+		if err != nil {
+			allowedHost, allowedPort = strings.Trim(allowed, "[]"), ""
+		}
+		if strings.EqualFold(allowedHost, host) && (allowedPort == "" || allowedPort == port) {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyPayment agenda a notificação para o callbackUrl do pagamento ou, sem ele,
+// para o WEBHOOK_URL global. Nunca bloqueia o processamento: com a fila cheia a
+// notificação é descartada.
+func (gw *gateway) notifyPayment(req PaymentRequest, status, processor string) {
+	target := req.CallbackURL
+	// Aceito antes de uma mudança em WEBHOOK_ALLOWED_HOSTS, ou vindo do outbox e da DLQ
+	if target != "" && !gw.callbackAllowed(target) {
+		log.Printf("callbackUrl de %s fora de WEBHOOK_ALLOWED_HOSTS, ignorado", req.CorrelationID)
+		target = ""
+	}
+	if target == "" {
+		target = gw.webhookConfig.URL
+	}
+	if target == "" || gw.webhookQueue == nil {
+		return
+	}
+
+	delivery := webhookDelivery{
+		URL: target,
+		Notification: PaymentNotification{
+			CorrelationID: req.CorrelationID,
+			Status:        status,
+			Processor:     processor,
+			ProcessedAt:   gw.appClock.Now().UTC(),
+		},
+	}
+
+	gw.webhookCloseMux.RLock()
+	defer gw.webhookCloseMux.RUnlock()
+	if gw.webhookClosed {
+		return
+	}
+
+	select {
+	case gw.webhookQueue <- delivery:
+	default:
+		gw.webhookDropped.Add(1)
+		log.Printf("Fila de notificações cheia, descartando notificação de %s", req.CorrelationID)
+	}
+}
+
+// deliverWebhook tenta entregar a notificação conforme a política própria dos
+// webhooks (WEBHOOK_*), independente da usada com os processors. As esperas
+// entre tentativas terminam com o ctx.
+func (gw *gateway) deliverWebhook(ctx context.Context, delivery webhookDelivery) {
+	body, err := json.Marshal(delivery.Notification)
+	if err != nil {
+		log.Printf("Erro ao serializar notificação de %s: %v", delivery.Notification.CorrelationID, err)
+		return
+	}
+
+	for attempt := 0; attempt < gw.webhookRetry.MaxAttempts; attempt++ {
+		if attempt > 0 && !gw.waitWebhookRetry(ctx, gw.webhookRetry.Delay(attempt)) {
+			break
+		}
+
+		status, err := gw.postWebhook(ctx, delivery.URL, body)
+		if err == nil && status >= 200 && status < 300 {
+			gw.webhookDelivered.Add(1)
+			return
+		}
+		if err != nil {
+			log.Printf("Erro na tentativa %d de notificar %s: %v", attempt+1, delivery.URL, err)
+		} else {
+			log.Printf("Status %d na tentativa %d de notificar %s", status, attempt+1, delivery.URL)
+		}
+		if !gw.webhookRetry.ShouldRetry(status) {
+			break
+		}
+	}
+
+	gw.webhookFailures.Add(1)
+	log.Printf("Notificação de %s para %s abandonada", delivery.Notification.CorrelationID, delivery.URL)
+}
+
+// waitWebhookRetry aguarda o backoff no relógio da aplicação; false se o ctx
+// terminar antes.
+func (gw *gateway) waitWebhookRetry(ctx context.Context, delay time.Duration) bool {
+	timer := gw.appClock.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// postWebhook faz uma única tentativa e retorna o status HTTP (0 em erro de rede).
+func (gw *gateway) postWebhook(ctx context.Context, target string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", jsonContentType)
+	if gw.webhookConfig.Secret != "" {
+		req.Header.Set(webhookSignatureHeader, signWebhook(gw.webhookConfig.Secret, body))
+	}
+
+	resp, err := gw.webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return fmt.Sprintf("sha256=%s", hex.EncodeToString(mac.Sum(nil)))
+}
+
+// stopWebhookWorkers fecha a fila e aguarda as entregas em andamento, incluindo
+// as esperas do retry, até o fim do prazo.
+func (gw *gateway) stopWebhookWorkers(ctx context.Context) {
+	if gw.webhookQueue == nil {
+		return
+	}
+	gw.webhookCloseMux.Lock()
+	gw.webhookClosed = true
+	close(gw.webhookQueue)
+	gw.webhookCloseMux.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		gw.webhookWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Tempo esgotado aguardando notificações: %d ainda na fila", len(gw.webhookQueue))
+		gw.webhookCancel()
+	}
+}
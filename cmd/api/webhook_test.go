@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/retry"
+)
+
+func TestCallbackAllowed(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		target  string
+		want    bool
+	}{
+		{nil, "http://hooks.local/pagamentos", false},
+		{[]string{"hooks.local"}, "http://hooks.local/pagamentos", true},
+		{[]string{"hooks.local"}, "https://HOOKS.local:9000/pagamentos", true},
+		{[]string{"hooks.local"}, "http://hooks.local.evil.com/", false},
+		{[]string{"hooks.local"}, "http://hooks.local@10.0.0.1/", false},
+		{[]string{"hooks.local"}, "http://169.254.169.254/latest/meta-data", false},
+		{[]string{"hooks.local:8443"}, "https://hooks.local:8443/", true},
+		{[]string{"hooks.local:8443"}, "https://hooks.local:9000/", false},
+		{[]string{"hooks.local:443"}, "https://hooks.local/", true},
+		{[]string{"hooks.local:443"}, "http://hooks.local/", false},
+		{[]string{"[::1]:9000"}, "http://[::1]:9000/", true},
+		{[]string{"::1"}, "http://[::1]:9000/", true},
+		{[]string{"hooks.local"}, "://hooks.local", false},
+		{[]string{"hooks.local"}, "ftp://hooks.local/", false},
+		{[]string{"hooks.local"}, "HTTPS://hooks.local/", true},
+		{[]string{"hooks.local"}, "//hooks.local/pagamentos", false},
+		{[]string{"hooks.local"}, "hooks.local:80/pagamentos", false},
+	}
+
+	gw := newGateway(clock.Real())
+	for _, tt := range tests {
+		gw.webhookConfig = config.WebhookConfig{AllowedHosts: tt.allowed}
+		if got := gw.callbackAllowed(tt.target); got != tt.want {
+			t.Errorf("callbackAllowed(%q) com %v = %v, esperado %v", tt.target, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestServePaymentRejectsCallbackOutsideAllowlist(t *testing.T) {
+	gw := newTestGateway(t, clock.Real())
+	gw.webhookConfig = config.WebhookConfig{AllowedHosts: []string{"hooks.local"}}
+
+	body := `{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":19.9,"callbackUrl":"http://127.0.0.1:6379/"}`
+	r := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	reply := gw.servePayment(httptest.NewRecorder(), r)
+	if reply.status != http.StatusBadRequest || reply.code != errCodeCallbackNotAllowed {
+		t.Fatalf("resposta %d %q, esperado 400 %q", reply.status, reply.code, errCodeCallbackNotAllowed)
+	}
+}
+
+// TestServePaymentRejectsCallbackScheme confere que um host da lista não basta:
+// fora de http(s), o callbackUrl é recusado como corpo inválido.
+func TestServePaymentRejectsCallbackScheme(t *testing.T) {
+	gw := newTestGateway(t, clock.Real())
+	gw.webhookConfig = config.WebhookConfig{AllowedHosts: []string{"hooks.local"}}
+
+	for _, callback := range []string{"ftp://hooks.local/", "//hooks.local/pagamentos", "hooks.local/pagamentos"} {
+		t.Run(callback, func(t *testing.T) {
+			body := `{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":19.9,"callbackUrl":"` + callback + `"}`
+			r := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+
+			reply := gw.servePayment(httptest.NewRecorder(), r)
+			if reply.status != http.StatusUnprocessableEntity || reply.code != errCodeInvalidPayload {
+				t.Fatalf("resposta %d %q, esperado 422 %q", reply.status, reply.code, errCodeInvalidPayload)
+			}
+		})
+	}
+}
+
+func TestDeliverWebhookWaitsOnAppClock(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	gw, fake := newWebhookTestGateway()
+	delivered := gw.webhookDelivered.Load()
+
+	done := make(chan struct{})
+	go func() {
+		gw.deliverWebhook(context.Background(), webhookDelivery{URL: server.URL})
+		close(done)
+	}()
+
+	waitForTimer(t, fake)
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("%d tentativas antes do backoff, esperado 1", got)
+	}
+	fake.Advance(time.Minute)
+	<-done
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("%d tentativas, esperado 2", got)
+	}
+	if gw.webhookDelivered.Load() != delivered+1 {
+		t.Errorf("entrega não contabilizada")
+	}
+}
+
+func TestDeliverWebhookStopsWaitingWhenCanceled(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	gw, fake := newWebhookTestGateway()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		gw.deliverWebhook(ctx, webhookDelivery{URL: server.URL})
+		close(done)
+	}()
+
+	waitForTimer(t, fake)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deliverWebhook não terminou com o contexto cancelado")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("%d tentativas, esperado 1", got)
+	}
+}
+
+// newWebhookTestGateway configura o retry dos webhooks sobre um relógio parado.
+func newWebhookTestGateway() (*gateway, *clock.Fake) {
+	fake := clock.NewFake(time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC))
+	gw := newGateway(fake)
+	gw.webhookRetry = retry.Policy{MaxAttempts: 3, BaseDelay: 10 * time.Second, MaxDelay: 10 * time.Second}
+	gw.webhookClient = &http.Client{Timeout: 5 * time.Second}
+	return gw, fake
+}
+
+// waitForTimer espera o código sob teste criar um timer no relógio parado.
+func waitForTimer(t *testing.T, fake *clock.Fake) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for fake.Pending() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("nenhum timer criado no relógio da aplicação")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
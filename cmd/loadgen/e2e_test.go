@@ -0,0 +1,238 @@
+//go:build e2e
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+
+	pp "rinha-backend-2025/internal/processor"
+)
+
+// Teste de ponta a ponta (go test -tags e2e -timeout 20m ./cmd/loadgen): sobe
+// com o docker compose os processors em memória de internal/processorstub, o
+// Redis, as duas instâncias e o nginx, roda a rampa de carga com falhas
+// induzidas nos processors e, além do resumo, confere que nenhum pagamento
+// aceito se perdeu: os processors têm de somar ao menos os 2xx da carga. Os
+// containers são derrubados no fim, a não ser com E2E_KEEP=1; E2E_VUS e
+// E2E_DURATION ajustam a rampa.
+
+const (
+	// Diretório dos docker-compose*.yml, a raiz do repositório
+	e2eDir = "../.."
+	// Espera máxima pela API e pelos processors depois do compose up
+	e2eReadyTimeout = 3 * time.Minute
+	// Espera mínima antes de conferir: com as falhas, parte dos pagamentos só
+	// chega pela DLQ e pelo outbox, que reconcilia depois de 45s
+	// (OUTBOX_RECONCILE_AFTER)
+	e2eMinSettle = time.Minute
+)
+
+// e2eStep é uma mudança nos processors num ponto da rampa (fração da duração).
+type e2eStep struct {
+	at        float64
+	processor string
+	failure   bool
+	delay     time.Duration
+}
+
+// Roteiro das falhas: default fora, default lento e fallback fora
+var e2eScript = []e2eStep{
+	{at: 0.25, processor: "default", failure: true},
+	{at: 0.50, processor: "default", delay: 1500 * time.Millisecond},
+	{at: 0.75, processor: "default"},
+	{at: 0.75, processor: "fallback", failure: true},
+}
+
+func TestE2E(t *testing.T) {
+	opts := e2eOptions(t)
+	ctx := t.Context()
+
+	// Na ordem de subida: o dos processors cria a rede externa do outro
+	files := []string{"docker-compose-processorstub.yml", "docker-compose.yml"}
+	t.Cleanup(func() {
+		if os.Getenv("E2E_KEEP") == "1" {
+			t.Log("Containers mantidos (E2E_KEEP=1)")
+			return
+		}
+		for i := len(files) - 1; i >= 0; i-- {
+			if err := compose(context.Background(), files[i], "down", "-v"); err != nil {
+				t.Logf("Aviso: erro ao derrubar %s: %v", files[i], err)
+			}
+		}
+	})
+	for _, file := range files {
+		if err := compose(ctx, file, "up", "-d", "--build"); err != nil {
+			t.Fatalf("Erro ao subir %s: %v", file, err)
+		}
+	}
+
+	client := newHTTPClient(opts)
+	if err := waitE2EReady(ctx, client, opts); err != nil {
+		t.Fatalf("Ambiente não ficou pronto: %v", err)
+	}
+
+	opts.duringRamp = func(ctx context.Context, client *http.Client) {
+		runFailureScript(ctx, t, client, opts)
+	}
+	opts.verify = func(ctx context.Context, client *http.Client, from, to time.Time, accepted int) int {
+		return checkNoLoss(ctx, t, client, opts, from, to, accepted)
+	}
+	if code := run(ctx, opts); code != 0 {
+		t.Fatalf("carga terminou com status %d", code)
+	}
+}
+
+// e2eOptions são os padrões do loadgen apontando para o docker-compose.
+func e2eOptions(t *testing.T) options {
+	t.Helper()
+	opts := options{
+		target:     "http://localhost:9999",
+		processors: map[string]string{"default": "http://localhost:8001", "fallback": "http://localhost:8002"},
+		token:      "123",
+		vus:        550,
+		duration:   60 * time.Second,
+		amount:     19.90,
+		timeout:    10 * time.Second,
+		settle:     e2eMinSettle,
+		tolerance:  0.005,
+	}
+	if v := os.Getenv("E2E_VUS"); v != "" {
+		vus, err := strconv.Atoi(v)
+		if err != nil || vus < 1 {
+			t.Fatalf("E2E_VUS inválido: %q", v)
+		}
+		opts.vus = vus
+	}
+	if v := os.Getenv("E2E_DURATION"); v != "" {
+		duration, err := time.ParseDuration(v)
+		if err != nil || duration <= 0 {
+			t.Fatalf("E2E_DURATION inválido: %q", v)
+		}
+		opts.duration = duration
+	}
+	return opts
+}
+
+func compose(ctx context.Context, file string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "docker", append([]string{"compose", "-f", file}, args...)...)
+	cmd.Dir = e2eDir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// waitE2EReady aguarda a API, pelo nginx, e o /admin dos dois processors.
+func waitE2EReady(ctx context.Context, client *http.Client, opts options) error {
+	ctx, cancel := context.WithTimeout(ctx, e2eReadyTimeout)
+	defer cancel()
+
+	targets := []string{opts.target + "/payments-summary"}
+	for _, name := range []string{"default", "fallback"} {
+		targets = append(targets, opts.processors[name]+"/admin/payments-summary")
+	}
+	for _, target := range targets {
+		for {
+			var body any
+			err := getJSON(ctx, client, target, opts.token, &body)
+			if err == nil {
+				break
+			}
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return fmt.Errorf("%s: %w", target, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runFailureScript aplica o roteiro durante a rampa e, no fim dela, volta os
+// processors ao normal para a API drenar a fila e a DLQ.
+func runFailureScript(ctx context.Context, t *testing.T, client *http.Client, opts options) {
+	defer resetProcessors(context.WithoutCancel(ctx), t, client, opts)
+
+	start := time.Now()
+	for _, step := range e2eScript {
+		wait := time.Until(start.Add(time.Duration(step.at * float64(opts.duration))))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+		t.Logf("Falha induzida: %s failure=%t delay=%v", step.processor, step.failure, step.delay)
+		if err := configureProcessor(ctx, client, opts, step.processor, step.failure, step.delay); err != nil {
+			t.Logf("Aviso: erro ao configurar o %s: %v", step.processor, err)
+		}
+	}
+	<-ctx.Done()
+}
+
+func resetProcessors(ctx context.Context, t *testing.T, client *http.Client, opts options) {
+	for _, name := range []string{"default", "fallback"} {
+		if err := configureProcessor(ctx, client, opts, name, false, 0); err != nil {
+			t.Logf("Aviso: erro ao restaurar o %s: %v", name, err)
+		}
+	}
+}
+
+// configureProcessor usa o /admin/configurations, o mesmo do processor oficial.
+func configureProcessor(ctx context.Context, client *http.Client, opts options, name string, failure bool, delay time.Duration) error {
+	base := opts.processors[name] + "/admin/configurations"
+	if err := putJSON(ctx, client, base+"/failure", opts.token, fmt.Sprintf(`{"failure":%t}`, failure)); err != nil {
+		return err
+	}
+	return putJSON(ctx, client, base+"/delay", opts.token, fmt.Sprintf(`{"delay":%d}`, delay.Milliseconds()))
+}
+
+func putJSON(ctx context.Context, client *http.Client, target, token, body string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Rinha-Token", token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: status %d", target, resp.StatusCode)
+	}
+	return nil
+}
+
+// checkNoLoss confere que os processors registraram ao menos os pagamentos
+// aceitos pela API em [from, to]. A mais são os que a API aceitou depois do
+// timeout do cliente, contados como erro na carga.
+func checkNoLoss(ctx context.Context, t *testing.T, client *http.Client, opts options, from, to time.Time, accepted int) int {
+	processed := 0
+	for _, name := range []string{"default", "fallback"} {
+		processor := pp.NewHTTPClient(opts.processors[name], client)
+		processor.SetAdminToken(opts.token)
+		admin, err := processor.PaymentsSummary(ctx, from, to)
+		if err != nil {
+			t.Errorf("Erro ao consultar o resumo do %s: %v", name, err)
+			return 1
+		}
+		processed += admin.TotalRequests
+	}
+
+	t.Logf("Aceitos (2xx) %d, nos processors %d", accepted, processed)
+	if processed < accepted {
+		t.Errorf("%d pagamentos aceitos perdidos", accepted-processed)
+		return 1
+	}
+	return 0
+}
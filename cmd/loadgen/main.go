@@ -0,0 +1,405 @@
+// Comando loadgen reproduz em Go o perfil de carga do k6 da Rinha: VUs subindo
+// em rampa até -vus, cada um enviando POST /payments com correlationId UUID e
+// amount fixo, e no fim compara o /payments-summary da API com o
+// /admin/payments-summary dos processors no mesmo período. Com -instances, confere
+// também que cada instância, consultada sem o nginx, responde o mesmo resumo. Sai
+// com status 1 quando há inconsistência. O teste de ponta a ponta (e2e_test.go,
+// go test -tags e2e) usa a mesma rampa; os benchmarks ficam nos _test.go (go test
+// -bench . ./...).
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	json "github.com/goccy/go-json"
+	"github.com/google/uuid"
+
+	pp "rinha-backend-2025/internal/processor"
+)
+
+type options struct {
+	target      string
+	processors  map[string]string
+	token       string
+	vus         int
+	duration    time.Duration
+	amount      float64
+	timeout     time.Duration
+	settle      time.Duration
+	tolerance   float64
+	thinkTime   time.Duration
+	skipSummary bool
+	// URLs de cada instância da API, para comparar os resumos entre elas
+	instances []string
+	// Roda junto com a rampa, até o fim dela (o roteiro de falhas do teste e2e)
+	duringRamp func(ctx context.Context, client *http.Client)
+	// Conferência extra no fim, com o período e os 2xx da carga; retorna o
+	// status de saída
+	verify func(ctx context.Context, client *http.Client, from, to time.Time, accepted int) int
+}
+
+func main() {
+	var opts options
+	var defaultURL, fallbackURL string
+	flag.StringVar(&opts.target, "url", "http://localhost:9999", "URL base da API")
+	flag.StringVar(&defaultURL, "default-url", "http://localhost:8001", "URL do processor default")
+	flag.StringVar(&fallbackURL, "fallback-url", "http://localhost:8002", "URL do processor fallback")
+	flag.StringVar(&opts.token, "token", "123", "X-Rinha-Token dos endpoints /admin dos processors")
+	flag.IntVar(&opts.vus, "vus", 550, "VUs ao fim da rampa")
+	flag.DurationVar(&opts.duration, "duration", 60*time.Second, "duração da rampa")
+	flag.Float64Var(&opts.amount, "amount", 19.90, "amount de cada pagamento")
+	flag.DurationVar(&opts.timeout, "timeout", 10*time.Second, "timeout de cada requisição")
+	flag.DurationVar(&opts.settle, "settle", 5*time.Second, "espera antes de conferir o resumo, para a API drenar a fila")
+	flag.Float64Var(&opts.tolerance, "tolerance", 0.005, "diferença aceita entre os totalAmount")
+	flag.DurationVar(&opts.thinkTime, "think", 0, "pausa de cada VU entre um pagamento e o próximo")
+	flag.BoolVar(&opts.skipSummary, "skip-summary", false, "não confere o resumo no fim (processors sem /admin)")
+	instances := flag.String("instances", "", "URLs das instâncias da API separadas por vírgula, comparadas entre si no fim")
+	flag.Parse()
+
+	for _, u := range strings.Split(*instances, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			opts.instances = append(opts.instances, strings.TrimRight(u, "/"))
+		}
+	}
+
+	opts.processors = map[string]string{"default": defaultURL, "fallback": fallbackURL}
+	if opts.vus < 1 || opts.duration <= 0 {
+		log.Fatal("-vus deve ser ao menos 1 e -duration positiva")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	os.Exit(run(ctx, opts))
+}
+
+// client com pool grande o bastante para todos os VUs
+func newHTTPClient(opts options) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = opts.vus
+	transport.MaxIdleConnsPerHost = opts.vus
+	return &http.Client{Timeout: opts.timeout, Transport: transport}
+}
+
+func run(ctx context.Context, opts options) int {
+	client := newHTTPClient(opts)
+
+	if !opts.skipSummary {
+		// Começar do zero, como o k6 da Rinha
+		if err := purge(ctx, client, opts); err != nil {
+			log.Printf("Aviso: purge falhou (%v); a conferência usa só o período do teste", err)
+		}
+	}
+
+	stats := newLoadStats()
+	start := time.Now().UTC()
+	log.Printf("Rampa de 1 a %d VUs em %v contra %s", opts.vus, opts.duration, opts.target)
+
+	runCtx, cancel := context.WithTimeout(ctx, opts.duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var active atomic.Int64
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	startVU := func() {
+		active.Add(1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runVU(runCtx, client, opts, stats)
+		}()
+	}
+	startVU()
+	if opts.duringRamp != nil {
+		go opts.duringRamp(runCtx, client)
+	}
+
+	lastReport := time.Now()
+ramp:
+	for {
+		select {
+		case <-runCtx.Done():
+			break ramp
+		case now := <-ticker.C:
+			// Rampa linear, como o ramping-vus do k6
+			elapsed := now.Sub(start)
+			want := 1 + int64(float64(opts.vus-1)*elapsed.Seconds()/opts.duration.Seconds())
+			for active.Load() < min(want, int64(opts.vus)) {
+				startVU()
+			}
+			if now.Sub(lastReport) >= 5*time.Second {
+				lastReport = now
+				log.Printf("%5.0fs  VUs %-4d  %s", elapsed.Seconds(), active.Load(), stats.progress())
+			}
+		}
+	}
+	wg.Wait()
+	end := time.Now().UTC()
+
+	stats.report(end.Sub(start))
+	if opts.skipSummary || ctx.Err() != nil {
+		return 0
+	}
+
+	log.Printf("Aguardando %v antes de conferir o resumo", opts.settle)
+	select {
+	case <-time.After(opts.settle):
+	case <-ctx.Done():
+		return 0
+	}
+	exit := checkSummary(ctx, client, opts, start, end.Add(opts.settle))
+	if opts.verify != nil && opts.verify(ctx, client, start, end.Add(opts.settle), stats.accepted()) != 0 {
+		exit = 1
+	}
+	return exit
+}
+
+func runVU(ctx context.Context, client *http.Client, opts options, stats *loadStats) {
+	for ctx.Err() == nil {
+		body := fmt.Sprintf(`{"correlationId":%q,"amount":%s}`, uuid.NewString(),
+			strconv.FormatFloat(opts.amount, 'f', -1, 64))
+
+		// O envio em andamento não é cortado pelo fim da rampa
+		reqCtx := context.WithoutCancel(ctx)
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, opts.target+"/payments", bytes.NewBufferString(body))
+		if err != nil {
+			log.Fatalf("Requisição inválida: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		begin := time.Now()
+		resp, err := client.Do(req)
+		latency := time.Since(begin)
+		status := 0
+		if err == nil {
+			status = resp.StatusCode
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		stats.record(status, latency)
+
+		if opts.thinkTime > 0 {
+			select {
+			case <-time.After(opts.thinkTime):
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+// loadStats acumula status e latências de todos os VUs.
+type loadStats struct {
+	mu        sync.Mutex
+	statuses  map[int]int
+	latencies []time.Duration
+}
+
+func newLoadStats() *loadStats {
+	return &loadStats{statuses: make(map[int]int), latencies: make([]time.Duration, 0, 1<<16)}
+}
+
+func (s *loadStats) record(status int, latency time.Duration) {
+	s.mu.Lock()
+	s.statuses[status]++
+	s.latencies = append(s.latencies, latency)
+	s.mu.Unlock()
+}
+
+func (s *loadStats) progress() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("requisições %-7d  2xx %d", len(s.latencies), s.acceptedLocked())
+}
+
+// accepted retorna as respostas 2xx: os pagamentos que a API aceitou.
+func (s *loadStats) accepted() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.acceptedLocked()
+}
+
+func (s *loadStats) acceptedLocked() int {
+	ok := 0
+	for status, n := range s.statuses {
+		if status >= 200 && status < 300 {
+			ok += n
+		}
+	}
+	return ok
+}
+
+func (s *loadStats) report(elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sorted := append([]time.Duration(nil), s.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	quantile := func(q float64) time.Duration {
+		if len(sorted) == 0 {
+			return 0
+		}
+		return sorted[min(int(q*float64(len(sorted))), len(sorted)-1)]
+	}
+
+	codes := make([]int, 0, len(s.statuses))
+	for status := range s.statuses {
+		codes = append(codes, status)
+	}
+	sort.Ints(codes)
+
+	fmt.Printf("\nRequisições: %d em %v (%.0f/s)\n", len(sorted), elapsed.Round(time.Millisecond),
+		float64(len(sorted))/elapsed.Seconds())
+	for _, status := range codes {
+		label := strconv.Itoa(status)
+		if status == 0 {
+			label = "erro"
+		}
+		fmt.Printf("  %-5s %d\n", label, s.statuses[status])
+	}
+	fmt.Printf("Latência: p50 %v  p90 %v  p99 %v  máx %v\n",
+		quantile(0.50), quantile(0.90), quantile(0.99), quantile(1))
+}
+
+func purge(ctx context.Context, client *http.Client, opts options) error {
+	targets := []string{opts.target + "/purge-payments"}
+	for _, base := range opts.processors {
+		targets = append(targets, base+"/admin/purge-payments")
+	}
+	var errs []error
+	for _, target := range targets {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("X-Rinha-Token", opts.token)
+		resp, err := client.Do(req)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			errs = append(errs, fmt.Errorf("%s: status %d", target, resp.StatusCode))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type summaryEntry struct {
+	TotalRequests int     `json:"totalRequests"`
+	TotalAmount   float64 `json:"totalAmount"`
+}
+
+// checkSummary compara a API com os processors em [from, to] e imprime a taxa e
+// o líquido de cada processor. Retorna o status de saída.
+func checkSummary(ctx context.Context, client *http.Client, opts options, from, to time.Time) int {
+	query := url.Values{}
+	query.Set("from", from.Format(pp.RequestedAtLayout))
+	query.Set("to", to.Format(pp.RequestedAtLayout))
+
+	var api map[string]summaryEntry
+	if err := getJSON(ctx, client, opts.target+"/payments-summary?"+query.Encode(), "", &api); err != nil {
+		log.Printf("Erro ao consultar o resumo da API: %v", err)
+		return 1
+	}
+
+	fmt.Println("\nResumo (API x processors):")
+	exit := 0
+	var net float64
+	for _, name := range []string{"default", "fallback"} {
+		processor := pp.NewHTTPClient(opts.processors[name], client)
+		processor.SetAdminToken(opts.token)
+		admin, err := processor.PaymentsSummary(ctx, from, to)
+		if err != nil {
+			log.Printf("Erro ao consultar o resumo do %s: %v", name, err)
+			exit = 1
+			continue
+		}
+
+		ours := api[name]
+		consistent := ours.TotalRequests == admin.TotalRequests &&
+			math.Abs(ours.TotalAmount-admin.TotalAmount) <= opts.tolerance
+		mark := "ok"
+		if !consistent {
+			mark = "INCONSISTENTE"
+			exit = 1
+		}
+		fmt.Printf("  %-8s API %d / %.2f   processor %d / %.2f   taxa %.2f   %s\n", name,
+			ours.TotalRequests, ours.TotalAmount, admin.TotalRequests, admin.TotalAmount, admin.TotalFee, mark)
+		net += admin.TotalAmount - admin.TotalFee
+	}
+	fmt.Printf("Líquido: %.2f\n", net)
+
+	if !instancesAgree(ctx, client, opts, query, api) {
+		exit = 1
+	}
+	return exit
+}
+
+// instancesAgree compara o resumo de cada instância com o da API: com os
+// contadores em memória, uma instância que não somasse as outras responderia
+// só a própria parte.
+func instancesAgree(ctx context.Context, client *http.Client, opts options, query url.Values, api map[string]summaryEntry) bool {
+	if len(opts.instances) == 0 {
+		return true
+	}
+
+	fmt.Println("\nResumo (instâncias x API):")
+	agree := true
+	for _, instance := range opts.instances {
+		var got map[string]summaryEntry
+		if err := getJSON(ctx, client, instance+"/payments-summary?"+query.Encode(), "", &got); err != nil {
+			log.Printf("Erro ao consultar o resumo de %s: %v", instance, err)
+			agree = false
+			continue
+		}
+		for _, name := range []string{"default", "fallback"} {
+			ours, theirs := api[name], got[name]
+			mark := "ok"
+			if ours.TotalRequests != theirs.TotalRequests || math.Abs(ours.TotalAmount-theirs.TotalAmount) > opts.tolerance {
+				mark = "DIVERGENTE"
+				agree = false
+			}
+			fmt.Printf("  %-24s %-8s %d / %.2f   %s\n", instance, name, theirs.TotalRequests, theirs.TotalAmount, mark)
+		}
+	}
+	return agree
+}
+
+func getJSON(ctx context.Context, client *http.Client, target, token string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Rinha-Token", token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
@@ -0,0 +1,46 @@
+// Comando processorstub sobe o Payment Processor em memória de
+// internal/processorstub, com as variáveis da imagem oficial: PORT (8080),
+// TRANSACTION_FEE, RATE_LIMIT_SECONDS e INITIAL_TOKEN. STUB_SCHEDULE define o
+// roteiro de falhas e de minResponseTime, repetido em ciclo, como
+// "30s:ok,10s:failing,20s:ok:800ms". docker-compose-processorstub.yml o sobe no
+// lugar das imagens oficiais, com os mesmos nomes e portas.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"rinha-backend-2025/internal/processorstub"
+)
+
+func main() {
+	opts := processorstub.Options{Token: envOr("INITIAL_TOKEN", "123")}
+	var err error
+	if v := os.Getenv("TRANSACTION_FEE"); v != "" {
+		if opts.Fee, err = strconv.ParseFloat(v, 64); err != nil {
+			log.Fatalf("TRANSACTION_FEE inválido: %v", err)
+		}
+	}
+	seconds, err := strconv.Atoi(envOr("RATE_LIMIT_SECONDS", "5"))
+	if err != nil || seconds < 0 {
+		log.Fatalf("RATE_LIMIT_SECONDS inválido: %q", os.Getenv("RATE_LIMIT_SECONDS"))
+	}
+	opts.HealthInterval = time.Duration(seconds) * time.Second
+	if opts.Schedule, err = processorstub.ParseSchedule(os.Getenv("STUB_SCHEDULE")); err != nil {
+		log.Fatalf("STUB_SCHEDULE inválido: %v", err)
+	}
+
+	addr := ":" + envOr("PORT", "8080")
+	log.Printf("Processor stub em %s: taxa %.2f, saúde a cada %ds, %d fases no roteiro", addr, opts.Fee, seconds, len(opts.Schedule))
+	log.Fatal(http.ListenAndServe(addr, processorstub.New(opts)))
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,252 @@
+// Comando replay reaplica as decisões de roteamento gravadas pela API
+// (SELECTOR_DECISION_LOG) a outras estratégias do selector e compara o lucro e
+// a latência que elas teriam dado. As decisões vêm de um NDJSON exportado de
+// GET /admin/routing/decisions (-in) ou direto da API (-url). Os parâmetros das
+// estratégias vêm do ambiente e do CONFIG_FILE, como na API: para comparar
+// pesos, rode de novo com outro SELECTOR_*.
+//
+// Quando a estratégia escolhe primeiro o mesmo processor que a API escolheu, o
+// desfecho e a latência são os registrados. Nas demais, são estimados dos
+// candidatos da decisão: a chance de sucesso da estratégia "profit", a latência
+// observada (ou o minResponseTime sem amostras) e, na falha, o próximo da ordem.
+// O failback e a guarda de SLO não são reaplicados, e o sorteio do "profit"
+// muda a cada execução.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	json "github.com/goccy/go-json"
+
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/selector"
+)
+
+// Linhas do NDJSON de até 1MiB: os candidatos de uma decisão cabem com folga
+const maxDecisionLine = 1 << 20
+
+func main() {
+	in := flag.String("in", "", "NDJSON de GET /admin/routing/decisions; - lê da entrada padrão")
+	apiURL := flag.String("url", "", "URL base da API, para ler as decisões direto de /admin/routing/decisions")
+	apiKey := flag.String("key", "", "chave de API das rotas /admin (ADMIN_API_KEY)")
+	keyHeader := flag.String("key-header", "X-API-Key", "header da chave de API (AUTH_HEADER)")
+	limit := flag.Int("limit", 0, "máximo de decisões lidas da API; 0 usa o padrão do endpoint")
+	strategies := flag.String("strategies", "failover,score,profit", "estratégias comparadas, separadas por vírgula")
+	flag.Parse()
+
+	if (*in == "") == (*apiURL == "") {
+		log.Fatal("informe -in ou -url")
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	}
+
+	var decisions []selector.Decision
+	if *in != "" {
+		decisions, err = readFile(*in)
+	} else {
+		decisions, err = fetchDecisions(context.Background(), *apiURL, *keyHeader, *apiKey, *limit)
+	}
+	if err != nil {
+		log.Fatalf("Erro ao ler as decisões: %v", err)
+	}
+	if len(decisions) == 0 {
+		log.Fatal("Nenhuma decisão para reaplicar")
+	}
+
+	report := []result{replayRecorded(decisions)}
+	for _, name := range strings.Split(*strategies, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		selectorCfg := cfg.Selector
+		selectorCfg.Strategy = name
+		report = append(report, replay(decisions, name, selector.New(selectorCfg)))
+	}
+	printReport(decisions, report)
+}
+
+func readFile(path string) ([]selector.Decision, error) {
+	if path == "-" {
+		return readDecisions(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readDecisions(f)
+}
+
+func fetchDecisions(ctx context.Context, base, keyHeader, key string, limit int) ([]selector.Decision, error) {
+	target := strings.TrimRight(base, "/") + "/admin/routing/decisions"
+	if limit > 0 {
+		target += "?" + url.Values{"limit": {fmt.Sprint(limit)}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		req.Header.Set(keyHeader, key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s: status %d: %s", target, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return readDecisions(resp.Body)
+}
+
+func readDecisions(r io.Reader) ([]selector.Decision, error) {
+	var decisions []selector.Decision
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxDecisionLine)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var d selector.Decision
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			return nil, fmt.Errorf("linha %d: %w", line, err)
+		}
+		if len(d.Ranking) == 0 {
+			continue
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions, scanner.Err()
+}
+
+// result soma o desempenho de uma estratégia sobre as decisões.
+type result struct {
+	name string
+	// Decisões em que a escolha coincide com a registrada
+	agreed int
+	// Primeira escolha por processor
+	first map[string]int
+	// Valor líquido, aceites e latência somados; esperados nas estimativas
+	profit    float64
+	successes float64
+	latency   time.Duration
+}
+
+// replayRecorded é a linha de base: o que a API de fato obteve.
+func replayRecorded(decisions []selector.Decision) result {
+	r := result{name: "registrado", first: make(map[string]int)}
+	for _, d := range decisions {
+		r.agreed++
+		r.first[d.Ranking[0]]++
+		profit, success := observed(d)
+		r.profit += profit
+		r.successes += success
+		r.latency += d.Latency
+	}
+	return r
+}
+
+func replay(decisions []selector.Decision, name string, s selector.Selector) result {
+	r := result{name: name, first: make(map[string]int)}
+	for _, d := range decisions {
+		// Só os processors tentáveis na decisão: fora ficaram os de teto menor que
+		// o valor e, nos pools de envio, os de outro pool
+		ranking := slices.DeleteFunc(s.Rank(d.Candidates), func(name string) bool {
+			return !slices.Contains(d.Ranking, name)
+		})
+		if len(ranking) == 0 {
+			continue
+		}
+		r.first[ranking[0]]++
+		if ranking[0] == d.Ranking[0] {
+			r.agreed++
+			profit, success := observed(d)
+			r.profit += profit
+			r.successes += success
+			r.latency += d.Latency
+			continue
+		}
+		profit, success, latency := estimate(d, ranking)
+		r.profit += profit
+		r.successes += success
+		r.latency += latency
+	}
+	return r
+}
+
+// observed é o valor líquido e o aceite registrados na decisão.
+func observed(d selector.Decision) (float64, float64) {
+	if d.Outcome != selector.OutcomeSucceeded {
+		return 0, 0
+	}
+	c, _ := d.Candidate(d.Processor)
+	return d.Amount * (1 - c.Fee), 1
+}
+
+// estimate percorre a ordem como a API faria: cada processor aceita com a chance
+// estimada e, na recusa, o próximo é tentado depois da latência do anterior.
+func estimate(d selector.Decision, ranking []string) (profit, success float64, latency time.Duration) {
+	reach := 1.0
+	for _, name := range ranking {
+		c, ok := d.Candidate(name)
+		if !ok {
+			continue
+		}
+		p := selector.SuccessProbability(c)
+		rtt := c.MinResponseTime
+		if c.Samples > 0 {
+			rtt = c.Observed
+		}
+		latency += time.Duration(reach * float64(rtt))
+		profit += reach * p * d.Amount * (1 - c.Fee)
+		success += reach * p
+		reach *= 1 - p
+	}
+	return profit, success, latency
+}
+
+func printReport(decisions []selector.Decision, report []result) {
+	n := float64(len(decisions))
+	from, to := decisions[0].At, decisions[len(decisions)-1].At
+	fmt.Printf("%d decisões de %s a %s\n\n", len(decisions), from.Format(time.RFC3339), to.Format(time.RFC3339))
+
+	var names []string
+	for _, d := range decisions {
+		for _, c := range d.Candidates {
+			if !slices.Contains(names, c.Name) {
+				names = append(names, c.Name)
+			}
+		}
+	}
+
+	baseline := report[0].profit
+	fmt.Printf("%-12s %9s %12s %10s %9s %11s  %s\n", "estratégia", "coincide", "líquido", "vs reg.", "aceites", "latência", "primeira escolha")
+	for _, r := range report {
+		shares := make([]string, 0, len(names))
+		for _, name := range names {
+			shares = append(shares, fmt.Sprintf("%s %.0f%%", name, 100*float64(r.first[name])/n))
+		}
+		delta := 0.0
+		if baseline != 0 {
+			delta = 100 * (r.profit - baseline) / baseline
+		}
+		fmt.Printf("%-12s %8.1f%% %12.2f %+9.1f%% %8.1f%% %11v  %s\n", r.name,
+			100*float64(r.agreed)/n, r.profit, delta, 100*r.successes/n,
+			(r.latency / time.Duration(len(decisions))).Round(time.Microsecond), strings.Join(shares, "  "))
+	}
+}
@@ -0,0 +1,197 @@
+// Package clock é a fonte de tempo da aplicação: a validade do health-check, as
+// esperas entre tentativas, o requestedAt dos pagamentos e as janelas de
+// latência, desfechos e orçamento leem o relógio por aqui. O Fake só anda com
+// Advance ou Set, para testar de forma determinística as janelas do resumo e os
+// cooldowns sem esperar o tempo passar.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock é o subconjunto do pacote time usado pela aplicação.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Until(t time.Time) time.Duration
+	// NewTimer dispara uma vez, depois de d
+	NewTimer(d time.Duration) Timer
+	// AfterFunc chama f depois de d; o Timer retornado só serve para Stop
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer é o time.Timer de um Clock.
+type Timer interface {
+	C() <-chan time.Time
+	// Stop retorna false se o timer já tinha disparado ou parado
+	Stop() bool
+}
+
+// Real retorna o relógio do sistema.
+func Real() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                  { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration { return time.Since(t) }
+func (realClock) Until(t time.Time) time.Duration { return time.Until(t) }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+func (t realTimer) Stop() bool          { return t.t.Stop() }
+
+// Fake é um relógio parado, que só anda com Advance ou Set. Os timers disparam
+// quando o relógio passa do prazo deles, na ordem dos prazos.
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFake cria um relógio parado em start.
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration { return f.Now().Sub(t) }
+func (f *Fake) Until(t time.Time) time.Duration { return t.Sub(f.Now()) }
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := &fakeTimer{clock: f, deadline: f.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.fire(f.now)
+		return t
+	}
+	f.timers = append(f.timers, t)
+	return t
+}
+
+// AfterFunc agenda f para quando o relógio passar de d. As funções vencidas rodam
+// dentro de Advance ou Set, depois de liberado o relógio; com d <= 0, f roda logo
+// em outra goroutine, como no time.AfterFunc.
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := &fakeTimer{clock: f, deadline: f.now.Add(d), fn: fn}
+	if d <= 0 {
+		t.done = true
+		go fn()
+		return t
+	}
+	f.timers = append(f.timers, t)
+	return t
+}
+
+// Advance anda o relógio em d, disparando os timers vencidos.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	due := f.setLocked(f.now.Add(d))
+	f.mu.Unlock()
+	runAll(due)
+}
+
+// Pending conta os timers ainda não disparados nem parados, para um teste saber
+// que o código já está esperando antes de chamar Advance.
+func (f *Fake) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+// Set põe o relógio em t; um instante anterior ao atual não dispara timers.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	due := f.setLocked(t)
+	f.mu.Unlock()
+	runAll(due)
+}
+
+// setLocked dispara os timers vencidos e retorna as funções de AfterFunc deles,
+// que rodam fora do lock: elas podem ler o relógio.
+func (f *Fake) setLocked(t time.Time) []func() {
+	f.now = t
+	sort.SliceStable(f.timers, func(i, j int) bool {
+		return f.timers[i].deadline.Before(f.timers[j].deadline)
+	})
+	var due []func()
+	pending := f.timers[:0]
+	for _, timer := range f.timers {
+		if timer.deadline.After(t) {
+			pending = append(pending, timer)
+			continue
+		}
+		if timer.fn != nil {
+			timer.done = true
+			due = append(due, timer.fn)
+			continue
+		}
+		timer.fire(t)
+	}
+	f.timers = pending
+	return due
+}
+
+func runAll(fns []func()) {
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+type fakeTimer struct {
+	clock    *Fake
+	deadline time.Time
+	c        chan time.Time
+	// Função de AfterFunc, chamada no lugar de entregar em c
+	fn func()
+	// Disparado ou parado; com clock.mu
+	done bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	if t.done {
+		return false
+	}
+	t.done = true
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// fire entrega o instante no canal. Chamada com clock.mu.
+func (t *fakeTimer) fire(now time.Time) {
+	t.done = true
+	t.c <- now
+}
@@ -0,0 +1,2459 @@
+// Package config carrega e valida a configuração da aplicação.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"rinha-backend-2025/internal/retry"
+)
+
+// Modos de confirmação de POST /payments (ACK_MODE)
+const (
+	// Responde antes de enfileirar; o pagamento pode ir para outra instância
+	AckImmediate = "immediate"
+	// Responde 202 só depois que o pagamento entrou na fila dos workers
+	AckEnqueued = "enqueued"
+	// Responde depois da resposta do processor; útil para depuração e testes de consistência
+	AckSync = "sync"
+)
+
+// Origem do requestedAt enviado aos processors (REQUESTED_AT)
+const (
+	// Instante em que o pagamento foi recebido
+	RequestedAtIngestion = "ingestion"
+	// Instante do primeiro envio a um processor
+	RequestedAtSend = "send"
+)
+
+// requestedAt das retentativas (REQUESTED_AT_RETRY)
+const (
+	// O mesmo em todas as tentativas, na volta para a fila e na DLQ
+	RequestedAtPreserve = "preserve"
+	// O instante de cada tentativa
+	RequestedAtRefresh = "refresh"
+	// O original, trocado pelo instante da tentativa quando fica mais antigo que
+	// REQUESTED_AT_MAX_SKEW
+	RequestedAtMaxSkew = "max-skew"
+)
+
+// Níveis de log (LOG_LEVEL)
+const (
+	// Logs por pagamento e access log do Gin
+	LogInfo = "info"
+	// Apenas avisos e erros da própria instância
+	LogWarn = "warn"
+)
+
+// Onde procurar um correlationId já recebido com outro valor (AMOUNT_CONFLICT_CHECK)
+const (
+	ConflictCheckOff = "off"
+	// Só os pagamentos na fila e os entregues há pouco aos workers, sem custo
+	// por requisição; uma repetição depois disso passa sem ser conferida (padrão)
+	ConflictCheckQueue = "queue"
+	// Também os registrados no storage: uma leitura a mais por pagamento cuja
+	// chave não está na fila, na resposta do POST /payments (um round-trip ao
+	// Redis no backend "redis")
+	ConflictCheckStorage = "storage"
+)
+
+// Modos de execução do processo (RUN_MODE)
+const (
+	// Um processo serve tudo
+	RunSingle = "single"
+	// O processo pai só supervisiona filhos, cada um com seu socket SO_REUSEPORT
+	// na mesma porta, seu GC e um heap menor
+	RunPrefork = "prefork"
+)
+
+// Topologias do Redis (REDIS_MODE)
+const (
+	RedisSingle   = "single"
+	RedisSentinel = "sentinel"
+	RedisCluster  = "cluster"
+)
+
+// RUN_ID que sorteia o namespace das chaves na inicialização
+const RunIDAuto = "auto"
+
+// Tamanho máximo do RUN_ID
+const maxRunIDLen = 64
+
+// Tetos do histograma e da lista dos maiores pagamentos (STORAGE_AMOUNT_BOUNDS,
+// STORAGE_TOP_AMOUNTS): cada limite é um campo por segundo no Redis
+const (
+	maxAmountBounds = 32
+	maxTopAmounts   = 10000
+)
+
+// Modos de arredondamento do totalAmount (AMOUNT_ROUNDING)
+const (
+	RoundHalfEven = "half-even"
+	RoundHalfUp   = "half-up"
+)
+
+// Config reúne todas as opções da aplicação. Os valores são carregados na ordem:
+// padrões, arquivo opcional (CONFIG_FILE, YAML ou JSON) e variáveis de ambiente.
+type Config struct {
+	Port string `json:"port" yaml:"port"`
+	// Sockets TCP abertos com SO_REUSEPORT na porta, cada um com seu laço de
+	// accept; 0 abre um por P (GOMAXPROCS). Sem suporte na plataforma, um só
+	Listeners  int              `json:"listeners" yaml:"listeners"`
+	Socket     SocketConfig     `json:"socket" yaml:"socket"`
+	Server     ServerConfig     `json:"server" yaml:"server"`
+	Instance   InstanceConfig   `json:"instance" yaml:"instance"`
+	RunMode    string           `json:"runMode" yaml:"runMode"`
+	Prefork    PreforkConfig    `json:"prefork" yaml:"prefork"`
+	Runtime    RuntimeConfig    `json:"runtime" yaml:"runtime"`
+	TLS        TLSConfig        `json:"tls" yaml:"tls"`
+	GRPC       GRPCConfig       `json:"grpc" yaml:"grpc"`
+	Processors ProcessorsConfig `json:"processors" yaml:"processors"`
+	HTTP       HTTPClientConfig `json:"http" yaml:"http"`
+	Retry      RetryConfig      `json:"retry" yaml:"retry"`
+	Workers    WorkersConfig    `json:"workers" yaml:"workers"`
+	Limiter    LimiterConfig    `json:"limiter" yaml:"limiter"`
+	Pacing     PacingConfig     `json:"pacing" yaml:"pacing"`
+	Hedging    HedgingConfig    `json:"hedging" yaml:"hedging"`
+	Failback   FailbackConfig   `json:"failback" yaml:"failback"`
+	Validation ValidationConfig `json:"validation" yaml:"validation"`
+	RateLimit  RateLimitConfig  `json:"rateLimit" yaml:"rateLimit"`
+	Redis      RedisConfig      `json:"redis" yaml:"redis"`
+	Storage    StorageConfig    `json:"storage" yaml:"storage"`
+	Selector   SelectorConfig   `json:"selector" yaml:"selector"`
+	DLQ        DLQConfig        `json:"dlq" yaml:"dlq"`
+	Outbox     OutboxConfig     `json:"outbox" yaml:"outbox"`
+	Counters   CountersConfig   `json:"counters" yaml:"counters"`
+	Debug      DebugConfig      `json:"debug" yaml:"debug"`
+	Peers      PeersConfig      `json:"peers" yaml:"peers"`
+	Warmup     WarmupConfig     `json:"warmup" yaml:"warmup"`
+	DryRun     DryRunConfig     `json:"dryRun" yaml:"dryRun"`
+	Chaos      ChaosConfig      `json:"chaos" yaml:"chaos"`
+	Webhook    WebhookConfig    `json:"webhook" yaml:"webhook"`
+	Stream     StreamConfig     `json:"stream" yaml:"stream"`
+	Events     EventsConfig     `json:"events" yaml:"events"`
+	Audit      AuditConfig      `json:"audit" yaml:"audit"`
+	Health     HealthConfig     `json:"health" yaml:"health"`
+	Auth       AuthConfig       `json:"auth" yaml:"auth"`
+	// Pagamentos por status no Redis, para GET /admin/payments
+	StatusIndex StatusIndexConfig `json:"statusIndex" yaml:"statusIndex"`
+	// Onde ficam as faixas da fila de pagamentos: memória, Redis ou NATS
+	Queue QueueConfig `json:"queue" yaml:"queue"`
+	// Desvio do tráfego quando o p99 de ponta a ponta passa do orçamento
+	SLO SLOConfig `json:"slo" yaml:"slo"`
+	// Teto do valor enviado ao fallback por janela de tempo
+	FallbackBudget FallbackBudgetConfig `json:"fallbackBudget" yaml:"fallbackBudget"`
+	// Middlewares em volta do processamento de cada pagamento nos workers
+	Pipeline PipelineConfig `json:"pipeline" yaml:"pipeline"`
+	// Tetos das estruturas em memória e recusa de pagamentos perto do limite
+	Memory MemoryConfig `json:"memory" yaml:"memory"`
+	// Trabalho acessório desligado sob CPU ou fila acima do limite
+	LoadShed LoadShedConfig `json:"loadShed" yaml:"loadShed"`
+	// Compressão do resumo e da exportação, e corpos comprimidos no lote
+	Compression CompressionConfig `json:"compression" yaml:"compression"`
+	// Política CORS do build padrão
+	CORS CORSConfig `json:"cors" yaml:"cors"`
+	// Conferência dos contadores com GET /admin/payments-summary dos processors
+	SummaryCheck SummaryCheckConfig `json:"summaryCheck" yaml:"summaryCheck"`
+	// Amostra dos pagamentos aceitos conferida com GET /payments/{id}
+	VerifySample VerifySampleConfig `json:"verifySample" yaml:"verifySample"`
+	// Quando POST /payments responde: "immediate", "enqueued" ou "sync"
+	AckMode string `json:"ackMode" yaml:"ackMode"`
+	// Quando o requestedAt é definido: "ingestion" ou "send"
+	RequestedAt string `json:"requestedAt" yaml:"requestedAt"`
+	// O que as retentativas, inclusive as da fila e da DLQ, enviam como
+	// requestedAt: "preserve", "refresh" ou "max-skew"
+	RequestedAtRetry string `json:"requestedAtRetry" yaml:"requestedAtRetry"`
+	// Idade máxima do requestedAt enviado com "max-skew"
+	RequestedAtMaxSkew Duration `json:"requestedAtMaxSkew" yaml:"requestedAtMaxSkew"`
+	// "info" (padrão) ou "warn"
+	LogLevel string `json:"logLevel" yaml:"logLevel"`
+	// Linhas de log aguardando a escrita em stdout/stderr; com o buffer cheio
+	// elas são descartadas em vez de segurar a requisição. 0 escreve direto
+	LogBuffer int `json:"logBuffer" yaml:"logBuffer"`
+	// Tempo máximo para drenar requisições, workers e contadores ao encerrar
+	ShutdownTimeout Duration `json:"shutdownTimeout" yaml:"shutdownTimeout"`
+}
+
+type SocketConfig struct {
+	// Caminho do socket unix; vazio desativa
+	Path string `json:"path" yaml:"path"`
+	// Permissões em octal, ex.: "0666"
+	Mode string `json:"mode" yaml:"mode"`
+	// Escutar apenas no socket, sem a porta TCP
+	Only bool `json:"only" yaml:"only"`
+}
+
+// ServerConfig ajusta o servidor HTTP e as conexões aceitas nas portas TCP. Os
+// zeros mantêm os padrões do net/http: sem prazos e headers de até 1MB.
+type ServerConfig struct {
+	// Prazo para ler a requisição inteira, corpo incluído
+	ReadTimeout Duration `json:"readTimeout" yaml:"readTimeout"`
+	// Prazo para ler os headers; 0 usa o ReadTimeout
+	ReadHeaderTimeout Duration `json:"readHeaderTimeout" yaml:"readHeaderTimeout"`
+	// Prazo para escrever a resposta; também encerra o stream de eventos e as
+	// exportações longas
+	WriteTimeout Duration `json:"writeTimeout" yaml:"writeTimeout"`
+	// Tempo de uma conexão keep-alive ociosa; 0 usa o ReadTimeout
+	IdleTimeout Duration `json:"idleTimeout" yaml:"idleTimeout"`
+	// Tamanho máximo dos headers
+	MaxHeaderBytes int `json:"maxHeaderBytes" yaml:"maxHeaderBytes"`
+	// TCP_NODELAY nas conexões: respostas pequenas saem sem esperar o Nagle
+	TCPNoDelay bool `json:"tcpNoDelay" yaml:"tcpNoDelay"`
+	// Intervalo do TCP keep-alive; 0 usa o padrão do Go (15s) e negativo desliga
+	TCPKeepAlive Duration `json:"tcpKeepAlive" yaml:"tcpKeepAlive"`
+	// Espera entre falhar a readiness e drenar as conexões no encerramento, para
+	// o balanceador tirar a instância antes; as respostas já saem com
+	// Connection: close. Conta fora do SHUTDOWN_TIMEOUT
+	PreStopDelay Duration `json:"preStopDelay" yaml:"preStopDelay"`
+}
+
+// InstanceConfig identifica a instância atrás do nginx nos logs, nas métricas, na
+// auditoria e em GET /admin/whoami.
+type InstanceConfig struct {
+	// Vazio usa o hostname
+	ID string `json:"id" yaml:"id"`
+	// Devolve o ID no header X-Instance de todas as respostas
+	Header bool `json:"header" yaml:"header"`
+}
+
+// PreforkConfig controla os processos filhos do RUN_MODE=prefork.
+type PreforkConfig struct {
+	// Processos filhos; 0 abre um por P (GOMAXPROCS)
+	Children int `json:"children" yaml:"children"`
+	// Espera antes de reiniciar um filho que terminou; dobra enquanto ele sair
+	// antes de MaxRestartDelay de execução, até MaxRestartDelay
+	RestartDelay    Duration `json:"restartDelay" yaml:"restartDelay"`
+	MaxRestartDelay Duration `json:"maxRestartDelay" yaml:"maxRestartDelay"`
+}
+
+// RuntimeConfig ajusta o runtime do Go aos limites do container. As variáveis
+// GOMAXPROCS, GOGC e GOMEMLIMIT do próprio Go têm precedência sobre estes campos.
+type RuntimeConfig struct {
+	// GOMAXPROCS pela cota de CPU do cgroup, arredondada para cima; sem cota,
+	// fica o padrão do Go (núcleos da máquina)
+	AutoMaxProcs bool `json:"autoMaxProcs" yaml:"autoMaxProcs"`
+	// GOGC; -1 desliga o GC por proporção e deixa só o GOMEMLIMIT
+	GCPercent int `json:"gcPercent" yaml:"gcPercent"`
+	// GOMEMLIMIT em bytes; 0 usa MemoryLimitRatio do limite de memória do
+	// cgroup, e sem limite no cgroup não há GOMEMLIMIT
+	MemoryLimitBytes int64 `json:"memoryLimitBytes" yaml:"memoryLimitBytes"`
+	// Fração do limite do cgroup usada como GOMEMLIMIT; o resto fica para a
+	// pilha das goroutines, os buffers fora do heap e o próprio runtime
+	MemoryLimitRatio float64 `json:"memoryLimitRatio" yaml:"memoryLimitRatio"`
+}
+
+// TLSConfig termina TLS na porta TCP e na porta gRPC; o socket unix segue sem
+// TLS. Certificado e chave em PEM, recarregados só no restart.
+type TLSConfig struct {
+	CertFile string `json:"certFile" yaml:"certFile"`
+	KeyFile  string `json:"keyFile" yaml:"keyFile"`
+}
+
+// Enabled indica se as portas TCP devem servir com TLS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+type GRPCConfig struct {
+	// Porta do serviço gRPC de ingestão; vazio desativa
+	Port string `json:"port" yaml:"port"`
+}
+
+type ProcessorsConfig struct {
+	DefaultURL  string `json:"defaultUrl" yaml:"defaultUrl"`
+	FallbackURL string `json:"fallbackUrl" yaml:"fallbackUrl"`
+	// Lista completa de processors; quando vazia, default e fallback são
+	// montados a partir das URLs acima e das taxas do selector
+	List []ProcessorDef `json:"list" yaml:"list"`
+	// Credenciais por nome de processor; PROCESSOR_<NOME>_* no ambiente
+	Auth map[string]ProcessorAuth `json:"auth" yaml:"auth"`
+	// Envia o metadata dos pagamentos aos processors no corpo de POST /payments
+	ForwardMetadata bool `json:"forwardMetadata" yaml:"forwardMetadata"`
+	// Restrições impostas por processor; PROCESSOR_<NOME>_MAX_* no ambiente
+	Limits map[string]ProcessorLimits `json:"limits" yaml:"limits"`
+}
+
+// ProcessorLimits são restrições de um processor, respeitadas pelo roteamento:
+// os pagamentos que não cabem seguem para os outros. 0 não limita.
+type ProcessorLimits struct {
+	// Maior valor aceito por transação
+	MaxAmount float64 `json:"maxAmount" yaml:"maxAmount"`
+	// Transações por segundo enviadas ao processor
+	MaxTPS float64 `json:"maxTps" yaml:"maxTps"`
+}
+
+// ProcessorAuth são as credenciais enviadas a um processor em toda requisição.
+type ProcessorAuth struct {
+	BearerToken string `json:"bearerToken" yaml:"bearerToken"`
+	// Segredo do HMAC-SHA256 do corpo; vazio desliga a assinatura
+	HMACSecret string `json:"hmacSecret" yaml:"hmacSecret"`
+	// Header da assinatura; vazio usa X-Signature
+	SignatureHeader string            `json:"signatureHeader" yaml:"signatureHeader"`
+	Headers         map[string]string `json:"headers" yaml:"headers"`
+}
+
+// ProcessorDef descreve um Payment Processor configurado.
+type ProcessorDef struct {
+	Name string  `json:"name" yaml:"name"`
+	URL  string  `json:"url" yaml:"url"`
+	Fee  float64 `json:"fee" yaml:"fee"`
+	// Menor valor = preferido pelo failover e nos empates do score
+	Priority int `json:"priority" yaml:"priority"`
+}
+
+// ProcessorDefs retorna os processors efetivos, ordenados por prioridade.
+func (c Config) ProcessorDefs() []ProcessorDef {
+	defs := c.Processors.List
+	if len(defs) == 0 {
+		defs = []ProcessorDef{
+			{Name: "default", URL: c.Processors.DefaultURL, Fee: c.Selector.DefaultFee, Priority: 1},
+			{Name: "fallback", URL: c.Processors.FallbackURL, Fee: c.Selector.FallbackFee, Priority: 2},
+		}
+	}
+
+	sorted := append([]ProcessorDef(nil), defs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+	return sorted
+}
+
+// processorEnvPrefix é o prefixo das variáveis de um processor, como
+// PROCESSOR_DEFAULT_ para "default"; o que não é letra ou dígito vira "_".
+func processorEnvPrefix(name string) string {
+	return "PROCESSOR_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name) + "_"
+}
+
+// parseProcessorList lê PROCESSORS no formato "nome|url|taxa|prioridade,...".
+func parseProcessorList(spec string) ([]ProcessorDef, error) {
+	var defs []ProcessorDef
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.Split(item, "|")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("PROCESSORS: %q deve ter o formato nome|url|taxa|prioridade", item)
+		}
+		fee, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("PROCESSORS: taxa inválida em %q", item)
+		}
+		priority, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return nil, fmt.Errorf("PROCESSORS: prioridade inválida em %q", item)
+		}
+
+		defs = append(defs, ProcessorDef{Name: parts[0], URL: parts[1], Fee: fee, Priority: priority})
+	}
+	return defs, nil
+}
+
+type HTTPClientConfig struct {
+	// Limite absoluto de qualquer chamada aos Payment Processors
+	Timeout Duration `json:"timeout" yaml:"timeout"`
+	// Limite de cada tentativa de POST /payments
+	AttemptTimeout Duration `json:"attemptTimeout" yaml:"attemptTimeout"`
+	// Limite de cada consulta a /payments/service-health
+	HealthCheckTimeout Duration `json:"healthCheckTimeout" yaml:"healthCheckTimeout"`
+	// "h2c" usa HTTP/2 sem TLS (prior knowledge), voltando ao HTTP/1.1 nos
+	// processors que não o suportam; vazio usa HTTP/1.1. Processors em https
+	// negociam HTTP/2 via ALPN
+	HTTP2 string `json:"http2" yaml:"http2"`
+	// CAs em PEM usadas, além das do sistema, para verificar processors em https
+	CAFile string `json:"caFile" yaml:"caFile"`
+	// Certificado e chave do cliente para processors que exigem mTLS
+	ClientCertFile string `json:"clientCertFile" yaml:"clientCertFile"`
+	ClientKeyFile  string `json:"clientKeyFile" yaml:"clientKeyFile"`
+	// Não verifica o certificado dos processors; só para ambientes de teste
+	InsecureSkipVerify bool `json:"insecureSkipVerify" yaml:"insecureSkipVerify"`
+	// Reresolve os hosts dos processors a cada intervalo e alterna as conexões
+	// novas entre os endereços (A/AAAA); 0 deixa a resolução com o dialer padrão
+	DNSRefresh Duration `json:"dnsRefresh" yaml:"dnsRefresh"`
+	// Tempo fora do rodízio de um endereço que recusou a conexão
+	DNSFailureCooldown Duration `json:"dnsFailureCooldown" yaml:"dnsFailureCooldown"`
+}
+
+type RetryConfig struct {
+	MaxAttempts int      `json:"maxAttempts" yaml:"maxAttempts"`
+	BaseDelay   Duration `json:"baseDelay" yaml:"baseDelay"`
+	MaxDelay    Duration `json:"maxDelay" yaml:"maxDelay"`
+	// Fração de variação aleatória do atraso (0 a 1)
+	Jitter float64 `json:"jitter" yaml:"jitter"`
+	// Status que merecem nova tentativa, ex.: "408,429,5xx"
+	RetryOnStatus string `json:"retryOnStatus" yaml:"retryOnStatus"`
+	// Tempo total disponível para um pagamento, somando tentativas e fallback
+	PaymentBudget Duration `json:"paymentBudget" yaml:"paymentBudget"`
+	// Consultar GET /payments/{id} nos processors com tentativa sem resposta antes
+	// de mandar o pagamento para a DLQ
+	VerifyBeforeFail bool `json:"verifyBeforeFail" yaml:"verifyBeforeFail"`
+	// Status com que o processor diz já ter o correlationId, ex.: "409,422":
+	// confirmado por GET /payments/{id}, o pagamento conta como aceito por ele.
+	// Vazio trata esses status como os demais
+	DuplicateStatus string `json:"duplicateStatus" yaml:"duplicateStatus"`
+	// Recusas definitivas, ex.: "400,422": sem novas tentativas nem os outros
+	// processors, o pagamento vai direto para a DLQ
+	TerminalStatus string `json:"terminalStatus" yaml:"terminalStatus"`
+	// Status que não dizem se o processor aceitou, ex.: "504": tratados como um
+	// timeout, com a reconciliação do outbox ou a conferência antes da falha
+	AmbiguousStatus string `json:"ambiguousStatus" yaml:"ambiguousStatus"`
+}
+
+type WorkersConfig struct {
+	Count     int `json:"count" yaml:"count"`
+	QueueSize int `json:"queueSize" yaml:"queueSize"`
+	// Pagamentos a partir deste valor vão para a faixa prioritária, com fila
+	// própria de queueSize; 0 desativa
+	PriorityAmount float64 `json:"priorityAmount" yaml:"priorityAmount"`
+	// Prioritários seguidos antes de atender um pagamento da faixa normal
+	PriorityBurst int `json:"priorityBurst" yaml:"priorityBurst"`
+	// Pagamentos em memória (na fila ou fora do pool) a partir dos quais novos
+	// são recusados com 503; 0 aceita sempre
+	MaxQueueDepth int `json:"maxQueueDepth" yaml:"maxQueueDepth"`
+	// Vezes que um pagamento volta para a fila depois de um pânico no worker,
+	// antes de ir para a DLQ
+	PanicRetries int `json:"panicRetries" yaml:"panicRetries"`
+	// Pools de envio próprios por nome de processor; PROCESSOR_<NOME>_WORKERS e
+	// _QUEUE_SIZE no ambiente. Com algum, os workers acima só roteiam
+	Pools map[string]WorkerPoolConfig `json:"pools" yaml:"pools"`
+	// Intervalo em que a fila de um pool cujo processor passou a falhar volta
+	// para o roteamento
+	RebalanceInterval Duration `json:"rebalanceInterval" yaml:"rebalanceInterval"`
+}
+
+// WorkerPoolConfig dimensiona o pool de envio de um processor.
+type WorkerPoolConfig struct {
+	Workers int `json:"workers" yaml:"workers"`
+	// Pagamentos aguardando um worker do pool; cheio, o roteamento tenta o
+	// próximo processor
+	QueueSize int `json:"queueSize" yaml:"queueSize"`
+}
+
+// LimiterConfig controla o limitador AIMD de requisições simultâneas por processor.
+type LimiterConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	Initial int  `json:"initial" yaml:"initial"`
+	Min     int  `json:"min" yaml:"min"`
+	Max     int  `json:"max" yaml:"max"`
+	// Fator aplicado ao limite em caso de falha (0 < backoff < 1)
+	Backoff float64 `json:"backoff" yaml:"backoff"`
+	// Respostas mais lentas que isso também reduzem o limite; 0 desativa
+	LatencyThreshold Duration `json:"latencyThreshold" yaml:"latencyThreshold"`
+	// Espera antes de devolver à fila um pagamento sem vaga
+	RequeueDelay Duration `json:"requeueDelay" yaml:"requeueDelay"`
+}
+
+// PacingConfig espaça os envios a cada processor (leaky bucket), para que o
+// acúmulo de uma queda não chegue de uma vez ao processor recuperado.
+type PacingConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Teto de envios por segundo por processor; os ausentes usam DefaultRPS
+	RPS map[string]float64 `json:"rps" yaml:"rps"`
+	// Teto dos processors sem entrada em RPS; 0 deixa só o teto automático
+	DefaultRPS float64 `json:"defaultRps" yaml:"defaultRps"`
+	// Envios simultâneos que um processor sustenta: o teto automático é
+	// concurrency envios a cada minResponseTime; 0 desativa o ajuste
+	Concurrency int `json:"concurrency" yaml:"concurrency"`
+	// Envios liberados em sequência, sem espaçamento, depois de um intervalo ocioso
+	Burst int `json:"burst" yaml:"burst"`
+	// Espera máxima pela vez de envio; acima disso o pagamento volta para a fila
+	MaxWait Duration `json:"maxWait" yaml:"maxWait"`
+}
+
+type HedgingConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Sem resposta do default nesse tempo, o fallback também é acionado
+	Delay Duration `json:"delay" yaml:"delay"`
+}
+
+// FailbackConfig devolve o tráfego ao processor de maior prioridade assim que ele
+// se recupera, sem esperar o próximo health-check.
+type FailbackConfig struct {
+	// Fração dos pagamentos enviada primeiro ao preferido enquanto o tráfego está
+	// em outro processor; 0 desativa
+	ProbeRate float64 `json:"probeRate" yaml:"probeRate"`
+	// Sucessos seguidos do preferido para considerá-lo recuperado
+	Successes int `json:"successes" yaml:"successes"`
+}
+
+// SLOConfig mede a latência de ponta a ponta dos envios (da primeira tentativa
+// à confirmação, somando retries e fallback) e, com o p99 do processor preferido
+// acima de P99Budget, desvia parte do tráfego para o mais rápido e corta os
+// retries até o p99 voltar a RecoverRatio do orçamento.
+type SLOConfig struct {
+	Enabled   bool     `json:"enabled" yaml:"enabled"`
+	P99Budget Duration `json:"p99Budget" yaml:"p99Budget"`
+	// Janela do p99 e amostras mínimas na janela para o guard agir
+	Window     Duration `json:"window" yaml:"window"`
+	MinSamples int64    `json:"minSamples" yaml:"minSamples"`
+	// Fração do orçamento abaixo da qual o SLO é considerado recuperado
+	RecoverRatio float64 `json:"recoverRatio" yaml:"recoverRatio"`
+	// Fração dos pagamentos enviada primeiro ao mais rápido durante a violação
+	ShiftRate float64 `json:"shiftRate" yaml:"shiftRate"`
+	// Tentativas por processor durante a violação; 0 mantém retry.maxAttempts
+	MaxAttempts int `json:"maxAttempts" yaml:"maxAttempts"`
+	// Intervalo de avaliação do p99
+	Interval Duration `json:"interval" yaml:"interval"`
+}
+
+// FallbackBudgetConfig limita o valor enviado aos processors fora do preferido,
+// de taxa maior, dentro de uma janela deslizante: em valor absoluto (MaxAmount)
+// e/ou em fração do volume aceito (MaxShare). O excedente volta para a fila
+// depois de Delay e espera o preferido se recuperar ou a janela andar.
+type FallbackBudgetConfig struct {
+	Enabled bool     `json:"enabled" yaml:"enabled"`
+	Window  Duration `json:"window" yaml:"window"`
+	// Valor máximo no fallback por janela; 0 desativa o teto
+	MaxAmount float64 `json:"maxAmount" yaml:"maxAmount"`
+	// Fração máxima do volume da janela no fallback; 0 desativa o teto
+	MaxShare float64 `json:"maxShare" yaml:"maxShare"`
+	// Espera antes de tentar de novo um pagamento adiado
+	Delay Duration `json:"delay" yaml:"delay"`
+}
+
+type ValidationConfig struct {
+	// Valor máximo aceito em POST /payments; 0 desativa o limite
+	MaxAmount float64 `json:"maxAmount" yaml:"maxAmount"`
+	// Tamanho máximo do corpo de POST /payments, em bytes
+	MaxBodyBytes int64 `json:"maxBodyBytes" yaml:"maxBodyBytes"`
+	// Limites de POST /payments/batch
+	MaxBatchItems     int   `json:"maxBatchItems" yaml:"maxBatchItems"`
+	MaxBatchBodyBytes int64 `json:"maxBatchBodyBytes" yaml:"maxBatchBodyBytes"`
+	// Tamanho máximo do objeto metadata, já compactado; 0 recusa o campo
+	MaxMetadataBytes int `json:"maxMetadataBytes" yaml:"maxMetadataBytes"`
+	// Recusa com 422 um correlationId já recebido com outro valor: "queue"
+	// (padrão), "storage" ou "off". "storage" alcança as repetições que já saíram
+	// da fila ao custo de uma consulta ao storage por pagamento novo, antes da
+	// resposta
+	AmountConflictCheck string `json:"amountConflictCheck" yaml:"amountConflictCheck"`
+}
+
+// RateLimitConfig controla os token buckets de POST /payments, compartilhados
+// entre as instâncias pelo Redis. Taxa 0 desativa o respectivo limite.
+type RateLimitConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Limite somado de todos os clientes (requisições/s)
+	GlobalRate  float64 `json:"globalRate" yaml:"globalRate"`
+	GlobalBurst int     `json:"globalBurst" yaml:"globalBurst"`
+	// Limite por IP do cliente (requisições/s)
+	ClientRate  float64 `json:"clientRate" yaml:"clientRate"`
+	ClientBurst int     `json:"clientBurst" yaml:"clientBurst"`
+}
+
+type RedisConfig struct {
+	// "single" (padrão), "sentinel" ou "cluster"
+	Mode string `json:"mode" yaml:"mode"`
+	Addr string `json:"addr" yaml:"addr"`
+	// Sentinels ou nós iniciais do Cluster; vazio usa Addr
+	Addrs []string `json:"addrs" yaml:"addrs"`
+	// Nome do master monitorado pelos Sentinels
+	MasterName       string   `json:"masterName" yaml:"masterName"`
+	SentinelPassword string   `json:"sentinelPassword" yaml:"sentinelPassword"`
+	Password         string   `json:"password" yaml:"password"`
+	DB               int      `json:"db" yaml:"db"`
+	PoolSize         int      `json:"poolSize" yaml:"poolSize"`
+	DialTimeout      Duration `json:"dialTimeout" yaml:"dialTimeout"`
+	ReadTimeout      Duration `json:"readTimeout" yaml:"readTimeout"`
+	WriteTimeout     Duration `json:"writeTimeout" yaml:"writeTimeout"`
+	// Backoff das tentativas de reconexão enquanto o Redis está fora
+	ReconnectMinBackoff Duration `json:"reconnectMinBackoff" yaml:"reconnectMinBackoff"`
+	ReconnectMaxBackoff Duration `json:"reconnectMaxBackoff" yaml:"reconnectMaxBackoff"`
+	// Intervalo dos pings que detectam a perda de conexão
+	PingInterval Duration `json:"pingInterval" yaml:"pingInterval"`
+	// Prazo máximo de cada comando ou pipeline, mesmo sem prazo do chamador
+	OpTimeout Duration `json:"opTimeout" yaml:"opTimeout"`
+	// Chamadas mais lentas que isto contam em redis_slow_calls_total; 0 desativa
+	SlowCallThreshold Duration `json:"slowCallThreshold" yaml:"slowCallThreshold"`
+	// Pipelines dos comandos do outbox montados por uma goroutine dedicada
+	Writer RedisWriterConfig `json:"writer" yaml:"writer"`
+	// Prefixo de todas as chaves, para execuções seguidas no mesmo Redis não se
+	// misturarem; "auto" sorteia um, compartilhado pelas instâncias. Vazio mantém
+	// os nomes sem prefixo
+	RunID string `json:"runId" yaml:"runId"`
+	// Com "auto", o ID sorteado vale enquanto alguma instância o renovar; depois
+	// disso a próxima execução sorteia outro
+	RunIDTTL Duration `json:"runIdTtl" yaml:"runIdTtl"`
+}
+
+type RedisWriterConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Comandos por pipeline
+	BatchSize int `json:"batchSize" yaml:"batchSize"`
+	// Espera por mais comandos depois do primeiro; 0 envia só o que já chegou
+	Linger Duration `json:"linger" yaml:"linger"`
+	// Comandos aguardando o writer; com a fila cheia, quem envia espera vaga
+	QueueSize int `json:"queueSize" yaml:"queueSize"`
+}
+
+// RedisAddrs retorna os endereços dos Sentinels ou dos nós iniciais do Cluster.
+func (c RedisConfig) RedisAddrs() []string {
+	if len(c.Addrs) > 0 {
+		return c.Addrs
+	}
+	return []string{c.Addr}
+}
+
+type StorageConfig struct {
+	// "redis" (padrão), "memory", "postgres" ou "bolt"
+	Backend     string `json:"backend" yaml:"backend"`
+	PostgresDSN string `json:"postgresDsn" yaml:"postgresDsn"`
+	// Arquivo do backend bolt, exclusivo de cada instância
+	BoltPath string `json:"boltPath" yaml:"boltPath"`
+	// No backend redis, as chaves dos pagamentos (registros, ZSETs e totais por
+	// segundo e por moeda) expiram depois desse tempo sem escritas; 0 não expira
+	RecordTTL Duration `json:"recordTtl" yaml:"recordTtl"`
+	// Limites superiores, crescentes, dos buckets do histograma de valores de GET
+	// /admin/stats/amounts; acima do último conta no +Inf
+	AmountBounds []float64 `json:"amountBounds" yaml:"amountBounds"`
+	// Maiores pagamentos guardados para o mesmo endpoint; 0 não os guarda
+	TopAmounts int `json:"topAmounts" yaml:"topAmounts"`
+}
+
+type DLQConfig struct {
+	RedriveInterval Duration `json:"redriveInterval" yaml:"redriveInterval"`
+}
+
+type OutboxConfig struct {
+	Enabled           bool     `json:"enabled" yaml:"enabled"`
+	ReconcileInterval Duration `json:"reconcileInterval" yaml:"reconcileInterval"`
+	// Idade mínima de uma entrada para ser conferida nos processors; deve
+	// superar retry.paymentBudget para não disputar com envios em andamento
+	ReconcileAfter Duration `json:"reconcileAfter" yaml:"reconcileAfter"`
+}
+
+type CountersConfig struct {
+	// Deltas acumulados em memória são enviados ao Redis a cada intervalo
+	// ou quando o lote atinge FlushBatchSize pagamentos
+	FlushInterval  Duration `json:"flushInterval" yaml:"flushInterval"`
+	FlushBatchSize int      `json:"flushBatchSize" yaml:"flushBatchSize"`
+	// Por quanto tempo o resumo sem filtro é servido do cache; 0 desativa
+	SummaryCacheTTL Duration `json:"summaryCacheTtl" yaml:"summaryCacheTtl"`
+	// Arredondamento do totalAmount para 2 casas: "half-even" ou "half-up"
+	AmountRounding string `json:"amountRounding" yaml:"amountRounding"`
+	// Diretório do WAL dos pagamentos ainda não enviados ao storage; vazio desativa
+	WALDir string `json:"walDir" yaml:"walDir"`
+	// Conta cada correlationId uma única vez no storage (redis e memory), por
+	// CountedTTL desde a última contagem
+	ExactlyOnce bool     `json:"exactlyOnce" yaml:"exactlyOnce"`
+	CountedTTL  Duration `json:"countedTtl" yaml:"countedTtl"`
+	// Cópia periódica do resumo fora do storage
+	Snapshot SnapshotConfig `json:"snapshot" yaml:"snapshot"`
+}
+
+// SnapshotConfig copia o resumo (e, com Payments, os pagamentos registrados) a
+// cada Interval para um arquivo e/ou um Redis secundário. Na subida, ou quando o
+// storage encolhe sem um purge, o que falta em relação à cópia volta ao storage.
+// Sem Path nem RedisAddr, desativado.
+type SnapshotConfig struct {
+	Path          string   `json:"path" yaml:"path"`
+	RedisAddr     string   `json:"redisAddr" yaml:"redisAddr"`
+	RedisPassword string   `json:"redisPassword" yaml:"redisPassword"`
+	Interval      Duration `json:"interval" yaml:"interval"`
+	Payments      bool     `json:"payments" yaml:"payments"`
+}
+
+// Enabled informa se há algum destino para a cópia.
+func (c SnapshotConfig) Enabled() bool {
+	return c.Path != "" || c.RedisAddr != ""
+}
+
+type DebugConfig struct {
+	// Expõe pprof e estatísticas de runtime em /debug
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Porta separada para os endpoints de diagnóstico; vazio usa a porta principal
+	Port string `json:"port" yaml:"port"`
+}
+
+// PeersConfig controla o repasse de pagamentos para outras instâncias quando a
+// fila local está cheia demais.
+type PeersConfig struct {
+	// URLs base das outras instâncias; vazio desativa o repasse. Só vale com
+	// ackMode "immediate": nos outros modos a resposta depende da fila local
+	URLs []string `json:"urls" yaml:"urls"`
+	// Profundidade da fila local a partir da qual os pagamentos são repassados
+	QueueThreshold int      `json:"queueThreshold" yaml:"queueThreshold"`
+	Timeout        Duration `json:"timeout" yaml:"timeout"`
+	// Somar ao resumo os pagamentos guardados só na memória das outras instâncias;
+	// sempre ligado com os backends "memory" e "bolt" (ver ClusterSummary)
+	AggregateSummary bool `json:"aggregateSummary" yaml:"aggregateSummary"`
+}
+
+// HealthConfig controla a consulta a /payments/service-health, limitada pelos
+// processors a 1 chamada a cada 5s somando todas as instâncias.
+type HealthConfig struct {
+	// Folga somada ao intervalo do token global, para que a variação de latência
+	// não aproxime duas consultas de instâncias diferentes
+	ProbeMargin Duration `json:"probeMargin" yaml:"probeMargin"`
+	// Instâncias que consultam os mesmos processors; sem Redis, cada uma consulta
+	// em uma fatia própria do ciclo. 0 usa peers.urls + 1
+	Instances int `json:"instances" yaml:"instances"`
+	// Fatia desta instância (0 a instances-1), distinta em cada uma; -1 sorteia
+	Slot int `json:"slot" yaml:"slot"`
+	// Saúde inferida do próprio tráfego, com /service-health só corroborando
+	Inference InferenceConfig `json:"inference" yaml:"inference"`
+	// Últimos health-checks e vereditos, em /admin/health-history
+	History HealthHistoryConfig `json:"history" yaml:"history"`
+}
+
+// HealthHistoryConfig guarda, por processor, os últimos Size resultados de
+// health-check e mudanças do veredito inferido, para cruzar o roteamento com o
+// comportamento dos processors depois do teste. Com Stream, as entradas também
+// vão para um stream do Redis com cerca de StreamMaxLen entradas.
+type HealthHistoryConfig struct {
+	// Entradas por processor; 0 desativa
+	Size         int   `json:"size" yaml:"size"`
+	Stream       bool  `json:"stream" yaml:"stream"`
+	StreamMaxLen int64 `json:"streamMaxLen" yaml:"streamMaxLen"`
+}
+
+// InferenceConfig decide o "failing" de cada processor pelas tentativas
+// recentes; sem tentativas suficientes na janela, vale o último health-check.
+type InferenceConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Janela deslizante das tentativas, em buckets de 1s
+	Window Duration `json:"window" yaml:"window"`
+	// Tentativas na janela para o veredito valer
+	MinSamples int `json:"minSamples" yaml:"minSamples"`
+	// Fração de tentativas recusadas a partir da qual o processor está falhando
+	ErrorRate float64 `json:"errorRate" yaml:"errorRate"`
+	// Latência média (ms) a partir da qual o processor está falhando; 0 deixa a
+	// latência só para o selector
+	LatencyMs int `json:"latencyMs" yaml:"latencyMs"`
+	// Intervalo entre consultas a /service-health com a inferência ativa
+	CheckInterval Duration `json:"checkInterval" yaml:"checkInterval"`
+}
+
+// ClusterSummary indica se o resumo soma a parte local das outras instâncias:
+// pedido em peers.aggregateSummary, ou implícito com os backends "memory" e
+// "bolt", em que cada instância só enxerga os próprios pagamentos.
+func (c Config) ClusterSummary() bool {
+	if len(c.Peers.URLs) == 0 {
+		return false
+	}
+	return c.Peers.AggregateSummary || c.Storage.Backend == "memory" || c.Storage.Backend == "bolt"
+}
+
+// HealthInstances retorna o número efetivo de instâncias para o health-check.
+func (c Config) HealthInstances() int {
+	if c.Health.Instances > 0 {
+		return c.Health.Instances
+	}
+	return len(c.Peers.URLs) + 1
+}
+
+// WarmupConfig controla as conexões abertas com os processors antes do tráfego.
+type WarmupConfig struct {
+	// Conexões keep-alive por processor; 0 desativa o aquecimento
+	Connections int `json:"connections" yaml:"connections"`
+	// Intervalo das requisições que mantêm as conexões ociosas abertas
+	PingInterval Duration `json:"pingInterval" yaml:"pingInterval"`
+}
+
+// DryRunConfig substitui os processors por simulações em processo, para ensaiar
+// carga sem os containers do payment-processor.
+type DryRunConfig struct {
+	Enabled bool     `json:"enabled" yaml:"enabled"`
+	Latency Duration `json:"latency" yaml:"latency"`
+	// Fração dos envios recusados com 500 (0 a 1)
+	FailureRate float64 `json:"failureRate" yaml:"failureRate"`
+}
+
+// ChaosConfig injeta falhas nas chamadas aos processors e ao Redis, para exercitar
+// retry, fallback e DLQ sem ferramentas externas. Tudo zerado desativa.
+type ChaosConfig struct {
+	Processor ChaosFaults `json:"processor" yaml:"processor"`
+	Redis     ChaosFaults `json:"redis" yaml:"redis"`
+}
+
+// ChaosFaults são as probabilidades (0 a 1) sorteadas a cada chamada.
+type ChaosFaults struct {
+	DelayRate float64  `json:"delayRate" yaml:"delayRate"`
+	Delay     Duration `json:"delay" yaml:"delay"`
+	// Erro imediato: 500 nos processors, erro de comando no Redis
+	FailRate float64 `json:"failRate" yaml:"failRate"`
+	// Espera até o timeout da chamada e falha
+	TimeoutRate float64 `json:"timeoutRate" yaml:"timeoutRate"`
+}
+
+// WebhookConfig controla as notificações de fim de processamento. Um pagamento
+// pode trazer o próprio callbackUrl se o host estiver em AllowedHosts; URL é o
+// destino dos demais.
+type WebhookConfig struct {
+	// Destino global; vazio notifica só os pagamentos com callbackUrl
+	URL string `json:"url" yaml:"url"`
+	// Hosts aceitos no callbackUrl, como "hooks.local" (qualquer porta) ou
+	// "hooks.local:8443"; vazio recusa callbackUrl, para que um cliente não faça a
+	// API chamar endereços internos
+	AllowedHosts []string `json:"allowedHosts" yaml:"allowedHosts"`
+	// Chave do HMAC-SHA256 enviado em X-Webhook-Signature; vazio não assina
+	Secret    string   `json:"secret" yaml:"secret"`
+	Workers   int      `json:"workers" yaml:"workers"`
+	QueueSize int      `json:"queueSize" yaml:"queueSize"`
+	Timeout   Duration `json:"timeout" yaml:"timeout"`
+	// Retry próprio, no mesmo formato do retry dos processors
+	MaxAttempts   int      `json:"maxAttempts" yaml:"maxAttempts"`
+	BaseDelay     Duration `json:"baseDelay" yaml:"baseDelay"`
+	MaxDelay      Duration `json:"maxDelay" yaml:"maxDelay"`
+	RetryOnStatus string   `json:"retryOnStatus" yaml:"retryOnStatus"`
+}
+
+// StreamConfig controla GET /payments/stream.
+type StreamConfig struct {
+	// Eventos guardados por cliente; acima disso os mais antigos são descartados
+	BufferSize int `json:"bufferSize" yaml:"bufferSize"`
+}
+
+// EventsConfig controla o barramento interno que entrega as etapas do pagamento
+// aos webhooks, à auditoria, ao stream e às métricas.
+type EventsConfig struct {
+	// Eventos aguardando entrega; acima disso os novos são descartados
+	BufferSize int `json:"bufferSize" yaml:"bufferSize"`
+}
+
+// CompressionConfig controla gzip/deflate nas respostas do resumo e da
+// exportação, negociados por Accept-Encoding, e nos corpos de POST /payments/batch.
+type CompressionConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Respostas com Content-Length menor que isto seguem sem compressão
+	MinBytes int `json:"minBytes" yaml:"minBytes"`
+	// Nível do gzip/deflate, de 1 (mais rápido) a 9; -1 usa o padrão da biblioteca
+	Level int `json:"level" yaml:"level"`
+	// Aceitar Content-Encoding gzip ou deflate em POST /payments/batch
+	Requests bool `json:"requests" yaml:"requests"`
+}
+
+// CORSConfig controla os headers CORS do build padrão. Desativado, o middleware
+// nem entra na cadeia das rotas, e o preflight OPTIONS cai no 404.
+type CORSConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Origens aceitas, como "https://painel.exemplo.com"; "*" aceita qualquer uma
+	AllowOrigins []string `json:"allowOrigins" yaml:"allowOrigins"`
+	AllowMethods []string `json:"allowMethods" yaml:"allowMethods"`
+	// Headers aceitos nas requisições; "*" aceita qualquer um
+	AllowHeaders []string `json:"allowHeaders" yaml:"allowHeaders"`
+	// Headers da resposta legíveis pelo navegador, como X-Request-Id
+	ExposeHeaders []string `json:"exposeHeaders" yaml:"exposeHeaders"`
+	// Cookies e Authorization nas requisições; não vale com a origem "*"
+	AllowCredentials bool `json:"allowCredentials" yaml:"allowCredentials"`
+	// Tempo que o navegador guarda a resposta do preflight
+	MaxAge Duration `json:"maxAge" yaml:"maxAge"`
+}
+
+// AuditConfig controla o histórico de transições por pagamento no Redis Stream
+// payments:audit, consultado em GET /admin/audit/:correlationId.
+type AuditConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Tamanho aproximado do stream; as entradas mais antigas saem primeiro
+	MaxLen        int64    `json:"maxLen" yaml:"maxLen"`
+	FlushInterval Duration `json:"flushInterval" yaml:"flushInterval"`
+	// O stream expira depois desse tempo sem entradas novas; 0 não expira
+	TTL Duration `json:"ttl" yaml:"ttl"`
+}
+
+// StatusIndexConfig controla os índices por status (pending, succeeded e failed)
+// no Redis, um sorted set por status ordenado pelo requestedAt.
+type StatusIndexConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Pagamentos mantidos em cada status; os de requestedAt mais antigo saem primeiro
+	MaxLen        int64    `json:"maxLen" yaml:"maxLen"`
+	FlushInterval Duration `json:"flushInterval" yaml:"flushInterval"`
+}
+
+// QueueConfig escolhe onde ficam as faixas da fila de pagamentos. "memory" usa
+// canais, sem custo e sem durabilidade; "redis" (Redis Streams) e "nats"
+// (JetStream) guardam os pagamentos fora do processo, e o que ficou na fila
+// volta quando a instância reinicia com o mesmo consumer.
+type QueueConfig struct {
+	Backend string `json:"backend" yaml:"backend"`
+	// Prefixo das chaves no Redis e nome do stream no NATS
+	Name string `json:"name" yaml:"name"`
+	// Identifica as filas desta instância; vazio usa o hostname
+	Consumer string `json:"consumer" yaml:"consumer"`
+	NATSURL  string `json:"natsUrl" yaml:"natsUrl"`
+	// Pagamentos de cada faixa lidos à frente dos workers
+	Prefetch int `json:"prefetch" yaml:"prefetch"`
+	// Prazo para gravar um pagamento no backend; esgotado, ele é processado fora
+	// do pool como na fila cheia
+	PublishTimeout Duration `json:"publishTimeout" yaml:"publishTimeout"`
+	// NATS: tempo até uma mensagem lida e não confirmada ser entregue de novo;
+	// deve passar do tempo de processamento de um pagamento
+	AckWait Duration `json:"ackWait" yaml:"ackWait"`
+}
+
+// Middlewares do processamento de pagamentos (PIPELINE_MIDDLEWARES)
+const (
+	// Descarta os pagamentos que não passam nas regras do POST /payments
+	MiddlewareValidation = "validation"
+	// Não processa de novo um correlationId em andamento ou já aceito
+	MiddlewareDedup = "dedup"
+	// Limita os pagamentos processados por segundo, somando as instâncias
+	MiddlewareRateLimit = "ratelimit"
+	// Desfechos e tempo de processamento em /metrics
+	MiddlewareMetrics = "metrics"
+	// Início do processamento e adiamentos no histórico de auditoria
+	MiddlewareAudit = "audit"
+)
+
+// PipelineConfig monta a cadeia de middlewares dos workers. Sem middlewares, o
+// processamento é o de sempre.
+type PipelineConfig struct {
+	// Do mais externo ao mais interno, sem repetições
+	Middlewares []string `json:"middlewares" yaml:"middlewares"`
+	// dedup: por quanto tempo lembrar um correlationId aceito
+	DedupTTL Duration `json:"dedupTtl" yaml:"dedupTtl"`
+	// ratelimit: pagamentos por segundo e rajada; acima disso, voltam para a fila
+	Rate  float64 `json:"rate" yaml:"rate"`
+	Burst int     `json:"burst" yaml:"burst"`
+}
+
+// MemoryConfig protege a instância do OOM kill. O monitor lê o runtime.MemStats
+// a cada CheckInterval: acima da marca alta do orçamento, força um GC e recusa
+// novos pagamentos (503) até a memória baixar da marca baixa. As estruturas que
+// crescem sem o Redis têm teto e descartam as entradas mais antigas.
+type MemoryConfig struct {
+	// Orçamento em bytes; 0 usa o GOMEMLIMIT em vigor, e sem ele o monitor só mede
+	BudgetBytes int64 `json:"budgetBytes" yaml:"budgetBytes"`
+	// Frações do orçamento, com LowWatermark < HighWatermark
+	HighWatermark float64 `json:"highWatermark" yaml:"highWatermark"`
+	LowWatermark  float64 `json:"lowWatermark" yaml:"lowWatermark"`
+	// 0 desativa o monitor; os tetos continuam valendo
+	CheckInterval Duration `json:"checkInterval" yaml:"checkInterval"`
+	// Entradas da DLQ e do outbox guardadas em memória enquanto o Redis não responde
+	MaxDLQEntries    int `json:"maxDlqEntries" yaml:"maxDlqEntries"`
+	MaxOutboxEntries int `json:"maxOutboxEntries" yaml:"maxOutboxEntries"`
+	// correlationIds lembrados pelo middleware dedup
+	MaxDedupEntries int `json:"maxDedupEntries" yaml:"maxDedupEntries"`
+	// Pagamentos que um processor indicou já ter, com o dono confirmado
+	MaxDuplicateEntries int `json:"maxDuplicateEntries" yaml:"maxDuplicateEntries"`
+}
+
+// LoadShedConfig desliga o trabalho acessório (auditoria, logs das requisições,
+// registro de decisões, conferência por amostragem e histograma de valores)
+// enquanto a CPU usada pelo processo ou a fila em memória passam da marca alta,
+// e o religa abaixo da marca baixa. A validação e o envio dos pagamentos seguem
+// como antes.
+type LoadShedConfig struct {
+	// Frações da CPU disponível (cota do cgroup, ou as CPUs da máquina) usadas
+	// pelo processo, com CPULow < CPUHigh; CPUHigh 0 ignora a CPU
+	CPUHigh float64 `json:"cpuHigh" yaml:"cpuHigh"`
+	CPULow  float64 `json:"cpuLow" yaml:"cpuLow"`
+	// Pagamentos em memória (fila e fora do pool), com QueueLow < QueueHigh;
+	// QueueHigh 0 ignora a fila
+	QueueHigh int `json:"queueHigh" yaml:"queueHigh"`
+	QueueLow  int `json:"queueLow" yaml:"queueLow"`
+	// 0 desativa o monitor
+	CheckInterval Duration `json:"checkInterval" yaml:"checkInterval"`
+}
+
+// SummaryCheckConfig controla a conferência periódica dos contadores com o resumo
+// administrativo de cada processor. Cada janela de Interval é conferida uma vez,
+// Lag depois do seu fim, para não contar como divergência os pagamentos em andamento
+// (com requestedAt "ingestion", isso inclui o tempo na fila).
+type SummaryCheckConfig struct {
+	Enabled  bool     `json:"enabled" yaml:"enabled"`
+	Interval Duration `json:"interval" yaml:"interval"`
+	Lag      Duration `json:"lag" yaml:"lag"`
+	// Token de /admin/payments-summary (X-Rinha-Token)
+	AdminToken string `json:"adminToken" yaml:"adminToken"`
+	// Soma a diferença encontrada aos contadores do resumo sem filtro
+	Correct bool `json:"correct" yaml:"correct"`
+}
+
+// VerifySampleConfig controla a conferência, logo depois do envio, de uma fração
+// dos pagamentos aceitos no processor que os aceitou.
+type VerifySampleConfig struct {
+	// Fração dos pagamentos aceitos conferidos, de 0 a 1; 0 desativa
+	Rate float64 `json:"rate" yaml:"rate"`
+	// Últimas conferências de cada processor que formam a taxa de acerto
+	Window int `json:"window" yaml:"window"`
+}
+
+// AuthConfig protege as rotas administrativas. Um grupo sem chave nem allowlist
+// fica aberto, como antes.
+type AuthConfig struct {
+	// Header com a chave de API
+	Header string `json:"header" yaml:"header"`
+	// /admin e /purge-payments
+	Admin AuthGroupConfig `json:"admin" yaml:"admin"`
+	// /debug, no router principal ou na porta de diagnóstico
+	Debug AuthGroupConfig `json:"debug" yaml:"debug"`
+}
+
+type AuthGroupConfig struct {
+	// Chave exigida no header; vazio não exige
+	APIKey string `json:"apiKey" yaml:"apiKey"`
+	// IPs ou CIDRs de origem aceitos; vazio aceita qualquer origem
+	AllowIPs []string `json:"allowIps" yaml:"allowIps"`
+}
+
+// ParseAllowIPs converte a allowlist em prefixos; um IP sem máscara vale sozinho.
+func ParseAllowIPs(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, raw := range list {
+		raw = strings.TrimSpace(raw)
+		if strings.Contains(raw, "/") {
+			prefix, err := netip.ParsePrefix(raw)
+			if err != nil {
+				return nil, fmt.Errorf("CIDR inválido: %q", raw)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(raw)
+		if err != nil {
+			return nil, fmt.Errorf("IP inválido: %q", raw)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// SelectorConfig reúne os parâmetros da estratégia por pontuação.
+type SelectorConfig struct {
+	// "score" (padrão), "failover" ou "profit"
+	Strategy string `json:"strategy" yaml:"strategy"`
+	// Acima deste minResponseTime (ms) um processor só é preferido se todos estiverem acima
+	LatencyThresholdMs int `json:"latencyThresholdMs" yaml:"latencyThresholdMs"`
+	// Taxas de default e fallback quando processors.list não é informada
+	DefaultFee  float64 `json:"defaultFee" yaml:"defaultFee"`
+	FallbackFee float64 `json:"fallbackFee" yaml:"fallbackFee"`
+	// Pesos do custo: FeeWeight*taxa + LatencyWeight*latência(s)
+	FeeWeight     float64 `json:"feeWeight" yaml:"feeWeight"`
+	LatencyWeight float64 `json:"latencyWeight" yaml:"latencyWeight"`
+	// Quantil da latência observada que substitui o minResponseTime, quando há
+	// ao menos MinLatencySamples amostras
+	LatencyQuantile   float64 `json:"latencyQuantile" yaml:"latencyQuantile"`
+	MinLatencySamples int64   `json:"minLatencySamples" yaml:"minLatencySamples"`
+	// Janela das estatísticas de latência e de desfechos dos envios
+	LatencyWindow Duration `json:"latencyWindow" yaml:"latencyWindow"`
+	// Temperatura do sorteio da estratégia "profit": a cada Temperature de valor
+	// esperado (fração do amount) a menos, a chance de um processor cai e vezes
+	Temperature float64 `json:"temperature" yaml:"temperature"`
+	// Registrar cada decisão de roteamento no stream routing:decisions do Redis,
+	// para o cmd/replay comparar estratégias
+	DecisionLog bool `json:"decisionLog" yaml:"decisionLog"`
+	// Tamanho aproximado do stream; as decisões mais antigas saem primeiro
+	DecisionLogMaxLen int64 `json:"decisionLogMaxLen" yaml:"decisionLogMaxLen"`
+}
+
+// Duration aceita valores como "250ms" ou "5s" em JSON e YAML.
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duração deve ser uma string como \"5s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func defaultConfig() Config {
+	return Config{
+		Port:               "8080",
+		Listeners:          1,
+		AckMode:            AckImmediate,
+		RequestedAt:        RequestedAtIngestion,
+		RequestedAtRetry:   RequestedAtPreserve,
+		RequestedAtMaxSkew: Duration(30 * time.Second),
+		LogLevel:           LogInfo,
+		LogBuffer:          4096,
+		Socket: SocketConfig{
+			Mode: "0666",
+		},
+		Server: ServerConfig{
+			TCPNoDelay: true,
+		},
+		RunMode: RunSingle,
+		Prefork: PreforkConfig{
+			RestartDelay:    Duration(time.Second),
+			MaxRestartDelay: Duration(30 * time.Second),
+		},
+		// No docker-compose cada backend tem 150MB dos 350MB da stack: com 90%
+		// o heap fica em ~135MB e o GC só aperta perto do limite
+		Runtime: RuntimeConfig{
+			AutoMaxProcs:     true,
+			GCPercent:        100,
+			MemoryLimitRatio: 0.9,
+		},
+		Processors: ProcessorsConfig{
+			DefaultURL:  "http://payment-processor-default:8080",
+			FallbackURL: "http://payment-processor-fallback:8080",
+		},
+		HTTP: HTTPClientConfig{
+			Timeout:            Duration(10 * time.Second),
+			AttemptTimeout:     Duration(5 * time.Second),
+			HealthCheckTimeout: Duration(2 * time.Second),
+			DNSFailureCooldown: Duration(5 * time.Second),
+		},
+		Retry: RetryConfig{
+			MaxAttempts:      3,
+			BaseDelay:        Duration(time.Second),
+			MaxDelay:         Duration(4 * time.Second),
+			Jitter:           0.2,
+			RetryOnStatus:    "408,429,5xx",
+			PaymentBudget:    Duration(30 * time.Second),
+			VerifyBeforeFail: true,
+			DuplicateStatus:  "409,422",
+			TerminalStatus:   "400,413,415,422",
+			AmbiguousStatus:  "504",
+		},
+		Workers: WorkersConfig{
+			Count:             100,
+			QueueSize:         10000,
+			PriorityBurst:     4,
+			PanicRetries:      2,
+			RebalanceInterval: Duration(time.Second),
+		},
+		Limiter: LimiterConfig{
+			Enabled:          true,
+			Initial:          20,
+			Min:              1,
+			Max:              200,
+			Backoff:          0.7,
+			LatencyThreshold: Duration(3 * time.Second),
+			RequeueDelay:     Duration(10 * time.Millisecond),
+		},
+		Pacing: PacingConfig{
+			Enabled:     false,
+			Concurrency: 50,
+			Burst:       10,
+			MaxWait:     Duration(time.Second),
+		},
+		SLO: SLOConfig{
+			P99Budget:    Duration(500 * time.Millisecond),
+			Window:       Duration(10 * time.Second),
+			MinSamples:   50,
+			RecoverRatio: 0.8,
+			ShiftRate:    0.5,
+			MaxAttempts:  1,
+			Interval:     Duration(time.Second),
+		},
+		FallbackBudget: FallbackBudgetConfig{
+			Window: Duration(10 * time.Second),
+			Delay:  Duration(100 * time.Millisecond),
+		},
+		Failback: FailbackConfig{
+			ProbeRate: 0.05,
+			Successes: 5,
+		},
+		Hedging: HedgingConfig{
+			Delay: Duration(500 * time.Millisecond),
+		},
+		Validation: ValidationConfig{
+			MaxAmount: 1_000_000,
+			// O payload esperado tem menos de 100 bytes
+			MaxBodyBytes:        1024,
+			MaxBatchItems:       500,
+			MaxBatchBodyBytes:   256 << 10,
+			MaxMetadataBytes:    256,
+			AmountConflictCheck: ConflictCheckQueue,
+		},
+		RateLimit: RateLimitConfig{
+			GlobalRate:  5000,
+			GlobalBurst: 10000,
+			ClientRate:  0,
+			ClientBurst: 1000,
+		},
+		Redis: RedisConfig{
+			Mode:         RedisSingle,
+			Addr:         "localhost:6379",
+			PoolSize:     0, // 0 = padrão do go-redis
+			DialTimeout:  Duration(5 * time.Second),
+			ReadTimeout:  Duration(3 * time.Second),
+			WriteTimeout: Duration(3 * time.Second),
+
+			ReconnectMinBackoff: Duration(500 * time.Millisecond),
+			ReconnectMaxBackoff: Duration(10 * time.Second),
+			PingInterval:        Duration(time.Second),
+			OpTimeout:           Duration(time.Second),
+			SlowCallThreshold:   Duration(50 * time.Millisecond),
+			Writer: RedisWriterConfig{
+				Enabled:   true,
+				BatchSize: 128,
+				Linger:    Duration(200 * time.Microsecond),
+				QueueSize: 4096,
+			},
+			RunIDTTL: Duration(30 * time.Second),
+		},
+		Storage: StorageConfig{
+			Backend:      "redis",
+			BoltPath:     "payments.db",
+			AmountBounds: []float64{10, 20, 50, 100, 200, 500, 1000, 5000, 10000},
+			TopAmounts:   100,
+		},
+		Selector: SelectorConfig{
+			Strategy:           "score",
+			LatencyThresholdMs: 1000,
+			DefaultFee:         0.05,
+			FallbackFee:        0.15,
+			FeeWeight:          1.0,
+			LatencyWeight:      0.1,
+			LatencyQuantile:    0.95,
+			MinLatencySamples:  20,
+			LatencyWindow:      Duration(30 * time.Second),
+			Temperature:        0.02,
+			DecisionLogMaxLen:  100_000,
+		},
+		DLQ: DLQConfig{
+			RedriveInterval: Duration(5 * time.Second),
+		},
+		Outbox: OutboxConfig{
+			Enabled:           true,
+			ReconcileInterval: Duration(10 * time.Second),
+			ReconcileAfter:    Duration(45 * time.Second),
+		},
+		Counters: CountersConfig{
+			FlushInterval:  Duration(50 * time.Millisecond),
+			FlushBatchSize: 200,
+			// Pouco acima do flush: o cache não esconde mais que um ciclo de escrita
+			SummaryCacheTTL: Duration(200 * time.Millisecond),
+			AmountRounding:  RoundHalfEven,
+			ExactlyOnce:     true,
+			CountedTTL:      Duration(time.Hour),
+			Snapshot: SnapshotConfig{
+				Interval: Duration(5 * time.Second),
+			},
+		},
+		Peers: PeersConfig{
+			QueueThreshold: 1000,
+			Timeout:        Duration(500 * time.Millisecond),
+		},
+		Warmup: WarmupConfig{
+			Connections: 10,
+			// Abaixo do IdleConnTimeout do transport (90s)
+			PingInterval: Duration(30 * time.Second),
+		},
+		DryRun: DryRunConfig{
+			Latency: Duration(10 * time.Millisecond),
+		},
+		Chaos: ChaosConfig{
+			Processor: ChaosFaults{Delay: Duration(time.Second)},
+			Redis:     ChaosFaults{Delay: Duration(100 * time.Millisecond)},
+		},
+		Webhook: WebhookConfig{
+			Workers:       4,
+			QueueSize:     1000,
+			Timeout:       Duration(2 * time.Second),
+			MaxAttempts:   5,
+			BaseDelay:     Duration(time.Second),
+			MaxDelay:      Duration(30 * time.Second),
+			RetryOnStatus: "408,429,5xx",
+		},
+		Stream: StreamConfig{
+			BufferSize: 256,
+		},
+		Events: EventsConfig{
+			BufferSize: 16384,
+		},
+		Compression: CompressionConfig{
+			Enabled:  true,
+			MinBytes: 1024,
+			Level:    1,
+			Requests: true,
+		},
+		CORS: CORSConfig{
+			Enabled:      true,
+			AllowOrigins: []string{"*"},
+			AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowHeaders: []string{"*"},
+			MaxAge:       Duration(12 * time.Hour),
+		},
+		Audit: AuditConfig{
+			MaxLen:        100_000,
+			FlushInterval: Duration(100 * time.Millisecond),
+		},
+		StatusIndex: StatusIndexConfig{
+			MaxLen:        100_000,
+			FlushInterval: Duration(100 * time.Millisecond),
+		},
+		Queue: QueueConfig{
+			Backend:        "memory",
+			Name:           "payments:queue",
+			NATSURL:        "nats://localhost:4222",
+			Prefetch:       64,
+			PublishTimeout: Duration(time.Second),
+			AckWait:        Duration(5 * time.Minute),
+		},
+		Pipeline: PipelineConfig{
+			DedupTTL: Duration(5 * time.Minute),
+			Burst:    100,
+		},
+		Memory: MemoryConfig{
+			HighWatermark:    0.9,
+			LowWatermark:     0.75,
+			CheckInterval:    Duration(250 * time.Millisecond),
+			MaxDLQEntries:    10_000,
+			MaxOutboxEntries: 10_000,
+			MaxDedupEntries:  100_000,
+			// Raros: só repetições de pagamentos já aceitos
+			MaxDuplicateEntries: 10_000,
+		},
+		LoadShed: LoadShedConfig{
+			CPUHigh: 0.95,
+			CPULow:  0.8,
+		},
+		Auth: AuthConfig{
+			Header: "X-API-Key",
+		},
+		Health: HealthConfig{
+			ProbeMargin: Duration(200 * time.Millisecond),
+			Slot:        -1,
+			Inference: InferenceConfig{
+				Enabled:       true,
+				Window:        Duration(10 * time.Second),
+				MinSamples:    20,
+				ErrorRate:     0.5,
+				CheckInterval: Duration(30 * time.Second),
+			},
+			History: HealthHistoryConfig{
+				Size:         256,
+				StreamMaxLen: 10_000,
+			},
+		},
+		SummaryCheck: SummaryCheckConfig{
+			Interval: Duration(30 * time.Second),
+			Lag:      Duration(90 * time.Second),
+			// Token padrão dos processors da rinha
+			AdminToken: "123",
+		},
+		VerifySample: VerifySampleConfig{
+			Window: 100,
+		},
+		ShutdownTimeout: Duration(10 * time.Second),
+	}
+}
+
+// Load monta a configuração efetiva e a valida.
+func Load() (Config, error) {
+	cfg := defaultConfig()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadConfigFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Reloadable é a parte da configuração trocada sem reiniciar (SIGHUP ou PUT
+// /admin/config). rateLimit.enabled, selector.latencyWindow e as taxas do
+// selector só são lidos na inicialização e mantêm o valor em vigor.
+type Reloadable struct {
+	Retry     RetryConfig     `json:"retry" yaml:"retry"`
+	Selector  SelectorConfig  `json:"selector" yaml:"selector"`
+	RateLimit RateLimitConfig `json:"rateLimit" yaml:"rateLimit"`
+	LogLevel  string          `json:"logLevel" yaml:"logLevel"`
+}
+
+// Reloadable retorna a parte recarregável da configuração.
+func (c Config) Reloadable() Reloadable {
+	return Reloadable{Retry: c.Retry, Selector: c.Selector, RateLimit: c.RateLimit, LogLevel: c.LogLevel}
+}
+
+// WithReloadable retorna a configuração com r aplicado, já validada.
+func (c Config) WithReloadable(r Reloadable) (Config, error) {
+	r.RateLimit.Enabled = c.RateLimit.Enabled
+	r.Selector.LatencyWindow = c.Selector.LatencyWindow
+	r.Selector.DefaultFee = c.Selector.DefaultFee
+	r.Selector.FallbackFee = c.Selector.FallbackFee
+
+	c.Retry = r.Retry
+	c.Selector = r.Selector
+	c.RateLimit = r.RateLimit
+	c.LogLevel = r.LogLevel
+	return c, c.Validate()
+}
+
+func loadConfigFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("erro ao ler %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, cfg)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("formato de configuração não suportado: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao decodificar %s: %w", path, err)
+	}
+	return nil
+}
+
+// envLoader acumula erros de conversão para reportar todos de uma vez.
+type envLoader struct {
+	errs []error
+}
+
+func (l *envLoader) str(dst *string, name string) {
+	if v := os.Getenv(name); v != "" {
+		*dst = v
+	}
+}
+
+func (l *envLoader) bool(dst *bool, name string) {
+	if v := os.Getenv(name); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q não é um booleano", name, v))
+			return
+		}
+		*dst = b
+	}
+}
+
+func (l *envLoader) int(dst *int, name string) {
+	if v := os.Getenv(name); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q não é um inteiro", name, v))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (l *envLoader) int64(dst *int64, name string) {
+	if v := os.Getenv(name); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q não é um inteiro", name, v))
+			return
+		}
+		*dst = n
+	}
+}
+
+// floats lê uma lista de números separada por vírgulas, ex.: "10,50,100".
+func (l *envLoader) floats(dst *[]float64, name string) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	var values []float64
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q não é um número", name, item))
+			return
+		}
+		values = append(values, f)
+	}
+	*dst = values
+}
+
+func (l *envLoader) float(dst *float64, name string) {
+	if v := os.Getenv(name); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q não é um número", name, v))
+			return
+		}
+		*dst = f
+	}
+}
+
+// list lê uma lista separada por vírgulas, ignorando itens vazios.
+func (l *envLoader) list(dst *[]string, name string) {
+	if v := os.Getenv(name); v != "" {
+		*dst = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*dst = append(*dst, item)
+			}
+		}
+	}
+}
+
+// rates lê pares "nome=valor" separados por vírgulas, ex.: "default=800,fallback=300".
+func (l *envLoader) rates(dst *map[string]float64, name string) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	rates := make(map[string]float64)
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q deve ter o formato nome=valor", name, item))
+			return
+		}
+		rates[strings.TrimSpace(key)] = f
+	}
+	*dst = rates
+}
+
+func (l *envLoader) headers(dst *map[string]string, name string) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	headers := make(map[string]string)
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q deve ter o formato Nome=valor", name, item))
+			return
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	*dst = headers
+}
+
+func (l *envLoader) duration(dst *Duration, name string) {
+	if v := os.Getenv(name); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q não é uma duração válida", name, v))
+			return
+		}
+		*dst = Duration(d)
+	}
+}
+
+func applyEnv(cfg *Config) error {
+	var l envLoader
+
+	l.str(&cfg.Port, "PORT")
+	l.int(&cfg.Listeners, "LISTENERS")
+	l.str(&cfg.AckMode, "ACK_MODE")
+	l.str(&cfg.LogLevel, "LOG_LEVEL")
+	l.int(&cfg.LogBuffer, "LOG_BUFFER")
+	l.str(&cfg.RequestedAt, "REQUESTED_AT")
+	l.str(&cfg.RequestedAtRetry, "REQUESTED_AT_RETRY")
+	l.duration(&cfg.RequestedAtMaxSkew, "REQUESTED_AT_MAX_SKEW")
+	l.str(&cfg.Socket.Path, "LISTEN_SOCKET")
+	l.str(&cfg.Socket.Mode, "LISTEN_SOCKET_MODE")
+	l.bool(&cfg.Socket.Only, "LISTEN_SOCKET_ONLY")
+	l.duration(&cfg.Server.ReadTimeout, "SERVER_READ_TIMEOUT")
+	l.duration(&cfg.Server.ReadHeaderTimeout, "SERVER_READ_HEADER_TIMEOUT")
+	l.duration(&cfg.Server.WriteTimeout, "SERVER_WRITE_TIMEOUT")
+	l.duration(&cfg.Server.IdleTimeout, "SERVER_IDLE_TIMEOUT")
+	l.int(&cfg.Server.MaxHeaderBytes, "SERVER_MAX_HEADER_BYTES")
+	l.bool(&cfg.Server.TCPNoDelay, "SERVER_TCP_NODELAY")
+	l.duration(&cfg.Server.TCPKeepAlive, "SERVER_TCP_KEEPALIVE")
+	l.duration(&cfg.Server.PreStopDelay, "SERVER_PRE_STOP_DELAY")
+	l.str(&cfg.Instance.ID, "INSTANCE_ID")
+	l.bool(&cfg.Instance.Header, "INSTANCE_HEADER")
+	l.str(&cfg.RunMode, "RUN_MODE")
+	l.int(&cfg.Prefork.Children, "PREFORK_CHILDREN")
+	l.duration(&cfg.Prefork.RestartDelay, "PREFORK_RESTART_DELAY")
+	l.duration(&cfg.Prefork.MaxRestartDelay, "PREFORK_MAX_RESTART_DELAY")
+	l.bool(&cfg.Runtime.AutoMaxProcs, "RUNTIME_AUTO_MAXPROCS")
+	l.int(&cfg.Runtime.GCPercent, "RUNTIME_GOGC")
+	l.int64(&cfg.Runtime.MemoryLimitBytes, "RUNTIME_MEMORY_LIMIT_BYTES")
+	l.float(&cfg.Runtime.MemoryLimitRatio, "RUNTIME_MEMORY_LIMIT_RATIO")
+	l.str(&cfg.TLS.CertFile, "CERT_FILE")
+	l.str(&cfg.TLS.KeyFile, "KEY_FILE")
+	l.str(&cfg.GRPC.Port, "GRPC_PORT")
+	l.str(&cfg.Processors.DefaultURL, "PAYMENT_PROCESSOR_URL_DEFAULT")
+	l.str(&cfg.Processors.FallbackURL, "PAYMENT_PROCESSOR_URL_FALLBACK")
+	l.bool(&cfg.Processors.ForwardMetadata, "PROCESSOR_FORWARD_METADATA")
+	if v := os.Getenv("PROCESSORS"); v != "" {
+		defs, err := parseProcessorList(v)
+		if err != nil {
+			l.errs = append(l.errs, err)
+		} else {
+			cfg.Processors.List = defs
+		}
+	}
+	for _, def := range cfg.ProcessorDefs() {
+		prefix := processorEnvPrefix(def.Name)
+		auth := cfg.Processors.Auth[def.Name]
+		l.str(&auth.BearerToken, prefix+"BEARER_TOKEN")
+		l.str(&auth.HMACSecret, prefix+"HMAC_SECRET")
+		l.str(&auth.SignatureHeader, prefix+"SIGNATURE_HEADER")
+		l.headers(&auth.Headers, prefix+"HEADERS")
+		if auth.BearerToken != "" || auth.HMACSecret != "" || auth.SignatureHeader != "" || len(auth.Headers) > 0 {
+			if cfg.Processors.Auth == nil {
+				cfg.Processors.Auth = make(map[string]ProcessorAuth)
+			}
+			cfg.Processors.Auth[def.Name] = auth
+		}
+
+		pool := cfg.Workers.Pools[def.Name]
+		l.int(&pool.Workers, prefix+"WORKERS")
+		l.int(&pool.QueueSize, prefix+"QUEUE_SIZE")
+		if pool != (WorkerPoolConfig{}) {
+			if cfg.Workers.Pools == nil {
+				cfg.Workers.Pools = make(map[string]WorkerPoolConfig)
+			}
+			cfg.Workers.Pools[def.Name] = pool
+		}
+
+		limits := cfg.Processors.Limits[def.Name]
+		l.float(&limits.MaxAmount, prefix+"MAX_AMOUNT")
+		l.float(&limits.MaxTPS, prefix+"MAX_TPS")
+		if limits != (ProcessorLimits{}) {
+			if cfg.Processors.Limits == nil {
+				cfg.Processors.Limits = make(map[string]ProcessorLimits)
+			}
+			cfg.Processors.Limits[def.Name] = limits
+		}
+	}
+
+	l.duration(&cfg.HTTP.Timeout, "HTTP_CLIENT_TIMEOUT")
+	l.duration(&cfg.HTTP.AttemptTimeout, "PROCESSOR_ATTEMPT_TIMEOUT")
+	l.duration(&cfg.HTTP.HealthCheckTimeout, "HEALTH_CHECK_TIMEOUT")
+	l.str(&cfg.HTTP.HTTP2, "PROCESSOR_HTTP2")
+	l.str(&cfg.HTTP.CAFile, "PROCESSOR_CA_FILE")
+	l.str(&cfg.HTTP.ClientCertFile, "PROCESSOR_CLIENT_CERT_FILE")
+	l.str(&cfg.HTTP.ClientKeyFile, "PROCESSOR_CLIENT_KEY_FILE")
+	l.bool(&cfg.HTTP.InsecureSkipVerify, "PROCESSOR_TLS_INSECURE_SKIP_VERIFY")
+	l.duration(&cfg.HTTP.DNSRefresh, "PROCESSOR_DNS_REFRESH")
+	l.duration(&cfg.HTTP.DNSFailureCooldown, "PROCESSOR_DNS_FAILURE_COOLDOWN")
+
+	l.int(&cfg.Retry.MaxAttempts, "RETRY_MAX_ATTEMPTS")
+	l.duration(&cfg.Retry.BaseDelay, "RETRY_BASE_DELAY")
+	l.duration(&cfg.Retry.MaxDelay, "RETRY_MAX_DELAY")
+	l.float(&cfg.Retry.Jitter, "RETRY_JITTER")
+	l.str(&cfg.Retry.RetryOnStatus, "RETRY_ON_STATUS")
+	l.duration(&cfg.Retry.PaymentBudget, "PAYMENT_DEADLINE_BUDGET")
+	l.bool(&cfg.Retry.VerifyBeforeFail, "RETRY_VERIFY_BEFORE_FAIL")
+	l.str(&cfg.Retry.DuplicateStatus, "RETRY_DUPLICATE_STATUS")
+	l.str(&cfg.Retry.TerminalStatus, "RETRY_TERMINAL_STATUS")
+	l.str(&cfg.Retry.AmbiguousStatus, "RETRY_AMBIGUOUS_STATUS")
+
+	l.int(&cfg.Workers.Count, "WORKER_COUNT")
+	l.int(&cfg.Workers.QueueSize, "QUEUE_SIZE")
+	l.float(&cfg.Workers.PriorityAmount, "PRIORITY_AMOUNT")
+	l.int(&cfg.Workers.PriorityBurst, "PRIORITY_BURST")
+	l.int(&cfg.Workers.MaxQueueDepth, "MAX_QUEUE_DEPTH")
+	l.int(&cfg.Workers.PanicRetries, "WORKER_PANIC_RETRIES")
+	l.duration(&cfg.Workers.RebalanceInterval, "WORKER_REBALANCE_INTERVAL")
+
+	l.str(&cfg.Queue.Backend, "QUEUE_BACKEND")
+	l.str(&cfg.Queue.Name, "QUEUE_NAME")
+	l.str(&cfg.Queue.Consumer, "QUEUE_CONSUMER")
+	l.str(&cfg.Queue.NATSURL, "NATS_URL")
+	l.int(&cfg.Queue.Prefetch, "QUEUE_PREFETCH")
+	l.duration(&cfg.Queue.PublishTimeout, "QUEUE_PUBLISH_TIMEOUT")
+	l.duration(&cfg.Queue.AckWait, "QUEUE_ACK_WAIT")
+
+	l.list(&cfg.Pipeline.Middlewares, "PIPELINE_MIDDLEWARES")
+	l.duration(&cfg.Pipeline.DedupTTL, "PIPELINE_DEDUP_TTL")
+	l.float(&cfg.Pipeline.Rate, "PIPELINE_RATE")
+	l.int(&cfg.Pipeline.Burst, "PIPELINE_BURST")
+
+	l.int64(&cfg.Memory.BudgetBytes, "MEMORY_BUDGET_BYTES")
+	l.float(&cfg.Memory.HighWatermark, "MEMORY_HIGH_WATERMARK")
+	l.float(&cfg.Memory.LowWatermark, "MEMORY_LOW_WATERMARK")
+	l.duration(&cfg.Memory.CheckInterval, "MEMORY_CHECK_INTERVAL")
+	l.int(&cfg.Memory.MaxDLQEntries, "MEMORY_MAX_DLQ_ENTRIES")
+	l.int(&cfg.Memory.MaxOutboxEntries, "MEMORY_MAX_OUTBOX_ENTRIES")
+	l.int(&cfg.Memory.MaxDedupEntries, "MEMORY_MAX_DEDUP_ENTRIES")
+	l.int(&cfg.Memory.MaxDuplicateEntries, "MEMORY_MAX_DUPLICATE_ENTRIES")
+
+	l.float(&cfg.LoadShed.CPUHigh, "LOAD_SHED_CPU_HIGH")
+	l.float(&cfg.LoadShed.CPULow, "LOAD_SHED_CPU_LOW")
+	l.int(&cfg.LoadShed.QueueHigh, "LOAD_SHED_QUEUE_HIGH")
+	l.int(&cfg.LoadShed.QueueLow, "LOAD_SHED_QUEUE_LOW")
+	l.duration(&cfg.LoadShed.CheckInterval, "LOAD_SHED_CHECK_INTERVAL")
+
+	l.bool(&cfg.Limiter.Enabled, "LIMITER_ENABLED")
+	l.int(&cfg.Limiter.Initial, "LIMITER_INITIAL")
+	l.int(&cfg.Limiter.Min, "LIMITER_MIN")
+	l.int(&cfg.Limiter.Max, "LIMITER_MAX")
+	l.float(&cfg.Limiter.Backoff, "LIMITER_BACKOFF")
+	l.duration(&cfg.Limiter.LatencyThreshold, "LIMITER_LATENCY_THRESHOLD")
+	l.duration(&cfg.Limiter.RequeueDelay, "LIMITER_REQUEUE_DELAY")
+	l.bool(&cfg.Pacing.Enabled, "DISPATCH_PACING")
+	l.rates(&cfg.Pacing.RPS, "DISPATCH_PACING_RPS")
+	l.float(&cfg.Pacing.DefaultRPS, "DISPATCH_PACING_DEFAULT_RPS")
+	l.int(&cfg.Pacing.Concurrency, "DISPATCH_PACING_CONCURRENCY")
+	l.int(&cfg.Pacing.Burst, "DISPATCH_PACING_BURST")
+	l.duration(&cfg.Pacing.MaxWait, "DISPATCH_PACING_MAX_WAIT")
+
+	l.bool(&cfg.Hedging.Enabled, "HEDGING_ENABLED")
+	l.duration(&cfg.Hedging.Delay, "HEDGE_DELAY")
+	l.float(&cfg.Failback.ProbeRate, "FAILBACK_PROBE_RATE")
+	l.int(&cfg.Failback.Successes, "FAILBACK_SUCCESSES")
+	l.bool(&cfg.SLO.Enabled, "SLO_GUARD_ENABLED")
+	l.duration(&cfg.SLO.P99Budget, "SLO_P99_BUDGET")
+	l.duration(&cfg.SLO.Window, "SLO_WINDOW")
+	l.int64(&cfg.SLO.MinSamples, "SLO_MIN_SAMPLES")
+	l.float(&cfg.SLO.RecoverRatio, "SLO_RECOVER_RATIO")
+	l.float(&cfg.SLO.ShiftRate, "SLO_SHIFT_RATE")
+	l.int(&cfg.SLO.MaxAttempts, "SLO_MAX_ATTEMPTS")
+	l.duration(&cfg.SLO.Interval, "SLO_CHECK_INTERVAL")
+	l.bool(&cfg.FallbackBudget.Enabled, "FALLBACK_BUDGET_ENABLED")
+	l.duration(&cfg.FallbackBudget.Window, "FALLBACK_BUDGET_WINDOW")
+	l.float(&cfg.FallbackBudget.MaxAmount, "FALLBACK_BUDGET_MAX_AMOUNT")
+	l.float(&cfg.FallbackBudget.MaxShare, "FALLBACK_BUDGET_MAX_SHARE")
+	l.duration(&cfg.FallbackBudget.Delay, "FALLBACK_BUDGET_DELAY")
+
+	l.float(&cfg.Validation.MaxAmount, "MAX_PAYMENT_AMOUNT")
+	l.int64(&cfg.Validation.MaxBodyBytes, "MAX_BODY_BYTES")
+	l.int(&cfg.Validation.MaxBatchItems, "MAX_BATCH_ITEMS")
+	l.int64(&cfg.Validation.MaxBatchBodyBytes, "MAX_BATCH_BODY_BYTES")
+	l.int(&cfg.Validation.MaxMetadataBytes, "MAX_METADATA_BYTES")
+	l.str(&cfg.Validation.AmountConflictCheck, "AMOUNT_CONFLICT_CHECK")
+
+	l.bool(&cfg.RateLimit.Enabled, "RATE_LIMIT_ENABLED")
+	l.float(&cfg.RateLimit.GlobalRate, "RATE_LIMIT_GLOBAL_RPS")
+	l.int(&cfg.RateLimit.GlobalBurst, "RATE_LIMIT_GLOBAL_BURST")
+	l.float(&cfg.RateLimit.ClientRate, "RATE_LIMIT_CLIENT_RPS")
+	l.int(&cfg.RateLimit.ClientBurst, "RATE_LIMIT_CLIENT_BURST")
+
+	l.str(&cfg.Redis.Mode, "REDIS_MODE")
+	l.str(&cfg.Redis.Addr, "REDIS_ADDR")
+	l.list(&cfg.Redis.Addrs, "REDIS_ADDRS")
+	l.str(&cfg.Redis.MasterName, "REDIS_MASTER_NAME")
+	l.str(&cfg.Redis.SentinelPassword, "REDIS_SENTINEL_PASSWORD")
+	l.str(&cfg.Redis.Password, "REDIS_PASSWORD")
+	l.int(&cfg.Redis.DB, "REDIS_DB")
+	l.int(&cfg.Redis.PoolSize, "REDIS_POOL_SIZE")
+	l.duration(&cfg.Redis.DialTimeout, "REDIS_DIAL_TIMEOUT")
+	l.duration(&cfg.Redis.ReadTimeout, "REDIS_READ_TIMEOUT")
+	l.duration(&cfg.Redis.WriteTimeout, "REDIS_WRITE_TIMEOUT")
+	l.duration(&cfg.Redis.ReconnectMinBackoff, "REDIS_RECONNECT_MIN_BACKOFF")
+	l.duration(&cfg.Redis.ReconnectMaxBackoff, "REDIS_RECONNECT_MAX_BACKOFF")
+	l.duration(&cfg.Redis.PingInterval, "REDIS_PING_INTERVAL")
+	l.duration(&cfg.Redis.OpTimeout, "REDIS_OP_TIMEOUT")
+	l.duration(&cfg.Redis.SlowCallThreshold, "REDIS_SLOW_CALL_THRESHOLD")
+	l.bool(&cfg.Redis.Writer.Enabled, "REDIS_WRITER")
+	l.int(&cfg.Redis.Writer.BatchSize, "REDIS_WRITER_BATCH_SIZE")
+	l.duration(&cfg.Redis.Writer.Linger, "REDIS_WRITER_LINGER")
+	l.int(&cfg.Redis.Writer.QueueSize, "REDIS_WRITER_QUEUE_SIZE")
+	l.str(&cfg.Redis.RunID, "RUN_ID")
+	l.duration(&cfg.Redis.RunIDTTL, "RUN_ID_TTL")
+
+	l.str(&cfg.Storage.Backend, "STORAGE_BACKEND")
+	l.str(&cfg.Storage.PostgresDSN, "POSTGRES_DSN")
+	l.str(&cfg.Storage.BoltPath, "BOLT_PATH")
+	l.duration(&cfg.Storage.RecordTTL, "STORAGE_RECORD_TTL")
+	l.floats(&cfg.Storage.AmountBounds, "STORAGE_AMOUNT_BOUNDS")
+	l.int(&cfg.Storage.TopAmounts, "STORAGE_TOP_AMOUNTS")
+
+	l.str(&cfg.Selector.Strategy, "SELECTOR_STRATEGY")
+	l.int(&cfg.Selector.LatencyThresholdMs, "SELECTOR_LATENCY_THRESHOLD_MS")
+	l.float(&cfg.Selector.DefaultFee, "PROCESSOR_FEE_DEFAULT")
+	l.float(&cfg.Selector.FallbackFee, "PROCESSOR_FEE_FALLBACK")
+	l.float(&cfg.Selector.FeeWeight, "SELECTOR_FEE_WEIGHT")
+	l.float(&cfg.Selector.LatencyWeight, "SELECTOR_LATENCY_WEIGHT")
+	l.float(&cfg.Selector.LatencyQuantile, "SELECTOR_LATENCY_QUANTILE")
+	l.int64(&cfg.Selector.MinLatencySamples, "SELECTOR_MIN_LATENCY_SAMPLES")
+	l.duration(&cfg.Selector.LatencyWindow, "LATENCY_STATS_WINDOW")
+	l.float(&cfg.Selector.Temperature, "SELECTOR_TEMPERATURE")
+	l.bool(&cfg.Selector.DecisionLog, "SELECTOR_DECISION_LOG")
+	l.int64(&cfg.Selector.DecisionLogMaxLen, "SELECTOR_DECISION_LOG_MAXLEN")
+
+	l.duration(&cfg.DLQ.RedriveInterval, "DLQ_REDRIVE_INTERVAL")
+
+	l.bool(&cfg.Outbox.Enabled, "OUTBOX_ENABLED")
+	l.duration(&cfg.Outbox.ReconcileInterval, "OUTBOX_RECONCILE_INTERVAL")
+	l.duration(&cfg.Outbox.ReconcileAfter, "OUTBOX_RECONCILE_AFTER")
+
+	l.duration(&cfg.Counters.FlushInterval, "COUNTER_FLUSH_INTERVAL")
+	l.int(&cfg.Counters.FlushBatchSize, "COUNTER_FLUSH_BATCH_SIZE")
+	l.duration(&cfg.Counters.SummaryCacheTTL, "SUMMARY_CACHE_TTL")
+	l.str(&cfg.Counters.AmountRounding, "AMOUNT_ROUNDING")
+	l.str(&cfg.Counters.WALDir, "COUNTER_WAL_DIR")
+	l.bool(&cfg.Counters.ExactlyOnce, "COUNTER_EXACTLY_ONCE")
+	l.duration(&cfg.Counters.CountedTTL, "COUNTER_COUNTED_TTL")
+	l.str(&cfg.Counters.Snapshot.Path, "COUNTER_SNAPSHOT_PATH")
+	l.str(&cfg.Counters.Snapshot.RedisAddr, "COUNTER_SNAPSHOT_REDIS_ADDR")
+	l.str(&cfg.Counters.Snapshot.RedisPassword, "COUNTER_SNAPSHOT_REDIS_PASSWORD")
+	l.duration(&cfg.Counters.Snapshot.Interval, "COUNTER_SNAPSHOT_INTERVAL")
+	l.bool(&cfg.Counters.Snapshot.Payments, "COUNTER_SNAPSHOT_PAYMENTS")
+
+	l.bool(&cfg.Debug.Enabled, "DEBUG_ENDPOINTS")
+	l.str(&cfg.Debug.Port, "DEBUG_PORT")
+
+	l.list(&cfg.Peers.URLs, "PEER_URLS")
+	l.int(&cfg.Peers.QueueThreshold, "PEER_FORWARD_THRESHOLD")
+	l.duration(&cfg.Peers.Timeout, "PEER_TIMEOUT")
+	l.bool(&cfg.Peers.AggregateSummary, "CLUSTER_SUMMARY")
+
+	l.int(&cfg.Warmup.Connections, "WARMUP_CONNECTIONS")
+	l.duration(&cfg.Warmup.PingInterval, "WARMUP_PING_INTERVAL")
+
+	l.bool(&cfg.DryRun.Enabled, "DRY_RUN")
+	l.duration(&cfg.DryRun.Latency, "DRY_RUN_LATENCY")
+	l.float(&cfg.DryRun.FailureRate, "DRY_RUN_FAILURE_RATE")
+
+	l.float(&cfg.Chaos.Processor.DelayRate, "CHAOS_PROCESSOR_DELAY_RATE")
+	l.duration(&cfg.Chaos.Processor.Delay, "CHAOS_PROCESSOR_DELAY")
+	l.float(&cfg.Chaos.Processor.FailRate, "CHAOS_PROCESSOR_FAIL_RATE")
+	l.float(&cfg.Chaos.Processor.TimeoutRate, "CHAOS_PROCESSOR_TIMEOUT_RATE")
+	l.float(&cfg.Chaos.Redis.DelayRate, "CHAOS_REDIS_DELAY_RATE")
+	l.duration(&cfg.Chaos.Redis.Delay, "CHAOS_REDIS_DELAY")
+	l.float(&cfg.Chaos.Redis.FailRate, "CHAOS_REDIS_FAIL_RATE")
+	l.float(&cfg.Chaos.Redis.TimeoutRate, "CHAOS_REDIS_TIMEOUT_RATE")
+
+	l.str(&cfg.Webhook.URL, "WEBHOOK_URL")
+	l.list(&cfg.Webhook.AllowedHosts, "WEBHOOK_ALLOWED_HOSTS")
+	l.str(&cfg.Webhook.Secret, "WEBHOOK_SECRET")
+	l.int(&cfg.Webhook.Workers, "WEBHOOK_WORKERS")
+	l.int(&cfg.Webhook.QueueSize, "WEBHOOK_QUEUE_SIZE")
+	l.duration(&cfg.Webhook.Timeout, "WEBHOOK_TIMEOUT")
+	l.int(&cfg.Webhook.MaxAttempts, "WEBHOOK_MAX_ATTEMPTS")
+	l.duration(&cfg.Webhook.BaseDelay, "WEBHOOK_BASE_DELAY")
+	l.duration(&cfg.Webhook.MaxDelay, "WEBHOOK_MAX_DELAY")
+	l.str(&cfg.Webhook.RetryOnStatus, "WEBHOOK_RETRY_ON_STATUS")
+
+	l.int(&cfg.Stream.BufferSize, "STREAM_BUFFER_SIZE")
+	l.int(&cfg.Events.BufferSize, "EVENT_BUS_BUFFER_SIZE")
+	l.bool(&cfg.Compression.Enabled, "COMPRESSION")
+	l.int(&cfg.Compression.MinBytes, "COMPRESSION_MIN_BYTES")
+	l.int(&cfg.Compression.Level, "COMPRESSION_LEVEL")
+	l.bool(&cfg.Compression.Requests, "COMPRESSION_REQUESTS")
+
+	l.bool(&cfg.CORS.Enabled, "CORS_ENABLED")
+	l.list(&cfg.CORS.AllowOrigins, "CORS_ALLOW_ORIGINS")
+	l.list(&cfg.CORS.AllowMethods, "CORS_ALLOW_METHODS")
+	l.list(&cfg.CORS.AllowHeaders, "CORS_ALLOW_HEADERS")
+	l.list(&cfg.CORS.ExposeHeaders, "CORS_EXPOSE_HEADERS")
+	l.bool(&cfg.CORS.AllowCredentials, "CORS_ALLOW_CREDENTIALS")
+	l.duration(&cfg.CORS.MaxAge, "CORS_MAX_AGE")
+
+	l.bool(&cfg.Audit.Enabled, "AUDIT_LOG")
+	l.int64(&cfg.Audit.MaxLen, "AUDIT_MAX_LEN")
+	l.duration(&cfg.Audit.FlushInterval, "AUDIT_FLUSH_INTERVAL")
+	l.duration(&cfg.Audit.TTL, "AUDIT_TTL")
+	l.bool(&cfg.StatusIndex.Enabled, "STATUS_INDEX")
+	l.int64(&cfg.StatusIndex.MaxLen, "STATUS_INDEX_MAX_LEN")
+	l.duration(&cfg.StatusIndex.FlushInterval, "STATUS_INDEX_FLUSH_INTERVAL")
+
+	l.duration(&cfg.Health.ProbeMargin, "HEALTH_PROBE_MARGIN")
+	l.int(&cfg.Health.Instances, "HEALTH_INSTANCES")
+	l.int(&cfg.Health.Slot, "HEALTH_SLOT")
+	l.bool(&cfg.Health.Inference.Enabled, "HEALTH_INFERENCE")
+	l.duration(&cfg.Health.Inference.Window, "HEALTH_INFERENCE_WINDOW")
+	l.int(&cfg.Health.Inference.MinSamples, "HEALTH_INFERENCE_MIN_SAMPLES")
+	l.float(&cfg.Health.Inference.ErrorRate, "HEALTH_INFERENCE_ERROR_RATE")
+	l.int(&cfg.Health.Inference.LatencyMs, "HEALTH_INFERENCE_LATENCY_MS")
+	l.duration(&cfg.Health.Inference.CheckInterval, "HEALTH_INFERENCE_CHECK_INTERVAL")
+	l.int(&cfg.Health.History.Size, "HEALTH_HISTORY_SIZE")
+	l.bool(&cfg.Health.History.Stream, "HEALTH_HISTORY_STREAM")
+	l.int64(&cfg.Health.History.StreamMaxLen, "HEALTH_HISTORY_STREAM_MAX_LEN")
+
+	l.str(&cfg.Auth.Header, "AUTH_HEADER")
+	l.str(&cfg.Auth.Admin.APIKey, "ADMIN_API_KEY")
+	l.list(&cfg.Auth.Admin.AllowIPs, "ADMIN_ALLOW_IPS")
+	l.str(&cfg.Auth.Debug.APIKey, "DEBUG_API_KEY")
+	l.list(&cfg.Auth.Debug.AllowIPs, "DEBUG_ALLOW_IPS")
+
+	l.bool(&cfg.SummaryCheck.Enabled, "SUMMARY_CHECK")
+	l.duration(&cfg.SummaryCheck.Interval, "SUMMARY_CHECK_INTERVAL")
+	l.duration(&cfg.SummaryCheck.Lag, "SUMMARY_CHECK_LAG")
+	l.str(&cfg.SummaryCheck.AdminToken, "PROCESSOR_ADMIN_TOKEN")
+	l.bool(&cfg.SummaryCheck.Correct, "SUMMARY_CHECK_CORRECT")
+
+	l.float(&cfg.VerifySample.Rate, "VERIFY_SAMPLE_RATE")
+	l.int(&cfg.VerifySample.Window, "VERIFY_SAMPLE_WINDOW")
+
+	l.duration(&cfg.ShutdownTimeout, "SHUTDOWN_TIMEOUT")
+
+	return errors.Join(l.errs...)
+}
+
+// Validate retorna todos os problemas encontrados na configuração.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	switch c.AckMode {
+	case AckImmediate, AckEnqueued, AckSync:
+	default:
+		check(false, "ackMode desconhecido: %q", c.AckMode)
+	}
+
+	switch c.RequestedAt {
+	case RequestedAtIngestion, RequestedAtSend:
+	default:
+		check(false, "requestedAt desconhecido: %q", c.RequestedAt)
+	}
+	switch c.RequestedAtRetry {
+	case RequestedAtPreserve, RequestedAtRefresh:
+	case RequestedAtMaxSkew:
+		check(c.RequestedAtMaxSkew > 0, "requestedAtMaxSkew deve ser positivo com requestedAtRetry max-skew")
+	default:
+		check(false, "requestedAtRetry desconhecido: %q", c.RequestedAtRetry)
+	}
+
+	switch c.LogLevel {
+	case LogInfo, LogWarn:
+	default:
+		check(false, "logLevel desconhecido: %q", c.LogLevel)
+	}
+	check(c.LogBuffer >= 0, "logBuffer não pode ser negativo")
+
+	port, err := strconv.Atoi(c.Port)
+	check(err == nil && port > 0 && port < 65536, "port inválida: %q", c.Port)
+	check(c.Listeners >= 0, "listeners não pode ser negativo")
+
+	_, err = strconv.ParseUint(c.Socket.Mode, 8, 32)
+	check(err == nil, "socket.mode deve ser octal, ex.: \"0666\": %q", c.Socket.Mode)
+	check(!c.Socket.Only || c.Socket.Path != "", "socket.only exige socket.path")
+	check(c.Server.ReadTimeout >= 0, "server.readTimeout não pode ser negativo")
+	check(c.Server.ReadHeaderTimeout >= 0, "server.readHeaderTimeout não pode ser negativo")
+	check(c.Server.WriteTimeout >= 0, "server.writeTimeout não pode ser negativo")
+	check(c.Server.IdleTimeout >= 0, "server.idleTimeout não pode ser negativo")
+	check(c.Server.MaxHeaderBytes >= 0, "server.maxHeaderBytes não pode ser negativo")
+	check(c.Server.PreStopDelay >= 0, "server.preStopDelay não pode ser negativo")
+	check(validRunID(c.Instance.ID), "instance.id deve ter até %d letras, dígitos, '.', '_' ou '-': %q", maxRunIDLen, c.Instance.ID)
+	check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "tls.certFile e tls.keyFile devem ser definidos juntos")
+
+	check(c.Runtime.GCPercent >= -1, "runtime.gcPercent deve ser -1 (desligado) ou não negativo")
+	check(c.Runtime.MemoryLimitBytes >= 0, "runtime.memoryLimitBytes não pode ser negativo")
+	check(c.Runtime.MemoryLimitRatio > 0 && c.Runtime.MemoryLimitRatio <= 1, "runtime.memoryLimitRatio deve estar em (0, 1]")
+
+	switch c.RunMode {
+	case RunSingle:
+	case RunPrefork:
+		check(c.Prefork.Children >= 0, "prefork.children não pode ser negativo")
+		check(c.Prefork.RestartDelay > 0, "prefork.restartDelay deve ser positivo")
+		check(c.Prefork.MaxRestartDelay >= c.Prefork.RestartDelay, "prefork.maxRestartDelay deve ser pelo menos prefork.restartDelay")
+		// Os filhos dividem só a porta TCP: o socket unix tem um dono, e os
+		// backends locais separariam os pagamentos por processo
+		check(c.Socket.Path == "", "runMode prefork não suporta socket.path")
+		check(c.Storage.Backend != "memory" && c.Storage.Backend != "bolt", "runMode prefork exige storage compartilhado (redis ou postgres)")
+	default:
+		check(false, "runMode desconhecido: %q", c.RunMode)
+	}
+
+	if c.GRPC.Port != "" {
+		grpcPort, err := strconv.Atoi(c.GRPC.Port)
+		check(err == nil && grpcPort > 0 && grpcPort < 65536, "grpc.port inválida: %q", c.GRPC.Port)
+		check(c.GRPC.Port != c.Port, "grpc.port deve ser diferente de port")
+	}
+
+	names := make(map[string]bool)
+	for _, def := range c.ProcessorDefs() {
+		check(def.Name != "", "processors.list: nome obrigatório")
+		check(!names[def.Name], "processors.list: nome repetido: %q", def.Name)
+		check(ValidURL(def.URL), "processors.list: url inválida para %q: %q", def.Name, def.URL)
+		check(def.Fee >= 0 && def.Fee <= 1, "processors.list: taxa de %q deve estar entre 0 e 1", def.Name)
+		names[def.Name] = true
+	}
+	for name, auth := range c.Processors.Auth {
+		check(names[name], "processors.auth: processor desconhecido: %q", name)
+		check(auth.SignatureHeader == "" || auth.HMACSecret != "",
+			"processors.auth: signatureHeader de %q exige hmacSecret", name)
+		check(validHeaderName(auth.SignatureHeader), "processors.auth: signatureHeader inválido para %q: %q", name, auth.SignatureHeader)
+		for header, value := range auth.Headers {
+			check(header != "" && validHeaderName(header), "processors.auth: header inválido para %q: %q", name, header)
+			check(!strings.ContainsAny(value, "\r\n"), "processors.auth: valor do header %q de %q não pode ter quebra de linha", header, name)
+		}
+	}
+	for name, limits := range c.Processors.Limits {
+		check(names[name], "processors.limits: processor desconhecido: %q", name)
+		check(limits.MaxAmount >= 0, "processors.limits: maxAmount de %q não pode ser negativo", name)
+		check(limits.MaxTPS >= 0, "processors.limits: maxTps de %q não pode ser negativo", name)
+	}
+
+	check(c.HTTP.Timeout > 0, "http.timeout deve ser positivo")
+	check(c.HTTP.HTTP2 == "" || c.HTTP.HTTP2 == "h2c", "http.http2 desconhecido: %q", c.HTTP.HTTP2)
+	check(c.HTTP.AttemptTimeout > 0, "http.attemptTimeout deve ser positivo")
+	check(c.HTTP.HealthCheckTimeout > 0, "http.healthCheckTimeout deve ser positivo")
+	check(c.HTTP.DNSRefresh >= 0, "http.dnsRefresh não pode ser negativo")
+	check(c.HTTP.DNSRefresh == 0 || c.HTTP.DNSFailureCooldown > 0, "http.dnsFailureCooldown deve ser positivo")
+	check((c.HTTP.ClientCertFile == "") == (c.HTTP.ClientKeyFile == ""),
+		"http.clientCertFile e http.clientKeyFile devem ser definidos juntos")
+
+	check(c.Retry.MaxAttempts >= 1, "retry.maxAttempts deve ser ao menos 1")
+	check(c.Retry.BaseDelay >= 0, "retry.baseDelay não pode ser negativo")
+	check(c.Retry.MaxDelay >= c.Retry.BaseDelay, "retry.maxDelay deve ser maior ou igual a retry.baseDelay")
+	check(c.Retry.Jitter >= 0 && c.Retry.Jitter <= 1, "retry.jitter deve estar entre 0 e 1")
+	if _, err := retry.ParseStatusList(c.Retry.RetryOnStatus); err != nil {
+		errs = append(errs, fmt.Errorf("retry.retryOnStatus: %w", err))
+	}
+	if _, err := retry.ParseStatusList(c.Retry.DuplicateStatus); err != nil {
+		errs = append(errs, fmt.Errorf("retry.duplicateStatus: %w", err))
+	}
+	if _, err := retry.ParseStatusList(c.Retry.TerminalStatus); err != nil {
+		errs = append(errs, fmt.Errorf("retry.terminalStatus: %w", err))
+	}
+	if _, err := retry.ParseStatusList(c.Retry.AmbiguousStatus); err != nil {
+		errs = append(errs, fmt.Errorf("retry.ambiguousStatus: %w", err))
+	}
+	check(c.Retry.PaymentBudget > 0, "retry.paymentBudget deve ser positivo")
+
+	check(c.Workers.Count >= 1, "workers.count deve ser ao menos 1")
+	check(c.Workers.QueueSize >= 0, "workers.queueSize não pode ser negativo")
+	check(c.Workers.MaxQueueDepth >= 0, "workers.maxQueueDepth não pode ser negativo")
+	check(c.Workers.PanicRetries >= 0, "workers.panicRetries não pode ser negativo")
+	check(c.Workers.PriorityAmount >= 0, "workers.priorityAmount não pode ser negativo")
+	check(c.Workers.PriorityAmount == 0 || c.Workers.PriorityBurst >= 1, "workers.priorityBurst deve ser ao menos 1")
+	for name, pool := range c.Workers.Pools {
+		check(names[name], "workers.pools: processor desconhecido: %q", name)
+		check(pool.Workers >= 1, "workers.pools: workers de %q deve ser ao menos 1", name)
+		check(pool.QueueSize >= 0, "workers.pools: queueSize de %q não pode ser negativo", name)
+	}
+	check(len(c.Workers.Pools) == 0 || c.Workers.RebalanceInterval > 0, "workers.rebalanceInterval deve ser positivo com workers.pools")
+	switch c.Queue.Backend {
+	case "memory":
+	case "redis", "nats":
+		check(c.Queue.Name != "", "queue.name não pode ser vazio")
+		check(c.Queue.Prefetch >= 1, "queue.prefetch deve ser ao menos 1")
+		check(c.Queue.PublishTimeout > 0, "queue.publishTimeout deve ser positivo")
+		check(c.Queue.Backend != "nats" || c.Queue.NATSURL != "", "queue.natsUrl não pode ser vazio com queue.backend nats")
+		check(c.Queue.Backend != "nats" || c.Queue.AckWait > 0, "queue.ackWait deve ser positivo")
+	default:
+		check(false, "queue.backend deve ser memory, redis ou nats: %q", c.Queue.Backend)
+	}
+	middlewares := make(map[string]bool, len(c.Pipeline.Middlewares))
+	for _, name := range c.Pipeline.Middlewares {
+		if middlewares[name] {
+			check(false, "pipeline.middlewares: %q repetido", name)
+			continue
+		}
+		middlewares[name] = true
+		switch name {
+		case MiddlewareValidation, MiddlewareMetrics, MiddlewareAudit:
+		case MiddlewareDedup:
+			check(c.Pipeline.DedupTTL > 0, "pipeline.dedupTtl deve ser positivo")
+		case MiddlewareRateLimit:
+			check(c.Pipeline.Rate > 0, "pipeline.rate deve ser positivo")
+			check(c.Pipeline.Burst >= 1, "pipeline.burst deve ser ao menos 1")
+		default:
+			check(false, "pipeline.middlewares: middleware desconhecido: %q", name)
+		}
+	}
+
+	check(c.Memory.BudgetBytes >= 0, "memory.budgetBytes não pode ser negativo")
+	check(c.Memory.LowWatermark > 0 && c.Memory.LowWatermark < c.Memory.HighWatermark && c.Memory.HighWatermark <= 1,
+		"memory: deve valer 0 < lowWatermark < highWatermark <= 1")
+	check(c.Memory.CheckInterval >= 0, "memory.checkInterval não pode ser negativo")
+	check(c.Memory.MaxDLQEntries >= 1, "memory.maxDlqEntries deve ser ao menos 1")
+	check(c.Memory.MaxOutboxEntries >= 1, "memory.maxOutboxEntries deve ser ao menos 1")
+	check(c.Memory.MaxDedupEntries >= 1, "memory.maxDedupEntries deve ser ao menos 1")
+	check(c.Memory.MaxDuplicateEntries >= 1, "memory.maxDuplicateEntries deve ser ao menos 1")
+
+	check(c.LoadShed.CheckInterval >= 0, "loadShed.checkInterval não pode ser negativo")
+	if c.LoadShed.CPUHigh != 0 {
+		check(c.LoadShed.CPULow > 0 && c.LoadShed.CPULow < c.LoadShed.CPUHigh && c.LoadShed.CPUHigh <= 1,
+			"loadShed: deve valer 0 < cpuLow < cpuHigh <= 1")
+	}
+	if c.LoadShed.QueueHigh != 0 {
+		check(c.LoadShed.QueueLow > 0 && c.LoadShed.QueueLow < c.LoadShed.QueueHigh,
+			"loadShed: deve valer 0 < queueLow < queueHigh")
+	}
+	check(c.LoadShed.CheckInterval == 0 || c.LoadShed.CPUHigh > 0 || c.LoadShed.QueueHigh > 0,
+		"loadShed.checkInterval exige cpuHigh ou queueHigh")
+
+	if c.Limiter.Enabled {
+		check(c.Limiter.Min >= 1, "limiter.min deve ser ao menos 1")
+		check(c.Limiter.Max >= c.Limiter.Min, "limiter.max deve ser maior ou igual a limiter.min")
+		check(c.Limiter.Initial >= c.Limiter.Min && c.Limiter.Initial <= c.Limiter.Max,
+			"limiter.initial deve estar entre limiter.min e limiter.max")
+		check(c.Limiter.Backoff > 0 && c.Limiter.Backoff < 1, "limiter.backoff deve estar entre 0 e 1")
+		check(c.Limiter.LatencyThreshold >= 0, "limiter.latencyThreshold não pode ser negativo")
+		check(c.Limiter.RequeueDelay >= 0, "limiter.requeueDelay não pode ser negativo")
+	}
+	if c.Pacing.Enabled {
+		for name, rps := range c.Pacing.RPS {
+			check(names[name], "pacing.rps: processor desconhecido: %q", name)
+			check(rps > 0, "pacing.rps de %q deve ser positivo", name)
+		}
+		check(c.Pacing.DefaultRPS >= 0, "pacing.defaultRps não pode ser negativo")
+		check(c.Pacing.Concurrency >= 0, "pacing.concurrency não pode ser negativo")
+		check(c.Pacing.Burst >= 1, "pacing.burst deve ser ao menos 1")
+		check(c.Pacing.MaxWait >= 0, "pacing.maxWait não pode ser negativo")
+	}
+
+	check(!c.Hedging.Enabled || c.Hedging.Delay > 0, "hedging.delay deve ser positivo")
+	check(c.Failback.ProbeRate >= 0 && c.Failback.ProbeRate <= 1, "failback.probeRate deve estar entre 0 e 1")
+	check(c.Failback.ProbeRate == 0 || c.Failback.Successes >= 1, "failback.successes deve ser ao menos 1")
+	if c.SLO.Enabled {
+		check(c.SLO.P99Budget > 0, "slo.p99Budget deve ser positivo")
+		check(c.SLO.Window > 0, "slo.window deve ser positivo")
+		check(c.SLO.MinSamples >= 1, "slo.minSamples deve ser ao menos 1")
+		check(c.SLO.RecoverRatio > 0 && c.SLO.RecoverRatio <= 1, "slo.recoverRatio deve estar entre 0 e 1")
+		check(c.SLO.ShiftRate >= 0 && c.SLO.ShiftRate <= 1, "slo.shiftRate deve estar entre 0 e 1")
+		check(c.SLO.MaxAttempts >= 0, "slo.maxAttempts não pode ser negativo")
+		check(c.SLO.Interval > 0, "slo.interval deve ser positivo")
+	}
+	if c.FallbackBudget.Enabled {
+		check(c.FallbackBudget.Window >= Duration(time.Second), "fallbackBudget.window deve ser de ao menos 1s")
+		check(c.FallbackBudget.MaxAmount >= 0, "fallbackBudget.maxAmount não pode ser negativo")
+		check(c.FallbackBudget.MaxShare >= 0 && c.FallbackBudget.MaxShare <= 1, "fallbackBudget.maxShare deve estar entre 0 e 1")
+		check(c.FallbackBudget.MaxAmount > 0 || c.FallbackBudget.MaxShare > 0, "fallbackBudget exige maxAmount ou maxShare")
+		check(c.FallbackBudget.Delay > 0, "fallbackBudget.delay deve ser positivo")
+	}
+
+	check(c.Validation.MaxAmount >= 0, "validation.maxAmount não pode ser negativo")
+	check(c.Validation.MaxBodyBytes >= 1, "validation.maxBodyBytes deve ser ao menos 1")
+	check(c.Validation.MaxBatchItems >= 1, "validation.maxBatchItems deve ser ao menos 1")
+	check(c.Validation.MaxMetadataBytes >= 0, "validation.maxMetadataBytes não pode ser negativo")
+	switch c.Validation.AmountConflictCheck {
+	case ConflictCheckOff, ConflictCheckQueue, ConflictCheckStorage:
+	default:
+		check(false, "validation.amountConflictCheck desconhecido: %q", c.Validation.AmountConflictCheck)
+	}
+	check(c.Validation.MaxBatchBodyBytes >= c.Validation.MaxBodyBytes,
+		"validation.maxBatchBodyBytes deve ser maior ou igual a validation.maxBodyBytes")
+	// O lote é enfileirado inteiro ou recusado
+	check(c.Validation.MaxBatchItems <= c.Workers.QueueSize || c.Workers.QueueSize == 0,
+		"validation.maxBatchItems não pode passar de workers.queueSize")
+	check(c.Validation.MaxBatchItems <= c.Workers.MaxQueueDepth || c.Workers.MaxQueueDepth == 0,
+		"validation.maxBatchItems não pode passar de workers.maxQueueDepth")
+
+	if c.RateLimit.Enabled {
+		check(c.RateLimit.GlobalRate >= 0, "rateLimit.globalRate não pode ser negativo")
+		check(c.RateLimit.ClientRate >= 0, "rateLimit.clientRate não pode ser negativo")
+		check(c.RateLimit.GlobalRate == 0 || c.RateLimit.GlobalBurst >= 1, "rateLimit.globalBurst deve ser ao menos 1")
+		check(c.RateLimit.ClientRate == 0 || c.RateLimit.ClientBurst >= 1, "rateLimit.clientBurst deve ser ao menos 1")
+	}
+
+	switch c.Redis.Mode {
+	case RedisSingle:
+		check(c.Redis.Addr != "", "redis.addr é obrigatório")
+	case RedisSentinel:
+		check(c.Redis.MasterName != "", "redis.masterName é obrigatório com redis.mode sentinel")
+	case RedisCluster:
+		// O Cluster só tem o banco 0
+		check(c.Redis.DB == 0, "redis.db deve ser 0 com redis.mode cluster")
+	default:
+		check(false, "redis.mode desconhecido: %q", c.Redis.Mode)
+	}
+	if c.Redis.Mode != RedisSingle {
+		check(len(c.Redis.RedisAddrs()) > 0 && c.Redis.RedisAddrs()[0] != "", "redis.addrs é obrigatório com redis.mode %s", c.Redis.Mode)
+	}
+	check(c.Redis.DB >= 0, "redis.db não pode ser negativo")
+	check(c.Redis.PoolSize >= 0, "redis.poolSize não pode ser negativo")
+	check(c.Redis.DialTimeout > 0, "redis.dialTimeout deve ser positivo")
+	check(c.Redis.OpTimeout > 0, "redis.opTimeout deve ser positivo")
+	check(c.Redis.SlowCallThreshold >= 0, "redis.slowCallThreshold não pode ser negativo")
+	if c.Redis.Writer.Enabled {
+		check(c.Redis.Writer.BatchSize > 0, "redis.writer.batchSize deve ser positivo")
+		check(c.Redis.Writer.Linger >= 0, "redis.writer.linger não pode ser negativo")
+		check(c.Redis.Writer.QueueSize > 0, "redis.writer.queueSize deve ser positivo")
+	}
+	check(c.Redis.ReconnectMinBackoff > 0, "redis.reconnectMinBackoff deve ser positivo")
+	check(c.Redis.ReconnectMaxBackoff >= c.Redis.ReconnectMinBackoff,
+		"redis.reconnectMaxBackoff deve ser maior ou igual a redis.reconnectMinBackoff")
+	check(c.Redis.PingInterval > 0, "redis.pingInterval deve ser positivo")
+	if c.Redis.RunID == RunIDAuto {
+		check(c.Redis.RunIDTTL >= Duration(time.Second), "redis.runIdTtl deve ser ao menos 1s com redis.runId auto")
+	} else {
+		check(validRunID(c.Redis.RunID), "redis.runId deve ter até %d letras, dígitos, '.', '_' ou '-': %q", maxRunIDLen, c.Redis.RunID)
+	}
+
+	switch c.Storage.Backend {
+	case "redis", "memory":
+	case "postgres":
+		check(c.Storage.PostgresDSN != "", "storage.postgresDsn é obrigatório com o backend postgres")
+	case "bolt":
+		check(c.Storage.BoltPath != "", "storage.boltPath é obrigatório com o backend bolt")
+	default:
+		check(false, "storage.backend desconhecido: %q", c.Storage.Backend)
+	}
+	check(c.Storage.RecordTTL >= 0, "storage.recordTtl não pode ser negativo")
+	check(len(c.Storage.AmountBounds) <= maxAmountBounds, "storage.amountBounds aceita até %d limites", maxAmountBounds)
+	for i, bound := range c.Storage.AmountBounds {
+		check(bound > 0 && (i == 0 || bound > c.Storage.AmountBounds[i-1]),
+			"storage.amountBounds deve ser crescente e positivo: %v", c.Storage.AmountBounds)
+	}
+	check(c.Storage.TopAmounts >= 0 && c.Storage.TopAmounts <= maxTopAmounts,
+		"storage.topAmounts deve estar entre 0 e %d", maxTopAmounts)
+
+	switch c.Selector.Strategy {
+	case "failover", "score", "profit":
+	default:
+		check(false, "selector.strategy desconhecida: %q", c.Selector.Strategy)
+	}
+	check(c.Selector.Temperature > 0, "selector.temperature deve ser positiva")
+	check(c.Selector.LatencyThresholdMs >= 0, "selector.latencyThresholdMs não pode ser negativo")
+	check(c.Selector.FeeWeight >= 0, "selector.feeWeight não pode ser negativo")
+	check(c.Selector.LatencyWeight >= 0, "selector.latencyWeight não pode ser negativo")
+	check(c.Selector.LatencyQuantile > 0 && c.Selector.LatencyQuantile <= 1, "selector.latencyQuantile deve estar entre 0 e 1")
+	check(c.Selector.MinLatencySamples >= 0, "selector.minLatencySamples não pode ser negativo")
+	check(c.Selector.LatencyWindow > 0, "selector.latencyWindow deve ser positivo")
+	check(!c.Selector.DecisionLog || c.Selector.DecisionLogMaxLen >= 1, "selector.decisionLogMaxLen deve ser ao menos 1")
+
+	check(c.DLQ.RedriveInterval > 0, "dlq.redriveInterval deve ser positivo")
+
+	if c.Outbox.Enabled {
+		check(c.Outbox.ReconcileInterval > 0, "outbox.reconcileInterval deve ser positivo")
+		check(c.Outbox.ReconcileAfter > c.Retry.PaymentBudget, "outbox.reconcileAfter deve ser maior que retry.paymentBudget")
+	}
+
+	check(c.Counters.FlushInterval > 0, "counters.flushInterval deve ser positivo")
+	check(c.Counters.FlushBatchSize >= 0, "counters.flushBatchSize não pode ser negativo")
+	check(c.Counters.SummaryCacheTTL >= 0, "counters.summaryCacheTtl não pode ser negativo")
+	check(!c.Counters.ExactlyOnce || c.Counters.CountedTTL >= Duration(time.Second), "counters.countedTtl deve ser ao menos 1s")
+	if c.Counters.Snapshot.Enabled() {
+		check(c.Counters.Snapshot.Interval >= Duration(100*time.Millisecond), "counters.snapshot.interval deve ser de ao menos 100ms")
+	}
+	switch c.Counters.AmountRounding {
+	case RoundHalfEven, RoundHalfUp:
+	default:
+		check(false, "counters.amountRounding desconhecido: %q", c.Counters.AmountRounding)
+	}
+
+	for _, u := range c.Peers.URLs {
+		check(ValidURL(u), "peers.urls: url inválida: %q", u)
+	}
+	check(!c.Peers.AggregateSummary || len(c.Peers.URLs) > 0, "peers.aggregateSummary exige peers.urls")
+	if len(c.Peers.URLs) > 0 {
+		check(c.Peers.QueueThreshold >= 1, "peers.queueThreshold deve ser ao menos 1")
+		check(c.Peers.Timeout > 0, "peers.timeout deve ser positivo")
+	}
+
+	check(c.Warmup.Connections >= 0, "warmup.connections não pode ser negativo")
+	check(c.Warmup.Connections == 0 || c.Warmup.PingInterval > 0, "warmup.pingInterval deve ser positivo")
+
+	if c.DryRun.Enabled {
+		check(c.DryRun.Latency >= 0, "dryRun.latency não pode ser negativo")
+		check(c.DryRun.FailureRate >= 0 && c.DryRun.FailureRate <= 1, "dryRun.failureRate deve estar entre 0 e 1")
+	}
+
+	checkChaos := func(target string, faults ChaosFaults) {
+		check(faults.DelayRate >= 0 && faults.DelayRate <= 1, "chaos.%s.delayRate deve estar entre 0 e 1", target)
+		check(faults.Delay >= 0, "chaos.%s.delay não pode ser negativo", target)
+		check(faults.FailRate >= 0 && faults.TimeoutRate >= 0 && faults.FailRate+faults.TimeoutRate <= 1,
+			"chaos.%s: failRate + timeoutRate deve estar entre 0 e 1", target)
+	}
+	checkChaos("processor", c.Chaos.Processor)
+	checkChaos("redis", c.Chaos.Redis)
+
+	check(c.Webhook.URL == "" || ValidURL(c.Webhook.URL), "webhook.url inválida: %q", c.Webhook.URL)
+	for _, host := range c.Webhook.AllowedHosts {
+		check(host != "" && !strings.ContainsAny(host, "/@ "), "webhook.allowedHosts: host inválido %q", host)
+	}
+	check(c.Webhook.Workers >= 1, "webhook.workers deve ser ao menos 1")
+	check(c.Webhook.QueueSize >= 0, "webhook.queueSize não pode ser negativo")
+	check(c.Webhook.Timeout > 0, "webhook.timeout deve ser positivo")
+	check(c.Webhook.MaxAttempts >= 1, "webhook.maxAttempts deve ser ao menos 1")
+	check(c.Webhook.BaseDelay >= 0, "webhook.baseDelay não pode ser negativo")
+	check(c.Webhook.MaxDelay >= c.Webhook.BaseDelay, "webhook.maxDelay deve ser maior ou igual a webhook.baseDelay")
+	if _, err := retry.ParseStatusList(c.Webhook.RetryOnStatus); err != nil {
+		errs = append(errs, fmt.Errorf("webhook.retryOnStatus: %w", err))
+	}
+
+	check(c.Stream.BufferSize >= 1, "stream.bufferSize deve ser ao menos 1")
+	check(c.Events.BufferSize >= 1, "events.bufferSize deve ser ao menos 1")
+	if c.Compression.Enabled {
+		check(c.Compression.MinBytes >= 0, "compression.minBytes não pode ser negativo")
+		check(c.Compression.Level == -1 || (c.Compression.Level >= 1 && c.Compression.Level <= 9),
+			"compression.level deve ser -1 ou estar entre 1 e 9")
+	}
+	if c.CORS.Enabled {
+		check(len(c.CORS.AllowOrigins) > 0, "cors.allowOrigins é obrigatório com o CORS ativo")
+		check(len(c.CORS.AllowMethods) > 0, "cors.allowMethods é obrigatório com o CORS ativo")
+		for _, origin := range c.CORS.AllowOrigins {
+			if origin == "*" {
+				check(len(c.CORS.AllowOrigins) == 1, "cors.allowOrigins: \"*\" não se combina com outras origens")
+				check(!c.CORS.AllowCredentials, "cors.allowCredentials não vale com a origem \"*\"")
+				continue
+			}
+			u, err := url.Parse(origin)
+			check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.Path == "",
+				"cors.allowOrigins: origem inválida: %q", origin)
+		}
+		check(c.CORS.MaxAge >= 0, "cors.maxAge não pode ser negativo")
+	}
+
+	if c.Audit.Enabled {
+		check(c.Audit.MaxLen >= 1, "audit.maxLen deve ser ao menos 1")
+		check(c.Audit.FlushInterval > 0, "audit.flushInterval deve ser positivo")
+		check(c.Audit.TTL >= 0, "audit.ttl não pode ser negativo")
+	}
+	if c.StatusIndex.Enabled {
+		check(c.StatusIndex.MaxLen >= 1, "statusIndex.maxLen deve ser ao menos 1")
+		check(c.StatusIndex.FlushInterval > 0, "statusIndex.flushInterval deve ser positivo")
+	}
+
+	check(c.Health.ProbeMargin > 0, "health.probeMargin deve ser positivo")
+	check(c.Health.Instances >= 0, "health.instances não pode ser negativo")
+	check(c.Health.Slot < c.HealthInstances(), "health.slot deve ser menor que o número de instâncias")
+	check(c.Health.History.Size >= 0, "health.history.size não pode ser negativo")
+	check(!c.Health.History.Stream || c.Health.History.StreamMaxLen >= 1, "health.history.streamMaxLen deve ser ao menos 1")
+	if c.Health.Inference.Enabled {
+		inference := c.Health.Inference
+		check(inference.Window >= Duration(time.Second), "health.inference.window deve ser de ao menos 1s")
+		check(inference.MinSamples >= 1, "health.inference.minSamples deve ser ao menos 1")
+		check(inference.ErrorRate > 0 && inference.ErrorRate <= 1, "health.inference.errorRate deve estar em (0, 1]")
+		check(inference.LatencyMs >= 0, "health.inference.latencyMs não pode ser negativo")
+		check(inference.CheckInterval >= Duration(5*time.Second), "health.inference.checkInterval deve ser de ao menos 5s (limite do /service-health)")
+	}
+
+	check(c.Auth.Header != "", "auth.header é obrigatório")
+	if _, err := ParseAllowIPs(c.Auth.Admin.AllowIPs); err != nil {
+		errs = append(errs, fmt.Errorf("auth.admin.allowIps: %w", err))
+	}
+	if _, err := ParseAllowIPs(c.Auth.Debug.AllowIPs); err != nil {
+		errs = append(errs, fmt.Errorf("auth.debug.allowIps: %w", err))
+	}
+
+	if c.SummaryCheck.Enabled {
+		check(c.SummaryCheck.Interval > 0, "summaryCheck.interval deve ser positivo")
+		check(c.SummaryCheck.Lag > c.Retry.PaymentBudget, "summaryCheck.lag deve ser maior que retry.paymentBudget")
+		// Pagamentos reconciliados pelo outbox chegam depois e seriam corrigidos em dobro
+		check(!c.SummaryCheck.Correct || !c.Outbox.Enabled || c.SummaryCheck.Lag > c.Outbox.ReconcileAfter+c.Outbox.ReconcileInterval,
+			"summaryCheck.lag deve ser maior que outbox.reconcileAfter + outbox.reconcileInterval")
+	}
+
+	check(c.VerifySample.Rate >= 0 && c.VerifySample.Rate <= 1, "verifySample.rate deve estar entre 0 e 1")
+	check(c.VerifySample.Window > 0, "verifySample.window deve ser positivo")
+
+	if c.Debug.Enabled && c.Debug.Port != "" {
+		debugPort, err := strconv.Atoi(c.Debug.Port)
+		check(err == nil && debugPort > 0 && debugPort < 65536, "debug.port inválida: %q", c.Debug.Port)
+		check(c.Debug.Port != c.Port, "debug.port deve ser diferente de port")
+	}
+
+	check(c.ShutdownTimeout > 0, "shutdownTimeout deve ser positivo")
+
+	return errors.Join(errs...)
+}
+
+// ValidURL aceita apenas URLs http(s) absolutas.
+func ValidURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// validHeaderName aceita nomes de header sem espaços, dois-pontos ou controle;
+// vazio é aceito e tratado por quem chama.
+func validHeaderName(name string) bool {
+	for _, r := range name {
+		if r <= ' ' || r >= 0x7f || r == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// validRunID aceita o RUN_ID que vira prefixo das chaves: letras, dígitos, '.',
+// '_' e '-', sem chaves que mexam na hash tag; vazio é aceito (sem prefixo). O
+// INSTANCE_ID segue a mesma regra, por ir para headers e labels.
+func validRunID(id string) bool {
+	if len(id) > maxRunIDLen {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// String serializa a configuração para o log de inicialização, sem segredos.
+func (c Config) String() string {
+	if c.Redis.Password != "" {
+		c.Redis.Password = "***"
+	}
+	if c.Redis.SentinelPassword != "" {
+		c.Redis.SentinelPassword = "***"
+	}
+	if c.Storage.PostgresDSN != "" {
+		c.Storage.PostgresDSN = "***"
+	}
+	if c.Webhook.Secret != "" {
+		c.Webhook.Secret = "***"
+	}
+	if c.Auth.Admin.APIKey != "" {
+		c.Auth.Admin.APIKey = "***"
+	}
+	if c.Auth.Debug.APIKey != "" {
+		c.Auth.Debug.APIKey = "***"
+	}
+	if c.Counters.Snapshot.RedisPassword != "" {
+		c.Counters.Snapshot.RedisPassword = "***"
+	}
+	if c.SummaryCheck.AdminToken != "" {
+		c.SummaryCheck.AdminToken = "***"
+	}
+	if u, err := url.Parse(c.Queue.NATSURL); err == nil && u.User != nil {
+		// Usuário e senha ou só o token
+		u.User = url.User("***")
+		c.Queue.NATSURL = u.String()
+	}
+	if len(c.Processors.Auth) > 0 {
+		// O mapa é compartilhado com a configuração original
+		auth := make(map[string]ProcessorAuth, len(c.Processors.Auth))
+		for name, a := range c.Processors.Auth {
+			if a.BearerToken != "" {
+				a.BearerToken = "***"
+			}
+			if a.HMACSecret != "" {
+				a.HMACSecret = "***"
+			}
+			if len(a.Headers) > 0 {
+				headers := make(map[string]string, len(a.Headers))
+				for header := range a.Headers {
+					headers[header] = "***"
+				}
+				a.Headers = headers
+			}
+			auth[name] = a
+		}
+		c.Processors.Auth = auth
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("<erro ao serializar configuração: %v>", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,142 @@
+// Package events é o barramento interno de eventos: quem publica só deposita o
+// evento em um buffer, e uma goroutine o entrega aos inscritos em segundo plano.
+package events
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+)
+
+// Bus entrega cada evento publicado a todos os inscritos, na ordem de
+// publicação. Os handlers rodam um após o outro na mesma goroutine: devem ser
+// rápidos e nunca bloquear, repassando o trabalho demorado às próprias filas.
+type Bus[E any] struct {
+	events      chan E
+	subscribers []subscriber[E]
+
+	started atomic.Bool
+	closed  atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+
+	published atomic.Int64
+	dropped   atomic.Int64
+}
+
+type subscriber[E any] struct {
+	name string
+	fn   func(E)
+}
+
+// New cria o barramento com espaço para buffer eventos ainda não entregues;
+// acima disso Publish descarta o evento.
+func New[E any](buffer int) *Bus[E] {
+	return &Bus[E]{
+		events: make(chan E, buffer),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Subscribe inscreve fn para receber todos os eventos. Deve ser chamado antes de
+// Start.
+func (b *Bus[E]) Subscribe(name string, fn func(E)) {
+	if b.started.Load() {
+		panic("events: Subscribe depois de Start")
+	}
+	b.subscribers = append(b.subscribers, subscriber[E]{name: name, fn: fn})
+}
+
+// Start inicia a entrega dos eventos.
+func (b *Bus[E]) Start() {
+	if b.started.Swap(true) {
+		return
+	}
+	go b.run()
+}
+
+// Publish deposita o evento sem esperar. Retorna false quando o evento foi
+// descartado: buffer cheio ou barramento encerrado.
+func (b *Bus[E]) Publish(event E) bool {
+	if len(b.subscribers) == 0 {
+		return true
+	}
+	if b.closed.Load() {
+		b.dropped.Add(1)
+		return false
+	}
+	select {
+	case b.events <- event:
+		b.published.Add(1)
+		return true
+	default:
+		b.dropped.Add(1)
+		return false
+	}
+}
+
+// Close para de aceitar eventos e espera a entrega dos que já estão no buffer,
+// até o prazo de ctx.
+func (b *Bus[E]) Close(ctx context.Context) {
+	if b.closed.Swap(true) || !b.started.Load() {
+		return
+	}
+	close(b.stop)
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		log.Printf("Barramento de eventos encerrado com %d eventos não entregues", len(b.events))
+	}
+}
+
+func (b *Bus[E]) run() {
+	defer close(b.done)
+	for {
+		select {
+		case event := <-b.events:
+			b.deliver(event)
+		case <-b.stop:
+			// Entregar o que já estava no buffer
+			for {
+				select {
+				case event := <-b.events:
+					b.deliver(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (b *Bus[E]) deliver(event E) {
+	for _, sub := range b.subscribers {
+		b.call(sub, event)
+	}
+}
+
+// call isola os inscritos: o pânico de um não impede a entrega aos demais.
+func (b *Bus[E]) call(sub subscriber[E], event E) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Pânico no inscrito %s do barramento de eventos: %v", sub.name, r)
+		}
+	}()
+	sub.fn(event)
+}
+
+// Published é o total de eventos aceitos por Publish.
+func (b *Bus[E]) Published() int64 {
+	return b.published.Load()
+}
+
+// Dropped é o total de eventos descartados por Publish.
+func (b *Bus[E]) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// Depth é o número de eventos aguardando entrega.
+func (b *Bus[E]) Depth() int {
+	return len(b.events)
+}
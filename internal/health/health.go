@@ -0,0 +1,355 @@
+// Package health mantém o último health-check de cada processor. Com Redis, um
+// token global por processor garante no máximo uma consulta a /service-health
+// por intervalo somando todas as instâncias, e as demais leem o resultado
+// compartilhado.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/keyspace"
+	pp "rinha-backend-2025/internal/processor"
+)
+
+const (
+	// Os processors aceitam no máximo 1 chamada a cada 5s em /service-health
+	checkInterval = 5 * time.Second
+	// Espera antes de reler o estado compartilhado quando outra instância detém o lock
+	sharedRetryDelay = 250 * time.Millisecond
+	// Por quanto tempo, no mínimo, o último resultado fica disponível no Redis
+	sharedTTL = 30 * time.Second
+)
+
+// Status é o último health-check conhecido de um processor.
+type Status struct {
+	Failing         bool      `json:"failing"`
+	MinResponseTime int       `json:"minResponseTime"`
+	LastCheckedAt   time.Time `json:"lastCheckedAt"`
+}
+
+// Options são as dependências do Monitor.
+type Options struct {
+	// Nomes em ordem de prioridade
+	Names   []string
+	Clients map[string]pp.ProcessorClient
+	// Cliente Redis em uso; nil consulta sem compartilhar (modo degradado)
+	Redis func() redis.UniversalClient
+	// Namespace das chaves compartilhadas (RUN_ID)
+	Keys keyspace.Space
+	// Limite de cada consulta a /service-health
+	Timeout time.Duration
+	// Chamado quando um processor volta a responder depois de falhar; não deve bloquear
+	OnRecover func(processor string)
+	// Chamado a cada resultado novo de health-check, consultado por esta
+	// instância (probed) ou lido do Redis; não deve bloquear
+	OnUpdate func(processor string, status Status, probed bool)
+	// Folga somada à validade do token global
+	ProbeMargin time.Duration
+	// Instâncias consultando os mesmos processors; sem Redis, cada uma consulta
+	// só na própria fatia de um ciclo com Instances fatias
+	Instances int
+	// Fatia desta instância (0 a Instances-1); negativa sorteia pelo instanceID
+	Slot int
+	// Intervalo entre consultas de cada processor; abaixo de 5s (o limite do
+	// processor) usa 5s. Maior quando a saúde vem do próprio tráfego
+	Interval time.Duration
+	// Validade do cache e fatias das consultas; nil usa o relógio do sistema
+	Clock clock.Clock
+}
+
+// O token de um processor vale por checkInterval + ProbeMargin a partir de quem o
+// obteve; enquanto existe, ninguém mais consulta. Retorna 0 quando esta instância
+// obteve o token ou quantos ms faltam para ele expirar.
+var probeTokenScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 0
+end
+return redis.call("PTTL", KEYS[1])
+`)
+
+// Monitor guarda o estado de saúde dos processors e o atualiza sob demanda.
+type Monitor struct {
+	opts Options
+
+	mu          sync.RWMutex
+	cache       map[string]*Status
+	nextRefresh map[string]time.Time
+	refreshing  map[string]bool
+	// Última consulta feita sem o token global (modo degradado)
+	lastLocalProbe map[string]time.Time
+
+	// Identifica esta instância como dona do token de health-check
+	instanceID string
+	// Fatia das consultas sem Redis
+	slot int
+}
+
+func New(opts Options) *Monitor {
+	if opts.Clock == nil {
+		opts.Clock = clock.Real()
+	}
+	m := &Monitor{
+		opts:        opts,
+		cache:       make(map[string]*Status),
+		nextRefresh: make(map[string]time.Time),
+		refreshing:  make(map[string]bool),
+		instanceID:  newInstanceID(opts.Clock),
+
+		lastLocalProbe: make(map[string]time.Time),
+	}
+	if m.opts.Instances < 1 {
+		m.opts.Instances = 1
+	}
+	m.opts.Interval = max(m.opts.Interval, checkInterval)
+	m.slot = opts.Slot % m.opts.Instances
+	if opts.Slot < 0 {
+		h := fnv.New32a()
+		h.Write([]byte(m.instanceID))
+		m.slot = int(h.Sum32() % uint32(m.opts.Instances))
+	}
+
+	// Até a primeira consulta, latências crescentes com a prioridade
+	for i, name := range opts.Names {
+		m.cache[name] = &Status{
+			Failing:         false,
+			MinResponseTime: 100 * (i + 1),
+			LastCheckedAt:   time.Time{},
+		}
+	}
+	return m
+}
+
+func newInstanceID(clk clock.Clock) string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return hostname + "-" + clk.Now().UTC().Format("150405.000000")
+}
+
+// Get retorna o estado do processor, atualizando-o antes quando venceu.
+func (m *Monitor) Get(ctx context.Context, processor string) *Status {
+	m.mu.Lock()
+	cached := m.cache[processor]
+	// Apenas uma goroutine por processor atualiza; as demais usam o valor em cache
+	due := !m.refreshing[processor] && m.opts.Clock.Now().After(m.nextRefresh[processor])
+	if due {
+		m.refreshing[processor] = true
+	}
+	m.mu.Unlock()
+
+	if !due {
+		return cached
+	}
+
+	next := m.refresh(ctx, processor)
+
+	m.mu.Lock()
+	m.refreshing[processor] = false
+	m.nextRefresh[processor] = m.opts.Clock.Now().Add(next)
+	cached = m.cache[processor]
+	m.mu.Unlock()
+
+	return cached
+}
+
+// AllFailing indica que nenhum processor está aceitando pagamentos.
+func (m *Monitor) AllFailing(ctx context.Context) bool {
+	for _, name := range m.opts.Names {
+		if !m.Get(ctx, name).Failing {
+			return false
+		}
+	}
+	return true
+}
+
+// Snapshot copia o estado em cache, sem disparar consultas.
+func (m *Monitor) Snapshot() map[string]Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot := make(map[string]Status, len(m.cache))
+	for name, status := range m.cache {
+		snapshot[name] = *status
+	}
+	return snapshot
+}
+
+// refresh atualiza o cache local e retorna quando deve ser atualizado de novo.
+// Com Redis, só quem obtém o token global consulta o processor e publica o
+// resultado; as demais instâncias leem o resultado publicado. Sem Redis, cada
+// instância consulta na própria fatia de tempo (ver probeLocally).
+func (m *Monitor) refresh(ctx context.Context, processor string) time.Duration {
+	client := m.opts.Redis()
+	if client == nil {
+		return m.probeLocally(ctx, processor)
+	}
+
+	shared, err := m.readShared(ctx, client, processor)
+	if err != nil {
+		log.Printf("Erro ao ler health compartilhado do %s: %v", processor, err)
+		return m.probeLocally(ctx, processor)
+	}
+
+	if shared != nil {
+		if age := m.opts.Clock.Since(shared.LastCheckedAt); age < m.opts.Interval {
+			m.setLocal(processor, shared, false)
+			return m.opts.Interval - age
+		}
+	}
+
+	ttl := checkInterval + m.opts.ProbeMargin
+	wait, err := probeTokenScript.Run(ctx, client, []string{m.tokenKey(processor)}, m.instanceID, ttl.Milliseconds()).Int64()
+	if err != nil {
+		log.Printf("Erro ao obter token de health do %s: %v", processor, err)
+		return m.probeLocally(ctx, processor)
+	}
+
+	if wait != 0 {
+		// Outra instância tem o token: usar o último valor conhecido e reler em breve,
+		// quando ela já deve ter publicado
+		if shared != nil {
+			m.setLocal(processor, shared, false)
+		}
+		return sharedRetryDelay
+	}
+
+	if result := m.check(ctx, processor); result != nil {
+		m.publishShared(ctx, client, processor, result, max(sharedTTL, 2*m.opts.Interval))
+	}
+	return m.opts.Interval
+}
+
+// probeLocally consulta sem o token global. Cada instância consulta só dentro da
+// própria fatia de Interval + ProbeMargin, em um ciclo de Instances fatias,
+// para que as instâncias não se atropelem no limite do processor. Com a fatia
+// sorteada, duas instâncias podem cair na mesma: sem Redis, o limite global só é
+// garantido com Slot configurado.
+func (m *Monitor) probeLocally(ctx context.Context, processor string) time.Duration {
+	now := m.opts.Clock.Now()
+	slotLen := m.opts.Interval + m.opts.ProbeMargin
+	period := slotLen * time.Duration(m.opts.Instances)
+	slotAt := now.Truncate(period).Add(slotLen * time.Duration(m.slot))
+
+	m.mu.Lock()
+	if slotAt.After(now) {
+		m.mu.Unlock()
+		return slotAt.Sub(now)
+	}
+	// Só no começo da fatia, para manter Interval até a fatia seguinte; passado
+	// esse ponto ou já consultada, esperar a do próximo ciclo
+	if now.Sub(slotAt) > m.opts.ProbeMargin || !m.lastLocalProbe[processor].Before(slotAt) {
+		m.mu.Unlock()
+		return slotAt.Add(period).Sub(now)
+	}
+	m.lastLocalProbe[processor] = now
+	m.mu.Unlock()
+
+	m.check(ctx, processor)
+	return slotAt.Add(period).Sub(m.opts.Clock.Now())
+}
+
+func (m *Monitor) key(processor string) string {
+	return m.opts.Keys.Key("health:" + processor)
+}
+
+func (m *Monitor) tokenKey(processor string) string {
+	return m.opts.Keys.Key("health:token:" + processor)
+}
+
+func (m *Monitor) readShared(ctx context.Context, client redis.UniversalClient, processor string) (*Status, error) {
+	data, err := client.Get(ctx, m.key(processor)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var status Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (m *Monitor) publishShared(ctx context.Context, client redis.UniversalClient, processor string, status *Status, ttl time.Duration) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		log.Printf("Erro ao serializar health do %s: %v", processor, err)
+		return
+	}
+	if err := client.Set(ctx, m.key(processor), data, ttl).Err(); err != nil {
+		log.Printf("Erro ao publicar health do %s: %v", processor, err)
+	}
+}
+
+func (m *Monitor) setLocal(processor string, status *Status, probed bool) {
+	m.mu.Lock()
+	previous := m.cache[processor]
+	m.cache[processor] = status
+	m.mu.Unlock()
+
+	if previous != nil && previous.Failing && !status.Failing && m.opts.OnRecover != nil {
+		m.opts.OnRecover(processor)
+	}
+	// O resultado compartilhado é relido até a próxima consulta
+	if m.opts.OnUpdate != nil && (previous == nil || !previous.LastCheckedAt.Equal(status.LastCheckedAt)) {
+		m.opts.OnUpdate(processor, *status, probed)
+	}
+}
+
+// check consulta o processor e atualiza o cache local. Retorna nil quando o
+// cache não foi alterado (rate limit ou resposta inválida).
+func (m *Monitor) check(ctx context.Context, processor string) *Status {
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, m.opts.Timeout)
+	defer cancel()
+
+	healthResp, err := m.opts.Clients[processor].ServiceHealth(ctx)
+	switch {
+	case err == nil:
+	case errors.Is(err, pp.ErrRateLimited):
+		// Limite de rate excedido, não atualizar o cache
+		log.Printf("Rate limit excedido para health check do %s", processor)
+		return nil
+	case errors.Is(err, pp.ErrInvalidResponse), pp.StatusCode(err) != 0:
+		log.Printf("Resposta inválida no health check do %s: %v", processor, err)
+		return nil
+	default:
+		log.Printf("Erro ao verificar health do %s: %v", processor, err)
+		// O orçamento de quem disparou a verificação acabou: não diz nada sobre o processor
+		if parent.Err() != nil {
+			return nil
+		}
+		// Marcar como falhando se não conseguir conectar
+		status := &Status{
+			Failing:         true,
+			MinResponseTime: 1000,
+			LastCheckedAt:   m.opts.Clock.Now(),
+		}
+		m.setLocal(processor, status, true)
+		return status
+	}
+
+	status := &Status{
+		Failing:         healthResp.Failing,
+		MinResponseTime: healthResp.MinResponseTime,
+		LastCheckedAt:   m.opts.Clock.Now(),
+	}
+	m.setLocal(processor, status, true)
+
+	log.Printf("Health check atualizado para %s: failing=%v, minResponseTime=%d",
+		processor, healthResp.Failing, healthResp.MinResponseTime)
+	return status
+}
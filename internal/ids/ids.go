@@ -0,0 +1,112 @@
+// Package ids valida os correlationIds (UUIDs) sem alocar e os converte para os
+// 16 bytes guardados no Redis.
+package ids
+
+import "github.com/google/uuid"
+
+// Tamanho da forma canônica, xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+const canonicalLen = 36
+
+// Posições dos hífens na forma canônica
+var dashes = [...]int{8, 13, 18, 23}
+
+// Posição, na forma canônica, do primeiro dígito de cada byte
+var digits = [16]int{0, 2, 4, 6, 9, 11, 14, 16, 19, 21, 24, 26, 28, 30, 32, 34}
+
+// hexValue é o valor de cada dígito hexadecimal; invalidHex nos demais bytes, e
+// upperHex somado aos maiúsculos.
+var hexValue = func() [256]byte {
+	var table [256]byte
+	for i := range table {
+		table[i] = invalidHex
+	}
+	for c := byte('0'); c <= '9'; c++ {
+		table[c] = c - '0'
+	}
+	for c := byte('a'); c <= 'f'; c++ {
+		table[c] = c - 'a' + 10
+	}
+	for c := byte('A'); c <= 'F'; c++ {
+		table[c] = upperHex | (c - 'A' + 10)
+	}
+	return table
+}()
+
+const (
+	invalidHex = 0xff
+	upperHex   = 0x10
+)
+
+// Valid indica se s é um UUID como o uuid.Parse o aceita. A forma canônica, a
+// de todo pagamento, é conferida aqui sem alocar; as demais (sem hífens, entre
+// chaves e com urn:uuid:) passam pelo uuid.Parse.
+func Valid(s string) bool {
+	if len(s) != canonicalLen {
+		_, err := uuid.Parse(s)
+		return err == nil
+	}
+	_, _, ok := parseCanonical(s)
+	return ok
+}
+
+// parseCanonical lê a forma canônica; lower indica só dígitos minúsculos, a
+// forma que Decode reconstrói.
+func parseCanonical(s string) (id [16]byte, lower, ok bool) {
+	if len(s) != canonicalLen {
+		return id, false, false
+	}
+	for _, i := range dashes {
+		if s[i] != '-' {
+			return id, false, false
+		}
+	}
+	var upper byte
+	for i, at := range digits {
+		hi, lo := hexValue[s[at]], hexValue[s[at+1]]
+		if hi == invalidHex || lo == invalidHex {
+			return id, false, false
+		}
+		upper |= hi | lo
+		id[i] = (hi&^upperHex)<<4 | lo&^upperHex
+	}
+	return id, upper&upperHex == 0, true
+}
+
+// Binary retorna os 16 bytes do UUID na forma canônica minúscula; false nas
+// demais formas, que não voltariam iguais de Decode.
+func Binary(s string) ([16]byte, bool) {
+	id, lower, ok := parseCanonical(s)
+	return id, ok && lower
+}
+
+// Encode é a forma do correlationId no Redis: os 16 bytes quando Binary os
+// aceita, senão o texto como veio. Um texto válido tem pelo menos 32
+// caracteres, então os dois não se confundem.
+func Encode(s string) string {
+	id, ok := Binary(s)
+	if !ok {
+		return s
+	}
+	return string(id[:])
+}
+
+// Decode desfaz Encode, aceitando também os valores gravados como texto.
+func Decode(s string) string {
+	if len(s) != len(digits) {
+		return s
+	}
+	return string(appendCanonical(make([]byte, 0, canonicalLen), s))
+}
+
+// appendCanonical acrescenta a dst a forma canônica dos 16 bytes em b.
+func appendCanonical(dst []byte, b string) []byte {
+	const hex = "0123456789abcdef"
+	for i := range len(b) {
+		switch i {
+		case 4, 6, 8, 10:
+			dst = append(dst, '-')
+		}
+		dst = append(dst, hex[b[i]>>4], hex[b[i]&0x0f])
+	}
+	return dst
+}
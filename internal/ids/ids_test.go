@@ -0,0 +1,131 @@
+package ids
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var validationCases = []struct {
+	name string
+	id   string
+}{
+	{"canônica", "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"},
+	{"maiúsculas", "4A7901B8-7D26-4D9D-AA19-4DC1C7CF60B3"},
+	{"mista", "4a7901B8-7d26-4D9d-aa19-4dc1c7cf60b3"},
+	{"sem hífens", "4a7901b87d264d9daa194dc1c7cf60b3"},
+	{"chaves", "{4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3}"},
+	{"urn", "urn:uuid:4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"},
+	{"urn maiúscula", "URN:UUID:4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"},
+	{"nil", "00000000-0000-0000-0000-000000000000"},
+	{"hífen deslocado", "4a7901b-87d26-4d9d-aa19-4dc1c7cf60b3"},
+	{"hífen no fim", "4a7901b87-d26-4d9d-aa19-4dc1c7cf60b3"},
+	{"espaço no lugar do hífen", "4a7901b8 7d26-4d9d-aa19-4dc1c7cf60b3"},
+	{"sem um hífen", "4a7901b8-7d264d9d-aa19-4dc1c7cf60b3"},
+	{"curto", "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b"},
+	{"longo", "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b30"},
+	{"vazio", ""},
+	{"não hexadecimal", "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60bg"},
+	{"não hexadecimal no início", "za7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"},
+	{"byte alto", "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60\xb3"},
+	{"chaves sem fechar", "{4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"},
+	{"prefixo errado", "urn:uid:4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"},
+}
+
+// TestValidMatchesUUIDParse confere que Valid aceita exatamente o que o
+// uuid.Parse aceita, na forma canônica e nas demais.
+func TestValidMatchesUUIDParse(t *testing.T) {
+	for _, tt := range validationCases {
+		_, err := uuid.Parse(tt.id)
+		if got, want := Valid(tt.id), err == nil; got != want {
+			t.Errorf("%s: Valid(%q) = %v, uuid.Parse aceita = %v", tt.name, tt.id, got, want)
+		}
+	}
+}
+
+func TestBinary(t *testing.T) {
+	tests := []struct {
+		id     string
+		wantOK bool
+	}{
+		{"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3", true},
+		{"00000000-0000-0000-0000-000000000000", true},
+		{"ffffffff-ffff-ffff-ffff-ffffffffffff", true},
+		// Válidos, mas Decode não os devolveria iguais
+		{"4A7901B8-7D26-4D9D-AA19-4DC1C7CF60B3", false},
+		{"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60B3", false},
+		{"4a7901b87d264d9daa194dc1c7cf60b3", false},
+		{"{4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3}", false},
+		{"urn:uuid:4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3", false},
+		// Inválidos
+		{"4a7901b-87d26-4d9d-aa19-4dc1c7cf60b3", false},
+		{"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60bg", false},
+		{"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b", false},
+	}
+	for _, tt := range tests {
+		got, ok := Binary(tt.id)
+		if ok != tt.wantOK {
+			t.Errorf("Binary(%q) ok = %v, esperado %v", tt.id, ok, tt.wantOK)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		want := uuid.MustParse(tt.id)
+		if got != [16]byte(want) {
+			t.Errorf("Binary(%q) = %x, esperado %x", tt.id, got, want)
+		}
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for _, tt := range validationCases {
+		encoded := Encode(tt.id)
+		if _, binary := Binary(tt.id); binary {
+			if len(encoded) != 16 {
+				t.Errorf("%s: Encode(%q) com %d bytes, esperado 16", tt.name, tt.id, len(encoded))
+			}
+		} else if encoded != tt.id {
+			t.Errorf("%s: Encode(%q) = %q, esperado o texto sem mudança", tt.name, tt.id, encoded)
+		}
+		if got := Decode(encoded); got != tt.id {
+			t.Errorf("%s: Decode(Encode(%q)) = %q", tt.name, tt.id, got)
+		}
+	}
+}
+
+func TestValidDoesNotAllocate(t *testing.T) {
+	for _, id := range []string{
+		"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3",
+		"4A7901B8-7D26-4D9D-AA19-4DC1C7CF60B3",
+		"4a7901b-87d26-4d9d-aa19-4dc1c7cf60b3",
+		"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60bg",
+	} {
+		if allocs := testing.AllocsPerRun(100, func() { Valid(id) }); allocs != 0 {
+			t.Errorf("Valid(%q) alocou %.0f vezes, esperado 0", id, allocs)
+		}
+	}
+	id := "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+	if allocs := testing.AllocsPerRun(100, func() { Binary(id) }); allocs != 0 {
+		t.Errorf("Binary alocou %.0f vezes, esperado 0", allocs)
+	}
+}
+
+func BenchmarkValid(b *testing.B) {
+	id := "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+	b.ReportAllocs()
+	for b.Loop() {
+		Valid(id)
+	}
+}
+
+// BenchmarkUUIDParse é a referência que Valid substitui no caminho do POST.
+func BenchmarkUUIDParse(b *testing.B) {
+	id := "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := uuid.Parse(id); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Package keyspace dá às chaves do Redis o namespace da execução (RUN_ID): com
+// ele, cada chave começa com <runId>: e testes seguidos no mesmo Redis não
+// enxergam o estado uns dos outros. O prefixo fica fora da hash tag, então as
+// chaves continuam no mesmo slot do Cluster.
+package keyspace
+
+// Space é o namespace de uma execução. Definido uma vez na inicialização, antes
+// do primeiro comando ao Redis, e entregue a quem monta as chaves; o valor zero
+// mantém os nomes sem prefixo.
+type Space struct {
+	// Prefixo com os dois-pontos; vazio sem RUN_ID
+	prefix string
+}
+
+// New retorna o namespace de id; vazio mantém os nomes sem prefixo.
+func New(id string) Space {
+	if id == "" {
+		return Space{}
+	}
+	return Space{prefix: id + ":"}
+}
+
+// RunID retorna o namespace, ou vazio.
+func (s Space) RunID() string {
+	if s.prefix == "" {
+		return ""
+	}
+	return s.prefix[:len(s.prefix)-1]
+}
+
+// Key retorna o nome da chave no namespace.
+func (s Space) Key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + name
+}
@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// DefaultSignatureHeader recebe a assinatura quando Auth.SignatureHeader é vazio.
+const DefaultSignatureHeader = "X-Signature"
+
+// Auth são as credenciais exigidas por um processor, enviadas em todas as
+// requisições do cliente.
+type Auth struct {
+	// Enviado como "Authorization: Bearer <token>"
+	BearerToken string
+	// Chave do HMAC-SHA256 do corpo, enviado como "sha256=<hex>"; as requisições
+	// sem corpo assinam o corpo vazio
+	HMACSecret      string
+	SignatureHeader string
+	// Headers fixos, como chaves de API em header próprio
+	Headers map[string]string
+}
+
+// SetAuth define as credenciais enviadas ao processor.
+func (c *HTTPClient) SetAuth(auth Auth) {
+	header := make(http.Header, len(auth.Headers)+1)
+	for name, value := range auth.Headers {
+		header.Set(name, value)
+	}
+	if auth.BearerToken != "" {
+		header.Set("Authorization", "Bearer "+auth.BearerToken)
+	}
+	c.authHeader = header
+
+	c.hmacSecret = nil
+	if auth.HMACSecret != "" {
+		c.hmacSecret = []byte(auth.HMACSecret)
+		c.signatureHeader = auth.SignatureHeader
+		if c.signatureHeader == "" {
+			c.signatureHeader = DefaultSignatureHeader
+		}
+	}
+}
+
+// authorize acrescenta as credenciais à requisição cujo corpo é body. Os valores
+// fixos são compartilhados entre as requisições e nunca alterados.
+func (c *HTTPClient) authorize(req *http.Request, body []byte) {
+	for name, values := range c.authHeader {
+		req.Header[name] = values
+	}
+	if c.hmacSecret != nil {
+		mac := hmac.New(sha256.New, c.hmacSecret)
+		mac.Write(body)
+		req.Header.Set(c.signatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+}
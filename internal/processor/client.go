@@ -0,0 +1,339 @@
+// Package processor é o cliente dos Payment Processors: envio de pagamentos,
+// consulta de saúde, consulta de pagamentos já aceitos e do resumo administrativo.
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RequestedAtLayout é o formato ISO 8601 com milissegundos esperado pelos processors.
+const RequestedAtLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// Payment é o pagamento como os processors o recebem e devolvem.
+type Payment struct {
+	CorrelationID string    `json:"correlationId"`
+	Amount        float64   `json:"amount"`
+	RequestedAt   time.Time `json:"requestedAt"`
+	// Objeto JSON do cliente, enviado só com PROCESSOR_FORWARD_METADATA
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// Health é a resposta de GET /payments/service-health.
+type Health struct {
+	Failing         bool `json:"failing"`
+	MinResponseTime int  `json:"minResponseTime"`
+}
+
+// AdminSummary é a resposta de GET /admin/payments-summary.
+type AdminSummary struct {
+	TotalRequests     int     `json:"totalRequests"`
+	TotalAmount       float64 `json:"totalAmount"`
+	TotalFee          float64 `json:"totalFee"`
+	FeePerTransaction float64 `json:"feePerTransaction"`
+}
+
+// ProcessorClient é a API de um Payment Processor. Os timeouts ficam a cargo do
+// contexto de quem chama.
+type ProcessorClient interface {
+	SubmitPayment(ctx context.Context, payment Payment) error
+	ServiceHealth(ctx context.Context) (Health, error)
+	GetPayment(ctx context.Context, correlationID string) (Payment, error)
+	// PaymentsSummary agrega os pagamentos aceitos com requestedAt em [from, to]
+	PaymentsSummary(ctx context.Context, from, to time.Time) (AdminSummary, error)
+}
+
+var (
+	// ErrNotFound indica que o processor não conhece o pagamento.
+	ErrNotFound = errors.New("pagamento não encontrado no processor")
+	// ErrRateLimited indica que o service-health foi consultado cedo demais.
+	ErrRateLimited = errors.New("limite de consultas ao service-health excedido")
+	// ErrInvalidResponse indica um corpo de resposta que não pôde ser lido.
+	ErrInvalidResponse = errors.New("resposta inválida do processor")
+	// ErrUnconfirmed indica um 2xx cujo corpo não confirma o pagamento, como a
+	// página de erro de um proxy ou uma resposta cortada.
+	ErrUnconfirmed = errors.New("processor respondeu sem confirmar o pagamento")
+)
+
+// Limite lido do corpo de POST /payments; a confirmação tem poucas dezenas de bytes
+const maxSubmitResponse = 4 << 10
+
+// StatusError é uma resposta HTTP fora do esperado.
+type StatusError struct {
+	Op   string
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: status %d", e.Op, e.Code)
+}
+
+// StatusCode retorna o status HTTP carregado pelo erro, 0 para erros de rede ou
+// timeout e 200 quando err é nil.
+func StatusCode(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code
+	}
+	return 0
+}
+
+// Ambiguous indica um erro de SubmitPayment que não diz se o processor aceitou o
+// pagamento: timeout, conexão perdida depois do envio ou resposta sem
+// confirmação. Recusas (status) e falhas de conexão antes do envio não são
+// ambíguas.
+func Ambiguous(err error) bool {
+	if err == nil || StatusCode(err) != 0 {
+		return false
+	}
+	if errors.Is(err, ErrUnconfirmed) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return false
+	}
+	return true
+}
+
+// HTTPClient fala com um processor real via HTTP.
+type HTTPClient struct {
+	baseURL   string
+	submitURL string
+	client    *http.Client
+	// Enviado em X-Rinha-Token nos endpoints /admin
+	adminToken string
+
+	// Credenciais de SetAuth (auth.go)
+	authHeader      http.Header
+	hmacSecret      []byte
+	signatureHeader string
+}
+
+var _ ProcessorClient = (*HTTPClient)(nil)
+
+func NewHTTPClient(baseURL string, client *http.Client) *HTTPClient {
+	return &HTTPClient{baseURL: baseURL, submitURL: baseURL + "/payments", client: client}
+}
+
+// SetAdminToken define o token dos endpoints administrativos do processor.
+func (c *HTTPClient) SetAdminToken(token string) {
+	c.adminToken = token
+}
+
+func (c *HTTPClient) SubmitPayment(ctx context.Context, payment Payment) error {
+	body := newPaymentBody(payment)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.submitURL, body)
+	if err != nil {
+		body.Close()
+		return err
+	}
+	req.ContentLength = int64(body.Len())
+	req.Header.Set("Content-Type", "application/json")
+	c.authorize(req, *body.buf)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &StatusError{Op: "POST /payments", Code: resp.StatusCode}
+	}
+	return confirmPayment(resp.Body)
+}
+
+// confirmPayment lê o corpo de um 2xx: vazio ou um objeto JSON sem "error"
+// confirma o pagamento.
+func confirmPayment(body io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(body, maxSubmitResponse))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnconfirmed, err)
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+
+	var confirmation struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if data[0] != '{' || json.Unmarshal(data, &confirmation) != nil {
+		return fmt.Errorf("%w: corpo %.64q", ErrUnconfirmed, data)
+	}
+	if len(confirmation.Error) > 0 && string(confirmation.Error) != "null" {
+		return fmt.Errorf("%w: %s", ErrUnconfirmed, confirmation.Error)
+	}
+	return nil
+}
+
+func (c *HTTPClient) ServiceHealth(ctx context.Context) (Health, error) {
+	var health Health
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/payments/service-health", nil)
+	if err != nil {
+		return health, err
+	}
+	c.authorize(req, nil)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return health, err
+	}
+	defer drainAndClose(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return health, ErrRateLimited
+	default:
+		return health, &StatusError{Op: "GET /payments/service-health", Code: resp.StatusCode}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return health, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	return health, nil
+}
+
+func (c *HTTPClient) GetPayment(ctx context.Context, correlationID string) (Payment, error) {
+	var payment Payment
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/payments/"+correlationID, nil)
+	if err != nil {
+		return payment, err
+	}
+	c.authorize(req, nil)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return payment, err
+	}
+	defer drainAndClose(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return payment, ErrNotFound
+	default:
+		return payment, &StatusError{Op: "GET /payments/{id}", Code: resp.StatusCode}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&payment); err != nil {
+		return payment, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	return payment, nil
+}
+
+func (c *HTTPClient) PaymentsSummary(ctx context.Context, from, to time.Time) (AdminSummary, error) {
+	var summary AdminSummary
+
+	query := url.Values{}
+	query.Set("from", from.UTC().Format(RequestedAtLayout))
+	query.Set("to", to.UTC().Format(RequestedAtLayout))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/admin/payments-summary?"+query.Encode(), nil)
+	if err != nil {
+		return summary, err
+	}
+	req.Header.Set("X-Rinha-Token", c.adminToken)
+	c.authorize(req, nil)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return summary, err
+	}
+	defer drainAndClose(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return summary, &StatusError{Op: "GET /admin/payments-summary", Code: resp.StatusCode}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		return summary, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	return summary, nil
+}
+
+// drainAndClose lê o restante do corpo para que a conexão volte ao pool.
+func drainAndClose(body io.ReadCloser) {
+	io.Copy(io.Discard, body)
+	body.Close()
+}
+
+// paymentBody é o corpo de POST /payments sobre um buffer do pool. O transport
+// chama Close quando termina de enviar, e só então o buffer volta ao pool; o
+// wrapper é de cada requisição para que um Close repetido não devolva o buffer
+// que outra requisição já está usando.
+type paymentBody struct {
+	bytes.Reader
+	buf *[]byte
+}
+
+var payloadPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 128)
+		return &buf
+	},
+}
+
+func newPaymentBody(p Payment) *paymentBody {
+	buf := payloadPool.Get().(*[]byte)
+	*buf = encodePayment((*buf)[:0], p)
+	body := &paymentBody{buf: buf}
+	body.Reader.Reset(*buf)
+	return body
+}
+
+func (b *paymentBody) Close() error {
+	if b.buf != nil {
+		b.Reader.Reset(nil)
+		payloadPool.Put(b.buf)
+		b.buf = nil
+	}
+	return nil
+}
+
+// encodePayment monta o corpo de POST /payments sem reflexão: o formato é fixo e
+// esta é a serialização mais frequente da aplicação.
+func encodePayment(buf []byte, p Payment) []byte {
+	buf = append(buf, `{"correlationId":`...)
+	buf = appendString(buf, p.CorrelationID)
+	buf = append(buf, `,"amount":`...)
+	buf = strconv.AppendFloat(buf, p.Amount, 'f', -1, 64)
+	buf = append(buf, `,"requestedAt":"`...)
+	buf = p.RequestedAt.UTC().AppendFormat(buf, RequestedAtLayout)
+	buf = append(buf, '"')
+	if len(p.Metadata) > 0 {
+		buf = append(buf, `,"metadata":`...)
+		buf = append(buf, p.Metadata...)
+	}
+	return append(buf, '}')
+}
+
+// appendString copia direto strings sem caracteres a escapar (UUIDs, na prática)
+// e delega ao encoding/json os demais casos.
+func appendString(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c == '"' || c == '\\' || c >= 0x80 {
+			quoted, _ := json.Marshal(s)
+			return append(buf, quoted...)
+		}
+	}
+	buf = append(buf, '"')
+	buf = append(buf, s...)
+	return append(buf, '"')
+}
@@ -0,0 +1,121 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func benchmarkPayment() Payment {
+	return Payment{
+		CorrelationID: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3",
+		Amount:        19.9,
+		RequestedAt:   time.Date(2025, 7, 15, 12, 34, 56, 789000000, time.UTC),
+	}
+}
+
+func TestEncodePayment(t *testing.T) {
+	tests := map[string]struct {
+		payment Payment
+		want    string
+	}{
+		"contest": {
+			payment: benchmarkPayment(),
+			want:    `{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":19.9,"requestedAt":"2025-07-15T12:34:56.789Z"}`,
+		},
+		"metadata and local time": {
+			payment: Payment{
+				CorrelationID: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3",
+				Amount:        1234.56,
+				RequestedAt:   time.Date(2025, 7, 15, 9, 34, 56, 0, time.FixedZone("BRT", -3*3600)),
+				Metadata:      json.RawMessage(`{"pedido":"42"}`),
+			},
+			want: `{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":1234.56,"requestedAt":"2025-07-15T12:34:56.000Z","metadata":{"pedido":"42"}}`,
+		},
+		"escaped id": {
+			payment: Payment{CorrelationID: `a"b`, Amount: 1, RequestedAt: time.Unix(0, 0)},
+			want:    `{"correlationId":"a\"b","amount":1,"requestedAt":"1970-01-01T00:00:00.000Z"}`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := encodePayment(nil, tt.payment)
+			if string(got) != tt.want {
+				t.Errorf("encodePayment = %s, esperado %s", got, tt.want)
+			}
+			var decoded Payment
+			if err := json.Unmarshal(got, &decoded); err != nil {
+				t.Fatalf("JSON inválido: %v", err)
+			}
+		})
+	}
+}
+
+func BenchmarkEncodePayment(b *testing.B) {
+	payment := benchmarkPayment()
+	buf := make([]byte, 0, 128)
+	b.ReportAllocs()
+	for b.Loop() {
+		buf = encodePayment(buf[:0], payment)
+	}
+}
+
+// BenchmarkEncodePaymentEncodingJSON é a referência pelo encoding/json.
+func BenchmarkEncodePaymentEncodingJSON(b *testing.B) {
+	payment := benchmarkPayment()
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := json.Marshal(payment); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkPaymentBody mede o corpo como o transport o consome: montado sobre o
+// buffer do pool, lido e devolvido.
+func BenchmarkPaymentBody(b *testing.B) {
+	payment := benchmarkPayment()
+	b.ReportAllocs()
+	for b.Loop() {
+		body := newPaymentBody(payment)
+		io.Copy(io.Discard, body)
+		body.Close()
+	}
+}
+
+// roundTripFunc responde sem rede, para medir só o código do cliente.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// BenchmarkSubmitPayment mede o envio ao processor sem rede: serialização do
+// corpo e leitura da resposta.
+func BenchmarkSubmitPayment(b *testing.B) {
+	okBody := []byte(`{"message":"payment processed successfully"}`)
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(okBody)),
+			Request:    r,
+		}, nil
+	})
+	client := NewHTTPClient("http://processor", &http.Client{Transport: transport})
+	payment := benchmarkPayment()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := client.SubmitPayment(ctx, payment); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
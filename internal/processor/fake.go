@@ -0,0 +1,163 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Fake é um ProcessorClient em memória que imita as regras do processor real
+// (correlationId duplicado é recusado com 422), para exercitar a seleção e o
+// roteamento sem rede.
+type Fake struct {
+	mu        sync.Mutex
+	payments  map[string]Payment
+	health    Health
+	submitErr error
+	healthErr error
+	delay     time.Duration
+	submits   int
+	// Fração dos envios recusados com 500, distribuídos de forma regular
+	failureRate float64
+}
+
+func NewFake() *Fake {
+	return &Fake{payments: make(map[string]Payment)}
+}
+
+// SetHealth define a resposta de ServiceHealth.
+func (f *Fake) SetHealth(health Health) {
+	f.mu.Lock()
+	f.health = health
+	f.mu.Unlock()
+}
+
+// FailWith faz SubmitPayment e ServiceHealth retornarem err; nil restaura o normal.
+func (f *Fake) FailWith(err error) {
+	f.mu.Lock()
+	f.submitErr = err
+	f.healthErr = err
+	f.mu.Unlock()
+}
+
+// SetFailureRate faz SubmitPayment recusar com 500 uma fração fixa dos envios.
+// As falhas são espaçadas de forma determinística (rate 0.1 recusa o 10º, o 20º,
+// ...), para que duas execuções se comportem igual.
+func (f *Fake) SetFailureRate(rate float64) {
+	f.mu.Lock()
+	f.failureRate = rate
+	f.mu.Unlock()
+}
+
+// SetDelay simula a latência de cada chamada.
+func (f *Fake) SetDelay(delay time.Duration) {
+	f.mu.Lock()
+	f.delay = delay
+	f.mu.Unlock()
+}
+
+// Payments retorna os pagamentos aceitos.
+func (f *Fake) Payments() []Payment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	payments := make([]Payment, 0, len(f.payments))
+	for _, p := range f.payments {
+		payments = append(payments, p)
+	}
+	return payments
+}
+
+// Submits retorna quantas vezes SubmitPayment foi chamado, aceitos ou não.
+func (f *Fake) Submits() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.submits
+}
+
+// Reset apaga os pagamentos e contagens, como o purge do processor real.
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	f.payments = make(map[string]Payment)
+	f.submits = 0
+	f.mu.Unlock()
+}
+
+func (f *Fake) wait(ctx context.Context) error {
+	f.mu.Lock()
+	delay := f.delay
+	f.mu.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *Fake) SubmitPayment(ctx context.Context, payment Payment) error {
+	if err := f.wait(ctx); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.submits++
+	if f.submitErr != nil {
+		return f.submitErr
+	}
+	if int(float64(f.submits)*f.failureRate) > int(float64(f.submits-1)*f.failureRate) {
+		return &StatusError{Op: "POST /payments", Code: 500}
+	}
+	if _, ok := f.payments[payment.CorrelationID]; ok {
+		return &StatusError{Op: "POST /payments", Code: 422}
+	}
+	f.payments[payment.CorrelationID] = payment
+	return nil
+}
+
+func (f *Fake) ServiceHealth(ctx context.Context) (Health, error) {
+	if err := f.wait(ctx); err != nil {
+		return Health{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.health, f.healthErr
+}
+
+func (f *Fake) GetPayment(ctx context.Context, correlationID string) (Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	payment, ok := f.payments[correlationID]
+	if !ok {
+		return Payment{}, ErrNotFound
+	}
+	return payment, nil
+}
+
+func (f *Fake) PaymentsSummary(ctx context.Context, from, to time.Time) (AdminSummary, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var summary AdminSummary
+	for _, payment := range f.payments {
+		if payment.RequestedAt.Before(from) || payment.RequestedAt.After(to) {
+			continue
+		}
+		summary.TotalRequests++
+		summary.TotalAmount += payment.Amount
+	}
+	return summary, nil
+}
+
+var _ ProcessorClient = (*Fake)(nil)
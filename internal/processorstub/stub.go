@@ -0,0 +1,377 @@
+// Package processorstub é um Payment Processor em memória com as rotas e as
+// regras do oficial da Rinha: correlationId duplicado recusado com 422, 500
+// enquanto falha, o atraso de minResponseTime em cada envio e o limite de uma
+// consulta de saúde a cada RATE_LIMIT_SECONDS. As falhas e o atraso seguem um
+// roteiro em ciclo (Schedule) ou os PUT /admin/configurations/*, como no
+// oficial. Serve aos testes de integração e ao docker-compose local, sem as
+// imagens e o Postgres dos processors.
+package processorstub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"rinha-backend-2025/internal/clock"
+	pp "rinha-backend-2025/internal/processor"
+)
+
+// Phase é um trecho do roteiro: por Duration, o processor falha ou não e
+// atrasa os envios em MinResponseTime.
+type Phase struct {
+	Duration        time.Duration
+	Failing         bool
+	MinResponseTime time.Duration
+}
+
+// Options configura o stub; o zero é um processor saudável, sem atraso, sem
+// taxa e sem limite na consulta de saúde.
+type Options struct {
+	// Repetido em ciclo desde a criação do stub; vazio fica sempre saudável
+	Schedule []Phase
+	// Taxa por transação de /admin/payments-summary (TRANSACTION_FEE)
+	Fee float64
+	// X-Rinha-Token das rotas /admin (INITIAL_TOKEN); vazio não exige
+	Token string
+	// Intervalo mínimo entre consultas de saúde (RATE_LIMIT_SECONDS); 0 não limita
+	HealthInterval time.Duration
+	// Relógio do roteiro e do limite; nil usa o real
+	Clock clock.Clock
+}
+
+// Stub é o http.Handler do processor.
+type Stub struct {
+	opts  Options
+	clock clock.Clock
+	start time.Time
+	// Duração de um ciclo do roteiro
+	cycle time.Duration
+
+	mu       sync.Mutex
+	payments map[string]pp.Payment
+	token    string
+	// Definidos pelos PUT /admin/configurations/*, no lugar do roteiro
+	failing    *bool
+	delay      *time.Duration
+	lastHealth time.Time
+
+	mux *http.ServeMux
+}
+
+func New(opts Options) *Stub {
+	c := opts.Clock
+	if c == nil {
+		c = clock.Real()
+	}
+	s := &Stub{
+		opts:     opts,
+		clock:    c,
+		start:    c.Now(),
+		payments: make(map[string]pp.Payment),
+		token:    opts.Token,
+		mux:      http.NewServeMux(),
+	}
+	for _, phase := range opts.Schedule {
+		s.cycle += phase.Duration
+	}
+
+	s.mux.HandleFunc("POST /payments", s.handleSubmit)
+	s.mux.HandleFunc("GET /payments/service-health", s.handleHealth)
+	s.mux.HandleFunc("GET /payments/{id}", s.handleGetPayment)
+	s.mux.HandleFunc("GET /admin/payments-summary", s.admin(s.handleSummary))
+	s.mux.HandleFunc("POST /admin/purge-payments", s.admin(s.handlePurge))
+	s.mux.HandleFunc("PUT /admin/configurations/token", s.admin(s.handleSetToken))
+	s.mux.HandleFunc("PUT /admin/configurations/delay", s.admin(s.handleSetDelay))
+	s.mux.HandleFunc("PUT /admin/configurations/failure", s.admin(s.handleSetFailure))
+	return s
+}
+
+func (s *Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// SetFailure faz o stub falhar ou não, no lugar do roteiro, como o PUT
+// /admin/configurations/failure.
+func (s *Stub) SetFailure(failing bool) {
+	s.mu.Lock()
+	s.failing = &failing
+	s.mu.Unlock()
+}
+
+// SetDelay fixa o atraso dos envios, como o PUT /admin/configurations/delay.
+func (s *Stub) SetDelay(delay time.Duration) {
+	s.mu.Lock()
+	s.delay = &delay
+	s.mu.Unlock()
+}
+
+// Payments retorna os pagamentos aceitos.
+func (s *Stub) Payments() []pp.Payment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payments := make([]pp.Payment, 0, len(s.payments))
+	for _, p := range s.payments {
+		payments = append(payments, p)
+	}
+	return payments
+}
+
+// Purge apaga os pagamentos, como o POST /admin/purge-payments.
+func (s *Stub) Purge() {
+	s.mu.Lock()
+	s.payments = make(map[string]pp.Payment)
+	s.mu.Unlock()
+}
+
+// ParseSchedule lê o roteiro no formato de STUB_SCHEDULE: fases separadas por
+// vírgula, cada uma duração:estado[:minResponseTime], com estado "ok" ou
+// "failing". Exemplo: "30s:ok,10s:failing,20s:ok:800ms".
+func ParseSchedule(spec string) ([]Phase, error) {
+	var schedule []Phase
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		fields := strings.Split(part, ":")
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("fase %q: use duração:estado[:minResponseTime]", part)
+		}
+		duration, err := time.ParseDuration(fields[0])
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("fase %q: duração inválida", part)
+		}
+		phase := Phase{Duration: duration}
+		switch fields[1] {
+		case "ok":
+		case "failing":
+			phase.Failing = true
+		default:
+			return nil, fmt.Errorf("fase %q: estado deve ser ok ou failing", part)
+		}
+		if len(fields) == 3 {
+			phase.MinResponseTime, err = time.ParseDuration(fields[2])
+			if err != nil || phase.MinResponseTime < 0 {
+				return nil, fmt.Errorf("fase %q: minResponseTime inválido", part)
+			}
+		}
+		schedule = append(schedule, phase)
+	}
+	return schedule, nil
+}
+
+// state é o estado em vigor: o definido por PUT ou o da fase atual do roteiro.
+func (s *Stub) state() (failing bool, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cycle > 0 {
+		elapsed := s.clock.Since(s.start) % s.cycle
+		for _, phase := range s.opts.Schedule {
+			if elapsed < phase.Duration {
+				failing, delay = phase.Failing, phase.MinResponseTime
+				break
+			}
+			elapsed -= phase.Duration
+		}
+	}
+	if s.failing != nil {
+		failing = *s.failing
+	}
+	if s.delay != nil {
+		delay = *s.delay
+	}
+	return failing, delay
+}
+
+func (s *Stub) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	failing, delay := s.state()
+	if delay > 0 {
+		timer := s.clock.NewTimer(delay)
+		select {
+		case <-timer.C():
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		}
+	}
+	if failing {
+		writeMessage(w, http.StatusInternalServerError, "payment processor is failing")
+		return
+	}
+
+	var payment pp.Payment
+	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
+		writeMessage(w, http.StatusUnprocessableEntity, "invalid payment")
+		return
+	}
+	if err := uuid.Validate(payment.CorrelationID); err != nil || payment.Amount <= 0 {
+		writeMessage(w, http.StatusUnprocessableEntity, "invalid payment")
+		return
+	}
+	if payment.RequestedAt.IsZero() {
+		payment.RequestedAt = s.clock.Now().UTC()
+	}
+
+	s.mu.Lock()
+	_, duplicate := s.payments[payment.CorrelationID]
+	if !duplicate {
+		s.payments[payment.CorrelationID] = payment
+	}
+	s.mu.Unlock()
+
+	if duplicate {
+		writeMessage(w, http.StatusUnprocessableEntity, "correlationId already exists")
+		return
+	}
+	writeMessage(w, http.StatusOK, "payment processed successfully")
+}
+
+func (s *Stub) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if s.opts.HealthInterval > 0 {
+		now := s.clock.Now()
+		s.mu.Lock()
+		limited := !s.lastHealth.IsZero() && now.Sub(s.lastHealth) < s.opts.HealthInterval
+		if !limited {
+			s.lastHealth = now
+		}
+		s.mu.Unlock()
+		if limited {
+			writeMessage(w, http.StatusTooManyRequests, "too many requests")
+			return
+		}
+	}
+
+	failing, delay := s.state()
+	writeJSON(w, http.StatusOK, pp.Health{Failing: failing, MinResponseTime: int(delay.Milliseconds())})
+}
+
+func (s *Stub) handleGetPayment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	payment, ok := s.payments[r.PathValue("id")]
+	s.mu.Unlock()
+
+	if !ok {
+		writeMessage(w, http.StatusNotFound, "payment not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, pp.Payment{
+		CorrelationID: payment.CorrelationID,
+		Amount:        payment.Amount,
+		RequestedAt:   payment.RequestedAt,
+	})
+}
+
+// admin exige o X-Rinha-Token em vigor, como o oficial.
+func (s *Stub) admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		token := s.token
+		s.mu.Unlock()
+
+		if token != "" && r.Header.Get("X-Rinha-Token") != token {
+			writeMessage(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Stub) handleSummary(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	summary := pp.AdminSummary{FeePerTransaction: s.opts.Fee}
+	s.mu.Lock()
+	for _, payment := range s.payments {
+		if (!from.IsZero() && payment.RequestedAt.Before(from)) || (!to.IsZero() && payment.RequestedAt.After(to)) {
+			continue
+		}
+		summary.TotalRequests++
+		summary.TotalAmount += payment.Amount
+	}
+	s.mu.Unlock()
+	summary.TotalFee = summary.TotalAmount * s.opts.Fee
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func parseRange(fromValue, toValue string) (from, to time.Time, err error) {
+	if fromValue != "" {
+		if from, err = time.Parse(time.RFC3339Nano, fromValue); err != nil {
+			return from, to, errors.New("from inválido")
+		}
+	}
+	if toValue != "" {
+		if to, err = time.Parse(time.RFC3339Nano, toValue); err != nil {
+			return from, to, errors.New("to inválido")
+		}
+	}
+	return from, to, nil
+}
+
+func (s *Stub) handlePurge(w http.ResponseWriter, r *http.Request) {
+	s.Purge()
+	writeMessage(w, http.StatusOK, "All payments purged.")
+}
+
+func (s *Stub) handleSetToken(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token string `json:"token"`
+	}
+	if !decodeConfiguration(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	s.token = body.Token
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Stub) handleSetDelay(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Delay int `json:"delay"`
+	}
+	if !decodeConfiguration(w, r, &body) {
+		return
+	}
+	s.SetDelay(time.Duration(max(body.Delay, 0)) * time.Millisecond)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Stub) handleSetFailure(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Failure bool `json:"failure"`
+	}
+	if !decodeConfiguration(w, r, &body) {
+		return
+	}
+	s.SetFailure(body.Failure)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeConfiguration(w http.ResponseWriter, r *http.Request, body any) bool {
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		writeMessage(w, http.StatusBadRequest, "invalid body")
+		return false
+	}
+	return true
+}
+
+func writeMessage(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,204 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Backends fora do processo (QUEUE_BACKEND): cada faixa vira uma fila durável
+// no Redis ou no NATS, e os itens passam serializados por Options.Encode e
+// Options.Decode. A instância só consome as próprias filas, pelo nome do
+// consumidor: o índice de Cancel e Find continua local, e o que ficou na fila
+// ou foi entregue sem confirmação volta quando a instância reinicia com o mesmo
+// nome. A confirmação vem depois do Process, então um item interrompido no
+// meio é processado de novo. O custo é uma ida ao backend por envio e por
+// confirmação, contra nenhuma do canal em memória.
+
+// Backend guarda as faixas do pool fora do processo.
+type Backend interface {
+	// Name identifica o backend em Status
+	Name() string
+	// Open prepara a faixa; a conexão pode ficar para o primeiro uso, para a
+	// indisponibilidade do backend não impedir a inicialização
+	Open(lane string) (Stream, error)
+	// Close libera o backend depois que os workers terminaram
+	Close() error
+}
+
+// Stream é uma faixa guardada em um Backend.
+type Stream interface {
+	// Publish grava as mensagens, todas ou nenhuma até onde o backend garante
+	Publish(ctx context.Context, msgs [][]byte) error
+	// Consume entrega as mensagens em out até ctx terminar, começando pelas
+	// entregues e não confirmadas em uma execução anterior. Fecha out ao sair
+	Consume(ctx context.Context, out chan<- Delivery)
+}
+
+// Delivery é uma mensagem entregue por um Stream.
+type Delivery struct {
+	Data []byte
+	// Ack confirma o processamento; a mensagem sai do backend
+	Ack func()
+}
+
+// envelope é o que vai para o backend: o item serializado e o que o pool
+// precisa para reconhecê-lo na volta.
+type envelope struct {
+	Item       json.RawMessage `json:"item"`
+	EnqueuedAt time.Time       `json:"enqueuedAt"`
+	// Execução do pool que publicou o item e a posição dele nessa execução
+	Boot string `json:"boot"`
+	Seq  uint64 `json:"seq"`
+}
+
+// remoteLane publica e consome uma faixa em um Stream.
+type remoteLane[T any] struct {
+	pool   *Pool[T]
+	name   string
+	stream Stream
+	cancel context.CancelFunc
+
+	// Itens publicados nesta execução e ainda não entregues, pelo Seq. Um item
+	// retirado daqui antes da entrega (envio desfeito) é descartado na volta
+	mu       sync.Mutex
+	inflight map[uint64]*ticket
+	// Itens de execuções anteriores entregues nesta
+	recovered atomic.Int64
+}
+
+// newBootID identifica a execução do pool nos envelopes.
+func newBootID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// openRemoteLane abre a faixa no backend e passa a consumi-la em items, que
+// fecha quando o consumo termina.
+func openRemoteLane[T any](p *Pool[T], name string, items chan entry[T]) (*remoteLane[T], error) {
+	stream, err := p.opts.Backend.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("faixa %s: %w", name, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &remoteLane[T]{pool: p, name: name, stream: stream, cancel: cancel, inflight: make(map[uint64]*ticket)}
+
+	deliveries := make(chan Delivery, cap(items))
+	go stream.Consume(ctx, deliveries)
+	go func() {
+		defer close(items)
+		for d := range deliveries {
+			if e, ok := r.decode(d); ok {
+				items <- e
+			}
+		}
+	}()
+	return r, nil
+}
+
+// depth conta os itens publicados nesta execução ainda no backend.
+func (r *remoteLane[T]) depth() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.inflight)
+}
+
+// offer grava as entradas no backend, se couberem em Size; false se não
+// couberem ou o backend recusar, com as entradas fora do índice. Uma entrada
+// que o consumo já entregou segue para os workers mesmo assim.
+func (r *remoteLane[T]) offer(entries []entry[T]) bool {
+	msgs := make([][]byte, len(entries))
+	for i := range entries {
+		e := &entries[i]
+		item, err := r.pool.opts.Encode(e.item)
+		if err == nil {
+			e.seq = r.pool.seq.Add(1)
+			msgs[i], err = json.Marshal(envelope{Item: item, EnqueuedAt: e.enqueuedAt, Boot: r.pool.boot, Seq: e.seq})
+		}
+		if err != nil {
+			log.Printf("Erro ao serializar %s para a fila: %v", r.pool.opts.Label(e.item), err)
+			r.pool.untrackAll(entries)
+			return false
+		}
+	}
+
+	// Registrar antes de publicar: o consumo pode receber o item antes de
+	// Publish retornar
+	r.mu.Lock()
+	if len(r.inflight)+len(entries) > r.pool.opts.Size {
+		r.mu.Unlock()
+		r.pool.untrackAll(entries)
+		return false
+	}
+	for _, e := range entries {
+		r.inflight[e.seq] = e.ticket
+	}
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.pool.opts.PublishTimeout)
+	defer cancel()
+	if err := r.stream.Publish(ctx, msgs); err != nil {
+		log.Printf("Erro ao publicar %d itens na faixa %s (%s): %v", len(msgs), r.name, r.pool.opts.Backend.Name(), err)
+		pending := r.retract(entries)
+		r.pool.untrackAll(pending)
+		return len(pending) == 0
+	}
+	return true
+}
+
+// retract desfaz um envio: as entradas que o backend chegou a gravar são
+// descartadas quando voltarem. Retorna as que ainda não tinham sido entregues.
+func (r *remoteLane[T]) retract(entries []entry[T]) []entry[T] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pending := entries[:0:0]
+	for _, e := range entries {
+		if _, ok := r.inflight[e.seq]; ok {
+			delete(r.inflight, e.seq)
+			pending = append(pending, e)
+		}
+	}
+	return pending
+}
+
+// decode remonta a entrada de uma mensagem. As ilegíveis e as de envios
+// desfeitos são confirmadas e descartadas, para não voltarem a cada reinício.
+func (r *remoteLane[T]) decode(d Delivery) (entry[T], bool) {
+	var env envelope
+	err := json.Unmarshal(d.Data, &env)
+	var item T
+	if err == nil {
+		item, err = r.pool.opts.Decode(env.Item)
+	}
+	if err != nil {
+		log.Printf("Mensagem ilegível descartada da faixa %s (%s): %v", r.name, r.pool.opts.Backend.Name(), err)
+		d.Ack()
+		return entry[T]{}, false
+	}
+
+	e := entry[T]{item: item, enqueuedAt: env.EnqueuedAt, ack: d.Ack}
+	if env.Boot != r.pool.boot {
+		// Deixado por uma execução anterior: entra no índice agora
+		r.recovered.Add(1)
+		e.ticket = r.pool.track(item)
+		return e, true
+	}
+
+	r.mu.Lock()
+	t, ok := r.inflight[env.Seq]
+	delete(r.inflight, env.Seq)
+	r.mu.Unlock()
+	if !ok {
+		d.Ack()
+		return entry[T]{}, false
+	}
+	e.ticket = t
+	return e, true
+}
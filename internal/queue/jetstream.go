@@ -0,0 +1,196 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// JetStream guarda as faixas em um stream do NATS JetStream com política de
+// work queue: uma mensagem confirmada sai do stream. Cada faixa é o subject
+// <stream>.<consumidor>.<faixa>, lido por um consumer durável de mesmo nome.
+// Uma mensagem não confirmada em AckWait é entregue de novo, então AckWait
+// precisa passar do tempo que um item leva entre a leitura e o fim do Process.
+type JetStream struct {
+	conn     *nats.Conn
+	js       jetstream.JetStream
+	stream   string
+	consumer string
+	ackWait  time.Duration
+
+	// O stream é criado no primeiro uso, com o servidor disponível
+	mu     sync.Mutex
+	handle jetstream.Stream
+}
+
+// NewJetStream conecta ao servidor em segundo plano: ele indisponível não
+// impede a inicialização, e as publicações falham até a conexão sair.
+func NewJetStream(url, stream, consumer string, ackWait time.Duration) (*JetStream, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("rinha-backend-"+consumer),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("conectar ao NATS: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &JetStream{
+		conn:     conn,
+		js:       js,
+		stream:   natsToken(stream),
+		consumer: natsToken(consumer),
+		ackWait:  ackWait,
+	}, nil
+}
+
+// natsToken troca o que não vale em nomes de stream, consumer e subject.
+func natsToken(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', '/', '\\', ' ', '\t':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+func (b *JetStream) Name() string { return "nats" }
+
+func (b *JetStream) Open(lane string) (Stream, error) {
+	return &jetStreamLane{
+		backend: b,
+		subject: b.stream + "." + b.consumer + "." + lane,
+		durable: b.consumer + "-" + lane,
+	}, nil
+}
+
+// Close envia as confirmações pendentes e encerra a conexão.
+func (b *JetStream) Close() error {
+	err := b.conn.FlushTimeout(time.Second)
+	b.conn.Close()
+	return err
+}
+
+// ensure cria o stream, se ainda não existe, e o retorna.
+func (b *JetStream) ensure(ctx context.Context) (jetstream.Stream, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.handle != nil {
+		return b.handle, nil
+	}
+	handle, err := b.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:      b.stream,
+		Subjects:  []string{b.stream + ".>"},
+		Retention: jetstream.WorkQueuePolicy,
+		Storage:   jetstream.FileStorage,
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.handle = handle
+	return handle, nil
+}
+
+type jetStreamLane struct {
+	backend *JetStream
+	subject string
+	durable string
+}
+
+// Publish grava as mensagens uma a uma; se uma falhar, as anteriores são
+// apagadas do stream.
+func (s *jetStreamLane) Publish(ctx context.Context, msgs [][]byte) error {
+	handle, err := s.backend.ensure(ctx)
+	if err != nil {
+		return err
+	}
+	seqs := make([]uint64, 0, len(msgs))
+	for _, msg := range msgs {
+		ack, err := s.backend.js.Publish(ctx, s.subject, msg)
+		if err != nil {
+			for _, seq := range seqs {
+				if delErr := handle.DeleteMsg(context.Background(), seq); delErr != nil {
+					err = errors.Join(err, delErr)
+				}
+			}
+			return err
+		}
+		seqs = append(seqs, ack.Sequence)
+	}
+	return nil
+}
+
+// Consume lê pelo consumer durável da faixa; as mensagens entregues e não
+// confirmadas antes voltam depois de AckWait.
+func (s *jetStreamLane) Consume(ctx context.Context, out chan<- Delivery) {
+	defer close(out)
+
+	for ctx.Err() == nil {
+		if _, err := s.backend.ensure(ctx); err != nil {
+			s.retry(ctx, "criar o stream", err)
+			continue
+		}
+		cons, err := s.backend.js.CreateOrUpdateConsumer(ctx, s.backend.stream, jetstream.ConsumerConfig{
+			Durable:       s.durable,
+			FilterSubject: s.subject,
+			AckPolicy:     jetstream.AckExplicitPolicy,
+			AckWait:       s.backend.ackWait,
+		})
+		if err != nil {
+			s.retry(ctx, "criar o consumer", err)
+			continue
+		}
+		it, err := cons.Messages(jetstream.PullMaxMessages(cap(out)))
+		if err != nil {
+			s.retry(ctx, "ler", err)
+			continue
+		}
+		stop := context.AfterFunc(ctx, it.Stop)
+		s.drain(ctx, it, out)
+		stop()
+		it.Stop()
+	}
+}
+
+// drain repassa as mensagens até o iterador fechar.
+func (s *jetStreamLane) drain(ctx context.Context, it jetstream.MessagesContext, out chan<- Delivery) {
+	for {
+		msg, err := it.Next()
+		if err != nil {
+			if !errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+				s.retry(ctx, "ler", err)
+			}
+			return
+		}
+		delivery := Delivery{Data: msg.Data(), Ack: func() {
+			if err := msg.Ack(); err != nil {
+				log.Printf("Erro ao confirmar mensagem de %s: %v", s.subject, err)
+			}
+		}}
+		select {
+		case out <- delivery:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *jetStreamLane) retry(ctx context.Context, op string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	log.Printf("Erro ao %s (fila %s): %v", op, s.subject, err)
+	sleep(ctx, time.Second)
+}
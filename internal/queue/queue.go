@@ -0,0 +1,682 @@
+// Package queue é o pool de workers que processa os pagamentos em segundo plano,
+// com uma faixa prioritária opcional. As faixas ficam em canais na memória ou,
+// com Options.Backend, em uma fila durável fora do processo.
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+)
+
+// Options configura o pool.
+type Options[T any] struct {
+	Workers int
+	// Capacidade da fila; acima dela os itens são processados fora do pool
+	Size int
+	// Process trata um item em um dos workers
+	Process func(ctx context.Context, item T)
+	// Label identifica o item nos logs
+	Label func(item T) string
+	// Priority indica os itens da faixa prioritária, que tem a própria fila de
+	// capacidade Size; nil usa apenas a faixa normal
+	Priority func(item T) bool
+	// Itens prioritários seguidos antes de atender um da faixa normal que esteja
+	// esperando, para que ela não fique parada sob carga
+	PriorityBurst int
+	// Key indexa os itens para Cancel e Find; nil desativa os dois
+	Key func(item T) string
+	// Quantos itens já entregues a um worker Cancel e Find ainda reconhecem; 0 usa Size
+	History int
+
+	// Backend guarda as faixas fora do processo (ver backend.go); nil usa canais
+	// em memória
+	Backend Backend
+	// Encode e Decode serializam os itens para o Backend
+	Encode func(item T) ([]byte, error)
+	Decode func(data []byte) (T, error)
+	// Itens de cada faixa lidos do Backend à frente dos workers; 0 usa 64
+	Prefetch int
+	// Prazo de uma publicação no Backend; 0 usa 1s
+	PublishTimeout time.Duration
+	// Relógio do tempo de espera na fila; nil usa o real
+	Clock clock.Clock
+}
+
+// CancelResult é o desfecho de Cancel.
+type CancelResult int
+
+const (
+	// O item foi retirado da fila antes de chegar a um worker
+	Cancelled CancelResult = iota
+	// O item já foi entregue a um worker
+	Dispatched
+	// Nenhum item com a chave na fila nem entre os entregues recentemente
+	NotFound
+)
+
+// Nomes das faixas em Status.Lanes
+const (
+	LaneHigh   = "high"
+	LaneNormal = "normal"
+)
+
+type Status struct {
+	Count         int `json:"count"`
+	Active        int `json:"active"`
+	QueueDepth    int `json:"queueDepth"`
+	QueueCapacity int `json:"queueCapacity"`
+	// Itens sendo processados fora do pool porque a fila estava cheia
+	Overflow int `json:"overflow"`
+	// Maior Depth já observado desde a criação do pool
+	HighWatermark int `json:"highWatermark"`
+	// Itens cancelados antes de chegar a um worker
+	Cancelled int64                 `json:"cancelled"`
+	Lanes     map[string]LaneStatus `json:"lanes"`
+	// memory ou o nome do Backend
+	Backend string `json:"backend"`
+}
+
+type LaneStatus struct {
+	Depth    int   `json:"depth"`
+	Capacity int   `json:"capacity"`
+	Taken    int64 `json:"taken"`
+	// Soma do tempo que os itens retirados esperaram na fila
+	WaitSeconds float64 `json:"waitSeconds"`
+	// Itens deixados no Backend por uma execução anterior e retomados nesta
+	Recovered int64 `json:"recovered,omitempty"`
+}
+
+// entry guarda quando o item entrou na fila, para medir a espera.
+type entry[T any] struct {
+	item       T
+	enqueuedAt time.Time
+	// nil sem Options.Key
+	ticket *ticket
+	// Com Backend, a posição do item na execução e a confirmação depois do
+	// Process; ack nil em memória
+	seq uint64
+	ack func()
+}
+
+// done confirma o item no Backend.
+func (e entry[T]) done() {
+	if e.ack != nil {
+		e.ack()
+	}
+}
+
+// ticket acompanha os itens de uma chave que aguardam na fila. Um item cancelado
+// continua no canal e é descartado pelo worker que o retirar.
+type ticket struct {
+	key       string
+	waiting   int
+	cancelled bool
+}
+
+// lane é uma das filas do pool. Com Backend, items recebe só os itens lidos à
+// frente dos workers.
+type lane[T any] struct {
+	items  chan entry[T]
+	taken  atomic.Int64
+	waitNs atomic.Int64
+	// nil em memória
+	remote *remoteLane[T]
+}
+
+func (l *lane[T]) status() LaneStatus {
+	status := LaneStatus{
+		Depth:       len(l.items),
+		Capacity:    cap(l.items),
+		Taken:       l.taken.Load(),
+		WaitSeconds: time.Duration(l.waitNs.Load()).Seconds(),
+	}
+	if l.remote != nil {
+		status.Depth += l.remote.depth()
+		status.Capacity = l.remote.pool.opts.Size
+		status.Recovered = l.remote.recovered.Load()
+	}
+	return status
+}
+
+// Pool distribui os itens enfileirados entre um número fixo de workers.
+type Pool[T any] struct {
+	opts   Options[T]
+	normal *lane[T]
+	// nil sem Options.Priority
+	high   *lane[T]
+	wg     sync.WaitGroup
+	active atomic.Int64
+
+	overflow      atomic.Int64
+	highWatermark atomic.Int64
+
+	// Protege o envio na fila contra o fechamento no encerramento
+	closeMux sync.RWMutex
+	closed   bool
+
+	// Índice por Options.Key: os itens na fila e as últimas chaves entregues,
+	// em um anel de History posições
+	indexMux   sync.Mutex
+	waiting    map[string]*ticket
+	dispatched map[string]int
+	// O último item de cada chave em waiting ou dispatched, para Find
+	latest     map[string]T
+	history    []string
+	historyPos int
+	cancelled  atomic.Int64
+
+	// Com Backend, identificam os itens publicados nesta execução
+	boot string
+	seq  atomic.Uint64
+}
+
+// New inicia os workers; com Backend, o erro é o da abertura das faixas.
+func New[T any](opts Options[T]) (*Pool[T], error) {
+	if opts.Backend != nil && (opts.Encode == nil || opts.Decode == nil) {
+		return nil, errors.New("queue: Backend exige Encode e Decode")
+	}
+	if opts.Prefetch <= 0 {
+		opts.Prefetch = 64
+	}
+	if opts.PublishTimeout <= 0 {
+		opts.PublishTimeout = time.Second
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real()
+	}
+
+	p := &Pool[T]{opts: opts}
+	if opts.Backend != nil {
+		p.boot = newBootID()
+	}
+	if opts.Key != nil {
+		history := opts.History
+		if history <= 0 {
+			history = max(opts.Size, 1)
+		}
+		p.waiting = make(map[string]*ticket)
+		p.dispatched = make(map[string]int, history)
+		p.latest = make(map[string]T, history)
+		p.history = make([]string, 0, history)
+	}
+
+	// O índice vem antes: o consumo das faixas registra nele os itens deixados
+	// por uma execução anterior
+	var err error
+	if p.normal, err = p.newLane(LaneNormal); err != nil {
+		return nil, err
+	}
+	if opts.Priority != nil {
+		if p.high, err = p.newLane(LaneHigh); err != nil {
+			p.normal.remote.cancel()
+			return nil, err
+		}
+	}
+
+	for i := 0; i < opts.Workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.work()
+		}()
+	}
+
+	switch {
+	case opts.Backend != nil:
+		log.Printf("%d workers iniciados (fila em %s com capacidade %d por faixa)", opts.Workers, opts.Backend.Name(), opts.Size)
+	case p.high != nil:
+		log.Printf("%d workers iniciados (faixas prioritária e normal com capacidade %d cada)", opts.Workers, opts.Size)
+	default:
+		log.Printf("%d workers iniciados (fila com capacidade %d)", opts.Workers, opts.Size)
+	}
+	return p, nil
+}
+
+// newLane cria a faixa, abrindo-a no Backend se houver um.
+func (p *Pool[T]) newLane(name string) (*lane[T], error) {
+	if p.opts.Backend == nil {
+		return &lane[T]{items: make(chan entry[T], p.opts.Size)}, nil
+	}
+	l := &lane[T]{items: make(chan entry[T], p.opts.Prefetch)}
+	var err error
+	l.remote, err = openRemoteLane(p, name, l.items)
+	return l, err
+}
+
+// work atende a faixa prioritária primeiro; a cada PriorityBurst itens
+// prioritários seguidos, um da faixa normal passa à frente se estiver esperando.
+// Termina quando as duas filas foram fechadas e esvaziadas.
+func (p *Pool[T]) work() {
+	normal := p.normal.items
+	var high chan entry[T]
+	if p.high != nil {
+		high = p.high.items
+	}
+	streak := 0
+
+	for high != nil || normal != nil {
+		e, fromHigh, ok := next(high, normal, high != nil && streak >= p.opts.PriorityBurst)
+		if !ok {
+			// Fila fechada e vazia: deixar de escutá-la
+			if fromHigh {
+				high = nil
+			} else {
+				normal = nil
+			}
+			continue
+		}
+
+		if !p.claim(e.ticket) {
+			e.done()
+			continue
+		}
+
+		l := p.normal
+		if fromHigh {
+			l = p.high
+			streak++
+		} else {
+			streak = 0
+		}
+		l.taken.Add(1)
+		l.waitNs.Add(int64(p.opts.Clock.Since(e.enqueuedAt)))
+
+		p.active.Add(1)
+		p.opts.Process(context.Background(), e.item)
+		p.active.Add(-1)
+		e.done()
+	}
+}
+
+// next retira o próximo item, preferindo high a menos que seja a vez de normal.
+// Um canal nil nunca é escolhido.
+func next[T any](high, normal chan entry[T], normalTurn bool) (e entry[T], fromHigh, ok bool) {
+	if normalTurn {
+		select {
+		case e, ok = <-normal:
+			return e, false, ok
+		default:
+		}
+	}
+
+	select {
+	case e, ok = <-high:
+		return e, true, ok
+	default:
+	}
+
+	select {
+	case e, ok = <-high:
+		return e, true, ok
+	case e, ok = <-normal:
+		return e, false, ok
+	}
+}
+
+// laneFor escolhe a faixa do item.
+func (p *Pool[T]) laneFor(item T) *lane[T] {
+	if p.high != nil && p.opts.Priority(item) {
+		return p.high
+	}
+	return p.normal
+}
+
+// Enqueue nunca perde o item: com a fila cheia ou fechada, ele é processado em
+// uma goroutine própria.
+func (p *Pool[T]) Enqueue(item T) {
+	p.closeMux.RLock()
+	defer p.closeMux.RUnlock()
+
+	if p.closed {
+		p.processOutside(item)
+		return
+	}
+
+	if p.offer(p.laneFor(item), entry[T]{item: item, enqueuedAt: p.opts.Clock.Now(), ticket: p.track(item)}) {
+		p.observeDepth()
+	} else {
+		// Fila cheia: processar fora do pool para não perder o pagamento
+		log.Printf("Fila de pagamentos cheia, processando %s fora do pool", p.opts.Label(item))
+		p.processOutside(item)
+	}
+}
+
+// offer coloca a entrada na faixa sem bloquear; false com ela cheia ou, com
+// Backend, se a publicação falhar, e então a entrada sai do índice.
+func (p *Pool[T]) offer(l *lane[T], e entry[T]) bool {
+	if l.remote != nil {
+		return l.remote.offer([]entry[T]{e})
+	}
+	select {
+	case l.items <- e:
+		return true
+	default:
+		p.untrack(e.ticket)
+		return false
+	}
+}
+
+func (p *Pool[T]) processOutside(item T) {
+	if p.opts.Key != nil {
+		p.indexMux.Lock()
+		p.remember(p.opts.Key(item))
+		p.indexMux.Unlock()
+	}
+	p.overflow.Add(1)
+	p.observeDepth()
+	go func() {
+		defer p.overflow.Add(-1)
+		p.opts.Process(context.Background(), item)
+	}()
+}
+
+// observeDepth atualiza o high watermark com a profundidade atual.
+func (p *Pool[T]) observeDepth() {
+	depth := int64(p.Depth())
+	for {
+		current := p.highWatermark.Load()
+		if depth <= current || p.highWatermark.CompareAndSwap(current, depth) {
+			return
+		}
+	}
+}
+
+// TryEnqueue coloca o item na fila sem recorrer ao processamento fora do pool;
+// false com a fila cheia ou fechada.
+func (p *Pool[T]) TryEnqueue(item T) bool {
+	p.closeMux.RLock()
+	defer p.closeMux.RUnlock()
+
+	if p.closed {
+		return false
+	}
+
+	if !p.offer(p.laneFor(item), entry[T]{item: item, enqueuedAt: p.opts.Clock.Now(), ticket: p.track(item)}) {
+		return false
+	}
+	p.observeDepth()
+	return true
+}
+
+// TryEnqueueAll coloca todos os itens na fila ou nenhum; false quando não há
+// espaço para todos ou a fila está fechada. Segura a fila com exclusividade para
+// que outros envios não ocupem o espaço conferido.
+func (p *Pool[T]) TryEnqueueAll(items []T) bool {
+	if p.opts.Backend != nil {
+		return p.publishAll(items)
+	}
+	p.closeMux.Lock()
+	defer p.closeMux.Unlock()
+
+	if p.closed {
+		return false
+	}
+	lanes := make([]*lane[T], len(items))
+	needed := make(map[*lane[T]]int, 2)
+	for i, item := range items {
+		lanes[i] = p.laneFor(item)
+		needed[lanes[i]]++
+	}
+	for l, n := range needed {
+		if cap(l.items)-len(l.items) < n {
+			return false
+		}
+	}
+
+	// Os workers só retiram itens, então o espaço conferido não diminui
+	now := p.opts.Clock.Now()
+	for i, item := range items {
+		lanes[i].items <- entry[T]{item: item, enqueuedAt: now, ticket: p.track(item)}
+	}
+	p.observeDepth()
+	return true
+}
+
+// publishAll é o TryEnqueueAll com Backend: uma publicação por faixa, e as
+// anteriores desfeitas se uma falhar. Os itens que o consumo já tiver entregue
+// seguem para os workers mesmo assim.
+func (p *Pool[T]) publishAll(items []T) bool {
+	p.closeMux.RLock()
+	defer p.closeMux.RUnlock()
+
+	if p.closed {
+		return false
+	}
+	now := p.opts.Clock.Now()
+	batches := make(map[*lane[T]][]entry[T], 2)
+	for _, item := range items {
+		l := p.laneFor(item)
+		batches[l] = append(batches[l], entry[T]{item: item, enqueuedAt: now, ticket: p.track(item)})
+	}
+
+	published := make(map[*lane[T]][]entry[T], len(batches))
+	for l, entries := range batches {
+		if l.remote.offer(entries) {
+			published[l] = entries
+			continue
+		}
+		for pl, done := range published {
+			p.untrackAll(pl.remote.retract(done))
+		}
+		for other, rest := range batches {
+			if other != l && published[other] == nil {
+				p.untrackAll(rest)
+			}
+		}
+		return false
+	}
+	p.observeDepth()
+	return true
+}
+
+// Requeue devolve o item para a fila após uma breve espera.
+func (p *Pool[T]) Requeue(item T, delay time.Duration) {
+	p.opts.Clock.AfterFunc(delay, func() {
+		p.Enqueue(item)
+	})
+}
+
+// track registra no índice um item prestes a entrar na fila; nil sem Options.Key.
+func (p *Pool[T]) track(item T) *ticket {
+	if p.opts.Key == nil {
+		return nil
+	}
+	key := p.opts.Key(item)
+
+	p.indexMux.Lock()
+	defer p.indexMux.Unlock()
+	t := p.waiting[key]
+	if t == nil || t.cancelled {
+		// Os itens da chave já cancelados seguem descartados; este é novo
+		t = &ticket{key: key}
+		p.waiting[key] = t
+	}
+	t.waiting++
+	p.latest[key] = item
+	return t
+}
+
+// untrack desfaz o track de um item que não coube na fila.
+func (p *Pool[T]) untrack(t *ticket) {
+	if t == nil {
+		return
+	}
+	p.indexMux.Lock()
+	p.release(t)
+	p.indexMux.Unlock()
+}
+
+// untrackAll desfaz o track das entradas de um envio que não foi feito.
+func (p *Pool[T]) untrackAll(entries []entry[T]) {
+	for _, e := range entries {
+		p.untrack(e.ticket)
+	}
+}
+
+// claim tira do índice o item retirado por um worker; false se ele foi cancelado.
+func (p *Pool[T]) claim(t *ticket) bool {
+	if t == nil {
+		return true
+	}
+	p.indexMux.Lock()
+	defer p.indexMux.Unlock()
+
+	p.release(t)
+	if t.cancelled {
+		return false
+	}
+	p.remember(t.key)
+	return true
+}
+
+// release conta a saída de um item do ticket. Chamada com indexMux.
+func (p *Pool[T]) release(t *ticket) {
+	t.waiting--
+	if t.waiting == 0 && p.waiting[t.key] == t {
+		delete(p.waiting, t.key)
+		if p.dispatched[t.key] == 0 {
+			delete(p.latest, t.key)
+		}
+	}
+}
+
+// remember guarda a chave entre as entregues, descartando a mais antiga com o
+// anel cheio. Chamada com indexMux.
+func (p *Pool[T]) remember(key string) {
+	if len(p.history) < cap(p.history) {
+		p.history = append(p.history, key)
+	} else {
+		oldest := p.history[p.historyPos]
+		if p.dispatched[oldest]--; p.dispatched[oldest] <= 0 {
+			delete(p.dispatched, oldest)
+			if p.waiting[oldest] == nil {
+				delete(p.latest, oldest)
+			}
+		}
+		p.history[p.historyPos] = key
+		p.historyPos = (p.historyPos + 1) % len(p.history)
+	}
+	p.dispatched[key]++
+}
+
+// Cancel retira da fila os itens da chave que ainda não chegaram a um worker.
+// Chaves entregues há mais de History itens não são mais reconhecidas e
+// resultam em NotFound.
+func (p *Pool[T]) Cancel(key string) CancelResult {
+	if p.opts.Key == nil {
+		return NotFound
+	}
+	p.indexMux.Lock()
+	defer p.indexMux.Unlock()
+
+	if t := p.waiting[key]; t != nil && !t.cancelled {
+		t.cancelled = true
+		p.cancelled.Add(int64(t.waiting))
+		return Cancelled
+	}
+	if p.dispatched[key] > 0 {
+		return Dispatched
+	}
+	return NotFound
+}
+
+// Find retorna o último item da chave que ainda aguarda um worker ou foi
+// entregue a um nos últimos History itens; os cancelados não contam.
+func (p *Pool[T]) Find(key string) (T, bool) {
+	var zero T
+	if p.opts.Key == nil {
+		return zero, false
+	}
+	p.indexMux.Lock()
+	defer p.indexMux.Unlock()
+
+	t := p.waiting[key]
+	if (t == nil || t.cancelled) && p.dispatched[key] == 0 {
+		return zero, false
+	}
+	item, ok := p.latest[key]
+	return item, ok
+}
+
+// Len é a quantidade de itens aguardando um worker, nas duas faixas. Com
+// Backend, conta só os publicados nesta execução.
+func (p *Pool[T]) Len() int {
+	n := p.normal.status().Depth
+	if p.high != nil {
+		n += p.high.status().Depth
+	}
+	return n
+}
+
+// Depth soma os itens aguardando um worker e os processados fora do pool: é o
+// que a instância ainda tem em memória para enviar.
+func (p *Pool[T]) Depth() int {
+	return p.Len() + int(p.overflow.Load())
+}
+
+// Stop fecha a fila e aguarda os workers terminarem o que já foi enfileirado.
+// Deve ser chamada depois que o servidor HTTP parou de aceitar requisições. Com
+// Backend, os workers terminam só o que já foi lido; o resto fica no backend
+// para a próxima execução.
+func (p *Pool[T]) Stop(ctx context.Context) {
+	p.closeMux.Lock()
+	p.closed = true
+	for _, l := range []*lane[T]{p.normal, p.high} {
+		switch {
+		case l == nil:
+		case l.remote != nil:
+			// O consumo fecha items ao terminar
+			l.remote.cancel()
+		default:
+			close(l.items)
+		}
+	}
+	p.closeMux.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Tempo esgotado aguardando workers: %d pagamentos ainda na fila", p.Len())
+	}
+	if p.opts.Backend != nil {
+		if err := p.opts.Backend.Close(); err != nil {
+			log.Printf("Erro ao fechar a fila em %s: %v", p.opts.Backend.Name(), err)
+		}
+	}
+}
+
+func (p *Pool[T]) Status() Status {
+	status := Status{
+		Backend: "memory",
+		Count:   p.opts.Workers,
+		Active:  int(p.active.Load()),
+		Lanes:   map[string]LaneStatus{LaneNormal: p.normal.status()},
+
+		Overflow:      int(p.overflow.Load()),
+		HighWatermark: int(p.highWatermark.Load()),
+		Cancelled:     p.cancelled.Load(),
+	}
+	if p.opts.Backend != nil {
+		status.Backend = p.opts.Backend.Name()
+	}
+	if p.high != nil {
+		status.Lanes[LaneHigh] = p.high.status()
+	}
+	for _, l := range status.Lanes {
+		status.QueueDepth += l.Depth
+		status.QueueCapacity += l.Capacity
+	}
+	return status
+}
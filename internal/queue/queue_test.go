@@ -0,0 +1,69 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+)
+
+// TestWaitFollowsClock confere que a espera na fila é medida pelo relógio das
+// Options.
+func TestWaitFollowsClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC))
+	started := make(chan int, 2)
+	release := make(chan struct{})
+	p, err := New(Options[int]{
+		Workers: 1,
+		Size:    4,
+		Process: func(ctx context.Context, item int) {
+			started <- item
+			<-release
+		},
+		Label: func(item int) string { return "item" },
+		Clock: fake,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.Enqueue(1)
+	<-started
+	// O segundo espera o worker, ocupado com o primeiro, por 3s do relógio
+	p.Enqueue(2)
+	fake.Advance(3 * time.Second)
+	release <- struct{}{}
+	<-started
+	close(release)
+	p.Stop(context.Background())
+
+	lane := p.Status().Lanes[LaneNormal]
+	if lane.Taken != 2 || lane.WaitSeconds != 3 {
+		t.Errorf("faixa normal com %d retirados e %.3fs de espera, esperado 2 e 3s", lane.Taken, lane.WaitSeconds)
+	}
+}
+
+// TestRequeueWaitsOnClock confere que o item devolvido só volta à fila quando o
+// relógio das Options passa da espera.
+func TestRequeueWaitsOnClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC))
+	p, err := New(Options[int]{
+		Size:  4,
+		Label: func(item int) string { return "item" },
+		Clock: fake,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.Requeue(1, time.Second)
+	fake.Advance(time.Second - time.Millisecond)
+	if n := p.Len(); n != 0 {
+		t.Fatalf("%d itens na fila antes do fim da espera, esperado 0", n)
+	}
+	fake.Advance(time.Millisecond)
+	if n := p.Len(); n != 1 {
+		t.Errorf("%d itens na fila depois da espera, esperado 1", n)
+	}
+}
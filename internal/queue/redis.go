@@ -0,0 +1,169 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Grupo dos streams do Redis; cada stream tem um único consumidor, a instância
+// dona dele
+const redisStreamGroup = "workers"
+
+// RedisStreams guarda cada faixa em um stream do Redis,
+// <prefixo>:<consumidor>:<faixa>, lido por um consumer group. O que foi entregue
+// e não confirmado fica na lista de pendentes do consumidor e é relido na
+// próxima execução.
+type RedisStreams struct {
+	// Conexão atual; nil enquanto o Redis está indisponível
+	client   func() redis.UniversalClient
+	prefix   string
+	consumer string
+}
+
+func NewRedisStreams(client func() redis.UniversalClient, prefix, consumer string) *RedisStreams {
+	return &RedisStreams{client: client, prefix: prefix, consumer: consumer}
+}
+
+func (b *RedisStreams) Name() string { return "redis" }
+
+func (b *RedisStreams) Open(lane string) (Stream, error) {
+	return &redisStream{backend: b, key: b.prefix + ":" + b.consumer + ":" + lane}, nil
+}
+
+// Close não fecha nada: a conexão é da aplicação.
+func (b *RedisStreams) Close() error { return nil }
+
+type redisStream struct {
+	backend *RedisStreams
+	key     string
+}
+
+var errRedisUnavailable = errors.New("Redis indisponível")
+
+// Publish grava as mensagens em uma transação.
+func (s *redisStream) Publish(ctx context.Context, msgs [][]byte) error {
+	client := s.backend.client()
+	if client == nil {
+		return errRedisUnavailable
+	}
+	_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, msg := range msgs {
+			pipe.XAdd(ctx, &redis.XAddArgs{Stream: s.key, Values: map[string]any{"data": msg}})
+		}
+		return nil
+	})
+	return err
+}
+
+// Consume lê primeiro as pendentes do consumidor (id 0) e depois as novas (>).
+func (s *redisStream) Consume(ctx context.Context, out chan<- Delivery) {
+	defer close(out)
+
+	grouped := false
+	// Ainda relendo as pendentes, a partir de pendingID
+	pending, pendingID := true, "0"
+	for ctx.Err() == nil {
+		client := s.backend.client()
+		if client == nil {
+			sleep(ctx, time.Second)
+			continue
+		}
+		if !grouped {
+			err := client.XGroupCreateMkStream(ctx, s.key, redisStreamGroup, "0").Err()
+			if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+				s.retry(ctx, "criar o grupo", err)
+				continue
+			}
+			grouped = true
+		}
+
+		id := ">"
+		if pending {
+			id = pendingID
+		}
+		streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    redisStreamGroup,
+			Consumer: s.backend.consumer,
+			Streams:  []string{s.key, id},
+			Count:    int64(cap(out)),
+			Block:    time.Second,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			// O stream pode ter sido apagado: recriar o grupo
+			grouped = false
+			s.retry(ctx, "ler", err)
+			continue
+		}
+
+		var msgs []redis.XMessage
+		if len(streams) > 0 {
+			msgs = streams[0].Messages
+		}
+		if pending && len(msgs) == 0 {
+			pending = false
+			continue
+		}
+		for _, m := range msgs {
+			if pending {
+				pendingID = m.ID
+			}
+			data, ok := m.Values["data"].(string)
+			if !ok {
+				// Apagada do stream depois da entrega
+				s.ack(m.ID)
+				continue
+			}
+			msgID := m.ID
+			select {
+			case out <- Delivery{Data: []byte(data), Ack: func() { s.ack(msgID) }}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// ack confirma e apaga a mensagem; sem Redis ela volta na próxima execução.
+func (s *redisStream) ack(id string) {
+	client := s.backend.client()
+	if client == nil {
+		log.Printf("Redis indisponível: mensagem %s de %s fica pendente", id, s.key)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, s.key, redisStreamGroup, id)
+		pipe.XDel(ctx, s.key, id)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Erro ao confirmar a mensagem %s de %s: %v", id, s.key, err)
+	}
+}
+
+func (s *redisStream) retry(ctx context.Context, op string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	log.Printf("Erro ao %s (fila %s): %v", op, s.key, err)
+	sleep(ctx, time.Second)
+}
+
+// sleep espera d ou o fim de ctx.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
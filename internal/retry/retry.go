@@ -0,0 +1,131 @@
+// Package retry define quantas vezes repetir uma chamada, quanto esperar entre as
+// tentativas e quais respostas merecem nova tentativa.
+package retry
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy decide quantas tentativas fazer, quanto esperar entre elas e quais
+// respostas merecem nova tentativa.
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Fração do atraso sorteada para mais ou para menos (0 desativa)
+	Jitter float64
+	// RetryOn recebe o status HTTP da tentativa; 0 indica erro de rede/timeout
+	RetryOn func(status int) bool
+	// Duplicate reconhece os status de um correlationId que o destino já tem
+	Duplicate func(status int) bool
+	// Terminal reconhece as recusas que nenhuma nova tentativa mudaria
+	Terminal func(status int) bool
+	// Ambiguous reconhece os status que não dizem se o destino processou a chamada
+	Ambiguous func(status int) bool
+}
+
+// Class é a classificação de uma resposta sem sucesso.
+type Class int
+
+const (
+	// Vale tentar de novo (RetryOn)
+	ClassRetryable Class = iota
+	// Nenhuma nova tentativa, aqui ou em outro destino, mudaria a recusa
+	ClassTerminal
+	// O destino pode ter processado a chamada: conferir antes de repetir
+	ClassAmbiguous
+	// O destino já tinha a chamada
+	ClassDuplicate
+	// Não repetir neste destino, sem dizer nada dos outros
+	ClassOther
+)
+
+// ClassNames são os nomes das classes, na ordem das constantes.
+var ClassNames = [...]string{
+	ClassRetryable: "retryable",
+	ClassTerminal:  "terminal",
+	ClassAmbiguous: "ambiguous",
+	ClassDuplicate: "duplicate",
+	ClassOther:     "other",
+}
+
+func (c Class) String() string {
+	return ClassNames[c]
+}
+
+// Classify classifica uma resposta sem sucesso pelo status; vale a primeira
+// lista que o contém, na ordem Duplicate, Ambiguous, Terminal e RetryOn. Erros
+// de rede (status 0) são sempre repetíveis: quem chama sabe se foram ambíguos.
+func (p Policy) Classify(status int) Class {
+	switch {
+	case p.IsDuplicate(status):
+		return ClassDuplicate
+	case status != 0 && p.Ambiguous != nil && p.Ambiguous(status):
+		return ClassAmbiguous
+	case status != 0 && p.Terminal != nil && p.Terminal(status):
+		return ClassTerminal
+	case p.ShouldRetry(status):
+		return ClassRetryable
+	}
+	return ClassOther
+}
+
+// Delay é a espera antes da tentativa de número attempt (a partir de 1):
+// BaseDelay * 2^(attempt-1), limitado a MaxDelay e com jitter.
+func (p Policy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay += time.Duration((rand.Float64()*2 - 1) * spread)
+		if delay < 0 {
+			delay = 0
+		}
+	}
+	return delay
+}
+
+// ShouldRetry indica se vale tentar de novo depois de uma resposta com esse status.
+func (p Policy) ShouldRetry(status int) bool {
+	return p.RetryOn == nil || p.RetryOn(status)
+}
+
+// IsDuplicate indica que a resposta recusou a chamada por já tê-la recebido.
+// Erros de rede (status 0) nunca são.
+func (p Policy) IsDuplicate(status int) bool {
+	return status != 0 && p.Duplicate != nil && p.Duplicate(status)
+}
+
+// ParseStatusList interpreta listas como "408,429,5xx". Erros de rede (status 0)
+// sempre são repetidos.
+func ParseStatusList(spec string) (func(status int) bool, error) {
+	exact := make(map[int]bool)
+	classes := make(map[int]bool)
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		if len(item) == 3 && strings.HasSuffix(item, "xx") && item[0] >= '1' && item[0] <= '5' {
+			classes[int(item[0]-'0')] = true
+			continue
+		}
+		code, err := strconv.Atoi(item)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("status inválido: %q", item)
+		}
+		exact[code] = true
+	}
+
+	return func(status int) bool {
+		return status == 0 || exact[status] || classes[status/100]
+	}, nil
+}
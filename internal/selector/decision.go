@@ -0,0 +1,50 @@
+package selector
+
+import "time"
+
+// Desfechos de uma decisão
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	// Sem confirmação nem recusa; resolvido depois pela reconciliação
+	OutcomeUnknown  = "unknown"
+	OutcomeRejected = "rejected"
+)
+
+// Decision é uma decisão de roteamento registrada pela API
+// (SELECTOR_DECISION_LOG): o que a estratégia sabia de cada processor, a ordem
+// tentada e o desfecho do envio. O cmd/replay reaplica os candidatos a outras
+// estratégias.
+type Decision struct {
+	At       time.Time `json:"at"`
+	Amount   float64   `json:"amount"`
+	Strategy string    `json:"strategy"`
+	// Na ordem de prioridade, como a estratégia os recebeu
+	Candidates []Candidate `json:"candidates"`
+	// Ordem tentada, depois do failback, da guarda de SLO e dos tetos por valor
+	Ranking []string `json:"ranking"`
+	// O que aceitou o pagamento ou o último tentado
+	Processor string `json:"processor"`
+	Outcome   string `json:"outcome"`
+	// Do primeiro envio ao desfecho, retentativas incluídas, em nanossegundos
+	Latency time.Duration `json:"latency"`
+	// Instância que decidiu
+	Instance string `json:"instance,omitempty"`
+}
+
+// Candidate retorna o candidato pelo nome.
+func (d Decision) Candidate(name string) (Candidate, bool) {
+	for _, c := range d.Candidates {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Candidate{}, false
+}
+
+// SuccessProbability é a estimativa da estratégia "profit" de que o candidato
+// aceite o próximo envio, exposta para o replay estimar os desfechos que não
+// aconteceram.
+func SuccessProbability(c Candidate) float64 {
+	return successProbability(c)
+}
@@ -0,0 +1,233 @@
+// Package selector decide a ordem em que os processors são tentados.
+package selector
+
+import (
+	"log"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+)
+
+// Candidate é o que o selector sabe sobre cada processor.
+// As tags servem ao registro de decisões (ver decision.go); as durações vão em
+// nanossegundos.
+type Candidate struct {
+	Name     string  `json:"name"`
+	Fee      float64 `json:"fee"`
+	Priority int     `json:"priority"`
+	// Último health-check conhecido
+	Failing         bool          `json:"failing"`
+	MinResponseTime time.Duration `json:"minResponseTime"`
+	// Latência observada no quantil configurado e o número de amostras por trás dela
+	Observed time.Duration `json:"observed"`
+	Samples  int64         `json:"samples"`
+	// Tentativas aceitas e recusadas na janela recente
+	Successes int64 `json:"successes"`
+	Failures  int64 `json:"failures"`
+}
+
+// Selector ordena os processors na sequência em que o próximo pagamento
+// deve tentá-los, a partir do estado de saúde conhecido de cada um. Os
+// candidatos chegam ordenados por prioridade.
+type Selector interface {
+	Rank(candidates []Candidate) []string
+}
+
+// Score é o que a estratégia calculou para um candidato, para explicar a ordem
+// (GET /admin/routing).
+type Score struct {
+	// Latência considerada (score): a observada com amostras suficientes, senão
+	// o minResponseTime anunciado
+	Latency time.Duration
+	// Acima de LatencyThresholdMs, vai para o fim dos saudáveis (score)
+	Slow bool
+	// Custo ponderado taxa x latência, o menor primeiro (score)
+	Cost float64
+	// Valor esperado do envio e chance de ser sorteado primeiro (profit)
+	Value  float64
+	Chance float64
+}
+
+// Explainer é implementado pelas estratégias que pontuam os candidatos.
+type Explainer interface {
+	// Scores pontua cada candidato, pelo nome, como o Rank o faria agora
+	Scores(candidates []Candidate) map[string]Score
+}
+
+// New cria a estratégia pelo nome ("failover", "profit" ou "score", padrão).
+func New(cfg config.SelectorConfig) Selector {
+	switch cfg.Strategy {
+	case "failover":
+		return failoverSelector{}
+	case "profit":
+		return &profitSelector{cfg: cfg}
+	case "", "score":
+		return &scoringSelector{cfg: cfg}
+	default:
+		log.Printf("Estratégia de seleção desconhecida %q, usando score", cfg.Strategy)
+		return &scoringSelector{cfg: cfg}
+	}
+}
+
+// failoverSelector é a regra original: por prioridade, pulando os que estão falhando.
+type failoverSelector struct{}
+
+func (failoverSelector) Rank(candidates []Candidate) []string {
+	return rankHealthyFirst(candidates, nil)
+}
+
+// scoringSelector prefere o processor de menor custo ponderado taxa x latência,
+// deixando para o fim os que passam do limite de latência.
+type scoringSelector struct {
+	cfg config.SelectorConfig
+}
+
+func (s *scoringSelector) Rank(candidates []Candidate) []string {
+	return rankHealthyFirst(candidates, func(a, b Candidate) bool {
+		aSlow, bSlow := s.slow(a), s.slow(b)
+		if aSlow != bSlow {
+			return bSlow
+		}
+		return s.cost(a) < s.cost(b)
+	})
+}
+
+func (s *scoringSelector) Scores(candidates []Candidate) map[string]Score {
+	scores := make(map[string]Score, len(candidates))
+	for _, c := range candidates {
+		scores[c.Name] = Score{Latency: s.latency(c), Slow: s.slow(c), Cost: s.cost(c)}
+	}
+	return scores
+}
+
+func (s *scoringSelector) slow(c Candidate) bool {
+	return s.cfg.LatencyThresholdMs > 0 && s.latency(c) > time.Duration(s.cfg.LatencyThresholdMs)*time.Millisecond
+}
+
+func (s *scoringSelector) cost(c Candidate) float64 {
+	return s.cfg.FeeWeight*c.Fee + s.cfg.LatencyWeight*s.latency(c).Seconds()
+}
+
+// latency prefere o que os envios mediram ao minResponseTime anunciado pelo
+// processor, quando há amostras suficientes.
+func (s *scoringSelector) latency(c Candidate) time.Duration {
+	if s.cfg.MinLatencySamples > 0 && c.Samples >= s.cfg.MinLatencySamples {
+		return c.Observed
+	}
+	return c.MinResponseTime
+}
+
+// profitSelector sorteia o primeiro processor com pesos softmax sobre o valor
+// esperado de cada envio, probabilidade de sucesso x (1 - taxa), em vez de
+// descartar de vez quem está falhando. Temperature controla a exploração: quanto
+// menor, mais tráfego vai para o de maior valor esperado. Os demais seguem em
+// ordem decrescente de valor esperado, para as retentativas.
+type profitSelector struct {
+	cfg config.SelectorConfig
+	// Sorteio em [0, 1); nil usa o math/rand global (os testes fixam a semente)
+	random func() float64
+}
+
+func (s *profitSelector) Rank(candidates []Candidate) []string {
+	ranked := append([]Candidate(nil), candidates...)
+	values := make(map[string]float64, len(ranked))
+	for _, c := range ranked {
+		values[c.Name] = expectedValue(c)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return values[ranked[i].Name] > values[ranked[j].Name]
+	})
+
+	weights, total := s.weights(ranked, values)
+	pick := 0
+	random := s.random
+	if random == nil {
+		random = rand.Float64
+	}
+	for r := random() * total; pick < len(ranked)-1; pick++ {
+		r -= weights[pick]
+		if r < 0 {
+			break
+		}
+	}
+
+	names := make([]string, 0, len(ranked))
+	names = append(names, ranked[pick].Name)
+	for i, c := range ranked {
+		if i != pick {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+func (s *profitSelector) Scores(candidates []Candidate) map[string]Score {
+	values := make(map[string]float64, len(candidates))
+	for _, c := range candidates {
+		values[c.Name] = expectedValue(c)
+	}
+	weights, total := s.weights(candidates, values)
+	scores := make(map[string]Score, len(candidates))
+	for i, c := range candidates {
+		scores[c.Name] = Score{Value: values[c.Name], Chance: weights[i] / total}
+	}
+	return scores
+}
+
+// weights são os pesos softmax dos candidatos, relativos ao de maior valor para
+// exp não estourar com temperaturas baixas, e a soma deles.
+func (s *profitSelector) weights(candidates []Candidate, values map[string]float64) ([]float64, float64) {
+	best := math.Inf(-1)
+	for _, c := range candidates {
+		best = max(best, values[c.Name])
+	}
+	weights := make([]float64, len(candidates))
+	var total float64
+	for i, c := range candidates {
+		weights[i] = math.Exp((values[c.Name] - best) / s.cfg.Temperature)
+		total += weights[i]
+	}
+	return weights, total
+}
+
+// expectedValue é o quanto um envio ao processor rende: probabilidade de
+// sucesso x (1 - taxa).
+func expectedValue(c Candidate) float64 {
+	return successProbability(c) * (1 - c.Fee)
+}
+
+// successProbability estima a chance de o processor aceitar o próximo envio:
+// os desfechos recentes com o health-check como uma observação a mais, para que
+// um processor sem tráfego recente não fique com estimativa nula nem perfeita.
+func successProbability(c Candidate) float64 {
+	prior := 1.0
+	if c.Failing {
+		prior = 0
+	}
+	return (float64(c.Successes) + prior) / float64(c.Successes+c.Failures+1)
+}
+
+// rankHealthyFirst coloca os saudáveis antes dos que estão falhando. Os saudáveis
+// seguem less (empates pela prioridade); os demais, a prioridade.
+func rankHealthyFirst(candidates []Candidate, less func(a, b Candidate) bool) []string {
+	sorted := append([]Candidate(nil), candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Failing != b.Failing {
+			return b.Failing
+		}
+		if less != nil && !a.Failing {
+			return less(a, b)
+		}
+		return false
+	})
+
+	names := make([]string, len(sorted))
+	for i, c := range sorted {
+		names[i] = c.Name
+	}
+	return names
+}
@@ -0,0 +1,276 @@
+package selector
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"rinha-backend-2025/internal/config"
+	pp "rinha-backend-2025/internal/processor"
+)
+
+// candidates monta default e fallback, nesta ordem de prioridade, como a API os
+// monta a partir do último health-check de cada um.
+func candidates(defaultHealth, fallbackHealth pp.Health) []Candidate {
+	return []Candidate{
+		{Name: "default", Fee: 0.05, Priority: 0, Failing: defaultHealth.Failing,
+			MinResponseTime: time.Duration(defaultHealth.MinResponseTime) * time.Millisecond},
+		{Name: "fallback", Fee: 0.15, Priority: 1, Failing: fallbackHealth.Failing,
+			MinResponseTime: time.Duration(fallbackHealth.MinResponseTime) * time.Millisecond},
+	}
+}
+
+func TestFailoverRank(t *testing.T) {
+	tests := []struct {
+		name                          string
+		defaultHealth, fallbackHealth pp.Health
+		want                          []string
+	}{
+		{"ambos saudáveis", pp.Health{}, pp.Health{}, []string{"default", "fallback"}},
+		{"default falhando", pp.Health{Failing: true}, pp.Health{}, []string{"fallback", "default"}},
+		{"fallback falhando", pp.Health{}, pp.Health{Failing: true}, []string{"default", "fallback"}},
+		{"ambos falhando", pp.Health{Failing: true}, pp.Health{Failing: true}, []string{"default", "fallback"}},
+		// A latência não conta no failover
+		{"default lento", pp.Health{MinResponseTime: 5000}, pp.Health{}, []string{"default", "fallback"}},
+	}
+
+	s := New(config.SelectorConfig{Strategy: "failover"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Rank(candidates(tt.defaultHealth, tt.fallbackHealth)); !slices.Equal(got, tt.want) {
+				t.Errorf("Rank = %v, esperado %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreRank(t *testing.T) {
+	// Custo: taxa + latência em segundos; default custa 0,10 a menos na taxa
+	weights := config.SelectorConfig{Strategy: "score", FeeWeight: 1, LatencyWeight: 1}
+	withThreshold := weights
+	withThreshold.LatencyThresholdMs = 50
+	feeOnly := weights
+	feeOnly.LatencyWeight = 0
+
+	tests := []struct {
+		name                          string
+		cfg                           config.SelectorConfig
+		defaultHealth, fallbackHealth pp.Health
+		want                          []string
+	}{
+		{"mesma latência", weights, pp.Health{MinResponseTime: 5}, pp.Health{MinResponseTime: 5}, []string{"default", "fallback"}},
+		{"latência compensa a taxa", weights, pp.Health{MinResponseTime: 200}, pp.Health{MinResponseTime: 5}, []string{"fallback", "default"}},
+		{"latência não compensa a taxa", weights, pp.Health{MinResponseTime: 90}, pp.Health{MinResponseTime: 5}, []string{"default", "fallback"}},
+		{"sem peso na latência", feeOnly, pp.Health{MinResponseTime: 200}, pp.Health{MinResponseTime: 5}, []string{"default", "fallback"}},
+		{"default acima do limite", withThreshold, pp.Health{MinResponseTime: 60}, pp.Health{MinResponseTime: 5}, []string{"fallback", "default"}},
+		{"no limite", withThreshold, pp.Health{MinResponseTime: 50}, pp.Health{MinResponseTime: 5}, []string{"default", "fallback"}},
+		{"ambos acima do limite", withThreshold, pp.Health{MinResponseTime: 60}, pp.Health{MinResponseTime: 70}, []string{"default", "fallback"}},
+		{"default falhando", weights, pp.Health{Failing: true, MinResponseTime: 5}, pp.Health{MinResponseTime: 5}, []string{"fallback", "default"}},
+		{"fallback lento mas saudável", withThreshold, pp.Health{Failing: true}, pp.Health{MinResponseTime: 500}, []string{"fallback", "default"}},
+		{"ambos falhando", weights, pp.Health{Failing: true}, pp.Health{Failing: true}, []string{"default", "fallback"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.cfg).Rank(candidates(tt.defaultHealth, tt.fallbackHealth)); !slices.Equal(got, tt.want) {
+				t.Errorf("Rank = %v, esperado %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestScoreRankObservedLatency confere que a latência medida nos envios
+// substitui o minResponseTime só com amostras suficientes.
+func TestScoreRankObservedLatency(t *testing.T) {
+	cfg := config.SelectorConfig{Strategy: "score", FeeWeight: 1, LatencyWeight: 1, MinLatencySamples: 10}
+	tests := []struct {
+		name    string
+		samples int64
+		want    []string
+	}{
+		{"poucas amostras", 9, []string{"default", "fallback"}},
+		{"amostras suficientes", 10, []string{"fallback", "default"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := candidates(pp.Health{MinResponseTime: 5}, pp.Health{MinResponseTime: 5})
+			c[0].Observed, c[0].Samples = 300*time.Millisecond, tt.samples
+			if got := New(cfg).Rank(c); !slices.Equal(got, tt.want) {
+				t.Errorf("Rank = %v, esperado %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreScores(t *testing.T) {
+	cfg := config.SelectorConfig{Strategy: "score", FeeWeight: 1, LatencyWeight: 2, LatencyThresholdMs: 50, MinLatencySamples: 10}
+	c := candidates(pp.Health{MinResponseTime: 5}, pp.Health{MinResponseTime: 20})
+	c[0].Observed, c[0].Samples = 80*time.Millisecond, 10
+	c[1].Observed, c[1].Samples = 900*time.Millisecond, 3
+
+	scores := New(cfg).(Explainer).Scores(c)
+	tests := []struct {
+		name    string
+		latency time.Duration
+		slow    bool
+		cost    float64
+	}{
+		{"default", 80 * time.Millisecond, true, 0.05 + 2*0.08},
+		{"fallback", 20 * time.Millisecond, false, 0.15 + 2*0.02},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := scores[tt.name]
+			if !ok {
+				t.Fatalf("sem score para %s: %v", tt.name, scores)
+			}
+			if got.Latency != tt.latency || got.Slow != tt.slow || math.Abs(got.Cost-tt.cost) > 1e-9 {
+				t.Errorf("Score = %+v, esperado latência %s, lento %v, custo %.3f", got, tt.latency, tt.slow, tt.cost)
+			}
+		})
+	}
+}
+
+// newSeededProfit cria a estratégia "profit" com o sorteio de semente fixa, para
+// o resultado não variar entre execuções.
+func newSeededProfit(temperature float64) *profitSelector {
+	return &profitSelector{
+		cfg:    config.SelectorConfig{Strategy: "profit", Temperature: temperature},
+		random: rand.New(rand.NewPCG(1, 2)).Float64,
+	}
+}
+
+// firstPicks conta quantas vezes cada processor sai primeiro em n sorteios.
+func firstPicks(s Selector, c []Candidate, n int) map[string]int {
+	picks := make(map[string]int)
+	for range n {
+		picks[s.Rank(c)[0]]++
+	}
+	return picks
+}
+
+func TestProfitRank(t *testing.T) {
+	const draws = 2000
+	// Desfechos recentes iguais: só a taxa separa os dois
+	even := func() []Candidate {
+		c := candidates(pp.Health{}, pp.Health{})
+		for i := range c {
+			c[i].Successes = 100
+		}
+		return c
+	}
+	// default recusa 15% dos envios: 0,85 x 0,95 < 1 x 0,85
+	defaultFailingOften := func() []Candidate {
+		c := even()
+		c[0].Successes, c[0].Failures = 85, 15
+		return c
+	}
+	// default recusa 5%: ainda rende mais que o fallback
+	defaultFailingRarely := func() []Candidate {
+		c := even()
+		c[0].Successes, c[0].Failures = 95, 5
+		return c
+	}
+
+	tests := []struct {
+		name        string
+		temperature float64
+		candidates  []Candidate
+		// Faixa de sorteios em que o default sai primeiro
+		minDefault, maxDefault int
+	}{
+		{"taxa menor, temperatura baixa", 0.01, even(), draws - 5, draws},
+		{"taxa menor, temperatura alta", 1, even(), draws * 50 / 100, draws * 56 / 100},
+		{"default falha acima do ponto de troca", 0.01, defaultFailingOften(), 0, draws * 5 / 100},
+		{"default falha abaixo do ponto de troca", 0.01, defaultFailingRarely(), draws * 95 / 100, draws},
+		{"default sem health-check bom nem desfechos", 0.01, candidates(pp.Health{Failing: true}, pp.Health{}), 0, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picks := firstPicks(newSeededProfit(tt.temperature), tt.candidates, draws)
+			if picks["default"] < tt.minDefault || picks["default"] > tt.maxDefault {
+				t.Errorf("default primeiro em %d de %d sorteios, esperado entre %d e %d (%v)",
+					picks["default"], draws, tt.minDefault, tt.maxDefault, picks)
+			}
+		})
+	}
+}
+
+// TestProfitRankIsDeterministic confere que a mesma semente repete a sequência
+// de rankings, e que os demais seguem por valor esperado.
+func TestProfitRankIsDeterministic(t *testing.T) {
+	c := candidates(pp.Health{}, pp.Health{})
+	a, b := newSeededProfit(0.1), newSeededProfit(0.1)
+	for i := range 100 {
+		got, want := a.Rank(c), b.Rank(c)
+		if !slices.Equal(got, want) {
+			t.Fatalf("sorteio %d: %v e %v com a mesma semente", i, got, want)
+		}
+		if len(got) != 2 || got[0] == got[1] {
+			t.Fatalf("sorteio %d: ranking %v sem os dois processors", i, got)
+		}
+	}
+}
+
+func TestProfitScores(t *testing.T) {
+	c := candidates(pp.Health{}, pp.Health{})
+	c[0].Successes, c[0].Failures = 9, 1
+	c[1].Successes = 10
+
+	for _, temperature := range []float64{0.01, 0.1, 1} {
+		s := newSeededProfit(temperature)
+		scores := s.Scores(c)
+		// (9 + 1) / 11 x 0,95 e (10 + 1) / 11 x 0,85
+		wantValues := map[string]float64{"default": 10.0 / 11 * 0.95, "fallback": 0.85}
+		for name, want := range wantValues {
+			if got := scores[name].Value; math.Abs(got-want) > 1e-9 {
+				t.Errorf("temperatura %v: valor de %s = %.4f, esperado %.4f", temperature, name, got, want)
+			}
+		}
+		wantChance := 1 / (1 + math.Exp((wantValues["fallback"]-wantValues["default"])/temperature))
+		if got := scores["default"].Chance; math.Abs(got-wantChance) > 1e-9 {
+			t.Errorf("temperatura %v: chance do default = %.4f, esperado %.4f", temperature, got, wantChance)
+		}
+		if sum := scores["default"].Chance + scores["fallback"].Chance; math.Abs(sum-1) > 1e-9 {
+			t.Errorf("temperatura %v: chances somam %.4f", temperature, sum)
+		}
+
+		// O sorteio segue a chance anunciada
+		const draws = 4000
+		share := float64(firstPicks(s, c, draws)["default"]) / draws
+		if math.Abs(share-wantChance) > 0.03 {
+			t.Errorf("temperatura %v: default primeiro em %.3f dos sorteios, chance %.3f", temperature, share, wantChance)
+		}
+	}
+}
+
+func BenchmarkRank(b *testing.B) {
+	cfg, err := config.Load()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, strategy := range []string{"score", "profit", "failover"} {
+		b.Run(strategy, func(b *testing.B) {
+			selectorCfg := cfg.Selector
+			selectorCfg.Strategy = strategy
+			s := New(selectorCfg)
+			candidates := []Candidate{
+				{Name: "default", Fee: selectorCfg.DefaultFee, Priority: 0, MinResponseTime: 5 * time.Millisecond,
+					Observed: 12 * time.Millisecond, Samples: 500, Successes: 480, Failures: 20},
+				{Name: "fallback", Fee: selectorCfg.FallbackFee, Priority: 1, MinResponseTime: 3 * time.Millisecond,
+					Observed: 8 * time.Millisecond, Samples: 500, Successes: 500},
+			}
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if len(s.Rank(candidates)) == 0 {
+					b.Fatal("ranking vazio")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/ids"
+)
+
+// AmountTracker é implementado pelos storages que mantêm o histograma de valores
+// por segundo de requestedAt e a lista dos maiores pagamentos, atualizados na
+// gravação. Nos demais, os dois são calculados a partir da exportação do período.
+type AmountTracker interface {
+	QueryAmounts(ctx context.Context, from, to time.Time, opts AmountOptions) (AmountStats, error)
+}
+
+// ErrNoAmountStats indica um storage sem histograma nem exportação para calculá-lo.
+var ErrNoAmountStats = errors.New("storage não permite consultar os valores por período")
+
+// AmountOptions dimensiona o histograma e a lista dos maiores pagamentos.
+type AmountOptions struct {
+	// Limites superiores dos buckets, crescentes; acima do último conta no +Inf
+	Bounds []float64
+	// Tamanho da lista dos maiores; 0 não a mantém
+	Top int
+}
+
+// AmountStats é o histograma de cada processor e os maiores pagamentos com
+// requestedAt em [from, to].
+type AmountStats struct {
+	Processors map[string]AmountHistogram
+	// Do maior para o menor; no Redis, só os que continuam entre os Top maiores
+	// desde o último purge
+	Largest []Record
+}
+
+// AmountHistogram conta os pagamentos de cada bucket, na ordem de Bounds e com
+// o +Inf no fim; não é cumulativo.
+type AmountHistogram struct {
+	Counts  []int
+	Amounts []float64
+}
+
+// QueryAmounts retorna o histograma e os maiores pagamentos de [from, to].
+func QueryAmounts(ctx context.Context, s Storage, from, to time.Time, opts AmountOptions) (AmountStats, error) {
+	if tracker, ok := s.(AmountTracker); ok {
+		return tracker.QueryAmounts(ctx, from, to, opts)
+	}
+	if exporter, ok := s.(Exporter); ok {
+		return amountsByExport(ctx, exporter, from, to, opts)
+	}
+	return AmountStats{}, ErrNoAmountStats
+}
+
+// amountBucket é o índice do bucket do valor: o primeiro limite que o comporta.
+func amountBucket(bounds []float64, amount float64) int {
+	i, _ := slices.BinarySearch(bounds, amount)
+	return i
+}
+
+// amountLabel nomeia o bucket i nos campos do Redis, como o le do Prometheus.
+func amountLabel(bounds []float64, i int) string {
+	if i >= len(bounds) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(bounds[i], 'f', -1, 64)
+}
+
+// amountSeries acumula o histograma e os maiores de um período.
+type amountSeries struct {
+	opts  AmountOptions
+	stats AmountStats
+}
+
+func newAmountSeries(opts AmountOptions) *amountSeries {
+	return &amountSeries{opts: opts, stats: AmountStats{Processors: make(map[string]AmountHistogram), Largest: []Record{}}}
+}
+
+func (a *amountSeries) add(processor string, bucket, requests int, amount float64) {
+	histogram, ok := a.stats.Processors[processor]
+	if !ok {
+		histogram = AmountHistogram{
+			Counts:  make([]int, len(a.opts.Bounds)+1),
+			Amounts: make([]float64, len(a.opts.Bounds)+1),
+		}
+		a.stats.Processors[processor] = histogram
+	}
+	histogram.Counts[bucket] += requests
+	histogram.Amounts[bucket] += amount
+}
+
+// offer considera o pagamento para a lista dos maiores.
+func (a *amountSeries) offer(record Record) {
+	if a.opts.Top <= 0 {
+		return
+	}
+	largest := a.stats.Largest
+	if len(largest) == a.opts.Top && record.Amount <= largest[len(largest)-1].Amount {
+		return
+	}
+	i, _ := slices.BinarySearchFunc(largest, record.Amount, func(r Record, amount float64) int {
+		// Ordem decrescente
+		switch {
+		case r.Amount > amount:
+			return -1
+		case r.Amount < amount:
+			return 1
+		}
+		return 0
+	})
+	largest = slices.Insert(largest, i, record)
+	if len(largest) > a.opts.Top {
+		largest = largest[:a.opts.Top]
+	}
+	a.stats.Largest = largest
+}
+
+func amountsByExport(ctx context.Context, exporter Exporter, from, to time.Time, opts AmountOptions) (AmountStats, error) {
+	series := newAmountSeries(opts)
+	err := exporter.ExportByRange(ctx, from, to, func(record Record) error {
+		series.add(record.Processor, amountBucket(opts.Bounds, record.Amount), 1, record.Amount)
+		series.offer(record)
+		return nil
+	})
+	return series.stats, err
+}
+
+// No Redis, o histograma fica nas hashes por segundo de timeseries.go, com os
+// campos hist:<processor>:<limite> e histamount:<processor>:<limite>, e os
+// maiores no ZSET top-amounts:{rinha} (score = valor, membro =
+// requestedAt em ms:processor:correlationId), cortado nos Top maiores a cada
+// gravação. Um limite que saiu de STORAGE_AMOUNT_BOUNDS deixa de ser lido.
+
+func (s *Redis) topAmountsKey(epoch int64) string {
+	return s.epochPrefix(epoch, "top-amounts")
+}
+
+func topAmountMember(payment Record) string {
+	return strconv.FormatInt(payment.RequestedAt.UnixMilli(), 10) + ":" + payment.Processor + ":" + ids.Encode(payment.CorrelationID)
+}
+
+func parseTopAmountMember(member string, amount float64) (Record, bool) {
+	millis, rest, ok := strings.Cut(member, ":")
+	if !ok {
+		return Record{}, false
+	}
+	processor, id, ok := strings.Cut(rest, ":")
+	unix, err := strconv.ParseInt(millis, 10, 64)
+	if !ok || err != nil {
+		return Record{}, false
+	}
+	return Record{
+		CorrelationID: ids.Decode(id),
+		Amount:        amount,
+		Processor:     processor,
+		RequestedAt:   time.UnixMilli(unix).UTC(),
+	}, true
+}
+
+type skipAmountHistogramKey struct{}
+
+// WithoutAmountHistogram deixa as escritas feitas com ctx fora do histograma de
+// valores, que passa a subcontar o período; os maiores pagamentos continuam
+// sendo guardados.
+func WithoutAmountHistogram(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipAmountHistogramKey{}, true)
+}
+
+// addAmountStats enfileira no pipeline da gravação o histograma e os maiores do
+// lote, e retorna as chaves escritas fora das hashes por segundo.
+func (s *Redis) addAmountStats(ctx context.Context, pipe RedisPipeline, epoch int64, payments []Record) []string {
+	if ctx.Value(skipAmountHistogramKey{}) == nil {
+		for _, payment := range payments {
+			label := amountLabel(s.amounts.Bounds, amountBucket(s.amounts.Bounds, payment.Amount))
+			key := s.bucketKey(epoch, strconv.FormatInt(payment.RequestedAt.Unix(), 10))
+			pipe.HIncrBy(key, "hist:"+payment.Processor+":"+label, 1)
+			pipe.HIncrByFloat(key, "histamount:"+payment.Processor+":"+label, payment.Amount)
+		}
+	}
+	if s.amounts.Top <= 0 {
+		return nil
+	}
+	members := make([]redis.Z, len(payments))
+	for i, payment := range payments {
+		members[i] = redis.Z{Score: payment.Amount, Member: topAmountMember(payment)}
+	}
+	key := s.topAmountsKey(epoch)
+	pipe.ZAdd(key, members...)
+	pipe.ZRemRangeByRank(key, 0, -int64(s.amounts.Top)-1)
+	return []string{key}
+}
+
+func (s *Redis) QueryAmounts(ctx context.Context, from, to time.Time, opts AmountOptions) (AmountStats, error) {
+	series := newAmountSeries(opts)
+	labels := make(map[string]int, len(opts.Bounds)+1)
+	for i := 0; i <= len(opts.Bounds); i++ {
+		labels[amountLabel(opts.Bounds, i)] = i
+	}
+
+	epoch := s.Epoch()
+	seconds, err := s.client.ZRangeByScore(ctx, s.bucketIndexKey(epoch), &redis.ZRangeBy{
+		Min: strconv.FormatInt(from.Unix(), 10),
+		Max: strconv.FormatInt(to.Unix(), 10),
+	})
+	if err != nil {
+		return AmountStats{}, err
+	}
+	for page := 0; page < len(seconds); page += bucketPage {
+		chunk := seconds[page:min(page+bucketPage, len(seconds))]
+		keys := make([]string, len(chunk))
+		for i, second := range chunk {
+			keys[i] = s.bucketKey(epoch, second)
+		}
+		hashes, err := s.client.HGetAllMany(ctx, keys)
+		if err != nil {
+			return AmountStats{}, err
+		}
+		for _, hash := range hashes {
+			for field, value := range hash {
+				kind, rest, _ := strings.Cut(field, ":")
+				if kind != "hist" && kind != "histamount" {
+					continue
+				}
+				processor, label, ok := strings.Cut(rest, ":")
+				bucket, known := labels[label]
+				if !ok || !known {
+					continue
+				}
+				if kind == "hist" {
+					n, _ := strconv.Atoi(value)
+					series.add(processor, bucket, n, 0)
+				} else {
+					amount, _ := strconv.ParseFloat(value, 64)
+					series.add(processor, bucket, 0, amount)
+				}
+			}
+		}
+	}
+
+	if opts.Top > 0 {
+		// O ZSET vem em ordem crescente; percorrido do fim, do maior para o menor
+		top, err := s.client.ZRangeByScoreWithScores(ctx, s.topAmountsKey(epoch), &redis.ZRangeBy{Min: "-inf", Max: "+inf"})
+		if err != nil {
+			return AmountStats{}, err
+		}
+		for i := len(top) - 1; i >= 0 && len(series.stats.Largest) < opts.Top; i-- {
+			member, _ := top[i].Member.(string)
+			record, ok := parseTopAmountMember(member, top[i].Score)
+			if ok && !record.RequestedAt.Before(from) && !record.RequestedAt.After(to) {
+				series.stats.Largest = append(series.stats.Largest, record)
+			}
+		}
+	}
+	return series.stats, nil
+}
+
+// QueryAmounts usa o Redis enquanto ele responde; em modo degradado, apenas os
+// pagamentos registrados desde a queda.
+func (s *Degradable) QueryAmounts(ctx context.Context, from, to time.Time, opts AmountOptions) (AmountStats, error) {
+	s.mu.RLock()
+	remote, local := s.remote, s.local
+	s.mu.RUnlock()
+
+	if remote != nil {
+		return remote.QueryAmounts(ctx, from, to, opts)
+	}
+	return amountsByExport(ctx, local, from, to, opts)
+}
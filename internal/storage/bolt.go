@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltSummaryBucket  = []byte("summary")
+	boltPaymentsBucket = []byte("payments")
+	boltRecordsBucket  = []byte("records")
+
+	boltBuckets = [][]byte{boltSummaryBucket, boltPaymentsBucket, boltRecordsBucket}
+)
+
+// Bolt guarda tudo em um arquivo bbolt local, para rodar como binário único sem
+// Redis. No bucket summary, cada processor tem requisições e valor (8 bytes
+// cada); no bucket payments, a chave é requestedAt em ms (big-endian) seguido do
+// correlationId, para que as consultas por período sejam um cursor em ordem, e o
+// valor é o amount (8 bytes) seguido do processor; no bucket records, o registro
+// completo em JSON pelo correlationId, para a consulta de um pagamento. O arquivo
+// é exclusivo do processo: cada instância tem o seu.
+type Bolt struct {
+	db *bolt.DB
+}
+
+func NewBolt(path string) (*Bolt, error) {
+	// Sem o timeout, Open espera para sempre enquanto outro processo segura o arquivo
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range boltBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("erro ao preparar %s: %w", path, err)
+	}
+	return &Bolt{db: db}, nil
+}
+
+func (s *Bolt) IncrementSummary(ctx context.Context, deltas map[string]*Delta) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltSummaryBucket)
+		for processor, delta := range deltas {
+			current := decodeBoltSummary(bucket.Get([]byte(processor)))
+			current.TotalRequests += int(delta.Requests)
+			current.TotalAmount += delta.Amount
+			if err := bucket.Put([]byte(processor), encodeBoltSummary(current)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Bolt) GetSummary(ctx context.Context) (map[string]Summary, error) {
+	summary := make(map[string]Summary)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSummaryBucket).ForEach(func(k, v []byte) error {
+			summary[string(k)] = decodeBoltSummary(v)
+			return nil
+		})
+	})
+	return summary, err
+}
+
+func (s *Bolt) RecordPayment(ctx context.Context, payment Record) error {
+	return s.RecordPayments(ctx, []Record{payment})
+}
+
+// RecordPayments grava o lote inteiro em uma única transação.
+func (s *Bolt) RecordPayments(ctx context.Context, payments []Record) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltPaymentsBucket)
+		records := tx.Bucket(boltRecordsBucket)
+		for _, payment := range payments {
+			value := make([]byte, 8, 8+len(payment.Processor))
+			binary.BigEndian.PutUint64(value, math.Float64bits(payment.Amount))
+			value = append(value, payment.Processor...)
+
+			if err := bucket.Put(boltPaymentKey(payment.RequestedAt, payment.CorrelationID), value); err != nil {
+				return err
+			}
+			record, err := json.Marshal(payment)
+			if err != nil {
+				return err
+			}
+			if err := records.Put([]byte(payment.CorrelationID), record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Bolt) FindPayment(ctx context.Context, correlationID string) (Record, bool, error) {
+	var record Record
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltRecordsBucket).Get([]byte(correlationID))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &record)
+	})
+	return record, found, err
+}
+
+func (s *Bolt) QueryByRange(ctx context.Context, from, to time.Time) (map[string]Summary, error) {
+	summary := make(map[string]Summary)
+	if to.Before(from) {
+		return summary, nil
+	}
+
+	// Compara só o instante: entram todos os pagamentos do ms de to
+	start := boltPaymentKey(from, "")
+	end := boltPaymentKey(to, "")
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltPaymentsBucket).Cursor()
+		for k, v := cursor.Seek(start); k != nil && bytes.Compare(k[:8], end) <= 0; k, v = cursor.Next() {
+			if len(v) < 8 {
+				continue
+			}
+			processor := string(v[8:])
+			current := summary[processor]
+			current.TotalRequests++
+			current.TotalAmount += math.Float64frombits(binary.BigEndian.Uint64(v))
+			summary[processor] = current
+		}
+		return nil
+	})
+	return summary, err
+}
+
+// ExportByRange percorre o período em ordem de requestedAt, em uma transação de
+// leitura que não bloqueia as gravações.
+func (s *Bolt) ExportByRange(ctx context.Context, from, to time.Time, fn func(Record) error) error {
+	if to.Before(from) {
+		return nil
+	}
+
+	start := boltPaymentKey(from, "")
+	end := boltPaymentKey(to, "")
+	return s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltPaymentsBucket).Cursor()
+		for k, v := cursor.Seek(start); k != nil && bytes.Compare(k[:8], end) <= 0; k, v = cursor.Next() {
+			if len(v) < 8 {
+				continue
+			}
+			record := Record{
+				CorrelationID: string(k[8:]),
+				Amount:        math.Float64frombits(binary.BigEndian.Uint64(v)),
+				Processor:     string(v[8:]),
+				RequestedAt:   time.UnixMilli(int64(binary.BigEndian.Uint64(k))).UTC(),
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Bolt) Purge(ctx context.Context) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range boltBuckets {
+			if err := tx.DeleteBucket(name); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LocalSummary: o arquivo só tem os pagamentos desta instância.
+func (s *Bolt) LocalSummary(ctx context.Context, from, to time.Time) (map[string]Summary, error) {
+	if from.IsZero() && to.IsZero() {
+		return s.GetSummary(ctx)
+	}
+	return s.QueryByRange(ctx, from, to)
+}
+
+// boltPaymentKey ordena por requestedAt; instantes antes de 1970 (from ausente)
+// ficam no início.
+func boltPaymentKey(requestedAt time.Time, correlationID string) []byte {
+	key := make([]byte, 8, 8+len(correlationID))
+	binary.BigEndian.PutUint64(key, uint64(max(requestedAt.UnixMilli(), 0)))
+	return append(key, correlationID...)
+}
+
+func encodeBoltSummary(summary Summary) []byte {
+	value := make([]byte, 16)
+	binary.BigEndian.PutUint64(value, uint64(summary.TotalRequests))
+	binary.BigEndian.PutUint64(value[8:], math.Float64bits(summary.TotalAmount))
+	return value
+}
+
+func decodeBoltSummary(value []byte) Summary {
+	if len(value) < 16 {
+		return Summary{}
+	}
+	return Summary{
+		TotalRequests: int(binary.BigEndian.Uint64(value)),
+		TotalAmount:   math.Float64frombits(binary.BigEndian.Uint64(value[8:])),
+	}
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency é a moeda dos pagamentos enviados sem currency.
+const DefaultCurrency = "BRL"
+
+// CurrencySummarizer é implementado pelos storages que mantêm os contadores de
+// cada processor também por moeda, atualizados na gravação dos pagamentos.
+type CurrencySummarizer interface {
+	// GetCurrencySummary retorna, por processor, os contadores de cada moeda
+	GetCurrencySummary(ctx context.Context) (map[string]map[string]Summary, error)
+}
+
+// ErrNoCurrencies indica um storage sem contadores por moeda.
+var ErrNoCurrencies = errors.New("storage não mantém os contadores por moeda")
+
+// CurrencySummary consulta os contadores por moeda de s, se ele os mantiver.
+func CurrencySummary(ctx context.Context, s Storage) (map[string]map[string]Summary, error) {
+	if summarizer, ok := s.(CurrencySummarizer); ok {
+		return summarizer.GetCurrencySummary(ctx)
+	}
+	return nil, ErrNoCurrencies
+}
+
+func recordCurrency(r Record) string {
+	if r.Currency == "" {
+		return DefaultCurrency
+	}
+	return r.Currency
+}
+
+// currencyDeltas agrupa um lote de pagamentos por processor e moeda.
+func currencyDeltas(payments []Record) map[string]map[string]*Delta {
+	deltas := make(map[string]map[string]*Delta)
+	for _, payment := range payments {
+		byCurrency := deltas[payment.Processor]
+		if byCurrency == nil {
+			byCurrency = make(map[string]*Delta)
+			deltas[payment.Processor] = byCurrency
+		}
+		currency := recordCurrency(payment)
+		delta := byCurrency[currency]
+		if delta == nil {
+			delta = &Delta{}
+			byCurrency[currency] = delta
+		}
+		delta.Requests++
+		delta.Amount += payment.Amount
+	}
+	return deltas
+}
+
+// Contadores por moeda no Redis: a hash currencies:{rinha}:<processor> tem os
+// campos requests:<moeda> e amount:<moeda>, como as hashes de timeseries.go.
+
+func (s *Redis) currencyKey(epoch int64, processor string) string {
+	return s.epochPrefix(epoch, "currencies") + ":" + processor
+}
+
+func (s *Redis) GetCurrencySummary(ctx context.Context) (map[string]map[string]Summary, error) {
+	epoch := s.Epoch()
+	keys := make([]string, len(s.names))
+	for i, processor := range s.names {
+		keys[i] = s.currencyKey(epoch, processor)
+	}
+	hashes, err := s.client.HGetAllMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := make(map[string]map[string]Summary, len(s.names))
+	for i, processor := range s.names {
+		byCurrency := make(map[string]Summary)
+		for field, value := range hashes[i] {
+			kind, currency, ok := strings.Cut(field, ":")
+			if !ok {
+				continue
+			}
+			current := byCurrency[currency]
+			switch kind {
+			case "requests":
+				current.TotalRequests, _ = strconv.Atoi(value)
+			case "amount":
+				current.TotalAmount, _ = strconv.ParseFloat(value, 64)
+			default:
+				continue
+			}
+			byCurrency[currency] = current
+		}
+		summary[processor] = byCurrency
+	}
+	return summary, nil
+}
+
+// GetCurrencySummary agrega os pagamentos guardados, como QueryByRange.
+func (s *Memory) GetCurrencySummary(ctx context.Context) (map[string]map[string]Summary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	summary := make(map[string]map[string]Summary)
+	for processor, byCurrency := range currencyDeltas(s.payments) {
+		summary[processor] = make(map[string]Summary, len(byCurrency))
+		for currency, delta := range byCurrency {
+			summary[processor][currency] = Summary{TotalRequests: int(delta.Requests), TotalAmount: delta.Amount}
+		}
+	}
+	return summary, nil
+}
+
+// GetCurrencySummary usa os contadores do Redis enquanto ele responde; em modo
+// degradado, apenas os pagamentos registrados desde a queda.
+func (s *Degradable) GetCurrencySummary(ctx context.Context) (map[string]map[string]Summary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.remote != nil {
+		return s.remote.GetCurrencySummary(ctx)
+	}
+	return s.local.GetCurrencySummary(ctx)
+}
@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/keyspace"
+)
+
+// Degradable é o backend "redis": usa o Redis enquanto ele responde e a
+// memória durante quedas. Na reconexão, o que foi gravado em memória é somado ao
+// Redis e a memória é zerada.
+type Degradable struct {
+	mu     sync.RWMutex
+	remote *Redis // nil em modo degradado
+	local  *Memory
+	names  []string
+	// STORAGE_RECORD_TTL, repassado ao Redis a cada Promote
+	recordTTL time.Duration
+	// STORAGE_AMOUNT_BOUNDS e STORAGE_TOP_AMOUNTS, idem
+	amounts AmountOptions
+	// Relógio da aplicação, idem
+	clock clock.Clock
+	// Namespace das chaves (RUN_ID), idem
+	keys keyspace.Space
+	// Purge feito em modo degradado, a aplicar no Redis ao reconectar
+	purgePending bool
+	// Época em modo degradado: a última do Redis mais os purges desde a queda
+	epoch int64
+
+	// Último resumo lido do Redis, somado ao local em modo degradado
+	lastRemote    map[string]Summary
+	lastRemoteMux sync.Mutex
+}
+
+func NewDegradable(client redis.UniversalClient, names []string, recordTTL time.Duration, amounts AmountOptions, clk clock.Clock, keys keyspace.Space) *Degradable {
+	s := &Degradable{names: names, recordTTL: recordTTL, amounts: amounts, clock: clk, keys: keys}
+	s.local = s.newLocal()
+	if client != nil {
+		s.remote = s.newRemote(client)
+	}
+	return s
+}
+
+func (s *Degradable) newLocal() *Memory {
+	local := NewMemory()
+	local.clock = s.clock
+	return local
+}
+
+func (s *Degradable) newRemote(client redis.UniversalClient) *Redis {
+	remote := NewRedis(client, s.names)
+	remote.recordTTL = s.recordTTL
+	remote.amounts = s.amounts
+	remote.clock = s.clock
+	remote.keys = s.keys
+	return remote
+}
+
+// Promote volta a usar o Redis, somando a ele o que foi gravado em memória.
+func (s *Degradable) Promote(ctx context.Context, client redis.UniversalClient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remote := s.newRemote(client)
+	if _, err := remote.SyncEpoch(ctx); err != nil {
+		return err
+	}
+	if s.purgePending {
+		// Um purge por purge da queda, para a época do Redis chegar à que a
+		// aplicação já usa; outra instância pode tê-la passado antes
+		for remote.Epoch() < s.epoch || s.purgePending {
+			if err := remote.Purge(ctx); err != nil {
+				return err
+			}
+			s.purgePending = false
+		}
+	}
+
+	s.local.mu.Lock()
+	totals, payments := s.local.totals, s.local.payments
+	countedOnce, ttl := s.local.countedTTL > 0, s.local.countedTTL
+	s.local.mu.Unlock()
+
+	if countedOnce {
+		// Contados uma vez na queda: conferir também com os já contados no Redis
+		counted, err := remote.CountOnce(ctx, payments, ttl)
+		if err != nil {
+			return err
+		}
+		payments = counted
+	} else if len(totals) > 0 {
+		if err := remote.IncrementSummary(ctx, totals); err != nil {
+			return err
+		}
+	}
+	if len(payments) > 0 {
+		// Se falhar aqui, os contadores já foram somados: manter só os pagamentos
+		if err := remote.RecordPayments(ctx, payments); err != nil {
+			s.local = s.newLocal()
+			s.local.payments = payments
+			return err
+		}
+	}
+
+	s.local = s.newLocal()
+	s.remote = remote
+	return nil
+}
+
+// Demote passa a gravar em memória até o próximo Promote.
+func (s *Degradable) Demote() {
+	s.mu.Lock()
+	if s.remote != nil {
+		s.epoch = s.remote.Epoch()
+	}
+	s.remote = nil
+	s.mu.Unlock()
+}
+
+func (s *Degradable) Epoch() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.remote != nil {
+		return s.remote.Epoch()
+	}
+	return s.epoch
+}
+
+func (s *Degradable) SyncEpoch(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.remote != nil {
+		return s.remote.SyncEpoch(ctx)
+	}
+	return s.epoch, nil
+}
+
+func (s *Degradable) IncrementSummary(ctx context.Context, deltas map[string]*Delta) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.remote != nil {
+		return s.remote.IncrementSummary(ctx, deltas)
+	}
+	return s.local.IncrementSummary(ctx, deltas)
+}
+
+func (s *Degradable) CountOnce(ctx context.Context, payments []Record, ttl time.Duration) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.remote != nil {
+		return s.remote.CountOnce(ctx, payments, ttl)
+	}
+	return s.local.CountOnce(ctx, payments, ttl)
+}
+
+func (s *Degradable) GetSummary(ctx context.Context) (map[string]Summary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.remote != nil {
+		summary, err := s.remote.GetSummary(ctx)
+		if err == nil {
+			s.lastRemoteMux.Lock()
+			s.lastRemote = summary
+			s.lastRemoteMux.Unlock()
+		}
+		return summary, err
+	}
+
+	summary, err := s.local.GetSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lastRemoteMux.Lock()
+	defer s.lastRemoteMux.Unlock()
+	for processor, remote := range s.lastRemote {
+		local := summary[processor]
+		local.TotalRequests += remote.TotalRequests
+		local.TotalAmount += remote.TotalAmount
+		summary[processor] = local
+	}
+	return summary, nil
+}
+
+func (s *Degradable) RecordPayment(ctx context.Context, payment Record) error {
+	return s.RecordPayments(ctx, []Record{payment})
+}
+
+func (s *Degradable) RecordPayments(ctx context.Context, payments []Record) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.remote != nil {
+		return s.remote.RecordPayments(ctx, payments)
+	}
+	for _, payment := range payments {
+		s.local.RecordPayment(ctx, payment)
+	}
+	return nil
+}
+
+func (s *Degradable) QueryByRange(ctx context.Context, from, to time.Time) (map[string]Summary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.remote != nil {
+		return s.remote.QueryByRange(ctx, from, to)
+	}
+	// Em modo degradado, apenas os pagamentos registrados desde a queda
+	return s.local.QueryByRange(ctx, from, to)
+}
+
+func (s *Degradable) FindPayment(ctx context.Context, correlationID string) (Record, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.remote != nil {
+		return s.remote.FindPayment(ctx, correlationID)
+	}
+	// Em modo degradado, apenas os pagamentos registrados desde a queda
+	return s.local.FindPayment(ctx, correlationID)
+}
+
+// ExportByRange não segura o lock durante a exportação: um Promote esperando
+// bloquearia todas as gravações até ela terminar.
+func (s *Degradable) ExportByRange(ctx context.Context, from, to time.Time, fn func(Record) error) error {
+	s.mu.RLock()
+	remote, local := s.remote, s.local
+	s.mu.RUnlock()
+
+	if remote != nil {
+		return remote.ExportByRange(ctx, from, to, fn)
+	}
+	// Em modo degradado, apenas os pagamentos registrados desde a queda
+	return local.ExportByRange(ctx, from, to, fn)
+}
+
+func (s *Degradable) Purge(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastRemoteMux.Lock()
+	s.lastRemote = nil
+	s.lastRemoteMux.Unlock()
+
+	if s.remote != nil {
+		if err := s.remote.Purge(ctx); err != nil {
+			return err
+		}
+		return s.local.Purge(ctx)
+	}
+
+	s.purgePending = true
+	s.epoch++
+	return s.local.Purge(ctx)
+}
+
+// LocalSummary retorna apenas o gravado em memória desde a queda, sem o último
+// resumo do Redis, que as outras instâncias também conhecem.
+func (s *Degradable) LocalSummary(ctx context.Context, from, to time.Time) (map[string]Summary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.local.LocalSummary(ctx, from, to)
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Épocas do estado no Redis: todas as chaves do storage levam a época no nome, e
+// o purge só incrementa epoch:{rinha}. Leituras e escritas passam na hora para as
+// chaves novas, vazias; uma escrita atrasada (um flush em andamento, outra
+// instância que ainda não viu o purge) cai nas chaves da época anterior, que
+// ninguém mais lê. As épocas aposentadas ficam no ZSET epochs:{rinha}, com o
+// momento do purge, e as chaves delas são apagadas no purge e de novo depois de
+// epochGrace, para levar também o que chegou atrasado.
+
+// Versioned é implementado pelos storages com o estado versionado por época.
+type Versioned interface {
+	// Epoch retorna a época conhecida por esta instância
+	Epoch() int64
+	// SyncEpoch lê a época atual, que outra instância pode ter avançado, e apaga
+	// as chaves das épocas aposentadas há mais de epochGrace
+	SyncEpoch(ctx context.Context) (int64, error)
+}
+
+// Tempo que as chaves de uma época aposentada esperam pelas escritas atrasadas
+// antes da limpeza final
+const epochGrace = time.Minute
+
+// Avança a época e aposenta a anterior. KEYS[1] é a época e KEYS[2] o ZSET das
+// aposentadas; ARGV[1] é o momento do purge em ms. Retorna a nova época.
+var bumpEpochScript = redis.NewScript(`
+local previous = tonumber(redis.call("GET", KEYS[1]) or "0")
+local epoch = redis.call("INCR", KEYS[1])
+redis.call("ZADD", KEYS[2], ARGV[1], previous)
+return epoch
+`)
+
+// Lê a época atual seguida das aposentadas até ARGV[1] (ms), com as chaves de
+// bumpEpochScript.
+var readEpochScript = redis.NewScript(`
+local result = {tonumber(redis.call("GET", KEYS[1]) or "0")}
+for _, epoch in ipairs(redis.call("ZRANGEBYSCORE", KEYS[2], "-inf", ARGV[1])) do
+	result[#result + 1] = tonumber(epoch)
+end
+return result
+`)
+
+// Tira do ZSET KEYS[1] as épocas em ARGV, já limpas.
+var forgetEpochsScript = redis.NewScript(`
+return redis.call("ZREM", KEYS[1], unpack(ARGV))
+`)
+
+func (s *Redis) epochKey() string {
+	return s.keys.Key("epoch:" + keyTag)
+}
+
+func (s *Redis) retiredEpochsKey() string {
+	return s.keys.Key("epochs:" + keyTag)
+}
+
+// epochPrefix é o início das chaves de kind na época: summary:{rinha} na época 0,
+// a das chaves anteriores ao versionamento, e summary:3:{rinha} na época 3.
+func (s *Redis) epochPrefix(epoch int64, kind string) string {
+	if epoch == 0 {
+		return s.keys.Key(kind + ":" + keyTag)
+	}
+	return s.keys.Key(kind + ":" + strconv.FormatInt(epoch, 10) + ":" + keyTag)
+}
+
+type epochContextKey struct{}
+
+// WithEpoch marca as escritas feitas com ctx como da época em que os dados foram
+// acumulados: se o storage já passou dela, elas vão para as chaves dessa época e
+// não aparecem nas leituras.
+func WithEpoch(ctx context.Context, epoch int64) context.Context {
+	return context.WithValue(ctx, epochContextKey{}, epoch)
+}
+
+// epochCounter guarda a época conhecida pela instância.
+type epochCounter struct {
+	current atomic.Int64
+}
+
+func (e *epochCounter) Epoch() int64 {
+	return e.current.Load()
+}
+
+// writeEpoch é a época das chaves de uma escrita: a de WithEpoch, se anterior à
+// atual, ou a atual.
+func (e *epochCounter) writeEpoch(ctx context.Context) int64 {
+	current := e.current.Load()
+	if epoch, ok := ctx.Value(epochContextKey{}).(int64); ok && epoch < current {
+		return epoch
+	}
+	return current
+}
+
+func (s *Redis) epochKeys() []string {
+	return []string{s.epochKey(), s.retiredEpochsKey()}
+}
+
+func (s *Redis) SyncEpoch(ctx context.Context) (int64, error) {
+	cutoff := s.clock.Now().Add(-epochGrace).UnixMilli()
+	values, err := s.client.Eval(ctx, readEpochScript, s.epochKeys(), cutoff).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+	epoch := values[0]
+	s.current.Store(epoch)
+
+	retired := values[1:]
+	if len(retired) == 0 {
+		return epoch, nil
+	}
+	args := make([]interface{}, len(retired))
+	for i, old := range retired {
+		// O Redis pode ter sido zerado depois da aposentadoria
+		if old != epoch {
+			if err := s.purgeEpoch(ctx, old); err != nil {
+				return epoch, err
+			}
+		}
+		args[i] = old
+	}
+	return epoch, s.client.Eval(ctx, forgetEpochsScript, []string{s.retiredEpochsKey()}, args...).Err()
+}
+
+// Purge avança a época, o que esvazia o storage para todas as instâncias de uma
+// vez, e apaga as chaves da época anterior.
+func (s *Redis) Purge(ctx context.Context) error {
+	epoch, err := s.client.Eval(ctx, bumpEpochScript, s.epochKeys(), s.clock.Now().UnixMilli()).Int64()
+	if err != nil {
+		return err
+	}
+	s.current.Store(epoch)
+	return s.purgeEpoch(ctx, epoch-1)
+}
+
+// purgeEpoch apaga as chaves de uma época.
+func (s *Redis) purgeEpoch(ctx context.Context, epoch int64) error {
+	keys := make([]string, 0, 3+3*len(s.names))
+	keys = append(keys, s.countedKey(epoch), s.recordsKey(epoch), s.topAmountsKey(epoch))
+	for _, processor := range s.names {
+		keys = append(keys, s.summaryKey(epoch, processor), s.paymentsKey(epoch, processor), s.currencyKey(epoch, processor))
+	}
+	if err := s.purgeBuckets(ctx, epoch); err != nil {
+		return err
+	}
+	return s.client.Del(ctx, keys...)
+}
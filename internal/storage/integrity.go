@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IntegrityChecker é implementado pelos storages que reconferem os contadores
+// com os pagamentos registrados. No Redis, a conferência inclui a soma de
+// conferência: requisições e valor em centavos somados junto dos contadores, no
+// mesmo script, em inteiros, sem o arredondamento acumulado do totalAmount. Nos
+// demais, os pagamentos vêm da exportação.
+type IntegrityChecker interface {
+	CheckIntegrity(ctx context.Context) (map[string]Integrity, error)
+}
+
+// ErrNoIntegrityCheck indica um storage sem exportação para recontar os pagamentos.
+var ErrNoIntegrityCheck = errors.New("storage não permite recontar os pagamentos registrados")
+
+// Integrity são os contadores de um processor e a recontagem dos pagamentos
+// registrados dele. Não é uma leitura atômica: pagamentos em gravação podem
+// aparecer de um lado só.
+type Integrity struct {
+	Counters Summary
+	// Ausente nos storages sem ela
+	Checksum *Tally
+	// Recontagem dos pagamentos registrados, qualquer requestedAt
+	Recorded Tally
+}
+
+// Tally conta os pagamentos e soma o valor em centavos.
+type Tally struct {
+	Requests int64
+	Cents    int64
+}
+
+// Limites da recontagem: do início de 1970 (o menor que o bolt aceita) ao maior
+// ano que o postgres guarda sem erro
+var (
+	integrityFrom = time.UnixMilli(0)
+	integrityTo   = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// amountCents converte um valor em reais para centavos, como na soma de conferência.
+func amountCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// CheckIntegrity reconfere os contadores de cada processor com os pagamentos
+// registrados.
+func CheckIntegrity(ctx context.Context, s Storage) (map[string]Integrity, error) {
+	if checker, ok := s.(IntegrityChecker); ok {
+		return checker.CheckIntegrity(ctx)
+	}
+	if exporter, ok := s.(Exporter); ok {
+		counters, err := s.GetSummary(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return integrityByExport(ctx, exporter, counters, nil)
+	}
+	return nil, ErrNoIntegrityCheck
+}
+
+func integrityByExport(ctx context.Context, exporter Exporter, counters map[string]Summary, checksums map[string]Tally) (map[string]Integrity, error) {
+	recorded := make(map[string]Tally, len(counters))
+	err := exporter.ExportByRange(ctx, integrityFrom, integrityTo, func(record Record) error {
+		tally := recorded[record.Processor]
+		tally.Requests++
+		tally.Cents += amountCents(record.Amount)
+		recorded[record.Processor] = tally
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	integrity := make(map[string]Integrity, len(counters))
+	for processor, summary := range counters {
+		entry := Integrity{Counters: summary, Recorded: recorded[processor]}
+		if checksum, ok := checksums[processor]; ok {
+			entry.Checksum = &checksum
+		}
+		integrity[processor] = entry
+	}
+	return integrity, nil
+}
+
+// Lê os contadores e a soma de conferência de todos os processors em uma única
+// operação atômica
+var readIntegrityScript = redis.NewScript(`
+local result = {}
+for i, key in ipairs(KEYS) do
+	local values = redis.call("HMGET", key, "totalRequests", "totalAmount", "checkRequests", "checkCents")
+	for j = 1, 4 do
+		result[#result + 1] = values[j] or false
+	end
+end
+return result
+`)
+
+// CheckIntegrity no Redis compara as hashes do resumo com os ZSETs dos
+// pagamentos da época atual. Contadores gravados antes da soma de conferência
+// existir aparecem nela como diferença.
+func (s *Redis) CheckIntegrity(ctx context.Context) (map[string]Integrity, error) {
+	epoch := s.Epoch()
+	keys := make([]string, len(s.names))
+	for i, processor := range s.names {
+		keys[i] = s.summaryKey(epoch, processor)
+	}
+
+	values, err := s.client.Eval(ctx, readIntegrityScript, keys).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make(map[string]Summary, len(s.names))
+	checksums := make(map[string]Tally, len(s.names))
+	for i, processor := range s.names {
+		counters[processor] = parseProcessorSummary(values[4*i], values[4*i+1])
+		checksums[processor] = Tally{Requests: parseCount(values[4*i+2]), Cents: parseCount(values[4*i+3])}
+	}
+	return integrityByExport(ctx, s, counters, checksums)
+}
+
+// parseCount lê um campo inteiro de um script; 0 quando ausente.
+func parseCount(value interface{}) int64 {
+	str, _ := value.(string)
+	n, _ := strconv.ParseInt(str, 10, 64)
+	return n
+}
+
+func (s *Degradable) CheckIntegrity(ctx context.Context) (map[string]Integrity, error) {
+	s.mu.RLock()
+	remote, local := s.remote, s.local
+	s.mu.RUnlock()
+
+	if remote != nil {
+		return remote.CheckIntegrity(ctx)
+	}
+	counters, err := local.GetSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return integrityByExport(ctx, local, counters, nil)
+}
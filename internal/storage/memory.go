@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+)
+
+// Memory mantém tudo no processo (não persistente, não compartilhado).
+type Memory struct {
+	mu       sync.RWMutex
+	totals   map[string]*Delta
+	payments []Record
+	// correlationIds contados por CountOnce, com o fim da marca
+	counted    map[string]time.Time
+	countedTTL time.Duration
+	// Próxima varredura das marcas vencidas, um TTL depois da anterior
+	nextSweep time.Time
+	// Relógio da expiração das marcas de CountOnce
+	clock clock.Clock
+}
+
+func NewMemory() *Memory {
+	return &Memory{totals: make(map[string]*Delta), clock: clock.Real()}
+}
+
+// SetClock troca o relógio das marcas de CountOnce, como o de um clock.Fake nos
+// testes.
+func (s *Memory) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+func (s *Memory) IncrementSummary(ctx context.Context, deltas map[string]*Delta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for processor, delta := range deltas {
+		current := s.totals[processor]
+		if current == nil {
+			current = &Delta{}
+			s.totals[processor] = current
+		}
+		current.Requests += delta.Requests
+		current.Amount += delta.Amount
+	}
+	return nil
+}
+
+func (s *Memory) CountOnce(ctx context.Context, payments []Record, ttl time.Duration) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.counted == nil {
+		s.counted = make(map[string]time.Time)
+	}
+	s.countedTTL = ttl
+	now := s.clock.Now()
+	if !now.Before(s.nextSweep) {
+		// Marcas vencidas já não barram nada; sem a varredura, o mapa só cresce
+		for id, expiry := range s.counted {
+			if !expiry.After(now) {
+				delete(s.counted, id)
+			}
+		}
+		s.nextSweep = now.Add(ttl)
+	}
+	var counted []Record
+	for _, payment := range payments {
+		if expiry, ok := s.counted[payment.CorrelationID]; ok && expiry.After(now) {
+			continue
+		}
+		s.counted[payment.CorrelationID] = now.Add(ttl)
+		current := s.totals[payment.Processor]
+		if current == nil {
+			current = &Delta{}
+			s.totals[payment.Processor] = current
+		}
+		current.Requests++
+		current.Amount += payment.Amount
+		counted = append(counted, payment)
+	}
+	return counted, nil
+}
+
+func (s *Memory) GetSummary(ctx context.Context) (map[string]Summary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	summary := make(map[string]Summary, len(s.totals))
+	for processor, delta := range s.totals {
+		summary[processor] = Summary{
+			TotalRequests: int(delta.Requests),
+			TotalAmount:   delta.Amount,
+		}
+	}
+	return summary, nil
+}
+
+func (s *Memory) RecordPayment(ctx context.Context, payment Record) error {
+	s.mu.Lock()
+	s.payments = append(s.payments, payment)
+	s.mu.Unlock()
+	return nil
+}
+
+// FindPayment procura do registro mais recente para o mais antigo.
+func (s *Memory) FindPayment(ctx context.Context, correlationID string) (Record, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.payments) - 1; i >= 0; i-- {
+		if s.payments[i].CorrelationID == correlationID {
+			return s.payments[i], true, nil
+		}
+	}
+	return Record{}, false, nil
+}
+
+func (s *Memory) QueryByRange(ctx context.Context, from, to time.Time) (map[string]Summary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	summary := make(map[string]Summary)
+	for _, payment := range s.payments {
+		if payment.RequestedAt.Before(from) || payment.RequestedAt.After(to) {
+			continue
+		}
+		current := summary[payment.Processor]
+		current.TotalRequests++
+		current.TotalAmount += payment.Amount
+		summary[payment.Processor] = current
+	}
+	return summary, nil
+}
+
+// ExportByRange percorre uma cópia dos registros, para não segurar o lock
+// enquanto fn escreve na resposta.
+func (s *Memory) ExportByRange(ctx context.Context, from, to time.Time, fn func(Record) error) error {
+	s.mu.RLock()
+	payments := append([]Record(nil), s.payments...)
+	s.mu.RUnlock()
+
+	for _, payment := range payments {
+		if payment.RequestedAt.Before(from) || payment.RequestedAt.After(to) {
+			continue
+		}
+		if err := fn(payment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Memory) Purge(ctx context.Context) error {
+	s.mu.Lock()
+	s.totals = make(map[string]*Delta)
+	s.payments = nil
+	s.counted = nil
+	s.nextSweep = time.Time{}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Memory) LocalSummary(ctx context.Context, from, to time.Time) (map[string]Summary, error) {
+	if from.IsZero() && to.IsZero() {
+		return s.GetSummary(ctx)
+	}
+	return s.QueryByRange(ctx, from, to)
+}
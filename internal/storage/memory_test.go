@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"rinha-backend-2025/internal/clock"
+)
+
+// TestMemoryCountOnceExpiresOnClock confere que a marca de um correlationId já
+// contado vale até o relógio do storage passar do TTL.
+func TestMemoryCountOnceExpiresOnClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC))
+	s := NewMemory()
+	s.SetClock(fake)
+	ctx := context.Background()
+	payment := testRecord(1, "default", 19.9, fake.Now())
+
+	if counted, err := s.CountOnce(ctx, []Record{payment}, time.Minute); err != nil || len(counted) != 1 {
+		t.Fatalf("primeira contagem: %d contados, %v", len(counted), err)
+	}
+	fake.Advance(time.Minute - time.Millisecond)
+	if counted, err := s.CountOnce(ctx, []Record{payment}, time.Minute); err != nil || len(counted) != 0 {
+		t.Fatalf("dentro do TTL: %d contados, %v; esperado 0", len(counted), err)
+	}
+	fake.Advance(time.Millisecond)
+	if counted, err := s.CountOnce(ctx, []Record{payment}, time.Minute); err != nil || len(counted) != 1 {
+		t.Errorf("depois do TTL: %d contados, %v; esperado 1", len(counted), err)
+	}
+}
+
+// TestMemoryCountOnceEvictsExpired confere que as marcas vencidas saem do mapa
+// numa chamada seguinte, mesmo que o correlationId não volte.
+func TestMemoryCountOnceEvictsExpired(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC))
+	s := NewMemory()
+	s.SetClock(fake)
+	ctx := context.Background()
+
+	if _, err := s.CountOnce(ctx, []Record{testRecord(1, "default", 19.9, fake.Now())}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(time.Minute)
+	if _, err := s.CountOnce(ctx, []Record{testRecord(2, "default", 19.9, fake.Now())}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.counted[testRecord(1, "default", 19.9, time.Time{}).CorrelationID]; ok {
+		t.Error("marca vencida continua no mapa")
+	}
+	if len(s.counted) != 1 {
+		t.Errorf("%d marcas, esperado só a do pagamento novo", len(s.counted))
+	}
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Postgres usa as tabelas de sql/init.sql: payments para os registros, com o
+// metadata em JSONB, e payment_summary para os contadores.
+type Postgres struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgres(ctx context.Context, dsn string) (*Postgres, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao configurar Postgres: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("erro ao conectar ao Postgres: %w", err)
+	}
+	return &Postgres{pool: pool}, nil
+}
+
+func (s *Postgres) IncrementSummary(ctx context.Context, deltas map[string]*Delta) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for processor, delta := range deltas {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO payment_summary (processor, total_requests, total_amount)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (processor) DO UPDATE SET
+				total_requests = payment_summary.total_requests + EXCLUDED.total_requests,
+				total_amount = payment_summary.total_amount + EXCLUDED.total_amount`,
+			processor, delta.Requests, delta.Amount)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Postgres) GetSummary(ctx context.Context) (map[string]Summary, error) {
+	rows, err := s.pool.Query(ctx, `SELECT processor, total_requests, total_amount::float8 FROM payment_summary`)
+	if err != nil {
+		return nil, err
+	}
+	return scanSummaryRows(rows)
+}
+
+func (s *Postgres) RecordPayment(ctx context.Context, payment Record) error {
+	// JSONB nulo sem metadata
+	var metadata any
+	if len(payment.Metadata) > 0 {
+		metadata = string(payment.Metadata)
+	}
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO payments (correlationId, amount, processor, requested_at, metadata)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (correlationId) DO NOTHING`,
+		payment.CorrelationID, payment.Amount, payment.Processor, payment.RequestedAt.UTC(), metadata)
+	return err
+}
+
+func (s *Postgres) FindPayment(ctx context.Context, correlationID string) (Record, bool, error) {
+	var record Record
+	var metadata []byte
+	err := s.pool.QueryRow(ctx, `
+		SELECT correlationId::text, amount::float8, processor, requested_at, metadata::text
+		FROM payments
+		WHERE correlationId = $1`,
+		correlationID).Scan(&record.CorrelationID, &record.Amount, &record.Processor, &record.RequestedAt, &metadata)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Record{}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, err
+	}
+	record.Metadata = metadata
+	return record, true, nil
+}
+
+func (s *Postgres) QueryByRange(ctx context.Context, from, to time.Time) (map[string]Summary, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT processor, COUNT(*), COALESCE(SUM(amount), 0)::float8
+		FROM payments
+		WHERE requested_at BETWEEN $1 AND $2
+		GROUP BY processor`,
+		from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanSummaryRows(rows)
+}
+
+func (s *Postgres) ExportByRange(ctx context.Context, from, to time.Time, fn func(Record) error) error {
+	rows, err := s.pool.Query(ctx, `
+		SELECT correlationId::text, amount::float8, processor, requested_at
+		FROM payments
+		WHERE requested_at BETWEEN $1 AND $2
+		ORDER BY requested_at`,
+		from.UTC(), to.UTC())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record Record
+		if err := rows.Scan(&record.CorrelationID, &record.Amount, &record.Processor, &record.RequestedAt); err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *Postgres) Purge(ctx context.Context) error {
+	_, err := s.pool.Exec(ctx, `TRUNCATE payments, payment_summary`)
+	return err
+}
+
+type summaryRows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+	Close()
+}
+
+func scanSummaryRows(rows summaryRows) (map[string]Summary, error) {
+	defer rows.Close()
+
+	summary := make(map[string]Summary)
+	for rows.Next() {
+		var processor string
+		var current Summary
+		if err := rows.Scan(&processor, &current.TotalRequests, &current.TotalAmount); err != nil {
+			return nil, err
+		}
+		summary[processor] = current
+	}
+	return summary, rows.Err()
+}
@@ -0,0 +1,382 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/ids"
+	"rinha-backend-2025/internal/keyspace"
+)
+
+// Incrementa as métricas de vários processors de forma atômica.
+// KEYS[i] recebe ARGV[3i-2] requisições, ARGV[3i-1] de valor e ARGV[3i] de
+// valor em centavos, este na soma de conferência (ver integrity.go).
+var incrementSummaryScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	redis.call("HINCRBY", key, "totalRequests", ARGV[3 * i - 2])
+	redis.call("HINCRBYFLOAT", key, "totalAmount", ARGV[3 * i - 1])
+	redis.call("HINCRBY", key, "checkRequests", ARGV[3 * i - 2])
+	redis.call("HINCRBY", key, "checkCents", ARGV[3 * i])
+end
+return 1
+`)
+
+// Lê o resumo de todos os processors em uma única operação atômica
+var readSummaryScript = redis.NewScript(`
+local result = {}
+for i, key in ipairs(KEYS) do
+	local values = redis.call("HMGET", key, "totalRequests", "totalAmount")
+	result[#result + 1] = values[1] or false
+	result[#result + 1] = values[2] or false
+end
+return result
+`)
+
+// Conta uma única vez cada correlationId. KEYS[1] é o SET dos já contados e
+// KEYS[2..] as hashes do resumo; ARGV[1] é o TTL do SET em segundos, seguido de
+// quartetos correlationId, índice em KEYS, valor e valor em centavos. Retorna as
+// posições (a partir de 1) dos pagamentos contados agora.
+var countOnceScript = redis.NewScript(`
+local counted = {}
+local position = 0
+for i = 2, #ARGV, 4 do
+	position = position + 1
+	if redis.call("SADD", KEYS[1], ARGV[i]) == 1 then
+		local key = KEYS[tonumber(ARGV[i + 1])]
+		redis.call("HINCRBY", key, "totalRequests", 1)
+		redis.call("HINCRBYFLOAT", key, "totalAmount", ARGV[i + 2])
+		redis.call("HINCRBY", key, "checkRequests", 1)
+		redis.call("HINCRBY", key, "checkCents", ARGV[i + 3])
+		counted[#counted + 1] = position
+	end
+end
+redis.call("EXPIRE", KEYS[1], ARGV[1])
+return counted
+`)
+
+// Redis guarda contadores em hashes summary:{rinha}:<processor>, com a soma de
+// conferência de integrity.go nos campos checkRequests e checkCents, e pagamentos em
+// ZSETs payments:{rinha}:<processor> (score = requestedAt em ms, membro =
+// correlationId:amount), além dos totais por segundo de timeseries.go e por moeda
+// de currencies.go. A hash records:{rinha} guarda cada registro em JSON, com a
+// moeda e o metadata, para a consulta por correlationId. Na contagem exatamente
+// uma vez, o SET counted:{rinha} guarda os correlationIds já somados. Nos membros
+// e campos, o correlationId vai nos 16 bytes de ids.Encode, menos da metade do
+// texto. A hash tag mantém todas as chaves no mesmo slot do Cluster, como exigem
+// os scripts e o DEL do purge. Depois do primeiro purge, os nomes levam a época
+// (ver epoch.go). Os comandos passam pelo RedisStore (ver redis_store.go).
+type Redis struct {
+	client RedisStore
+	// Processors configurados: o resumo e o purge cobrem cada um deles
+	names []string
+	// Expiração das chaves dos pagamentos, renovada a cada gravação; 0 não expira
+	recordTTL time.Duration
+	// Histograma e maiores pagamentos mantidos na gravação (amounts.go)
+	amounts AmountOptions
+	// Relógio da carência das épocas aposentadas
+	clock clock.Clock
+	// Namespace das chaves (RUN_ID)
+	keys keyspace.Space
+	epochCounter
+}
+
+func NewRedis(client redis.UniversalClient, names []string) *Redis {
+	return NewRedisFrom(NewRedisStore(client), names)
+}
+
+// NewRedisFrom monta o storage sobre qualquer RedisStore, como o FakeRedis.
+func NewRedisFrom(store RedisStore, names []string) *Redis {
+	return &Redis{client: store, names: names, clock: clock.Real()}
+}
+
+// SetClock troca o relógio das épocas, como o de um clock.Fake nos testes.
+func (s *Redis) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// SetKeys põe as chaves no namespace da execução (RUN_ID).
+func (s *Redis) SetKeys(keys keyspace.Space) {
+	s.keys = keys
+}
+
+const keyTag = "{rinha}"
+
+func (s *Redis) summaryKey(epoch int64, processor string) string {
+	return s.epochPrefix(epoch, "summary") + ":" + processor
+}
+
+func (s *Redis) paymentsKey(epoch int64, processor string) string {
+	return s.epochPrefix(epoch, "payments") + ":" + processor
+}
+
+func (s *Redis) countedKey(epoch int64) string {
+	return s.epochPrefix(epoch, "counted")
+}
+
+func (s *Redis) recordsKey(epoch int64) string {
+	return s.epochPrefix(epoch, "records")
+}
+
+func (s *Redis) IncrementSummary(ctx context.Context, deltas map[string]*Delta) error {
+	epoch := s.writeEpoch(ctx)
+	keys := make([]string, 0, len(deltas))
+	args := make([]interface{}, 0, 3*len(deltas))
+	for processor, delta := range deltas {
+		keys = append(keys, s.summaryKey(epoch, processor))
+		args = append(args, delta.Requests, strconv.FormatFloat(delta.Amount, 'f', -1, 64), amountCents(delta.Amount))
+	}
+
+	return s.client.Eval(ctx, incrementSummaryScript, keys, args...).Err()
+}
+
+func (s *Redis) CountOnce(ctx context.Context, payments []Record, ttl time.Duration) ([]Record, error) {
+	if len(payments) == 0 {
+		return nil, nil
+	}
+	epoch := s.writeEpoch(ctx)
+	keys := []string{s.countedKey(epoch)}
+	index := make(map[string]int, len(s.names))
+	args := make([]interface{}, 0, 1+4*len(payments))
+	args = append(args, max(int64(ttl.Seconds()), 1))
+	for _, payment := range payments {
+		i, ok := index[payment.Processor]
+		if !ok {
+			keys = append(keys, s.summaryKey(epoch, payment.Processor))
+			i = len(keys)
+			index[payment.Processor] = i
+		}
+		args = append(args, ids.Encode(payment.CorrelationID), i, strconv.FormatFloat(payment.Amount, 'f', -1, 64), amountCents(payment.Amount))
+	}
+
+	positions, err := s.client.Eval(ctx, countOnceScript, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	counted := make([]Record, len(positions))
+	for i, position := range positions {
+		counted[i] = payments[position-1]
+	}
+	return counted, nil
+}
+
+func (s *Redis) GetSummary(ctx context.Context) (map[string]Summary, error) {
+	epoch := s.Epoch()
+	keys := make([]string, len(s.names))
+	for i, processor := range s.names {
+		keys[i] = s.summaryKey(epoch, processor)
+	}
+
+	values, err := s.client.Eval(ctx, readSummaryScript, keys).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	summary := make(map[string]Summary, len(s.names))
+	for i, processor := range s.names {
+		summary[processor] = parseProcessorSummary(values[2*i], values[2*i+1])
+	}
+	return summary, nil
+}
+
+func (s *Redis) RecordPayment(ctx context.Context, payment Record) error {
+	return s.RecordPayments(ctx, []Record{payment})
+}
+
+// RecordPayments grava o lote inteiro, com os totais por segundo e por moeda, em
+// um único pipeline.
+func (s *Redis) RecordPayments(ctx context.Context, payments []Record) error {
+	epoch := s.writeEpoch(ctx)
+	pipe := s.client.Pipeline()
+	// Com STORAGE_RECORD_TTL, cada chave escrita tem a expiração renovada no
+	// mesmo pipeline
+	var touched map[string]struct{}
+	if s.recordTTL > 0 {
+		touched = make(map[string]struct{})
+	}
+	touch := func(key string) {
+		if touched != nil {
+			touched[key] = struct{}{}
+		}
+	}
+	for _, payment := range payments {
+		key := s.paymentsKey(epoch, payment.Processor)
+		pipe.ZAdd(key, paymentMember(payment))
+		touch(key)
+		record, err := json.Marshal(payment)
+		if err != nil {
+			return err
+		}
+		pipe.HSet(s.recordsKey(epoch), ids.Encode(payment.CorrelationID), record)
+		touch(s.recordsKey(epoch))
+	}
+	for second, byProcessor := range bucketDeltas(payments) {
+		member := strconv.FormatInt(second, 10)
+		key := s.bucketKey(epoch, member)
+		for processor, delta := range byProcessor {
+			pipe.HIncrBy(key, "requests:"+processor, delta.Requests)
+			pipe.HIncrByFloat(key, "amount:"+processor, delta.Amount)
+		}
+		touch(key)
+		pipe.ZAdd(s.bucketIndexKey(epoch), redis.Z{Score: float64(second), Member: member})
+		touch(s.bucketIndexKey(epoch))
+	}
+	for _, key := range s.addAmountStats(ctx, pipe, epoch, payments) {
+		touch(key)
+	}
+	for processor, byCurrency := range currencyDeltas(payments) {
+		key := s.currencyKey(epoch, processor)
+		for currency, delta := range byCurrency {
+			pipe.HIncrBy(key, "requests:"+currency, delta.Requests)
+			pipe.HIncrByFloat(key, "amount:"+currency, delta.Amount)
+		}
+		touch(key)
+	}
+	for key := range touched {
+		pipe.Expire(key, s.recordTTL)
+	}
+	return pipe.Exec(ctx)
+}
+
+func (s *Redis) FindPayment(ctx context.Context, correlationID string) (Record, bool, error) {
+	key, field := s.recordsKey(s.Epoch()), ids.Encode(correlationID)
+	data, err := s.client.HGet(ctx, key, field)
+	if err == redis.Nil && field != correlationID {
+		// Gravado como texto, antes dos correlationIds binários
+		data, err = s.client.HGet(ctx, key, correlationID)
+	}
+	if err == redis.Nil {
+		return Record{}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, err
+	}
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return Record{}, false, fmt.Errorf("registro inválido de %s: %w", correlationID, err)
+	}
+	return record, true, nil
+}
+
+func paymentMember(payment Record) redis.Z {
+	return redis.Z{
+		Score:  float64(payment.RequestedAt.UnixMilli()),
+		Member: ids.Encode(payment.CorrelationID) + ":" + strconv.FormatFloat(payment.Amount, 'f', -1, 64),
+	}
+}
+
+func (s *Redis) QueryByRange(ctx context.Context, from, to time.Time) (map[string]Summary, error) {
+	rangeBy := &redis.ZRangeBy{
+		Min: strconv.FormatInt(from.UnixMilli(), 10),
+		Max: strconv.FormatInt(to.UnixMilli(), 10),
+	}
+
+	epoch := s.Epoch()
+	keys := make([]string, len(s.names))
+	for i, processor := range s.names {
+		keys[i] = s.paymentsKey(epoch, processor)
+	}
+	members, err := s.client.ZRangeByScoreMany(ctx, keys, rangeBy)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := make(map[string]Summary, len(s.names))
+	for i, processor := range s.names {
+		current := Summary{}
+		for _, member := range members[i] {
+			sep := strings.LastIndexByte(member, ':')
+			if sep < 0 {
+				continue
+			}
+			amount, err := strconv.ParseFloat(member[sep+1:], 64)
+			if err != nil {
+				continue
+			}
+			current.TotalRequests++
+			current.TotalAmount += amount
+		}
+		summary[processor] = current
+	}
+	return summary, nil
+}
+
+// Pagamentos lidos por vez de cada ZSET na exportação
+const exportPage = 1000
+
+// ExportByRange percorre os ZSETs em páginas de exportPage, um processor por vez.
+func (s *Redis) ExportByRange(ctx context.Context, from, to time.Time, fn func(Record) error) error {
+	epoch := s.Epoch()
+	for _, processor := range s.names {
+		for offset := int64(0); ; offset += exportPage {
+			members, err := s.client.ZRangeByScoreWithScores(ctx, s.paymentsKey(epoch, processor), &redis.ZRangeBy{
+				Min:    strconv.FormatInt(from.UnixMilli(), 10),
+				Max:    strconv.FormatInt(to.UnixMilli(), 10),
+				Offset: offset,
+				Count:  exportPage,
+			})
+			if err != nil {
+				return err
+			}
+			for _, z := range members {
+				record, ok := parsePaymentMember(processor, z)
+				if !ok {
+					continue
+				}
+				if err := fn(record); err != nil {
+					return err
+				}
+			}
+			if len(members) < exportPage {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func parsePaymentMember(processor string, z redis.Z) (Record, bool) {
+	member, _ := z.Member.(string)
+	sep := strings.LastIndexByte(member, ':')
+	if sep < 0 {
+		return Record{}, false
+	}
+	amount, err := strconv.ParseFloat(member[sep+1:], 64)
+	if err != nil {
+		return Record{}, false
+	}
+	return Record{
+		CorrelationID: ids.Decode(member[:sep]),
+		Amount:        amount,
+		Processor:     processor,
+		RequestedAt:   time.UnixMilli(int64(z.Score)).UTC(),
+	}, true
+}
+
+func parseProcessorSummary(totalRequestsVal, totalAmountVal interface{}) Summary {
+	totalRequests := 0
+	totalAmount := 0.0
+
+	if totalRequestsStr, ok := totalRequestsVal.(string); ok {
+		if val, err := strconv.Atoi(totalRequestsStr); err == nil {
+			totalRequests = val
+		}
+	}
+
+	if totalAmountStr, ok := totalAmountVal.(string); ok {
+		if val, err := strconv.ParseFloat(totalAmountStr, 64); err == nil {
+			totalAmount = val
+		}
+	}
+
+	return Summary{
+		TotalRequests: totalRequests,
+		TotalAmount:   totalAmount,
+	}
+}
@@ -0,0 +1,614 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FakeRedis é um RedisStore em memória com a semântica do Redis nos comandos do
+// storage: strings, hashes, ZSETs ordenados por score e membro, intervalos com "(" e
+// ±inf, o Nil dos campos ausentes e o DEL de qualquer tipo de chave. Os scripts
+// Lua não rodam: cada script do storage tem um equivalente em Go, executado sob
+// o mesmo lock, o que preserva a atomicidade. Serve para testes; NewRedisFrom
+// monta o storage sobre ele.
+type FakeRedis struct {
+	mu      sync.Mutex
+	values  map[string]string
+	hashes  map[string]map[string]string
+	zsets   map[string]map[string]float64
+	sets    map[string]map[string]struct{}
+	expires map[string]time.Time
+	// Relógio das expirações, trocável nos testes
+	now func() time.Time
+}
+
+func NewFakeRedis() *FakeRedis {
+	return &FakeRedis{
+		values:  make(map[string]string),
+		hashes:  make(map[string]map[string]string),
+		zsets:   make(map[string]map[string]float64),
+		sets:    make(map[string]map[string]struct{}),
+		expires: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// SetClock troca o relógio usado nas expirações, como o Now de um clock.Fake.
+func (f *FakeRedis) SetClock(now func() time.Time) {
+	f.mu.Lock()
+	f.now = now
+	f.mu.Unlock()
+}
+
+// fakeScript é o equivalente em Go de um script Lua, com o lock já adquirido.
+type fakeScript func(f *FakeRedis, keys []string, args []string) (interface{}, error)
+
+// fakeScripts indexa os equivalentes pelo hash dos scripts do storage.
+var fakeScripts = map[string]fakeScript{
+	incrementSummaryScript.Hash(): func(f *FakeRedis, keys []string, args []string) (interface{}, error) {
+		for i, key := range keys {
+			if err := f.hincrBy(key, "totalRequests", args[3*i]); err != nil {
+				return nil, err
+			}
+			if err := f.hincrByFloat(key, "totalAmount", args[3*i+1]); err != nil {
+				return nil, err
+			}
+			if err := f.hincrBy(key, "checkRequests", args[3*i]); err != nil {
+				return nil, err
+			}
+			if err := f.hincrBy(key, "checkCents", args[3*i+2]); err != nil {
+				return nil, err
+			}
+		}
+		return int64(1), nil
+	},
+	readSummaryScript.Hash(): func(f *FakeRedis, keys []string, args []string) (interface{}, error) {
+		return f.hmgetAll(keys, "totalRequests", "totalAmount"), nil
+	},
+	readIntegrityScript.Hash(): func(f *FakeRedis, keys []string, args []string) (interface{}, error) {
+		return f.hmgetAll(keys, "totalRequests", "totalAmount", "checkRequests", "checkCents"), nil
+	},
+	countOnceScript.Hash(): func(f *FakeRedis, keys []string, args []string) (interface{}, error) {
+		counted := []interface{}{}
+		position := int64(0)
+		for i := 1; i+3 < len(args); i += 4 {
+			position++
+			added, err := f.sadd(keys[0], args[i])
+			if err != nil {
+				return nil, err
+			}
+			if !added {
+				continue
+			}
+			index, err := strconv.Atoi(args[i+1])
+			if err != nil || index < 1 || index > len(keys) {
+				return nil, fmt.Errorf("índice de chave inválido: %s", args[i+1])
+			}
+			key := keys[index-1]
+			if err := f.hincrBy(key, "totalRequests", "1"); err != nil {
+				return nil, err
+			}
+			if err := f.hincrByFloat(key, "totalAmount", args[i+2]); err != nil {
+				return nil, err
+			}
+			if err := f.hincrBy(key, "checkRequests", "1"); err != nil {
+				return nil, err
+			}
+			if err := f.hincrBy(key, "checkCents", args[i+3]); err != nil {
+				return nil, err
+			}
+			counted = append(counted, position)
+		}
+		ttl, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return nil, errors.New("ERR value is not an integer or out of range")
+		}
+		f.expire(keys[0], time.Duration(ttl)*time.Second)
+		return counted, nil
+	},
+	bumpEpochScript.Hash(): func(f *FakeRedis, keys []string, args []string) (interface{}, error) {
+		previous, err := f.integer(keys[0])
+		if err != nil {
+			return nil, err
+		}
+		score, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return nil, errors.New("ERR value is not a valid float")
+		}
+		if err := f.checkType(keys[1], "zset"); err != nil {
+			return nil, err
+		}
+		f.values[keys[0]] = strconv.FormatInt(previous+1, 10)
+		f.zsetForWrite(keys[1])[strconv.FormatInt(previous, 10)] = score
+		return previous + 1, nil
+	},
+	readEpochScript.Hash(): func(f *FakeRedis, keys []string, args []string) (interface{}, error) {
+		epoch, err := f.integer(keys[0])
+		if err != nil {
+			return nil, err
+		}
+		retired, err := f.zrangeByScore(keys[1], &redis.ZRangeBy{Min: "-inf", Max: args[0]})
+		if err != nil {
+			return nil, err
+		}
+		result := []interface{}{epoch}
+		for _, z := range retired {
+			old, _ := strconv.ParseInt(z.Member.(string), 10, 64)
+			result = append(result, old)
+		}
+		return result, nil
+	},
+	forgetEpochsScript.Hash(): func(f *FakeRedis, keys []string, args []string) (interface{}, error) {
+		if err := f.checkType(keys[0], "zset"); err != nil {
+			return nil, err
+		}
+		removed := int64(0)
+		for _, member := range args {
+			if _, ok := f.zsets[keys[0]][member]; ok {
+				delete(f.zsets[keys[0]], member)
+				removed++
+			}
+		}
+		if len(f.zsets[keys[0]]) == 0 {
+			delete(f.zsets, keys[0])
+		}
+		return removed, nil
+	},
+}
+
+func (f *FakeRedis) Eval(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) *redis.Cmd {
+	fn, ok := fakeScripts[script.Hash()]
+	if !ok {
+		return redis.NewCmdResult(nil, errors.New("NOSCRIPT script desconhecido pelo FakeRedis"))
+	}
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = fakeArg(arg)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expireKeys(keys...)
+	return redis.NewCmdResult(fn(f, keys, strs))
+}
+
+func (f *FakeRedis) HGet(ctx context.Context, key, field string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expireKeys(key)
+	v, ok := f.hash(key)[field]
+	if !ok {
+		return nil, redis.Nil
+	}
+	return []byte(v), nil
+}
+
+func (f *FakeRedis) HGetAllMany(ctx context.Context, keys []string) ([]map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expireKeys(keys...)
+	values := make([]map[string]string, len(keys))
+	for i, key := range keys {
+		values[i] = make(map[string]string, len(f.hash(key)))
+		for field, v := range f.hash(key) {
+			values[i][field] = v
+		}
+	}
+	return values, nil
+}
+
+func (f *FakeRedis) ZRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expireKeys(key)
+	members := f.sortedZSet(key)
+	n := int64(len(members))
+	// Índices negativos contam do fim, como no Redis
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return []string{}, nil
+	}
+	result := make([]string, 0, stop-start+1)
+	for _, z := range members[start : stop+1] {
+		result = append(result, z.Member.(string))
+	}
+	return result, nil
+}
+
+func (f *FakeRedis) ZRangeByScore(ctx context.Context, key string, by *redis.ZRangeBy) ([]string, error) {
+	values, err := f.ZRangeByScoreMany(ctx, []string{key}, by)
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+func (f *FakeRedis) ZRangeByScoreWithScores(ctx context.Context, key string, by *redis.ZRangeBy) ([]redis.Z, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expireKeys(key)
+	return f.zrangeByScore(key, by)
+}
+
+func (f *FakeRedis) ZRangeByScoreMany(ctx context.Context, keys []string, by *redis.ZRangeBy) ([][]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expireKeys(keys...)
+	values := make([][]string, len(keys))
+	for i, key := range keys {
+		members, err := f.zrangeByScore(key, by)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = make([]string, len(members))
+		for j, z := range members {
+			values[i][j] = z.Member.(string)
+		}
+	}
+	return values, nil
+}
+
+func (f *FakeRedis) Del(ctx context.Context, keys ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range keys {
+		f.del(key)
+	}
+	return nil
+}
+
+func (f *FakeRedis) Pipeline() RedisPipeline {
+	return &fakePipeline{f: f}
+}
+
+// fakePipeline aplica os comandos enfileirados de uma vez no Exec. Como no
+// Redis, um comando com erro não desfaz os anteriores.
+type fakePipeline struct {
+	f    *FakeRedis
+	cmds []func() error
+}
+
+func (p *fakePipeline) HSet(key, field string, value interface{}) {
+	v := fakeArg(value)
+	p.cmds = append(p.cmds, func() error {
+		if err := p.f.checkType(key, "hash"); err != nil {
+			return err
+		}
+		p.f.hashForWrite(key)[field] = v
+		return nil
+	})
+}
+
+func (p *fakePipeline) HIncrBy(key, field string, incr int64) {
+	p.cmds = append(p.cmds, func() error {
+		return p.f.hincrBy(key, field, strconv.FormatInt(incr, 10))
+	})
+}
+
+func (p *fakePipeline) HIncrByFloat(key, field string, incr float64) {
+	p.cmds = append(p.cmds, func() error {
+		return p.f.hincrByFloat(key, field, strconv.FormatFloat(incr, 'f', -1, 64))
+	})
+}
+
+func (p *fakePipeline) ZAdd(key string, members ...redis.Z) {
+	zs := slices.Clone(members)
+	p.cmds = append(p.cmds, func() error {
+		if err := p.f.checkType(key, "zset"); err != nil {
+			return err
+		}
+		zset := p.f.zsetForWrite(key)
+		for _, z := range zs {
+			zset[fakeArg(z.Member)] = z.Score
+		}
+		return nil
+	})
+}
+
+func (p *fakePipeline) ZRemRangeByRank(key string, start, stop int64) {
+	p.cmds = append(p.cmds, func() error {
+		if err := p.f.checkType(key, "zset"); err != nil {
+			return err
+		}
+		members := p.f.sortedZSet(key)
+		n := int64(len(members))
+		if start < 0 {
+			start = max(n+start, 0)
+		}
+		if stop < 0 {
+			stop = n + stop
+		}
+		stop = min(stop, n-1)
+		for i := start; i <= stop; i++ {
+			delete(p.f.zsets[key], members[i].Member.(string))
+		}
+		return nil
+	})
+}
+
+func (p *fakePipeline) Expire(key string, ttl time.Duration) {
+	p.cmds = append(p.cmds, func() error {
+		p.f.expire(key, ttl)
+		return nil
+	})
+}
+
+func (p *fakePipeline) Exec(ctx context.Context) error {
+	p.f.mu.Lock()
+	defer p.f.mu.Unlock()
+	var first error
+	for _, cmd := range p.cmds {
+		if err := cmd(); err != nil && first == nil {
+			first = err
+		}
+	}
+	p.cmds = nil
+	return first
+}
+
+// Daqui em diante, tudo roda com f.mu adquirido.
+
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+func (f *FakeRedis) checkType(key, kind string) error {
+	f.expireKeys(key)
+	_, isString := f.values[key]
+	_, isHash := f.hashes[key]
+	_, isZSet := f.zsets[key]
+	_, isSet := f.sets[key]
+	switch {
+	case isString && kind != "string", isHash && kind != "hash", isZSet && kind != "zset", isSet && kind != "set":
+		return errWrongType
+	}
+	return nil
+}
+
+func (f *FakeRedis) hash(key string) map[string]string {
+	return f.hashes[key]
+}
+
+// hmgetAll é o HMGET dos campos em cada chave, em sequência, como nos scripts
+// de leitura.
+func (f *FakeRedis) hmgetAll(keys []string, fields ...string) []interface{} {
+	result := make([]interface{}, 0, len(fields)*len(keys))
+	for _, key := range keys {
+		for _, field := range fields {
+			if v, ok := f.hash(key)[field]; ok {
+				result = append(result, v)
+			} else {
+				// false do Lua vira nil no go-redis
+				result = append(result, nil)
+			}
+		}
+	}
+	return result
+}
+
+func (f *FakeRedis) hashForWrite(key string) map[string]string {
+	h := f.hashes[key]
+	if h == nil {
+		h = make(map[string]string)
+		f.hashes[key] = h
+	}
+	return h
+}
+
+func (f *FakeRedis) zsetForWrite(key string) map[string]float64 {
+	z := f.zsets[key]
+	if z == nil {
+		z = make(map[string]float64)
+		f.zsets[key] = z
+	}
+	return z
+}
+
+// integer lê uma string com inteiro; 0 quando a chave não existe, como o INCR.
+func (f *FakeRedis) integer(key string) (int64, error) {
+	if err := f.checkType(key, "string"); err != nil {
+		return 0, err
+	}
+	v, ok := f.values[key]
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, errors.New("ERR value is not an integer or out of range")
+	}
+	return n, nil
+}
+
+func (f *FakeRedis) hincrBy(key, field, incr string) error {
+	if err := f.checkType(key, "hash"); err != nil {
+		return err
+	}
+	delta, err := strconv.ParseInt(incr, 10, 64)
+	if err != nil {
+		return errors.New("ERR value is not an integer or out of range")
+	}
+	current := int64(0)
+	if v, ok := f.hash(key)[field]; ok {
+		if current, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return errors.New("ERR hash value is not an integer")
+		}
+	}
+	f.hashForWrite(key)[field] = strconv.FormatInt(current+delta, 10)
+	return nil
+}
+
+func (f *FakeRedis) hincrByFloat(key, field, incr string) error {
+	if err := f.checkType(key, "hash"); err != nil {
+		return err
+	}
+	// O Redis soma em long double (64 bits de mantissa) e formata com %.17Lg
+	delta, _, err := big.ParseFloat(incr, 10, 64, big.ToNearestEven)
+	if err != nil {
+		return errors.New("ERR value is not a valid float")
+	}
+	current := new(big.Float).SetPrec(64)
+	if v, ok := f.hash(key)[field]; ok {
+		if current, _, err = big.ParseFloat(v, 10, 64, big.ToNearestEven); err != nil {
+			return errors.New("ERR hash value is not a float")
+		}
+	}
+	f.hashForWrite(key)[field] = current.Add(current, delta).Text('g', 17)
+	return nil
+}
+
+// sadd retorna true quando o membro é novo.
+func (f *FakeRedis) sadd(key, member string) (bool, error) {
+	if err := f.checkType(key, "set"); err != nil {
+		return false, err
+	}
+	set := f.sets[key]
+	if set == nil {
+		set = make(map[string]struct{})
+		f.sets[key] = set
+	}
+	if _, ok := set[member]; ok {
+		return false, nil
+	}
+	set[member] = struct{}{}
+	return true, nil
+}
+
+func (f *FakeRedis) expire(key string, ttl time.Duration) {
+	if f.exists(key) {
+		f.expires[key] = f.now().Add(ttl)
+	}
+}
+
+func (f *FakeRedis) exists(key string) bool {
+	_, isString := f.values[key]
+	_, isHash := f.hashes[key]
+	_, isZSet := f.zsets[key]
+	_, isSet := f.sets[key]
+	return isString || isHash || isZSet || isSet
+}
+
+// expireKeys apaga as chaves vencidas antes de um comando usá-las.
+func (f *FakeRedis) expireKeys(keys ...string) {
+	now := f.now()
+	for _, key := range keys {
+		if at, ok := f.expires[key]; ok && !now.Before(at) {
+			f.del(key)
+		}
+	}
+}
+
+func (f *FakeRedis) del(key string) {
+	delete(f.values, key)
+	delete(f.hashes, key)
+	delete(f.zsets, key)
+	delete(f.sets, key)
+	delete(f.expires, key)
+}
+
+// sortedZSet ordena por score e, no empate, pelo membro, como o Redis.
+func (f *FakeRedis) sortedZSet(key string) []redis.Z {
+	zset := f.zsets[key]
+	members := make([]redis.Z, 0, len(zset))
+	for member, score := range zset {
+		members = append(members, redis.Z{Score: score, Member: member})
+	}
+	slices.SortFunc(members, func(a, b redis.Z) int {
+		if a.Score != b.Score {
+			if a.Score < b.Score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Member.(string), b.Member.(string))
+	})
+	return members
+}
+
+func (f *FakeRedis) zrangeByScore(key string, by *redis.ZRangeBy) ([]redis.Z, error) {
+	minScore, minExclusive, err := parseScoreBound(by.Min)
+	if err != nil {
+		return nil, err
+	}
+	maxScore, maxExclusive, err := parseScoreBound(by.Max)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []redis.Z{}
+	for _, z := range f.sortedZSet(key) {
+		if z.Score < minScore || (minExclusive && z.Score == minScore) {
+			continue
+		}
+		if z.Score > maxScore || (maxExclusive && z.Score == maxScore) {
+			break
+		}
+		result = append(result, z)
+	}
+
+	// O go-redis só manda o LIMIT com offset ou count; count negativo é "todos"
+	if by.Offset != 0 || by.Count != 0 {
+		if by.Offset < 0 || by.Offset >= int64(len(result)) {
+			return []redis.Z{}, nil
+		}
+		result = result[by.Offset:]
+		if by.Count >= 0 && by.Count < int64(len(result)) {
+			result = result[:by.Count]
+		}
+	}
+	return result, nil
+}
+
+// parseScoreBound interpreta um limite do ZRANGEBYSCORE: número, "(" exclusivo,
+// "-inf" ou "+inf".
+func parseScoreBound(bound string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(bound, "(")
+	bound = strings.TrimPrefix(bound, "(")
+	switch bound {
+	case "-inf":
+		return math.Inf(-1), exclusive, nil
+	case "+inf", "inf":
+		return math.Inf(1), exclusive, nil
+	}
+	score, err := strconv.ParseFloat(bound, 64)
+	if err != nil {
+		return 0, false, errors.New("ERR min or max is not a float")
+	}
+	return score, exclusive, nil
+}
+
+// fakeArg converte um argumento como o go-redis o envia ao Redis.
+func fakeArg(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+	return fmt.Sprint(arg)
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore é o subconjunto de comandos do Redis usado pelo storage Redis:
+// contadores em hashes, pagamentos em ZSETs e os scripts atômicos. O
+// go-redis o implementa por NewRedisStore e o FakeRedis em memória, para testar a
+// contabilidade e as consultas por período sem um Redis de verdade.
+type RedisStore interface {
+	// Eval roda um dos scripts do storage; o FakeRedis só conhece esses.
+	Eval(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) *redis.Cmd
+	// HGet retorna redis.Nil quando o campo não existe.
+	HGet(ctx context.Context, key, field string) ([]byte, error)
+	// HGetAllMany lê várias hashes de uma vez, na ordem das chaves; as que não
+	// existem vêm vazias.
+	HGetAllMany(ctx context.Context, keys []string) ([]map[string]string, error)
+	ZRange(ctx context.Context, key string, start, stop int64) ([]string, error)
+	ZRangeByScore(ctx context.Context, key string, by *redis.ZRangeBy) ([]string, error)
+	ZRangeByScoreWithScores(ctx context.Context, key string, by *redis.ZRangeBy) ([]redis.Z, error)
+	// ZRangeByScoreMany lê o mesmo intervalo de vários ZSETs de uma vez, na ordem
+	// das chaves.
+	ZRangeByScoreMany(ctx context.Context, keys []string, by *redis.ZRangeBy) ([][]string, error)
+	Del(ctx context.Context, keys ...string) error
+	// Pipeline agrupa escritas enviadas juntas no Exec.
+	Pipeline() RedisPipeline
+}
+
+// RedisPipeline acumula escritas até o Exec.
+type RedisPipeline interface {
+	HSet(key, field string, value interface{})
+	HIncrBy(key, field string, incr int64)
+	HIncrByFloat(key, field string, incr float64)
+	ZAdd(key string, members ...redis.Z)
+	// ZRemRangeByRank aceita índices negativos, contados do fim
+	ZRemRangeByRank(key string, start, stop int64)
+	Expire(key string, ttl time.Duration)
+	Exec(ctx context.Context) error
+}
+
+// NewRedisStore adapta um cliente go-redis ao RedisStore.
+func NewRedisStore(client redis.UniversalClient) RedisStore {
+	return goRedisStore{client: client}
+}
+
+type goRedisStore struct {
+	client redis.UniversalClient
+}
+
+func (s goRedisStore) Eval(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) *redis.Cmd {
+	return script.Run(ctx, s.client, keys, args...)
+}
+
+func (s goRedisStore) HGet(ctx context.Context, key, field string) ([]byte, error) {
+	return s.client.HGet(ctx, key, field).Bytes()
+}
+
+func (s goRedisStore) HGetAllMany(ctx context.Context, keys []string) ([]map[string]string, error) {
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.HGetAll(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	values := make([]map[string]string, len(cmds))
+	for i, cmd := range cmds {
+		values[i] = cmd.Val()
+	}
+	return values, nil
+}
+
+func (s goRedisStore) ZRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	return s.client.ZRange(ctx, key, start, stop).Result()
+}
+
+func (s goRedisStore) ZRangeByScore(ctx context.Context, key string, by *redis.ZRangeBy) ([]string, error) {
+	return s.client.ZRangeByScore(ctx, key, by).Result()
+}
+
+func (s goRedisStore) ZRangeByScoreWithScores(ctx context.Context, key string, by *redis.ZRangeBy) ([]redis.Z, error) {
+	return s.client.ZRangeByScoreWithScores(ctx, key, by).Result()
+}
+
+func (s goRedisStore) ZRangeByScoreMany(ctx context.Context, keys []string, by *redis.ZRangeBy) ([][]string, error) {
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.StringSliceCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.ZRangeByScore(ctx, key, by)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	values := make([][]string, len(cmds))
+	for i, cmd := range cmds {
+		values[i] = cmd.Val()
+	}
+	return values, nil
+}
+
+func (s goRedisStore) Del(ctx context.Context, keys ...string) error {
+	return s.client.Del(ctx, keys...).Err()
+}
+
+func (s goRedisStore) Pipeline() RedisPipeline {
+	return &goRedisPipeline{pipe: s.client.Pipeline()}
+}
+
+// goRedisPipeline enfileira os comandos sem contexto: vale o do Exec, quando o
+// pipeline do go-redis de fato os envia.
+type goRedisPipeline struct {
+	pipe redis.Pipeliner
+}
+
+func (p *goRedisPipeline) HSet(key, field string, value interface{}) {
+	p.pipe.HSet(context.Background(), key, field, value)
+}
+
+func (p *goRedisPipeline) HIncrBy(key, field string, incr int64) {
+	p.pipe.HIncrBy(context.Background(), key, field, incr)
+}
+
+func (p *goRedisPipeline) HIncrByFloat(key, field string, incr float64) {
+	p.pipe.HIncrByFloat(context.Background(), key, field, incr)
+}
+
+func (p *goRedisPipeline) ZAdd(key string, members ...redis.Z) {
+	p.pipe.ZAdd(context.Background(), key, members...)
+}
+
+func (p *goRedisPipeline) ZRemRangeByRank(key string, start, stop int64) {
+	p.pipe.ZRemRangeByRank(context.Background(), key, start, stop)
+}
+
+func (p *goRedisPipeline) Expire(key string, ttl time.Duration) {
+	p.pipe.Expire(context.Background(), key, ttl)
+}
+
+func (p *goRedisPipeline) Exec(ctx context.Context) error {
+	_, err := p.pipe.Exec(ctx)
+	return err
+}
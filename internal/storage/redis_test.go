@@ -0,0 +1,406 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/ids"
+	"rinha-backend-2025/internal/keyspace"
+)
+
+var testProcessors = []string{"default", "fallback"}
+
+// redisBackends roda cada teste nos scripts Lua de verdade, sobre o miniredis,
+// e nos equivalentes em Go do FakeRedis, que precisam dar o mesmo resultado.
+func redisBackends(t *testing.T, run func(t *testing.T, s *Redis, server *miniredis.Miniredis)) {
+	t.Run("miniredis", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+		run(t, NewRedis(client, testProcessors), server)
+	})
+	t.Run("fake", func(t *testing.T) {
+		run(t, NewRedisFrom(NewFakeRedis(), testProcessors), nil)
+	})
+}
+
+func testRecord(n int, processor string, amount float64, requestedAt time.Time) Record {
+	return Record{
+		CorrelationID: testCorrelationID(n),
+		Amount:        amount,
+		Processor:     processor,
+		RequestedAt:   requestedAt,
+		Currency:      DefaultCurrency,
+	}
+}
+
+func testCorrelationID(n int) string {
+	return "4a7901b8-7d26-4d9d-aa19-" + leftPad(strconv.Itoa(n), 12)
+}
+
+func leftPad(s string, width int) string {
+	for len(s) < width {
+		s = "0" + s
+	}
+	return s
+}
+
+func assertSummary(t *testing.T, got map[string]Summary, want map[string]Summary) {
+	t.Helper()
+	for _, processor := range testProcessors {
+		g, w := got[processor], want[processor]
+		if g.TotalRequests != w.TotalRequests || math.Abs(g.TotalAmount-w.TotalAmount) > 1e-9 {
+			t.Errorf("%s: %d pagamentos, %.2f; esperado %d, %.2f", processor, g.TotalRequests, g.TotalAmount, w.TotalRequests, w.TotalAmount)
+		}
+	}
+}
+
+func TestRedisCountOnce(t *testing.T) {
+	redisBackends(t, func(t *testing.T, s *Redis, _ *miniredis.Miniredis) {
+		ctx := context.Background()
+		at := time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC)
+
+		first := []Record{
+			testRecord(1, "default", 19.9, at),
+			testRecord(2, "fallback", 10.1, at),
+			// Repetido no mesmo lote
+			testRecord(1, "default", 19.9, at),
+			testRecord(3, "default", 0.1, at),
+		}
+		counted, err := s.CountOnce(ctx, first, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(counted) != 3 || counted[0].CorrelationID != first[0].CorrelationID || counted[2].CorrelationID != first[3].CorrelationID {
+			t.Errorf("contados %v, esperado os 3 primeiros distintos na ordem do lote", counted)
+		}
+
+		// Um lote repetido (flush refeito, outra instância) não conta de novo
+		second := []Record{first[1], testRecord(4, "fallback", 5, at)}
+		counted, err = s.CountOnce(ctx, second, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(counted) != 1 || counted[0].CorrelationID != second[1].CorrelationID {
+			t.Errorf("contados %v, esperado só o pagamento novo", counted)
+		}
+
+		summary, err := s.GetSummary(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSummary(t, summary, map[string]Summary{
+			"default":  {TotalRequests: 2, TotalAmount: 20},
+			"fallback": {TotalRequests: 2, TotalAmount: 15.1},
+		})
+
+		if counted, err := s.CountOnce(ctx, nil, time.Hour); err != nil || len(counted) != 0 {
+			t.Errorf("lote vazio: %v, %v", counted, err)
+		}
+	})
+}
+
+func TestRedisCountedSetExpires(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	s := NewRedis(client, testProcessors)
+	ctx := context.Background()
+
+	payment := testRecord(1, "default", 1, time.Now())
+	if _, err := s.CountOnce(ctx, []Record{payment}, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL(s.countedKey(0)); ttl != 30*time.Second {
+		t.Errorf("TTL do SET dos contados %v, esperado 30s", ttl)
+	}
+
+	// Um TTL abaixo de 1s vira 1s: EXPIRE 0 apagaria o SET na hora
+	if _, err := s.CountOnce(ctx, []Record{testRecord(2, "default", 1, time.Now())}, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL(s.countedKey(0)); ttl != time.Second {
+		t.Errorf("TTL do SET dos contados %v, esperado 1s", ttl)
+	}
+
+	server.FastForward(2 * time.Second)
+	counted, err := s.CountOnce(ctx, []Record{payment}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counted) != 1 {
+		t.Errorf("depois de expirar o SET, o pagamento deveria contar de novo")
+	}
+}
+
+func TestRedisIncrementSummary(t *testing.T) {
+	redisBackends(t, func(t *testing.T, s *Redis, _ *miniredis.Miniredis) {
+		ctx := context.Background()
+		for i := 0; i < 10; i++ {
+			err := s.IncrementSummary(ctx, map[string]*Delta{
+				"default":  {Requests: 1, Amount: 19.9},
+				"fallback": {Requests: 2, Amount: 0.2},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		summary, err := s.GetSummary(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSummary(t, summary, map[string]Summary{
+			"default":  {TotalRequests: 10, TotalAmount: 199},
+			"fallback": {TotalRequests: 20, TotalAmount: 2},
+		})
+	})
+}
+
+func TestRedisQueryByRange(t *testing.T) {
+	redisBackends(t, func(t *testing.T, s *Redis, _ *miniredis.Miniredis) {
+		ctx := context.Background()
+		base := time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC)
+		payments := []Record{
+			testRecord(1, "default", 10, base),
+			testRecord(2, "default", 20, base.Add(time.Second)),
+			testRecord(3, "fallback", 30, base.Add(time.Second+999*time.Millisecond)),
+			testRecord(4, "default", 40, base.Add(2*time.Second)),
+			testRecord(5, "fallback", 50, base.Add(time.Minute)),
+		}
+		if err := s.RecordPayments(ctx, payments); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name     string
+			from, to time.Time
+			want     map[string]Summary
+		}{
+			{"tudo", base, base.Add(time.Hour), map[string]Summary{
+				"default":  {TotalRequests: 3, TotalAmount: 70},
+				"fallback": {TotalRequests: 2, TotalAmount: 80},
+			}},
+			{"limites inclusivos", base.Add(time.Second), base.Add(2 * time.Second), map[string]Summary{
+				"default":  {TotalRequests: 2, TotalAmount: 60},
+				"fallback": {TotalRequests: 1, TotalAmount: 30},
+			}},
+			{"milissegundos", base.Add(time.Second + 999*time.Millisecond), base.Add(time.Second + 999*time.Millisecond), map[string]Summary{
+				"fallback": {TotalRequests: 1, TotalAmount: 30},
+			}},
+			{"vazio", base.Add(time.Hour), base.Add(2 * time.Hour), nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				summary, err := s.QueryByRange(ctx, tt.from, tt.to)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertSummary(t, summary, tt.want)
+			})
+		}
+
+		record, found, err := s.FindPayment(ctx, payments[2].CorrelationID)
+		if err != nil || !found {
+			t.Fatalf("FindPayment: %v, %v", found, err)
+		}
+		if record.Amount != 30 || record.Processor != "fallback" || !record.RequestedAt.Equal(payments[2].RequestedAt) {
+			t.Errorf("FindPayment retornou %+v", record)
+		}
+	})
+}
+
+func TestRedisPurgeBumpsEpoch(t *testing.T) {
+	redisBackends(t, func(t *testing.T, s *Redis, server *miniredis.Miniredis) {
+		ctx := context.Background()
+		at := time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC)
+		payment := testRecord(1, "default", 19.9, at)
+		if _, err := s.CountOnce(ctx, []Record{payment}, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RecordPayments(ctx, []Record{payment}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Purge(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if s.Epoch() != 1 {
+			t.Fatalf("época %d depois do purge, esperado 1", s.Epoch())
+		}
+		summary, err := s.GetSummary(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSummary(t, summary, nil)
+		if server != nil && server.Exists(s.summaryKey(0, "default")) {
+			t.Errorf("purge não apagou as chaves da época anterior")
+		}
+
+		// Flush atrasado, acumulado antes do purge: cai na época 0, que ninguém lê
+		late := WithEpoch(ctx, 0)
+		if _, err := s.CountOnce(late, []Record{testRecord(2, "default", 5, at)}, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if summary, _ = s.GetSummary(ctx); summary["default"].TotalRequests != 0 {
+			t.Errorf("escrita atrasada apareceu na época nova: %+v", summary)
+		}
+
+		// O correlationId contado antes do purge conta de novo na época nova
+		counted, err := s.CountOnce(ctx, []Record{payment}, time.Hour)
+		if err != nil || len(counted) != 1 {
+			t.Errorf("pagamento da época anterior: contados %v, %v", counted, err)
+		}
+		if _, found, _ := s.FindPayment(ctx, payment.CorrelationID); found {
+			t.Errorf("registro da época anterior ainda visível")
+		}
+	})
+}
+
+func TestRedisSyncEpochPurgesRetiredKeys(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	fake := clock.NewFake(time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC))
+	first := NewRedis(client, testProcessors)
+	other := NewRedis(client, testProcessors)
+	first.SetClock(fake)
+	other.SetClock(fake)
+	if err := first.Purge(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A outra instância ainda escreve na época 0 até sincronizar
+	late := testRecord(1, "default", 1, time.Now())
+	if _, err := other.CountOnce(ctx, []Record{late}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	epoch, err := other.SyncEpoch(ctx)
+	if err != nil || epoch != 1 {
+		t.Fatalf("SyncEpoch = %d, %v; esperado 1", epoch, err)
+	}
+	if !server.Exists(other.summaryKey(0, "default")) {
+		t.Fatalf("a escrita atrasada deveria estar na época 0 até o fim da carência")
+	}
+
+	// Ainda na carência, a época aposentada fica
+	fake.Advance(epochGrace - time.Millisecond)
+	if _, err := other.SyncEpoch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Exists(other.summaryKey(0, "default")) {
+		t.Fatalf("época 0 apagada antes do fim da carência")
+	}
+
+	// Passada a carência, a sincronização apaga a época aposentada e a esquece
+	fake.Advance(time.Second)
+	if _, err := other.SyncEpoch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if server.Exists(other.summaryKey(0, "default")) || server.Exists(other.countedKey(0)) {
+		t.Errorf("chaves da época 0 não foram apagadas")
+	}
+	if members, _ := server.ZMembers(other.retiredEpochsKey()); len(members) != 0 {
+		t.Errorf("épocas aposentadas restantes: %v", members)
+	}
+}
+
+func TestRedisCheckIntegrity(t *testing.T) {
+	redisBackends(t, func(t *testing.T, s *Redis, _ *miniredis.Miniredis) {
+		ctx := context.Background()
+		at := time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC)
+		payments := []Record{
+			testRecord(1, "default", 19.9, at),
+			testRecord(2, "default", 0.1, at),
+			testRecord(3, "fallback", 1234.56, at),
+		}
+		if _, err := s.CountOnce(ctx, payments, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RecordPayments(ctx, payments); err != nil {
+			t.Fatal(err)
+		}
+
+		integrity, err := s.CheckIntegrity(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]Tally{"default": {Requests: 2, Cents: 2000}, "fallback": {Requests: 1, Cents: 123456}}
+		for processor, tally := range want {
+			got := integrity[processor]
+			if got.Checksum == nil || *got.Checksum != tally {
+				t.Errorf("%s: soma de conferência %+v, esperado %+v", processor, got.Checksum, tally)
+			}
+			if got.Recorded != tally {
+				t.Errorf("%s: recontagem %+v, esperado %+v", processor, got.Recorded, tally)
+			}
+			if int64(got.Counters.TotalRequests) != tally.Requests {
+				t.Errorf("%s: contadores %+v", processor, got.Counters)
+			}
+		}
+
+		// Contado sem registro: a recontagem denuncia a diferença
+		if _, err := s.CountOnce(ctx, []Record{testRecord(4, "fallback", 5, at)}, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		integrity, err = s.CheckIntegrity(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fallback := integrity["fallback"]
+		if *fallback.Checksum != (Tally{Requests: 2, Cents: 123956}) || fallback.Recorded != want["fallback"] {
+			t.Errorf("fallback: soma %+v, recontagem %+v", *fallback.Checksum, fallback.Recorded)
+		}
+	})
+}
+
+func TestRedisStoresBinaryCorrelationIDs(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	s := NewRedis(client, testProcessors)
+	ctx := context.Background()
+
+	payment := testRecord(1, "default", 19.9, time.Now())
+	if err := s.RecordPayments(ctx, []Record{payment}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := server.HKeys(s.recordsKey(0)); len(got) != 1 || got[0] != ids.Encode(payment.CorrelationID) || len(got[0]) != 16 {
+		t.Errorf("campos de records %q, esperado o correlationId em 16 bytes", got)
+	}
+}
+
+func TestRedisKeysInRunNamespace(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	s := NewRedis(client, testProcessors)
+	s.SetKeys(keyspace.New("run1"))
+	ctx := context.Background()
+
+	payment := testRecord(1, "default", 19.9, time.Now())
+	if _, err := s.CountOnce(ctx, []Record{payment}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Exists("run1:summary:{rinha}:default") || server.Exists("summary:{rinha}:default") {
+		t.Errorf("resumo fora do namespace: chaves %v", server.Keys())
+	}
+
+	// Outra execução no mesmo Redis não enxerga o resumo
+	other := NewRedis(client, testProcessors)
+	other.SetKeys(keyspace.New("run2"))
+	summary, err := other.GetSummary(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSummary(t, summary, nil)
+}
@@ -0,0 +1,114 @@
+// Package storage guarda os contadores do resumo e os pagamentos processados.
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rinha-backend-2025/internal/clock"
+	"rinha-backend-2025/internal/config"
+	"rinha-backend-2025/internal/keyspace"
+)
+
+// Storage abstrai onde os contadores e os pagamentos processados são guardados.
+type Storage interface {
+	// IncrementSummary soma os deltas de cada processor aos contadores
+	IncrementSummary(ctx context.Context, deltas map[string]*Delta) error
+	// GetSummary retorna os contadores de todos os processors
+	GetSummary(ctx context.Context) (map[string]Summary, error)
+	// RecordPayment guarda um pagamento processado com sucesso
+	RecordPayment(ctx context.Context, payment Record) error
+	// QueryByRange agrega os pagamentos com requestedAt em [from, to]
+	QueryByRange(ctx context.Context, from, to time.Time) (map[string]Summary, error)
+	// Purge apaga contadores e pagamentos
+	Purge(ctx context.Context) error
+}
+
+// BatchRecorder é implementado pelos storages que gravam vários pagamentos de uma vez.
+type BatchRecorder interface {
+	RecordPayments(ctx context.Context, payments []Record) error
+}
+
+// OnceCounter é implementado pelos storages que contam cada correlationId uma
+// única vez, mesmo que o pagamento volte por retry, reconciliação ou replay do WAL.
+type OnceCounter interface {
+	// CountOnce soma aos contadores os pagamentos ainda não contados, marcando-os
+	// na mesma operação, e retorna os que foram contados agora. A marca vale por
+	// ttl, renovado a cada chamada.
+	CountOnce(ctx context.Context, payments []Record, ttl time.Duration) ([]Record, error)
+}
+
+// Exporter é implementado pelos storages que percorrem os pagamentos registrados
+// sem carregá-los todos de uma vez. A ordem não é garantida; um erro de fn
+// interrompe a exportação e é retornado.
+type Exporter interface {
+	ExportByRange(ctx context.Context, from, to time.Time, fn func(Record) error) error
+}
+
+// Finder é implementado pelos storages que localizam um pagamento registrado
+// pelo correlationId; false quando ele não foi registrado.
+type Finder interface {
+	FindPayment(ctx context.Context, correlationID string) (Record, bool, error)
+}
+
+// LocalSummarizer é implementado pelos backends que guardam pagamentos que só
+// esta instância conhece (memória); a agregação do cluster soma essa parte de
+// cada instância. Com from e to zerados, retorna os contadores sem filtro.
+type LocalSummarizer interface {
+	LocalSummary(ctx context.Context, from, to time.Time) (map[string]Summary, error)
+}
+
+// Delta é o quanto somar aos contadores de um processor.
+type Delta struct {
+	Requests int64
+	Amount   float64
+}
+
+// Summary são os contadores de um processor.
+type Summary struct {
+	TotalRequests int     `json:"totalRequests"`
+	TotalAmount   float64 `json:"totalAmount"`
+}
+
+// Record é um pagamento confirmado por um processor.
+type Record struct {
+	CorrelationID string    `json:"correlationId"`
+	Amount        float64   `json:"amount"`
+	Processor     string    `json:"processor"`
+	RequestedAt   time.Time `json:"requestedAt"`
+	// Código ISO 4217; vazio nos registros anteriores à moeda, tratados como BRL
+	Currency string `json:"currency,omitempty"`
+	// Objeto JSON opcional do cliente, guardado como veio (compactado)
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// Época do envio (ver Versioned); não é guardada com o registro
+	Epoch int64 `json:"-"`
+}
+
+// New cria o backend escolhido em STORAGE_BACKEND para os processors em names,
+// com clk nas épocas do Redis e na contagem única em memória, e as chaves no
+// namespace keys. Com client nil (Redis fora do ar), o backend "redis" grava em
+// memória até receber Promote.
+func New(ctx context.Context, cfg config.StorageConfig, client redis.UniversalClient, names []string, clk clock.Clock, keys keyspace.Space) (Storage, error) {
+	switch cfg.Backend {
+	case "redis":
+		if client == nil {
+			log.Printf("Aviso: Redis indisponível, usando storage em memória até reconectar")
+		}
+		return NewDegradable(client, names, cfg.RecordTTL.Std(), AmountOptions{Bounds: cfg.AmountBounds, Top: cfg.TopAmounts}, clk, keys), nil
+	case "memory":
+		memory := NewMemory()
+		memory.SetClock(clk)
+		return memory, nil
+	case "postgres":
+		return NewPostgres(ctx, cfg.PostgresDSN)
+	case "bolt":
+		return NewBolt(cfg.BoltPath)
+	default:
+		return nil, fmt.Errorf("storage desconhecido: %q", cfg.Backend)
+	}
+}
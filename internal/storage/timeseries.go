@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TimeSeries é implementado pelos storages que mantêm totais por segundo de
+// requestedAt, atualizados na gravação dos pagamentos. Nos demais, a série é
+// agregada a partir da exportação do período.
+type TimeSeries interface {
+	QueryBuckets(ctx context.Context, from, to time.Time, bucket time.Duration) ([]Bucket, error)
+}
+
+// ErrNoTimeSeries indica um storage sem série nem exportação para agregá-la.
+var ErrNoTimeSeries = errors.New("storage não permite consultar a série por período")
+
+// Bucket soma os pagamentos com requestedAt em [Start, Start+bucket).
+type Bucket struct {
+	Start      time.Time
+	Processors map[string]Summary
+}
+
+// QueryBuckets retorna um bucket para cada intervalo entre from e to, inclusive
+// os vazios. from é alinhado ao início do seu bucket; bucket deve ser múltiplo
+// de um segundo.
+func QueryBuckets(ctx context.Context, s Storage, from, to time.Time, bucket time.Duration) ([]Bucket, error) {
+	if series, ok := s.(TimeSeries); ok {
+		return series.QueryBuckets(ctx, from, to, bucket)
+	}
+	if exporter, ok := s.(Exporter); ok {
+		return bucketsByExport(ctx, exporter, from, to, bucket)
+	}
+	return nil, ErrNoTimeSeries
+}
+
+// bucketSeries é a série vazia de [from, to], a preencher com add.
+type bucketSeries struct {
+	buckets []Bucket
+	start   time.Time
+	bucket  time.Duration
+}
+
+func newBucketSeries(from, to time.Time, bucket time.Duration) *bucketSeries {
+	start := from.UTC().Truncate(bucket)
+	series := &bucketSeries{start: start, bucket: bucket}
+	for t := start; !t.After(to); t = t.Add(bucket) {
+		series.buckets = append(series.buckets, Bucket{Start: t, Processors: make(map[string]Summary)})
+	}
+	return series
+}
+
+func (b *bucketSeries) add(at time.Time, processor string, requests int, amount float64) {
+	i := int(at.Sub(b.start) / b.bucket)
+	if i < 0 || i >= len(b.buckets) {
+		return
+	}
+	current := b.buckets[i].Processors[processor]
+	current.TotalRequests += requests
+	current.TotalAmount += amount
+	b.buckets[i].Processors[processor] = current
+}
+
+func bucketsByExport(ctx context.Context, exporter Exporter, from, to time.Time, bucket time.Duration) ([]Bucket, error) {
+	series := newBucketSeries(from, to, bucket)
+	err := exporter.ExportByRange(ctx, series.start, to, func(record Record) error {
+		series.add(record.RequestedAt, record.Processor, 1, record.Amount)
+		return nil
+	})
+	return series.buckets, err
+}
+
+// Totais por segundo no Redis: a hash buckets:{rinha}:<segundo unix> tem os
+// campos requests:<processor> e amount:<processor>, e o ZSET buckets:{rinha}
+// indexa os segundos com pagamentos, para a consulta ler só as hashes que
+// existem e o purge saber o que apagar.
+
+// Hashes lidas por pipeline na consulta e apagadas por DEL no purge
+const bucketPage = 500
+
+func (s *Redis) bucketIndexKey(epoch int64) string {
+	return s.epochPrefix(epoch, "buckets")
+}
+
+func (s *Redis) bucketKey(epoch int64, second string) string {
+	return s.epochPrefix(epoch, "buckets") + ":" + second
+}
+
+// bucketDeltas agrupa um lote de pagamentos por segundo e processor.
+func bucketDeltas(payments []Record) map[int64]map[string]*Delta {
+	deltas := make(map[int64]map[string]*Delta)
+	for _, payment := range payments {
+		second := payment.RequestedAt.Unix()
+		byProcessor := deltas[second]
+		if byProcessor == nil {
+			byProcessor = make(map[string]*Delta)
+			deltas[second] = byProcessor
+		}
+		delta := byProcessor[payment.Processor]
+		if delta == nil {
+			delta = &Delta{}
+			byProcessor[payment.Processor] = delta
+		}
+		delta.Requests++
+		delta.Amount += payment.Amount
+	}
+	return deltas
+}
+
+func (s *Redis) QueryBuckets(ctx context.Context, from, to time.Time, bucket time.Duration) ([]Bucket, error) {
+	series := newBucketSeries(from, to, bucket)
+	epoch := s.Epoch()
+	seconds, err := s.client.ZRangeByScore(ctx, s.bucketIndexKey(epoch), &redis.ZRangeBy{
+		Min: strconv.FormatInt(series.start.Unix(), 10),
+		Max: strconv.FormatInt(to.Unix(), 10),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for page := 0; page < len(seconds); page += bucketPage {
+		chunk := seconds[page:min(page+bucketPage, len(seconds))]
+		keys := make([]string, len(chunk))
+		for i, second := range chunk {
+			keys[i] = s.bucketKey(epoch, second)
+		}
+		hashes, err := s.client.HGetAllMany(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, second := range chunk {
+			unix, err := strconv.ParseInt(second, 10, 64)
+			if err != nil {
+				continue
+			}
+			at := time.Unix(unix, 0).UTC()
+			for field, value := range hashes[i] {
+				kind, processor, ok := strings.Cut(field, ":")
+				if !ok {
+					continue
+				}
+				switch kind {
+				case "requests":
+					n, _ := strconv.Atoi(value)
+					series.add(at, processor, n, 0)
+				case "amount":
+					amount, _ := strconv.ParseFloat(value, 64)
+					series.add(at, processor, 0, amount)
+				}
+			}
+		}
+	}
+	return series.buckets, nil
+}
+
+// purgeBuckets apaga as hashes indexadas e o índice da época.
+func (s *Redis) purgeBuckets(ctx context.Context, epoch int64) error {
+	seconds, err := s.client.ZRange(ctx, s.bucketIndexKey(epoch), 0, -1)
+	if err != nil {
+		return err
+	}
+	for page := 0; page < len(seconds); page += bucketPage {
+		chunk := seconds[page:min(page+bucketPage, len(seconds))]
+		keys := make([]string, len(chunk))
+		for i, second := range chunk {
+			keys[i] = s.bucketKey(epoch, second)
+		}
+		if err := s.client.Del(ctx, keys...); err != nil {
+			return err
+		}
+	}
+	return s.client.Del(ctx, s.bucketIndexKey(epoch))
+}
+
+// QueryBuckets usa a série do Redis enquanto ele responde; em modo degradado,
+// apenas os pagamentos registrados desde a queda.
+func (s *Degradable) QueryBuckets(ctx context.Context, from, to time.Time, bucket time.Duration) ([]Bucket, error) {
+	s.mu.RLock()
+	remote, local := s.remote, s.local
+	s.mu.RUnlock()
+
+	if remote != nil {
+		return remote.QueryBuckets(ctx, from, to, bucket)
+	}
+	return bucketsByExport(ctx, local, from, to, bucket)
+}
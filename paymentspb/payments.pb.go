@@ -0,0 +1,427 @@
+// Serviço gRPC de ingestão, equivalente às rotas HTTP POST /payments e
+// GET /payments-summary.
+//
+// Para regenerar o código Go (a partir da raiz do repositório):
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	  --go-grpc_out=. --go-grpc_opt=paths=source_relative \
+//	  paymentspb/payments.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: paymentspb/payments.proto
+
+package paymentspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Também é o corpo de POST /payments com Content-Type application/x-protobuf.
+type SubmitPaymentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CorrelationId string                 `protobuf:"bytes,1,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+	Amount        float64                `protobuf:"fixed64,2,opt,name=amount,proto3" json:"amount,omitempty"`
+	// Opcionais, como no JSON: URL notificada ao fim do processamento e código
+	// ISO 4217 (vazio vale BRL)
+	CallbackUrl   string `protobuf:"bytes,3,opt,name=callback_url,json=callbackUrl,proto3" json:"callback_url,omitempty"`
+	Currency      string `protobuf:"bytes,4,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitPaymentRequest) Reset() {
+	*x = SubmitPaymentRequest{}
+	mi := &file_paymentspb_payments_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitPaymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitPaymentRequest) ProtoMessage() {}
+
+func (x *SubmitPaymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentspb_payments_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitPaymentRequest.ProtoReflect.Descriptor instead.
+func (*SubmitPaymentRequest) Descriptor() ([]byte, []int) {
+	return file_paymentspb_payments_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubmitPaymentRequest) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+func (x *SubmitPaymentRequest) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *SubmitPaymentRequest) GetCallbackUrl() string {
+	if x != nil {
+		return x.CallbackUrl
+	}
+	return ""
+}
+
+func (x *SubmitPaymentRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+type SubmitPaymentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitPaymentResponse) Reset() {
+	*x = SubmitPaymentResponse{}
+	mi := &file_paymentspb_payments_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitPaymentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitPaymentResponse) ProtoMessage() {}
+
+func (x *SubmitPaymentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentspb_payments_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitPaymentResponse.ProtoReflect.Descriptor instead.
+func (*SubmitPaymentResponse) Descriptor() ([]byte, []int) {
+	return file_paymentspb_payments_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SubmitPaymentResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type GetSummaryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Limites opcionais de requestedAt em ISO 8601
+	From string `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To   string `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	// Aguarda as escritas em andamento, como ?consistent=true
+	Consistent bool `protobuf:"varint,3,opt,name=consistent,proto3" json:"consistent,omitempty"`
+	// Ignora o cache do resumo sem filtro, como ?nocache=true
+	Nocache       bool `protobuf:"varint,4,opt,name=nocache,proto3" json:"nocache,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSummaryRequest) Reset() {
+	*x = GetSummaryRequest{}
+	mi := &file_paymentspb_payments_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSummaryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSummaryRequest) ProtoMessage() {}
+
+func (x *GetSummaryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentspb_payments_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSummaryRequest.ProtoReflect.Descriptor instead.
+func (*GetSummaryRequest) Descriptor() ([]byte, []int) {
+	return file_paymentspb_payments_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetSummaryRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *GetSummaryRequest) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *GetSummaryRequest) GetConsistent() bool {
+	if x != nil {
+		return x.Consistent
+	}
+	return false
+}
+
+func (x *GetSummaryRequest) GetNocache() bool {
+	if x != nil {
+		return x.Nocache
+	}
+	return false
+}
+
+type ProcessorSummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TotalRequests int64                  `protobuf:"varint,1,opt,name=total_requests,json=totalRequests,proto3" json:"total_requests,omitempty"`
+	TotalAmount   float64                `protobuf:"fixed64,2,opt,name=total_amount,json=totalAmount,proto3" json:"total_amount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProcessorSummary) Reset() {
+	*x = ProcessorSummary{}
+	mi := &file_paymentspb_payments_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProcessorSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessorSummary) ProtoMessage() {}
+
+func (x *ProcessorSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentspb_payments_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessorSummary.ProtoReflect.Descriptor instead.
+func (*ProcessorSummary) Descriptor() ([]byte, []int) {
+	return file_paymentspb_payments_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ProcessorSummary) GetTotalRequests() int64 {
+	if x != nil {
+		return x.TotalRequests
+	}
+	return 0
+}
+
+func (x *ProcessorSummary) GetTotalAmount() float64 {
+	if x != nil {
+		return x.TotalAmount
+	}
+	return 0
+}
+
+type GetSummaryResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Default  *ProcessorSummary      `protobuf:"bytes,1,opt,name=default,proto3" json:"default,omitempty"`
+	Fallback *ProcessorSummary      `protobuf:"bytes,2,opt,name=fallback,proto3" json:"fallback,omitempty"`
+	// Todos os processors configurados, incluindo default e fallback
+	Processors    map[string]*ProcessorSummary `protobuf:"bytes,3,rep,name=processors,proto3" json:"processors,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSummaryResponse) Reset() {
+	*x = GetSummaryResponse{}
+	mi := &file_paymentspb_payments_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSummaryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSummaryResponse) ProtoMessage() {}
+
+func (x *GetSummaryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentspb_payments_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSummaryResponse.ProtoReflect.Descriptor instead.
+func (*GetSummaryResponse) Descriptor() ([]byte, []int) {
+	return file_paymentspb_payments_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetSummaryResponse) GetDefault() *ProcessorSummary {
+	if x != nil {
+		return x.Default
+	}
+	return nil
+}
+
+func (x *GetSummaryResponse) GetFallback() *ProcessorSummary {
+	if x != nil {
+		return x.Fallback
+	}
+	return nil
+}
+
+func (x *GetSummaryResponse) GetProcessors() map[string]*ProcessorSummary {
+	if x != nil {
+		return x.Processors
+	}
+	return nil
+}
+
+var File_paymentspb_payments_proto protoreflect.FileDescriptor
+
+const file_paymentspb_payments_proto_rawDesc = "" +
+	"\n" +
+	"\x19paymentspb/payments.proto\x12\vpayments.v1\"\x94\x01\n" +
+	"\x14SubmitPaymentRequest\x12%\n" +
+	"\x0ecorrelation_id\x18\x01 \x01(\tR\rcorrelationId\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x01R\x06amount\x12!\n" +
+	"\fcallback_url\x18\x03 \x01(\tR\vcallbackUrl\x12\x1a\n" +
+	"\bcurrency\x18\x04 \x01(\tR\bcurrency\"1\n" +
+	"\x15SubmitPaymentResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"q\n" +
+	"\x11GetSummaryRequest\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\tR\x02to\x12\x1e\n" +
+	"\n" +
+	"consistent\x18\x03 \x01(\bR\n" +
+	"consistent\x12\x18\n" +
+	"\anocache\x18\x04 \x01(\bR\anocache\"\\\n" +
+	"\x10ProcessorSummary\x12%\n" +
+	"\x0etotal_requests\x18\x01 \x01(\x03R\rtotalRequests\x12!\n" +
+	"\ftotal_amount\x18\x02 \x01(\x01R\vtotalAmount\"\xb7\x02\n" +
+	"\x12GetSummaryResponse\x127\n" +
+	"\adefault\x18\x01 \x01(\v2\x1d.payments.v1.ProcessorSummaryR\adefault\x129\n" +
+	"\bfallback\x18\x02 \x01(\v2\x1d.payments.v1.ProcessorSummaryR\bfallback\x12O\n" +
+	"\n" +
+	"processors\x18\x03 \x03(\v2/.payments.v1.GetSummaryResponse.ProcessorsEntryR\n" +
+	"processors\x1a\\\n" +
+	"\x0fProcessorsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x123\n" +
+	"\x05value\x18\x02 \x01(\v2\x1d.payments.v1.ProcessorSummaryR\x05value:\x028\x012\xb7\x01\n" +
+	"\x0ePaymentService\x12V\n" +
+	"\rSubmitPayment\x12!.payments.v1.SubmitPaymentRequest\x1a\".payments.v1.SubmitPaymentResponse\x12M\n" +
+	"\n" +
+	"GetSummary\x12\x1e.payments.v1.GetSummaryRequest\x1a\x1f.payments.v1.GetSummaryResponseB\x1fZ\x1drinha-backend-2025/paymentspbb\x06proto3"
+
+var (
+	file_paymentspb_payments_proto_rawDescOnce sync.Once
+	file_paymentspb_payments_proto_rawDescData []byte
+)
+
+func file_paymentspb_payments_proto_rawDescGZIP() []byte {
+	file_paymentspb_payments_proto_rawDescOnce.Do(func() {
+		file_paymentspb_payments_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_paymentspb_payments_proto_rawDesc), len(file_paymentspb_payments_proto_rawDesc)))
+	})
+	return file_paymentspb_payments_proto_rawDescData
+}
+
+var file_paymentspb_payments_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_paymentspb_payments_proto_goTypes = []any{
+	(*SubmitPaymentRequest)(nil),  // 0: payments.v1.SubmitPaymentRequest
+	(*SubmitPaymentResponse)(nil), // 1: payments.v1.SubmitPaymentResponse
+	(*GetSummaryRequest)(nil),     // 2: payments.v1.GetSummaryRequest
+	(*ProcessorSummary)(nil),      // 3: payments.v1.ProcessorSummary
+	(*GetSummaryResponse)(nil),    // 4: payments.v1.GetSummaryResponse
+	nil,                           // 5: payments.v1.GetSummaryResponse.ProcessorsEntry
+}
+var file_paymentspb_payments_proto_depIdxs = []int32{
+	3, // 0: payments.v1.GetSummaryResponse.default:type_name -> payments.v1.ProcessorSummary
+	3, // 1: payments.v1.GetSummaryResponse.fallback:type_name -> payments.v1.ProcessorSummary
+	5, // 2: payments.v1.GetSummaryResponse.processors:type_name -> payments.v1.GetSummaryResponse.ProcessorsEntry
+	3, // 3: payments.v1.GetSummaryResponse.ProcessorsEntry.value:type_name -> payments.v1.ProcessorSummary
+	0, // 4: payments.v1.PaymentService.SubmitPayment:input_type -> payments.v1.SubmitPaymentRequest
+	2, // 5: payments.v1.PaymentService.GetSummary:input_type -> payments.v1.GetSummaryRequest
+	1, // 6: payments.v1.PaymentService.SubmitPayment:output_type -> payments.v1.SubmitPaymentResponse
+	4, // 7: payments.v1.PaymentService.GetSummary:output_type -> payments.v1.GetSummaryResponse
+	6, // [6:8] is the sub-list for method output_type
+	4, // [4:6] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_paymentspb_payments_proto_init() }
+func file_paymentspb_payments_proto_init() {
+	if File_paymentspb_payments_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_paymentspb_payments_proto_rawDesc), len(file_paymentspb_payments_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_paymentspb_payments_proto_goTypes,
+		DependencyIndexes: file_paymentspb_payments_proto_depIdxs,
+		MessageInfos:      file_paymentspb_payments_proto_msgTypes,
+	}.Build()
+	File_paymentspb_payments_proto = out.File
+	file_paymentspb_payments_proto_goTypes = nil
+	file_paymentspb_payments_proto_depIdxs = nil
+}
@@ -1,8 +1,15 @@
-
 CREATE UNLOGGED TABLE payments (
     correlationId UUID PRIMARY KEY,
     amount DECIMAL NOT NULL,
-    requested_at TIMESTAMP NOT NULL
+    processor TEXT NOT NULL,
+    requested_at TIMESTAMP NOT NULL,
+    metadata JSONB
 );
 
-CREATE INDEX payments_requested_at ON payments (requested_at);
\ No newline at end of file
+CREATE INDEX payments_requested_at ON payments (requested_at);
+
+CREATE UNLOGGED TABLE payment_summary (
+    processor TEXT PRIMARY KEY,
+    total_requests BIGINT NOT NULL DEFAULT 0,
+    total_amount DECIMAL NOT NULL DEFAULT 0
+);